@@ -0,0 +1,43 @@
+// Command agent runs on a Docker host and connects it to the DockerManager backend, which then
+// manages the host's containers through this connection instead of SSH.
+//
+// The backend URL and the server's enrollment token are read from flags or from the
+// DOCKERMANAGER_URL and DOCKERMANAGER_TOKEN environment variables:
+//
+//	agent -url wss://panel.example.com/ws/agent -token dma_...
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"docker-pulse/internal/agent"
+	"docker-pulse/internal/version"
+)
+
+func main() {
+	cfg := agent.Config{Version: version.Version}
+	flag.StringVar(&cfg.URL, "url", os.Getenv("DOCKERMANAGER_URL"), "backend agent endpoint, e.g. wss://panel.example.com/ws/agent")
+	flag.StringVar(&cfg.Token, "token", os.Getenv("DOCKERMANAGER_TOKEN"), "enrollment token of the server")
+	flag.StringVar(&cfg.DockerSocket, "docker-socket", "/var/run/docker.sock", "path of the Docker daemon's socket")
+	flag.BoolVar(&cfg.InsecureSkipVerify, "insecure", false, "accept any TLS certificate of the backend")
+	flag.Parse()
+	if cfg.URL == "" || cfg.Token == "" {
+		slog.Error("the backend URL and the enrollment token are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	slog.Info("starting agent", "version", version.String(), "docker_socket", cfg.DockerSocket)
+	if err := agent.Run(ctx, cfg); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("agent stopped", "error", err)
+		os.Exit(1)
+	}
+}
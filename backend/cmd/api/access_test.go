@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/api/handler"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// accessCase is a mutating route and the access level it requires: read, manage or full on the
+// server, or the admin role
+type accessCase struct {
+	method, route, path, body, level string
+}
+
+var accessCases = []accessCase{
+	{"POST", "/servers/:id/test-connection", "/servers/1/test-connection", ``, "admin"},
+	{"POST", "/servers/:id/reset-circuit", "/servers/1/reset-circuit", ``, "admin"},
+	{"PUT", "/servers/:id", "/servers/1", `{"description":"edited"}`, "admin"},
+	{"POST", "/servers/:id/agent/token", "/servers/1/agent/token", ``, "admin"},
+	{"DELETE", "/servers/:id/agent/token", "/servers/1/agent/token", ``, "admin"},
+	{"PUT", "/servers/:id/docker/daemon-config", "/servers/1/docker/daemon-config", `{"config":{}}`, "admin"},
+	{"POST", "/servers/:id/ports/check", "/servers/1/ports/check", `{"ports":["8080:80"]}`, model.AccessLevelRead},
+	{"POST", "/servers/:id/docker/prune", "/servers/1/docker/prune", `{"types":["images"],"dry_run":true}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/containers", "/servers/1/containers", `{"image":"nginx","name":"web"}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/containers/action", "/servers/1/containers/action", `{"server_id":1,"container_id":"abc123","action":"restart"}`, model.AccessLevelManage},
+	{"POST", "/servers/:id/containers/action", "/servers/1/containers/action", `{"server_id":1,"container_id":"abc123","action":"remove"}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/containers/batch-action", "/servers/1/containers/batch-action", `{"action":"stop","container_ids":["abc123"]}`, model.AccessLevelManage},
+	{"POST", "/servers/:id/containers/batch-action", "/servers/1/containers/batch-action", `{"action":"remove","container_ids":["abc123"]}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/containers/:containerID/logs/truncate", "/servers/1/containers/abc123/logs/truncate", `{"confirm":"abc123"}`, model.AccessLevelFull},
+	{"PUT", "/servers/:id/containers/:containerID/log-config", "/servers/1/containers/abc123/log-config", `{"max_size":"10m","max_file":3}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/containers/:containerID/logs/search", "/servers/1/containers/abc123/logs/search", `{"pattern":"error"}`, model.AccessLevelRead},
+	{"PUT", "/servers/:id/containers/:containerID/restart-policy", "/servers/1/containers/abc123/restart-policy", `{"policy":"always"}`, model.AccessLevelManage},
+	{"PUT", "/servers/:id/containers/:containerID/auto-update", "/servers/1/containers/abc123/auto-update", `{"enabled":true}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/containers/:containerID/files/op", "/servers/1/containers/abc123/files/op", `{"op":"mkdir","path":"/tmp/new"}`, model.AccessLevelManage},
+	{"POST", "/servers/:id/containers/:containerID/files/op", "/servers/1/containers/abc123/files/op", `{"op":"delete","path":"/tmp/old"}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/images/pull", "/servers/1/images/pull", `{"image":"nginx","tag":"1.27"}`, model.AccessLevelManage},
+	{"POST", "/servers/:id/images/build", "/servers/1/images/build", `{"tag":"app:1","dockerfile":"FROM scratch"}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/volumes/prune", "/servers/1/volumes/prune", `{"dry_run":true}`, model.AccessLevelFull},
+	{"DELETE", "/servers/:id/volumes/:name", "/servers/1/volumes/data", ``, model.AccessLevelFull},
+	{"POST", "/servers/:id/networks", "/servers/1/networks", `{"name":"backend"}`, model.AccessLevelManage},
+	{"DELETE", "/servers/:id/networks/:name", "/servers/1/networks/backend", ``, model.AccessLevelFull},
+	{"POST", "/servers/:id/networks/:name/connect", "/servers/1/networks/backend/connect", `{"container":"abc123"}`, model.AccessLevelManage},
+	{"POST", "/servers/:id/networks/:name/disconnect", "/servers/1/networks/backend/disconnect", `{"container":"abc123"}`, model.AccessLevelManage},
+	{"POST", "/servers/:id/compose/:project/action", "/servers/1/compose/shop/action", `{"action":"restart"}`, model.AccessLevelManage},
+	{"POST", "/servers/:id/compose/:project/action", "/servers/1/compose/shop/action", `{"action":"down"}`, model.AccessLevelFull},
+	{"PUT", "/servers/:id/compose/:project/file", "/servers/1/compose/shop/file", `{"content":"services: {}"}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/swarm/services/:name/scale", "/servers/1/swarm/services/api/scale", `{"replicas":2}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/swarm/services/:name/update", "/servers/1/swarm/services/api/update", `{"force":true}`, model.AccessLevelFull},
+	{"POST", "/servers/:id/tasks", "/servers/1/tasks", `{"name":"nightly","target_type":"container","target":"web","type":"restart","cron":"0 3 * * *"}`, model.AccessLevelManage},
+	{"PUT", "/servers/:id/tasks/:taskID", "/servers/1/tasks/1", `{"name":"nightly","target_type":"container","target":"web","type":"restart","cron":"0 4 * * *"}`, model.AccessLevelManage},
+	{"DELETE", "/servers/:id/tasks/:taskID", "/servers/1/tasks/1", ``, model.AccessLevelManage},
+	{"POST", "/servers/:id/containers/from-template/:templateID", "/servers/1/containers/from-template/1", `{"name":"web"}`, model.AccessLevelFull},
+	// Last, as the admin removes the server
+	{"DELETE", "/servers/:id", "/servers/1", ``, "admin"},
+}
+
+// TestMutationsRequireAccessLevel sends every mutating server route as users holding each access
+// level. Users below the route's level are refused with 403; the others get past the check, which
+// for most routes means reaching SSH and failing against the closed port the server points at.
+func TestMutationsRequireAccessLevel(t *testing.T) {
+	env := newTestEnv(t)
+	router := apiRouter(context.Background(), env.db, env.config(), env.startup, env.backups)
+
+	// Every mutating server route has a case, so new ones are not left out
+	covered := map[string]bool{}
+	for _, tc := range accessCases {
+		covered[tc.method+" /api/v1"+tc.route] = true
+	}
+	for _, route := range router.Routes() {
+		if route.Method != http.MethodGet && strings.HasPrefix(route.Path, "/api/v1/servers/:id") && !covered[route.Method+" "+route.Path] {
+			t.Errorf("%s %s has no access case", route.Method, route.Path)
+		}
+	}
+
+	server := model.Server{Name: "web", IP: "127.0.0.1", Port: closedPort(t), Username: "root", AuthMode: "password", Secret: "secret", ConnectionMode: model.ConnectionModeCLI}
+	if err := env.db.Create(&server).Error; err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+	// The failed connections open the server's circuit, which is kept per server ID
+	t.Cleanup(func() { ssh.ResetCircuit(server.ID) })
+	task := model.ScheduledTask{ServerID: server.ID, Name: "nightly", TargetType: "container", Target: "web", Type: "restart", Cron: "0 3 * * *"}
+	if err := env.db.Create(&task).Error; err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	tokens := map[string]string{}
+	for _, level := range []string{"none", model.AccessLevelRead, model.AccessLevelManage, model.AccessLevelFull, "admin"} {
+		role := "user"
+		if level == "admin" {
+			role = "admin"
+		}
+		user := model.User{Username: level, Password: "password", Role: role}
+		if err := env.db.Create(&user).Error; err != nil {
+			t.Fatalf("create user %s: %v", level, err)
+		}
+		if role == "user" && level != "none" {
+			if err := env.db.Create(&model.ServerPermission{UserID: user.ID, ServerID: server.ID, AccessLevel: level}).Error; err != nil {
+				t.Fatalf("grant %s: %v", level, err)
+			}
+		}
+		tokens[level] = signToken(t, env.config().JWTSecret, user)
+	}
+
+	rank := map[string]int{"none": 0, model.AccessLevelRead: 1, model.AccessLevelManage: 2, model.AccessLevelFull: 3, "admin": 4}
+	for _, tc := range accessCases {
+		for _, level := range []string{"none", model.AccessLevelRead, model.AccessLevelManage, model.AccessLevelFull, "admin"} {
+			req := httptest.NewRequest(tc.method, "/api/v1"+tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tokens[level])
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			allowed := rank[level] >= rank[tc.level]
+			if denied := w.Code == http.StatusForbidden; denied == allowed {
+				t.Errorf("%s %s %s as %s = %d %s, want allowed=%t", tc.method, tc.path, tc.body, level, w.Code, w.Body.String(), allowed)
+			}
+			if w.Code == http.StatusUnauthorized {
+				t.Errorf("%s %s as %s was not authenticated: %s", tc.method, tc.path, level, w.Body.String())
+			}
+		}
+	}
+}
+
+// closedPort returns a local port nothing listens on, so that SSH connections are refused at once
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func signToken(t *testing.T, secret string, user model.User) string {
+	t.Helper()
+	claims := &handler.Claims{
+		UserID:           user.ID,
+		Username:         user.Username,
+		Role:             user.Role,
+		TokenVersion:     user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
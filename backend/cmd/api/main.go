@@ -1,19 +1,44 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/hex"
-	"io"
-	"log"
+	"errors"
+	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"docker-pulse/internal/api/handler"
 	"docker-pulse/internal/api/middleware"
+	"docker-pulse/internal/api/openapi"
+	"docker-pulse/internal/api/static"
 	"docker-pulse/internal/api/websocket"
+	"docker-pulse/internal/autoupdate"
+	"docker-pulse/internal/backup"
 	"docker-pulse/internal/bot"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/certs"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/demo"
+	"docker-pulse/internal/events"
+	"docker-pulse/internal/listen"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/maintenance"
+	"docker-pulse/internal/metrics"
+	"docker-pulse/internal/migrate"
 	"docker-pulse/internal/model"
+	"docker-pulse/internal/notify"
+	"docker-pulse/internal/schedule"
+	"docker-pulse/internal/secretbox"
+	"docker-pulse/internal/ssh"
 	"docker-pulse/internal/stats"
+	"docker-pulse/internal/version"
 
 	"embed"
 	"io/fs"
@@ -22,6 +47,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -30,7 +57,9 @@ import (
 var staticFiles embed.FS
 
 const (
-	jwtSecretFile = "data/.sk"
+	jwtSecretFileName = ".sk"
+	// gzipMinSize is the smallest API response that is compressed
+	gzipMinSize = 1024
 )
 
 type Config struct {
@@ -41,48 +70,67 @@ type Config struct {
 }
 
 func getConfigValue(db *gorm.DB, key string) string {
-	var config model.Config
-	db.Where("key = ?", key).First(&config)
-	return config.Value
+	value, _ := config.Get(db, key)
+	return value
 }
 
-func loadConfig(db *gorm.DB) Config {
-	jwtSecret := loadOrCreateJWTSecret()
+func loadConfig(db *gorm.DB, startup *config.Startup) Config {
+	jwtSecret := loadJWTSecret(startup)
 
+	if startup.BotToken.Set() {
+		config.Override(model.ConfigKeyTelegramBotToken, startup.BotToken.Value, startup.BotToken.Source)
+		slog.Info("using Telegram bot token from the environment, the stored value is ignored", "source", startup.BotToken.Env)
+	}
 	botToken := getConfigValue(db, model.ConfigKeyTelegramBotToken)
 	webAppURL := getConfigValue(db, model.ConfigKeyTelegramWebAppURL)
 
 	if botToken == "" {
-		log.Println("Telegram Bot Token is not configured in DB. Bot will not start.")
+		slog.Info("Telegram Bot Token is not configured in DB. Bot will not start.")
 	}
 
 	return Config{
 		JWTSecret:  jwtSecret,
 		BotToken:   botToken,
 		WebAppURL:  webAppURL,
-		ListenAddr: ":9090",
+		ListenAddr: startup.ListenAddr,
 	}
 }
 
-func loadOrCreateJWTSecret() string {
-	secretBytes, err := os.ReadFile(jwtSecretFile)
+// loadJWTSecret prefers the secret from the environment over the generated file. Switching
+// between them, or changing the provided secret, invalidates every existing session.
+func loadJWTSecret(startup *config.Startup) string {
+	path := startup.DataPath(jwtSecretFileName)
+	if !startup.JWTSecret.Set() {
+		return loadOrCreateJWTSecret(path)
+	}
+	if _, err := os.Stat(path); err == nil {
+		slog.Warn("JWT secret from the environment takes precedence over the secret file; sessions signed with the file secret must log in again",
+			"source", startup.JWTSecret.Env, "ignored", path)
+	} else {
+		slog.Info("Loaded JWT secret", "source", startup.JWTSecret.Env)
+	}
+	return startup.JWTSecret.Value
+}
+
+func loadOrCreateJWTSecret(path string) string {
+	secretBytes, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			log.Println("JWT secret file not found, generating a new one...")
+			slog.Info("JWT secret file not found, generating a new one")
 			newSecret, err := generateRandomString(32)
 			if err != nil {
-				log.Fatalf("failed to generate JWT secret: %v", err)
+				logging.Fatal("failed to generate JWT secret", "error", err)
 			}
-			err = os.WriteFile(jwtSecretFile, []byte(newSecret), 0600)
+			err = os.WriteFile(path, []byte(newSecret), 0600)
 			if err != nil {
-				log.Fatalf("failed to write JWT secret to file: %v", err)
+				logging.Fatal("failed to write JWT secret to file", "error", err)
 			}
-			log.Printf("Generated and saved new JWT secret to %s", jwtSecretFile)
+			slog.Info("Generated and saved new JWT secret", "path", path)
 			return newSecret
 		}
-		log.Fatalf("failed to read JWT secret file: %v", err)
+		logging.Fatal("failed to read JWT secret file", "error", err)
 	}
-	log.Printf("Loaded JWT secret from %s", jwtSecretFile)
+	slog.Info("Loaded JWT secret", "path", path)
 	return string(secretBytes)
 }
 
@@ -94,25 +142,49 @@ func generateRandomString(length int) (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-func initDB() *gorm.DB {
-	newLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             time.Second,
-			LogLevel:                  logger.Warn,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  false,
-		},
-	)
-
-	db, err := gorm.Open(sqlite.Open("data/dockerpulse.db"), &gorm.Config{
-		Logger: newLogger,
+// dialector returns the gorm dialector for the configured database driver
+func dialector(startup *config.Startup) gorm.Dialector {
+	switch startup.DatabaseDriver {
+	case config.DriverPostgres:
+		return postgres.Open(startup.DatabaseDSN)
+	case config.DriverMySQL:
+		return mysql.Open(config.MySQLDSN(startup.DatabaseDSN))
+	default:
+		return sqlite.Open(startup.DatabaseDSN)
+	}
+}
+
+func initDB(startup *config.Startup) *gorm.DB {
+	logLevel := logger.Warn
+	switch startup.LogLevel {
+	case "debug":
+		logLevel = logger.Info
+	case "error":
+		logLevel = logger.Error
+	}
+
+	db, err := gorm.Open(dialector(startup), &gorm.Config{
+		Logger: logging.NewGormLogger(logLevel),
 	})
 	if err != nil {
-		log.Fatalf("failed to connect database: %v", err)
+		logging.Fatal("failed to connect database", "error", err)
 	}
 
-	db.AutoMigrate(&model.User{}, &model.Server{}, &model.ServerPermission{}, &model.Config{}, &model.StatsHistory{})
+	if startup.MigrateRollback > 0 {
+		if err := migrate.Rollback(db, startup.MigrateRollback); err != nil {
+			logging.Fatal("failed to roll back migrations", "error", err)
+		}
+		os.Exit(0)
+	}
+	if err := migrate.Run(db); err != nil {
+		logging.Fatal("failed to run migrations", "error", err)
+	}
+	if startup.AutoMigrate {
+		slog.Warn("running AutoMigrate, schema changes should be added as migrations")
+		if err := db.AutoMigrate(model.All()...); err != nil {
+			logging.Fatal("AutoMigrate failed", "error", err)
+		}
+	}
 
 	var count int64
 	db.Model(&model.User{}).Count(&count)
@@ -123,45 +195,131 @@ func initDB() *gorm.DB {
 			Role:     "admin",
 		}
 		db.Create(&admin)
-		log.Println("Created initial admin user. Password: 'admin123'")
+		slog.Warn("Created initial admin user with the default password 'admin123', change it after the first login")
 	}
 
 	return db
 }
 
-func setupRouter(db *gorm.DB, cfg Config) http.Handler {
-	// Create a Gin router for API routes
-	ginRouter := gin.Default()
-	ginRouter.Use(middleware.CORSMiddleware())
+// apiRouter builds the Gin router serving the API, WebSocket and metrics routes below the base path
+func apiRouter(ctx context.Context, db *gorm.DB, cfg Config, startup *config.Startup, backups *backup.Service) *gin.Engine {
+	ginRouter := gin.New()
+	ginRouter.Use(logging.Middleware(), middleware.Metrics(), middleware.Recovery())
+	if err := ginRouter.SetTrustedProxies(startup.TrustedProxies); err != nil {
+		logging.Fatal("invalid trusted proxies", "error", err)
+	}
+	ginRouter.Use(middleware.Gzip(gzipMinSize))
+	ginRouter.Use(middleware.CORSMiddleware(db))
+	// Demo mode is enforced here for every route, including ones added later
+	ginRouter.Use(middleware.Demo(startup.BasePath + "/api/v1/login"))
+
+	// All routes live below the base path, which is empty unless the panel is served under a sub-path
+	base := startup.BasePath
 
 	// API routes
-	public := ginRouter.Group("/api/v1")
+	public := ginRouter.Group(base + "/api/v1")
 	{
 		public.POST("/login", handler.Login(db, cfg.JWTSecret))
+		public.GET("/status", handler.GetStatus())
 	}
 
-	auth := ginRouter.Group("/api/v1")
-	auth.Use(middleware.AuthMiddleware(db, cfg.JWTSecret))
+	// Routes that wait on SSH commands give up instead of holding the request when a server hangs
+	sshTimeout := middleware.Timeout(startup.SSHTimeout)
+	actionTimeout := middleware.Timeout(startup.ActionTimeout)
+
+	auth := ginRouter.Group(base + "/api/v1")
+	auth.Use(middleware.AuthMiddleware(db, cfg.JWTSecret), middleware.Maintenance())
 	{
 		// Server Management
 		auth.GET("/servers", handler.ListServers(db))
 		auth.GET("/servers/:id", handler.GetServer(db))
 		auth.POST("/servers", middleware.RoleCheck("admin"), handler.CreateServer(db))
+		auth.POST("/servers/test-connection", middleware.RoleCheck("admin"), handler.TestServerConnection(db))
 		auth.PUT("/servers/:id", middleware.RoleCheck("admin"), handler.UpdateServer(db))
 		auth.DELETE("/servers/:id", middleware.RoleCheck("admin"), handler.DeleteServer(db))
-		auth.GET("/servers/:id/stats", handler.GetServerStats(db))
+		auth.GET("/servers/:id/stats", sshTimeout, handler.GetServerStats(db))
+		auth.GET("/servers/:id/system-info", sshTimeout, handler.GetSystemInfo(db))
+		auth.POST("/servers/:id/test-connection", middleware.RoleCheck("admin"), sshTimeout, handler.TestConnection(db))
+		auth.POST("/servers/:id/reset-circuit", middleware.RoleCheck("admin"), handler.ResetServerCircuit(db))
+		auth.GET("/servers/:id/agent", handler.GetAgentStatus(db))
+		auth.POST("/servers/:id/agent/token", middleware.RoleCheck("admin"), handler.CreateAgentToken(db))
+		auth.DELETE("/servers/:id/agent/token", middleware.RoleCheck("admin"), handler.DeleteAgentToken(db))
+		auth.GET("/servers/:id/ports", sshTimeout, handler.ListPorts(db))
+		auth.POST("/servers/:id/ports/check", sshTimeout, handler.CheckPorts(db))
+		auth.GET("/servers/:id/docker/daemon-config", middleware.RoleCheck("admin"), sshTimeout, handler.GetDaemonConfig(db))
+		auth.PUT("/servers/:id/docker/daemon-config", middleware.RoleCheck("admin"), actionTimeout, handler.UpdateDaemonConfig(db))
+		auth.GET("/servers/:id/docker/disk-usage", actionTimeout, handler.GetDockerDiskUsage(db))
+		auth.POST("/servers/:id/docker/prune", actionTimeout, handler.PruneDocker(db))
 		auth.GET("/servers/stats/history", handler.GetStatsHistory(db))
+		auth.GET("/servers/:id/stats/disk-history", handler.GetDiskHistory(db))
 
 		// Container Management
-		auth.GET("/servers/:id/containers", handler.ListContainers(db))
-		auth.POST("/servers/:id/containers/action", handler.ContainerAction(db))
-		auth.GET("/servers/:id/containers/:containerID/logs", handler.GetContainerLogs(db))
-		auth.GET("/servers/:id/containers/:containerID/details", handler.GetContainerDetails(db))
-		auth.GET("/servers/:id/containers/:containerID/check-update", handler.CheckContainerImageUpdate(db))
+		auth.GET("/servers/:id/containers", sshTimeout, handler.ListContainers(db))
+		auth.GET("/servers/:id/containers/stats", sshTimeout, handler.ListContainerStats(db))
+		auth.POST("/servers/:id/containers", actionTimeout, handler.CreateContainer(db))
+		auth.POST("/servers/:id/containers/action", actionTimeout, handler.ContainerAction(db))
+		auth.POST("/servers/:id/containers/batch-action", actionTimeout, handler.BatchContainerAction(db))
+		auth.GET("/tasks/:id", handler.GetPullTask(db))
+		auth.GET("/servers/:id/containers/:containerID/logs", sshTimeout, handler.GetContainerLogs(db))
+		auth.POST("/servers/:id/containers/:containerID/logs/truncate", sshTimeout, handler.TruncateContainerLog(db))
+		auth.PUT("/servers/:id/containers/:containerID/log-config", sshTimeout, handler.SetContainerLogConfig(db))
+		auth.POST("/servers/:id/containers/:containerID/logs/search", sshTimeout, handler.SearchContainerLogs(db))
+		auth.GET("/servers/:id/log-usage", sshTimeout, handler.GetLogUsage(db))
+		auth.GET("/servers/:id/containers/:containerID/details", sshTimeout, handler.GetContainerDetails(db))
+		auth.GET("/servers/:id/containers/:containerID/health", sshTimeout, handler.GetContainerHealth(db))
+		auth.GET("/servers/:id/containers/:containerID/stats", sshTimeout, handler.GetContainerStats(db))
+		auth.GET("/servers/:id/containers/:containerID/processes", sshTimeout, handler.ListContainerProcesses(db))
+		auth.PUT("/servers/:id/containers/:containerID/restart-policy", sshTimeout, handler.UpdateRestartPolicy(db))
+		auth.GET("/servers/:id/containers/:containerID/check-update", sshTimeout, handler.CheckContainerImageUpdate(db))
+		auth.GET("/servers/:id/containers/:containerID/image-tags", actionTimeout, handler.ListImageTags(db))
+		auth.PUT("/servers/:id/containers/:containerID/auto-update", sshTimeout, handler.SetContainerAutoUpdate(db))
+		auth.GET("/servers/:id/image-updates", handler.ListImageChecks(db))
+		auth.GET("/servers/:id/auto-updates", handler.ListAutoUpdateRuns(db))
 
 		// Container File Management
-		auth.GET("/servers/:id/containers/:containerID/files", handler.ListContainerFiles(db))
-		auth.GET("/servers/:id/containers/:containerID/files/content", handler.GetContainerFileContent(db))
+		auth.GET("/servers/:id/containers/:containerID/files", sshTimeout, handler.ListContainerFiles(db))
+		auth.GET("/servers/:id/containers/:containerID/files/content", sshTimeout, handler.GetContainerFileContent(db))
+		auth.GET("/servers/:id/containers/:containerID/files/download", handler.DownloadContainerFile(db))
+		auth.POST("/servers/:id/containers/:containerID/files/op", sshTimeout, handler.ContainerFileOp(db))
+
+		// Images
+		auth.GET("/servers/:id/images", sshTimeout, handler.ListImages(db))
+		auth.POST("/servers/:id/images/pull", handler.PullImage(db))
+		auth.POST("/servers/:id/images/build", handler.BuildImage(db))
+		auth.GET("/servers/:id/images/builds/:jobID", handler.GetBuild(db))
+
+		// Volumes
+		auth.GET("/servers/:id/volumes", actionTimeout, handler.ListVolumes(db))
+		auth.POST("/servers/:id/volumes/prune", actionTimeout, handler.PruneVolumes(db))
+		auth.GET("/servers/:id/volumes/:name", actionTimeout, handler.GetVolume(db))
+		auth.DELETE("/servers/:id/volumes/:name", sshTimeout, handler.DeleteVolume(db))
+		auth.GET("/servers/:id/volumes/:name/files", sshTimeout, handler.ListVolumeFiles(db))
+		auth.GET("/servers/:id/volumes/:name/files/content", sshTimeout, handler.GetVolumeFileContent(db))
+
+		// Networks
+		auth.GET("/servers/:id/networks", sshTimeout, handler.ListNetworks(db))
+		auth.POST("/servers/:id/networks", sshTimeout, handler.CreateNetwork(db))
+		auth.GET("/servers/:id/networks/:name", sshTimeout, handler.GetNetwork(db))
+		auth.DELETE("/servers/:id/networks/:name", sshTimeout, handler.DeleteNetwork(db))
+		auth.POST("/servers/:id/networks/:name/connect", sshTimeout, handler.ConnectNetwork(db))
+		auth.POST("/servers/:id/networks/:name/disconnect", sshTimeout, handler.DisconnectNetwork(db))
+
+		// Compose Projects
+		auth.GET("/servers/:id/compose", sshTimeout, handler.ListComposeProjects(db))
+		auth.POST("/servers/:id/compose/:project/action", actionTimeout, handler.ComposeProjectAction(db))
+		auth.GET("/servers/:id/compose/:project/file", sshTimeout, handler.GetComposeFile(db))
+		auth.PUT("/servers/:id/compose/:project/file", sshTimeout, handler.UpdateComposeFile(db))
+
+		// Swarm Services
+		auth.POST("/servers/:id/swarm/services/:name/scale", actionTimeout, handler.ScaleSwarmService(db))
+		auth.POST("/servers/:id/swarm/services/:name/update", actionTimeout, handler.UpdateSwarmService(db))
+
+		// Scheduled Tasks
+		auth.GET("/servers/:id/tasks", handler.ListTasks(db))
+		auth.POST("/servers/:id/tasks", handler.CreateTask(db))
+		auth.PUT("/servers/:id/tasks/:taskID", handler.UpdateTask(db))
+		auth.DELETE("/servers/:id/tasks/:taskID", handler.DeleteTask(db))
+		auth.GET("/servers/:id/tasks/:taskID/runs", handler.ListTaskRuns(db))
 
 		// User Management
 		auth.GET("/users", middleware.RoleCheck("admin"), handler.ListUsers(db))
@@ -176,138 +334,386 @@ func setupRouter(db *gorm.DB, cfg Config) http.Handler {
 
 		// Self-service routes
 		auth.PUT("/users/change-password", handler.ChangePassword(db))
-		auth.POST("/users/bind-telegram", handler.BindTelegram(db, cfg.BotToken))
+		auth.POST("/users/bind-telegram", handler.BindTelegram(db))
+		auth.PUT("/users/language", handler.SetLanguage(db))
+		auth.GET("/users/notifications", handler.GetNotificationPreferences(db))
+		auth.PUT("/users/notifications", handler.UpdateNotificationPreferences(db))
 
 		// Config Management
+		auth.GET("/config", middleware.RoleCheck("admin"), handler.GetConfig(db))
+		auth.PUT("/config", middleware.RoleCheck("admin"), handler.UpdateConfig(db))
 		auth.GET("/config/telegram", middleware.RoleCheck("admin"), handler.GetTelegramConfig(db))
 		auth.PUT("/config/telegram", middleware.RoleCheck("admin"), handler.UpdateTelegramConfig(db))
 		auth.GET("/config/latency", middleware.RoleCheck("admin"), handler.GetLatencyConfig(db))
 		auth.PUT("/config/latency", middleware.RoleCheck("admin"), handler.UpdateLatencyConfig(db))
+		auth.POST("/config/smtp/test", middleware.RoleCheck("admin"), handler.TestSMTP(db))
+
+		// Container Templates
+		auth.GET("/container-templates", handler.ListTemplates(db))
+		auth.GET("/container-templates/export", handler.ExportTemplates(db))
+		auth.POST("/container-templates/import", middleware.RoleCheck("admin"), handler.ImportTemplates(db))
+		auth.GET("/container-templates/:id", handler.GetTemplate(db))
+		auth.POST("/container-templates", middleware.RoleCheck("admin"), handler.CreateTemplate(db))
+		auth.PUT("/container-templates/:id", middleware.RoleCheck("admin"), handler.UpdateTemplate(db))
+		auth.DELETE("/container-templates/:id", middleware.RoleCheck("admin"), handler.DeleteTemplate(db))
+		auth.POST("/servers/:id/containers/from-template/:templateID", actionTimeout, handler.CreateFromTemplate(db))
+
+		// Registry logins
+		auth.GET("/registries", middleware.RoleCheck("admin"), handler.ListRegistryCredentials(db))
+		auth.POST("/registries", middleware.RoleCheck("admin"), handler.CreateRegistryCredential(db))
+		auth.PUT("/registries/:id", middleware.RoleCheck("admin"), handler.UpdateRegistryCredential(db))
+		auth.DELETE("/registries/:id", middleware.RoleCheck("admin"), handler.DeleteRegistryCredential(db))
+
+		// Webhooks
+		auth.GET("/webhooks", middleware.RoleCheck("admin"), handler.ListWebhooks(db))
+		auth.POST("/webhooks", middleware.RoleCheck("admin"), handler.CreateWebhook(db))
+		auth.PUT("/webhooks/:id", middleware.RoleCheck("admin"), handler.UpdateWebhook(db))
+		auth.DELETE("/webhooks/:id", middleware.RoleCheck("admin"), handler.DeleteWebhook(db))
+		auth.POST("/webhooks/:id/test", middleware.RoleCheck("admin"), handler.TestWebhook(db))
+		auth.GET("/webhooks/:id/deliveries", middleware.RoleCheck("admin"), handler.ListWebhookDeliveries(db))
+
+		// Backup and restore
+		auth.GET("/admin/backups", middleware.RoleCheck("admin"), handler.ListBackups(backups))
+		auth.POST("/admin/backup", middleware.RoleCheck("admin"), handler.CreateBackup(backups))
+		auth.POST("/admin/restore", middleware.RoleCheck("admin"), handler.RestoreBackup(db, backups))
+		auth.GET("/admin/export", middleware.RoleCheck("admin"), handler.ExportBundle(db))
+		auth.POST("/admin/import", middleware.RoleCheck("admin"), handler.ImportBundle(db))
+
+		auth.GET("/version", handler.GetVersion(db))
+
+		// API documentation
+		auth.GET("/openapi.json", middleware.RoleCheck("admin"), openapi.Handler(base))
+		auth.GET("/docs", middleware.RoleCheck("admin"), openapi.DocsHandler())
+
+		// Diagnostics
+		auth.GET("/debug/cache", middleware.RoleCheck("admin"), handler.GetCacheStats())
+		auth.DELETE("/debug/cache", middleware.RoleCheck("admin"), handler.FlushCaches())
+		auth.GET("/debug/stats", middleware.RoleCheck("admin"), handler.GetRuntimeStats())
 
 		// Telegram WebApp endpoints
 		telegram := auth.Group("/telegram")
 		{
 			telegram.GET("/info", handler.GetTelegramUserInfo(db))
 			telegram.GET("/servers", handler.GetTelegramServerList(db))
-			telegram.GET("/summary", handler.GetTelegramQuickSummary(db))
-			telegram.GET("/servers/:id/stats", handler.GetTelegramServerStats(db))
-			telegram.GET("/servers/:id/containers", handler.GetTelegramContainerStatus(db))
+			telegram.GET("/summary", actionTimeout, handler.GetTelegramQuickSummary(db))
+			telegram.GET("/servers/:id/stats", sshTimeout, handler.GetTelegramServerStats(db))
+			telegram.GET("/servers/:id/containers", sshTimeout, handler.GetTelegramContainerStatus(db))
 		}
 	}
 
+	// Prometheus metrics
+	ginRouter.GET(base+"/metrics", middleware.BearerToken(startup.MetricsToken), gin.WrapH(metrics.Handler()))
+
 	// WebSocket routes
-	ws := ginRouter.Group("/ws")
-	ws.Use(middleware.AuthMiddleware(db, cfg.JWTSecret))
+	ws := ginRouter.Group(base + "/ws")
+	ws.Use(middleware.AuthMiddleware(db, cfg.JWTSecret), middleware.MaintenanceAll())
 	{
 		ws.GET("/terminal", func(c *gin.Context) {
-			websocket.TerminalHandler(c, db)
+			websocket.TerminalHandler(ctx, c, db)
 		})
+		ws.GET("/tasks/:id", func(c *gin.Context) {
+			websocket.TaskHandler(ctx, c, db)
+		})
+		ws.GET("/events", func(c *gin.Context) {
+			websocket.EventsHandler(ctx, c, db)
+		})
+		ws.GET("/logs", func(c *gin.Context) {
+			websocket.LogsHandler(ctx, c, db)
+		})
+	}
+	// Agents authenticate with their server's enrollment token and keep connecting during
+	// maintenance, as they are not user sessions
+	ginRouter.GET(base+"/ws/agent", func(c *gin.Context) {
+		websocket.AgentHandler(ctx, c, db)
+	})
+	return ginRouter
+}
+
+func setupRouter(ctx context.Context, db *gorm.DB, cfg Config, startup *config.Startup, backups *backup.Service) http.Handler {
+	base := startup.BasePath
+	ginRouter := apiRouter(ctx, db, cfg, startup, backups)
+	for _, route := range openapi.Missing(ginRouter.Routes(), base) {
+		slog.Warn("route is missing from the OpenAPI document", "route", route)
 	}
 
 	// Static files and SPA routes
 	staticFS, _ := fs.Sub(staticFiles, "static")
+	staticHandler := http.StripPrefix(base, static.Handler(staticFS, base))
 
 	// Create a custom http.Handler that handles all requests
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return middleware.RecoverHTTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 
-		// Check if it's an API or WS request
-		if strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/ws/") {
-			// Let Gin handle these
-			ginRouter.ServeHTTP(w, r)
-			return
-		}
-
-		// Try to serve the requested file from staticFS
-		// Remove leading slash for fs.Open
-		fsPath := strings.TrimPrefix(path, "/")
-		f, err := staticFS.Open(fsPath)
-		if err == nil {
-			// File exists, serve it
-			defer f.Close()
-			
-			// Set appropriate content type based on file extension
-			if strings.HasSuffix(path, ".js") {
-				w.Header().Set("Content-Type", "application/javascript")
-			} else if strings.HasSuffix(path, ".css") {
-				w.Header().Set("Content-Type", "text/css")
-			} else if strings.HasSuffix(path, ".html") {
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			} else if strings.HasSuffix(path, ".svg") {
-				w.Header().Set("Content-Type", "image/svg+xml")
+		if base != "" {
+			if path == base {
+				target := base + "/"
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
 			}
-			
-			if _, err := io.Copy(w, f); err != nil {
-				log.Printf("Error copying file %s: %v", path, err)
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte("Internal Server Error"))
+			if !strings.HasPrefix(path, base+"/") {
+				http.NotFound(w, r)
 				return
 			}
-			return
 		}
 
-		// File doesn't exist, serve index.html for SPA routing
-		log.Printf("Serving index.html for path: %s", path)
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		
-		// Open index.html
-		indexFile, err := staticFS.Open("index.html")
-		if err != nil {
-			log.Printf("Error opening index.html: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Internal Server Error"))
-			return
-		}
-		defer indexFile.Close()
-		
-		// Copy content to response
-		if _, err := io.Copy(w, indexFile); err != nil {
-			log.Printf("Error copying index.html: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Internal Server Error"))
+		// Check if it's an API or WS request
+		if strings.HasPrefix(path, base+"/api/") || strings.HasPrefix(path, base+"/ws/") || path == base+"/metrics" {
+			// Let Gin handle these
+			ginRouter.ServeHTTP(w, r)
 			return
 		}
-	})
+
+		staticHandler.ServeHTTP(w, r)
+	}))
+}
+
+var (
+	botMu      sync.Mutex
+	botHandler *bot.BotHandler
+	// botToken and botWebAppURL are the settings the running bot was started with
+	botToken, botWebAppURL string
+)
+
+// sendTelegram delivers a notification through the running bot
+func sendTelegram(r notify.Recipient, m notify.Message) error {
+	botMu.Lock()
+	h := botHandler
+	botMu.Unlock()
+	if h == nil {
+		return errors.New("the Telegram bot is not running")
+	}
+	return h.SendMessage(r.TelegramID, m.Subject+"\n\n"+m.Text)
+}
+
+// restartBot stops the running Telegram bot (if any) and starts a new one with the given settings.
+// A bot already running with them is kept.
+func restartBot(token, webAppURL string) error {
+	botMu.Lock()
+	defer botMu.Unlock()
+
+	if botHandler != nil && token == botToken && webAppURL == botWebAppURL {
+		return nil
+	}
+	if botHandler != nil {
+		botHandler.Stop()
+		botHandler = nil
+		slog.Info("Telegram Bot stopped")
+	}
+	if token == "" {
+		return nil
+	}
+
+	h, err := bot.NewBotHandler(token, webAppURL)
+	if err != nil {
+		return err
+	}
+	botHandler, botToken, botWebAppURL = h, token, webAppURL
+	go h.Start()
+	slog.Info("Telegram Bot started")
+	return nil
 }
 
 func main() {
-	log.Println("DockerManager | Verison 1.0.7")
-	db := initDB()
-	cfg := loadConfig(db)
-	stats.StartCollector(db)
+	startup, err := config.LoadStartup(os.Args[1:])
+	if err != nil {
+		logging.Fatal("invalid startup configuration", "error", err)
+	}
+	logging.Setup(startup.LogLevel, startup.LogFormat)
+	slog.Info("DockerManager | Version " + version.String())
+	gin.SetMode(startup.GinMode)
+	if err := os.MkdirAll(startup.DataDir, 0700); err != nil {
+		logging.Fatal("failed to create data directory", "path", startup.DataDir, "error", err)
+	}
+	slog.Info("effective configuration", "config", startup.String())
+	config.SetBasePath(startup.BasePath)
+
+	// ctx is cancelled on SIGINT/SIGTERM and stops every background worker
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The master key comes first, as migrations encrypt the secrets they move
+	if err := secretbox.SetKey(startup.MasterKey.Value); err != nil {
+		logging.Fatal("failed to set up the master key", "error", err)
+	}
+	db := initDB(startup)
+	demo.Configure(startup.DemoMode, startup.DemoCommands)
+	if startup.DemoMode {
+		if err := demo.SeedUser(db, startup.DemoUser, startup.DemoPassword); err != nil {
+			logging.Fatal("failed to seed the demo user", "error", err)
+		}
+		slog.Warn("demo mode is on, changes are rejected for every user", "demo_user", startup.DemoUser)
+	}
+	cfg := loadConfig(db, startup)
+	cache.Configure(db)
+	ssh.ConfigureCircuits(db)
+	ssh.ConfigureCommands(db)
+	ssh.ConfigureStreams(db)
+	ssh.ConfigureSessions(db)
+	ssh.ConfigureLatency(db)
+	ssh.ConfigureRegistries(db)
+	ssh.StartPool(ctx, db)
+	collectorDone := stats.StartCollector(ctx, db)
+	// A secret supplied through the environment is managed outside the panel and not backed up
+	secretPath := startup.DataPath(jwtSecretFileName)
+	if startup.JWTSecret.Set() {
+		secretPath = ""
+	}
+	backups := backup.New(db, startup.DatabaseDriver, secretPath, startup.DataPath("backups"))
+	backups.StartScheduler(ctx)
+	notify.Start(ctx, db)
+	notify.RegisterSender(notify.ChannelTelegram, sendTelegram)
+	maintenance.Start(ctx, db)
+	schedulerDone := schedule.Start(ctx, db, handler.InvalidateContainers)
+	autoupdate.Start(ctx, db, handler.InvalidateContainers)
+	events.OnEvent(handler.InvalidateContainers)
+	version.Start(ctx, db)
 
 	if cfg.BotToken != "" {
-		botHandler, err := bot.NewBotHandler(cfg.BotToken, cfg.WebAppURL)
-		if err != nil {
-			log.Fatalf("Failed to initialize Telegram Bot: %v", err)
+		if err := restartBot(cfg.BotToken, cfg.WebAppURL); err != nil {
+			logging.Fatal("failed to initialize Telegram Bot", "error", err)
 		}
-		go botHandler.Start()
-		log.Println("Telegram Bot started.")
 	} else {
-		log.Println("Telegram Bot Token not configured in DB. Skipping Telegram Bot initialization.")
+		slog.Info("Telegram Bot Token not configured in DB. Skipping Telegram Bot initialization.")
+	}
+
+	// Hot-reload the bot whenever its settings change. Saving both settings runs both hooks,
+	// and the second finds the bot already running with them.
+	reloadBot := func(string) {
+		token := getConfigValue(db, model.ConfigKeyTelegramBotToken)
+		webAppURL := getConfigValue(db, model.ConfigKeyTelegramWebAppURL)
+		if err := restartBot(token, webAppURL); err != nil {
+			slog.Error("failed to reload Telegram Bot", "error", err)
+		}
+	}
+	config.OnChange(model.ConfigKeyTelegramBotToken, reloadBot)
+	config.OnChange(model.ConfigKeyTelegramWebAppURL, reloadBot)
+
+	handler := setupRouter(ctx, db, cfg, startup, backups)
+	servers := []*http.Server{{Addr: cfg.ListenAddr, Handler: handler}}
+	serveErr := make(chan error, 2)
+
+	if startup.TLSEnabled() {
+		tlsConfig, err := setupTLS(startup)
+		if err != nil {
+			logging.Fatal("failed to configure TLS", "error", err)
+		}
+		servers[0].Handler = withHSTS(handler)
+		servers[0].TLSConfig = tlsConfig
+		slog.Info("TLS enabled")
+	}
+
+	// A Unix socket is removed again when Shutdown closes the listener
+	ln, err := listen.Listen(cfg.ListenAddr, startup.SocketMode, startup.SocketGroup)
+	if err != nil {
+		logging.Fatal("failed to listen", "addr", cfg.ListenAddr, "error", err)
 	}
 
-	handler := setupRouter(db, cfg)
-	log.Printf("Server listening on %s", cfg.ListenAddr)
+	if startup.TLSEnabled() {
+		go func() { serveErr <- servers[0].ServeTLS(ln, "", "") }()
+
+		if startup.HTTPRedirectAddr != "" {
+			redirect := httpsRedirectServer(startup.HTTPRedirectAddr, cfg.ListenAddr)
+			servers = append(servers, redirect)
+			slog.Info("HTTP redirect listener started", "addr", startup.HTTPRedirectAddr)
+			go func() {
+				if err := redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					slog.Error("HTTP redirect listener stopped", "error", err)
+				}
+			}()
+		}
+	} else {
+		go func() { serveErr <- servers[0].Serve(ln) }()
+	}
+	slog.Info("server listening", "addr", cfg.ListenAddr)
 
-	s := http.ListenAndServe(cfg.ListenAddr, handler)
-	if s != nil {
-		log.Fatalf("Server failed to start: %v", s)
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("server failed to start", "error", err)
+		}
+	case <-ctx.Done():
 	}
+	stop()
+	shutdown(servers, collectorDone, schedulerDone, startup.ShutdownTimeout)
 }
 
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+// shutdown drains in-flight requests, then stops background workers. Connections still open
+// after the timeout are closed forcibly.
+func shutdown(servers []*http.Server, collectorDone, schedulerDone <-chan struct{}, timeout time.Duration) {
+	slog.Info("shutting down", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Warn("drain timeout exceeded, closing remaining connections", "addr", srv.Addr, "error", err)
+			srv.Close()
+		}
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	if err := restartBot("", ""); err != nil {
+		slog.Error("failed to stop Telegram Bot", "error", err)
+	}
+	if err := websocket.Wait(ctx); err != nil {
+		slog.Warn("terminal sessions still open at shutdown", "error", err)
+	}
+	select {
+	case <-collectorDone:
+	case <-ctx.Done():
+		slog.Warn("stats collector did not finish before the shutdown timeout")
+	}
+	select {
+	case <-schedulerDone:
+	case <-ctx.Done():
+		slog.Warn("scheduler did not stop before the shutdown timeout")
+	}
+	slog.Info("shutdown complete")
+}
+
+// setupTLS loads (or generates) the certificate and returns a config that picks up renewed certificates
+func setupTLS(startup *config.Startup) (*tls.Config, error) {
+	certFile, keyFile := startup.TLSCert, startup.TLSKey
+	if certFile == config.TLSSelfSigned {
+		var err error
+		certFile, keyFile, err = certs.EnsureSelfSigned(startup.DataPath("tls"))
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		c.Next()
+	reloader, err := certs.NewReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
 	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}, nil
+}
+
+// httpsRedirectServer answers plain HTTP requests with a permanent redirect to the HTTPS listener
+func httpsRedirectServer(addr, tlsAddr string) *http.Server {
+	_, tlsPort, _ := net.SplitHostPort(tlsAddr)
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if tlsPort != "" && tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+	return &http.Server{Addr: addr, Handler: redirect}
+}
+
+// withHSTS tells browsers to only use HTTPS for this host
+func withHSTS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		next.ServeHTTP(w, r)
+	})
 }
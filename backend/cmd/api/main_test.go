@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"docker-pulse/internal/api/openapi"
+	"docker-pulse/internal/backup"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/migrate"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testEnv is a migrated database in a data dir of its own and the startup configuration pointing at it
+type testEnv struct {
+	db      *gorm.DB
+	startup *config.Startup
+	backups *backup.Service
+}
+
+func newTestEnv(t *testing.T, args ...string) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	startup, err := config.LoadStartup(append([]string{"--data-dir", dir, "--gin-mode", "test"}, args...))
+	if err != nil {
+		t.Fatalf("startup configuration: %v", err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "dockerpulse.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("database handle: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := migrate.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return &testEnv{
+		db:      db,
+		startup: startup,
+		backups: backup.New(db, startup.DatabaseDriver, "", startup.DataPath("backups")),
+	}
+}
+
+func (e *testEnv) config() Config {
+	return Config{JWTSecret: "test-secret", ListenAddr: e.startup.ListenAddr}
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	for _, base := range []string{"", "/dm"} {
+		env := newTestEnv(t, "--base-path", base)
+		router := apiRouter(context.Background(), env.db, env.config(), env.startup, env.backups)
+		if missing := openapi.Missing(router.Routes(), base); len(missing) > 0 {
+			t.Errorf("base %q: routes missing from the OpenAPI document:", base)
+			for _, route := range missing {
+				t.Errorf("  %s", route)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouterServesUnderBasePath(t *testing.T) {
+	for _, base := range []string{"", "/dm"} {
+		t.Run("base="+base, func(t *testing.T) {
+			env := newTestEnv(t, "--base-path", base)
+			router := setupRouter(context.Background(), env.db, env.config(), env.startup, env.backups)
+			get := func(path string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				return w
+			}
+			isIndex := func(w *httptest.ResponseRecorder) bool {
+				return strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") &&
+					strings.Contains(w.Body.String(), `<base href="`+base+`/">`)
+			}
+
+			// API and WebSocket routes reach Gin, including the ones it has no route for
+			if w := get(base + "/api/v1/status"); w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+				t.Errorf("status = %d %q, want 200 JSON", w.Code, w.Header().Get("Content-Type"))
+			}
+			if w := get(base + "/api/v1/servers"); w.Code != http.StatusUnauthorized {
+				t.Errorf("servers without a token = %d, want 401", w.Code)
+			}
+			if w := get(base + "/api/v1/no-such-route"); w.Code != http.StatusNotFound || isIndex(w) {
+				t.Errorf("unknown API route = %d, want a 404 from the API, not the SPA", w.Code)
+			}
+			if w := get(base + "/ws/events"); w.Code != http.StatusUnauthorized {
+				t.Errorf("events socket without a token = %d, want 401", w.Code)
+			}
+
+			// Static files are looked up with the base path stripped
+			if w := get(base + "/"); w.Code != http.StatusOK || !isIndex(w) {
+				t.Errorf("root = %d, want index.html with its base href", w.Code)
+			}
+			if w := get(base + "/assets/index-0hCC__8P.js"); w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") {
+				t.Errorf("asset = %d %q, want 200 JavaScript", w.Code, w.Header().Get("Content-Type"))
+			}
+
+			// SPA routes get index.html
+			if w := get(base + "/servers/1/containers"); w.Code != http.StatusOK || !isIndex(w) {
+				t.Errorf("SPA route = %d, want index.html", w.Code)
+			}
+
+			if base == "" {
+				return
+			}
+			// The bare base path redirects into the panel and nothing outside it is served
+			if w := get(base + "?lang=de"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != base+"/?lang=de" {
+				t.Errorf("bare base path = %d to %q, want a redirect to %s/?lang=de", w.Code, w.Header().Get("Location"), base)
+			}
+			for _, path := range []string{"/", "/api/v1/status", "/assets/index-0hCC__8P.js", "/dmx/"} {
+				if w := get(path); w.Code != http.StatusNotFound {
+					t.Errorf("%s outside the base path = %d, want 404", path, w.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/schedule"
+	"docker-pulse/internal/stats"
+)
+
+func TestShutdownDrainsRequestsAndStopsWorkers(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	collectorDone := stats.StartCollector(ctx, env.db)
+	schedulerDone := schedule.Start(ctx, env.db, nil)
+
+	// A request that is still being answered when shutdown starts
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/", setupRouter(ctx, env.db, env.config(), env.startup, env.backups))
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &http.Server{Handler: mux}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ln) }()
+	url := "http://" + ln.Addr().String()
+
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		slow <- result{string(body), err}
+	}()
+	<-started
+
+	// As on SIGTERM: the workers' context is cancelled, then the server drains
+	stop()
+	finished := make(chan struct{})
+	go func() {
+		shutdown([]*http.Server{srv}, collectorDone, schedulerDone, 5*time.Second)
+		close(finished)
+	}()
+
+	select {
+	case r := <-slow:
+		if r.err != nil || r.body != "done" {
+			t.Fatalf("in-flight request = %q, %v; want it to complete", r.body, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight request did not complete")
+	}
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not return")
+	}
+	if err := <-serveErr; err != http.ErrServerClosed {
+		t.Fatalf("serve = %v, want %v", err, http.ErrServerClosed)
+	}
+
+	for name, done := range map[string]<-chan struct{}{"stats collector": collectorDone, "scheduler": schedulerDone} {
+		select {
+		case <-done:
+		default:
+			t.Errorf("%s is still running after shutdown", name)
+		}
+	}
+	if _, err := http.Get(url + "/api/v1/status"); err == nil {
+		t.Fatal("server still accepts requests after shutdown")
+	}
+}
@@ -0,0 +1,301 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrDisconnected is returned for requests to an agent whose connection dropped
+var ErrDisconnected = errors.New("agent disconnected")
+
+// ExitError is returned for commands the agent ran that exited with a non-zero status
+type ExitError struct {
+	Status int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("Process exited with status %d", e.Status)
+}
+
+// ExitStatus returns the command's exit status, like ssh.ExitError
+func (e *ExitError) ExitStatus() int {
+	return e.Status
+}
+
+// Conn is the backend's side of an agent's connection. Requests run concurrently, each answered
+// on its own stream of frames.
+type Conn struct {
+	ServerID    uint
+	Version     string
+	Hostname    string
+	ConnectedAt time.Time
+
+	ws       *websocket.Conn
+	writeMu  sync.Mutex
+	lastSeen atomic.Int64
+	closed   chan struct{}
+	close    sync.Once
+
+	mu      sync.Mutex
+	nextID  uint64
+	streams map[uint64]chan Message // nil once the connection dropped
+}
+
+func newConn(ws *websocket.Conn, serverID uint, version, hostname string) *Conn {
+	c := &Conn{
+		ServerID:    serverID,
+		Version:     version,
+		Hostname:    hostname,
+		ConnectedAt: time.Now(),
+		ws:          ws,
+		closed:      make(chan struct{}),
+		streams:     map[uint64]chan Message{},
+	}
+	c.lastSeen.Store(c.ConnectedAt.UnixNano())
+	return c
+}
+
+// LastSeen returns when the agent was last heard from
+func (c *Conn) LastSeen() time.Time {
+	return time.Unix(0, c.lastSeen.Load())
+}
+
+// Close drops the connection, failing the requests in flight
+func (c *Conn) Close() {
+	c.close.Do(func() {
+		close(c.closed)
+		c.ws.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "disconnected"), time.Now().Add(writeWait))
+		c.ws.Close()
+	})
+}
+
+// Docker returns a Docker Engine API client reaching the host's Docker through the agent
+func (c *Conn) Docker() *dockerapi.Client {
+	return dockerapi.New(c, "http://docker", c.ServerID)
+}
+
+// Exec runs command with sh -c on the host, returning its stdout and stderr. Commands that exit
+// with a non-zero status return an *ExitError.
+func (c *Conn) Exec(ctx context.Context, command string, stdin []byte) (stdout, stderr string, err error) {
+	s, err := c.open(ctx, Message{Op: OpExec, Command: command, Stdin: stdin})
+	if err != nil {
+		return "", "", err
+	}
+	defer s.Close()
+	output, err := io.ReadAll(s)
+	if err == nil && s.last.ExitCode != 0 {
+		err = &ExitError{Status: s.last.ExitCode}
+	}
+	return string(output), string(s.last.Stderr), err
+}
+
+// RoundTrip sends an HTTP request to the host's Docker socket through the agent, which makes
+// Conn the transport of the client Docker returns
+func (c *Conn) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	s, err := c.open(req.Context(), Message{Op: OpHTTP, Method: req.Method, Path: req.URL.RequestURI(), Header: req.Header, Body: body})
+	if err != nil {
+		return nil, err
+	}
+	first, err := s.next()
+	if err == nil && first.Error != "" {
+		err = errors.New(first.Error)
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.buf, s.done = first.Data, first.Done
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", first.Status, http.StatusText(first.Status)),
+		StatusCode:    first.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          s,
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// open sends a request and returns the stream its response frames arrive on
+func (c *Conn) open(ctx context.Context, msg Message) (*stream, error) {
+	c.mu.Lock()
+	if c.streams == nil {
+		c.mu.Unlock()
+		return nil, ErrDisconnected
+	}
+	c.nextID++
+	msg.ID = c.nextID
+	// The agent waits for acks after window frames, so the last frame always fits
+	frames := make(chan Message, window+1)
+	c.streams[msg.ID] = frames
+	c.mu.Unlock()
+
+	if err := c.send(msg); err != nil {
+		c.forget(msg.ID)
+		return nil, err
+	}
+	return &stream{conn: c, ctx: ctx, id: msg.ID, frames: frames}, nil
+}
+
+func (c *Conn) forget(id uint64) {
+	c.mu.Lock()
+	delete(c.streams, id)
+	c.mu.Unlock()
+}
+
+func (c *Conn) send(msg Message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.ws.WriteJSON(msg); err != nil {
+		return fmt.Errorf("%w: %v", ErrDisconnected, err)
+	}
+	return nil
+}
+
+// readLoop delivers the agent's frames to their streams until the connection drops
+func (c *Conn) readLoop() error {
+	defer c.failStreams()
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		c.seen()
+		return nil
+	})
+	for {
+		var msg Message
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			return err
+		}
+		c.seen()
+		c.mu.Lock()
+		frames, ok := c.streams[msg.ID]
+		if ok && msg.Done {
+			delete(c.streams, msg.ID)
+		}
+		c.mu.Unlock()
+		if !ok {
+			// Frames of cancelled requests
+			continue
+		}
+		select {
+		case frames <- msg:
+		default:
+			return fmt.Errorf("agent sent more than %d unacknowledged frames", window)
+		}
+	}
+}
+
+func (c *Conn) seen() {
+	now := time.Now()
+	c.lastSeen.Store(now.UnixNano())
+	c.ws.SetReadDeadline(now.Add(pongWait))
+}
+
+func (c *Conn) failStreams() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, frames := range c.streams {
+		close(frames)
+	}
+	c.streams = nil
+}
+
+// ping keeps the connection alive and lets readLoop notice an agent that stopped answering
+func (c *Conn) ping() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// stream reads the response frames of a request. It is the body of HTTP responses and the
+// stdout of commands.
+type stream struct {
+	conn   *Conn
+	ctx    context.Context
+	id     uint64
+	frames chan Message
+	buf    []byte
+	done   bool
+	// last is the request's last frame once it arrived
+	last Message
+}
+
+// next returns the next frame, acknowledging it so the agent sends more
+func (s *stream) next() (Message, error) {
+	select {
+	case msg, ok := <-s.frames:
+		if !ok {
+			s.done, s.last.Error = true, ErrDisconnected.Error()
+			return Message{}, ErrDisconnected
+		}
+		if msg.Done {
+			s.done, s.last = true, msg
+		} else {
+			s.conn.send(Message{ID: s.id, Op: OpAck})
+		}
+		return msg, nil
+	case <-s.ctx.Done():
+		return Message{}, s.ctx.Err()
+	}
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.done {
+			if s.last.Error != "" {
+				return 0, errors.New(s.last.Error)
+			}
+			return 0, io.EOF
+		}
+		msg, err := s.next()
+		if err != nil {
+			return 0, err
+		}
+		s.buf = msg.Data
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// Close cancels the request on the agent when its response was not read to the end
+func (s *stream) Close() error {
+	if !s.done {
+		s.done = true
+		s.conn.forget(s.id)
+		s.conn.send(Message{ID: s.id, Op: OpCancel})
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Package agent connects Docker hosts running the DockerManager agent to the backend. The agent
+// dials out and keeps a WebSocket open, over which the backend sends Docker Engine API requests
+// and commands instead of reaching the host over SSH.
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// Message is a frame of the agent protocol, sent as JSON in a WebSocket text message. The backend
+// sends requests and the agent answers each with frames of the same ID, the last one Done.
+type Message struct {
+	ID uint64 `json:"id"`
+	// Op is the request: OpHTTP, OpExec, OpCancel or OpAck. It is empty in responses.
+	Op string `json:"op,omitempty"`
+
+	// Method, Path, Header and Body of an OpHTTP request to the Docker Engine API
+	Method string      `json:"method,omitempty"`
+	Path   string      `json:"path,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	// Command is run with sh -c for OpExec, reading Stdin
+	Command string `json:"command,omitempty"`
+	Stdin   []byte `json:"stdin,omitempty"`
+
+	// Status of an HTTP response, sent in its first frame
+	Status int `json:"status,omitempty"`
+	// Data is the next chunk of an HTTP response body or of a command's stdout
+	Data []byte `json:"data,omitempty"`
+	// Stderr and ExitCode are sent in the last frame of a command
+	Stderr   []byte `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	Done     bool   `json:"done,omitempty"`
+	// Error reports a request the agent could not run
+	Error string `json:"error,omitempty"`
+}
+
+// Requests sent by the backend
+const (
+	OpHTTP = "http"
+	OpExec = "exec"
+	// OpCancel stops the request with the message's ID
+	OpCancel = "cancel"
+	// OpAck tells the agent a data frame of the request was consumed
+	OpAck = "ack"
+)
+
+const (
+	// window is how many data frames of a request the agent sends before waiting for an ack, so
+	// a slow reader of one stream does not hold up the others
+	window = 16
+	// chunkSize is the largest Data of a frame
+	chunkSize = 32 << 10
+	// stderrLimit is how much of a command's stderr is kept
+	stderrLimit = 64 << 10
+
+	// pingInterval is how often the backend pings an agent, which is dropped when nothing is read
+	// from it for pongWait. The agent reconnects when it is not pinged for as long.
+	pingInterval = 20 * time.Second
+	pongWait     = 60 * time.Second
+	writeWait    = 10 * time.Second
+)
+
+// Headers the agent identifies itself with when connecting
+const (
+	HeaderVersion  = "X-Agent-Version"
+	HeaderHostname = "X-Agent-Hostname"
+)
+
+// NewToken generates an enrollment token, returning it and the hash to store for its server
+func NewToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = "dma_" + hex.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hash of an enrollment token that is stored for its server
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
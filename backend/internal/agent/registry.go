@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	mu    sync.Mutex
+	conns = map[uint]*Conn{}
+	// lastSeen keeps when the agents that disconnected were last heard from
+	lastSeen = map[uint]time.Time{}
+)
+
+// Status is the state of a server's agent
+type Status struct {
+	Connected   bool       `json:"connected"`
+	Version     string     `json:"version,omitempty"`
+	Hostname    string     `json:"hostname,omitempty"`
+	ConnectedAt *time.Time `json:"connected_at,omitempty"`
+	// LastSeen is when the agent was last heard from, missing when it never connected since the
+	// backend started
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// Serve registers ws as the connection of the server's agent, replacing any previous one, and
+// reads from it until the connection drops, the agent stops answering pings or ctx is done
+func Serve(ctx context.Context, ws *websocket.Conn, serverID uint, version, hostname string) {
+	c := newConn(ws, serverID, version, hostname)
+	mu.Lock()
+	previous := conns[serverID]
+	conns[serverID] = c
+	mu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+
+	logger := slog.With("server_id", serverID, "agent_version", version, "hostname", hostname)
+	logger.Info("agent connected")
+	stop := context.AfterFunc(ctx, c.Close)
+	defer stop()
+	go c.ping()
+
+	err := c.readLoop()
+	c.Close()
+	mu.Lock()
+	if conns[serverID] == c {
+		delete(conns, serverID)
+	}
+	lastSeen[serverID] = c.LastSeen()
+	mu.Unlock()
+	logger.Info("agent disconnected", "error", err)
+}
+
+// Lookup returns the connection of the server's agent, nil when it is not connected
+func Lookup(serverID uint) *Conn {
+	mu.Lock()
+	defer mu.Unlock()
+	return conns[serverID]
+}
+
+// Disconnect drops the connection of the server's agent, after its enrollment token changed
+func Disconnect(serverID uint) {
+	if c := Lookup(serverID); c != nil {
+		c.Close()
+	}
+}
+
+// StatusOf returns the state of the server's agent
+func StatusOf(serverID uint) Status {
+	mu.Lock()
+	c, seen := conns[serverID], lastSeen[serverID]
+	mu.Unlock()
+	if c == nil {
+		if seen.IsZero() {
+			return Status{}
+		}
+		return Status{LastSeen: &seen}
+	}
+	connectedAt, seen := c.ConnectedAt, c.LastSeen()
+	return Status{Connected: true, Version: c.Version, Hostname: c.Hostname, ConnectedAt: &connectedAt, LastSeen: &seen}
+}
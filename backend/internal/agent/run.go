@@ -0,0 +1,297 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxBackoff bounds the wait between attempts to reconnect to the backend
+	maxBackoff = time.Minute
+	// waitDelay is how long a command's output is read after it exited or was cancelled
+	waitDelay = 2 * time.Second
+)
+
+// Config configures the agent running on a Docker host
+type Config struct {
+	// URL is the backend's agent endpoint, e.g. "wss://panel.example.com/ws/agent"
+	URL string
+	// Token is the server's enrollment token
+	Token string
+	// DockerSocket is the path of the Docker daemon's socket
+	DockerSocket string
+	Version      string
+	// InsecureSkipVerify accepts any TLS certificate of the backend
+	InsecureSkipVerify bool
+}
+
+// Run connects to the backend and answers its requests, reconnecting with backoff whenever the
+// connection drops, until ctx is done
+func Run(ctx context.Context, cfg Config) error {
+	hostname, _ := os.Hostname()
+	header := http.Header{
+		"Authorization": {"Bearer " + cfg.Token},
+		HeaderVersion:   {cfg.Version},
+		HeaderHostname:  {hostname},
+	}
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: writeWait,
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify},
+	}
+	docker := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", cfg.DockerSocket)
+		},
+	}}
+
+	backoff := time.Second
+	for {
+		ws, resp, err := dialer.DialContext(ctx, cfg.URL, header)
+		switch {
+		case err == nil:
+			slog.Info("connected to the backend", "url", cfg.URL)
+			backoff = time.Second
+			err = serve(ctx, ws, docker)
+			slog.Warn("disconnected from the backend", "error", err)
+		case resp != nil && resp.StatusCode == http.StatusUnauthorized:
+			slog.Error("the backend rejected the enrollment token", "url", cfg.URL)
+		default:
+			slog.Warn("failed to connect to the backend", "url", cfg.URL, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// session serves the backend's requests on one connection
+type session struct {
+	ws     *websocket.Conn
+	docker *http.Client
+
+	writeMu  sync.Mutex
+	mu       sync.Mutex
+	requests map[uint64]*request
+	wg       sync.WaitGroup
+}
+
+type request struct {
+	cancel context.CancelFunc
+	// window holds a token for every frame the backend has yet to acknowledge
+	window chan struct{}
+}
+
+func serve(ctx context.Context, ws *websocket.Conn, docker *http.Client) error {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &session{ws: ws, docker: docker, requests: map[uint64]*request{}}
+	defer func() {
+		cancel()
+		ws.Close()
+		s.wg.Wait()
+	}()
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+
+	// The backend pings regularly, a connection it stopped pinging is dead
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPingHandler(func(data string) error {
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		err := ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(writeWait))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+
+	for {
+		var msg Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			return err
+		}
+		switch msg.Op {
+		case OpHTTP, OpExec:
+			s.start(ctx, msg)
+		case OpCancel:
+			if r := s.lookup(msg.ID); r != nil {
+				r.cancel()
+			}
+		case OpAck:
+			if r := s.lookup(msg.ID); r != nil {
+				select {
+				case <-r.window:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (s *session) lookup(id uint64) *request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[id]
+}
+
+// start runs a request in the background, ending its response with a last frame
+func (s *session) start(ctx context.Context, msg Message) {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &request{cancel: cancel, window: make(chan struct{}, window)}
+	s.mu.Lock()
+	s.requests[msg.ID] = r
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			cancel()
+			s.mu.Lock()
+			delete(s.requests, msg.ID)
+			s.mu.Unlock()
+		}()
+		var last Message
+		if msg.Op == OpHTTP {
+			last = s.proxy(ctx, r, msg)
+		} else {
+			last = s.exec(ctx, r, msg)
+		}
+		last.ID, last.Done = msg.ID, true
+		s.write(last)
+	}()
+}
+
+// proxy sends an HTTP request to the Docker socket and streams back the response
+func (s *session) proxy(ctx context.Context, r *request, msg Message) Message {
+	req, err := http.NewRequestWithContext(ctx, msg.Method, "http://docker"+msg.Path, bytes.NewReader(msg.Body))
+	if err != nil {
+		return Message{Error: err.Error()}
+	}
+	for name, values := range msg.Header {
+		req.Header[name] = values
+	}
+	if len(msg.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.docker.Do(req)
+	if err != nil {
+		return Message{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	if err := s.send(ctx, r, Message{ID: msg.ID, Status: resp.StatusCode}); err != nil {
+		return Message{Error: err.Error()}
+	}
+	if err := s.stream(ctx, r, msg.ID, resp.Body); err != nil {
+		return Message{Error: err.Error()}
+	}
+	return Message{}
+}
+
+// exec runs a command with sh -c, streaming back its stdout
+func (s *session) exec(ctx context.Context, r *request, msg Message) Message {
+	cmd := exec.CommandContext(ctx, "sh", "-c", msg.Command)
+	cmd.Stdin = bytes.NewReader(msg.Stdin)
+	var stderr limitedBuffer
+	cmd.Stderr = &stderr
+	// Children left holding stdout, like the sleep of a cancelled "sh -c 'sleep 60'", do not keep
+	// the request open for longer than waitDelay
+	stdout, output := io.Pipe()
+	cmd.Stdout = output
+	cmd.WaitDelay = waitDelay
+	if err := cmd.Start(); err != nil {
+		return Message{Error: err.Error()}
+	}
+	waited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		output.Close()
+		waited <- err
+	}()
+	streamErr := s.stream(ctx, r, msg.ID, stdout)
+	if streamErr != nil {
+		// Nobody reads the output anymore, so the command must not block writing it
+		r.cancel()
+		stdout.Close()
+	}
+	err := <-waited
+
+	last := Message{Stderr: stderr.Bytes()}
+	var exitErr *exec.ExitError
+	switch {
+	case streamErr != nil:
+		last.Error = streamErr.Error()
+	case errors.As(err, &exitErr):
+		last.ExitCode = exitErr.ExitCode()
+	case errors.Is(err, exec.ErrWaitDelay):
+		// The command succeeded, only a child it started in the background kept stdout open
+	case err != nil:
+		last.Error = err.Error()
+	}
+	return last
+}
+
+// stream sends what reader yields as data frames of the request
+func (s *session) stream(ctx context.Context, r *request, id uint64, reader io.Reader) error {
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if err := s.send(ctx, r, Message{ID: id, Data: bytes.Clone(buf[:n])}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// send writes a frame before the last one of a request, waiting while the backend has window
+// frames of it to acknowledge
+func (s *session) send(ctx context.Context, r *request, msg Message) error {
+	select {
+	case r.window <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.write(msg)
+}
+
+func (s *session) write(msg Message) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return s.ws.WriteJSON(msg)
+}
+
+// limitedBuffer keeps the first stderrLimit bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := stderrLimit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
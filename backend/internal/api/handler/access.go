@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// accessRank orders access levels so a higher level includes the lower ones
+var accessRank = map[string]int{
+	model.AccessLevelRead:   1,
+	model.AccessLevelManage: 2,
+	model.AccessLevelFull:   3,
+}
+
+// currentUser returns the authenticated user's ID and role. When the auth middleware did not set
+// them it responds with unauthorized instead of letting a type assertion panic.
+func currentUser(c *gin.Context) (uint, string, bool) {
+	id := c.GetUint("userID")
+	role := c.GetString("role")
+	if id == 0 || role == "" {
+		apierror.Abort(c, apierror.Unauthorized)
+		return 0, "", false
+	}
+	return id, role, true
+}
+
+// parseID parses a numeric route parameter, responding with invalid_id on failure
+func parseID(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.InvalidID)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// containerParam returns the ":containerID" route parameter, responding with invalid_id unless
+// it is a container name or ID that is safe to pass to shell commands
+func containerParam(c *gin.Context) (string, bool) {
+	containerID := c.Param("containerID")
+	if !ssh.ValidContainerID(containerID) {
+		apierror.Abort(c, apierror.InvalidID)
+		return "", false
+	}
+	return containerID, true
+}
+
+// checkAccess checks that the current user holds at least the given access level on the server
+// and returns the granted level, "admin" for admins, who pass every check. On failure the error
+// response has been written.
+func checkAccess(c *gin.Context, db *gorm.DB, serverID uint, level string) (string, bool) {
+	userID, role, ok := currentUser(c)
+	if !ok {
+		return "", false
+	}
+	if role == "admin" {
+		return role, true
+	}
+	var permission model.ServerPermission
+	if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.PermissionDenied)
+			return "", false
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return "", false
+	}
+	if accessRank[permission.AccessLevel] < accessRank[level] {
+		apierror.AbortMessage(c, apierror.PermissionDenied, apierror.T(c, "server_access_required", level))
+		return "", false
+	}
+	return permission.AccessLevel, true
+}
+
+// loadServer fetches a server, responding with server_not_found when it does not exist
+func loadServer(c *gin.Context, db *gorm.DB, serverID uint) (*model.Server, bool) {
+	var server model.Server
+	if err := db.First(&server, serverID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.ServerNotFound)
+			return nil, false
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return nil, false
+	}
+	return &server, true
+}
+
+// authorizeServer checks access to the server with checkAccess and loads it
+func authorizeServer(c *gin.Context, db *gorm.DB, serverID uint, level string) (*model.Server, bool) {
+	if _, ok := checkAccess(c, db, serverID, level); !ok {
+		return nil, false
+	}
+	return loadServer(c, db, serverID)
+}
+
+// authorizeServerParam is authorizeServer for the server ID in the ":id" route parameter
+func authorizeServerParam(c *gin.Context, db *gorm.DB, level string) (*model.Server, bool) {
+	serverID, ok := parseID(c, "id")
+	if !ok {
+		return nil, false
+	}
+	return authorizeServer(c, db, serverID, level)
+}
+
+// connectServer creates an SSH client for the server, responding with ssh_unreachable on failure
+func connectServer(c *gin.Context, server *model.Server) (*ssh.SSHClient, bool) {
+	client, err := ssh.NewServerClient(server)
+	if err != nil {
+		sshFailed(c, server.ID, "connect", err)
+		return nil, false
+	}
+	return client, true
+}
+
+// dockerBackend returns the backend container operations on the server go through, responding
+// like connectServer on failure
+func dockerBackend(c *gin.Context, server *model.Server) (dockerapi.Backend, bool) {
+	backend, err := ssh.NewServerBackend(server)
+	if err != nil {
+		sshFailed(c, server.ID, "connect", err)
+		return nil, false
+	}
+	return backend, true
+}
+
+// sshFailed logs a failed SSH operation and responds with a stable code. Only the server ID and
+// the operation are logged, never connection details.
+func sshFailed(c *gin.Context, serverID uint, op string, err error) {
+	logging.L(c).Warn("ssh operation failed", "server_id", serverID, "op", op, "error", err)
+	code := apierror.SSHCommandFailed
+	var netErr *net.OpError
+	switch {
+	case errors.Is(err, ssh.ErrNoSSH):
+		code = apierror.SSHUnavailable
+	case errors.Is(err, ssh.ErrCircuitOpen):
+		code = apierror.CircuitOpen
+	case errors.Is(err, ssh.ErrServerBusy):
+		code = apierror.ServerBusy
+	case errors.Is(err, ssh.ErrJumpHost):
+		code = apierror.JumpHostUnreachable
+	case errors.Is(err, context.DeadlineExceeded):
+		code = apierror.Timeout
+	case op == "connect" || errors.As(err, &netErr):
+		code = apierror.SSHUnreachable
+	}
+	apierror.Abort(c, code)
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+
+	"docker-pulse/internal/agent"
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AgentStatus is the state of a server's agent
+type AgentStatus struct {
+	// Enrolled reports whether the server has an enrollment token
+	Enrolled bool `json:"enrolled"`
+	agent.Status
+}
+
+// AgentToken is a newly created enrollment token, shown only once
+type AgentToken struct {
+	Token string `json:"token"`
+}
+
+// GetAgentStatus reports whether the server's agent is enrolled and connected
+func GetAgentStatus(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, AgentStatus{Enrolled: server.AgentTokenHash != "", Status: agent.StatusOf(server.ID)})
+	}
+}
+
+// CreateAgentToken creates the server's enrollment token, replacing and disconnecting any
+// previous one. Only its hash is stored.
+func CreateAgentToken(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		token, hash, err := agent.NewToken()
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+		if err := db.Model(server).Update("agent_token_hash", hash).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		agent.Disconnect(server.ID)
+		auditEvent(c, db, server.ID, "created an agent enrollment token for server %s", server.Name)
+		c.JSON(http.StatusCreated, AgentToken{Token: token})
+	}
+}
+
+// DeleteAgentToken revokes the server's enrollment token and disconnects its agent
+func DeleteAgentToken(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		if err := db.Model(server).Update("agent_token_hash", "").Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		agent.Disconnect(server.ID)
+		auditEvent(c, db, server.ID, "revoked the agent enrollment token of server %s", server.Name)
+		c.JSON(http.StatusOK, gin.H{"message": "agent token revoked"})
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/pull"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListImageChecks returns the results of the latest scheduled update check of each container of
+// a server, with whether the container is opted into auto-updates
+func ListImageChecks(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		checks := []model.ImageCheck{}
+		if err := db.Where("server_id = ?", server.ID).Order("container").Find(&checks).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		var enabled []string
+		if err := db.Model(&model.ContainerAutoUpdate{}).Where("server_id = ?", server.ID).Pluck("container", &enabled).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		optedIn := map[string]bool{}
+		for _, name := range enabled {
+			optedIn[name] = true
+		}
+		for i := range checks {
+			checks[i].AutoUpdate = optedIn[checks[i].Container]
+		}
+		c.JSON(http.StatusOK, model.ImageChecksResponse{Checks: checks})
+	}
+}
+
+// SetContainerAutoUpdate opts a container into or out of auto-updates. Opting in requires the
+// access level of updating the container by hand. Containers are kept by name, so that the
+// setting carries over to the container that replaces an updated one.
+func SetContainerAutoUpdate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		var req model.AutoUpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		server, ok := authorizeServerParam(c, db, actionLevels[pull.ActionUpdate])
+		if !ok {
+			return
+		}
+		backend, ok := dockerBackend(c, server)
+		if !ok {
+			return
+		}
+		ref, err := dockerapi.ContainerImage(c.Request.Context(), backend, containerID)
+		if err != nil {
+			if dockerapi.IsNoSuchContainer(err) {
+				apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "container_not_found", containerID))
+				return
+			}
+			sshFailed(c, server.ID, "inspect", err)
+			return
+		}
+
+		q := db.Where("server_id = ? AND container = ?", server.ID, ref.Container)
+		if *req.Enabled {
+			err = q.FirstOrCreate(&model.ContainerAutoUpdate{ServerID: server.ID, Container: ref.Container, CreatedBy: c.GetUint("userID")}).Error
+		} else {
+			err = q.Delete(&model.ContainerAutoUpdate{}).Error
+		}
+		if err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, server.ID, "set auto-update of container %s on %s to %t", ref.Container, server.Name, *req.Enabled)
+		c.JSON(http.StatusOK, model.AutoUpdateResponse{Container: ref.Container, AutoUpdate: *req.Enabled})
+	}
+}
+
+// ListAutoUpdateRuns returns a server's automatic updates, newest first. ?limit= caps the
+// number of runs.
+func ListAutoUpdateRuns(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		q := db.Where("server_id = ?", server.ID).Order("id DESC")
+		if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+			q = q.Limit(limit)
+		}
+		runs := []model.AutoUpdateRun{}
+		if err := q.Find(&runs).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, model.AutoUpdateRunsResponse{Runs: runs})
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/backup"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxRestoreSize limits the size of an uploaded backup archive
+const maxRestoreSize = 2 << 30
+
+// CreateBackup streams a backup archive to the client, or stores it in the backups directory with ?store=true
+func CreateBackup(svc *backup.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("store") == "true" {
+			name, err := svc.Save()
+			if err != nil {
+				apierror.AbortCause(c, apierror.Internal, err)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"file": name})
+			return
+		}
+
+		filename := "dockermanager-backup-" + time.Now().UTC().Format("20060102-150405") + ".tar.gz"
+		c.Header("Content-Type", "application/gzip")
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		if err := svc.Write(c.Writer); err != nil {
+			logging.L(c).Error("backup failed", "error", err)
+			if !c.Writer.Written() {
+				c.Writer.Header().Del("Content-Type")
+				c.Writer.Header().Del("Content-Disposition")
+				apierror.Abort(c, apierror.Internal)
+			}
+		}
+	}
+}
+
+// ListBackups returns the backups stored on the server, newest first
+func ListBackups(svc *backup.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		names, err := svc.Stored()
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+		if names == nil {
+			names = []string{}
+		}
+		c.JSON(http.StatusOK, gin.H{"backups": names})
+	}
+}
+
+// RestoreBackup replaces the database with an archive uploaded in the "file" form field.
+// All users are logged out; a restored JWT secret becomes active after a restart.
+func RestoreBackup(db *gorm.DB, svc *backup.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRestoreSize)
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			apierror.AbortField(c, apierror.InvalidRequest, "file", apierror.T(c, "backup_file_required"))
+			return
+		}
+		f, err := fileHeader.Open()
+		if err != nil {
+			apierror.AbortCause(c, apierror.InvalidRequest, err)
+			return
+		}
+		defer f.Close()
+
+		manifest, err := svc.Restore(f)
+		if err != nil {
+			if errors.Is(err, backup.ErrInvalidArchive) {
+				apierror.AbortMessage(c, apierror.InvalidRequest, err.Error())
+				return
+			}
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+
+		for _, ch := range cache.All() {
+			ch.Flush()
+		}
+		if err := config.Reload(db); err != nil {
+			logging.L(c).Warn("failed to reload configuration after restore", "error", err)
+		}
+		logging.L(c).Warn("database restored from backup", "backup_created_at", manifest.CreatedAt)
+		auditEvent(c, db, 0, "restored a backup created at %s", manifest.CreatedAt.Format(time.RFC3339))
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Backup restored. All users have been logged out.",
+			"created_at": manifest.CreatedAt,
+		})
+	}
+}
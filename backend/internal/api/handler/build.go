@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/build"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/pull"
+	"docker-pulse/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxBuildContext limits the size of an uploaded build context
+const maxBuildContext = 100 << 20
+
+var (
+	buildTag = regexp.MustCompile(`^[a-z0-9][a-z0-9._/-]*(:[A-Za-z0-9_][A-Za-z0-9_.-]{0,127})?$`)
+	// varName matches build argument and environment variable names
+	varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// pullRef matches image references to pull: an optional registry host and port, the
+	// repository's path components, and an optional tag and digest
+	pullRef = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9.-]*(:[0-9]+)?/)?[a-z0-9]+([._-]+[a-z0-9]+)*(/[a-z0-9]+([._-]+[a-z0-9]+)*)*` +
+		`(:[A-Za-z0-9_][A-Za-z0-9_.-]{0,127})?(@sha256:[a-f0-9]{64})?$`)
+	imageTag = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+)
+
+// ListImages lists a server's images, marking those built on the host
+func ListImages(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		images, err := sshClient.ListImages(c.Request.Context())
+		if err != nil {
+			sshFailed(c, server.ID, "list_images", err)
+			return
+		}
+		c.JSON(http.StatusOK, images)
+	}
+}
+
+// PullImage starts pulling an image by name, with the registry login stored for its registry
+// when there is one. The pull runs in the background as a task clients follow with GET /tasks/:id
+// or the /ws/tasks/:id WebSocket; a failed task's error_code tells unknown images, denied logins,
+// rate limits and full disks apart.
+func PullImage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.ImagePullRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		image := strings.TrimSpace(req.Image)
+		if _, err := registry.ParseReference(image); err != nil || !pullRef.MatchString(image) {
+			apierror.AbortField(c, apierror.ValidationFailed, "image", apierror.T(c, "validation_invalid", "image"))
+			return
+		}
+		if req.Tag != "" {
+			if !imageTag.MatchString(req.Tag) {
+				apierror.AbortField(c, apierror.ValidationFailed, "tag", apierror.T(c, "validation_invalid", "tag"))
+				return
+			}
+			if strings.Contains(image, "@") || strings.LastIndex(image, ":") > strings.LastIndex(image, "/") {
+				apierror.AbortField(c, apierror.ValidationFailed, "tag", apierror.T(c, "image_pull_tag_conflict", image))
+				return
+			}
+			image += ":" + req.Tag
+		}
+		server, ok := authorizeServerParam(c, db, actionLevels[pull.ActionPull])
+		if !ok {
+			return
+		}
+		job, err := pull.StartImage(*server, image)
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+		auditEvent(c, db, server.ID, "started pulling image %s on %s", image, server.Name)
+		c.JSON(http.StatusAccepted, job.Snapshot(0))
+	}
+}
+
+// BuildImage starts building an image on the server from a multipart form: "tag", repeated
+// "build_arg" fields of the form NAME=value, and either a "dockerfile" text field or a
+// gzipped tar build context in the "context" file field. The build runs in the background;
+// GetBuild follows its output.
+func BuildImage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBuildContext+1<<20)
+
+		tag := c.PostForm("tag")
+		if !buildTag.MatchString(tag) {
+			apierror.AbortField(c, apierror.ValidationFailed, "tag", apierror.T(c, "validation_invalid", "tag"))
+			return
+		}
+		buildArgs := map[string]string{}
+		for _, arg := range c.PostFormArray("build_arg") {
+			name, value, _ := strings.Cut(arg, "=")
+			if !varName.MatchString(name) {
+				apierror.AbortField(c, apierror.ValidationFailed, "build_arg", apierror.T(c, "build_arg_invalid", arg))
+				return
+			}
+			buildArgs[name] = value
+		}
+
+		dockerfile := c.PostForm("dockerfile")
+		upload, uploadErr := c.FormFile("context")
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(uploadErr, &maxErr):
+			apierror.AbortField(c, apierror.ValidationFailed, "context", apierror.T(c, "build_context_too_large", maxBuildContext>>20))
+			return
+		case (dockerfile == "") == (uploadErr != nil):
+			apierror.AbortMessage(c, apierror.ValidationFailed, apierror.T(c, "build_source_required"))
+			return
+		case upload != nil && upload.Size > maxBuildContext:
+			apierror.AbortField(c, apierror.ValidationFailed, "context", apierror.T(c, "build_context_too_large", maxBuildContext>>20))
+			return
+		}
+
+		contextFile, err := os.CreateTemp("", "dockermanager-build-*.tar.gz")
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+		if upload != nil {
+			err = copyUpload(contextFile, upload)
+		} else {
+			err = writeDockerfileContext(contextFile, dockerfile)
+		}
+		if cerr := contextFile.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(contextFile.Name())
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+
+		job, err := build.Start(*server, contextFile.Name(), tag, buildArgs)
+		if err != nil {
+			os.Remove(contextFile.Name())
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+		auditEvent(c, db, server.ID, "started building image %s on %s", tag, server.Name)
+		c.JSON(http.StatusAccepted, job.Snapshot(0))
+	}
+}
+
+// GetBuild returns a build's state and its output from ?offset= on. Clients follow a build by
+// passing the previous response's next_offset until the status is no longer running.
+func GetBuild(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		job, found := build.Get(server.ID, c.Param("jobID"))
+		if !found {
+			apierror.Abort(c, apierror.NotFound)
+			return
+		}
+		offset, _ := strconv.ParseInt(c.Query("offset"), 10, 64)
+		c.JSON(http.StatusOK, job.Snapshot(offset))
+	}
+}
+
+func copyUpload(dst io.Writer, upload *multipart.FileHeader) error {
+	src, err := upload.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// writeDockerfileContext writes a gzipped tar build context holding only the Dockerfile
+func writeDockerfileContext(w io.Writer, dockerfile string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0o644, Size: int64(len(dockerfile)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(tw, dockerfile); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
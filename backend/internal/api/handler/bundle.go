@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/bundle"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// PassphraseHeader carries the passphrase that encrypts or decrypts a bundle's secrets
+	PassphraseHeader = "X-Bundle-Passphrase"
+	// maxBundleSize limits the size of an uploaded bundle
+	maxBundleSize = 32 << 20
+)
+
+// ExportBundle downloads servers, users, permissions, settings and webhooks as a JSON bundle.
+// ?include_secrets=true adds credentials encrypted with the passphrase header and
+// ?include_passwords=true adds password hashes.
+func ExportBundle(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts := bundle.Options{
+			IncludeSecrets:   c.Query("include_secrets") == "true",
+			IncludePasswords: c.Query("include_passwords") == "true",
+			Passphrase:       c.GetHeader(PassphraseHeader),
+		}
+		b, err := bundle.Export(db, opts)
+		if err != nil {
+			if errors.Is(err, bundle.ErrPassphraseRequired) {
+				apierror.AbortMessage(c, apierror.InvalidRequest, err.Error())
+				return
+			}
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, 0, "exported a configuration bundle (secrets: %t, password hashes: %t)", opts.IncludeSecrets, opts.IncludePasswords)
+
+		filename := "dockermanager-bundle-" + time.Now().UTC().Format("20060102-150405") + ".json"
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		c.JSON(http.StatusOK, b)
+	}
+}
+
+// ImportBundle applies an uploaded bundle, or only reports what it would do with ?dry_run=true.
+// Bundles with secrets need the passphrase header they were exported with.
+func ImportBundle(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var b bundle.Bundle
+		if err := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxBundleSize)).Decode(&b); err != nil {
+			apierror.AbortMessage(c, apierror.InvalidRequest, apierror.T(c, "invalid_json"))
+			return
+		}
+		dryRun := c.Query("dry_run") == "true"
+
+		report, err := bundle.Import(db, &b, c.GetHeader(PassphraseHeader), dryRun)
+		switch {
+		case errors.Is(err, bundle.ErrConflicts):
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "bundle_conflicts", report.Counts[bundle.ActionConflict]))
+			return
+		case errors.Is(err, bundle.ErrInvalidBundle), errors.Is(err, bundle.ErrPassphraseRequired), errors.Is(err, bundle.ErrPassphrase):
+			apierror.AbortMessage(c, apierror.InvalidRequest, err.Error())
+			return
+		case err != nil:
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		if dryRun {
+			c.JSON(http.StatusOK, report)
+			return
+		}
+
+		for _, ch := range cache.All() {
+			ch.Flush()
+		}
+		if report.ConfigChanged {
+			if err := config.Reload(db); err != nil {
+				logging.L(c).Warn("failed to reload configuration after import", "error", err)
+			}
+		}
+		auditEvent(c, db, 0, "imported a configuration bundle (%d created, %d updated)",
+			report.Counts[bundle.ActionCreate], report.Counts[bundle.ActionUpdate])
+		c.JSON(http.StatusOK, report)
+	}
+}
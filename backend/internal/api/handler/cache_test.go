@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+func TestCachedResponsesReportTheirAge(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	server := seedServer(t, db, "web")
+	snapshot := primeContainers(t, server, model.Container{ID: "abc123", Name: "nginx", State: "running"})
+
+	r := newTestRouter(db)
+	r.GET("/servers/:id", GetServer(db))
+	r.GET("/servers/:id/containers", ListContainers(db))
+
+	var list model.ContainerListResponse
+	decode(t, request(r, http.MethodGet, "/servers/1/containers", "admin", nil), &list)
+	if !list.Cached || !list.FetchedAt.Equal(snapshot.fetchedAt) {
+		t.Fatalf("container list cached=%t fetched_at=%v, want true and %v", list.Cached, list.FetchedAt, snapshot.fetchedAt)
+	}
+
+	var details model.ServerDetails
+	w := request(r, http.MethodGet, "/servers/1", "admin", nil)
+	decode(t, w, &details)
+	if details.Cached || details.Name != "web" || time.Since(details.FetchedAt) > time.Minute {
+		t.Fatalf("first read cached=%t name=%q fetched_at=%v, want a fresh read of web", details.Cached, details.Name, details.FetchedAt)
+	}
+	if got := w.Header().Get("X-Cached"); got != "false" {
+		t.Fatalf("X-Cached = %q, want false", got)
+	}
+	first := details.FetchedAt
+
+	decode(t, request(r, http.MethodGet, "/servers/1", "admin", nil), &details)
+	if !details.Cached || !details.FetchedAt.Equal(first) {
+		t.Fatalf("second read cached=%t fetched_at=%v, want the cached %v", details.Cached, details.FetchedAt, first)
+	}
+}
+
+func TestForcedRefreshesAreLimitedPerServer(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	web := seedServer(t, db, "web")
+	db1 := seedServer(t, db, "db")
+	primeContainers(t, web, model.Container{ID: "abc123", Name: "nginx"})
+
+	r := newTestRouter(db)
+	r.GET("/servers/:id", GetServer(db))
+	r.GET("/servers/:id/containers", ListContainers(db))
+
+	var details model.ServerDetails
+	decode(t, request(r, http.MethodGet, "/servers/1?refresh=true", "admin", nil), &details)
+	if details.Cached {
+		t.Fatal("first forced refresh was served from the cache")
+	}
+
+	// The server's budget is spent: its container list is not listed again, which would take SSH
+	var list model.ContainerListResponse
+	decode(t, request(r, http.MethodGet, "/servers/1/containers?refresh=true", "admin", nil), &list)
+	if !list.Cached || len(list.Containers) != 1 {
+		t.Fatalf("container list cached=%t with %d containers, want the cached one", list.Cached, len(list.Containers))
+	}
+	decode(t, request(r, http.MethodGet, "/servers/1?refresh=true", "admin", nil), &details)
+	if !details.Cached {
+		t.Fatal("second forced refresh within the interval was not served from the cache")
+	}
+
+	// Other servers have budgets of their own
+	decode(t, request(r, http.MethodGet, "/servers/2?refresh=true", "admin", nil), &details)
+	if details.Cached || details.ID != db1.ID {
+		t.Fatalf("other server cached=%t id=%d, want a fresh read of %d", details.Cached, details.ID, db1.ID)
+	}
+}
+
+func TestContainerCacheIsAnnotatedPerUser(t *testing.T) {
+	db := newTestDB(t)
+	alice := seedUser(t, db, "alice", "user")
+	bob := seedUser(t, db, "bob", "user")
+	carol := seedUser(t, db, "carol", "user")
+	server := seedServer(t, db, "web")
+	grant(t, db, alice, server, model.AccessLevelFull)
+	grant(t, db, bob, server, model.AccessLevelRead)
+	primeContainers(t, server, model.Container{ID: "abc123", Name: "nginx"}, model.Container{ID: "def456", Name: "redis"})
+
+	r := newTestRouter(db)
+	r.GET("/servers/:id/containers", ListContainers(db))
+
+	// Back to back on the same cache entry, each user gets the list stamped for themselves
+	var etags []string
+	for _, u := range []struct {
+		user   model.User
+		access string
+	}{{alice, model.AccessLevelFull}, {bob, model.AccessLevelRead}, {alice, model.AccessLevelFull}} {
+		w := request(r, http.MethodGet, "/servers/1/containers", u.user.Username, nil)
+		var list model.ContainerListResponse
+		decode(t, w, &list)
+		if !list.Cached || len(list.Containers) != 2 {
+			t.Fatalf("%s got cached=%t with %d containers, want both from the shared entry", u.user.Username, list.Cached, len(list.Containers))
+		}
+		for _, container := range list.Containers {
+			if container.UserID != u.user.ID || container.Permission != u.access {
+				t.Fatalf("%s got %s stamped for user %d with %q, want user %d with %q",
+					u.user.Username, container.Name, container.UserID, container.Permission, u.user.ID, u.access)
+			}
+		}
+		etags = append(etags, w.Header().Get("ETag"))
+	}
+	if etags[0] == etags[1] || etags[0] != etags[2] {
+		t.Fatalf("ETags = %v, want one per user that stays stable", etags)
+	}
+
+	// Bob cannot revalidate against Alice's list and get it confirmed as his own
+	w := request(r, http.MethodGet, "/servers/1/containers", "bob", http.Header{"If-None-Match": {etags[0]}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("bob revalidating alice's ETag = %d, want 200 with his own list", w.Code)
+	}
+
+	// A user without a permission row is never served the cached entry
+	if w := request(r, http.MethodGet, "/servers/1/containers", carol.Username, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("carol = %d, want 403", w.Code)
+	}
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// composeLevels is the access level each compose action requires. down removes the project's
+// containers and networks.
+var composeLevels = map[string]string{
+	model.ComposeUp:      model.AccessLevelManage,
+	model.ComposeRestart: model.AccessLevelManage,
+	model.ComposePull:    model.AccessLevelManage,
+	model.ComposeDown:    model.AccessLevelFull,
+}
+
+// ListComposeProjects lists the compose projects of a server's containers, found from their
+// labels in the cached container list. ?refresh=true lists the containers again.
+func ListComposeProjects(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		snapshot, _, ok := loadContainers(c, server, forceServerRefresh(c, server.ID))
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, model.ComposeProjectsResponse{Projects: dockerapi.ComposeProjects(snapshot.containers)})
+	}
+}
+
+// ComposeProjectAction runs docker compose up -d, down, restart or pull and up -d on a project
+// in its working directory and responds with the output. A failing docker compose is reported
+// through the exit code and output rather than an error, so that its messages reach the user.
+func ComposeProjectAction(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := composeProjectParam(c)
+		if !ok {
+			return
+		}
+		var req model.ComposeActionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		server, ok := authorizeServerParam(c, db, composeLevels[req.Action])
+		if !ok {
+			return
+		}
+		project, ok := composeProject(c, server, name)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+
+		output, exitCode, err := sshClient.ComposeAction(c.Request.Context(), *project, req.Action)
+		// Even a failed command may have changed some of the containers
+		invalidateContainers(server.ID)
+		if err != nil {
+			sshFailed(c, server.ID, "compose_"+req.Action, err)
+			return
+		}
+		auditEvent(c, db, server.ID, "ran compose %s on project %s on %s (exit code %d)", req.Action, name, server.Name, exitCode)
+		c.JSON(http.StatusOK, model.ComposeActionResult{Project: name, Action: req.Action, Output: output, ExitCode: exitCode})
+	}
+}
+
+// GetComposeFile returns a config file of a compose project, the first one unless ?file= names
+// another of them. It requires full access, as compose files often hold secrets.
+func GetComposeFile(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := composeProjectParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		project, ok := composeProject(c, server, name)
+		if !ok {
+			return
+		}
+		file, ok := composeConfigFile(c, project, c.Query("file"))
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		content, err := sshClient.ReadComposeFile(c.Request.Context(), file)
+		if errors.Is(err, ssh.ErrComposeFileNotFound) {
+			apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "compose_file_not_found", file))
+			return
+		}
+		if err != nil {
+			sshFailed(c, server.ID, "read_compose_file", err)
+			return
+		}
+		c.JSON(http.StatusOK, model.ComposeFile{Project: name, Path: file, Files: project.ConfigFiles, Content: content})
+	}
+}
+
+// UpdateComposeFile replaces a config file of a compose project with YAML that parses as a
+// compose file, after copying the current file to a timestamped backup next to it. It responds
+// with the services the change added, removed or changed. The project is not recreated.
+func UpdateComposeFile(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := composeProjectParam(c)
+		if !ok {
+			return
+		}
+		var req model.ComposeFileUpdate
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		after, err := composeServices([]byte(req.Content))
+		if err != nil {
+			apierror.AbortField(c, apierror.ValidationFailed, "content", apierror.T(c, "compose_file_invalid", err.Error()))
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		project, ok := composeProject(c, server, name)
+		if !ok {
+			return
+		}
+		file, ok := composeConfigFile(c, project, req.File)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		current, err := sshClient.ReadComposeFile(c.Request.Context(), file)
+		var backup string
+		if err == nil {
+			backup, err = sshClient.WriteComposeFile(c.Request.Context(), file, []byte(req.Content))
+		}
+		if errors.Is(err, ssh.ErrComposeFileNotFound) {
+			apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "compose_file_not_found", file))
+			return
+		}
+		if err != nil {
+			sshFailed(c, server.ID, "write_compose_file", err)
+			return
+		}
+		auditEvent(c, db, server.ID, "edited compose file %s of project %s on %s", file, name, server.Name)
+		// A current file that does not parse counts as having no services
+		before, _ := composeServices([]byte(current))
+		c.JSON(http.StatusOK, model.ComposeFileResult{Project: name, Path: file, Backup: backup, Services: serviceChanges(before, after)})
+	}
+}
+
+// composeProjectParam validates the ":project" parameter. On failure the error response has
+// been written.
+func composeProjectParam(c *gin.Context) (string, bool) {
+	name := c.Param("project")
+	if !ssh.ValidContainerID(name) {
+		apierror.AbortField(c, apierror.ValidationFailed, "project", apierror.T(c, "validation_invalid", "project"))
+		return "", false
+	}
+	return name, true
+}
+
+// composeProject finds a compose project among the server's cached containers, responding with
+// not found when none of its containers exists. On failure the error response has been written.
+func composeProject(c *gin.Context, server *model.Server, name string) (*model.ComposeProject, bool) {
+	snapshot, _, ok := loadContainers(c, server, false)
+	if !ok {
+		return nil, false
+	}
+	for _, p := range dockerapi.ComposeProjects(snapshot.containers) {
+		if p.Name == name {
+			return &p, true
+		}
+	}
+	apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "compose_project_not_found", name))
+	return nil, false
+}
+
+// composeConfigFile picks one of the project's config files, the first when file is empty. Only
+// files compose recorded on the project's containers can be read or written. On failure the
+// error response has been written.
+func composeConfigFile(c *gin.Context, project *model.ComposeProject, file string) (string, bool) {
+	if len(project.ConfigFiles) == 0 {
+		apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "compose_file_unknown", project.Name))
+		return "", false
+	}
+	if file == "" {
+		file = project.ConfigFiles[0]
+	}
+	if !slices.Contains(project.ConfigFiles, file) || !path.IsAbs(file) {
+		apierror.AbortField(c, apierror.ValidationFailed, "file", apierror.T(c, "compose_file_other", project.Name))
+		return "", false
+	}
+	return file, true
+}
+
+// composeServices parses a compose file and returns its services by name. The file has to be a
+// YAML mapping whose services, if any, are a mapping too.
+func composeServices(content []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("the file is empty")
+	}
+	services := map[string]interface{}{}
+	if raw, ok := doc["services"]; ok && raw != nil {
+		if services, ok = raw.(map[string]interface{}); !ok {
+			return nil, errors.New("services must be a mapping of service names to their definitions")
+		}
+	}
+	return services, nil
+}
+
+// serviceChanges compares the services of two versions of a compose file, each list sorted
+func serviceChanges(before, after map[string]interface{}) model.ComposeServiceChanges {
+	changes := model.ComposeServiceChanges{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for name, definition := range after {
+		old, existed := before[name]
+		switch {
+		case !existed:
+			changes.Added = append(changes.Added, name)
+		case !reflect.DeepEqual(old, definition):
+			changes.Changed = append(changes.Changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changes.Removed = append(changes.Removed, name)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Changed)
+	return changes
+}
@@ -1,50 +1,133 @@
 package handler
 
 import (
-	"docker-pulse/internal/model"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/maintenance"
+	"docker-pulse/internal/model"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// GetTelegramConfig retrieves Telegram Bot Token and Web App URL from the database.
-func GetTelegramConfig(db *gorm.DB) gin.HandlerFunc {
+// GetConfig returns the effective value of every registered configuration key.
+func GetConfig(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var botToken, webAppURL string
+		values, err := config.Effective(db, true)
+		if err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, values)
+	}
+}
 
-		// Fetch Bot Token
-		var tokenConfig model.Config
-		if err := db.Where("key = ?", model.ConfigKeyTelegramBotToken).First(&tokenConfig).Error; err != nil {
-			if err != gorm.ErrRecordNotFound {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve Telegram Bot Token"})
+// UpdateConfig updates any number of registered keys in one request. A null value resets a key to its default.
+func UpdateConfig(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input map[string]interface{}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+
+		values := make(map[string]*string, len(input))
+		for key, raw := range input {
+			if raw == nil {
+				values[key] = nil
+				continue
+			}
+			var s string
+			switch v := raw.(type) {
+			case string:
+				s = v
+			case float64, bool:
+				s = fmt.Sprint(v)
+			default:
+				apierror.AbortField(c, apierror.ValidationFailed, key, apierror.T(c, "config_value_type", key))
 				return
 			}
-			// If not found, it's not an error, just means it's not configured yet.
-		} else {
-			botToken = tokenConfig.Value
+			values[key] = &s
 		}
 
-		// Fetch Web App URL
-		var urlConfig model.Config
-		if err := db.Where("key = ?", model.ConfigKeyTelegramWebAppURL).First(&urlConfig).Error; err != nil {
-			if err != gorm.ErrRecordNotFound {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve Telegram Web App URL"})
-				return
+		if !applyConfig(c, db, values) {
+			return
+		}
+
+		effective, err := config.Effective(db, true)
+		if err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, effective)
+	}
+}
+
+// applyConfig writes values through the registry and reports failures to the client
+func applyConfig(c *gin.Context, db *gorm.DB, values map[string]*string) bool {
+	wasMaintenance := maintenance.Active()
+	err := config.SetMany(db, values)
+	if err == nil {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		auditEvent(c, db, 0, "changed the settings %s", strings.Join(keys, ", "))
+		if isMaintenance := maintenance.Active(); isMaintenance != wasMaintenance {
+			if isMaintenance {
+				logging.L(c).Warn("maintenance mode enabled", "user", c.GetString("username"))
+				auditEvent(c, db, 0, "enabled maintenance mode")
+			} else {
+				logging.L(c).Info("maintenance mode disabled", "user", c.GetString("username"))
+				auditEvent(c, db, 0, "disabled maintenance mode")
 			}
-			// If not found, it's not an error.
-		} else {
-			webAppURL = urlConfig.Value
+		}
+		return true
+	}
+	var validationErr *config.ValidationError
+	switch {
+	case errors.Is(err, config.ErrUnknownKey):
+		apierror.AbortMessage(c, apierror.ValidationFailed, err.Error())
+	case errors.As(err, &validationErr):
+		apierror.AbortField(c, apierror.ValidationFailed, validationErr.Key, err.Error())
+	default:
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+	}
+	return false
+}
+
+// GetTelegramConfig retrieves Telegram Bot Token and Web App URL from the database.
+func GetTelegramConfig(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		botToken, err := config.Get(db, model.ConfigKeyTelegramBotToken)
+		if err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+
+		webAppURL, err := config.Get(db, model.ConfigKeyTelegramWebAppURL)
+		if err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"bot_token":   botToken,
+			"bot_token":   config.Masked(model.ConfigKeyTelegramBotToken, botToken),
 			"web_app_url": webAppURL,
 		})
 	}
 }
 
-// UpdateTelegramConfig updates Telegram Bot Token and Web App URL in the database.
+// UpdateTelegramConfig updates Telegram Bot Token and Web App URL in the database. The running
+// bot is reloaded by the change hooks of the two settings.
 func UpdateTelegramConfig(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
@@ -52,23 +135,14 @@ func UpdateTelegramConfig(db *gorm.DB) gin.HandlerFunc {
 			WebAppURL string `json:"web_app_url"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
-		// Update or create Bot Token
-		if err := db.Model(&model.Config{}).Where("key = ?", model.ConfigKeyTelegramBotToken).
-			Assign(model.Config{Value: input.BotToken}).
-			FirstOrCreate(&model.Config{}).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update Telegram Bot Token"})
-			return
-		}
-
-		// Update or create Web App URL
-		if err := db.Model(&model.Config{}).Where("key = ?", model.ConfigKeyTelegramWebAppURL).
-			Assign(model.Config{Value: input.WebAppURL}).
-			FirstOrCreate(&model.Config{}).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update Telegram Web App URL"})
+		if !applyConfig(c, db, map[string]*string{
+			model.ConfigKeyTelegramBotToken:  &input.BotToken,
+			model.ConfigKeyTelegramWebAppURL: &input.WebAppURL,
+		}) {
 			return
 		}
 
@@ -79,11 +153,7 @@ func UpdateTelegramConfig(db *gorm.DB) gin.HandlerFunc {
 // GetLatencyConfig retrieves the ping targets from the database.
 func GetLatencyConfig(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var pingTargets string
-		var config model.Config
-		if err := db.Where("key = ?", model.ConfigKeyPingTargets).First(&config).Error; err == nil {
-			pingTargets = config.Value
-		}
+		pingTargets, _ := config.Get(db, model.ConfigKeyPingTargets)
 		c.JSON(http.StatusOK, gin.H{"ping_targets": pingTargets})
 	}
 }
@@ -95,14 +165,11 @@ func UpdateLatencyConfig(db *gorm.DB) gin.HandlerFunc {
 			PingTargets string `json:"ping_targets"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
-		if err := db.Model(&model.Config{}).Where("key = ?", model.ConfigKeyPingTargets).
-			Assign(model.Config{Value: input.PingTargets}).
-			FirstOrCreate(&model.Config{}).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ping targets"})
+		if !applyConfig(c, db, map[string]*string{model.ConfigKeyPingTargets: &input.PingTargets}) {
 			return
 		}
 
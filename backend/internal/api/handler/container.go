@@ -1,380 +1,834 @@
 package handler
 
 import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/logging"
 	"docker-pulse/internal/model"
+	"docker-pulse/internal/pull"
 	"docker-pulse/internal/ssh"
 
 	"github.com/gin-gonic/gin"
-	"github.com/patrickmn/go-cache"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
 const (
-	containerCacheKeyPrefix = "containers_server_"
-	containerCacheTTL       = 5 * time.Minute
-	containerCacheCleanup   = 10 * time.Minute
+	containerCacheKeyPrefix      = "containers_server_"
+	containerStatsCacheKeyPrefix = "container_stats_server_"
 )
 
-// Cache for container lists
-var containerCache = cache.New(containerCacheTTL, containerCacheCleanup)
+// Cache for container lists, holding one containerSnapshot per server. Entries older than the
+// TTL are still served for containerStaleFor while they are refreshed in the background.
+var containerCache = cache.New("containers", model.ConfigKeyContainerCacheTTL, 5*time.Minute)
 
-// ListContainers handles fetching a list of Docker containers for a given server
-func ListContainers(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		id := c.Param("id")
-		serverID, err := strconv.ParseUint(id, 10, 32)
+// containerStaleFor is how long past the TTL a container list may be served while it is
+// refreshed; older lists are fetched before responding
+const containerStaleFor = time.Hour
+
+// containerFetches collapses concurrent listings of the same server into one
+var containerFetches singleflight.Group
+
+// containerSnapshot is the user-neutral container list of a server. Per-user fields are filled in
+// after it is taken from the cache, so the entry is safe to share between users.
+type containerSnapshot struct {
+	containers []model.Container
+	etag       string
+	fetchedAt  time.Time
+}
+
+func newContainerSnapshot(containers []model.Container) (containerSnapshot, error) {
+	body, err := json.Marshal(containers)
+	if err != nil {
+		return containerSnapshot{}, err
+	}
+	return containerSnapshot{containers: containers, etag: hashETag(body), fetchedAt: time.Now()}, nil
+}
+
+// stale tells whether the snapshot is older than the cache TTL and due to be refreshed
+func (s containerSnapshot) stale() bool {
+	return time.Since(s.fetchedAt) >= containerCache.TTL()
+}
+
+// containerFetchError is a failed container listing with the operation that failed
+type containerFetchError struct {
+	op  string
+	err error
+}
+
+func (e *containerFetchError) Error() string { return e.op + ": " + e.err.Error() }
+
+func (e *containerFetchError) Unwrap() error { return e.err }
+
+// fetchContainers lists the server's containers and caches the snapshot. Calls for the same
+// server while a listing runs wait for it and share its result. The listing is not bound by
+// ctx's cancellation, as other callers may be waiting for it, only by the command timeout.
+func fetchContainers(ctx context.Context, server model.Server) (containerSnapshot, error) {
+	key := containerCacheKey(server.ID)
+	v, err, _ := containerFetches.Do(key, func() (interface{}, error) {
+		backend, err := ssh.NewServerBackend(&server)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
-			return
+			return nil, &containerFetchError{op: "connect", err: err}
 		}
+		containers, err := backend.ListContainers(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, &containerFetchError{op: "list_containers", err: err}
+		}
+		snapshot, err := newContainerSnapshot(containers)
+		if err != nil {
+			return nil, err
+		}
+		containerCache.SetFor(key, snapshot, containerCache.TTL()+containerStaleFor)
+		return snapshot, nil
+	})
+	if err != nil {
+		return containerSnapshot{}, err
+	}
+	return v.(containerSnapshot), nil
+}
 
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
-
-		// 权限检查：非管理员必须拥有显式权限
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "access to this server is denied"})
-					return
+// loadContainers returns the server's container list from the cache, fetching it on a miss or
+// when refresh is set. A stale list is returned at once and refreshed in the background.
+// cached tells whether the list came from the cache. On failure the error response has been
+// written.
+func loadContainers(c *gin.Context, server *model.Server, refresh bool) (snapshot containerSnapshot, cached, ok bool) {
+	if !refresh {
+		if v, found := containerCache.Get(containerCacheKey(server.ID)); found {
+			if snapshot, ok := v.(containerSnapshot); ok {
+				if snapshot.stale() {
+					refreshContainers(c, *server)
 				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
-				return
+				return snapshot, true, true
 			}
 		}
+	}
+	snapshot, err := fetchContainers(c.Request.Context(), *server)
+	if err != nil {
+		var fetchErr *containerFetchError
+		if !errors.As(err, &fetchErr) {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return snapshot, false, false
+		}
+		sshFailed(c, server.ID, fetchErr.op, fetchErr.err)
+		return snapshot, false, false
+	}
+	return snapshot, false, true
+}
 
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server from DB"})
-			return
+// refreshContainers fetches the server's container list in the background, unless a listing of
+// the server already runs
+func refreshContainers(c *gin.Context, server model.Server) {
+	logger := logging.L(c).With("server_id", server.ID)
+	go func() {
+		if _, err := fetchContainers(context.Background(), server); err != nil {
+			logger.Warn("background container list refresh failed", "error", err)
 		}
+	}()
+}
+
+// containerSnapshotFor returns the server's cached container list, fetching it on a miss
+func containerSnapshotFor(c *gin.Context, server *model.Server) (containerSnapshot, bool) {
+	snapshot, _, ok := loadContainers(c, server, false)
+	return snapshot, ok
+}
+
+// write sends the snapshot annotated for the given user and access level, filtered and shaped by
+// q. The ETag covers all three, so a client never revalidates against a list annotated for
+// someone else or shaped differently. It does not cover cached and fetched_at, which describe
+// the data's age rather than the data.
+func (s containerSnapshot) write(c *gin.Context, userID uint, access string, q containerQuery, cached bool) {
+	etag := hashETag([]byte(s.etag), []byte(fmt.Sprintf("%d:%s:%s", userID, access, q.key())))
+	writeWithETag(c, etag, s.fetchedAt, cached, func() ([]byte, error) {
+		page, filtered := q.apply(s.containers)
+		containers := make([]model.Container, len(page))
+		for i, container := range page {
+			container.UserID = userID
+			container.Permission = access
+			containers[i] = container
+		}
+		if q.groupBy == "project" {
+			return json.Marshal(model.ContainerGroupsResponse{Groups: groupContainers(containers), Total: len(s.containers), Filtered: filtered, Page: q.page, PageSize: q.pageSize, FetchedAt: s.fetchedAt.UTC(), Cached: cached})
+		}
+		return json.Marshal(model.ContainerListResponse{Containers: containers, Total: len(s.containers), Filtered: filtered, Page: q.page, PageSize: q.pageSize, FetchedAt: s.fetchedAt.UTC(), Cached: cached})
+	})
+}
+
+// actionLevels is the access level each container action requires
+var actionLevels = map[string]string{
+	"start":   model.AccessLevelManage,
+	"stop":    model.AccessLevelManage,
+	"restart": model.AccessLevelManage,
+	"pause":   model.AccessLevelManage,
+	"unpause": model.AccessLevelManage,
+	"kill":    model.AccessLevelManage,
+	"rename":  model.AccessLevelManage,
+	"pull":    model.AccessLevelManage,
+	"remove":  model.AccessLevelFull,
+	"update":  model.AccessLevelFull,
+}
 
-		cacheKey := fmt.Sprintf("%s%d", containerCacheKeyPrefix, serverID)
+// destructiveActions are the actions that are only run after checking that the container exists
+// on the server, so that a wrong ID is answered with not found rather than a failed command
+var destructiveActions = []string{"kill", "remove", pull.ActionUpdate}
+
+// actionDone is how the success message of each synchronous action reads
+var actionDone = map[string]string{
+	"start":   "started",
+	"stop":    "stopped",
+	"restart": "restarted",
+	"pause":   "paused",
+	"unpause": "unpaused",
+	"kill":    "killed",
+	"rename":  "renamed",
+	"remove":  "removed",
+}
 
-		// 尝试从缓存中获取
-		if cachedContainers, found := containerCache.Get(cacheKey); found {
-			c.JSON(http.StatusOK, cachedContainers)
+// ListContainers handles fetching a list of Docker containers for a given server. The whole list
+// is cached once per server and filtered, sorted and paged per request.
+func ListContainers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverID, ok := parseID(c, "id")
+		if !ok {
 			return
 		}
-
-		// 缓存未命中，从 SSH 获取
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create SSH client: %v", err)})
+		access, ok := checkAccess(c, db, serverID, model.AccessLevelRead)
+		if !ok {
 			return
 		}
-
-		output, err := sshClient.GetContainers()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get containers from server: %v", err)})
+		server, ok := loadServer(c, db, serverID)
+		if !ok {
+			return
+		}
+		userID, _, _ := currentUser(c)
+		query, ok := parseContainerQuery(c)
+		if !ok {
 			return
 		}
 
-		containers := parseContainerOutput(output, uint(serverID), userID.(uint))
-
-		// 存入缓存
-		containerCache.Set(cacheKey, model.ContainerListResponse{Containers: containers, Total: len(containers)}, containerCacheTTL)
-
-		c.JSON(http.StatusOK, model.ContainerListResponse{Containers: containers, Total: len(containers)})
+		snapshot, cached, ok := loadContainers(c, server, forceServerRefresh(c, server.ID))
+		if !ok {
+			return
+		}
+		snapshot.write(c, userID, access, query, cached)
 	}
 }
 
 // ContainerAction handles starting, stopping, restarting, or removing a Docker container
 func ContainerAction(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		serverID, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
 		var req model.ContainerActionRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
+			return
+		}
+		// The server is the one in the path, which access is checked for. The body may repeat
+		// it, as older clients send it, but never name another one.
+		if req.ServerID != 0 && req.ServerID != serverID {
+			apierror.AbortField(c, apierror.ValidationFailed, "server_id", apierror.T(c, "server_id_mismatch", req.ServerID, serverID))
+			return
+		}
+		if !ssh.ValidContainerID(req.ContainerID) {
+			apierror.AbortField(c, apierror.ValidationFailed, "container_id", apierror.T(c, "validation_invalid", "container_id"))
 			return
 		}
 
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+		level, known := actionLevels[req.Action]
+		if !known {
+			apierror.AbortField(c, apierror.InvalidRequest, "action", apierror.T(c, "unknown_action"))
+			return
+		}
 
-		// 权限检查
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, req.ServerID).First(&permission).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "access to this server is denied"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
+		signal, valid := dockerapi.ParseSignal(req.Signal)
+		if req.Action == "kill" && !valid {
+			apierror.AbortField(c, apierror.ValidationFailed, "signal", apierror.T(c, "signal_invalid", req.Signal, strings.Join(dockerapi.KillSignals, ", ")))
+			return
+		}
+		if req.Action == "rename" {
+			if req.NewName == "" {
+				apierror.AbortField(c, apierror.ValidationFailed, "new_name", apierror.T(c, "validation_required", "new_name"))
 				return
 			}
-
-			// Check access level
-			switch req.Action {
-			case "remove":
-				if permission.AccessLevel != model.AccessLevelFull {
-					c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions: 'full' access required for removal"})
-					return
-				}
-			case "start", "stop", "restart", "pull":
-				if permission.AccessLevel != model.AccessLevelManage && permission.AccessLevel != model.AccessLevelFull {
-					c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions: 'manage' access required for this action"})
-					return
-				}
-			default:
-				c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions for this action"})
+			if !ssh.ValidContainerID(req.NewName) || len(req.NewName) < 2 {
+				apierror.AbortField(c, apierror.ValidationFailed, "new_name", apierror.T(c, "container_name_invalid", req.NewName))
 				return
 			}
 		}
 
-		var server model.Server
-		if err := db.First(&server, req.ServerID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
+		server, ok := authorizeServer(c, db, serverID, level)
+		if !ok {
+			return
+		}
+		if slices.Contains(destructiveActions, req.Action) && !containerExists(c, server, req.ContainerID) {
+			return
+		}
+
+		// Pulls and updates can take minutes, so they run in the background and the response is
+		// the task clients follow with GET /tasks/:id or the /ws/tasks/:id WebSocket
+		if req.Action == pull.ActionPull || req.Action == pull.ActionUpdate {
+			if req.Action == pull.ActionUpdate {
+				auditEvent(c, db, server.ID, "started updating container %s on %s", req.ContainerID, server.Name)
+			}
+			job, err := pull.Start(*server, req.ContainerID, req.Action)
+			if err != nil {
+				apierror.AbortCause(c, apierror.Internal, err)
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server from DB"})
+			go func() {
+				<-job.Done()
+				invalidateContainers(server.ID)
+			}()
+			c.JSON(http.StatusAccepted, job.Snapshot(0))
 			return
 		}
 
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create SSH client: %v", err)})
+		backend, ok := dockerBackend(c, server)
+		if !ok {
 			return
 		}
 
-		err = sshClient.ExecuteContainerAction(req.ContainerID, req.Action)
+		err := runContainerAction(c.Request.Context(), backend, req.ContainerID, req.Action, signal, req.NewName)
+		var stateErr *dockerapi.StateError
+		if errors.As(err, &stateErr) {
+			apierror.AbortMessage(c, apierror.Conflict, stateErr.Message)
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to execute container action: %v", err)})
+			sshFailed(c, server.ID, "container_action", err)
 			return
 		}
 
 		// 操作成功后，清除缓存以确保下次请求获取最新数据
-		cacheKey := fmt.Sprintf("%s%d", containerCacheKeyPrefix, req.ServerID)
-		containerCache.Delete(cacheKey)
+		invalidateContainers(server.ID)
 
-		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("container %s %sed successfully", req.ContainerID, req.Action)})
+		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("container %s %s successfully", req.ContainerID, actionDone[req.Action])})
 	}
 }
 
-// GetContainerLogs handles fetching logs for a specific Docker container
-func GetContainerLogs(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		serverIDStr := c.Param("id")
-		containerID := c.Param("containerID")
-		tail := c.DefaultQuery("tail", "all") // Default to all logs
+// containerExists checks that the container exists on the server, responding with not found
+// when it does not. On failure the error response has been written.
+func containerExists(c *gin.Context, server *model.Server, containerID string) bool {
+	backend, ok := dockerBackend(c, server)
+	if !ok {
+		return false
+	}
+	if _, err := backend.InspectContainer(c.Request.Context(), containerID); err != nil {
+		if dockerapi.IsNoSuchContainer(err) {
+			apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "container_not_found", containerID))
+			return false
+		}
+		sshFailed(c, server.ID, "inspect", err)
+		return false
+	}
+	return true
+}
 
-		serverID, err := strconv.ParseUint(serverIDStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+// runContainerAction runs one of the synchronous actions of actionDone. signal is for kill and
+// newName for rename.
+func runContainerAction(ctx context.Context, backend dockerapi.Backend, containerID, action, signal, newName string) error {
+	switch action {
+	case "kill":
+		return backend.KillContainer(ctx, containerID, signal)
+	case "rename":
+		return backend.RenameContainer(ctx, containerID, newName)
+	default:
+		return backend.ContainerAction(ctx, containerID, action)
+	}
+}
+
+// BatchContainerAction runs an action on several containers of a server, one after another over
+// the same connection. Access is checked once, for the level the action requires. A container the
+// action fails for is reported in its result and does not stop the others; the batch is audited
+// as a whole.
+func BatchContainerAction(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.BatchActionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
 			return
 		}
+		signal, valid := dockerapi.ParseSignal(req.Signal)
+		if req.Action == "kill" && !valid {
+			apierror.AbortField(c, apierror.ValidationFailed, "signal", apierror.T(c, "signal_invalid", req.Signal, strings.Join(dockerapi.KillSignals, ", ")))
+			return
+		}
+		var containerIDs []string
+		for _, id := range req.ContainerIDs {
+			if !ssh.ValidContainerID(id) {
+				apierror.AbortField(c, apierror.ValidationFailed, "container_ids", apierror.T(c, "validation_invalid", id))
+				return
+			}
+			if !slices.Contains(containerIDs, id) {
+				containerIDs = append(containerIDs, id)
+			}
+		}
 
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+		server, ok := authorizeServerParam(c, db, actionLevels[req.Action])
+		if !ok {
+			return
+		}
+		backend, ok := dockerBackend(c, server)
+		if !ok {
+			return
+		}
 
-		// 权限检查
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "access to this server is denied"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
-				return
+		resp := model.BatchActionResponse{Action: req.Action, Results: []model.BatchActionResult{}}
+		var failures []string
+		for _, id := range containerIDs {
+			result := model.BatchActionResult{ContainerID: id}
+			err := runContainerAction(c.Request.Context(), backend, id, req.Action, signal, "")
+			if err != nil {
+				logging.L(c).Warn("batch container action failed", "server_id", server.ID, "container_id", id, "action", req.Action, "error", err)
+				result.Error = batchActionError(c, err)
+				failures = append(failures, fmt.Sprintf("%s (%s)", id, result.Error))
+				resp.Failed++
+			} else {
+				result.Success = true
+				result.Message = fmt.Sprintf("container %s %s successfully", id, actionDone[req.Action])
+				resp.Succeeded++
 			}
-			// TODO: Add more granular container-level permissions if needed
+			resp.Results = append(resp.Results, result)
 		}
 
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
+		if resp.Succeeded > 0 {
+			invalidateContainers(server.ID)
+		}
+		detail := fmt.Sprintf("ran %s on %d containers on %s: %d succeeded", req.Action, len(containerIDs), server.Name, resp.Succeeded)
+		if len(failures) > 0 {
+			detail += fmt.Sprintf(", %d failed: %s", resp.Failed, strings.Join(failures, ", "))
+		}
+		auditEvent(c, db, server.ID, "%s", detail)
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// batchActionError is the error reported for a container of a batch action: docker's message
+// when the daemon refused the action, or the message of the code a single action answers with
+func batchActionError(c *gin.Context, err error) string {
+	if msg, ok := dockerapi.DaemonMessage(err); ok {
+		return msg
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return apierror.Message(apierror.Language(c), apierror.Timeout)
+	}
+	return apierror.Message(apierror.Language(c), apierror.SSHCommandFailed)
+}
+
+// GetPullTask returns the progress of an image pull started by the pull container action and
+// its output from ?offset= on. Clients follow a pull by passing the previous response's
+// next_offset until the status is no longer running.
+func GetPullTask(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, found := pull.Get(c.Param("id"))
+		if !found {
+			apierror.Abort(c, apierror.NotFound)
+			return
+		}
+		if _, ok := checkAccess(c, db, job.ServerID(), model.AccessLevelRead); !ok {
+			return
+		}
+		offset, _ := strconv.ParseInt(c.Query("offset"), 10, 64)
+		c.JSON(http.StatusOK, job.Snapshot(offset))
+	}
+}
+
+// GetContainerLogs handles fetching logs for a specific Docker container. With ?grep= only the
+// lines matching that regular expression are returned, at most log_search_max_matches of them.
+func GetContainerLogs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		opts, ok := logOptions(c, db)
+		if !ok {
+			return
+		}
+		var grep *regexp.Regexp
+		if v := c.Query("grep"); v != "" {
+			if grep, ok = logPattern(c, "grep", v, false); !ok {
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server from DB"})
+		}
+
+		// TODO: Add more granular container-level permissions if needed
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
 			return
 		}
 
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create SSH client: %v", err)})
+		backend, ok := dockerBackend(c, server)
+		if !ok {
 			return
 		}
 
-		logs, err := sshClient.GetContainerLogs(containerID, tail)
+		logs, err := backend.ContainerLogs(c.Request.Context(), containerID, opts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get container logs: %v", err)})
+			sshFailed(c, server.ID, "container_logs", err)
 			return
 		}
+		defer logs.Close()
 
-		c.JSON(http.StatusOK, model.ContainerLogResponse{Logs: logs})
+		// Logs are passed on as they arrive rather than held in memory. Reading ahead lets a
+		// command that fails right away, such as for an unknown container, still get an error
+		// response; a later failure can only cut the response short.
+		r := bufio.NewReader(logs)
+		if _, err := r.Peek(1); err != nil && err != io.EOF {
+			sshFailed(c, server.ID, "container_logs", err)
+			return
+		}
+		var src io.Reader = r
+		var filter *dockerapi.LineFilter
+		if grep != nil {
+			filter = dockerapi.FilterLines(r, grep, config.GetInt(db, model.ConfigKeyLogSearchMaxMatches))
+			src = filter
+		}
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(http.StatusOK)
+		err = writeJSONChunks(c.Writer, `{"logs":"`, src, `"`)
+		if err == nil {
+			suffix := "}"
+			if filter != nil && filter.LimitReached() {
+				suffix = `,"limit_reached":true}`
+			}
+			_, err = io.WriteString(c.Writer, suffix)
+		}
+		if err != nil {
+			logging.L(c).Warn("container log stream failed", "server_id", server.ID, "error", err)
+		}
 	}
 }
 
-// GetContainerDetails handles fetching detailed information for a specific Docker container
-func GetContainerDetails(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		serverIDStr := c.Param("id")
-		containerID := c.Param("containerID")
+// logOptions reads the ?tail=, ?since=, ?until= and ?timestamps= of a log request. tail is
+// "all", the default, or at most log_tail_max lines. On failure the error response has been
+// written.
+func logOptions(c *gin.Context, db *gorm.DB) (dockerapi.LogOptions, bool) {
+	opts, ok := parseLogOptions(c, db, c.Query("tail"), c.Query("since"), c.Query("until"))
+	if !ok {
+		return opts, false
+	}
+	if v := c.Query("timestamps"); v != "" {
+		timestamps, err := strconv.ParseBool(v)
+		if err != nil {
+			apierror.AbortField(c, apierror.ValidationFailed, "timestamps", apierror.T(c, "validation_type", "timestamps", "boolean"))
+			return opts, false
+		}
+		opts.Timestamps = timestamps
+	}
+	return opts, true
+}
 
-		serverID, err := strconv.ParseUint(serverIDStr, 10, 32)
+// parseLogOptions checks the tail and time bounds of a log request, an empty tail meaning "all".
+// On failure the error response has been written.
+func parseLogOptions(c *gin.Context, db *gorm.DB, tail, since, until string) (dockerapi.LogOptions, bool) {
+	opts := dockerapi.LogOptions{Tail: tail}
+	if opts.Tail == "" {
+		opts.Tail = "all"
+	}
+	if opts.Tail != "all" {
+		n, err := strconv.Atoi(opts.Tail)
+		maxTail := config.GetInt(db, model.ConfigKeyLogTailMax)
+		switch {
+		case err != nil:
+			apierror.AbortField(c, apierror.ValidationFailed, "tail", apierror.T(c, "validation_type", "tail", "integer"))
+			return opts, false
+		case n < 1:
+			apierror.AbortField(c, apierror.ValidationFailed, "tail", apierror.T(c, "validation_min", "tail", "1"))
+			return opts, false
+		case n > maxTail:
+			apierror.AbortField(c, apierror.ValidationFailed, "tail", apierror.T(c, "validation_max", "tail", strconv.Itoa(maxTail)))
+			return opts, false
+		}
+		opts.Tail = strconv.Itoa(n)
+	}
+	now := time.Now()
+	for _, bound := range []struct {
+		name, value string
+		t           *time.Time
+	}{{"since", since, &opts.Since}, {"until", until, &opts.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := dockerapi.ParseLogTime(bound.value, now)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
-			return
+			apierror.AbortField(c, apierror.ValidationFailed, bound.name, apierror.T(c, "log_time_invalid", bound.name))
+			return opts, false
 		}
+		*bound.t = t
+	}
+	return opts, true
+}
 
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+// logPattern compiles the regular expression of a log search, responding with a validation
+// error on the field when it is invalid
+func logPattern(c *gin.Context, field, pattern string, ignoreCase bool) (*regexp.Regexp, bool) {
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		apierror.AbortField(c, apierror.ValidationFailed, field, apierror.T(c, "log_pattern_invalid", field))
+		return nil, false
+	}
+	return re, true
+}
 
-		// 权限检查
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "access to this server is denied"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
-				return
+// writeJSONChunks writes prefix, the content of r escaped as the inside of a JSON string, and
+// suffix. Runes split between reads are held back until they are complete, so the output is what
+// json.Marshal makes of the whole content, invalid UTF-8 included.
+func writeJSONChunks(w io.Writer, prefix string, r io.Reader, suffix string) error {
+	if _, err := io.WriteString(w, prefix); err != nil {
+		return err
+	}
+	buf := make([]byte, 32<<10)
+	pending := 0
+	for {
+		n, err := r.Read(buf[pending:])
+		n += pending
+		end := n
+		if err == nil {
+			end = completeRunes(buf[:n])
+		}
+		if end > 0 {
+			quoted, _ := json.Marshal(string(buf[:end]))
+			if _, werr := w.Write(quoted[1 : len(quoted)-1]); werr != nil {
+				return werr
 			}
 		}
+		pending = copy(buf, buf[end:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, suffix)
+	return err
+}
 
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
-				return
+// completeRunes returns the length of b without a rune cut off at its end
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server from DB"})
-			return
+			return i
 		}
+	}
+	return len(b)
+}
 
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create SSH client: %v", err)})
+// ContainerDetailsResponse is a container's inspect output in sections, with its GPU request,
+// null when it has none
+type ContainerDetailsResponse struct {
+	model.ContainerDetails
+	GPUs *ssh.GPURequest `json:"gpus"`
+}
+
+// GetContainerDetails handles fetching detailed information for a specific Docker container,
+// parsed into sections, or with ?raw=true the docker inspect output as it is. Values of sensitive
+// environment variables are masked for non-admins either way.
+func GetContainerDetails(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
 			return
 		}
 
-		details, err := sshClient.GetContainerDetails(containerID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get container details: %v", err)})
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"details": details})
-	}
-}
-
-// CheckContainerImageUpdate handles checking if a container's image has an update
-func CheckContainerImageUpdate(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		serverIDStr := c.Param("id")
-		containerID := c.Param("containerID")
+		backend, ok := dockerBackend(c, server)
+		if !ok {
+			return
+		}
 
-		serverID, err := strconv.ParseUint(serverIDStr, 10, 32)
+		details, err := backend.InspectContainer(c.Request.Context(), containerID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+			sshFailed(c, server.ID, "container_inspect", err)
 			return
 		}
+		_, role, _ := currentUser(c)
+		admin := role == "admin"
 
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
-
-		// 权限检查
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "access to this server is denied"})
+		if c.Query("raw") == "true" {
+			raw := details
+			if !admin {
+				if raw, err = dockerapi.MaskInspectEnv(details); err != nil {
+					apierror.AbortCause(c, apierror.SSHCommandFailed, err)
 					return
 				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
-				return
 			}
-		}
-
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
-				return
+			var restartPolicy *model.RestartPolicy
+			if policy, err := dockerapi.RestartPolicyFromInspect(details); err == nil {
+				restartPolicy = &policy
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server from DB"})
+			c.JSON(http.StatusOK, gin.H{"details": raw, "gpus": ssh.GPURequestFromInspect(details), "restart_policy": restartPolicy})
 			return
 		}
 
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
+		parsed, err := dockerapi.ParseDetails(details)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create SSH client: %v", err)})
+			apierror.AbortCause(c, apierror.SSHCommandFailed, err)
 			return
 		}
+		if !admin {
+			parsed.Config.Env = dockerapi.MaskEnv(parsed.Config.Env)
+		}
+		// The networks are found beside the container, under the same server
+		serverPath, _, _ := strings.Cut(c.Request.URL.Path, "/containers/")
+		for i := range parsed.Network.Networks {
+			parsed.Network.Networks[i].Link = serverPath + "/networks/" + parsed.Network.Networks[i].Name
+		}
+		c.JSON(http.StatusOK, ContainerDetailsResponse{ContainerDetails: parsed, GPUs: ssh.GPURequestFromInspect(details)})
+	}
+}
 
-		hasUpdate, err := sshClient.CheckForImageUpdate(containerID)
+// UpdateRestartPolicy changes a container's restart policy. It requires manage access.
+func UpdateRestartPolicy(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+		if !ok {
+			return
+		}
+		var req model.RestartPolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		policy, err := dockerapi.ParseRestartPolicy(req.Policy)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to check for image update: %v", err)})
+			apierror.AbortField(c, apierror.ValidationFailed, "policy", apierror.T(c, "restart_policy_invalid", req.Policy))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"has_update": hasUpdate})
+		backend, ok := dockerBackend(c, server)
+		if !ok {
+			return
+		}
+		if err := backend.UpdateRestartPolicy(c.Request.Context(), containerID, policy); err != nil {
+			sshFailed(c, server.ID, "container_update", err)
+			return
+		}
+		invalidateContainers(server.ID)
+		c.JSON(http.StatusOK, policy)
 	}
 }
 
-// ListContainerFiles handles fetching a list of files/directories inside a container
-func ListContainerFiles(db *gorm.DB) gin.HandlerFunc {
+// GetContainerHealth returns the state of a container's health check with its latest probes,
+// the last ?limit= of them when set
+func GetContainerHealth(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		serverIDStr := c.Param("id")
-		containerID := c.Param("containerID")
-		path := c.DefaultQuery("path", "/") // Default path is root
-
-		serverID, err := strconv.ParseUint(serverIDStr, 10, 32)
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		backend, ok := dockerBackend(c, server)
+		if !ok {
+			return
+		}
+		details, err := backend.InspectContainer(c.Request.Context(), containerID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+			sshFailed(c, server.ID, "container_inspect", err)
 			return
 		}
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		health, err := dockerapi.ParseHealth(details, max(limit, 0))
+		if err != nil {
+			apierror.AbortCause(c, apierror.SSHCommandFailed, err)
+			return
+		}
+		c.JSON(http.StatusOK, health)
+	}
+}
 
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+// ListContainerProcesses lists the processes running in a container, as docker top reports them
+func ListContainerProcesses(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		backend, ok := dockerBackend(c, server)
+		if !ok {
+			return
+		}
+		top, err := backend.ContainerTop(c.Request.Context(), containerID)
+		if errors.Is(err, dockerapi.ErrNotRunning) {
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "container_not_running", containerID))
+			return
+		}
+		if err != nil {
+			sshFailed(c, server.ID, "container_top", err)
+			return
+		}
+		c.JSON(http.StatusOK, model.ContainerProcessesResponse{Titles: top.Titles, Processes: top.ContainerProcesses()})
+	}
+}
 
-		// 权限检查
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "access to this server is denied"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
-				return
-			}
+// ListContainerFiles handles fetching a list of files/directories inside a container
+func ListContainerFiles(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		path, ok := cleanContainerPath(c, "path", c.DefaultQuery("path", "/")) // Default path is root
+		if !ok {
+			return
 		}
 
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server from DB"})
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
 			return
 		}
 
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create SSH client: %v", err)})
+		sshClient, ok := connectServer(c, server)
+		if !ok {
 			return
 		}
 
-		files, err := sshClient.ListContainerFiles(containerID, path)
+		files, err := sshClient.ListContainerFiles(c.Request.Context(), containerID, path)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to list container files: %v", err)})
+			sshFailed(c, server.ID, "list_container_files", err)
 			return
 		}
 
@@ -382,108 +836,184 @@ func ListContainerFiles(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// GetContainerFileContent handles fetching the content of a file inside a container
+// GetContainerFileContent handles fetching the content of a file inside a container, or the part
+// of it ?offset= and ?limit=, ?head= or ?tail= select. Files that look binary or are larger than
+// file_content_max_bytes are answered without content, pointing to the download endpoint, unless
+// ?encoding=base64 asks for binary content.
 func GetContainerFileContent(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		serverIDStr := c.Param("id")
-		containerID := c.Param("containerID")
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
 		path := c.Query("path") // Path is required
 
 		if path == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "file path is required"})
+			apierror.AbortField(c, apierror.InvalidRequest, "path", apierror.T(c, "path_required"))
 			return
 		}
-
-		serverID, err := strconv.ParseUint(serverIDStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+		path, ok = cleanContainerPath(c, "path", path)
+		if !ok {
 			return
 		}
-
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
-
-		// 权限检查
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "access to this server is denied"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
-				return
-			}
+		encoding := c.Query("encoding")
+		if encoding != "" && encoding != "base64" {
+			apierror.AbortField(c, apierror.ValidationFailed, "encoding", apierror.T(c, "validation_oneof", "encoding", "base64"))
+			return
+		}
+		maxSize := int64(config.GetInt(db, model.ConfigKeyFileContentMax))
+		fileRange, ranged, ok := parseFileRange(c, maxSize)
+		if !ok {
+			return
 		}
 
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server from DB"})
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
 			return
 		}
 
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create SSH client: %v", err)})
+		sshClient, ok := connectServer(c, server)
+		if !ok {
 			return
 		}
 
-		content, err := sshClient.GetContainerFileContent(containerID, path)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get file content: %v", err)})
+		var content []byte
+		var err error
+		resp := model.FileContentResponse{Path: path}
+		if ranged {
+			content, resp.Offset, resp.TotalSize, err = sshClient.ReadContainerFileRange(c.Request.Context(), containerID, path, fileRange, maxSize)
+		} else {
+			content, resp.TotalSize, err = sshClient.GetContainerFileContent(c.Request.Context(), containerID, path, maxSize)
+		}
+		switch {
+		case errors.Is(err, ssh.ErrNotAFile):
+			apierror.AbortField(c, apierror.InvalidRequest, "path", apierror.T(c, "path_not_a_file"))
+			return
+		case errors.Is(err, ssh.ErrFileNotFound):
+			apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "file_not_found", path))
+			return
+		case errors.Is(err, dockerapi.ErrNotRunning):
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "container_not_running", containerID))
+			return
+		case errors.Is(err, ssh.ErrRangeNotSatisfiable):
+			apierror.AbortField(c, apierror.RangeNotSatisfiable, "offset", apierror.T(c, "file_range_offset", fileRange.Offset, resp.TotalSize))
+			return
+		case errors.Is(err, ssh.ErrFileTooLarge):
+			resp.Size = resp.TotalSize
+			resp.IsBinary, resp.TooLarge = true, true
+			resp.Hint = apierror.T(c, "file_too_large_hint", strconv.FormatInt(maxSize, 10))
+			c.JSON(http.StatusOK, resp)
+			return
+		case err != nil:
+			sshFailed(c, server.ID, "read_container_file", err)
 			return
 		}
 
-		c.JSON(http.StatusOK, model.FileContentResponse{Path: path, Content: content})
+		writeFileContent(c, resp, content, ranged, encoding)
 	}
 }
 
-// parseContainerOutput parses the raw output from "docker ps -a --format" into a slice of Container models
-func parseContainerOutput(output string, serverID, userID uint) []model.Container {
-	var containers []model.Container
-	lines := strings.Split(strings.TrimSpace(output), "\n")
+// writeFileContent responds with content read from a file, or the part of it at resp.Offset
+// when ranged. resp.TotalSize is the file's size. Content that looks binary is left out unless
+// encoding asks for base64.
+func writeFileContent(c *gin.Context, resp model.FileContentResponse, content []byte, ranged bool, encoding string) {
+	if ranged && encoding == "" {
+		content, resp.Offset = trimRunes(content, resp.Offset, resp.TotalSize)
+	}
+	resp.Size = int64(len(content))
+	resp.EOF = resp.Offset+resp.Size >= resp.TotalSize
+	switch {
+	case encoding == "base64":
+		resp.Content = base64.StdEncoding.EncodeToString(content)
+		resp.Encoding = encoding
+	case looksBinary(content):
+		resp.IsBinary = true
+		resp.Hint = apierror.T(c, "file_binary_hint")
+	default:
+		resp.Content = string(content)
+	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	for _, line := range lines {
-		if line == "" {
-			continue
+// DownloadContainerFile streams a file out of a container as an attachment
+func DownloadContainerFile(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
 		}
-		parts := strings.Split(line, "|")
-		if len(parts) != 7 {
-			// Skip malformed lines
-			continue
+		path := c.Query("path")
+		if path == "" {
+			apierror.AbortField(c, apierror.InvalidRequest, "path", apierror.T(c, "path_required"))
+			return
+		}
+		path, ok = cleanContainerPath(c, "path", path)
+		if !ok {
+			return
 		}
 
-		createdAt, err := time.Parse(time.RFC3339, parts[6]) // Assuming CreatedAt is in RFC3339 format
-		if err != nil {
-			createdAt = time.Now() // Fallback to current time if parsing fails
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
 		}
 
-		// Parse ports string
-		ports := []string{}
-		if parts[5] != "" {
-			// Example: "0.0.0.0:80->80/tcp, 0.0.0.0:443->443/tcp"
-			portMappings := strings.Split(parts[5], ", ")
-			for _, pm := range portMappings {
-				ports = append(ports, strings.TrimSpace(pm))
-			}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
 		}
 
-		containers = append(containers, model.Container{
-			ID:         parts[0],
-			ServerID:   serverID,
-			Name:       parts[1],
-			Image:      parts[2],
-			Status:     parts[3],
-			State:      parts[4],
-			Ports:      ports,
-			CreatedAt:  createdAt,
-			UserID:     userID,  // Assign current user as owner for now, refine with actual Docker labels if available
-			Permission: "admin", // Default permission for now, refine with actual permission logic
+		err := sshClient.CopyContainerFile(c.Request.Context(), containerID, path, func(file model.FileEntry) (io.Writer, error) {
+			c.Header("Content-Type", "application/octet-stream")
+			c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+			// The size is unknown when the file had to be read with cat
+			if file.Size >= 0 {
+				c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+			}
+			c.Status(http.StatusOK)
+			return c.Writer, nil
 		})
+		if err == nil {
+			return
+		}
+		if c.Writer.Written() {
+			logging.L(c).Warn("container file download failed", "server_id", server.ID, "error", err)
+			return
+		}
+		for _, header := range []string{"Content-Type", "Content-Disposition", "Content-Length"} {
+			c.Writer.Header().Del(header)
+		}
+		if errors.Is(err, ssh.ErrNotAFile) {
+			apierror.AbortField(c, apierror.InvalidRequest, "path", apierror.T(c, "path_not_a_file"))
+			return
+		}
+		sshFailed(c, server.ID, "download_container_file", err)
+	}
+}
+
+// groupContainers nests containers under their compose project, in the order of
+// model.ContainerGroupsResponse
+func groupContainers(containers []model.Container) []model.ContainerGroup {
+	byProject := map[string][]model.Container{}
+	var projects []string
+	var standalone []model.Container
+	for _, container := range containers {
+		if container.ComposeProject == "" {
+			standalone = append(standalone, container)
+			continue
+		}
+		if _, ok := byProject[container.ComposeProject]; !ok {
+			projects = append(projects, container.ComposeProject)
+		}
+		byProject[container.ComposeProject] = append(byProject[container.ComposeProject], container)
+	}
+	sort.Strings(projects)
+
+	groups := make([]model.ContainerGroup, 0, len(projects)+1)
+	for _, project := range projects {
+		groups = append(groups, model.ContainerGroup{Project: project, Containers: byProject[project]})
+	}
+	if len(standalone) > 0 {
+		groups = append(groups, model.ContainerGroup{Project: model.StandaloneGroup, Containers: standalone})
 	}
-	return containers
+	return groups
 }
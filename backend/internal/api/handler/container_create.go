@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateContainer creates and starts a container on the server like docker run -d, pulling its
+// image first when the server does not have it. A container that fails to start is removed again.
+func CreateContainer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.ContainerCreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		cfg, ok := containerRunConfig(c, req)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		if !ensurePortsFree(c, server, req.Ports, "") {
+			return
+		}
+		containerID, pulled, ok := startContainer(c, server, cfg)
+		if !ok {
+			return
+		}
+
+		invalidateContainers(server.ID)
+		listenerCache.Delete(listenerCacheKey(server.ID))
+		auditEvent(c, db, server.ID, "created container %s from %s on %s", req.Name, req.Image, server.Name)
+		c.JSON(http.StatusCreated, model.ContainerCreateResult{ContainerID: containerID, Name: req.Name, Pulled: pulled})
+	}
+}
+
+// startContainer creates a container from cfg and starts it, pulling the image first when the
+// server does not have it. A container that fails to start is removed again. It returns the
+// container's ID and whether the image was pulled; on failure the error response has been
+// written.
+func startContainer(c *gin.Context, server *model.Server, cfg dockerapi.RunConfig) (string, bool, bool) {
+	backend, ok := dockerBackend(c, server)
+	if !ok {
+		return "", false, false
+	}
+
+	ctx := c.Request.Context()
+	var pulled bool
+	if _, err := backend.InspectImage(ctx, cfg.Image); err != nil {
+		if !strings.Contains(err.Error(), "No such image") {
+			sshFailed(c, server.ID, "inspect_image", err)
+			return "", false, false
+		}
+		if err := backend.PullImageRef(ctx, cfg.Image, nil); err != nil {
+			sshFailed(c, server.ID, "pull_image", err)
+			return "", false, false
+		}
+		pulled = true
+	}
+	containerID, err := backend.CreateContainer(ctx, cfg)
+	var stateErr *dockerapi.StateError
+	if errors.As(dockerapi.StateConflict(err), &stateErr) {
+		apierror.AbortField(c, apierror.Conflict, "name", stateErr.Message)
+		return "", false, false
+	}
+	if err != nil {
+		sshFailed(c, server.ID, "container_create", err)
+		return "", false, false
+	}
+	if err := backend.ContainerAction(ctx, containerID, "start"); err != nil {
+		if rmErr := backend.ContainerAction(context.WithoutCancel(ctx), containerID, "remove"); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+		sshFailed(c, server.ID, "container_start", err)
+		return "", false, false
+	}
+	return containerID, pulled, true
+}
+
+// containerRunConfig validates a create request and returns the container's configuration,
+// responding with the first invalid field otherwise
+func containerRunConfig(c *gin.Context, req model.ContainerCreateRequest) (dockerapi.RunConfig, bool) {
+	invalid := func(field, message string) (dockerapi.RunConfig, bool) {
+		apierror.AbortField(c, apierror.ValidationFailed, field, message)
+		return dockerapi.RunConfig{}, false
+	}
+	if !ssh.ValidContainerID(req.Name) || len(req.Name) < 2 {
+		return invalid("name", apierror.T(c, "container_name_invalid", req.Name))
+	}
+	if !imageRef.MatchString(req.Image) {
+		return invalid("image", apierror.T(c, "validation_invalid", "image"))
+	}
+	for _, env := range req.Env {
+		name, _, found := strings.Cut(env, "=")
+		if !found || !varName.MatchString(name) || strings.Contains(env, "\n") {
+			return invalid("env", apierror.T(c, "invalid_env", env))
+		}
+	}
+	for _, spec := range req.Ports {
+		if _, err := dockerapi.ParsePortSpecs([]string{spec}); err != nil {
+			return invalid("ports", apierror.T(c, "invalid_port_spec", spec))
+		}
+	}
+	ports, _ := dockerapi.ParsePortSpecs(req.Ports)
+	for _, volume := range req.Volumes {
+		if err := dockerapi.CheckBind(volume); err != nil {
+			return invalid("volumes", apierror.T(c, "invalid_volume_spec", volume))
+		}
+	}
+	cfg := dockerapi.RunConfig{
+		Name:        req.Name,
+		Image:       req.Image,
+		Env:         req.Env,
+		Binds:       req.Volumes,
+		Ports:       ports,
+		NetworkMode: req.Network,
+		Cmd:         req.Command,
+	}
+	if req.RestartPolicy != "" {
+		policy, err := dockerapi.ParseRestartPolicy(req.RestartPolicy)
+		if err != nil {
+			return invalid("restart_policy", apierror.T(c, "restart_policy_invalid", req.RestartPolicy))
+		}
+		cfg.RestartPolicy, cfg.MaxRetries = policy.Name, policy.MaximumRetryCount
+	}
+	if network := strings.TrimPrefix(req.Network, "container:"); req.Network != "" && !ssh.ValidContainerID(network) {
+		return invalid("network", apierror.T(c, "validation_invalid", "network"))
+	}
+	return cfg, true
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxContainerPageSize bounds ?page_size= of container lists
+const maxContainerPageSize = 500
+
+// defaultContainerPageSize is the page size when ?page= is given without ?page_size=
+const defaultContainerPageSize = 50
+
+// containerStates are the states ?state= accepts, as docker reports them
+var containerStates = []string{"created", "restarting", "running", "removing", "paused", "exited", "dead"}
+
+// containerSorts are the orders ?sort= accepts
+var containerSorts = []string{"name", "created", "state"}
+
+// containerQuery is how a container list is filtered, sorted, paged and shaped. The zero value
+// returns the list as it is.
+type containerQuery struct {
+	// groupBy is "project" to nest the containers under their compose project
+	groupBy string
+	state   string
+	// name and image match substrings, ignoring case
+	name, image string
+	// labels are "key" or "key=value", all of which a container must carry
+	labels []string
+	sort   string
+	desc   bool
+	// page counts from 1; a zero pageSize returns all containers
+	page, pageSize int
+}
+
+// parseContainerQuery reads the filter, sort and paging parameters of a container list. On
+// failure the error response has been written.
+func parseContainerQuery(c *gin.Context) (containerQuery, bool) {
+	q := containerQuery{
+		groupBy: c.Query("group_by"),
+		state:   c.Query("state"),
+		name:    strings.ToLower(c.Query("name")),
+		image:   strings.ToLower(c.Query("image")),
+		labels:  c.QueryArray("label"),
+		sort:    c.Query("sort"),
+	}
+	if q.groupBy != "" && q.groupBy != "project" {
+		apierror.AbortField(c, apierror.ValidationFailed, "group_by", apierror.T(c, "validation_invalid", "group_by"))
+		return q, false
+	}
+	if q.state != "" && !slices.Contains(containerStates, q.state) {
+		apierror.AbortField(c, apierror.ValidationFailed, "state", apierror.T(c, "validation_oneof", "state", strings.Join(containerStates, " ")))
+		return q, false
+	}
+	if q.sort != "" && !slices.Contains(containerSorts, q.sort) {
+		apierror.AbortField(c, apierror.ValidationFailed, "sort", apierror.T(c, "validation_oneof", "sort", strings.Join(containerSorts, " ")))
+		return q, false
+	}
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		q.desc = true
+	default:
+		apierror.AbortField(c, apierror.ValidationFailed, "order", apierror.T(c, "validation_oneof", "order", "asc desc"))
+		return q, false
+	}
+
+	for _, p := range []struct {
+		name     string
+		value    *int
+		min, max int
+	}{{"page", &q.page, 1, 0}, {"page_size", &q.pageSize, 1, maxContainerPageSize}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		switch {
+		case err != nil:
+			apierror.AbortField(c, apierror.ValidationFailed, p.name, apierror.T(c, "validation_type", p.name, "integer"))
+			return q, false
+		case n < p.min:
+			apierror.AbortField(c, apierror.ValidationFailed, p.name, apierror.T(c, "validation_min", p.name, strconv.Itoa(p.min)))
+			return q, false
+		case p.max > 0 && n > p.max:
+			apierror.AbortField(c, apierror.ValidationFailed, p.name, apierror.T(c, "validation_max", p.name, strconv.Itoa(p.max)))
+			return q, false
+		}
+		*p.value = n
+	}
+	if q.page > 0 && q.pageSize == 0 {
+		q.pageSize = defaultContainerPageSize
+	}
+	if q.pageSize > 0 && q.page == 0 {
+		q.page = 1
+	}
+	return q, true
+}
+
+// key identifies the query for ETags, so that differently shaped lists never share one
+func (q containerQuery) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%q|%s|%t|%d|%d", q.groupBy, q.state, q.name, q.image, q.labels, q.sort, q.desc, q.page, q.pageSize)
+}
+
+// apply returns the page of containers matching the query, in its order, and how many matched.
+// containers is left unchanged.
+func (q containerQuery) apply(containers []model.Container) ([]model.Container, int) {
+	matched := make([]model.Container, 0, len(containers))
+	for _, container := range containers {
+		if q.matches(container) {
+			matched = append(matched, container)
+		}
+	}
+	if q.sort != "" {
+		slices.SortStableFunc(matched, func(a, b model.Container) int {
+			var cmp int
+			switch q.sort {
+			case "name":
+				cmp = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+			case "created":
+				cmp = a.CreatedAt.Compare(b.CreatedAt)
+			case "state":
+				cmp = strings.Compare(a.State, b.State)
+			}
+			if cmp == 0 {
+				cmp = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+			}
+			if q.desc {
+				return -cmp
+			}
+			return cmp
+		})
+	}
+	total := len(matched)
+	if q.pageSize > 0 {
+		start := min((q.page-1)*q.pageSize, total)
+		matched = matched[start:min(start+q.pageSize, total)]
+	}
+	return matched, total
+}
+
+func (q containerQuery) matches(container model.Container) bool {
+	if q.state != "" && container.State != q.state {
+		return false
+	}
+	if q.name != "" && !strings.Contains(strings.ToLower(container.Name), q.name) {
+		return false
+	}
+	if q.image != "" && !strings.Contains(strings.ToLower(container.Image), q.image) {
+		return false
+	}
+	for _, label := range q.labels {
+		key, value, hasValue := strings.Cut(label, "=")
+		v, ok := container.Labels[key]
+		if !ok || hasValue && v != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxStatsSamples and maxStatsInterval bound GetContainerStats' series, so that it is read within
+// the SSH timeout; a docker stats reading itself takes about a second
+const (
+	maxStatsSamples  = 10
+	maxStatsInterval = 1000
+)
+
+// Cache for container resource usage, holding one containerSnapshot per server with the usage
+// merged in. Usage goes stale within seconds, so it expires much sooner than containerCache.
+var containerStatsCache = cache.New("container_stats", model.ConfigKeyContainerStatsTTL, 15*time.Second)
+
+// ListContainerStats handles fetching the containers of a server with the CPU, memory, network
+// and block I/O usage of the running ones
+func ListContainerStats(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverID, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
+		access, ok := checkAccess(c, db, serverID, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		server, ok := loadServer(c, db, serverID)
+		if !ok {
+			return
+		}
+		userID, _, _ := currentUser(c)
+		cacheKey := containerStatsCacheKey(server.ID)
+
+		if !forceServerRefresh(c, server.ID) {
+			if cached, found := containerStatsCache.Get(cacheKey); found {
+				if snapshot, ok := cached.(containerSnapshot); ok {
+					snapshot.write(c, userID, access, containerQuery{}, true)
+					return
+				}
+			}
+		}
+
+		list, ok := containerSnapshotFor(c, server)
+		if !ok {
+			return
+		}
+		backend, ok := dockerBackend(c, server)
+		if !ok {
+			return
+		}
+		usage, err := backend.ContainerStats(c.Request.Context())
+		if err != nil {
+			sshFailed(c, server.ID, "container_stats", err)
+			return
+		}
+
+		snapshot, err := newContainerSnapshot(mergeContainerStats(list.containers, usage))
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+		containerStatsCache.Set(cacheKey, snapshot)
+		snapshot.write(c, userID, access, containerQuery{}, false)
+	}
+}
+
+// GetContainerStats returns a reading of a container's resource usage, or with ?samples= that
+// many readings ?interval= milliseconds apart. Readings are always taken, never cached.
+func GetContainerStats(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		samples, interval := 1, maxStatsInterval
+		if v := c.Query("samples"); v != "" {
+			n, err := strconv.Atoi(v)
+			switch {
+			case err != nil:
+				apierror.AbortField(c, apierror.ValidationFailed, "samples", apierror.T(c, "validation_type", "samples", "integer"))
+				return
+			case n < 1:
+				apierror.AbortField(c, apierror.ValidationFailed, "samples", apierror.T(c, "validation_min", "samples", "1"))
+				return
+			case n > maxStatsSamples:
+				apierror.AbortField(c, apierror.ValidationFailed, "samples", apierror.T(c, "validation_max", "samples", strconv.Itoa(maxStatsSamples)))
+				return
+			}
+			samples = n
+		}
+		if v := c.Query("interval"); v != "" {
+			n, err := strconv.Atoi(v)
+			switch {
+			case err != nil:
+				apierror.AbortField(c, apierror.ValidationFailed, "interval", apierror.T(c, "validation_type", "interval", "integer"))
+				return
+			case n < 0:
+				apierror.AbortField(c, apierror.ValidationFailed, "interval", apierror.T(c, "validation_min", "interval", "0"))
+				return
+			case n > maxStatsInterval:
+				apierror.AbortField(c, apierror.ValidationFailed, "interval", apierror.T(c, "validation_max", "interval", strconv.Itoa(maxStatsInterval)))
+				return
+			}
+			interval = n
+		}
+
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		backend, ok := dockerBackend(c, server)
+		if !ok {
+			return
+		}
+		readings, err := backend.ContainerSamples(c.Request.Context(), containerID, samples, time.Duration(interval)*time.Millisecond)
+		if err != nil {
+			sshFailed(c, server.ID, "container_stats", err)
+			return
+		}
+		if len(readings) == 0 {
+			apierror.Abort(c, apierror.NotFound)
+			return
+		}
+
+		stats := model.ContainerStats{ContainerID: containerID}
+		for _, r := range readings {
+			stats.Samples = append(stats.Samples, model.ContainerStatsSample{
+				Time:       r.Time.UTC(),
+				CPUPercent: r.CPUPercent,
+				MemUsage:   r.MemUsage,
+				MemLimit:   r.MemLimit,
+				NetIO:      r.NetIO,
+				BlockIO:    r.BlockIO,
+			})
+		}
+		stats.ContainerStatsSample = stats.Samples[len(stats.Samples)-1]
+		if samples == 1 {
+			stats.Samples = nil
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// mergeContainerStats returns a copy of containers with the usage of the ones in usage filled in.
+// Containers that stopped between the two readings have no usage and are left as they are, and
+// ones started in between are not in the list until it is refreshed.
+func mergeContainerStats(containers []model.Container, usage map[string]dockerapi.Usage) []model.Container {
+	merged := make([]model.Container, len(containers))
+	for i, container := range containers {
+		if u, ok := usage[dockerapi.ShortID(container.ID)]; ok {
+			container.CPUPercent = u.CPUPercent
+			container.MemUsage, container.MemLimit = u.MemUsage, u.MemLimit
+			container.NetIO, container.BlockIO = u.NetIO, u.BlockIO
+		}
+		merged[i] = container
+	}
+	return merged
+}
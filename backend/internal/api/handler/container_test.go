@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"docker-pulse/internal/model"
+)
+
+func TestContainerActionStaysOnThePathServer(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	alice := seedUser(t, db, "alice", "user")
+	web := seedServer(t, db, "web")
+	seedServer(t, db, "db")
+	grant(t, db, alice, web, model.AccessLevelFull)
+
+	r := newTestRouter(db)
+	r.POST("/servers/:id/containers/action", ContainerAction(db))
+
+	for _, tc := range []struct {
+		name, user, path, body string
+		want                   int
+	}{
+		// Alice may act on web, so she tries to reach db through web's path
+		{"body names another server", "alice", "/servers/1/containers/action", `{"server_id":2,"container_id":"abc123","action":"remove"}`, http.StatusBadRequest},
+		// and then through db's path with web in the body, which access would have been checked for
+		{"path names a server without access", "alice", "/servers/2/containers/action", `{"server_id":1,"container_id":"abc123","action":"remove"}`, http.StatusBadRequest},
+		{"path alone names a server without access", "alice", "/servers/2/containers/action", `{"container_id":"abc123","action":"restart"}`, http.StatusForbidden},
+		{"matching body on a server without access", "alice", "/servers/2/containers/action", `{"server_id":2,"container_id":"abc123","action":"restart"}`, http.StatusForbidden},
+		// Admins may act on every server, yet the body still has to agree with the path
+		{"admin with another server in the body", "admin", "/servers/1/containers/action", `{"server_id":2,"container_id":"abc123","action":"stop"}`, http.StatusBadRequest},
+		{"unknown server", "admin", "/servers/9/containers/action", `{"container_id":"abc123","action":"stop"}`, http.StatusNotFound},
+	} {
+		w := requestBody(r, http.MethodPost, tc.path, tc.user, tc.body, nil)
+		if w.Code != tc.want {
+			t.Errorf("%s: %s %s as %s = %d %s, want %d", tc.name, tc.path, tc.body, tc.user, w.Code, w.Body.String(), tc.want)
+		}
+		if tc.want == http.StatusBadRequest && !strings.Contains(w.Body.String(), "server_id") {
+			t.Errorf("%s: error %s does not name server_id", tc.name, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/daemonconfig"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// dockerRestartWait bounds how long docker may take to answer again after a restart
+const dockerRestartWait = 30 * time.Second
+
+// rollbackTimeout bounds restoring the previous daemon configuration and restarting docker with it
+const rollbackTimeout = 3 * time.Minute
+
+// GetDaemonConfig returns a server's /etc/docker/daemon.json
+func GetDaemonConfig(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverID, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
+		server, ok := loadServer(c, db, serverID)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		content, exists, err := sshClient.ReadDaemonConfig(c.Request.Context())
+		if err != nil {
+			sshFailed(c, server.ID, "read_daemon_config", err)
+			return
+		}
+
+		resp := model.DaemonConfig{Path: ssh.DaemonConfigPath, Exists: exists, Config: map[string]interface{}{}, Raw: content}
+		if exists {
+			if err := json.Unmarshal([]byte(content), &resp.Config); err != nil {
+				resp.Config = map[string]interface{}{}
+				resp.ParseError = err.Error()
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// UpdateDaemonConfig validates and writes a server's daemon.json after backing up the current
+// one. With restart set, docker is restarted and checked with docker info; when it does not come
+// back the backup is restored and docker restarted again.
+func UpdateDaemonConfig(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverID, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
+		server, ok := loadServer(c, db, serverID)
+		if !ok {
+			return
+		}
+		var input model.DaemonConfigUpdate
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if keyErr := daemonconfig.Validate(input.Config); keyErr != nil {
+			msg := apierror.T(c, "daemon_option_unknown", keyErr.Key)
+			if keyErr.Kind != "" {
+				msg = apierror.T(c, "daemon_option_type", keyErr.Key, keyErr.Kind)
+			}
+			apierror.AbortField(c, apierror.ValidationFailed, "config", msg)
+			return
+		}
+		if input.Restart && input.Confirm != server.Name {
+			apierror.AbortField(c, apierror.ValidationFailed, "confirm", apierror.T(c, "daemon_restart_confirm", server.Name))
+			return
+		}
+		content, err := daemonconfig.Encode(input.Config)
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		backup, err := sshClient.WriteDaemonConfig(c.Request.Context(), content)
+		if err != nil {
+			sshFailed(c, server.ID, "write_daemon_config", err)
+			return
+		}
+		auditEvent(c, db, server.ID, "updated the docker daemon configuration of %s", server.Name)
+		result := model.DaemonConfigResult{Path: ssh.DaemonConfigPath, Backup: backup}
+		if !input.Restart {
+			c.JSON(http.StatusOK, result)
+			return
+		}
+
+		invalidateContainers(server.ID)
+		version, err := restartDocker(c.Request.Context(), sshClient)
+		if err == nil {
+			auditEvent(c, db, server.ID, "restarted docker on %s", server.Name)
+			result.Restarted = true
+			result.DockerVersion = version
+			c.JSON(http.StatusOK, result)
+			return
+		}
+
+		logging.L(c).Warn("docker did not come back after a daemon config change, restoring the backup", "server_id", server.ID, "error", err)
+		// The rollback has to finish even when the request has timed out or was cancelled
+		rollback, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), rollbackTimeout)
+		defer cancel()
+		if rerr := sshClient.RestoreDaemonConfig(rollback, backup); rerr != nil {
+			logging.L(c).Error("failed to restore the daemon config", "server_id", server.ID, "error", rerr)
+			auditEvent(c, db, server.ID, "restarting docker on %s failed and the previous daemon configuration could not be restored", server.Name)
+			apierror.AbortMessage(c, apierror.SSHCommandFailed, apierror.T(c, "daemon_restore_failed"))
+			return
+		}
+		if _, rerr := restartDocker(rollback, sshClient); rerr != nil {
+			logging.L(c).Error("docker did not come back with the restored daemon config", "server_id", server.ID, "error", rerr)
+			auditEvent(c, db, server.ID, "restarting docker on %s failed, restored the previous daemon configuration but docker is still down", server.Name)
+			apierror.AbortMessage(c, apierror.SSHCommandFailed, apierror.T(c, "daemon_restored_down"))
+			return
+		}
+		auditEvent(c, db, server.ID, "restarting docker on %s failed, restored the previous daemon configuration", server.Name)
+		apierror.AbortMessage(c, apierror.SSHCommandFailed, apierror.T(c, "daemon_restored"))
+	}
+}
+
+// restartDocker restarts the docker service and waits for it to answer docker info
+func restartDocker(ctx context.Context, client *ssh.SSHClient) (string, error) {
+	if err := client.RestartDocker(ctx); err != nil {
+		return "", err
+	}
+	return client.WaitForDocker(ctx, dockerRestartWait)
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"docker-pulse/internal/api/middleware"
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/metrics"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCacheStats reports entry counts, hit/miss counters and TTLs of every cache
+func GetCacheStats() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		all := cache.All()
+		stats := make([]cache.Stats, 0, len(all))
+		for _, ch := range all {
+			stats = append(stats, ch.Stats())
+		}
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// RequestStats summarizes the requests of one route
+type RequestStats struct {
+	Method string  `json:"method"`
+	Route  string  `json:"route"`
+	Count  uint64  `json:"count"`
+	Errors uint64  `json:"errors"` // responses with a 5xx status
+	AvgMs  float64 `json:"avg_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+}
+
+// SSHStats summarizes one SSH operation against one server
+type SSHStats struct {
+	Operation string  `json:"operation"`
+	ServerID  uint    `json:"server_id"`
+	Count     uint64  `json:"count"`
+	Errors    uint64  `json:"errors"`
+	AvgMs     float64 `json:"avg_ms"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+}
+
+// CacheEffectiveness adds the hit ratio to a cache's counters
+type CacheEffectiveness struct {
+	cache.Stats
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// RuntimeStats is a human readable digest of the metrics served on /metrics
+type RuntimeStats struct {
+	Requests []RequestStats       `json:"requests"`
+	SSH      []SSHStats           `json:"ssh"`
+	Caches   []CacheEffectiveness `json:"caches"`
+	Panics   int64                `json:"panics"`
+}
+
+// GetRuntimeStats reports request latency per route, SSH operation latency per server and cache hit ratios
+func GetRuntimeStats() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats := RuntimeStats{
+			Requests: []RequestStats{},
+			SSH:      []SSHStats{},
+			Caches:   []CacheEffectiveness{},
+			Panics:   middleware.Panics(),
+		}
+
+		requestErrors := map[[2]string]uint64{}
+		for _, s := range middleware.Requests.Samples() {
+			if strings.HasPrefix(s.Labels[2], "5") {
+				requestErrors[[2]string{s.Labels[0], s.Labels[1]}] += uint64(s.Value)
+			}
+		}
+		for _, s := range middleware.RequestDuration.Summaries() {
+			stats.Requests = append(stats.Requests, RequestStats{
+				Method: s.Labels[0],
+				Route:  s.Labels[1],
+				Count:  s.Count,
+				Errors: requestErrors[[2]string{s.Labels[0], s.Labels[1]}],
+				AvgMs:  avgMs(s),
+				P50Ms:  s.P50 * 1000,
+				P95Ms:  s.P95 * 1000,
+			})
+		}
+
+		sshErrors := map[[2]string]uint64{}
+		for _, s := range ssh.Operations.Samples() {
+			if s.Labels[2] == "error" {
+				sshErrors[[2]string{s.Labels[0], s.Labels[1]}] += uint64(s.Value)
+			}
+		}
+		for _, s := range ssh.OperationDuration.Summaries() {
+			serverID, _ := strconv.ParseUint(s.Labels[1], 10, 32)
+			stats.SSH = append(stats.SSH, SSHStats{
+				Operation: s.Labels[0],
+				ServerID:  uint(serverID),
+				Count:     s.Count,
+				Errors:    sshErrors[[2]string{s.Labels[0], s.Labels[1]}],
+				AvgMs:     avgMs(s),
+				P50Ms:     s.P50 * 1000,
+				P95Ms:     s.P95 * 1000,
+			})
+		}
+
+		for _, ch := range cache.All() {
+			cs := ch.Stats()
+			e := CacheEffectiveness{Stats: cs}
+			if lookups := cs.Hits + cs.Misses; lookups > 0 {
+				e.HitRatio = float64(cs.Hits) / float64(lookups)
+			}
+			stats.Caches = append(stats.Caches, e)
+		}
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+func avgMs(s metrics.Summary) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count) * 1000
+}
+
+// FlushCaches empties the caches listed in the comma separated "names" query parameter, or all caches when omitted
+func FlushCaches() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var targets []*cache.Cache
+		if names := c.Query("names"); names != "" {
+			for _, name := range strings.Split(names, ",") {
+				ch, ok := cache.Lookup(strings.TrimSpace(name))
+				if !ok {
+					apierror.AbortField(c, apierror.InvalidRequest, "names", apierror.T(c, "unknown_cache", name))
+					return
+				}
+				targets = append(targets, ch)
+			}
+		} else {
+			targets = cache.All()
+		}
+
+		flushed := make([]string, 0, len(targets))
+		for _, ch := range targets {
+			ch.Flush()
+			flushed = append(flushed, ch.Name())
+		}
+		c.JSON(http.StatusOK, gin.H{"flushed": flushed})
+	}
+}
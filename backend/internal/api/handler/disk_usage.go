@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetDockerDiskUsage reports the space docker's images, containers, volumes and build cache
+// take on a server. With ?verbose=true it also lists images and volumes, largest first.
+func GetDockerDiskUsage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		usage, err := sshClient.GetDiskUsage(c.Request.Context(), c.Query("verbose") == "true")
+		if err != nil {
+			sshFailed(c, server.ID, "disk_usage", err)
+			return
+		}
+		sort.SliceStable(usage.Images, func(i, j int) bool {
+			return usage.Images[i].SizeBytes > usage.Images[j].SizeBytes
+		})
+		sort.SliceStable(usage.Volumes, func(i, j int) bool {
+			return usage.Volumes[i].SizeBytes > usage.Volumes[j].SizeBytes
+		})
+		c.JSON(http.StatusOK, usage)
+	}
+}
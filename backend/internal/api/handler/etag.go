@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonEntry is a marshalled response body stored in a cache together with its ETag, so the
+// hash is computed once per cache fill instead of once per request
+type jsonEntry struct {
+	body      []byte
+	etag      string
+	fetchedAt time.Time
+}
+
+func newJSONEntry(v interface{}) (jsonEntry, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return jsonEntry{}, err
+	}
+	return jsonEntry{body: body, etag: hashETag(body), fetchedAt: time.Now()}, nil
+}
+
+// hashETag derives a strong ETag from the given parts
+func hashETag(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// cachedEntry returns the jsonEntry stored under key, if any
+func cachedEntry(ch *cache.Cache, key string) (jsonEntry, bool) {
+	v, found := ch.Get(key)
+	if !found {
+		return jsonEntry{}, false
+	}
+	entry, ok := v.(jsonEntry)
+	return entry, ok
+}
+
+// write sends the body with its ETag, or 304 Not Modified when the client already has it
+func (e jsonEntry) write(c *gin.Context, cached bool) {
+	writeWithETag(c, e.etag, e.fetchedAt, cached, func() ([]byte, error) { return e.body, nil })
+}
+
+// writeWithETag answers If-None-Match hits with 304 and otherwise sends the JSON produced by body,
+// which is only called when needed. X-Cached and X-Fetched-At tell the client whether the data
+// came from the cache and how old it is.
+func writeWithETag(c *gin.Context, etag string, fetchedAt time.Time, cached bool, body func() ([]byte, error)) {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("X-Cached", strconv.FormatBool(cached))
+	c.Header("X-Fetched-At", fetchedAt.UTC().Format(time.RFC3339))
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	data, err := body()
+	if err != nil {
+		apierror.AbortCause(c, apierror.Internal, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// etagMatches implements the weak comparison of If-None-Match against an ETag
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshInterval is the minimum time between two forced refreshes under the same key
+const refreshInterval = 10 * time.Second
+
+var refreshes = struct {
+	sync.Mutex
+	last map[string]time.Time
+}{last: map[string]time.Time{}}
+
+// forceRefresh reports whether the request asked for ?refresh=true and may bypass the cache
+// entries limited by key. Forced refreshes are limited to one per refreshInterval per key so that
+// repeated refreshes cannot flood the servers or registries; later ones are served from the cache.
+func forceRefresh(c *gin.Context, key string) bool {
+	if c.Query("refresh") != "true" {
+		return false
+	}
+	refreshes.Lock()
+	defer refreshes.Unlock()
+	now := time.Now()
+	if last, ok := refreshes.last[key]; ok && now.Sub(last) < refreshInterval {
+		return false
+	}
+	for k, t := range refreshes.last {
+		if now.Sub(t) >= refreshInterval {
+			delete(refreshes.last, k)
+		}
+	}
+	refreshes.last[key] = now
+	return true
+}
+
+// forceServerRefresh is forceRefresh for data read from a server. The limit is shared by
+// everything cached about the server, so that refreshing its details, container list and
+// resource usage in turn still runs at most one forced SSH listing per refreshInterval.
+func forceServerRefresh(c *gin.Context, serverID uint) bool {
+	return forceRefresh(c, "server:"+strconv.FormatUint(uint64(serverID), 10))
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"docker-pulse/internal/model"
+)
+
+func TestContainerListETag(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	server := seedServer(t, db, "web")
+	primeContainers(t, server, model.Container{ID: "abc123", Name: "nginx", State: "running"})
+
+	r := newTestRouter(db)
+	r.GET("/servers/:id/containers", ListContainers(db))
+
+	w := request(r, http.MethodGet, "/servers/1/containers", "admin", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first request = %d with ETag %q, want 200 with an ETag", w.Code, etag)
+	}
+
+	w = request(r, http.MethodGet, "/servers/1/containers", "admin", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("revalidation = %d with %d bytes, want 304 without a body", w.Code, w.Body.Len())
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Fatalf("304 ETag = %q, want %q", got, etag)
+	}
+
+	// A container action drops the list and the next listing finds another state
+	invalidateContainers(server.ID)
+	primeContainers(t, server, model.Container{ID: "abc123", Name: "nginx", State: "exited"})
+	w = request(r, http.MethodGet, "/servers/1/containers", "admin", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("after the change = %d, want 200", w.Code)
+	}
+	var list model.ContainerListResponse
+	decode(t, w, &list)
+	if got := w.Header().Get("ETag"); got == "" || got == etag {
+		t.Fatalf("ETag after the change = %q, want a new one", got)
+	}
+	if len(list.Containers) != 1 || list.Containers[0].State != "exited" {
+		t.Fatalf("containers = %+v, want the exited nginx", list.Containers)
+	}
+
+	// Differently shaped lists of the same data never share an ETag
+	current := w.Header().Get("ETag")
+	w = request(r, http.MethodGet, "/servers/1/containers?state=running", "admin", http.Header{"If-None-Match": {current}})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == current {
+		t.Fatalf("filtered list = %d with ETag %q, want 200 with its own", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestServerListETag(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	seedServer(t, db, "web")
+
+	r := newTestRouter(db)
+	r.GET("/servers", ListServers(db))
+	r.PUT("/servers/:id", UpdateServer(db))
+
+	w := request(r, http.MethodGet, "/servers", "admin", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first request = %d with ETag %q, want 200 with an ETag", w.Code, etag)
+	}
+	if w = request(r, http.MethodGet, "/servers", "admin", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Fatalf("revalidation = %d, want 304", w.Code)
+	}
+
+	if w = requestBody(r, http.MethodPut, "/servers/1", "admin", `{"description":"front end"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("update = %d %s, want 200", w.Code, w.Body.String())
+	}
+	w = request(r, http.MethodGet, "/servers", "admin", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("after the update = %d with ETag %q, want 200 with a new ETag", w.Code, w.Header().Get("ETag"))
+	}
+	var servers []model.Server
+	decode(t, w, &servers)
+	if len(servers) != 1 || servers[0].Description != "front end" {
+		t.Fatalf("servers = %+v, want web with its new description", servers)
+	}
+}
+
+func TestETagDoesNotBypassPermissions(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	seedUser(t, db, "bob", "user")
+	server := seedServer(t, db, "web")
+	primeContainers(t, server, model.Container{ID: "abc123", Name: "nginx"})
+
+	r := newTestRouter(db)
+	r.GET("/servers/:id", GetServer(db))
+	r.GET("/servers/:id/containers", ListContainers(db))
+
+	for _, path := range []string{"/servers/1", "/servers/1/containers"} {
+		etag := request(r, http.MethodGet, path, "admin", nil).Header().Get("ETag")
+		// A user without access who replays the ETag, or any ETag, is still refused
+		for _, inm := range []string{etag, "*"} {
+			w := request(r, http.MethodGet, path, "bob", http.Header{"If-None-Match": {inm}})
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("%s with If-None-Match %s as bob = %d, want 403", path, inm, w.Code)
+			}
+		}
+	}
+}
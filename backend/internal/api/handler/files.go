@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// fileMode is what chmod accepts: an octal mode of three or four digits
+var fileMode = regexp.MustCompile(`^[0-7]{3,4}$`)
+
+// ContainerFileOp deletes, renames, creates or changes the mode of a path in a container and
+// responds with the listing of the path's parent directory. Deleting requires full access, the
+// other operations manage access.
+func ContainerFileOp(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		var req model.FileOpRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if req.Path, ok = containerFilePath(c, "path", req.Path); !ok {
+			return
+		}
+		switch req.Op {
+		case model.FileOpRename:
+			if req.NewPath == "" {
+				apierror.AbortField(c, apierror.ValidationFailed, "new_path", apierror.T(c, "validation_required", "new_path"))
+				return
+			}
+			if req.NewPath, ok = containerFilePath(c, "new_path", req.NewPath); !ok {
+				return
+			}
+		case model.FileOpChmod:
+			if !fileMode.MatchString(req.Mode) {
+				apierror.AbortField(c, apierror.ValidationFailed, "mode", apierror.T(c, "file_mode_invalid"))
+				return
+			}
+		}
+
+		level := model.AccessLevelManage
+		if req.Op == model.FileOpDelete {
+			level = model.AccessLevelFull
+		}
+		server, ok := authorizeServerParam(c, db, level)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+
+		err := sshClient.ContainerFileOp(c.Request.Context(), containerID, req)
+		switch {
+		case errors.Is(err, dockerapi.ErrNotRunning):
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "container_not_running", containerID))
+			return
+		case errors.Is(err, ssh.ErrFileNotFound):
+			apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "file_not_found", req.Path))
+			return
+		case errors.Is(err, ssh.ErrFileExists):
+			existing := req.Path
+			if req.Op == model.FileOpRename {
+				existing = req.NewPath
+			}
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "file_exists", existing))
+			return
+		case errors.Is(err, ssh.ErrDirectoryNotEmpty):
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "directory_not_empty", req.Path))
+			return
+		case err != nil:
+			sshFailed(c, server.ID, "file_"+req.Op, err)
+			return
+		}
+		switch req.Op {
+		case model.FileOpDelete:
+			auditEvent(c, db, server.ID, "deleted %s in container %s on %s (recursive: %t)", req.Path, containerID, server.Name, req.Recursive)
+		case model.FileOpRename:
+			auditEvent(c, db, server.ID, "renamed %s to %s in container %s on %s", req.Path, req.NewPath, containerID, server.Name)
+		case model.FileOpChmod:
+			auditEvent(c, db, server.ID, "changed the mode of %s to %s in container %s on %s (recursive: %t)", req.Path, req.Mode, containerID, server.Name, req.Recursive)
+		case model.FileOpMkdir:
+			auditEvent(c, db, server.ID, "created directory %s in container %s on %s", req.Path, containerID, server.Name)
+		}
+
+		dir := path.Dir(req.Path)
+		files, err := sshClient.ListContainerFiles(c.Request.Context(), containerID, dir)
+		if err != nil {
+			sshFailed(c, server.ID, "list_container_files", err)
+			return
+		}
+		c.JSON(http.StatusOK, model.FileListResponse{Path: dir, Files: files})
+	}
+}
+
+// binarySniffLen is how much of a file is searched for NUL bytes, as git does
+const binarySniffLen = 8000
+
+// looksBinary tells whether content is not text: it has a NUL byte near the start or is not
+// valid UTF-8, which a JSON string could not carry unchanged
+func looksBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLen)], 0) >= 0 || !utf8.Valid(content)
+}
+
+// parseFileRange reads the part of a file a content request selects: ?offset= and ?limit= in
+// bytes, or ?head= or ?tail= in lines. limit defaults to maxSize, which also bounds it. The
+// second result tells whether a part was selected at all. On failure the error response has
+// been written.
+func parseFileRange(c *gin.Context, maxSize int64) (ssh.FileRange, bool, bool) {
+	r := ssh.FileRange{Length: maxSize}
+	var given []string
+	for _, p := range []struct {
+		name     string
+		value    *int64
+		min, max int64
+	}{{"offset", &r.Offset, 0, 0}, {"limit", &r.Length, 1, maxSize}, {"head", &r.Head, 1, 0}, {"tail", &r.Tail, 1, 0}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		switch {
+		case err != nil:
+			apierror.AbortField(c, apierror.ValidationFailed, p.name, apierror.T(c, "validation_type", p.name, "integer"))
+			return r, false, false
+		case n < p.min:
+			apierror.AbortField(c, apierror.ValidationFailed, p.name, apierror.T(c, "validation_min", p.name, strconv.FormatInt(p.min, 10)))
+			return r, false, false
+		case p.max > 0 && n > p.max:
+			apierror.AbortField(c, apierror.ValidationFailed, p.name, apierror.T(c, "validation_max", p.name, strconv.FormatInt(p.max, 10)))
+			return r, false, false
+		}
+		*p.value = n
+		given = append(given, p.name)
+	}
+	lines := slices.Contains(given, "head") || slices.Contains(given, "tail")
+	if lines && len(given) > 1 {
+		apierror.AbortField(c, apierror.ValidationFailed, given[len(given)-1], apierror.T(c, "file_range_conflict"))
+		return r, false, false
+	}
+	return r, len(given) > 0, true
+}
+
+// trimRunes cuts the bytes of characters split by the ends of a part of a file read at offset
+// off both ends, so that a text file read in parts is not taken for binary, and returns the
+// part with its new offset. Reading on from the end of the part starts at the next character.
+func trimRunes(content []byte, offset, size int64) ([]byte, int64) {
+	if offset > 0 {
+		for i := 0; i < utf8.UTFMax-1 && len(content) > 0 && !utf8.RuneStart(content[0]); i++ {
+			content = content[1:]
+			offset++
+		}
+	}
+	if offset+int64(len(content)) < size {
+		for i := len(content) - 1; i >= 0 && i >= len(content)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(content[i]) {
+				if !utf8.FullRune(content[i:]) {
+					content = content[:i]
+				}
+				break
+			}
+		}
+	}
+	return content, offset
+}
+
+// containerFilePath cleans a path of a file operation, which must be absolute and below the
+// root. On failure the error response has been written.
+func containerFilePath(c *gin.Context, field, p string) (string, bool) {
+	if !path.IsAbs(p) {
+		apierror.AbortField(c, apierror.ValidationFailed, field, apierror.T(c, "file_path_invalid", field))
+		return "", false
+	}
+	p, ok := cleanContainerPath(c, field, p)
+	if !ok {
+		return "", false
+	}
+	if p == "/" {
+		apierror.AbortField(c, apierror.ValidationFailed, field, apierror.T(c, "file_path_invalid", field))
+		return "", false
+	}
+	return p, true
+}
+
+// cleanContainerPath makes a container path absolute and collapses "." and "..", so that ".."
+// never climbs above the root. Control characters such as line breaks are refused, as the
+// listings are read line by line. On failure the error response has been written.
+func cleanContainerPath(c *gin.Context, field, p string) (string, bool) {
+	if strings.ContainsFunc(p, unicode.IsControl) || !utf8.ValidString(p) {
+		apierror.AbortField(c, apierror.ValidationFailed, field, apierror.T(c, "validation_invalid", field))
+		return "", false
+	}
+	return path.Clean("/" + p), true
+}
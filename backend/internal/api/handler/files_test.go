@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestContainerPathsStayBelowTheRoot(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	r := newTestRouter(db)
+	r.GET("/clean", func(c *gin.Context) {
+		if p, ok := cleanContainerPath(c, "path", c.Query("path")); ok {
+			c.String(http.StatusOK, p)
+		}
+	})
+
+	for p, want := range map[string]string{
+		"":                          "/",
+		"/":                         "/",
+		"etc":                       "/etc",
+		"../../etc/passwd":          "/etc/passwd",
+		"/var/../../../etc/shadow":  "/etc/shadow",
+		"/app//./data/":             "/app/data",
+		"/app/with space/ünïcödé":   "/app/with space/ünïcödé",
+		"/app/$(touch pwned);`id`'": "/app/$(touch pwned);`id`'",
+	} {
+		w := request(r, http.MethodGet, "/clean?path="+url.QueryEscape(p), "admin", nil)
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("path %q = %d %q, want %q", p, w.Code, w.Body.String(), want)
+		}
+	}
+
+	// Line breaks would end a line of the listing early, and a name with them could not be told
+	// apart from the entries that follow
+	for _, p := range []string{"/app\n/etc", "/app\r", "/app\x00/etc", "/app\x1b[2J", "/app/\xff"} {
+		if w := request(r, http.MethodGet, "/clean?path="+url.QueryEscape(p), "admin", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("path %q = %d, want 400", p, w.Code)
+		}
+	}
+}
+
+func TestFileOpsRefuseMaliciousArguments(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	seedServer(t, db, "web")
+	r := newTestRouter(db)
+	r.POST("/servers/:id/containers/:containerID/files/op", ContainerFileOp(db))
+
+	// All of these are refused before the server is connected to
+	for _, tc := range []struct{ container, body string }{
+		{"web;id", `{"op":"mkdir","path":"/tmp/new"}`},
+		{"-it", `{"op":"mkdir","path":"/tmp/new"}`},
+		{"$(id)", `{"op":"mkdir","path":"/tmp/new"}`},
+		{"web", `{"op":"mkdir","path":"tmp/new"}`},
+		{"web", `{"op":"delete","path":"/"}`},
+		{"web", `{"op":"delete","path":"/tmp/.."}`},
+		{"web", `{"op":"delete","path":"/tmp/a\nb"}`},
+		{"web", `{"op":"rename","path":"/tmp/a","new_path":"../b"}`},
+		{"web", `{"op":"rename","path":"/tmp/a","new_path":"/tmp/b\u0000"}`},
+		{"web", `{"op":"chmod","path":"/tmp/a","mode":"755; id"}`},
+	} {
+		w := requestBody(r, http.MethodPost, "/servers/1/containers/"+url.PathEscape(tc.container)+"/files/op", "admin", tc.body, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("container %q %s = %d %s, want 400", tc.container, tc.body, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/migrate"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB returns a migrated sqlite database of its own and empties the package's caches and
+// refresh limits, which outlive a single test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "data.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("database handle: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := migrate.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	resetCaches := func() {
+		for _, c := range cache.All() {
+			c.Flush()
+		}
+		refreshes.Lock()
+		refreshes.last = map[string]time.Time{}
+		refreshes.Unlock()
+	}
+	resetCaches()
+	t.Cleanup(resetCaches)
+	return db
+}
+
+func seedUser(t *testing.T, db *gorm.DB, username, role string) model.User {
+	t.Helper()
+	user := model.User{Username: username, Password: "password", Role: role}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+func seedServer(t *testing.T, db *gorm.DB, name string) model.Server {
+	t.Helper()
+	server := model.Server{Name: name, IP: "10.0.0.1", Port: 22, Username: "root", AuthMode: "password", ConnectionMode: model.ConnectionModeCLI}
+	if err := db.Create(&server).Error; err != nil {
+		t.Fatalf("create server %s: %v", name, err)
+	}
+	return server
+}
+
+func grant(t *testing.T, db *gorm.DB, user model.User, server model.Server, level string) {
+	t.Helper()
+	perm := model.ServerPermission{UserID: user.ID, ServerID: server.ID, AccessLevel: level}
+	if err := db.Create(&perm).Error; err != nil {
+		t.Fatalf("grant %s on %s: %v", level, server.Name, err)
+	}
+}
+
+// asUser stands in for the auth middleware, reading the user from the X-Test-User header
+func asUser(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user model.User
+		if err := db.Where("username = ?", c.GetHeader("X-Test-User")).First(&user).Error; err != nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set("userID", user.ID)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Next()
+	}
+}
+
+// newTestRouter returns a router whose routes run as the user named by X-Test-User
+func newTestRouter(db *gorm.DB) *gin.Engine {
+	r := gin.New()
+	r.Use(asUser(db))
+	return r
+}
+
+// request sends a request as the given user, with the given headers, and returns the response
+func request(r http.Handler, method, path, username string, header http.Header) *httptest.ResponseRecorder {
+	return requestBody(r, method, path, username, "", header)
+}
+
+// requestBody is request with a JSON body
+func requestBody(r http.Handler, method, path, username, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("X-Test-User", username)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+}
+
+// primeContainers stores a container list for the server as if it had just been listed, so
+// that tests never reach for SSH
+func primeContainers(t *testing.T, server model.Server, containers ...model.Container) containerSnapshot {
+	t.Helper()
+	snapshot, err := newContainerSnapshot(containers)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	containerCache.SetFor(containerCacheKey(server.ID), snapshot, time.Hour)
+	return snapshot
+}
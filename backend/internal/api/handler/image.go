@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/registry"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTagsPerPage = 100
+	maxTagsPerPage     = 1000
+)
+
+// Cache for repository tag lists, keyed by registry and repository
+var tagCache = cache.New("image_tags", "", time.Hour)
+
+// tagEntry is a repository's tag list as stored in tagCache
+type tagEntry struct {
+	tags      []registry.Tag
+	fetchedAt time.Time
+}
+
+// CheckContainerImageUpdate compares the repo digests of the image a container runs with the
+// digest its tag points at in the registry. Registry failures respond with an error rather than
+// no update, ?refresh=true asks the registry again instead of using the cached digest.
+func CheckContainerImageUpdate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		imageRef, err := sshClient.GetImageReference(c.Request.Context(), containerID)
+		if err != nil {
+			sshFailed(c, server.ID, "image_reference", err)
+			return
+		}
+		image := imageRef.Image
+		ref, err := registry.ParseReference(image)
+		if err != nil {
+			apierror.AbortMessage(c, apierror.InvalidRequest, apierror.T(c, "image_no_repository", image))
+			return
+		}
+		refresh := forceRefresh(c, "image_digest:"+ref.String()+"@"+imageRef.Platform)
+		resp, err := registry.CheckUpdate(c.Request.Context(), db, server.ID, image, imageRef.Platform, imageRef.RepoDigests, refresh)
+		if errors.Is(err, registry.ErrNotPulled) {
+			apierror.AbortMessage(c, apierror.InvalidRequest, apierror.T(c, "image_not_pulled", image))
+			return
+		}
+		if !registryOK(c, ref, err) {
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// registryOK reports whether a registry request succeeded, otherwise responding with the error
+// matching err
+func registryOK(c *gin.Context, ref registry.Reference, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, registry.ErrUnauthorized):
+		apierror.AbortMessage(c, apierror.RegistryUnauthorized, apierror.T(c, "registry_denied", ref.Registry))
+	case errors.Is(err, registry.ErrNotFound):
+		apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "registry_repo_not_found", ref.String()))
+	case errors.Is(err, registry.ErrRateLimited):
+		apierror.Abort(c, apierror.RegistryRateLimited)
+	default:
+		logging.L(c).Warn("registry request failed", "registry", ref.Registry, "repository", ref.Repository, "error", err)
+		apierror.Abort(c, apierror.RegistryError)
+	}
+	return false
+}
+
+// ListImageTags lists the tags available in the registry for a container's image, marking
+// those that point at the running image. ?page= and ?per_page= page through the list.
+func ListImageTags(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		page, perPage, ok := pagination(c)
+		if !ok {
+			return
+		}
+
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		imageRef, err := sshClient.GetImageReference(c.Request.Context(), containerID)
+		if err != nil {
+			sshFailed(c, server.ID, "image_reference", err)
+			return
+		}
+		image, digests := imageRef.Image, imageRef.RepoDigests
+		ref, err := registry.ParseReference(image)
+		if err != nil {
+			apierror.AbortMessage(c, apierror.InvalidRequest, apierror.T(c, "image_no_repository", image))
+			return
+		}
+
+		key := ref.Registry + "/" + ref.Repository
+		entry, found := tagCache.Get(key)
+		if !found || forceRefresh(c, "image_tags:"+key) {
+			tags, err := registry.ListTags(c.Request.Context(), ref, registry.CredentialsFor(db, server.ID, ref.Registry))
+			if !registryOK(c, ref, err) {
+				return
+			}
+			entry = tagEntry{tags: tags, fetchedAt: time.Now()}
+			tagCache.Set(key, entry)
+		}
+		te := entry.(tagEntry)
+
+		running := map[string]bool{}
+		for _, d := range digests {
+			if _, digest, ok := strings.Cut(d, "@"); ok {
+				running[digest] = true
+			}
+		}
+		resp := model.ImageTagList{
+			Image:          image,
+			Registry:       ref.Registry,
+			Repository:     ref.Repository,
+			Tag:            ref.Tag,
+			RunningDigests: digests,
+			RunningTags:    []string{},
+			Tags:           []model.ImageTag{},
+			Total:          len(te.tags),
+			Page:           page,
+			PerPage:        perPage,
+			FetchedAt:      te.fetchedAt,
+		}
+		if resp.RunningDigests == nil {
+			resp.RunningDigests = []string{}
+		}
+		for i, t := range te.tags {
+			isRunning := t.Digest != "" && running[t.Digest]
+			if isRunning {
+				resp.RunningTags = append(resp.RunningTags, t.Name)
+			}
+			if i >= (page-1)*perPage && i < page*perPage {
+				resp.Tags = append(resp.Tags, model.ImageTag{Name: t.Name, Digest: t.Digest, UpdatedAt: t.UpdatedAt, Running: isRunning})
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// pagination reads ?page= (from 1) and ?per_page=, responding with validation_failed when they
+// are out of range
+func pagination(c *gin.Context) (int, int, bool) {
+	page, perPage := 1, defaultTagsPerPage
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			apierror.AbortField(c, apierror.ValidationFailed, "page", apierror.T(c, "validation_min", "page", "1"))
+			return 0, 0, false
+		}
+		page = n
+	}
+	if v := c.Query("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTagsPerPage {
+			apierror.AbortField(c, apierror.ValidationFailed, "per_page", apierror.T(c, "validation_max", "per_page", strconv.Itoa(maxTagsPerPage)))
+			return 0, 0, false
+		}
+		perPage = n
+	}
+	return page, perPage, true
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"fmt"
+
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const serverEntryCacheKeyPrefix = "server_"
+
+func serverListCacheKey(userID uint) string {
+	return fmt.Sprintf("%s%d", serverCacheKeyPrefix, userID)
+}
+
+func serverEntryCacheKey(serverID uint) string {
+	return fmt.Sprintf("%s%d", serverEntryCacheKeyPrefix, serverID)
+}
+
+func containerCacheKey(serverID uint) string {
+	return fmt.Sprintf("%s%d", containerCacheKeyPrefix, serverID)
+}
+
+func containerStatsCacheKey(serverID uint) string {
+	return fmt.Sprintf("%s%d", containerStatsCacheKeyPrefix, serverID)
+}
+
+// invalidateServer drops the cache entries that can contain the server: the server itself, its
+// container list and the server lists of admins and of users with a permission row for it.
+// Call it before deleting permission rows, since they decide whose lists are affected.
+func invalidateServer(c *gin.Context, db *gorm.DB, serverID uint) {
+	serverCache.Delete(serverEntryCacheKey(serverID))
+	invalidateContainers(serverID)
+	listenerCache.Delete(listenerCacheKey(serverID))
+
+	var userIDs []uint
+	permitted := db.Model(&model.ServerPermission{}).Select("user_id").Where("server_id = ?", serverID)
+	if err := db.Model(&model.User{}).Where("role = ? OR id IN (?)", "admin", permitted).Pluck("id", &userIDs).Error; err != nil {
+		// Serving stale lists is worse than rebuilding all of them
+		logging.L(c).Warn("failed to resolve users to invalidate, flushing server cache", "server_id", serverID, "error", err)
+		serverCache.Flush()
+		return
+	}
+	for _, userID := range userIDs {
+		invalidateServerList(userID)
+	}
+}
+
+// invalidateServerList drops the cached server list of a single user
+func invalidateServerList(userID uint) {
+	serverCache.Delete(serverListCacheKey(userID))
+}
+
+// invalidateContainers drops the cached container list and resource usage of a server
+func invalidateContainers(serverID uint) {
+	containerCache.Delete(containerCacheKey(serverID))
+	containerStatsCache.Delete(containerStatsCacheKey(serverID))
+}
+
+// InvalidateContainers drops the cached container list of a server after changes made outside
+// a request, such as scheduled tasks
+func InvalidateContainers(serverID uint) {
+	invalidateContainers(serverID)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"docker-pulse/internal/model"
+)
+
+func TestServerEditKeepsUnrelatedCacheEntries(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	alice := seedUser(t, db, "alice", "user")
+	bob := seedUser(t, db, "bob", "user")
+	web := seedServer(t, db, "web")
+	dbServer := seedServer(t, db, "db")
+	grant(t, db, alice, web, model.AccessLevelRead)
+	grant(t, db, bob, dbServer, model.AccessLevelRead)
+	primeContainers(t, web, model.Container{ID: "abc123", Name: "nginx"})
+	primeContainers(t, dbServer, model.Container{ID: "def456", Name: "postgres"})
+
+	r := newTestRouter(db)
+	r.GET("/servers", ListServers(db))
+	r.GET("/servers/:id", GetServer(db))
+	r.PUT("/servers/:id", UpdateServer(db))
+
+	// Fill every user's server list and both servers' entries
+	for _, username := range []string{"admin", "alice", "bob"} {
+		if w := request(r, http.MethodGet, "/servers", username, nil); w.Code != http.StatusOK {
+			t.Fatalf("list as %s = %d", username, w.Code)
+		}
+	}
+	for _, path := range []string{"/servers/1", "/servers/2"} {
+		if w := request(r, http.MethodGet, path, "admin", nil); w.Code != http.StatusOK {
+			t.Fatalf("get %s = %d", path, w.Code)
+		}
+	}
+
+	if w := requestBody(r, http.MethodPut, "/servers/1", "admin", `{"name":"web-1"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("update = %d %s", w.Code, w.Body.String())
+	}
+
+	for key, want := range map[string]bool{
+		// Everything that shows the edited server is dropped
+		serverEntryCacheKey(web.ID):  false,
+		serverListCacheKey(1):        false, // admin
+		serverListCacheKey(alice.ID): false,
+		// Entries of users and servers the edit does not touch survive
+		serverListCacheKey(bob.ID):       true,
+		serverEntryCacheKey(dbServer.ID): true,
+	} {
+		if _, found := serverCache.Get(key); found != want {
+			t.Errorf("%s cached = %t after editing web, want %t", key, found, want)
+		}
+	}
+	for key, want := range map[string]bool{containerCacheKey(web.ID): false, containerCacheKey(dbServer.ID): true} {
+		if _, found := containerCache.Get(key); found != want {
+			t.Errorf("%s cached = %t after editing web, want %t", key, found, want)
+		}
+	}
+
+	// Alice sees the new name, Bob's list is still served from the cache
+	var servers []model.Server
+	decode(t, request(r, http.MethodGet, "/servers", "alice", nil), &servers)
+	if len(servers) != 1 || servers[0].Name != "web-1" {
+		t.Fatalf("alice's servers = %+v, want web-1", servers)
+	}
+	if w := request(r, http.MethodGet, "/servers", "bob", nil); w.Header().Get("X-Cached") != "true" {
+		t.Fatalf("bob's list X-Cached = %q, want true", w.Header().Get("X-Cached"))
+	}
+}
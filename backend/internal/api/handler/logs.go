@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/pull"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetLogUsage lists the size of every container's log files on a server, largest first
+func GetLogUsage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		usage, err := sshClient.GetLogUsage(c.Request.Context())
+		if err != nil {
+			sshFailed(c, server.ID, "log_usage", err)
+			return
+		}
+
+		resp := model.LogUsageResponse{Containers: []model.ContainerLogUsage{}}
+		for _, u := range usage {
+			resp.Containers = append(resp.Containers, u)
+			resp.TotalBytes += u.SizeBytes
+		}
+		sort.SliceStable(resp.Containers, func(i, j int) bool {
+			return resp.Containers[i].SizeBytes > resp.Containers[j].SizeBytes
+		})
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// TruncateContainerLog empties a container's log file. It requires full access and the
+// container name or ID from the path repeated in the body.
+func TruncateContainerLog(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		var req model.LogTruncateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if req.Confirm != containerID {
+			apierror.AbortField(c, apierror.ValidationFailed, "confirm", apierror.T(c, "log_truncate_confirm", containerID))
+			return
+		}
+
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		if err := sshClient.TruncateContainerLog(c.Request.Context(), containerID); err != nil {
+			sshFailed(c, server.ID, "truncate_log", err)
+			return
+		}
+		auditEvent(c, db, server.ID, "truncated the log of container %s on %s", containerID, server.Name)
+		c.JSON(http.StatusOK, gin.H{"message": "Container log truncated"})
+	}
+}
+
+// logSize is a --log-opt max-size value, a number of bytes with an optional k, m or g unit
+var logSize = regexp.MustCompile(`^[1-9][0-9]*[kmg]?$`)
+
+// SetContainerLogConfig changes how a container's log is rotated. Docker only sets log options
+// when a container is created, so the container is recreated in the background like an update
+// and the response is the task to follow. It requires full access.
+func SetContainerLogConfig(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		var req model.LogConfigRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if req.MaxSize == "" && req.MaxFile == 0 {
+			apierror.AbortField(c, apierror.ValidationFailed, "max_size", apierror.T(c, "log_config_empty"))
+			return
+		}
+		if req.MaxSize != "" && !logSize.MatchString(req.MaxSize) {
+			apierror.AbortField(c, apierror.ValidationFailed, "max_size", apierror.T(c, "validation_invalid", "max_size"))
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		if !containerExists(c, server, containerID) {
+			return
+		}
+
+		auditEvent(c, db, server.ID, "started changing the log rotation of container %s on %s", containerID, server.Name)
+		job, err := pull.StartLogRotation(*server, containerID, dockerapi.LogRotation{MaxSize: req.MaxSize, MaxFile: req.MaxFile})
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+		go func() {
+			<-job.Done()
+			invalidateContainers(server.ID)
+		}()
+		c.JSON(http.StatusAccepted, model.LogConfigResponse{PullTask: job.Snapshot(0), Warning: apierror.T(c, "log_config_recreate")})
+	}
+}
+
+// SearchContainerLogs searches a container's log with a regular expression and returns the
+// matching lines with the lines around them. The log is read on the panel as it arrives and
+// stops being read once the match limit is reached.
+func SearchContainerLogs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		containerID, ok := containerParam(c)
+		if !ok {
+			return
+		}
+		var req model.LogSearchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		re, ok := logPattern(c, "pattern", req.Pattern, req.IgnoreCase)
+		if !ok {
+			return
+		}
+		opts, ok := parseLogOptions(c, db, req.Tail, req.Since, req.Until)
+		if !ok {
+			return
+		}
+		opts.Timestamps = req.Timestamps
+		maxMatches := config.GetInt(db, model.ConfigKeyLogSearchMaxMatches)
+		if req.MaxMatches > 0 {
+			maxMatches = min(req.MaxMatches, maxMatches)
+		}
+
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		backend, ok := dockerBackend(c, server)
+		if !ok {
+			return
+		}
+		logs, err := backend.ContainerLogs(c.Request.Context(), containerID, opts)
+		if err != nil {
+			sshFailed(c, server.ID, "container_logs", err)
+			return
+		}
+		defer logs.Close()
+
+		resp, err := dockerapi.SearchLogs(logs, re, req.Before, req.After, maxMatches)
+		if err != nil {
+			sshFailed(c, server.ID, "container_logs", err)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
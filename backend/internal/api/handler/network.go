@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListNetworks lists a server's networks with their subnets and the containers attached to them
+func ListNetworks(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		networks, err := sshClient.ListNetworks(c.Request.Context())
+		if err != nil {
+			sshFailed(c, server.ID, "list_networks", err)
+			return
+		}
+		c.JSON(http.StatusOK, model.NetworksResponse{Networks: networks})
+	}
+}
+
+// GetNetwork returns a network with its driver options
+func GetNetwork(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := networkParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		network, err := sshClient.GetNetwork(c.Request.Context(), name)
+		if !networkOK(c, server.ID, "inspect_network", name, "", err) {
+			return
+		}
+		c.JSON(http.StatusOK, network)
+	}
+}
+
+// CreateNetwork creates a bridge network, optionally with its own subnet and gateway. It
+// requires manage access. Docker refusing it, such as for a name that is taken or a subnet
+// that overlaps another network's, is a conflict with docker's message.
+func CreateNetwork(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.NetworkCreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if !dockerapi.ValidNetworkName(req.Name) || dockerapi.IsBuiltInNetwork(req.Name) {
+			apierror.AbortField(c, apierror.ValidationFailed, "name", apierror.T(c, "validation_invalid", "name"))
+			return
+		}
+		if !networkAddressesOK(c, req) {
+			return
+		}
+		for key := range req.Labels {
+			if key == "" || strings.ContainsAny(key, "=\n") {
+				apierror.AbortField(c, apierror.ValidationFailed, "labels", apierror.T(c, "validation_invalid", "labels"))
+				return
+			}
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		network, err := sshClient.CreateNetwork(c.Request.Context(), req)
+		if !networkOK(c, server.ID, "create_network", req.Name, "", err) {
+			return
+		}
+		auditEvent(c, db, server.ID, "created network %s on %s", req.Name, server.Name)
+		c.JSON(http.StatusCreated, network)
+	}
+}
+
+// DeleteNetwork removes a network. It requires full access. The bridge, host and none networks
+// cannot be removed, and networks containers are attached to are refused with a conflict
+// naming the containers.
+func DeleteNetwork(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := networkParam(c)
+		if !ok {
+			return
+		}
+		if dockerapi.IsBuiltInNetwork(name) {
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "network_built_in", name))
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		usedBy, err := sshClient.RemoveNetwork(c.Request.Context(), name)
+		if errors.Is(err, ssh.ErrNetworkInUse) {
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "network_in_use", name, strings.Join(usedBy, ", ")))
+			return
+		}
+		if !networkOK(c, server.ID, "remove_network", name, "", err) {
+			return
+		}
+		auditEvent(c, db, server.ID, "removed network %s on %s", name, server.Name)
+		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("network %s removed", name)})
+	}
+}
+
+// ConnectNetwork attaches a container to a network, optionally at a fixed IPv4 address and
+// with aliases. It requires manage access.
+func ConnectNetwork(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := networkParam(c)
+		if !ok {
+			return
+		}
+		var req model.NetworkConnectRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if !networkContainerOK(c, req.Container) {
+			return
+		}
+		for _, alias := range req.Aliases {
+			if !dockerapi.ValidNetworkName(alias) {
+				apierror.AbortField(c, apierror.ValidationFailed, "aliases", apierror.T(c, "validation_invalid", "aliases"))
+				return
+			}
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		err := sshClient.ConnectNetwork(c.Request.Context(), name, req.Container, req.IPv4Address, req.Aliases)
+		if !networkOK(c, server.ID, "connect_network", name, req.Container, err) {
+			return
+		}
+		invalidateContainers(server.ID)
+		auditEvent(c, db, server.ID, "connected container %s to network %s on %s", req.Container, name, server.Name)
+		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("container %s connected to network %s", req.Container, name)})
+	}
+}
+
+// DisconnectNetwork detaches a container from a network. It requires manage access.
+func DisconnectNetwork(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := networkParam(c)
+		if !ok {
+			return
+		}
+		var req model.NetworkDisconnectRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if !networkContainerOK(c, req.Container) {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		err := sshClient.DisconnectNetwork(c.Request.Context(), name, req.Container, req.Force)
+		if !networkOK(c, server.ID, "disconnect_network", name, req.Container, err) {
+			return
+		}
+		invalidateContainers(server.ID)
+		auditEvent(c, db, server.ID, "disconnected container %s from network %s on %s", req.Container, name, server.Name)
+		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("container %s disconnected from network %s", req.Container, name)})
+	}
+}
+
+// networkAddressesOK checks that the gateway and IP range of a network to create lie in its
+// subnet, which they require. On failure the error response has been written.
+func networkAddressesOK(c *gin.Context, req model.NetworkCreateRequest) bool {
+	var subnet *net.IPNet
+	if req.Subnet != "" {
+		_, subnet, _ = net.ParseCIDR(req.Subnet)
+	}
+	if req.Gateway != "" && (subnet == nil || !subnet.Contains(net.ParseIP(req.Gateway))) {
+		apierror.AbortField(c, apierror.ValidationFailed, "gateway", apierror.T(c, "network_address_outside", "gateway"))
+		return false
+	}
+	if req.IPRange != "" {
+		ip, _, _ := net.ParseCIDR(req.IPRange)
+		if subnet == nil || !subnet.Contains(ip) {
+			apierror.AbortField(c, apierror.ValidationFailed, "ip_range", apierror.T(c, "network_address_outside", "ip_range"))
+			return false
+		}
+	}
+	return true
+}
+
+// networkContainerOK validates the container of a connect or disconnect request. On failure
+// the error response has been written.
+func networkContainerOK(c *gin.Context, container string) bool {
+	if !ssh.ValidContainerID(container) {
+		apierror.AbortField(c, apierror.ValidationFailed, "container", apierror.T(c, "validation_invalid", "container"))
+		return false
+	}
+	return true
+}
+
+// networkOK reports whether a network operation succeeded, otherwise responding with the error
+// matching err. Docker refusing the operation is a conflict with docker's message.
+func networkOK(c *gin.Context, serverID uint, op, network, container string, err error) bool {
+	msg, refused := dockerapi.DaemonMessage(err)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ssh.ErrNetworkNotFound):
+		apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "network_not_found", network))
+	case container != "" && dockerapi.IsNoSuchContainer(err):
+		apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "container_not_found", container))
+	case refused:
+		apierror.AbortMessage(c, apierror.Conflict, msg)
+	default:
+		sshFailed(c, serverID, op, err)
+	}
+	return false
+}
+
+// networkParam validates the ":name" parameter. On failure the error response has been written.
+func networkParam(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	if !dockerapi.ValidNetworkName(name) {
+		apierror.AbortField(c, apierror.ValidationFailed, "name", apierror.T(c, "validation_invalid", "name"))
+		return "", false
+	}
+	return name, true
+}
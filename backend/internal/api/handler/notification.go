@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// NotificationPreferences are the current user's email address and the channels alerts are sent on
+type NotificationPreferences struct {
+	Email    string   `json:"email" binding:"omitempty,email,max=191"`
+	Channels []string `json:"channels"`
+}
+
+// GetNotificationPreferences returns the current user's notification settings
+func GetNotificationPreferences(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _, ok := currentUser(c)
+		if !ok {
+			return
+		}
+		user, ok := loadUser(c, db, userID)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, NotificationPreferences{Email: user.Email, Channels: notify.SplitList(user.NotificationChannels)})
+	}
+}
+
+// UpdateNotificationPreferences stores the current user's email address and channel selection.
+// Selecting no channel turns notifications off.
+func UpdateNotificationPreferences(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input NotificationPreferences
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		userID, _, ok := currentUser(c)
+		if !ok {
+			return
+		}
+		user, ok := loadUser(c, db, userID)
+		if !ok {
+			return
+		}
+
+		channels := []string{}
+		seen := map[string]bool{}
+		for _, ch := range input.Channels {
+			ch = strings.ToLower(strings.TrimSpace(ch))
+			if !notify.ValidChannel(ch) {
+				apierror.AbortField(c, apierror.ValidationFailed, "channels", apierror.T(c, "notification_channel", ch))
+				return
+			}
+			switch {
+			case ch == notify.ChannelEmail && input.Email == "":
+				apierror.AbortField(c, apierror.ValidationFailed, "email", apierror.T(c, "email_channel_requires"))
+				return
+			case ch == notify.ChannelTelegram && user.TelegramID == 0:
+				apierror.AbortField(c, apierror.ValidationFailed, "channels", apierror.T(c, "telegram_channel_requires"))
+				return
+			}
+			if !seen[ch] {
+				seen[ch] = true
+				channels = append(channels, ch)
+			}
+		}
+
+		err := db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"email":                 input.Email,
+			"notification_channels": strings.Join(channels, ","),
+		}).Error
+		if err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, NotificationPreferences{Email: input.Email, Channels: channels})
+	}
+}
+
+// SMTPTest names the recipient of a test email
+type SMTPTest struct {
+	To string `json:"to" binding:"omitempty,email"`
+}
+
+// TestSMTP sends a test email with the stored SMTP settings, to the given address or the
+// current user's. SMTP errors are returned as is so the admin can fix the settings.
+func TestSMTP(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input SMTPTest
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+			apierror.Invalid(c, err)
+			return
+		}
+		if input.To == "" {
+			userID, _, ok := currentUser(c)
+			if !ok {
+				return
+			}
+			user, ok := loadUser(c, db, userID)
+			if !ok {
+				return
+			}
+			input.To = user.Email
+		}
+		if input.To == "" {
+			apierror.AbortField(c, apierror.ValidationFailed, "to", apierror.T(c, "test_recipient_required"))
+			return
+		}
+
+		settings := notify.LoadSMTP(db)
+		if !settings.Configured() {
+			apierror.AbortMessage(c, apierror.NotConfigured, apierror.T(c, "smtp_not_configured"))
+			return
+		}
+		msg, err := notify.Render(notify.Event{Type: notify.Test})
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+		if err := notify.SendEmail(settings, input.To, msg); err != nil {
+			logging.L(c).Warn("test email failed", "to", input.To, "error", err)
+			apierror.AbortMessage(c, apierror.DeliveryFailed, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Test email sent to " + input.To})
+	}
+}
+
+// auditEvent reports a change made by the current admin to webhooks subscribed to admin_action
+func auditEvent(c *gin.Context, db *gorm.DB, serverID uint, format string, args ...interface{}) {
+	notify.Notify(db, notify.Event{
+		Type:     notify.AdminAction,
+		ServerID: serverID,
+		Actor:    c.GetString("username"),
+		Detail:   fmt.Sprintf(format, args...),
+	})
+}
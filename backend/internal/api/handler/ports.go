@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	listenerCacheKeyPrefix = "listeners_server_"
+	// maxPortRange caps how many ports a single range may expand to
+	maxPortRange = 1024
+)
+
+// Cache for the non-docker listeners of a server, refreshed when a ports view or check needs it
+var listenerCache = cache.New("listeners", "", time.Minute)
+
+// ssProcess extracts the first process name from ss's users:(("name",pid=1,fd=3)) column
+var ssProcess = regexp.MustCompile(`users:\(\("([^"]+)"`)
+
+func listenerCacheKey(serverID uint) string {
+	return fmt.Sprintf("%s%d", listenerCacheKeyPrefix, serverID)
+}
+
+// ListPorts returns the host ports in use on a server: ports published by containers, from the
+// cached container list, and other listening sockets reported by ss
+func ListPorts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		resp, ok := serverPorts(c, server)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// CheckPorts answers 409 naming the container or process that holds one of the requested host
+// ports, before anything runs on the server
+func CheckPorts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		var req model.PortCheckRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if !ensurePortsFree(c, server, req.Ports, req.ExcludeContainer) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"available": true})
+	}
+}
+
+// ensurePortsFree checks docker -p style port specs against the ports in use on the server and
+// responds with 409 on the first conflict. Ports of the container named or identified by
+// exclude are ignored. Endpoints that create or recreate containers call it before running
+// anything remotely.
+func ensurePortsFree(c *gin.Context, server *model.Server, specs []string, exclude string) bool {
+	var requested []model.PortBinding
+	for _, spec := range specs {
+		ports, err := parsePortSpec(spec)
+		if err != nil {
+			apierror.AbortField(c, apierror.ValidationFailed, "ports", apierror.T(c, "invalid_port_spec", spec))
+			return false
+		}
+		requested = append(requested, ports...)
+	}
+	if len(requested) == 0 {
+		return true
+	}
+
+	used, ok := serverPorts(c, server)
+	if !ok {
+		return false
+	}
+	for _, r := range requested {
+		for _, u := range used.Ports {
+			if u.Container != "" && exclude != "" && (u.Container == exclude || strings.HasPrefix(exclude, u.Container)) {
+				continue
+			}
+			if u.Port != r.Port || u.Protocol != r.Protocol || !hostIPsOverlap(u.HostIP, r.HostIP) {
+				continue
+			}
+			switch {
+			case u.Container != "":
+				apierror.AbortField(c, apierror.Conflict, "ports", apierror.T(c, "port_in_use_container", r.Port, r.Protocol, u.Container))
+			case u.Process != "":
+				apierror.AbortField(c, apierror.Conflict, "ports", apierror.T(c, "port_in_use_process", r.Port, r.Protocol, u.Process))
+			default:
+				apierror.AbortField(c, apierror.Conflict, "ports", apierror.T(c, "port_in_use", r.Port, r.Protocol))
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// serverPorts builds the ports view of a server. Failing to list listeners is not fatal: the
+// container ports are still returned along with the reason.
+func serverPorts(c *gin.Context, server *model.Server) (model.PortListResponse, bool) {
+	resp := model.PortListResponse{Ports: []model.PortBinding{}}
+	snapshot, ok := containerSnapshotFor(c, server)
+	if !ok {
+		return resp, false
+	}
+	published := map[string]bool{}
+	for _, ct := range snapshot.containers {
+		for _, p := range ct.Ports {
+			for _, b := range parsePublishedPort(p) {
+				b.Container = ct.Name
+				resp.Ports = append(resp.Ports, b)
+				published[fmt.Sprintf("%d/%s", b.Port, b.Protocol)] = true
+			}
+		}
+	}
+
+	listeners, err := serverListeners(c.Request.Context(), server)
+	if err != nil {
+		logging.L(c).Info("failed to list listeners", "server_id", server.ID, "error", err)
+		resp.ListenerError = apierror.T(c, "listeners_unavailable")
+	}
+	for _, l := range listeners {
+		// Published ports also show up as docker-proxy listeners
+		if published[fmt.Sprintf("%d/%s", l.Port, l.Protocol)] {
+			continue
+		}
+		resp.Ports = append(resp.Ports, l)
+	}
+
+	sort.SliceStable(resp.Ports, func(i, j int) bool {
+		if resp.Ports[i].Port != resp.Ports[j].Port {
+			return resp.Ports[i].Port < resp.Ports[j].Port
+		}
+		return resp.Ports[i].Protocol < resp.Ports[j].Protocol
+	})
+	return resp, true
+}
+
+// serverListeners returns the server's listening sockets from the cache or over SSH
+func serverListeners(ctx context.Context, server *model.Server) ([]model.PortBinding, error) {
+	key := listenerCacheKey(server.ID)
+	if cached, found := listenerCache.Get(key); found {
+		if listeners, ok := cached.([]model.PortBinding); ok {
+			return listeners, nil
+		}
+	}
+	client, err := ssh.NewServerClient(server)
+	if err != nil {
+		return nil, err
+	}
+	output, err := client.ListListeners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listeners := parseListeners(output)
+	listenerCache.Set(key, listeners)
+	return listeners, nil
+}
+
+// parseListeners parses "ss -Htulnp" output such as
+// "tcp LISTEN 0 511 0.0.0.0:80 0.0.0.0:* users:(("nginx",pid=812,fd=6))"
+func parseListeners(output string) []model.PortBinding {
+	listeners := []model.PortBinding{}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		proto := fields[0]
+		if proto != "tcp" && proto != "udp" {
+			continue
+		}
+		host, port, ok := splitHostPort(fields[4])
+		if !ok {
+			continue
+		}
+		b := model.PortBinding{HostIP: host, Port: port, Protocol: proto}
+		if m := ssProcess.FindStringSubmatch(line); m != nil {
+			b.Process = m[1]
+		}
+		if b.Process == "docker-proxy" {
+			continue
+		}
+		key := fmt.Sprintf("%s|%d|%s", b.HostIP, b.Port, b.Protocol)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		listeners = append(listeners, b)
+	}
+	return listeners
+}
+
+// parsePublishedPort parses one entry of docker ps's Ports column, e.g. "0.0.0.0:8080->80/tcp",
+// "[::]:8000-8001->8000-8001/tcp". Exposed but unpublished ports ("80/tcp") yield nothing.
+func parsePublishedPort(entry string) []model.PortBinding {
+	hostPart, containerPart, ok := strings.Cut(strings.TrimSpace(entry), "->")
+	if !ok {
+		return nil
+	}
+	containerPort, proto, _ := strings.Cut(containerPart, "/")
+	if proto == "" {
+		proto = "tcp"
+	}
+	host, portRange := "", hostPart
+	if i := strings.LastIndex(hostPart, ":"); i >= 0 {
+		host, portRange = hostPart[:i], hostPart[i+1:]
+	}
+	ports, err := expandPortRange(portRange)
+	if err != nil {
+		return nil
+	}
+	bindings := make([]model.PortBinding, 0, len(ports))
+	for _, p := range ports {
+		bindings = append(bindings, model.PortBinding{HostIP: normalizeHostIP(host), Port: p, Protocol: proto, ContainerPort: containerPort})
+	}
+	return bindings
+}
+
+// parsePortSpec parses a docker -p value: [ip:]hostPort:containerPort[/proto], where both ports
+// may be ranges. A spec without a host port publishes on a random port and yields nothing.
+func parsePortSpec(spec string) ([]model.PortBinding, error) {
+	spec = strings.TrimSpace(spec)
+	mapping, proto, _ := strings.Cut(spec, "/")
+	switch proto {
+	case "":
+		proto = "tcp"
+	case "tcp", "udp", "sctp":
+	default:
+		return nil, fmt.Errorf("unknown protocol %q", proto)
+	}
+
+	i := strings.LastIndex(mapping, ":")
+	if i < 0 {
+		if _, err := expandPortRange(mapping); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	rest := mapping[:i]
+	host, hostPorts := "", rest
+	if j := strings.LastIndex(rest, ":"); j >= 0 {
+		host, hostPorts = rest[:j], rest[j+1:]
+	}
+	if _, err := expandPortRange(mapping[i+1:]); err != nil {
+		return nil, err
+	}
+	if hostPorts == "" {
+		return nil, nil
+	}
+	ports, err := expandPortRange(hostPorts)
+	if err != nil {
+		return nil, err
+	}
+	bindings := make([]model.PortBinding, 0, len(ports))
+	for _, p := range ports {
+		bindings = append(bindings, model.PortBinding{HostIP: normalizeHostIP(host), Port: p, Protocol: proto})
+	}
+	return bindings, nil
+}
+
+// expandPortRange turns "8080" or "8000-8010" into its ports
+func expandPortRange(s string) ([]int, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	from, err := strconv.Atoi(lo)
+	if err != nil || from < 1 || from > 65535 {
+		return nil, fmt.Errorf("invalid port %q", s)
+	}
+	to := from
+	if isRange {
+		if to, err = strconv.Atoi(hi); err != nil || to < from || to > 65535 || to-from >= maxPortRange {
+			return nil, fmt.Errorf("invalid port range %q", s)
+		}
+	}
+	ports := make([]int, 0, to-from+1)
+	for p := from; p <= to; p++ {
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+// splitHostPort splits ss's local address column: "0.0.0.0:22", "*:68", "[::]:80",
+// "127.0.0.53%lo:53"
+func splitHostPort(addr string) (string, int, bool) {
+	i := strings.LastIndex(addr, ":")
+	if i < 0 {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(addr[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	host := addr[:i]
+	if j := strings.Index(host, "%"); j >= 0 {
+		host = host[:j]
+	}
+	return normalizeHostIP(host), port, true
+}
+
+// normalizeHostIP strips brackets and maps every wildcard address to ""
+func normalizeHostIP(host string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	switch host {
+	case "0.0.0.0", "::", "*":
+		return ""
+	}
+	return host
+}
+
+// hostIPsOverlap reports whether two bindings can clash: they do when either listens on all
+// addresses or both name the same address
+func hostIPsOverlap(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// pruneLabel is a label filter, "key" or "key=value"
+var pruneLabel = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*(=.*)?$`)
+
+// PruneDocker removes unused docker objects on a server, or with dry_run lists what would be
+// removed. It requires full access. Each selected kind is pruned in turn, and a kind that fails
+// does not stop the others.
+func PruneDocker(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		var req model.PruneRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		var kinds []string
+		for _, kind := range model.PruneTypes {
+			if slices.Contains(req.Types, kind) {
+				kinds = append(kinds, kind)
+			}
+		}
+		if req.Until != "" {
+			if d, err := time.ParseDuration(req.Until); err != nil || d <= 0 {
+				apierror.AbortField(c, apierror.ValidationFailed, "until", apierror.T(c, "prune_until_invalid", req.Until))
+				return
+			}
+			if slices.Contains(kinds, model.PruneVolumes) {
+				apierror.AbortField(c, apierror.ValidationFailed, "until", apierror.T(c, "prune_until_volumes"))
+				return
+			}
+		}
+		for _, label := range req.Labels {
+			if !pruneLabel.MatchString(label) {
+				apierror.AbortField(c, apierror.ValidationFailed, "labels", apierror.T(c, "prune_label_invalid", label))
+				return
+			}
+		}
+		if len(req.Labels) > 0 && slices.Contains(kinds, model.PruneBuildCache) {
+			apierror.AbortField(c, apierror.ValidationFailed, "labels", apierror.T(c, "prune_labels_build_cache"))
+			return
+		}
+
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		resp := model.PruneResult{DryRun: req.DryRun}
+		if req.DryRun {
+			results, err := sshClient.PruneDryRun(c.Request.Context(), kinds, req)
+			if err != nil {
+				sshFailed(c, server.ID, "prune_dry_run", err)
+				return
+			}
+			resp.Types = results
+		} else {
+			for _, kind := range kinds {
+				result, err := sshClient.Prune(c.Request.Context(), kind, req)
+				if err != nil {
+					logging.L(c).Warn("prune failed", "server_id", server.ID, "type", kind, "error", err)
+					result = model.PruneTypeResult{Type: kind, Items: []model.PruneItem{}, Error: err.Error()}
+				}
+				resp.Types = append(resp.Types, result)
+			}
+		}
+		for _, result := range resp.Types {
+			resp.ReclaimedBytes += result.ReclaimedBytes
+		}
+
+		if !req.DryRun {
+			invalidateContainers(server.ID)
+			auditEvent(c, db, server.ID, "pruned docker %s on %s, reclaiming %s",
+				strings.Join(kinds, ", "), server.Name, dockerapi.HumanSize(uint64(resp.ReclaimedBytes)))
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
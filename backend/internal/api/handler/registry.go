@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/registry"
+	"docker-pulse/internal/secretbox"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RegistryCredentialInput adds or updates a registry login. The password is required when
+// adding one; a missing password keeps the stored one on updates. No server applies the login
+// to every server.
+type RegistryCredentialInput struct {
+	Registry string  `json:"registry" binding:"required,max=191"`
+	Username string  `json:"username" binding:"required,max=191"`
+	Password *string `json:"password"`
+	ServerID *uint   `json:"server_id"`
+}
+
+// ListRegistryCredentials returns every stored registry login without its password
+func ListRegistryCredentials(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		creds := []model.RegistryCredential{}
+		if err := db.Order("registry, id").Find(&creds).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, creds)
+	}
+}
+
+// CreateRegistryCredential stores a registry login, encrypted with the master key
+func CreateRegistryCredential(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var cred model.RegistryCredential
+		if !bindRegistryCredential(c, db, &cred) {
+			return
+		}
+		if err := db.Create(&cred).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, serverIDOf(cred), "added registry login %s@%s", cred.Username, cred.Registry)
+		c.JSON(http.StatusCreated, cred)
+	}
+}
+
+// UpdateRegistryCredential replaces a registry login
+func UpdateRegistryCredential(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cred, ok := loadRegistryCredential(c, db)
+		if !ok {
+			return
+		}
+		if !bindRegistryCredential(c, db, cred) {
+			return
+		}
+		if err := db.Save(cred).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, serverIDOf(*cred), "updated registry login %s@%s", cred.Username, cred.Registry)
+		c.JSON(http.StatusOK, cred)
+	}
+}
+
+// DeleteRegistryCredential removes a registry login
+func DeleteRegistryCredential(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cred, ok := loadRegistryCredential(c, db)
+		if !ok {
+			return
+		}
+		if err := db.Delete(cred).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, serverIDOf(*cred), "deleted registry login %s@%s", cred.Username, cred.Registry)
+		c.JSON(http.StatusOK, gin.H{"message": "Registry login deleted successfully"})
+	}
+}
+
+// bindRegistryCredential validates the request body and applies it to cred, encrypting the
+// password
+func bindRegistryCredential(c *gin.Context, db *gorm.DB, cred *model.RegistryCredential) bool {
+	var input RegistryCredentialInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Invalid(c, err)
+		return false
+	}
+	host := registry.NormalizeHost(strings.TrimSpace(input.Registry))
+	if host == "" || strings.ContainsAny(host, "/ @") {
+		apierror.AbortField(c, apierror.ValidationFailed, "registry", apierror.T(c, "validation_invalid", "registry"))
+		return false
+	}
+	if input.Password == nil && cred.Secret == "" {
+		apierror.AbortField(c, apierror.ValidationFailed, "password", apierror.T(c, "validation_required", "password"))
+		return false
+	}
+	if input.ServerID != nil {
+		if _, ok := loadServer(c, db, *input.ServerID); !ok {
+			return false
+		}
+	}
+	if input.Password != nil {
+		secret, err := secretbox.Seal(*input.Password)
+		if errors.Is(err, secretbox.ErrNoKey) {
+			apierror.AbortMessage(c, apierror.NotConfigured, apierror.T(c, "master_key_required"))
+			return false
+		}
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return false
+		}
+		cred.Secret = secret
+	}
+	cred.Registry = host
+	cred.Username = input.Username
+	cred.ServerID = input.ServerID
+	return true
+}
+
+// loadRegistryCredential fetches the registry login named by the ":id" route parameter
+func loadRegistryCredential(c *gin.Context, db *gorm.DB) (*model.RegistryCredential, bool) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return nil, false
+	}
+	var cred model.RegistryCredential
+	if err := db.First(&cred, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.NotFound)
+			return nil, false
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return nil, false
+	}
+	return &cred, true
+}
+
+// serverIDOf returns the server a registry login is limited to, zero for all servers
+func serverIDOf(cred model.RegistryCredential) uint {
+	if cred.ServerID == nil {
+		return 0
+	}
+	return *cred.ServerID
+}
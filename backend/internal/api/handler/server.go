@@ -1,88 +1,240 @@
 package handler
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
-	"time" // Import time package for cache TTL
-
+	"time"
+
+	"docker-pulse/internal/agent"
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/logging"
 	"docker-pulse/internal/model"
 	"docker-pulse/internal/ssh"
 
 	"github.com/gin-gonic/gin"
-	"github.com/patrickmn/go-cache" // Import go-cache
 	"gorm.io/gorm"
 )
 
 const (
 	serverCacheKeyPrefix = "servers_user_"
-	serverCacheTTL       = 5 * time.Minute
-	serverCacheCleanup   = 10 * time.Minute
 )
 
 // Cache for server lists and individual servers
-var serverCache = cache.New(serverCacheTTL, serverCacheCleanup)
+var serverCache = cache.New("servers", model.ConfigKeyServerCacheTTL, 5*time.Minute)
 
 // GetServerStats handles fetching real-time statistics for a single server
 func GetServerStats(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		serverID, err := strconv.ParseUint(id, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
 			return
 		}
 
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+		// Get ping targets from config
+		pingTargets, _ := config.Get(db, model.ConfigKeyPingTargets)
 
-		// 权限检查：非管理员必须拥有显式权限
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "access to this server is denied"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
+		// Get real-time stats
+		stats, err := ssh.RealtimeStats(c.Request.Context(), server, pingTargets)
+		if err != nil {
+			sshFailed(c, server.ID, "connect", err)
+			return
+		}
+		if stats.Status != ssh.StatusOffline && server.AuthMode != model.AuthModeDockerTLS {
+			sshClient, ok := connectServer(c, server)
+			if !ok {
 				return
 			}
+			if stats.GPUs, err = sshClient.GetGPUStats(c.Request.Context()); err != nil {
+				logging.L(c).Info("failed to read GPU stats", "server_id", server.ID, "error", err)
+			}
 		}
+		circuit := ssh.Circuit(server.ID)
+		stats.Circuit = &circuit
 
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server from DB"})
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// GetSystemInfo returns a server's OS, kernel, architecture and Docker storage setup. The stored
+// result is returned unless there is none yet or ?refresh=true asks to read it again.
+func GetSystemInfo(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+
+		var stored model.SystemInfo
+		err := db.Where("server_id = ?", server.ID).First(&stored).Error
+		switch {
+		case err == nil && c.Query("refresh") != "true":
+			c.JSON(http.StatusOK, stored)
+			return
+		case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
 
-		// Create SSH client
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		info, err := sshClient.GetSystemInfo(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create SSH client: %v", err)})
+			sshFailed(c, server.ID, "system_info", err)
+			return
+		}
+		info.ServerID = server.ID
+		if err := db.Save(info).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
+		c.JSON(http.StatusOK, info)
+	}
+}
 
-		// Get ping targets from config
-		var pingTargets string
-		var config model.Config
-		if err := db.Where("key = ?", model.ConfigKeyPingTargets).First(&config).Error; err == nil {
-			pingTargets = config.Value
+// ConnectionTest is the result of an explicit connection test. FailedHop is "jump_host" when the
+// server's jump host could not be reached and "server" for other failures. Sudo tells reachable
+// servers whether sudo works for the SSH user, so use_sudo can be checked before it is enabled.
+type ConnectionTest struct {
+	Reachable bool             `json:"reachable"`
+	LatencyMS int64            `json:"latency_ms"`
+	Error     string           `json:"error,omitempty"`
+	FailedHop string           `json:"failed_hop,omitempty"`
+	Sudo      *ssh.SudoCheck   `json:"sudo,omitempty"`
+	Circuit   ssh.CircuitState `json:"circuit"`
+}
+
+// TestConnection connects to the server even when its circuit is open. A successful test
+// closes the circuit. Servers reached over the Docker API are tested by pinging the engine.
+func TestConnection(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		if server.AuthMode == model.AuthModeDockerTLS {
+			testDockerTLS(c, server)
+			return
 		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		sshClient.BypassCircuit = true
+		// The test is of SSH, even while the server's agent is connected
+		sshClient.SetAgent(nil)
 
-		// Get real-time stats
-		stats, err := sshClient.GetServerRealtimeStats(pingTargets)
+		start := time.Now()
+		session, client, err := sshClient.CreateSession(c.Request.Context())
+		result := ConnectionTest{Reachable: err == nil, LatencyMS: time.Since(start).Milliseconds()}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to get server stats: %v", err)})
+			logging.L(c).Info("connection test failed", "server_id", server.ID, "error", err)
+			result.Error = err.Error()
+			result.FailedHop = "server"
+			if errors.Is(err, ssh.ErrJumpHost) {
+				result.FailedHop = "jump_host"
+			}
+		} else {
+			session.Close()
+			client.Close()
+			sudo, err := sshClient.CheckSudo(c.Request.Context())
+			if err != nil {
+				logging.L(c).Info("sudo check failed", "server_id", server.ID, "error", err)
+			}
+			result.Sudo = sudo
+		}
+		result.Circuit = ssh.Circuit(server.ID)
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// TestServerConnection connects to a server before it is saved, taking the body of
+// CreateServer, or with server_id a stored server whose fields the body's replace like in
+// UpdateServer. It reports each stage of connecting, see ssh.Probe, within ten seconds, and
+// neither stores the server nor touches its circuit breaker.
+func TestServerConnection(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			ServerID uint `json:"server_id"`
+			serverUpdateInput
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		server := &model.Server{}
+		if input.ServerID != 0 {
+			var ok bool
+			if server, ok = loadServer(c, db, input.ServerID); !ok {
+				return
+			}
+		}
+		input.apply(server)
+		required := []struct{ field, value string }{
+			{"ip", server.IP},
+			{"auth_mode", server.AuthMode},
+			{"secret", server.Secret},
+		}
+		for _, r := range required {
+			if r.value == "" {
+				apierror.AbortField(c, apierror.ValidationFailed, r.field, apierror.T(c, "validation_required", r.field))
+				return
+			}
+		}
+		if server.Port == 0 {
+			server.Port = 22
+			if server.AuthMode == model.AuthModeDockerTLS {
+				server.Port = 2376
+			}
+		}
+		if !checkServerAuth(c, server) || !checkJumpHost(c, server) || !checkNetInterface(c, server) || !checkConnectionMode(c, server) {
 			return
 		}
 
-		c.JSON(http.StatusOK, stats)
+		probe := ssh.ProbeServer(c.Request.Context(), server)
+		if probe.Error != "" {
+			logging.L(c).Info("connection probe failed", "server_id", input.ServerID, "stage", probe.FailedStage, "error", probe.Error)
+		}
+		c.JSON(http.StatusOK, probe)
+	}
+}
+
+// testDockerTLS is TestConnection for a docker-tls server
+func testDockerTLS(c *gin.Context, server *model.Server) {
+	client, err := ssh.NewDockerTLSClient(server)
+	if err != nil {
+		sshFailed(c, server.ID, "connect", err)
+		return
+	}
+	start := time.Now()
+	err = client.Ping(c.Request.Context())
+	result := ConnectionTest{Reachable: err == nil, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		logging.L(c).Info("connection test failed", "server_id", server.ID, "error", err)
+		result.Error = err.Error()
+		result.FailedHop = "server"
+	}
+	result.Circuit = ssh.Circuit(server.ID)
+	c.JSON(http.StatusOK, result)
+}
+
+// ResetServerCircuit closes the server's circuit breaker so the next request connects again
+func ResetServerCircuit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+		if !ok {
+			return
+		}
+		ssh.ResetCircuit(server.ID)
+		logging.L(c).Info("circuit reset", "server_id", server.ID)
+		c.JSON(http.StatusOK, ssh.Circuit(server.ID))
 	}
 }
 
@@ -93,23 +245,31 @@ func CreateServer(db *gorm.DB) gin.HandlerFunc {
 			Name     string `json:"name" binding:"required"`
 			IP       string `json:"ip" binding:"required"`
 			Port     int    `json:"port"`
-			Username string `json:"username" binding:"required"`
-			AuthMode string `json:"auth_mode" binding:"required"`
+			Username string `json:"username"`
+			AuthMode string `json:"auth_mode" binding:"required,oneof=password key docker-tls"`
 			Secret   string `json:"secret" binding:"required"`
+			// KeyPassphrase decrypts an encrypted private key
+			KeyPassphrase string `json:"key_passphrase"`
+			UseSudo       bool   `json:"use_sudo"`
+			SudoPassword  string `json:"sudo_password"`
+			NetInterface  string `json:"net_interface"`
+			// ConnectionMode is "cli" (the default) or "api"
+			ConnectionMode string `json:"connection_mode"`
+			Description    string `json:"description" binding:"max=255"`
+			Notes          string `json:"notes" binding:"max=65535"`
+			jumpHostInput
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
 		// Get current user ID from context
-		userID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		currentUserID, _, ok := currentUser(c)
+		if !ok {
 			return
 		}
-		currentUserID := userID.(uint)
 
 		server := model.Server{
 			Name:     input.Name,
@@ -118,6 +278,26 @@ func CreateServer(db *gorm.DB) gin.HandlerFunc {
 			Username: input.Username,
 			AuthMode: input.AuthMode,
 			Secret:   input.Secret,
+
+			KeyPassphrase: input.KeyPassphrase,
+			UseSudo:       input.UseSudo,
+			SudoPassword:  input.SudoPassword,
+			NetInterface:  input.NetInterface,
+
+			ConnectionMode: input.ConnectionMode,
+
+			Description: input.Description,
+			Notes:       input.Notes,
+		}
+		if server.ConnectionMode == "" {
+			server.ConnectionMode = model.ConnectionModeCLI
+		}
+		if server.Port == 0 && server.AuthMode == model.AuthModeDockerTLS {
+			server.Port = 2376
+		}
+		input.jumpHostInput.apply(&server)
+		if !checkServerAuth(c, &server) || !checkJumpHost(c, &server) || !checkNetInterface(c, &server) || !checkConnectionMode(c, &server) {
+			return
 		}
 
 		// Use a transaction to ensure atomicity
@@ -142,202 +322,304 @@ func CreateServer(db *gorm.DB) gin.HandlerFunc {
 		})
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create server and assign permissions"})
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
 
+		// The creator and admins now list the new server
+		invalidateServer(c, db, server.ID)
+		auditEvent(c, db, server.ID, "added server %s (%s)", server.Name, server.IP)
+
 		c.JSON(http.StatusCreated, server)
 	}
 }
 
-// ListServers handles listing servers based on user permissions
+// ListServers handles listing servers based on user permissions. ?q= only lists the servers
+// whose name, IP or description contains it, ignoring case; searches are not cached.
 func ListServers(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+		userID, userRole, ok := currentUser(c)
+		if !ok {
+			return
+		}
 
-		var servers []model.Server
-		cacheKey := fmt.Sprintf("%s%d", serverCacheKeyPrefix, userID)
+		servers := []model.Server{}
+		cacheKey := serverListCacheKey(userID)
+		query := strings.ToLower(strings.TrimSpace(c.Query("q")))
 
-		// 尝试从缓存中获取
-		if cachedServers, found := serverCache.Get(cacheKey); found {
-			c.JSON(http.StatusOK, cachedServers)
-			return
+		// 尝试从缓存中获取，?refresh=true 时跳过缓存
+		if query == "" && !forceRefresh(c, cacheKey) {
+			if entry, found := cachedEntry(serverCache, cacheKey); found {
+				entry.write(c, true)
+				return
+			}
 		}
 
 		if userRole == "admin" {
 			// Admins get all servers
 			if err := db.Find(&servers).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch servers for admin"})
+				apierror.AbortCause(c, apierror.DatabaseError, err)
 				return
 			}
 		} else {
 			// Regular users get only permitted servers
 			var permissions []model.ServerPermission
 			if err := db.Where("user_id = ?", userID).Find(&permissions).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user permissions"})
+				apierror.AbortCause(c, apierror.DatabaseError, err)
 				return
 			}
 
-			if len(permissions) == 0 {
-				// No permissions, return empty list
-				c.JSON(http.StatusOK, []model.Server{})
-				return
-			}
+			// No permissions, return empty list
+			if len(permissions) > 0 {
+				serverIDs := make([]uint, len(permissions))
+				for i, p := range permissions {
+					serverIDs[i] = p.ServerID
+				}
 
-			serverIDs := make([]uint, len(permissions))
-			for i, p := range permissions {
-				serverIDs[i] = p.ServerID
+				if err := db.Where("id IN ?", serverIDs).Find(&servers).Error; err != nil {
+					apierror.AbortCause(c, apierror.DatabaseError, err)
+					return
+				}
 			}
+		}
 
-			if err := db.Where("id IN ?", serverIDs).Find(&servers).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch permitted servers"})
-				return
-			}
+		if query != "" {
+			c.JSON(http.StatusOK, searchServers(servers, query))
+			return
+		}
+
+		entry, err := newJSONEntry(servers)
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
 		}
 
 		// 存入缓存
-		serverCache.Set(cacheKey, servers, serverCacheTTL)
+		serverCache.Set(cacheKey, entry)
 
-		c.JSON(http.StatusOK, servers)
+		entry.write(c, false)
 	}
 }
 
+// searchServers returns the servers whose name, IP or description contains query, which is
+// lowercase
+func searchServers(servers []model.Server, query string) []model.Server {
+	found := []model.Server{}
+	for _, s := range servers {
+		for _, field := range []string{s.Name, s.IP, s.Description} {
+			if strings.Contains(strings.ToLower(field), query) {
+				found = append(found, s)
+				break
+			}
+		}
+	}
+	return found
+}
+
 // GetServer handles fetching a single server by ID, checking permissions
 func GetServer(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		serverID, err := strconv.ParseUint(id, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+		serverID, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
+
+		// Admins can view any server, regular users must have explicit permission
+		if _, ok := checkAccess(c, db, serverID, model.AccessLevelRead); !ok {
 			return
 		}
 
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+		cacheKey := serverEntryCacheKey(serverID)
 
-		// Admins can view any server
-		if userRole != "admin" {
-			// Regular users must have explicit permission
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusForbidden, gin.H{"error": "access to this server is denied"})
+		// 尝试从缓存中获取单个服务器，?refresh=true 时跳过缓存
+		if !forceServerRefresh(c, serverID) {
+			if v, found := serverCache.Get(cacheKey); found {
+				if entry, ok := v.(serverEntry); ok {
+					entry.write(c, true)
 					return
 				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check permissions"})
-				return
 			}
 		}
 
-		var server model.Server
-		cacheKey := fmt.Sprintf("server_%d", serverID)
-
-		// 尝试从缓存中获取单个服务器
-		if cachedServer, found := serverCache.Get(cacheKey); found {
-			c.JSON(http.StatusOK, cachedServer)
+		server, ok := loadServer(c, db, serverID)
+		if !ok {
 			return
 		}
 
-		if err := db.First(&server, serverID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server"})
+		entry, err := newServerEntry(*server)
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
 			return
 		}
 
 		// 存入缓存
-		serverCache.Set(cacheKey, server, serverCacheTTL)
+		serverCache.Set(cacheKey, entry)
 
-		c.JSON(http.StatusOK, server)
+		entry.write(c, false)
+	}
+}
+
+// serverEntry is a server as GetServer caches it, with the ETag of its JSON
+type serverEntry struct {
+	server    model.Server
+	etag      string
+	fetchedAt time.Time
+}
+
+func newServerEntry(server model.Server) (serverEntry, error) {
+	body, err := json.Marshal(server)
+	if err != nil {
+		return serverEntry{}, err
 	}
+	return serverEntry{server: server, etag: hashETag(body), fetchedAt: time.Now()}, nil
+}
+
+// write sends the server with the age of the data. As for container lists, the ETag covers the
+// server only.
+func (e serverEntry) write(c *gin.Context, cached bool) {
+	writeWithETag(c, e.etag, e.fetchedAt, cached, func() ([]byte, error) {
+		return json.Marshal(model.ServerDetails{Server: e.server, FetchedAt: e.fetchedAt.UTC(), Cached: cached})
+	})
 }
 
 // UpdateServer handles updating an existing server entry
 func UpdateServer(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		serverID, err := strconv.ParseUint(id, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+		serverID, ok := parseID(c, "id")
+		if !ok {
 			return
 		}
 
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch server"})
+		server, ok := loadServer(c, db, serverID)
+		if !ok {
 			return
 		}
+		addr := server.IP + ":" + strconv.Itoa(server.Port)
 
-		var input struct {
-			Name     string `json:"name"`
-			IP       string `json:"ip"`
-			Port     int    `json:"port"`
-			Username string `json:"username"`
-			AuthMode string `json:"auth_mode"`
-			Secret   string `json:"secret"`
-		}
-
+		var input serverUpdateInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
-		// Update fields if provided
-		if input.Name != "" {
-			server.Name = input.Name
-		}
-		if input.IP != "" {
-			server.IP = input.IP
-		}
-		if input.Port != 0 {
-			server.Port = input.Port
-		}
-		if input.Username != "" {
-			server.Username = input.Username
-		}
-		if input.AuthMode != "" {
-			server.AuthMode = input.AuthMode
+		input.apply(server)
+		if !checkServerAuth(c, server) || !checkJumpHost(c, server) || !checkNetInterface(c, server) || !checkConnectionMode(c, server) {
+			return
 		}
-		if input.Secret != "" {
-			server.Secret = input.Secret
+		// The host at a new address may run another platform, which is detected again
+		if server.IP+":"+strconv.Itoa(server.Port) != addr {
+			server.Platform = ""
 		}
 
-		if err := db.Save(&server).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update server"})
+		if err := db.Save(server).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
 
-		// 更新成功后，清除所有相关缓存，以确保所有用户的列表都是最新的
-		serverCache.Flush()
+		// 更新成功后，只清除包含该服务器的缓存
+		invalidateServer(c, db, server.ID)
+		// New address or credentials deserve a fresh connection attempt
+		ssh.ResetCircuit(server.ID)
+		// The stored system info may describe the host previously at another address
+		if server.IP+":"+strconv.Itoa(server.Port) != addr {
+			if err := db.Where("server_id = ?", server.ID).Delete(&model.SystemInfo{}).Error; err != nil {
+				logging.L(c).Warn("failed to delete stored system info", "server_id", server.ID, "error", err)
+			}
+		}
+		auditEvent(c, db, server.ID, "updated server %s (%s)", server.Name, server.IP)
 
 		c.JSON(http.StatusOK, server)
 	}
 }
 
+// serverUpdateInput is the body of server updates. Omitted fields keep the stored values.
+type serverUpdateInput struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	AuthMode string `json:"auth_mode" binding:"omitempty,oneof=password key docker-tls"`
+	Secret   string `json:"secret"`
+	// KeyPassphrase and SudoPassword replace the stored ones when present, empty ones remove them
+	KeyPassphrase *string `json:"key_passphrase"`
+	UseSudo       *bool   `json:"use_sudo"`
+	SudoPassword  *string `json:"sudo_password"`
+	// NetInterface replaces the stored interface when present, empty uses the default route's
+	NetInterface *string `json:"net_interface"`
+	// ConnectionMode is "cli" or "api"
+	ConnectionMode string `json:"connection_mode"`
+	// Description and Notes replace the stored ones when present, empty ones remove them
+	Description *string `json:"description" binding:"omitempty,max=255"`
+	Notes       *string `json:"notes" binding:"omitempty,max=65535"`
+	jumpHostInput
+}
+
+// apply copies the given fields to server
+func (in serverUpdateInput) apply(server *model.Server) {
+	if in.Name != "" {
+		server.Name = in.Name
+	}
+	if in.IP != "" {
+		server.IP = in.IP
+	}
+	if in.Port != 0 {
+		server.Port = in.Port
+	}
+	if in.Username != "" {
+		server.Username = in.Username
+	}
+	if in.AuthMode != "" {
+		server.AuthMode = in.AuthMode
+	}
+	if in.Secret != "" {
+		server.Secret = in.Secret
+	}
+	if in.KeyPassphrase != nil {
+		server.KeyPassphrase = *in.KeyPassphrase
+	}
+	if in.UseSudo != nil {
+		server.UseSudo = *in.UseSudo
+	}
+	if in.SudoPassword != nil {
+		server.SudoPassword = *in.SudoPassword
+	}
+	if in.NetInterface != nil {
+		server.NetInterface = *in.NetInterface
+	}
+	if in.ConnectionMode != "" {
+		server.ConnectionMode = in.ConnectionMode
+	}
+	if server.ConnectionMode == "" {
+		server.ConnectionMode = model.ConnectionModeCLI
+	}
+	if in.Description != nil {
+		server.Description = *in.Description
+	}
+	if in.Notes != nil {
+		server.Notes = *in.Notes
+	}
+	in.jumpHostInput.apply(server)
+}
+
 // DeleteServer handles deleting a server entry
 func DeleteServer(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		serverID, err := strconv.ParseUint(id, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+		serverID, ok := parseID(c, "id")
+		if !ok {
 			return
 		}
 
+		// 权限记录决定哪些用户的列表受影响，因此在删除前清除缓存
+		invalidateServer(c, db, serverID)
+
 		if err := db.Delete(&model.Server{}, serverID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete server"})
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
 
-		// 删除成功后，刷新全部缓存
-		serverCache.Flush()
+		ssh.ResetCircuit(serverID)
+		agent.Disconnect(serverID)
+		auditEvent(c, db, serverID, "deleted server %d", serverID)
 
 		c.JSON(http.StatusOK, gin.H{"message": "server deleted successfully"})
 	}
@@ -350,26 +632,18 @@ func GetStatsHistory(db *gorm.DB) gin.HandlerFunc {
 		targetsParam := c.Query("targets")      // comma separated
 		duration := c.Query("range")            // 1H, 24H, 7D, 1M
 
-		var startTime time.Time
-		now := time.Now()
-
-		switch duration {
-		case "1H":
-			startTime = now.Add(-1 * time.Hour)
-		case "24H":
-			startTime = now.Add(-24 * time.Hour)
-		case "7D":
-			startTime = now.AddDate(0, 0, -7)
-		case "1M":
-			startTime = now.AddDate(0, -1, 0)
-		default:
-			startTime = now.Add(-24 * time.Hour) // Default 24H
-		}
-
-		query := db.Model(&model.StatsHistory{}).Where("timestamp >= ?", startTime)
+		query := db.Model(&model.StatsHistory{}).Where("timestamp >= ?", historyStart(duration))
 
 		if serverIDsParam != "" {
-			ids := strings.Split(serverIDsParam, ",")
+			var ids []uint
+			for _, s := range strings.Split(serverIDsParam, ",") {
+				id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+				if err != nil {
+					apierror.AbortField(c, apierror.InvalidID, "server_ids", apierror.Message(apierror.Language(c), apierror.InvalidID))
+					return
+				}
+				ids = append(ids, uint(id))
+			}
 			query = query.Where("server_id IN ?", ids)
 		}
 
@@ -380,7 +654,7 @@ func GetStatsHistory(db *gorm.DB) gin.HandlerFunc {
 
 		var rawResults []model.StatsHistory
 		if err := query.Order("timestamp asc").Find(&rawResults).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch history"})
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
 
@@ -391,24 +665,14 @@ func GetStatsHistory(db *gorm.DB) gin.HandlerFunc {
 
 		resultMap := make(map[string][]float64)
 		for _, r := range rawResults {
-			var timeKey string
-			if duration == "1H" || duration == "24H" {
-				timeKey = r.Timestamp.Format("15:04")
-			} else {
-				timeKey = r.Timestamp.Format("01-02 15h")
-			}
+			timeKey := historyKey(r.Timestamp, duration)
 			resultMap[timeKey] = append(resultMap[timeKey], r.Latency)
 		}
 
 		var finalHistory []HistoryPoint
 		seenKeys := make(map[string]bool)
 		for _, r := range rawResults {
-			var timeKey string
-			if duration == "1H" || duration == "24H" {
-				timeKey = r.Timestamp.Format("15:04")
-			} else {
-				timeKey = r.Timestamp.Format("01-02 15h")
-			}
+			timeKey := historyKey(r.Timestamp, duration)
 			if !seenKeys[timeKey] {
 				lats := resultMap[timeKey]
 				var sum float64
@@ -427,6 +691,71 @@ func GetStatsHistory(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// DiskHistoryPoint is a server's disk usage in one interval of the history. Usage is averaged
+// over the interval, Used and Total are its last reading.
+type DiskHistoryPoint struct {
+	Name  string  `json:"name"`
+	Usage float64 `json:"usage"`
+	Used  int64   `json:"used"`
+	Total int64   `json:"total"`
+}
+
+// GetDiskHistory returns the disk usage history of a server over the range given like for
+// GetStatsHistory
+func GetDiskHistory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		duration := c.Query("range")
+
+		var rows []model.DiskHistory
+		if err := db.Where("server_id = ? AND timestamp >= ?", server.ID, historyStart(duration)).Order("timestamp asc").Find(&rows).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+
+		history := []DiskHistoryPoint{}
+		var sum float64
+		var count int
+		for i, r := range rows {
+			sum += r.Usage
+			count++
+			key := historyKey(r.Timestamp, duration)
+			if i+1 < len(rows) && historyKey(rows[i+1].Timestamp, duration) == key {
+				continue
+			}
+			history = append(history, DiskHistoryPoint{Name: key, Usage: MathRound(sum/float64(count), 1), Used: r.Used, Total: r.Total})
+			sum, count = 0, 0
+		}
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+// historyStart returns the start of a history range: 1H, 24H, 7D or 1M, 24H by default
+func historyStart(duration string) time.Time {
+	now := time.Now()
+	switch duration {
+	case "1H":
+		return now.Add(-1 * time.Hour)
+	case "7D":
+		return now.AddDate(0, 0, -7)
+	case "1M":
+		return now.AddDate(0, -1, 0)
+	}
+	return now.Add(-24 * time.Hour)
+}
+
+// historyKey labels the interval of a history range t falls in: minutes for the 1H and 24H
+// ranges and hours otherwise
+func historyKey(t time.Time, duration string) string {
+	if duration == "1H" || duration == "24H" {
+		return t.Format("15:04")
+	}
+	return t.Format("01-02 15h")
+}
+
 func MathRound(val float64, precision int) float64 {
 	p := 1.0
 	for i := 0; i < precision; i++ {
@@ -434,3 +763,125 @@ func MathRound(val float64, precision int) float64 {
 	}
 	return float64(int(val*p+0.5)) / p
 }
+
+// checkServerAuth makes sure a server has what its authentication mode needs: an SSH user, a
+// private key that can be parsed with its passphrase for key authentication, or a usable TLS
+// bundle and no jump host for docker-tls. Bad credentials are reported when they are saved
+// rather than on every connection.
+func checkServerAuth(c *gin.Context, server *model.Server) bool {
+	if server.AuthMode == model.AuthModeDockerTLS {
+		if _, err := dockerapi.ParseTLSBundle(server.Secret); err != nil {
+			apierror.AbortField(c, apierror.ValidationFailed, "secret", apierror.T(c, "tls_bundle_invalid"))
+			return false
+		}
+		if server.JumpIP != "" {
+			apierror.AbortField(c, apierror.ValidationFailed, "jump_ip", apierror.T(c, "validation_invalid", "jump_ip"))
+			return false
+		}
+		return true
+	}
+	if server.Username == "" {
+		apierror.AbortField(c, apierror.ValidationFailed, "username", apierror.T(c, "validation_required", "username"))
+		return false
+	}
+	if server.AuthMode != "key" {
+		return true
+	}
+	return checkKey(c, server.Secret, server.KeyPassphrase, "secret", "key_passphrase")
+}
+
+// checkKey responds with validation_failed for the offending field when key cannot be parsed
+func checkKey(c *gin.Context, key, passphrase, keyField, passphraseField string) bool {
+	_, err := ssh.ParsePrivateKey(key, passphrase)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ssh.ErrKeyPassphraseRequired):
+		apierror.AbortField(c, apierror.ValidationFailed, passphraseField, apierror.T(c, "key_passphrase_required"))
+	case errors.Is(err, ssh.ErrKeyPassphrase):
+		apierror.AbortField(c, apierror.ValidationFailed, passphraseField, apierror.T(c, "key_passphrase_wrong"))
+	default:
+		apierror.AbortField(c, apierror.ValidationFailed, keyField, apierror.T(c, "key_unsupported"))
+	}
+	return false
+}
+
+// jumpHostInput is the optional jump host of a server in create and update requests. On
+// updates omitted fields keep the stored values and an empty jump_ip removes the jump host.
+type jumpHostInput struct {
+	JumpIP            *string `json:"jump_ip"`
+	JumpPort          int     `json:"jump_port" binding:"omitempty,min=1,max=65535"`
+	JumpUsername      string  `json:"jump_username"`
+	JumpAuthMode      string  `json:"jump_auth_mode" binding:"omitempty,oneof=password key"`
+	JumpSecret        string  `json:"jump_secret"`
+	JumpKeyPassphrase *string `json:"jump_key_passphrase"`
+}
+
+// apply copies the given jump host fields to server
+func (in jumpHostInput) apply(server *model.Server) {
+	if in.JumpIP != nil {
+		server.JumpIP = *in.JumpIP
+	}
+	if server.JumpIP == "" {
+		server.JumpPort, server.JumpUsername, server.JumpAuthMode, server.JumpSecret, server.JumpKeyPassphrase = 0, "", "", "", ""
+		return
+	}
+	if in.JumpPort != 0 {
+		server.JumpPort = in.JumpPort
+	}
+	if server.JumpPort == 0 {
+		server.JumpPort = 22
+	}
+	if in.JumpUsername != "" {
+		server.JumpUsername = in.JumpUsername
+	}
+	if in.JumpAuthMode != "" {
+		server.JumpAuthMode = in.JumpAuthMode
+	}
+	if in.JumpSecret != "" {
+		server.JumpSecret = in.JumpSecret
+	}
+	if in.JumpKeyPassphrase != nil {
+		server.JumpKeyPassphrase = *in.JumpKeyPassphrase
+	}
+}
+
+// checkJumpHost makes sure a server with a jump host has everything needed to connect to it
+func checkJumpHost(c *gin.Context, server *model.Server) bool {
+	if server.JumpIP == "" {
+		return true
+	}
+	required := []struct{ field, value string }{
+		{"jump_username", server.JumpUsername},
+		{"jump_auth_mode", server.JumpAuthMode},
+		{"jump_secret", server.JumpSecret},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			apierror.AbortField(c, apierror.ValidationFailed, r.field, apierror.T(c, "validation_required", r.field))
+			return false
+		}
+	}
+	if server.JumpAuthMode != "key" {
+		return true
+	}
+	return checkKey(c, server.JumpSecret, server.JumpKeyPassphrase, "jump_secret", "jump_key_passphrase")
+}
+
+// checkConnectionMode rejects connection modes other than "cli" and "api"
+func checkConnectionMode(c *gin.Context, server *model.Server) bool {
+	if server.ConnectionMode == model.ConnectionModeCLI || server.ConnectionMode == model.ConnectionModeAPI {
+		return true
+	}
+	apierror.AbortField(c, apierror.ValidationFailed, "connection_mode", apierror.T(c, "validation_invalid", "connection_mode"))
+	return false
+}
+
+// checkNetInterface rejects network interface names that are not valid on Linux
+func checkNetInterface(c *gin.Context, server *model.Server) bool {
+	if server.NetInterface == "" || ssh.ValidInterfaceName.MatchString(server.NetInterface) {
+		return true
+	}
+	apierror.AbortField(c, apierror.ValidationFailed, "net_interface", apierror.T(c, "validation_invalid", "net_interface"))
+	return false
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/maintenance"
+	"docker-pulse/internal/version"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PanelStatus is the public panel status the frontend polls for its banner
+type PanelStatus struct {
+	Maintenance maintenance.Status `json:"maintenance"`
+}
+
+// GetVersion returns the running version and the result of the last update check
+func GetVersion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, version.Current(db))
+	}
+}
+
+// GetStatus returns the panel status. It needs no token.
+func GetStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := maintenance.Current()
+		if status.Enabled && status.Message == "" {
+			status.Message = apierror.T(c, string(apierror.Maintenance))
+		}
+		c.JSON(http.StatusOK, PanelStatus{Maintenance: status})
+	}
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// rolloutWait bounds how long a scale or update waits for the service's tasks to settle
+	rolloutWait  = 30 * time.Second
+	rolloutPoll  = 2 * time.Second
+	maxTaskError = 5
+)
+
+// imageRef matches image references passed to docker service update
+var imageRef = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/:@-]*$`)
+
+// ScaleSwarmService sets a service's replica count and waits briefly for the tasks to converge
+func ScaleSwarmService(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.SwarmScaleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		server, sshClient, name, ok := swarmService(c, db)
+		if !ok {
+			return
+		}
+		before, ok := serviceTaskIDs(c, server.ID, sshClient, name)
+		if !ok {
+			return
+		}
+		if err := sshClient.ScaleService(c.Request.Context(), name, *req.Replicas); err != nil {
+			sshFailed(c, server.ID, "swarm_scale", err)
+			return
+		}
+		auditEvent(c, db, server.ID, "scaled swarm service %s on %s to %d replicas", name, server.Name, *req.Replicas)
+		respondRollout(c, server.ID, sshClient, name, before)
+	}
+}
+
+// UpdateSwarmService changes a service's image and/or forces a rolling restart and waits
+// briefly for the new tasks
+func UpdateSwarmService(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.SwarmUpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if req.Image == "" && !req.Force {
+			apierror.AbortMessage(c, apierror.ValidationFailed, apierror.T(c, "swarm_update_empty"))
+			return
+		}
+		if req.Image != "" && !imageRef.MatchString(req.Image) {
+			apierror.AbortField(c, apierror.ValidationFailed, "image", apierror.T(c, "validation_invalid", "image"))
+			return
+		}
+		server, sshClient, name, ok := swarmService(c, db)
+		if !ok {
+			return
+		}
+		before, ok := serviceTaskIDs(c, server.ID, sshClient, name)
+		if !ok {
+			return
+		}
+		if err := sshClient.UpdateService(c.Request.Context(), name, req.Image, req.Force); err != nil {
+			sshFailed(c, server.ID, "swarm_update", err)
+			return
+		}
+		if req.Image != "" {
+			auditEvent(c, db, server.ID, "updated swarm service %s on %s to image %s (force: %t)", name, server.Name, req.Image, req.Force)
+		} else {
+			auditEvent(c, db, server.ID, "forced a rolling restart of swarm service %s on %s", name, server.Name)
+		}
+		respondRollout(c, server.ID, sshClient, name, before)
+	}
+}
+
+// swarmService authorizes full access to the server, validates the ":name" parameter and
+// checks that the server is a swarm manager
+func swarmService(c *gin.Context, db *gorm.DB) (*model.Server, *ssh.SSHClient, string, bool) {
+	server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+	if !ok {
+		return nil, nil, "", false
+	}
+	name := c.Param("name")
+	if !ssh.ValidContainerID(name) {
+		apierror.AbortField(c, apierror.ValidationFailed, "name", apierror.T(c, "validation_invalid", "name"))
+		return nil, nil, "", false
+	}
+	sshClient, ok := connectServer(c, server)
+	if !ok {
+		return nil, nil, "", false
+	}
+	manager, err := sshClient.IsSwarmManager(c.Request.Context())
+	if err != nil {
+		sshFailed(c, server.ID, "swarm_info", err)
+		return nil, nil, "", false
+	}
+	if !manager {
+		apierror.Abort(c, apierror.NotSwarmManager)
+		return nil, nil, "", false
+	}
+	return server, sshClient, name, true
+}
+
+// serviceTaskIDs records the service's existing tasks, so that only failures of tasks created
+// by the change are reported. It also answers not_found for unknown services.
+func serviceTaskIDs(c *gin.Context, serverID uint, client *ssh.SSHClient, name string) (map[string]bool, bool) {
+	tasks, err := client.ServiceTasks(c.Request.Context(), name)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such service") {
+			apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "swarm_service_not_found", name))
+			return nil, false
+		}
+		sshFailed(c, serverID, "swarm_tasks", err)
+		return nil, false
+	}
+	ids := map[string]bool{}
+	for _, t := range tasks {
+		ids[t.ID] = true
+	}
+	return ids, true
+}
+
+// respondRollout polls the service's tasks until they converge, one fails or rolloutWait
+// passes, and responds with the last state
+func respondRollout(c *gin.Context, serverID uint, client *ssh.SSHClient, name string, before map[string]bool) {
+	deadline := time.Now().Add(rolloutWait)
+	for {
+		desired, err := client.ServiceReplicas(c.Request.Context(), name)
+		if err != nil {
+			sshFailed(c, serverID, "swarm_inspect", err)
+			return
+		}
+		tasks, err := client.ServiceTasks(c.Request.Context(), name)
+		if err != nil {
+			sshFailed(c, serverID, "swarm_tasks", err)
+			return
+		}
+		rollout := summarizeTasks(name, desired, tasks, before)
+		if rollout.Converged || rollout.Failed > 0 || time.Now().After(deadline) {
+			c.JSON(http.StatusOK, rollout)
+			return
+		}
+		time.Sleep(rolloutPoll)
+	}
+}
+
+// summarizeTasks counts the tasks meant to run and the new tasks that failed. desired is -1
+// for global services, which want one running task per eligible node.
+func summarizeTasks(name string, desired int, tasks []ssh.SwarmTask, before map[string]bool) model.SwarmRollout {
+	r := model.SwarmRollout{Service: name, Desired: desired, Errors: []string{}}
+	seen := map[string]bool{}
+	wanted := 0
+	for _, t := range tasks {
+		if t.DesiredState == "Running" {
+			wanted++
+			if strings.HasPrefix(t.CurrentState, "Running") {
+				r.Running++
+			} else {
+				r.Pending++
+			}
+		}
+		failed := strings.HasPrefix(t.CurrentState, "Failed") || strings.HasPrefix(t.CurrentState, "Rejected")
+		if failed && !before[t.ID] {
+			r.Failed++
+			if t.Error != "" && !seen[t.Error] && len(r.Errors) < maxTaskError {
+				seen[t.Error] = true
+				r.Errors = append(r.Errors, t.Error)
+			}
+		}
+	}
+	if r.Desired < 0 {
+		r.Desired = wanted
+	}
+	r.Converged = r.Pending == 0 && r.Running == r.Desired
+	return r
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/schedule"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TaskInput creates or updates a scheduled task. Command is only used by exec tasks.
+type TaskInput struct {
+	Name       string `json:"name" binding:"required,max=191"`
+	TargetType string `json:"target_type" binding:"required,oneof=container compose"`
+	Target     string `json:"target" binding:"required,max=191"`
+	Type       string `json:"type" binding:"required,oneof=restart exec pull"`
+	Command    string `json:"command"`
+	Cron       string `json:"cron" binding:"required,max=64"`
+	Enabled    *bool  `json:"enabled"`
+}
+
+// ListTasks returns the server's scheduled tasks
+func ListTasks(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+		if !ok {
+			return
+		}
+		tasks := []model.ScheduledTask{}
+		if err := db.Where("server_id = ?", server.ID).Order("id").Find(&tasks).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, tasks)
+	}
+}
+
+// CreateTask schedules a new task on the server
+func CreateTask(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+		if !ok {
+			return
+		}
+		userID, _, _ := currentUser(c)
+		task := model.ScheduledTask{ServerID: server.ID, CreatedBy: userID, Enabled: true}
+		if !bindTask(c, &task) {
+			return
+		}
+		if err := db.Create(&task).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusCreated, task)
+	}
+}
+
+// UpdateTask replaces a task's settings and reschedules it
+func UpdateTask(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		task, ok := loadTask(c, db)
+		if !ok {
+			return
+		}
+		if !bindTask(c, task) {
+			return
+		}
+		if err := db.Save(task).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, task)
+	}
+}
+
+// DeleteTask removes a task and its run history
+func DeleteTask(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		task, ok := loadTask(c, db)
+		if !ok {
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("task_id = ?", task.ID).Delete(&model.TaskRun{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(task).Error
+		})
+		if err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
+	}
+}
+
+// ListTaskRuns returns a task's run history, newest first. ?limit= caps the number of runs.
+func ListTaskRuns(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		task, ok := loadTask(c, db)
+		if !ok {
+			return
+		}
+		q := db.Where("task_id = ?", task.ID).Order("id DESC")
+		if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+			q = q.Limit(limit)
+		}
+		runs := []model.TaskRun{}
+		if err := q.Find(&runs).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, runs)
+	}
+}
+
+// bindTask validates the request body, applies it to task and computes the next run
+func bindTask(c *gin.Context, task *model.ScheduledTask) bool {
+	var input TaskInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Invalid(c, err)
+		return false
+	}
+	task.Name = input.Name
+	task.TargetType = input.TargetType
+	task.Target = input.Target
+	task.Type = input.Type
+	task.Command = input.Command
+	task.Cron = input.Cron
+	if input.Enabled != nil {
+		task.Enabled = *input.Enabled
+	}
+
+	next, err := schedule.Validate(task, time.Now())
+	if err != nil {
+		apierror.AbortMessage(c, apierror.ValidationFailed, err.Error())
+		return false
+	}
+	task.NextRunAt = nil
+	if task.Enabled {
+		task.NextRunAt = &next
+	}
+	return true
+}
+
+// loadTask fetches the task named by ":taskID" after checking manage access to the ":id" server
+func loadTask(c *gin.Context, db *gorm.DB) (*model.ScheduledTask, bool) {
+	server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+	if !ok {
+		return nil, false
+	}
+	taskID, ok := parseID(c, "taskID")
+	if !ok {
+		return nil, false
+	}
+	var task model.ScheduledTask
+	if err := db.Where("server_id = ?", server.ID).First(&task, taskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.NotFound)
+			return nil, false
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return nil, false
+	}
+	return &task, true
+}
@@ -2,8 +2,9 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/config"
 	"docker-pulse/internal/model"
 	"docker-pulse/internal/ssh"
 
@@ -14,15 +15,13 @@ import (
 // GetTelegramUserInfo 获取当前 Telegram 用户的基本信息
 func GetTelegramUserInfo(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		userID, _, ok := currentUser(c)
+		if !ok {
 			return
 		}
 
-		var user model.User
-		if err := db.First(&user, userID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		user, ok := loadUser(c, db, userID)
+		if !ok {
 			return
 		}
 
@@ -50,19 +49,21 @@ func GetTelegramUserInfo(db *gorm.DB) gin.HandlerFunc {
 // GetTelegramServerList 获取 Telegram 用户可访问的服务器列表
 func GetTelegramServerList(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+		userID, userRole, ok := currentUser(c)
+		if !ok {
+			return
+		}
 
 		var servers []model.Server
 		if userRole == "admin" {
 			if err := db.Find(&servers).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch servers"})
+				apierror.AbortCause(c, apierror.DatabaseError, err)
 				return
 			}
 		} else {
 			var permissions []model.ServerPermission
 			if err := db.Where("user_id = ?", userID).Find(&permissions).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch permissions"})
+				apierror.AbortCause(c, apierror.DatabaseError, err)
 				return
 			}
 
@@ -77,7 +78,7 @@ func GetTelegramServerList(db *gorm.DB) gin.HandlerFunc {
 			}
 
 			if err := db.Where("id IN ?", serverIDs).Find(&servers).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch permitted servers"})
+				apierror.AbortCause(c, apierror.DatabaseError, err)
 				return
 			}
 		}
@@ -105,44 +106,19 @@ func GetTelegramServerList(db *gorm.DB) gin.HandlerFunc {
 // GetTelegramContainerStatus 获取指定服务器的容器状态（简化版）
 func GetTelegramContainerStatus(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		serverID, err := strconv.ParseUint(id, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
 			return
 		}
-
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
-
-		// 权限检查
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-				return
-			}
-		}
-
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
+		query, ok := parseContainerQuery(c)
+		if !ok {
 			return
 		}
-
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to connect to server"})
-			return
-		}
-
-		output, err := sshClient.GetContainers()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get containers"})
+		snapshot, ok := containerSnapshotFor(c, server)
+		if !ok {
 			return
 		}
-
-		containers := parseContainerOutput(output, uint(serverID), userID.(uint))
+		containers, filtered := query.apply(snapshot.containers)
 
 		// 简化返回的容器信息
 		type TelegramContainerInfo struct {
@@ -165,7 +141,8 @@ func GetTelegramContainerStatus(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{
 			"server_name": server.Name,
 			"containers":  result,
-			"total":       len(result),
+			"total":       len(snapshot.containers),
+			"filtered":    filtered,
 		})
 	}
 }
@@ -173,54 +150,35 @@ func GetTelegramContainerStatus(db *gorm.DB) gin.HandlerFunc {
 // GetTelegramServerStats 获取指定服务器的统计信息（简化版）
 func GetTelegramServerStats(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		serverID, err := strconv.ParseUint(id, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid server ID"})
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
 			return
 		}
 
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+		pingTargets, _ := config.Get(db, model.ConfigKeyPingTargets)
 
-		// 权限检查
-		if userRole != "admin" {
-			var permission model.ServerPermission
-			if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
-				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-				return
-			}
-		}
-
-		var server model.Server
-		if err := db.First(&server, serverID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
-			return
-		}
-
-		sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to connect to server"})
-			return
-		}
-
-		var pingTargets string
-		var config model.Config
-		if err := db.Where("key = ?", model.ConfigKeyPingTargets).First(&config).Error; err == nil {
-			pingTargets = config.Value
-		}
-
-		stats, err := sshClient.GetServerRealtimeStats(pingTargets)
+		stats, err := ssh.RealtimeStats(c.Request.Context(), server, pingTargets)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get server stats"})
+			sshFailed(c, server.ID, "connect", err)
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"server_name":         server.Name,
 			"status":              stats.Status,
+			"status_detail":       stats.StatusDetail,
 			"cpu_usage":           stats.CPUUsage,
 			"ram_usage":           stats.RAMUsage,
+			"load_avg_1":          stats.LoadAvg1,
+			"load_avg_5":          stats.LoadAvg5,
+			"load_avg_15":         stats.LoadAvg15,
+			"cpu_cores":           stats.CPUCores,
+			"load_per_core":       stats.LoadPerCore,
+			"disk_usage":          stats.DiskUsage,
+			"disk_total":          stats.DiskTotal,
+			"disk_used":           stats.DiskUsed,
+			"net_rx_rate":         stats.NetRxRate,
+			"net_tx_rate":         stats.NetTxRate,
 			"docker_version":      stats.DockerVersion,
 			"uptime":              stats.Uptime,
 			"running_containers":  stats.RunningContainers,
@@ -233,8 +191,10 @@ func GetTelegramServerStats(db *gorm.DB) gin.HandlerFunc {
 // GetTelegramQuickSummary 获取 Telegram 快速摘要信息
 func GetTelegramQuickSummary(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, _ := c.Get("userID")
-		userRole, _ := c.Get("role")
+		userID, userRole, ok := currentUser(c)
+		if !ok {
+			return
+		}
 
 		var servers []model.Server
 		if userRole == "admin" {
@@ -248,6 +208,7 @@ func GetTelegramQuickSummary(db *gorm.DB) gin.HandlerFunc {
 					"online_servers":    0,
 					"total_containers":  0,
 					"running_containers": 0,
+					"unhealthy_containers": 0,
 				})
 				return
 			}
@@ -262,20 +223,23 @@ func GetTelegramQuickSummary(db *gorm.DB) gin.HandlerFunc {
 		onlineServers := 0
 		totalContainers := 0
 		runningContainers := 0
+		unhealthyContainers := 0
 
 		// 获取每个服务器的状态
 		for _, server := range servers {
-			sshClient, err := ssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
+			backend, err := ssh.NewServerBackend(&server)
 			if err == nil {
 				// 尝试获取状态
-				output, err := sshClient.GetContainers()
+				containers, err := backend.ListContainers(c.Request.Context())
 				if err == nil {
-					containers := parseContainerOutput(output, server.ID, userID.(uint))
 					totalContainers += len(containers)
 					for _, c := range containers {
 						if c.State == "running" {
 							runningContainers++
 						}
+						if c.Health == model.HealthUnhealthy {
+							unhealthyContainers++
+						}
 					}
 					onlineServers++
 				}
@@ -287,6 +251,7 @@ func GetTelegramQuickSummary(db *gorm.DB) gin.HandlerFunc {
 			"online_servers":     onlineServers,
 			"total_containers":   totalContainers,
 			"running_containers": runningContainers,
+			"unhealthy_containers": unhealthyContainers,
 		})
 	}
 }
\ No newline at end of file
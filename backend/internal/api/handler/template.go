@@ -0,0 +1,433 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// templateExportVersion is the format written by ExportTemplates and accepted by ImportTemplates
+const templateExportVersion = 1
+
+var (
+	// placeholder matches ${NAME} in template fields
+	placeholder   = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	restartPolicy = regexp.MustCompile(`^(no|always|unless-stopped|on-failure(:[0-9]+)?)$`)
+)
+
+// TemplateInput creates, updates or imports a container template. Env entries are KEY=value,
+// ports are docker -p specs and volumes docker -v specs.
+type TemplateInput struct {
+	Name          string   `json:"name" binding:"required,max=191"`
+	Image         string   `json:"image" binding:"required,max=512"`
+	Env           []string `json:"env"`
+	Ports         []string `json:"ports"`
+	Volumes       []string `json:"volumes"`
+	RestartPolicy string   `json:"restart_policy"`
+	Notes         string   `json:"notes"`
+}
+
+// TemplateInfo is a container template as returned by the API. Variables lists the placeholders
+// that must be given values to create a container from it.
+type TemplateInfo struct {
+	ID            uint      `json:"id"`
+	Name          string    `json:"name"`
+	Image         string    `json:"image"`
+	Env           []string  `json:"env"`
+	Ports         []string  `json:"ports"`
+	Volumes       []string  `json:"volumes"`
+	RestartPolicy string    `json:"restart_policy"`
+	Notes         string    `json:"notes"`
+	Variables     []string  `json:"variables"`
+	CreatedBy     uint      `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TemplateExport is the JSON document templates are shared in between instances
+type TemplateExport struct {
+	Version   int             `json:"version"`
+	Templates []TemplateInput `json:"templates" binding:"dive"`
+}
+
+// TemplateImportResult counts the templates an import created and the ones it replaced by name
+type TemplateImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+func templateInfo(t model.ContainerTemplate) TemplateInfo {
+	return TemplateInfo{
+		ID:            t.ID,
+		Name:          t.Name,
+		Image:         t.Image,
+		Env:           templateLines(t.Env),
+		Ports:         templateLines(t.Ports),
+		Volumes:       templateLines(t.Volumes),
+		RestartPolicy: t.RestartPolicy,
+		Notes:         t.Notes,
+		Variables:     templateVariables(t),
+		CreatedBy:     t.CreatedBy,
+		CreatedAt:     t.CreatedAt,
+		UpdatedAt:     t.UpdatedAt,
+	}
+}
+
+// ListTemplates returns every container template
+func ListTemplates(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var templates []model.ContainerTemplate
+		if err := db.Order("name").Find(&templates).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		out := make([]TemplateInfo, 0, len(templates))
+		for _, t := range templates {
+			out = append(out, templateInfo(t))
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// GetTemplate returns a container template and the placeholders it expects
+func GetTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := loadTemplate(c, db, "id")
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, templateInfo(*t))
+	}
+}
+
+// CreateTemplate adds a container template
+func CreateTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input TemplateInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		userID, _, _ := currentUser(c)
+		t := model.ContainerTemplate{CreatedBy: userID}
+		if !applyTemplate(c, db, &t, input) {
+			return
+		}
+		if err := db.Create(&t).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, 0, "added container template %s", t.Name)
+		c.JSON(http.StatusCreated, templateInfo(t))
+	}
+}
+
+// UpdateTemplate replaces a container template's settings
+func UpdateTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := loadTemplate(c, db, "id")
+		if !ok {
+			return
+		}
+		var input TemplateInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if !applyTemplate(c, db, t, input) {
+			return
+		}
+		if err := db.Save(t).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, 0, "updated container template %s", t.Name)
+		c.JSON(http.StatusOK, templateInfo(*t))
+	}
+}
+
+// DeleteTemplate removes a container template. Containers created from it keep running.
+func DeleteTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := loadTemplate(c, db, "id")
+		if !ok {
+			return
+		}
+		if err := db.Delete(t).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, 0, "deleted container template %s", t.Name)
+		c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+	}
+}
+
+// ExportTemplates downloads every container template for ImportTemplates on another instance
+func ExportTemplates(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var templates []model.ContainerTemplate
+		if err := db.Order("name").Find(&templates).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		export := TemplateExport{Version: templateExportVersion, Templates: make([]TemplateInput, 0, len(templates))}
+		for _, t := range templates {
+			export.Templates = append(export.Templates, TemplateInput{
+				Name:          t.Name,
+				Image:         t.Image,
+				Env:           templateLines(t.Env),
+				Ports:         templateLines(t.Ports),
+				Volumes:       templateLines(t.Volumes),
+				RestartPolicy: t.RestartPolicy,
+				Notes:         t.Notes,
+			})
+		}
+		c.Header("Content-Disposition", `attachment; filename="container-templates.json"`)
+		c.JSON(http.StatusOK, export)
+	}
+}
+
+// ImportTemplates adds the templates of an export, replacing existing templates with the same
+// name. Nothing is imported when any template is invalid.
+func ImportTemplates(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var export TemplateExport
+		if err := c.ShouldBindJSON(&export); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if export.Version != templateExportVersion {
+			apierror.AbortField(c, apierror.ValidationFailed, "version", apierror.T(c, "validation_invalid", "version"))
+			return
+		}
+		userID, _, _ := currentUser(c)
+		var result TemplateImportResult
+		templates := make([]model.ContainerTemplate, 0, len(export.Templates))
+		seen := map[string]bool{}
+		for _, input := range export.Templates {
+			if seen[input.Name] {
+				apierror.AbortField(c, apierror.ValidationFailed, "templates", apierror.T(c, "template_name_taken", input.Name))
+				return
+			}
+			seen[input.Name] = true
+			var t model.ContainerTemplate
+			err := db.Where("name = ?", input.Name).First(&t).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				t = model.ContainerTemplate{CreatedBy: userID}
+				result.Created++
+			case err != nil:
+				apierror.AbortCause(c, apierror.DatabaseError, err)
+				return
+			default:
+				result.Updated++
+			}
+			if !applyTemplate(c, db, &t, input) {
+				return
+			}
+			templates = append(templates, t)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i := range templates {
+				if err := tx.Save(&templates[i]).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, 0, "imported container templates (%d added, %d replaced)", result.Created, result.Updated)
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// CreateFromTemplate creates and starts a container on the server from a template, filling its
+// placeholders with the given values. The container is labelled with the template it came from.
+func CreateFromTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.TemplateDeployRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if !ssh.ValidContainerID(req.Name) {
+			apierror.AbortField(c, apierror.ValidationFailed, "name", apierror.T(c, "validation_invalid", "name"))
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		t, ok := loadTemplate(c, db, "templateID")
+		if !ok {
+			return
+		}
+
+		create, missing := expandTemplate(*t, req.Values)
+		if len(missing) > 0 {
+			apierror.AbortField(c, apierror.ValidationFailed, "values", apierror.T(c, "template_values_missing", strings.Join(missing, ", ")))
+			return
+		}
+		create.Name = req.Name
+		// The filled in template is checked like a create request
+		cfg, ok := containerRunConfig(c, create)
+		if !ok {
+			return
+		}
+		cfg.Labels = map[string]string{
+			model.LabelTemplateID:   strconv.FormatUint(uint64(t.ID), 10),
+			model.LabelTemplateName: t.Name,
+		}
+		if !ensurePortsFree(c, server, create.Ports, "") {
+			return
+		}
+
+		containerID, pulled, ok := startContainer(c, server, cfg)
+		if !ok {
+			return
+		}
+		invalidateContainers(server.ID)
+		listenerCache.Delete(listenerCacheKey(server.ID))
+		auditEvent(c, db, server.ID, "created container %s on %s from template %s", create.Name, server.Name, t.Name)
+		c.JSON(http.StatusCreated, model.TemplateDeployResult{ContainerID: containerID, Name: create.Name, TemplateID: t.ID, Pulled: pulled})
+	}
+}
+
+// applyTemplate validates input and applies it to t. Ports and volumes are checked once the
+// placeholders are filled in, since they may be part of them.
+func applyTemplate(c *gin.Context, db *gorm.DB, t *model.ContainerTemplate, input TemplateInput) bool {
+	var taken int64
+	if err := db.Model(&model.ContainerTemplate{}).Where("name = ? AND id <> ?", input.Name, t.ID).Count(&taken).Error; err != nil {
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return false
+	}
+	if taken > 0 {
+		apierror.AbortField(c, apierror.Conflict, "name", apierror.T(c, "template_name_taken", input.Name))
+		return false
+	}
+	for _, env := range input.Env {
+		name, _, found := strings.Cut(env, "=")
+		if !found || !varName.MatchString(name) || strings.Contains(env, "\n") {
+			apierror.AbortField(c, apierror.ValidationFailed, "env", apierror.T(c, "invalid_env", env))
+			return false
+		}
+	}
+	for _, f := range []struct {
+		name    string
+		entries []string
+	}{{"ports", input.Ports}, {"volumes", input.Volumes}} {
+		for _, entry := range f.entries {
+			if strings.TrimSpace(entry) == "" || strings.Contains(entry, "\n") {
+				apierror.AbortField(c, apierror.ValidationFailed, f.name, apierror.T(c, "validation_invalid", f.name))
+				return false
+			}
+		}
+	}
+	if input.RestartPolicy != "" && !restartPolicy.MatchString(input.RestartPolicy) {
+		apierror.AbortField(c, apierror.ValidationFailed, "restart_policy", apierror.T(c, "validation_invalid", "restart_policy"))
+		return false
+	}
+
+	t.Name = input.Name
+	t.Image = input.Image
+	t.Env = strings.Join(input.Env, "\n")
+	t.Ports = strings.Join(input.Ports, "\n")
+	t.Volumes = strings.Join(input.Volumes, "\n")
+	t.RestartPolicy = input.RestartPolicy
+	t.Notes = input.Notes
+	return true
+}
+
+// loadTemplate fetches the template named by the given route parameter
+func loadTemplate(c *gin.Context, db *gorm.DB, param string) (*model.ContainerTemplate, bool) {
+	id, ok := parseID(c, param)
+	if !ok {
+		return nil, false
+	}
+	var t model.ContainerTemplate
+	if err := db.First(&t, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.NotFound)
+			return nil, false
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return nil, false
+	}
+	return &t, true
+}
+
+// templateLines splits a stored template field into its entries
+func templateLines(s string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// templateFields returns the template fields that may contain placeholders, in order
+func templateFields(t model.ContainerTemplate) []string {
+	fields := []string{t.Image}
+	fields = append(fields, templateLines(t.Env)...)
+	fields = append(fields, templateLines(t.Ports)...)
+	return append(fields, templateLines(t.Volumes)...)
+}
+
+// templateVariables lists the template's placeholders in order of first use
+func templateVariables(t model.ContainerTemplate) []string {
+	variables := []string{}
+	seen := map[string]bool{}
+	for _, field := range templateFields(t) {
+		for _, m := range placeholder.FindAllStringSubmatch(field, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				variables = append(variables, m[1])
+			}
+		}
+	}
+	return variables
+}
+
+// expandTemplate fills in the template's placeholders and returns the placeholders without a
+// value, if any
+func expandTemplate(t model.ContainerTemplate, values map[string]string) (model.ContainerCreateRequest, []string) {
+	var missing []string
+	for _, name := range templateVariables(t) {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	expand := func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+			return values[placeholder.FindStringSubmatch(m)[1]]
+		})
+	}
+	expandAll := func(lines []string) []string {
+		for i := range lines {
+			lines[i] = expand(lines[i])
+		}
+		return lines
+	}
+	return model.ContainerCreateRequest{
+		Image:         expand(t.Image),
+		Env:           expandAll(templateLines(t.Env)),
+		Ports:         expandAll(templateLines(t.Ports)),
+		Volumes:       expandAll(templateLines(t.Volumes)),
+		RestartPolicy: t.RestartPolicy,
+	}, missing
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"docker-pulse/internal/model"
+)
+
+func TestTemplateValuesAreCheckedLikeACreateRequest(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "admin", "admin")
+	seedServer(t, db, "web")
+	tmpl := model.ContainerTemplate{Name: "app", Image: "${IMAGE}", Env: "TZ=${TZ}", Ports: "${PORT}:80", Volumes: "${DATA}:/data", RestartPolicy: "unless-stopped"}
+	if err := db.Create(&tmpl).Error; err != nil {
+		t.Fatalf("create template: %v", err)
+	}
+	r := newTestRouter(db)
+	r.POST("/servers/:id/containers/from-template/:templateID", CreateFromTemplate(db))
+
+	// Every one of these is refused before the server is connected to
+	for _, body := range []string{
+		`{"name":"app","values":{"IMAGE":"nginx; id","TZ":"UTC","PORT":"8080","DATA":"/srv/app"}}`,
+		`{"name":"app","values":{"IMAGE":"nginx","TZ":"UTC","PORT":"80 -p 22","DATA":"/srv/app"}}`,
+		`{"name":"app","values":{"IMAGE":"nginx","TZ":"UTC","PORT":"8080","DATA":"relative/path"}}`,
+		`{"name":"app","values":{"IMAGE":"nginx","TZ":"UTC\nX=1","PORT":"8080","DATA":"/srv/app"}}`,
+		`{"name":"app","values":{"IMAGE":"nginx","TZ":"UTC"}}`,
+	} {
+		w := requestBody(r, http.MethodPost, "/servers/1/containers/from-template/1", "admin", body, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d %s, want 400", body, w.Code, w.Body.String())
+		}
+	}
+}
@@ -13,6 +13,9 @@ import (
 	"strings"
 	"time"
 
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/logging"
 	"docker-pulse/internal/model"
 
 	"github.com/gin-gonic/gin"
@@ -37,18 +40,22 @@ func Login(db *gorm.DB, secret string) gin.HandlerFunc {
 			Password string `json:"password"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
 		var user model.User
 		if err := db.Where("username = ?", input.Username).First(&user).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.AbortCause(c, apierror.DatabaseError, err)
+				return
+			}
+			apierror.Abort(c, apierror.InvalidCredentials)
 			return
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			apierror.Abort(c, apierror.InvalidCredentials)
 			return
 		}
 
@@ -70,7 +77,7 @@ func Login(db *gorm.DB, secret string) gin.HandlerFunc {
 		// Create the JWT string
 		tokenString, err := token.SignedString([]byte(secret))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+			apierror.AbortCause(c, apierror.Internal, err)
 			return
 		}
 
@@ -90,38 +97,39 @@ func ChangePassword(db *gorm.DB) gin.HandlerFunc {
 			NewPassword     string `json:"new_password"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
 		// Extract user from context (set by AuthMiddleware)
-		userID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		userID, _, ok := currentUser(c)
+		if !ok {
 			return
 		}
 
-		var user model.User
-		if err := db.First(&user, userID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		user, ok := loadUser(c, db, userID)
+		if !ok {
 			return
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Current password incorrect"})
+			apierror.AbortField(c, apierror.ValidationFailed, "current_password", apierror.T(c, "current_password_incorrect"))
 			return
 		}
 
 		// Update password and increment token version to invalidate all existing tokens
 		hashedPassword, err := model.HashPassword(input.NewPassword)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			apierror.AbortCause(c, apierror.Internal, err)
 			return
 		}
 
 		user.Password = hashedPassword
 		user.TokenVersion++
-		db.Save(&user)
+		if err := db.Save(user).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 	}
@@ -130,7 +138,10 @@ func ChangePassword(db *gorm.DB) gin.HandlerFunc {
 func ListUsers(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var users []model.User
-		db.Find(&users)
+		if err := db.Find(&users).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
 		c.JSON(http.StatusOK, users)
 	}
 }
@@ -144,7 +155,7 @@ func CreateUser(db *gorm.DB) gin.HandlerFunc {
 			TelegramID int64  `json:"telegram_id"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
@@ -156,19 +167,22 @@ func CreateUser(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Create(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
+		auditEvent(c, db, 0, "created user %s with role %s", user.Username, user.Role)
 		c.JSON(http.StatusCreated, user)
 	}
 }
 
 func UpdateUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		var user model.User
-		if err := db.First(&user, id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		id, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
+		user, ok := loadUser(c, db, id)
+		if !ok {
 			return
 		}
 
@@ -179,7 +193,7 @@ func UpdateUser(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
@@ -188,14 +202,23 @@ func UpdateUser(db *gorm.DB) gin.HandlerFunc {
 		user.Role = input.Role
 		user.TelegramID = input.TelegramID
 
-		db.Save(&user)
+		if err := db.Save(user).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		// The role decides which servers are listed
+		invalidateServerList(user.ID)
+		auditEvent(c, db, 0, "updated user %s (role %s)", user.Username, user.Role)
 		c.JSON(http.StatusOK, user)
 	}
 }
 
 func DeleteUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
+		id, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
 
 		// Use a transaction to ensure atomicity
 		err := db.Transaction(func(tx *gorm.DB) error {
@@ -213,9 +236,11 @@ func DeleteUser(db *gorm.DB) gin.HandlerFunc {
 		})
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user and associated permissions"})
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
+		invalidateServerList(id)
+		auditEvent(c, db, 0, "deleted user %d", id)
 
 		c.JSON(http.StatusOK, gin.H{"message": "User deleted permanently"})
 	}
@@ -224,38 +249,47 @@ func DeleteUser(db *gorm.DB) gin.HandlerFunc {
 func ResetUserPassword(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if the requesting user is an admin
-		requesterRole, exists := c.Get("role")
-		if !exists || requesterRole != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Only administrators can reset passwords"})
+		_, role, ok := currentUser(c)
+		if !ok {
+			return
+		}
+		if role != "admin" {
+			apierror.Abort(c, apierror.AdminRequired)
 			return
 		}
 
-		id := c.Param("id")
+		id, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
 		var input struct {
 			NewPassword string `json:"new_password"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
-		var user model.User
-		if err := db.First(&user, id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		user, ok := loadUser(c, db, id)
+		if !ok {
 			return
 		}
 
 		// Update password and increment token version to invalidate all existing tokens
 		hashedPassword, err := model.HashPassword(input.NewPassword)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			apierror.AbortCause(c, apierror.Internal, err)
 			return
 		}
 
 		user.Password = hashedPassword
 		user.TokenVersion++
-		db.Save(&user)
+		if err := db.Save(user).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
 
+		auditEvent(c, db, 0, "reset the password of user %s", user.Username)
 		c.JSON(http.StatusOK, gin.H{"message": "User password reset successfully"})
 	}
 }
@@ -323,62 +357,69 @@ func validateTelegramData(data string, botToken string) (map[string]string, erro
 }
 
 // BindTelegram handles binding the current authenticated user to a Telegram ID
-func BindTelegram(db *gorm.DB, botToken string) gin.HandlerFunc {
+func BindTelegram(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			InitData string `json:"init_data"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+			apierror.Invalid(c, err)
 			return
 		}
 
+		// Read the token per request so a hot-reloaded bot token takes effect immediately
+		botToken, _ := config.Get(db, model.ConfigKeyTelegramBotToken)
 		if botToken == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Telegram Bot Token is not configured"})
+			apierror.AbortMessage(c, apierror.NotConfigured, apierror.T(c, "telegram_not_configured"))
 			return
 		}
 
 		// 1. Validate Telegram data
 		userData, err := validateTelegramData(input.InitData, botToken)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			apierror.AbortCause(c, apierror.InvalidToken, err)
 			return
 		}
 
 		telegramIDStr, ok := userData["id"]
 		if !ok || telegramIDStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Telegram user ID not found in data"})
+			apierror.AbortField(c, apierror.InvalidRequest, "init_data", apierror.T(c, "telegram_id_missing"))
 			return
 		}
 
 		telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Telegram ID format"})
+			apierror.AbortField(c, apierror.InvalidRequest, "init_data", apierror.T(c, "telegram_id_invalid"))
 			return
 		}
 
 		// 2. Check if Telegram ID is already bound to another user
 		var existingUser model.User
 		// Exclude the current user from the check
-		currentUserID, _ := c.Get("userID")
+		currentUserID, _, ok := currentUser(c)
+		if !ok {
+			return
+		}
 
 		if err := db.Where("telegram_id = ? AND id != ?", telegramID, currentUserID).First(&existingUser).Error; err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Telegram ID %d is already bound to user %s", telegramID, existingUser.Username)})
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "telegram_already_bound", telegramID, existingUser.Username))
 			return
 		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error during check"})
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
 
 		// 3. Bind Telegram ID to current user
-		var user model.User
-		if err := db.First(&user, currentUserID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		user, ok := loadUser(c, db, currentUserID)
+		if !ok {
 			return
 		}
 
 		user.TelegramID = telegramID
-		db.Save(&user)
+		if err := db.Save(user).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{"message": "Telegram ID bound successfully", "telegram_id": telegramID})
 	}
@@ -386,10 +427,13 @@ func BindTelegram(db *gorm.DB, botToken string) gin.HandlerFunc {
 
 func GetUserPermissions(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.Param("id")
+		userID, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
 		var permissions []model.ServerPermission
 		if err := db.Where("user_id = ?", userID).Find(&permissions).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
 		c.JSON(http.StatusOK, permissions)
@@ -398,10 +442,8 @@ func GetUserPermissions(db *gorm.DB) gin.HandlerFunc {
 
 func UpdateUserPermissions(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userIDStr := c.Param("id")
-		userID, err := strconv.ParseUint(userIDStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		userID, ok := parseID(c, "id")
+		if !ok {
 			return
 		}
 
@@ -412,14 +454,14 @@ func UpdateUserPermissions(db *gorm.DB) gin.HandlerFunc {
 			} `json:"permissions"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Invalid(c, err)
 			return
 		}
 
-		err = db.Transaction(func(tx *gorm.DB) error {
+		err := db.Transaction(func(tx *gorm.DB) error {
 			// Remove existing permissions
 			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.ServerPermission{}).Error; err != nil {
-				fmt.Printf("Error deleting existing permissions for user %d: %v\n", userID, err)
+				logging.L(c).Error("failed to delete existing permissions", "target_user_id", userID, "error", err)
 				return err
 			}
 
@@ -431,12 +473,12 @@ func UpdateUserPermissions(db *gorm.DB) gin.HandlerFunc {
 				}
 
 				permission := model.ServerPermission{
-					UserID:      uint(userID),
+					UserID:      userID,
 					ServerID:    p.ServerID,
 					AccessLevel: accessLevel,
 				}
 				if err := tx.Create(&permission).Error; err != nil {
-					fmt.Printf("Error creating new permission for user %d, server %d: %v\n", userID, p.ServerID, err)
+					logging.L(c).Error("failed to create permission", "target_user_id", userID, "server_id", p.ServerID, "error", err)
 					return err
 				}
 			}
@@ -444,15 +486,61 @@ func UpdateUserPermissions(db *gorm.DB) gin.HandlerFunc {
 		})
 
 		if err != nil {
-			fmt.Printf("Transaction failed for user %d permissions update: %v\n", userID, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update permissions", "details": err.Error()})
+			logging.L(c).Error("permissions update transaction failed", "target_user_id", userID, "error", err)
+			apierror.Abort(c, apierror.DatabaseError)
 			return
 		}
 
 		// Clear server list cache for this specific user
-		cacheKey := fmt.Sprintf("servers_user_%d", userID)
-		serverCache.Delete(cacheKey)
+		invalidateServerList(userID)
+		auditEvent(c, db, 0, "updated the server permissions of user %d", userID)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Permissions updated successfully"})
 	}
 }
+
+// SetLanguage stores the current user's preferred language for API messages. An empty
+// language clears the preference so the Accept-Language header applies again.
+func SetLanguage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Language string `json:"language"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		if input.Language != "" {
+			lang, ok := apierror.Supported(input.Language)
+			if !ok {
+				apierror.AbortField(c, apierror.ValidationFailed, "language", apierror.T(c, "unsupported_language"))
+				return
+			}
+			input.Language = lang
+		}
+
+		userID, _, ok := currentUser(c)
+		if !ok {
+			return
+		}
+		if err := db.Model(&model.User{}).Where("id = ?", userID).Update("language", input.Language).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"language": input.Language})
+	}
+}
+
+// loadUser fetches a user, responding with user_not_found when it does not exist
+func loadUser(c *gin.Context, db *gorm.DB, id uint) (*model.User, bool) {
+	var user model.User
+	if err := db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.UserNotFound)
+			return nil, false
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return nil, false
+	}
+	return &user, true
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListVolumes lists a server's volumes with their size, the containers that mount them and
+// whether they are dangling, mounted by no container
+func ListVolumes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		volumes, err := sshClient.ListVolumes(c.Request.Context())
+		if err != nil {
+			sshFailed(c, server.ID, "list_volumes", err)
+			return
+		}
+		c.JSON(http.StatusOK, model.VolumesResponse{Volumes: volumes})
+	}
+}
+
+// GetVolume returns a volume with its driver options and status
+func GetVolume(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := volumeParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelRead)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		volume, err := sshClient.GetVolume(c.Request.Context(), name)
+		if errors.Is(err, ssh.ErrVolumeNotFound) {
+			apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "volume_not_found", name))
+			return
+		}
+		if err != nil {
+			sshFailed(c, server.ID, "inspect_volume", err)
+			return
+		}
+		c.JSON(http.StatusOK, volume)
+	}
+}
+
+// DeleteVolume removes a volume with its data. It requires full access, and volumes mounted by
+// a container, even a stopped one, are refused with a conflict naming the containers.
+func DeleteVolume(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := volumeParam(c)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		usedBy, err := sshClient.RemoveVolume(c.Request.Context(), name)
+		switch {
+		case errors.Is(err, ssh.ErrVolumeNotFound):
+			apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "volume_not_found", name))
+			return
+		case errors.Is(err, ssh.ErrVolumeInUse):
+			apierror.AbortMessage(c, apierror.Conflict, apierror.T(c, "volume_in_use", name, strings.Join(usedBy, ", ")))
+			return
+		case err != nil:
+			sshFailed(c, server.ID, "remove_volume", err)
+			return
+		}
+		auditEvent(c, db, server.ID, "removed volume %s on %s", name, server.Name)
+		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("volume %s removed", name)})
+	}
+}
+
+// PruneVolumes removes the volumes no container mounts, or with dry_run lists them. It requires
+// full access.
+func PruneVolumes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.VolumePruneRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Invalid(c, err)
+			return
+		}
+		for _, label := range req.Labels {
+			if !pruneLabel.MatchString(label) {
+				apierror.AbortField(c, apierror.ValidationFailed, "labels", apierror.T(c, "prune_label_invalid", label))
+				return
+			}
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelFull)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		prune := model.PruneRequest{Types: []string{model.PruneVolumes}, Labels: req.Labels, All: req.All, DryRun: req.DryRun}
+		var result model.PruneTypeResult
+		if req.DryRun {
+			results, err := sshClient.PruneDryRun(c.Request.Context(), prune.Types, prune)
+			if err != nil {
+				sshFailed(c, server.ID, "prune_dry_run", err)
+				return
+			}
+			result = results[0]
+		} else {
+			var err error
+			if result, err = sshClient.Prune(c.Request.Context(), model.PruneVolumes, prune); err != nil {
+				sshFailed(c, server.ID, "prune", err)
+				return
+			}
+			auditEvent(c, db, server.ID, "pruned %d volumes on %s, reclaiming %s",
+				len(result.Items), server.Name, dockerapi.HumanSize(uint64(result.ReclaimedBytes)))
+		}
+		c.JSON(http.StatusOK, model.PruneResult{DryRun: req.DryRun, Types: []model.PruneTypeResult{result}, ReclaimedBytes: result.ReclaimedBytes})
+	}
+}
+
+// maxVolumeFiles caps the entries of a volume directory listing
+const maxVolumeFiles = 5000
+
+// ListVolumeFiles lists a directory of a volume, ?path= inside it, for browsing the data of
+// stopped containers. It requires manage access, as the volume is read on the host or through a
+// helper container rather than a container of the user's. Listings stop at maxVolumeFiles
+// entries.
+func ListVolumeFiles(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := volumeParam(c)
+		if !ok {
+			return
+		}
+		path, ok := cleanContainerPath(c, "path", c.DefaultQuery("path", "/"))
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+		helperImage, _ := config.Get(db, model.ConfigKeyVolumeHelperImage)
+		files, truncated, err := sshClient.ListVolumeFiles(c.Request.Context(), name, helperImage, path, maxVolumeFiles)
+		if !volumeFileOK(c, server.ID, "list_volume_files", name, path, err) {
+			return
+		}
+		c.JSON(http.StatusOK, model.FileListResponse{Path: path, Files: files, Truncated: truncated})
+	}
+}
+
+// GetVolumeFileContent returns the content of a file in a volume, or a part of it, like
+// GetContainerFileContent does for containers. It requires manage access.
+func GetVolumeFileContent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := volumeParam(c)
+		if !ok {
+			return
+		}
+		path := c.Query("path")
+		if path == "" {
+			apierror.AbortField(c, apierror.InvalidRequest, "path", apierror.T(c, "path_required"))
+			return
+		}
+		path, ok = cleanContainerPath(c, "path", path)
+		if !ok {
+			return
+		}
+		encoding := c.Query("encoding")
+		if encoding != "" && encoding != "base64" {
+			apierror.AbortField(c, apierror.ValidationFailed, "encoding", apierror.T(c, "validation_oneof", "encoding", "base64"))
+			return
+		}
+		maxSize := int64(config.GetInt(db, model.ConfigKeyFileContentMax))
+		fileRange, ranged, ok := parseFileRange(c, maxSize)
+		if !ok {
+			return
+		}
+		server, ok := authorizeServerParam(c, db, model.AccessLevelManage)
+		if !ok {
+			return
+		}
+		sshClient, ok := connectServer(c, server)
+		if !ok {
+			return
+		}
+
+		helperImage, _ := config.Get(db, model.ConfigKeyVolumeHelperImage)
+		resp := model.FileContentResponse{Path: path}
+		// Without a range the first maxSize bytes are read, which is the whole file unless it
+		// is too large to return
+		content, offset, size, err := sshClient.ReadVolumeFileRange(c.Request.Context(), name, helperImage, path, fileRange, maxSize)
+		resp.Offset, resp.TotalSize = offset, size
+		if errors.Is(err, ssh.ErrRangeNotSatisfiable) {
+			apierror.AbortField(c, apierror.RangeNotSatisfiable, "offset", apierror.T(c, "file_range_offset", fileRange.Offset, size))
+			return
+		}
+		if !volumeFileOK(c, server.ID, "read_volume_file", name, path, err) {
+			return
+		}
+		if !ranged && size > maxSize {
+			resp.Size = size
+			resp.IsBinary, resp.TooLarge = true, true
+			resp.Hint = apierror.T(c, "file_too_large_hint", strconv.FormatInt(maxSize, 10))
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		writeFileContent(c, resp, content, ranged, encoding)
+	}
+}
+
+// volumeFileOK reports whether reading a volume's files succeeded, otherwise responding with the
+// error matching err
+func volumeFileOK(c *gin.Context, serverID uint, op, volume, path string, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ssh.ErrVolumeNotFound):
+		apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "volume_not_found", volume))
+	case errors.Is(err, ssh.ErrFileNotFound):
+		apierror.AbortMessage(c, apierror.NotFound, apierror.T(c, "file_not_found", path))
+	case errors.Is(err, ssh.ErrNotAFile):
+		apierror.AbortField(c, apierror.InvalidRequest, "path", apierror.T(c, "path_not_a_file"))
+	case errors.Is(err, ssh.ErrNotADirectory):
+		apierror.AbortField(c, apierror.InvalidRequest, "path", apierror.T(c, "path_not_a_directory"))
+	default:
+		sshFailed(c, serverID, op, err)
+	}
+	return false
+}
+
+// volumeParam validates the ":name" parameter. On failure the error response has been written.
+func volumeParam(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	if !dockerapi.ValidVolumeName(name) {
+		apierror.AbortField(c, apierror.ValidationFailed, "name", apierror.T(c, "validation_invalid", "name"))
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// WebhookInput creates or updates a webhook. A missing secret keeps the stored one and an
+// empty one removes it; no events subscribes to all of them.
+type WebhookInput struct {
+	Name     string   `json:"name" binding:"required,max=191"`
+	URL      string   `json:"url" binding:"required"`
+	Secret   *string  `json:"secret"`
+	Events   []string `json:"events"`
+	ServerID *uint    `json:"server_id"`
+	Format   string   `json:"format" binding:"omitempty,oneof=json slack discord"`
+	Enabled  *bool    `json:"enabled"`
+}
+
+// WebhookInfo is a webhook as returned by the API. The secret itself is never returned.
+type WebhookInfo struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	HasSecret bool      `json:"has_secret"`
+	Events    []string  `json:"events"`
+	ServerID  *uint     `json:"server_id"`
+	Format    string    `json:"format"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func webhookInfo(h model.Webhook) WebhookInfo {
+	return WebhookInfo{
+		ID:        h.ID,
+		Name:      h.Name,
+		URL:       h.URL,
+		HasSecret: h.Secret != "",
+		Events:    notify.SplitList(h.Events),
+		ServerID:  h.ServerID,
+		Format:    h.Format,
+		Enabled:   h.Enabled,
+		CreatedAt: h.CreatedAt,
+		UpdatedAt: h.UpdatedAt,
+	}
+}
+
+// ListWebhooks returns every webhook
+func ListWebhooks(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hooks []model.Webhook
+		if err := db.Order("id").Find(&hooks).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		out := make([]WebhookInfo, 0, len(hooks))
+		for _, h := range hooks {
+			out = append(out, webhookInfo(h))
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// CreateWebhook adds a webhook
+func CreateWebhook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook := model.Webhook{Enabled: true}
+		if !bindWebhook(c, db, &hook) {
+			return
+		}
+		if err := db.Create(&hook).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, 0, "added webhook %s", hook.Name)
+		c.JSON(http.StatusCreated, webhookInfo(hook))
+	}
+}
+
+// UpdateWebhook replaces a webhook's settings
+func UpdateWebhook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := loadWebhook(c, db)
+		if !ok {
+			return
+		}
+		if !bindWebhook(c, db, hook) {
+			return
+		}
+		if err := db.Save(hook).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, 0, "updated webhook %s", hook.Name)
+		c.JSON(http.StatusOK, webhookInfo(*hook))
+	}
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func DeleteWebhook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := loadWebhook(c, db)
+		if !ok {
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("webhook_id = ?", hook.ID).Delete(&model.WebhookDelivery{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(hook).Error
+		})
+		if err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		auditEvent(c, db, 0, "deleted webhook %s", hook.Name)
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+	}
+}
+
+// TestWebhook sends a test event right away and returns the recorded delivery, failed or not
+func TestWebhook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := loadWebhook(c, db)
+		if !ok {
+			return
+		}
+		ev := notify.Event{Type: notify.Test, Actor: c.GetString("username")}
+		msg, err := notify.Render(ev)
+		if err != nil {
+			apierror.AbortCause(c, apierror.Internal, err)
+			return
+		}
+		delivery, _ := notify.DeliverWebhook(db, *hook, ev, msg, 1)
+		c.JSON(http.StatusOK, delivery)
+	}
+}
+
+// ListWebhookDeliveries returns the webhook's recent deliveries, newest first
+func ListWebhookDeliveries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := loadWebhook(c, db)
+		if !ok {
+			return
+		}
+		deliveries := []model.WebhookDelivery{}
+		if err := db.Where("webhook_id = ?", hook.ID).Order("id DESC").Find(&deliveries).Error; err != nil {
+			apierror.AbortCause(c, apierror.DatabaseError, err)
+			return
+		}
+		c.JSON(http.StatusOK, deliveries)
+	}
+}
+
+// bindWebhook validates the request body and applies it to hook
+func bindWebhook(c *gin.Context, db *gorm.DB, hook *model.Webhook) bool {
+	var input WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Invalid(c, err)
+		return false
+	}
+	if u, err := url.Parse(input.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		apierror.AbortField(c, apierror.ValidationFailed, "url", apierror.T(c, "webhook_url"))
+		return false
+	}
+	events := make([]string, 0, len(input.Events))
+	for _, e := range input.Events {
+		if !notify.ValidEventType(e) {
+			apierror.AbortField(c, apierror.ValidationFailed, "events", apierror.T(c, "webhook_event", e))
+			return false
+		}
+		events = append(events, e)
+	}
+	if input.ServerID != nil {
+		if _, ok := loadServer(c, db, *input.ServerID); !ok {
+			return false
+		}
+	}
+
+	hook.Name = input.Name
+	hook.URL = input.URL
+	hook.Events = strings.Join(events, ",")
+	hook.ServerID = input.ServerID
+	hook.Format = input.Format
+	if hook.Format == "" {
+		hook.Format = model.WebhookFormatJSON
+	}
+	if input.Secret != nil {
+		hook.Secret = *input.Secret
+	}
+	if input.Enabled != nil {
+		hook.Enabled = *input.Enabled
+	}
+	return true
+}
+
+// loadWebhook fetches the webhook named by the ":id" route parameter
+func loadWebhook(c *gin.Context, db *gorm.DB) (*model.Webhook, bool) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return nil, false
+	}
+	var hook model.Webhook
+	if err := db.First(&hook, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.NotFound)
+			return nil, false
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return nil, false
+	}
+	return &hook, true
+}
@@ -1,10 +1,11 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"docker-pulse/internal/apierror"
 	"docker-pulse/internal/model"
 	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -15,31 +16,36 @@ import (
 // AuthMiddleware validates JWT token and attaches user info to context
 func AuthMiddleware(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := getToken(c)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
+		tokenString, ok := getToken(c)
+		if !ok {
+			apierror.Abort(c, apierror.Unauthorized)
 			return
 		}
 
 		claims, err := parseToken(tokenString, jwtSecret)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
+			// Expired tokens and tokens signed with a previous secret (after a rotation) just need a new login
+			if errors.Is(err, jwt.ErrTokenExpired) || errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+				apierror.AbortCause(c, apierror.SessionExpired, err)
+				return
+			}
+			apierror.AbortCause(c, apierror.InvalidToken, err)
 			return
 		}
 
 		// Verify TokenVersion
 		var user model.User
-		if err := db.Select("token_version").First(&user, claims.UserID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
-			c.Abort()
+		if err := db.Select("token_version", "language").First(&user, claims.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Abort(c, apierror.InvalidToken)
+				return
+			}
+			apierror.AbortCause(c, apierror.DatabaseError, err)
 			return
 		}
 
 		if user.TokenVersion != claims.TokenVersion {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "session expired, please login again"})
-			c.Abort()
+			apierror.Abort(c, apierror.SessionExpired)
 			return
 		}
 
@@ -47,6 +53,7 @@ func AuthMiddleware(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		c.Set(apierror.LanguageKey, user.Language)
 
 		c.Next()
 	}
@@ -57,14 +64,16 @@ func RoleCheck(requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("role")
 		if !exists {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user role not found in context"})
-			c.Abort()
+			apierror.Abort(c, apierror.Unauthorized)
 			return
 		}
 
-		if userRole.(string) != requiredRole {
-			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("requires %s role", requiredRole)})
-			c.Abort()
+		if role, _ := userRole.(string); role != requiredRole {
+			if requiredRole == "admin" {
+				apierror.Abort(c, apierror.AdminRequired)
+				return
+			}
+			apierror.AbortMessage(c, apierror.PermissionDenied, apierror.T(c, "role_required", requiredRole))
 			return
 		}
 
@@ -72,22 +81,42 @@ func RoleCheck(requiredRole string) gin.HandlerFunc {
 	}
 }
 
-func getToken(c *gin.Context) (string, error) {
+// BearerToken requires the static token in the Authorization header. An empty token allows every request.
+func BearerToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		got, ok := getToken(c)
+		if !ok {
+			apierror.Abort(c, apierror.Unauthorized)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			apierror.Abort(c, apierror.InvalidToken)
+			return
+		}
+		c.Next()
+	}
+}
+
+func getToken(c *gin.Context) (string, bool) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader != "" {
 		parts := strings.Split(authHeader, " ")
 		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
-			return parts[1], nil
+			return parts[1], true
 		}
 	}
 
 	// Try query parameter
 	token := c.Query("token")
 	if token != "" {
-		return token, nil
+		return token, true
 	}
 
-	return "", errors.New("authorization token required")
+	return "", false
 }
 
 // Claims represents the JWT claims
@@ -124,20 +153,3 @@ func parseToken(tokenString, jwtSecret string) (*Claims, error) {
 
 	return claims, nil
 }
-
-// CORSMiddleware sets up CORS headers (moved from main.go for better organization)
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	}
-}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const corsAllowedMethods = "GET, POST, PUT, DELETE, OPTIONS"
+
+// corsExposedHeaders are the response headers scripts on other origins may read
+const corsExposedHeaders = logging.RequestIDHeader + ", ETag, X-Cached, X-Fetched-At"
+
+// CORSMiddleware allows cross-origin requests only from the configured origins.
+// With no origins configured no CORS headers are sent, so only the same-origin SPA can use the API.
+func CORSMiddleware(db *gorm.DB) gin.HandlerFunc {
+	var origins atomic.Value
+	value, _ := config.Get(db, model.ConfigKeyCORSOrigins)
+	origins.Store(parseOrigins(value))
+	config.OnChange(model.ConfigKeyCORSOrigins, func(value string) {
+		origins.Store(parseOrigins(value))
+	})
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		allowed := originAllowed(origin, origins.Load().([]string))
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if allowed {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		}
+
+		if preflight {
+			if !allowed {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			if headers := c.GetHeader("Access-Control-Request-Headers"); headers != "" {
+				c.Writer.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			c.Writer.Header().Set("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func parseOrigins(value string) []string {
+	var origins []string
+	for _, o := range strings.Split(value, ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			origins = append(origins, strings.ToLower(o))
+		}
+	}
+	return origins
+}
+
+// originAllowed matches an origin exactly or against a "scheme://*.domain" pattern
+func originAllowed(origin string, allowed []string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		if pattern == origin {
+			return true
+		}
+		scheme, rest, ok := strings.Cut(pattern, "://*.")
+		if !ok || !strings.HasPrefix(origin, scheme+"://") {
+			continue
+		}
+		host := strings.TrimPrefix(origin, scheme+"://")
+		if strings.HasSuffix(host, "."+rest) {
+			return true
+		}
+	}
+	return false
+}
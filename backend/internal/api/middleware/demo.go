@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/demo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Demo enforces demo mode for every route: requests other than GET, HEAD and OPTIONS are
+// rejected with 403, except logging in at loginPath, and server addresses and SSH usernames are
+// masked in JSON responses. It does nothing while demo mode is off. Terminal input is filtered by
+// the terminal handler, since it arrives over the WebSocket rather than as requests.
+func Demo(loginPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !demo.Enabled() {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if c.Request.Method != http.MethodPost || c.Request.URL.Path != loginPath {
+				apierror.Abort(c, apierror.DemoMode)
+				return
+			}
+		}
+		if c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
+		w := &maskWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			w.finish()
+		}()
+		c.Next()
+	}
+}
+
+// maskWriter holds back JSON responses so that demo.MaskJSON can rewrite them. Other responses
+// pass through as they are written.
+type maskWriter struct {
+	gin.ResponseWriter
+	status  int
+	decided bool
+	json    bool
+	buf     bytes.Buffer
+}
+
+func (w *maskWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *maskWriter) WriteHeaderNow() {
+	// Headers are sent once the body is known
+}
+
+func (w *maskWriter) Status() int {
+	if !w.decided {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *maskWriter) Written() bool {
+	return w.decided || w.ResponseWriter.Written()
+}
+
+func (w *maskWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.json = isJSON(w.Header().Get("Content-Type"))
+		if !w.json {
+			w.ResponseWriter.WriteHeader(w.status)
+		}
+	}
+	if w.json {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *maskWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *maskWriter) Flush() {
+	if w.decided && !w.json {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish sends a held back JSON response with its addresses masked
+func (w *maskWriter) finish() {
+	if !w.decided {
+		if !w.ResponseWriter.Written() {
+			w.ResponseWriter.WriteHeader(w.status)
+		}
+		return
+	}
+	if !w.json {
+		return
+	}
+	body := demo.MaskJSON(w.buf.Bytes())
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
+
+func isJSON(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(contentType), "application/json")
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var gzipPool = sync.Pool{
+	New: func() interface{} {
+		gz, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return gz
+	},
+}
+
+// Gzip compresses responses of at least minSize bytes for clients that accept gzip. Smaller
+// responses, WebSocket upgrades and content that is already compressed are sent as is.
+func Gzip(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.IsWebsocket() || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if !AcceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		w := &gzipWriter{ResponseWriter: c.Writer, minSize: minSize, status: http.StatusOK}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			if rec := recover(); rec != nil {
+				// Close a started stream; otherwise leave the response to the recovery middleware
+				if w.decided {
+					w.finish()
+				}
+				panic(rec)
+			}
+			w.finish()
+		}()
+		c.Next()
+	}
+}
+
+// AcceptsGzip reports whether an Accept-Encoding header allows a gzip response
+func AcceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		params = strings.ReplaceAll(params, " ", "")
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Compressible reports whether content of the given type benefits from gzip. Images other than
+// SVG, fonts and archives are already compressed.
+func Compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "application/x-yaml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// gzipWriter buffers the start of a response until it is known whether the body reaches the
+// size threshold, then either compresses or passes it through unchanged.
+type gzipWriter struct {
+	gin.ResponseWriter
+	minSize int
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) WriteHeaderNow() {
+	// Headers are sent once the encoding is decided
+}
+
+func (w *gzipWriter) Status() int {
+	if !w.decided {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *gzipWriter) Written() bool {
+	return w.decided || len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.minSize {
+			return len(data), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.gz != nil {
+		return w.gz.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(len(w.buf) >= w.minSize); err != nil {
+			return
+		}
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide sends the headers and the buffered body, compressing when the response qualifies
+func (w *gzipWriter) decide(large bool) error {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	compress := large && h.Get("Content-Encoding") == "" && Compressible(h.Get("Content-Type")) &&
+		w.status != http.StatusNoContent && w.status != http.StatusNotModified
+	if compress {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = gzipPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// finish writes a response that stayed below the threshold and closes the gzip stream
+func (w *gzipWriter) finish() {
+	if !w.decided {
+		if len(w.buf) == 0 && !w.ResponseWriter.Written() {
+			// Let gin write the status of an empty response as usual
+			w.ResponseWriter.WriteHeader(w.status)
+			w.decided = true
+			return
+		}
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(io.Discard)
+		gzipPool.Put(w.gz)
+		w.gz = nil
+	}
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testMinSize = 1024
+
+// gzipRouter serves a large JSON body, a small one, a PNG and a WebSocket endpoint behind Gzip
+func gzipRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Gzip(testMinSize))
+	large := strings.Repeat(`{"name":"container"},`, 200)
+	r.GET("/large", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(large))
+	})
+	r.GET("/small", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	r.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", []byte(large))
+	})
+	r.GET("/ws", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/plain", []byte(large))
+	})
+	return r
+}
+
+func serveGzip(t *testing.T, r *gin.Engine, path string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d, want 200", path, w.Code)
+	}
+	return w
+}
+
+func TestGzipCompressesLargeResponses(t *testing.T) {
+	r := gzipRouter()
+	w := serveGzip(t, r, "/large", http.Header{"Accept-Encoding": {"gzip, deflate"}})
+
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Fatalf("Vary = %q, want Accept-Encoding", got)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("decompress: %v", err)
+	}
+	if want := strings.Repeat(`{"name":"container"},`, 200); string(body) != want {
+		t.Fatalf("decoded body has %d bytes, want the %d bytes sent", len(body), len(want))
+	}
+}
+
+func TestGzipIdentity(t *testing.T) {
+	r := gzipRouter()
+	large := strings.Repeat(`{"name":"container"},`, 200)
+	for _, tc := range []struct {
+		name   string
+		path   string
+		header http.Header
+		want   string
+	}{
+		{"no Accept-Encoding", "/large", nil, large},
+		{"gzip refused", "/large", http.Header{"Accept-Encoding": {"gzip;q=0, identity"}}, large},
+		{"below the threshold", "/small", http.Header{"Accept-Encoding": {"gzip"}}, `{"ok":true}`},
+		{"already compressed", "/image", http.Header{"Accept-Encoding": {"gzip"}}, large},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveGzip(t, r, tc.path, tc.header)
+			if got := w.Header().Get("Content-Encoding"); got != "" {
+				t.Fatalf("Content-Encoding = %q, want none", got)
+			}
+			if w.Body.String() != tc.want {
+				t.Fatalf("body has %d bytes, want the %d bytes sent", w.Body.Len(), len(tc.want))
+			}
+		})
+	}
+}
+
+func TestGzipSkipsWebSocketUpgrades(t *testing.T) {
+	r := gzipRouter()
+	w := serveGzip(t, r, "/ws", http.Header{
+		"Accept-Encoding": {"gzip"},
+		"Connection":      {"Upgrade"},
+		"Upgrade":         {"websocket"},
+	})
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding = %q, want none on a WebSocket upgrade", got)
+	}
+	if got := w.Header().Get("Vary"); got != "" {
+		t.Fatalf("Vary = %q, want none on a WebSocket upgrade", got)
+	}
+}
+
+func TestGzipStreamFlushedBeforeThreshold(t *testing.T) {
+	// A stream that flushes a small first chunk, as log follow does, is sent as is
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Gzip(testMinSize))
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		c.Writer.WriteString("line 1\n")
+		c.Writer.Flush()
+		c.Writer.WriteString(strings.Repeat("line\n", 500))
+	})
+	w := serveGzip(t, r, "/stream", http.Header{"Accept-Encoding": {"gzip"}})
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding = %q, want none once the stream has started plain", got)
+	}
+	if !strings.HasPrefix(w.Body.String(), "line 1\n") || w.Body.Len() != len("line 1\n")+5*500 {
+		t.Fatalf("stream body has %d bytes, want %d", w.Body.Len(), len("line 1\n")+5*500)
+	}
+}
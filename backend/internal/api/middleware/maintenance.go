@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/maintenance"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Maintenance rejects requests that change anything with a 503 while maintenance mode is on.
+// Reads stay available, and admins are let through so they can finish the work and turn
+// maintenance mode off. Use it after AuthMiddleware.
+func Maintenance() gin.HandlerFunc {
+	return maintenanceCheck(false)
+}
+
+// MaintenanceAll is Maintenance for routes that start an operation with a GET request, such as
+// the terminal WebSocket
+func MaintenanceAll() gin.HandlerFunc {
+	return maintenanceCheck(true)
+}
+
+func maintenanceCheck(all bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if !all {
+				c.Next()
+				return
+			}
+		}
+		status := maintenance.Current()
+		if !status.Enabled || c.GetString("role") == "admin" {
+			c.Next()
+			return
+		}
+		if status.Until != nil {
+			if wait := time.Until(*status.Until); wait > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			}
+		}
+		message := status.Message
+		if message == "" {
+			message = apierror.T(c, string(apierror.Maintenance))
+		}
+		apierror.AbortMessage(c, apierror.Maintenance, message)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"docker-pulse/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// Requests counts API requests by method, route template and status code
+	Requests = metrics.NewCounterVec("dockermanager_http_requests_total",
+		"HTTP requests by method, route and status.", "method", "route", "status")
+	// RequestDuration records request latency by method and route template
+	RequestDuration = metrics.NewHistogramVec("dockermanager_http_request_duration_seconds",
+		"Duration of HTTP requests.", metrics.DefaultBuckets, "method", "route")
+)
+
+func init() {
+	metrics.NewCounterFunc("dockermanager_panics_total", "Handler panics recovered since startup.", func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(Panics())}}
+	})
+}
+
+// Metrics records the count and latency of every request. Routes are labeled with their
+// template, such as /api/v1/servers/:id, so label cardinality stays bounded. Register it
+// before Recovery so that recovered panics are counted with their 500 status.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		Requests.Inc(method, route, strconv.Itoa(c.Writer.Status()))
+		RequestDuration.Observe(time.Since(start).Seconds(), method, route)
+	}
+}
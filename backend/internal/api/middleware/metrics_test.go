@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsLabelRequestsByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/metrics-test/servers/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/metrics-test/servers/:id", func(c *gin.Context) { c.Status(http.StatusForbidden) })
+
+	// The counters are global, so only what these requests add is looked at
+	counts := map[string]float64{}
+	for _, s := range Requests.Samples() {
+		counts[strings.Join(s.Labels, " ")] = s.Value
+	}
+	observations := map[string]uint64{}
+	for _, s := range RequestDuration.Summaries() {
+		observations[strings.Join(s.Labels, " ")] = s.Count
+	}
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/metrics-test/servers/1"},
+		{http.MethodGet, "/metrics-test/servers/2"},
+		{http.MethodPost, "/metrics-test/servers/3"},
+		{http.MethodGet, "/metrics-test/nothing/here"},
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	wantCounts := map[string]float64{
+		"GET /metrics-test/servers/:id 200":  2,
+		"POST /metrics-test/servers/:id 403": 1,
+		"GET unmatched 404":                  1,
+	}
+	for _, s := range Requests.Samples() {
+		key := strings.Join(s.Labels, " ")
+		if strings.Contains(key, "/metrics-test/") && !strings.Contains(key, ":id") {
+			t.Errorf("counter labeled with the raw path: %q", key)
+		}
+		if want, ok := wantCounts[key]; ok && s.Value-counts[key] != want {
+			t.Errorf("counter %q grew by %v, want %v", key, s.Value-counts[key], want)
+		}
+		delete(wantCounts, key)
+	}
+	for key := range wantCounts {
+		t.Errorf("no counter labeled %q", key)
+	}
+
+	wantObservations := map[string]uint64{
+		"GET /metrics-test/servers/:id":  2,
+		"POST /metrics-test/servers/:id": 1,
+		"GET unmatched":                  1,
+	}
+	for _, s := range RequestDuration.Summaries() {
+		key := strings.Join(s.Labels, " ")
+		if strings.Contains(key, "/metrics-test/") && !strings.Contains(key, ":id") {
+			t.Errorf("histogram labeled with the raw path: %q", key)
+		}
+		if want, ok := wantObservations[key]; ok && s.Count-observations[key] != want {
+			t.Errorf("histogram %q grew by %d, want %d", key, s.Count-observations[key], want)
+		}
+		delete(wantObservations, key)
+	}
+	for key := range wantObservations {
+		t.Errorf("no histogram labeled %q", key)
+	}
+}
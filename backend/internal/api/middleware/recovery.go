@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+var panics atomic.Int64
+
+// Panics returns the number of handler panics recovered since startup
+func Panics() int64 {
+	return panics.Load()
+}
+
+// Recovery turns a panic in a handler into an internal_error response and logs the stack trace
+// with the request ID and user
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				// Deliberate abort of the connection, let net/http handle it quietly
+				panic(rec)
+			}
+			panics.Add(1)
+			logging.L(c).Error("panic recovered", "panic", rec, "stack", string(debug.Stack()))
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			apierror.Abort(c, apierror.Internal)
+		}()
+		c.Next()
+	}
+}
+
+// RecoverHTTP is Recovery for plain handlers outside gin, such as the static file server
+func RecoverHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			panics.Add(1)
+			requestID := logging.NewRequestID()
+			slog.Error("panic recovered", "request_id", requestID, "method", r.Method, "path", r.URL.Path,
+				"panic", rec, "stack", string(debug.Stack()))
+
+			body, _ := json.Marshal(apierror.Envelope{Error: apierror.Body{
+				Code:      apierror.Internal,
+				Message:   apierror.Message(apierror.ParseAcceptLanguage(r.Header.Get("Accept-Language")), apierror.Internal),
+				RequestID: requestID,
+			}})
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set(logging.RequestIDHeader, requestID)
+			w.WriteHeader(apierror.Status(apierror.Internal))
+			w.Write(body)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout cancels the request context after d and, if the handler has not finished by then,
+// answers with a 504 timeout error that names the server from the ":id" route parameter.
+// The handler's response is buffered until it returns and discarded after a timeout, so the
+// middleware is not meant for WebSocket or streaming routes.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		serverID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+		body := apierror.Body{
+			Code:      apierror.Timeout,
+			Message:   apierror.Message(apierror.Language(c), apierror.Timeout),
+			RequestID: logging.RequestID(c),
+			ServerID:  uint(serverID),
+		}
+
+		logger := logging.L(c)
+		w := &timeoutWriter{ResponseWriter: c.Writer, header: c.Writer.Header().Clone()}
+		c.Writer = w
+		done := make(chan struct{})
+		watcherDone := make(chan struct{})
+		go func() {
+			defer close(watcherDone)
+			select {
+			case <-done:
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					w.timeout(body)
+					logger.Warn("request timed out", "server_id", serverID, "timeout", d)
+				}
+			}
+		}()
+
+		defer func() {
+			close(done)
+			<-watcherDone
+			c.Writer = w.ResponseWriter
+			if rec := recover(); rec != nil {
+				// Drop the buffered response so the recovery middleware can answer
+				panic(rec)
+			}
+			w.finish()
+		}()
+		c.Next()
+	}
+}
+
+// timeoutWriter buffers the handler's response so that the timeout response can be written
+// from another goroutine without racing the handler
+type timeoutWriter struct {
+	gin.ResponseWriter
+	header http.Header
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+// timeout writes the timeout response and discards whatever the handler writes from now on
+func (w *timeoutWriter) timeout(body apierror.Body) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+	data, _ := json.Marshal(apierror.Envelope{Error: body})
+	w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.ResponseWriter.WriteHeader(apierror.Status(apierror.Timeout))
+	w.ResponseWriter.Write(data)
+	w.ResponseWriter.Flush()
+}
+
+// finish copies the buffered response to the client when the handler finished in time
+func (w *timeoutWriter) finish() {
+	if w.timedOut {
+		return
+	}
+	h := w.ResponseWriter.Header()
+	for k := range h {
+		delete(h, k)
+	}
+	for k, v := range w.header {
+		h[k] = v
+	}
+	if w.status == 0 && w.buf.Len() == 0 {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.Status())
+	w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.buf.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {}
+
+func (w *timeoutWriter) Flush() {}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.timedOut || w.status != 0 || w.buf.Len() > 0
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Len()
+}
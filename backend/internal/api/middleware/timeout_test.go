@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeoutAnswersWhileHandlerIsStalled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// The handler stands in for an SSH call that never returns, not even when its context ends
+	stalled := make(chan struct{})
+	r := gin.New()
+	r.GET("/servers/:id/stats", Timeout(200*time.Millisecond), func(c *gin.Context) {
+		<-stalled
+		c.JSON(http.StatusOK, gin.H{"late": true})
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	defer close(stalled)
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/servers/7/stats")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("timeout answered after %v, want about 200ms", elapsed)
+	}
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", resp.StatusCode)
+	}
+	// The connection stays open until the handler returns, so only the first document is read
+	var env apierror.Envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if env.Error.Code != apierror.Timeout || env.Error.ServerID != 7 {
+		t.Fatalf("error = %+v, want a timeout naming server 7", env.Error)
+	}
+}
+
+func TestTimeoutPassesFastResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/servers/:id/stats", Timeout(time.Second), func(c *gin.Context) {
+		c.Header("X-Test", "kept")
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/servers/7/stats", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != `{"ok":true}` || w.Header().Get("X-Test") != "kept" {
+		t.Fatalf("response = %d %q (X-Test %q), want the handler's", w.Code, w.Body.String(), w.Header().Get("X-Test"))
+	}
+}
@@ -0,0 +1,310 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BasePath is the prefix of every documented route
+const BasePath = "/api/v1"
+
+// Operation documents one API route
+type Operation struct {
+	Method  string
+	Path    string // gin syntax, relative to BasePath
+	Tag     string
+	Summary string
+	// Admin routes require the admin role, Public routes need no token
+	Admin  bool
+	Public bool
+	Query  []Param
+	// Request and Response are sample values whose types describe the JSON bodies
+	Request  interface{}
+	Response interface{}
+	// Status is the success status code, 200 when zero
+	Status int
+	// ContentType overrides the response media type for non-JSON responses
+	ContentType string
+}
+
+// Param documents a query parameter
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Message is the body of responses that only confirm an action
+type Message struct {
+	Message string `json:"message"`
+}
+
+var (
+	specMu   sync.Mutex
+	specJSON = map[string][]byte{}
+)
+
+// Spec returns the OpenAPI 3 document as JSON for an API mounted below prefix, the panel's base path
+func Spec(prefix string) []byte {
+	specMu.Lock()
+	defer specMu.Unlock()
+	spec, ok := specJSON[prefix]
+	if !ok {
+		spec, _ = json.Marshal(build(prefix))
+		specJSON[prefix] = spec
+	}
+	return spec
+}
+
+// Handler serves the OpenAPI document for an API mounted below prefix
+func Handler(prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", Spec(prefix))
+	}
+}
+
+// DocsHandler serves a Swagger UI page for the document. The token query parameter used to
+// open the page is reused for the spec and for "try it out" requests.
+func DocsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+	}
+}
+
+// Missing returns the registered API routes below prefix that have no documented operation
+func Missing(routes gin.RoutesInfo, prefix string) []string {
+	documented := make(map[string]bool, len(operations))
+	for _, op := range operations {
+		documented[op.Method+" "+prefix+BasePath+op.Path] = true
+	}
+	var missing []string
+	for _, r := range routes {
+		if strings.HasPrefix(r.Path, prefix+BasePath+"/") && !documented[r.Method+" "+r.Path] {
+			missing = append(missing, r.Method+" "+r.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+func build(prefix string) map[string]interface{} {
+	b := &builder{schemas: map[string]interface{}{}}
+	errorRef := b.schema(reflect.TypeOf(apierror.Envelope{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range operations {
+		path := pathParam.ReplaceAllString(op.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+
+		var params []interface{}
+		for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+			typ := "string"
+			if m[1] == "id" {
+				typ = "integer"
+			}
+			params = append(params, map[string]interface{}{
+				"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": typ},
+			})
+		}
+		for _, q := range op.Query {
+			params = append(params, map[string]interface{}{
+				"name": q.Name, "in": "query", "required": q.Required, "description": q.Description,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		status := http.StatusOK
+		if op.Status != 0 {
+			status = op.Status
+		}
+		success := map[string]interface{}{"description": "OK"}
+		switch {
+		case op.ContentType != "":
+			success["content"] = map[string]interface{}{op.ContentType: map[string]interface{}{}}
+		case op.Response != nil:
+			success["content"] = jsonContent(b.schema(reflect.TypeOf(op.Response)))
+		}
+		responses := map[string]interface{}{
+			strconv.Itoa(status): success,
+			"default":            map[string]interface{}{"description": "Error", "content": jsonContent(errorRef)},
+		}
+
+		operation := map[string]interface{}{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"responses":   responses,
+		}
+		if op.Admin {
+			operation["description"] = "Requires the admin role."
+		}
+		if op.Public {
+			operation["security"] = []interface{}{}
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(b.schema(reflect.TypeOf(op.Request))),
+			}
+		}
+		paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "DockerManager API",
+			"version": "1.0.7",
+		},
+		"servers":  []interface{}{map[string]interface{}{"url": prefix + BasePath}},
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+		"paths":    paths,
+		"components": map[string]interface{}{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// operationID derives a stable identifier such as getServersIdContainers
+func operationID(op Operation) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(op.Method))
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' || r == '-' || r == ':' }) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// builder turns Go types into JSON schemas, collecting named structs as components
+type builder struct {
+	schemas map[string]interface{}
+}
+
+func (b *builder) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case deletedAtType:
+		return map[string]interface{}{"type": "string", "format": "date-time", "nullable": true}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		if _, ok := b.schemas[t.Name()]; !ok {
+			b.schemas[t.Name()] = nil // reserve the name so recursive types terminate
+			b.schemas[t.Name()] = b.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (b *builder) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	b.fields(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func (b *builder) fields(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// Embedded structs without a JSON name are flattened, as encoding/json does
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			b.fields(f.Type, props)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = b.schema(f.Type)
+	}
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>DockerManager API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    const token = new URLSearchParams(location.search).get("token") || "";
+    SwaggerUIBundle({
+      url: "openapi.json?token=" + encodeURIComponent(token),
+      dom_id: "#swagger-ui",
+      requestInterceptor: (req) => {
+        if (token) req.headers["Authorization"] = "Bearer " + token;
+        return req;
+      },
+    });
+  </script>
+</body>
+</html>
+`
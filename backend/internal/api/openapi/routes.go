@@ -0,0 +1,354 @@
+package openapi
+
+import (
+	"net/http"
+
+	"docker-pulse/internal/api/handler"
+	"docker-pulse/internal/bundle"
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+	"docker-pulse/internal/version"
+)
+
+// Request and response bodies that handlers declare inline
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	Token string `json:"token"`
+	Role  string `json:"role"`
+}
+
+type ServerInput struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	// AuthMode is "password", "key" or "docker-tls", whose Secret is a PEM bundle of the client
+	// certificate, its key and the CA certificate
+	AuthMode string `json:"auth_mode"`
+	Secret   string `json:"secret"`
+	// KeyPassphrase decrypts an encrypted private key
+	KeyPassphrase string `json:"key_passphrase,omitempty"`
+
+	// Optional jump host the server is reached through. An empty JumpIP removes it on updates.
+	JumpIP            string `json:"jump_ip,omitempty"`
+	JumpPort          int    `json:"jump_port,omitempty"`
+	JumpUsername      string `json:"jump_username,omitempty"`
+	JumpAuthMode      string `json:"jump_auth_mode,omitempty"`
+	JumpSecret        string `json:"jump_secret,omitempty"`
+	JumpKeyPassphrase string `json:"jump_key_passphrase,omitempty"`
+
+	// UseSudo runs docker commands through sudo, SudoPassword answers its prompt when needed
+	UseSudo      bool   `json:"use_sudo,omitempty"`
+	SudoPassword string `json:"sudo_password,omitempty"`
+
+	// NetInterface is the interface network rates are read from, the default route's when empty
+	NetInterface string `json:"net_interface,omitempty"`
+
+	// ConnectionMode is "cli" (the default), running docker commands over SSH, or "api", using the
+	// Docker Engine API on the host's socket forwarded through SSH
+	ConnectionMode string `json:"connection_mode,omitempty"`
+
+	// Description is a line on what the host is for, Notes longer markdown. On updates given ones
+	// replace the stored ones, empty ones remove them.
+	Description string `json:"description,omitempty"`
+	Notes       string `json:"notes,omitempty"`
+}
+
+// ServerConnectionTestInput is a server to test connecting to: the fields of ServerInput, or
+// ServerID with fields replacing the stored server's
+type ServerConnectionTestInput struct {
+	ServerID uint `json:"server_id,omitempty"`
+	ServerInput
+}
+
+type HistoryPoint struct {
+	Name    string  `json:"name"`
+	Latency float64 `json:"latency"`
+}
+
+type UserInput struct {
+	Username   string `json:"username"`
+	Password   string `json:"password,omitempty"`
+	Role       string `json:"role"`
+	TelegramID int64  `json:"telegram_id"`
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type PasswordReset struct {
+	NewPassword string `json:"new_password"`
+}
+
+type PermissionsInput struct {
+	Permissions []PermissionInput `json:"permissions"`
+}
+
+type PermissionInput struct {
+	ServerID    uint   `json:"server_id"`
+	AccessLevel string `json:"access_level"`
+}
+
+type TelegramBinding struct {
+	InitData string `json:"init_data"`
+}
+
+// LanguagePreference is an "en" or "zh-CN" language, or empty to follow Accept-Language
+type LanguagePreference struct {
+	Language string `json:"language"`
+}
+
+type TelegramConfig struct {
+	BotToken  string `json:"bot_token"`
+	WebAppURL string `json:"web_app_url"`
+}
+
+type LatencyConfig struct {
+	PingTargets string `json:"ping_targets"`
+}
+
+type BackupList struct {
+	Backups []string `json:"backups"`
+}
+
+type FlushResult struct {
+	Flushed []string `json:"flushed"`
+}
+
+type PortsAvailable struct {
+	Available bool `json:"available"`
+}
+
+// refreshParam documents ?refresh=true on cached list endpoints
+var refreshParam = Param{Name: "refresh", Description: "\"true\" bypasses the cache, at most once per 10 seconds per server; X-Cached and X-Fetched-At report the data age, as do cached and fetched_at in container lists and single servers"}
+
+var operations = []Operation{
+	{Method: http.MethodPost, Path: "/login", Tag: "auth", Summary: "Log in and obtain a JWT", Public: true, Request: LoginRequest{}, Response: LoginResponse{}},
+	{Method: http.MethodGet, Path: "/status", Tag: "status", Summary: "Get the panel status, including maintenance mode", Public: true, Response: handler.PanelStatus{}},
+	{Method: http.MethodGet, Path: "/version", Tag: "status", Summary: "Get the running version and the latest release", Response: version.Info{}},
+
+	{Method: http.MethodGet, Path: "/servers", Tag: "servers", Summary: "List servers visible to the current user", Response: []model.Server{}, Query: []Param{
+		{Name: "refresh", Description: "\"true\" bypasses the cache, at most once per 10 seconds per user; X-Cached and X-Fetched-At report the data age"},
+		{Name: "q", Description: "Only list servers whose name, IP or description contains this, ignoring case"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id", Tag: "servers", Summary: "Get a server", Response: model.ServerDetails{}, Query: []Param{refreshParam}},
+	{Method: http.MethodPost, Path: "/servers", Tag: "servers", Summary: "Create a server", Admin: true, Request: ServerInput{}, Response: model.Server{}, Status: http.StatusCreated},
+	{Method: http.MethodPut, Path: "/servers/:id", Tag: "servers", Summary: "Update a server", Admin: true, Request: ServerInput{}, Response: model.Server{}},
+	{Method: http.MethodDelete, Path: "/servers/:id", Tag: "servers", Summary: "Delete a server", Admin: true, Response: Message{}},
+	{Method: http.MethodGet, Path: "/servers/:id/stats", Tag: "servers", Summary: "Get realtime server stats, including the server's circuit breaker", Response: ssh.ServerStats{}},
+	{Method: http.MethodGet, Path: "/servers/:id/system-info", Tag: "servers", Summary: "Get a server's OS, kernel, architecture and Docker storage setup", Response: model.SystemInfo{}, Query: []Param{
+		{Name: "refresh", Description: "\"true\" reads the details from the server again instead of returning the stored ones"},
+	}},
+	{Method: http.MethodPost, Path: "/servers/test-connection", Tag: "servers", Summary: "Test connecting to a server before saving it, stage by stage: reaching it, authenticating and docker version; takes the create body, or server_id with fields replacing the stored ones; answers within about 10 seconds and stores nothing", Admin: true, Request: ServerConnectionTestInput{}, Response: ssh.Probe{}},
+	{Method: http.MethodPost, Path: "/servers/:id/test-connection", Tag: "servers", Summary: "Test the SSH connection, bypassing the circuit breaker", Admin: true, Response: handler.ConnectionTest{}},
+	{Method: http.MethodPost, Path: "/servers/:id/reset-circuit", Tag: "servers", Summary: "Close the server's circuit breaker", Admin: true, Response: ssh.CircuitState{}},
+	{Method: http.MethodGet, Path: "/servers/:id/agent", Tag: "servers", Summary: "Get whether the server's agent is enrolled and connected", Response: handler.AgentStatus{}},
+	{Method: http.MethodPost, Path: "/servers/:id/agent/token", Tag: "servers", Summary: "Create the server's agent enrollment token, replacing the previous one", Admin: true, Response: handler.AgentToken{}, Status: http.StatusCreated},
+	{Method: http.MethodDelete, Path: "/servers/:id/agent/token", Tag: "servers", Summary: "Revoke the server's agent enrollment token and disconnect its agent", Admin: true, Response: Message{}},
+	{Method: http.MethodGet, Path: "/servers/:id/ports", Tag: "servers", Summary: "List host ports used by containers and other processes", Response: model.PortListResponse{}},
+	{Method: http.MethodPost, Path: "/servers/:id/ports/check", Tag: "servers", Summary: "Check that host port mappings are free, answering 409 on conflicts", Request: model.PortCheckRequest{}, Response: PortsAvailable{}},
+	{Method: http.MethodGet, Path: "/servers/:id/docker/daemon-config", Tag: "servers", Summary: "Read the Docker daemon configuration", Admin: true, Response: model.DaemonConfig{}},
+	{Method: http.MethodPut, Path: "/servers/:id/docker/daemon-config", Tag: "servers", Summary: "Replace the Docker daemon configuration, optionally restarting docker", Admin: true, Request: model.DaemonConfigUpdate{}, Response: model.DaemonConfigResult{}},
+	{Method: http.MethodGet, Path: "/servers/:id/docker/disk-usage", Tag: "servers", Summary: "Get the disk space taken by Docker images, containers, volumes and build cache", Response: model.DockerDiskUsage{}, Query: []Param{
+		{Name: "verbose", Description: "\"true\" also lists every image and volume, largest first"},
+	}},
+	{Method: http.MethodPost, Path: "/servers/:id/docker/prune", Tag: "servers", Summary: "Remove unused Docker objects, or list what would be removed with dry_run", Request: model.PruneRequest{}, Response: model.PruneResult{}},
+	{Method: http.MethodGet, Path: "/servers/stats/history", Tag: "servers", Summary: "Get latency history", Response: []HistoryPoint{}, Query: []Param{
+		{Name: "server_ids", Description: "Comma separated server IDs"},
+		{Name: "targets", Description: "Comma separated ping targets"},
+		{Name: "range", Description: "1H, 24H, 7D or 1M"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/stats/disk-history", Tag: "servers", Summary: "Get a server's disk usage history", Response: []handler.DiskHistoryPoint{}, Query: []Param{
+		{Name: "range", Description: "1H, 24H, 7D or 1M"},
+	}},
+
+	{Method: http.MethodGet, Path: "/servers/:id/containers", Tag: "containers", Summary: "List containers, optionally filtered, sorted, paged and grouped by docker compose project", Response: model.ContainerListResponse{}, Query: []Param{
+		refreshParam,
+		{Name: "group_by", Description: "\"project\" nests the containers under their docker compose project, containers without one under \"standalone\""},
+		{Name: "state", Description: "Only containers in this state, e.g. running or exited"},
+		{Name: "name", Description: "Only containers whose name contains this, ignoring case"},
+		{Name: "image", Description: "Only containers whose image contains this, ignoring case"},
+		{Name: "label", Description: "Only containers with this label, as key or key=value; may be repeated"},
+		{Name: "sort", Description: "name, created or state; docker's order by default"},
+		{Name: "order", Description: "asc, the default, or desc"},
+		{Name: "page", Description: "Page to return, from 1"},
+		{Name: "page_size", Description: "Containers per page, at most 500, 50 when only page is given"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/stats", Tag: "containers", Summary: "List containers with the CPU, memory and I/O usage of the running ones", Response: model.ContainerListResponse{}, Query: []Param{refreshParam}},
+	{Method: http.MethodPost, Path: "/servers/:id/containers", Tag: "containers", Summary: "Create and start a container like docker run -d, pulling its image when missing", Request: model.ContainerCreateRequest{}, Response: model.ContainerCreateResult{}, Status: http.StatusCreated},
+	{Method: http.MethodPost, Path: "/servers/:id/containers/action", Tag: "containers", Summary: "Start, stop, restart, pause, unpause, kill, rename or remove a container, or start pulling its image or updating it, which responds with the task; the body's server_id, if given, must match the path; 404 when a container to kill, remove or update does not exist; 409 when the container's state does not allow the action", Request: model.ContainerActionRequest{}, Response: Message{}},
+	{Method: http.MethodPost, Path: "/servers/:id/containers/batch-action", Tag: "containers", Summary: "Run start, stop, restart, pause, unpause, kill or remove on several containers, reporting each container's outcome", Request: model.BatchActionRequest{}, Response: model.BatchActionResponse{}},
+	{Method: http.MethodGet, Path: "/tasks/:id", Tag: "containers", Summary: "Get the progress and output of an image pull or container update", Response: model.PullTask{}, Query: []Param{
+		{Name: "offset", Description: "Output offset to continue from, the previous response's next_offset"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/logs", Tag: "containers", Summary: "Get container logs", Response: model.ContainerLogResponse{}, Query: []Param{
+		{Name: "tail", Description: "Number of lines, at most log_tail_max, or \"all\", the default"},
+		{Name: "since", Description: "Only lines after this RFC 3339 time or duration before now, e.g. 30m"},
+		{Name: "until", Description: "Only lines before this RFC 3339 time or duration before now"},
+		{Name: "timestamps", Description: "true starts every line with its time"},
+		{Name: "grep", Description: "Only lines matching this regular expression (RE2 syntax), at most log_search_max_matches"},
+	}},
+	{Method: http.MethodPost, Path: "/servers/:id/containers/:containerID/logs/search", Tag: "containers", Summary: "Search a container's log with a regular expression, with lines of context", Request: model.LogSearchRequest{}, Response: model.LogSearchResponse{}},
+	{Method: http.MethodPost, Path: "/servers/:id/containers/:containerID/logs/truncate", Tag: "containers", Summary: "Empty a container's log file", Request: model.LogTruncateRequest{}, Response: Message{}},
+	{Method: http.MethodPut, Path: "/servers/:id/containers/:containerID/log-config", Tag: "containers", Summary: "Recreate a container to change how its log is rotated", Request: model.LogConfigRequest{}, Response: model.LogConfigResponse{}, Status: http.StatusAccepted},
+	{Method: http.MethodGet, Path: "/servers/:id/log-usage", Tag: "containers", Summary: "List container log sizes, largest first", Response: model.LogUsageResponse{}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/details", Tag: "containers", Summary: "Inspect a container, parsed into sections; sensitive environment values are masked for non-admins", Response: handler.ContainerDetailsResponse{}, Query: []Param{
+		{Name: "raw", Description: "true returns the docker inspect output as a string in details, with gpus and restart_policy"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/health", Tag: "containers", Summary: "Get a container's health check state and latest probes", Response: model.ContainerHealth{}, Query: []Param{
+		{Name: "limit", Description: "Only return the last this many probes; docker keeps five"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/stats", Tag: "containers", Summary: "Read a container's resource usage, or a short series of readings", Response: model.ContainerStats{}, Query: []Param{
+		{Name: "samples", Description: "Take this many readings, at most 10; samples lists them when more than one"},
+		{Name: "interval", Description: "Milliseconds between the readings, at most 1000, default 1000"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/processes", Tag: "containers", Summary: "List the processes running in a container; 409 when it is not running", Response: model.ContainerProcessesResponse{}},
+	{Method: http.MethodPut, Path: "/servers/:id/containers/:containerID/restart-policy", Tag: "containers", Summary: "Change a container's restart policy", Request: model.RestartPolicyRequest{}, Response: model.RestartPolicy{}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/check-update", Tag: "containers", Summary: "Check whether a newer image is available", Response: model.ImageUpdate{}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/image-tags", Tag: "containers", Summary: "List the registry tags of a container's image", Response: model.ImageTagList{}, Query: []Param{
+		{Name: "page", Description: "Page number, from 1"},
+		{Name: "per_page", Description: "Tags per page, 100 by default and at most 1000"},
+		refreshParam,
+	}},
+	{Method: http.MethodPut, Path: "/servers/:id/containers/:containerID/auto-update", Tag: "containers", Summary: "Opt a container into or out of auto-updates during the maintenance window", Request: model.AutoUpdateRequest{}, Response: model.AutoUpdateResponse{}},
+	{Method: http.MethodGet, Path: "/servers/:id/image-updates", Tag: "containers", Summary: "List the latest scheduled image update checks of a server's containers", Response: model.ImageChecksResponse{}},
+	{Method: http.MethodGet, Path: "/servers/:id/auto-updates", Tag: "containers", Summary: "List a server's automatic container updates, newest first", Response: model.AutoUpdateRunsResponse{}, Query: []Param{
+		{Name: "limit", Description: "Maximum number of runs to return"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/files", Tag: "containers", Summary: "List files in a container", Response: model.FileListResponse{}, Query: []Param{
+		{Name: "path", Description: "Directory to list"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/files/content", Tag: "containers", Summary: "Read a file in a container; binary files and files larger than file_content_max_bytes come without content and with is_binary set", Response: model.FileContentResponse{}, Query: []Param{
+		{Name: "path", Description: "File to read", Required: true},
+		{Name: "encoding", Description: "base64 to return the content base64-encoded, binary or not"},
+		{Name: "offset", Description: "Byte to start reading at, from 0; 416 when past the end of the file"},
+		{Name: "limit", Description: "Bytes to read from offset, file_content_max_bytes by default and at most"},
+		{Name: "head", Description: "Read the first lines instead, up to file_content_max_bytes"},
+		{Name: "tail", Description: "Read the last lines instead, up to file_content_max_bytes"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/containers/:containerID/files/download", Tag: "containers", Summary: "Download a file from a container", ContentType: "application/octet-stream", Query: []Param{
+		{Name: "path", Description: "File to download", Required: true},
+	}},
+	{Method: http.MethodPost, Path: "/servers/:id/containers/:containerID/files/op", Tag: "containers", Summary: "Delete, rename, create or chmod a path in a container and list its parent directory; deleting requires full access, a directory that is not empty needs recursive", Request: model.FileOpRequest{}, Response: model.FileListResponse{}},
+
+	{Method: http.MethodGet, Path: "/servers/:id/images", Tag: "images", Summary: "List images, marking those built on the host", Response: []model.ImageSummary{}},
+	{Method: http.MethodPost, Path: "/servers/:id/images/pull", Tag: "images", Summary: "Start pulling an image by name with the stored registry login; follow the task with GET /tasks/:id, whose error_code tells image_not_found, registry_unauthorized, registry_rate_limited and disk_full failures apart", Request: model.ImagePullRequest{}, Response: model.PullTask{}, Status: http.StatusAccepted},
+	{Method: http.MethodPost, Path: "/servers/:id/images/build", Tag: "images", Summary: "Start an image build from a multipart form with tag, build_arg, and a dockerfile field or a tar.gz context file", Response: model.BuildJob{}, Status: http.StatusAccepted},
+	{Method: http.MethodGet, Path: "/servers/:id/images/builds/:jobID", Tag: "images", Summary: "Get a build's status and output", Response: model.BuildJob{}, Query: []Param{
+		{Name: "offset", Description: "Output offset to continue from, the previous response's next_offset"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/volumes", Tag: "volumes", Summary: "List volumes with their size from docker system df -v, the containers that mount them and whether they are dangling", Response: model.VolumesResponse{}},
+	{Method: http.MethodPost, Path: "/servers/:id/volumes/prune", Tag: "volumes", Summary: "Remove the volumes no container mounts, or list them with dry_run; requires full access", Request: model.VolumePruneRequest{}, Response: model.PruneResult{}},
+	{Method: http.MethodGet, Path: "/servers/:id/volumes/:name", Tag: "volumes", Summary: "Inspect a volume, with its driver options and the containers that mount it", Response: model.VolumeDetails{}},
+	{Method: http.MethodDelete, Path: "/servers/:id/volumes/:name", Tag: "volumes", Summary: "Remove a volume and its data; requires full access; 409 naming the containers when a container, even a stopped one, mounts it", Response: Message{}},
+	{Method: http.MethodGet, Path: "/servers/:id/volumes/:name/files", Tag: "volumes", Summary: "List a directory of a volume, on the host or through a helper container; requires manage access; truncated when it has more than 5000 entries", Response: model.FileListResponse{}, Query: []Param{
+		{Name: "path", Description: "Directory inside the volume, / by default"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/volumes/:name/files/content", Tag: "volumes", Summary: "Read a file of a volume like a file in a container; requires manage access", Response: model.FileContentResponse{}, Query: []Param{
+		{Name: "path", Description: "File to read, inside the volume", Required: true},
+		{Name: "encoding", Description: "base64 to return the content base64-encoded, binary or not"},
+		{Name: "offset", Description: "Byte to start reading at, from 0; 416 when past the end of the file"},
+		{Name: "limit", Description: "Bytes to read from offset, file_content_max_bytes by default and at most"},
+		{Name: "head", Description: "Read the first lines instead, up to file_content_max_bytes"},
+		{Name: "tail", Description: "Read the last lines instead, up to file_content_max_bytes"},
+	}},
+	{Method: http.MethodGet, Path: "/servers/:id/networks", Tag: "networks", Summary: "List networks with their subnets and the containers attached to them, with their addresses", Response: model.NetworksResponse{}},
+	{Method: http.MethodPost, Path: "/servers/:id/networks", Tag: "networks", Summary: "Create a bridge network, optionally with a subnet, gateway and IP range; requires manage access; 409 with docker's message when docker refuses it", Request: model.NetworkCreateRequest{}, Response: model.NetworkDetails{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/servers/:id/networks/:name", Tag: "networks", Summary: "Inspect a network, with its driver options", Response: model.NetworkDetails{}},
+	{Method: http.MethodDelete, Path: "/servers/:id/networks/:name", Tag: "networks", Summary: "Remove a network; requires full access; 409 for bridge, host and none and naming the containers when containers are attached", Response: Message{}},
+	{Method: http.MethodPost, Path: "/servers/:id/networks/:name/connect", Tag: "networks", Summary: "Attach a container to a network; requires manage access; 409 with docker's message when docker refuses it", Request: model.NetworkConnectRequest{}, Response: Message{}},
+	{Method: http.MethodPost, Path: "/servers/:id/networks/:name/disconnect", Tag: "networks", Summary: "Detach a container from a network; requires manage access; 409 with docker's message when docker refuses it", Request: model.NetworkDisconnectRequest{}, Response: Message{}},
+	{Method: http.MethodGet, Path: "/servers/:id/compose", Tag: "compose", Summary: "List the docker compose projects of a server's containers, with their working directory and config files", Response: model.ComposeProjectsResponse{}, Query: []Param{refreshParam}},
+	{Method: http.MethodPost, Path: "/servers/:id/compose/:project/action", Tag: "compose", Summary: "Run docker compose up -d, down, restart or pull and up -d on a project in its working directory; a failing docker compose responds 200 with its exit code and output; 404 when no container of the project exists", Request: model.ComposeActionRequest{}, Response: model.ComposeActionResult{}},
+	{Method: http.MethodGet, Path: "/servers/:id/compose/:project/file", Tag: "compose", Summary: "Read a config file of a compose project; requires full access", Response: model.ComposeFile{}, Query: []Param{
+		{Name: "file", Description: "One of the project's config files, the first by default"},
+	}},
+	{Method: http.MethodPut, Path: "/servers/:id/compose/:project/file", Tag: "compose", Summary: "Replace a config file of a compose project with valid YAML after backing it up, listing the services added, removed and changed; requires full access", Request: model.ComposeFileUpdate{}, Response: model.ComposeFileResult{}},
+	{Method: http.MethodPost, Path: "/servers/:id/swarm/services/:name/scale", Tag: "swarm", Summary: "Scale a swarm service and report its tasks", Request: model.SwarmScaleRequest{}, Response: model.SwarmRollout{}},
+	{Method: http.MethodPost, Path: "/servers/:id/swarm/services/:name/update", Tag: "swarm", Summary: "Change a swarm service's image or force a rolling restart", Request: model.SwarmUpdateRequest{}, Response: model.SwarmRollout{}},
+	{Method: http.MethodGet, Path: "/servers/:id/tasks", Tag: "tasks", Summary: "List the server's scheduled tasks", Response: []model.ScheduledTask{}},
+	{Method: http.MethodPost, Path: "/servers/:id/tasks", Tag: "tasks", Summary: "Schedule a task", Request: handler.TaskInput{}, Response: model.ScheduledTask{}, Status: http.StatusCreated},
+	{Method: http.MethodPut, Path: "/servers/:id/tasks/:taskID", Tag: "tasks", Summary: "Update a scheduled task", Request: handler.TaskInput{}, Response: model.ScheduledTask{}},
+	{Method: http.MethodDelete, Path: "/servers/:id/tasks/:taskID", Tag: "tasks", Summary: "Delete a scheduled task and its run history", Response: Message{}},
+	{Method: http.MethodGet, Path: "/servers/:id/tasks/:taskID/runs", Tag: "tasks", Summary: "List the task's runs, newest first", Response: []model.TaskRun{}, Query: []Param{
+		{Name: "limit", Description: "Maximum number of runs to return"},
+	}},
+
+	{Method: http.MethodGet, Path: "/users", Tag: "users", Summary: "List users", Admin: true, Response: []model.User{}},
+	{Method: http.MethodPost, Path: "/users", Tag: "users", Summary: "Create a user", Admin: true, Request: UserInput{}, Response: model.User{}, Status: http.StatusCreated},
+	{Method: http.MethodPut, Path: "/users/:id", Tag: "users", Summary: "Update a user", Admin: true, Request: UserInput{}, Response: model.User{}},
+	{Method: http.MethodDelete, Path: "/users/:id", Tag: "users", Summary: "Delete a user", Admin: true, Response: Message{}},
+	{Method: http.MethodPut, Path: "/users/:id/reset-password", Tag: "users", Summary: "Reset a user's password", Admin: true, Request: PasswordReset{}, Response: Message{}},
+	{Method: http.MethodGet, Path: "/users/:id/permissions", Tag: "users", Summary: "List a user's server permissions", Admin: true, Response: []model.ServerPermission{}},
+	{Method: http.MethodPut, Path: "/users/:id/permissions", Tag: "users", Summary: "Replace a user's server permissions", Admin: true, Request: PermissionsInput{}, Response: Message{}},
+	{Method: http.MethodPut, Path: "/users/change-password", Tag: "users", Summary: "Change the current user's password", Request: PasswordChange{}, Response: Message{}},
+	{Method: http.MethodPost, Path: "/users/bind-telegram", Tag: "users", Summary: "Bind a Telegram account to the current user", Request: TelegramBinding{}, Response: Message{}},
+	{Method: http.MethodPut, Path: "/users/language", Tag: "users", Summary: "Set the language of API messages for the current user", Request: LanguagePreference{}, Response: LanguagePreference{}},
+	{Method: http.MethodGet, Path: "/users/notifications", Tag: "users", Summary: "Get the current user's notification email and channels", Response: handler.NotificationPreferences{}},
+	{Method: http.MethodPut, Path: "/users/notifications", Tag: "users", Summary: "Set the current user's notification email and channels (telegram, email); no channel turns notifications off", Request: handler.NotificationPreferences{}, Response: handler.NotificationPreferences{}},
+
+	{Method: http.MethodGet, Path: "/config", Tag: "config", Summary: "List all configuration keys", Admin: true, Response: []config.Value{}},
+	{Method: http.MethodPut, Path: "/config", Tag: "config", Summary: "Update configuration keys; null resets a key to its default", Admin: true, Request: map[string]interface{}{}, Response: []config.Value{}},
+	{Method: http.MethodGet, Path: "/config/telegram", Tag: "config", Summary: "Get the Telegram settings", Admin: true, Response: TelegramConfig{}},
+	{Method: http.MethodPut, Path: "/config/telegram", Tag: "config", Summary: "Update the Telegram settings", Admin: true, Request: TelegramConfig{}, Response: Message{}},
+	{Method: http.MethodGet, Path: "/config/latency", Tag: "config", Summary: "Get the latency probe targets", Admin: true, Response: LatencyConfig{}},
+	{Method: http.MethodPut, Path: "/config/latency", Tag: "config", Summary: "Update the latency probe targets", Admin: true, Request: LatencyConfig{}, Response: Message{}},
+	{Method: http.MethodPost, Path: "/config/smtp/test", Tag: "config", Summary: "Send a test email with the stored SMTP settings; SMTP errors are returned verbatim", Admin: true, Request: handler.SMTPTest{}, Response: Message{}},
+
+	{Method: http.MethodGet, Path: "/container-templates", Tag: "templates", Summary: "List container templates", Response: []handler.TemplateInfo{}},
+	{Method: http.MethodGet, Path: "/container-templates/export", Tag: "templates", Summary: "Download every container template as JSON", Response: handler.TemplateExport{}},
+	{Method: http.MethodPost, Path: "/container-templates/import", Tag: "templates", Summary: "Import templates from an export, replacing templates with the same name", Admin: true, Request: handler.TemplateExport{}, Response: handler.TemplateImportResult{}},
+	{Method: http.MethodGet, Path: "/container-templates/:id", Tag: "templates", Summary: "Get a container template and the placeholders it expects", Response: handler.TemplateInfo{}},
+	{Method: http.MethodPost, Path: "/container-templates", Tag: "templates", Summary: "Add a container template", Admin: true, Request: handler.TemplateInput{}, Response: handler.TemplateInfo{}, Status: http.StatusCreated},
+	{Method: http.MethodPut, Path: "/container-templates/:id", Tag: "templates", Summary: "Update a container template", Admin: true, Request: handler.TemplateInput{}, Response: handler.TemplateInfo{}},
+	{Method: http.MethodDelete, Path: "/container-templates/:id", Tag: "templates", Summary: "Delete a container template", Admin: true, Response: Message{}},
+	{Method: http.MethodPost, Path: "/servers/:id/containers/from-template/:templateID", Tag: "templates", Summary: "Create and start a container from a template, filling in its placeholders", Request: model.TemplateDeployRequest{}, Response: model.TemplateDeployResult{}, Status: http.StatusCreated},
+
+	{Method: http.MethodGet, Path: "/registries", Tag: "registries", Summary: "List registry logins; passwords are never returned", Admin: true, Response: []model.RegistryCredential{}},
+	{Method: http.MethodPost, Path: "/registries", Tag: "registries", Summary: "Add a registry login, encrypted with the master key", Admin: true, Request: handler.RegistryCredentialInput{}, Response: model.RegistryCredential{}},
+	{Method: http.MethodPut, Path: "/registries/:id", Tag: "registries", Summary: "Update a registry login; omit password to keep the stored one", Admin: true, Request: handler.RegistryCredentialInput{}, Response: model.RegistryCredential{}},
+	{Method: http.MethodDelete, Path: "/registries/:id", Tag: "registries", Summary: "Delete a registry login", Admin: true, Response: Message{}},
+	{Method: http.MethodGet, Path: "/webhooks", Tag: "webhooks", Summary: "List webhooks", Admin: true, Response: []handler.WebhookInfo{}},
+	{Method: http.MethodPost, Path: "/webhooks", Tag: "webhooks", Summary: "Add a webhook", Admin: true, Request: handler.WebhookInput{}, Response: handler.WebhookInfo{}},
+	{Method: http.MethodPut, Path: "/webhooks/:id", Tag: "webhooks", Summary: "Update a webhook; omit secret to keep the stored one", Admin: true, Request: handler.WebhookInput{}, Response: handler.WebhookInfo{}},
+	{Method: http.MethodDelete, Path: "/webhooks/:id", Tag: "webhooks", Summary: "Delete a webhook and its delivery log", Admin: true, Response: Message{}},
+	{Method: http.MethodPost, Path: "/webhooks/:id/test", Tag: "webhooks", Summary: "Send a test event and return the delivery", Admin: true, Response: model.WebhookDelivery{}},
+	{Method: http.MethodGet, Path: "/webhooks/:id/deliveries", Tag: "webhooks", Summary: "List the webhook's recent deliveries, newest first", Admin: true, Response: []model.WebhookDelivery{}},
+
+	{Method: http.MethodGet, Path: "/admin/backups", Tag: "admin", Summary: "List stored backups", Admin: true, Response: BackupList{}},
+	{Method: http.MethodPost, Path: "/admin/backup", Tag: "admin", Summary: "Download a backup, or store it on the server with store=true", Admin: true, ContentType: "application/gzip", Query: []Param{
+		{Name: "store", Description: "\"true\" stores the backup under the data directory instead of downloading it"},
+	}},
+	{Method: http.MethodGet, Path: "/admin/export", Tag: "admin", Summary: "Download servers, users, permissions, settings and webhooks as a JSON bundle", Admin: true, Response: bundle.Bundle{}, Query: []Param{
+		{Name: "include_secrets", Description: "\"true\" adds credentials and secrets, encrypted with the X-Bundle-Passphrase header"},
+		{Name: "include_passwords", Description: "\"true\" adds the users' password hashes"},
+	}},
+	{Method: http.MethodPost, Path: "/admin/import", Tag: "admin", Summary: "Import a bundle; bundles with secrets need the X-Bundle-Passphrase header", Admin: true, Request: bundle.Bundle{}, Response: bundle.Report{}, Query: []Param{
+		{Name: "dry_run", Description: "\"true\" only reports what would be created, updated or conflict"},
+	}},
+	{Method: http.MethodPost, Path: "/admin/restore", Tag: "admin", Summary: "Restore an uploaded backup (multipart field \"file\")", Admin: true, Response: Message{}},
+
+	{Method: http.MethodGet, Path: "/debug/cache", Tag: "admin", Summary: "Get cache statistics", Admin: true, Response: []cache.Stats{}},
+	{Method: http.MethodGet, Path: "/debug/stats", Tag: "admin", Summary: "Get request, SSH and cache performance statistics", Admin: true, Response: handler.RuntimeStats{}},
+	{Method: http.MethodDelete, Path: "/debug/cache", Tag: "admin", Summary: "Flush caches", Admin: true, Response: FlushResult{}, Query: []Param{
+		{Name: "names", Description: "Comma separated cache names, all when omitted"},
+	}},
+
+	{Method: http.MethodGet, Path: "/openapi.json", Tag: "meta", Summary: "This OpenAPI document", Admin: true},
+	{Method: http.MethodGet, Path: "/docs", Tag: "meta", Summary: "Swagger UI for this document", Admin: true, ContentType: "text/html"},
+
+	{Method: http.MethodGet, Path: "/telegram/info", Tag: "telegram", Summary: "Get the Telegram Web App user info"},
+	{Method: http.MethodGet, Path: "/telegram/servers", Tag: "telegram", Summary: "List servers for the Telegram Web App"},
+	{Method: http.MethodGet, Path: "/telegram/summary", Tag: "telegram", Summary: "Get a quick summary for the Telegram Web App"},
+	{Method: http.MethodGet, Path: "/telegram/servers/:id/stats", Tag: "telegram", Summary: "Get server stats for the Telegram Web App"},
+	{Method: http.MethodGet, Path: "/telegram/servers/:id/containers", Tag: "telegram", Summary: "Get container status for the Telegram Web App; takes the filter, sort and page parameters of the container list"},
+}
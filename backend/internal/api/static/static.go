@@ -0,0 +1,228 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/api/middleware"
+)
+
+// minGzipSize is the smallest file worth compressing
+const minGzipSize = 1024
+
+// Cache policies. Vite fingerprints everything under assets/, so those files never change under
+// the same name; everything else must be revalidated.
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "no-cache"
+)
+
+// fingerprinted matches build outputs such as assets/index-4f1c2a9b.js and their source maps
+var fingerprinted = regexp.MustCompile(`^assets/.+-[A-Za-z0-9_-]{8,}(\.[A-Za-z0-9]+)+$`)
+
+// contentTypes overrides mime.TypeByExtension, whose result depends on the host's mime.types
+var contentTypes = map[string]string{
+	".html":        "text/html; charset=utf-8",
+	".css":         "text/css; charset=utf-8",
+	".js":          "text/javascript; charset=utf-8",
+	".mjs":         "text/javascript; charset=utf-8",
+	".json":        "application/json",
+	".map":         "application/json",
+	".webmanifest": "application/manifest+json",
+	".svg":         "image/svg+xml",
+	".ico":         "image/x-icon",
+	".png":         "image/png",
+	".jpg":         "image/jpeg",
+	".webp":        "image/webp",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".ttf":         "font/ttf",
+	".wasm":        "application/wasm",
+	".txt":         "text/plain; charset=utf-8",
+}
+
+// Handler serves the frontend from fsys and falls back to index.html for SPA routes, which are
+// the paths without a file extension. Requests must have basePath stripped already; index.html
+// gets a <base href> for it, so the relative asset URLs of the build resolve under any sub-path.
+// Files are prepared on first request and kept in memory with their ETag and gzipped form, since
+// embedded files never change.
+func Handler(fsys fs.FS, basePath string) http.Handler {
+	return &handler{fsys: fsys, baseTag: []byte(`<base href="` + html.EscapeString(basePath+"/") + `">`)}
+}
+
+type handler struct {
+	fsys    fs.FS
+	baseTag []byte
+	assets  sync.Map // file name -> *asset
+}
+
+// asset is a file prepared for serving
+type asset struct {
+	data         []byte
+	gz           []byte // nil when not worth compressing
+	etag         string
+	contentType  string
+	cacheControl string
+	modTime      time.Time
+}
+
+var headTag = regexp.MustCompile(`(?i)<head[^>]*>`)
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	a, err := h.asset(name)
+	if err != nil {
+		// A missing file is a 404, anything else is an SPA route and gets index.html. Answering
+		// for a stale asset with HTML would only fail later in the browser, as a script error.
+		if path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+		slog.Debug("serving index.html for SPA route", "path", r.URL.Path)
+		if a, err = h.asset("index.html"); err != nil {
+			slog.Error("failed to open index.html", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", a.contentType)
+	header.Set("Cache-Control", a.cacheControl)
+	if !a.modTime.IsZero() {
+		header.Set("Last-Modified", a.modTime.UTC().Format(http.TimeFormat))
+	}
+	data, etag := a.data, a.etag
+	if a.gz != nil {
+		header.Add("Vary", "Accept-Encoding")
+		if middleware.AcceptsGzip(r.Header.Get("Accept-Encoding")) {
+			data, etag = a.gz, strings.TrimSuffix(a.etag, `"`)+`-gzip"`
+			header.Set("Content-Encoding", "gzip")
+		}
+	}
+	header.Set("ETag", etag)
+
+	if notModified(r, etag, a.modTime) {
+		header.Del("Content-Type")
+		header.Del("Content-Encoding")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(data); err != nil {
+		slog.Debug("failed to write static file", "path", r.URL.Path, "error", err)
+	}
+}
+
+// asset returns the prepared file, reading it on first use
+func (h *handler) asset(name string) (*asset, error) {
+	if v, ok := h.assets.Load(name); ok {
+		return v.(*asset), nil
+	}
+	if name == "" {
+		return nil, fs.ErrNotExist
+	}
+	data, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var modTime time.Time
+	if info, err := fs.Stat(h.fsys, name); err == nil {
+		modTime = info.ModTime() // zero for embedded files
+	}
+	if name == "index.html" {
+		data = h.withBase(data)
+	}
+
+	sum := sha256.Sum256(data)
+	a := &asset{
+		data:         data,
+		etag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		contentType:  contentType(name, data),
+		cacheControl: cacheRevalidate,
+		modTime:      modTime,
+	}
+	if fingerprinted.MatchString(name) {
+		a.cacheControl = cacheImmutable
+	}
+	if len(data) >= minGzipSize && middleware.Compressible(a.contentType) {
+		a.gz = compress(data)
+	}
+	v, _ := h.assets.LoadOrStore(name, a)
+	return v.(*asset), nil
+}
+
+// withBase inserts the <base href> right after the opening head tag
+func (h *handler) withBase(data []byte) []byte {
+	loc := headTag.FindIndex(data)
+	if loc == nil {
+		return data
+	}
+	out := make([]byte, 0, len(data)+len(h.baseTag))
+	out = append(out, data[:loc[1]]...)
+	out = append(out, h.baseTag...)
+	return append(out, data[loc[1]:]...)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no ETag was sent
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if modTime.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modTime.Truncate(time.Second).After(since)
+}
+
+// compress gzips a file once, nil when compression fails
+func compress(data []byte) []byte {
+	var buf bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if _, err := gz.Write(data); err != nil {
+		return nil
+	}
+	if err := gz.Close(); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// contentType picks the content type by extension and sniffs the data for unknown ones
+func contentType(name string, data []byte) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ct, ok := contentTypes[ext]; ok {
+		return ct
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return http.DetectContentType(data)
+}
@@ -0,0 +1,103 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testFS = fstest.MapFS{
+	"index.html":                   {Data: []byte("<!doctype html><html><head><title>DockerManager</title></head><body></body></html>")},
+	"favicon.ico":                  {Data: []byte{0, 0, 1, 0}},
+	"manifest.webmanifest":         {Data: []byte(`{"name":"DockerManager"}`)},
+	"assets/index-4f1c2a9b.js":     {Data: []byte("console.log(1)")},
+	"assets/index-4f1c2a9b.js.map": {Data: []byte(`{"version":3}`)},
+	"assets/index-0hCC__8P.css":    {Data: []byte("body{}")},
+	"assets/inter-a1b2c3d4.woff2":  {Data: []byte("wOF2")},
+	"assets/logo-a1b2c3d4.svg":     {Data: []byte("<svg></svg>")},
+	"assets/logo-a1b2c3d4.png":     {Data: []byte("\x89PNG\r\n\x1a\n")},
+	"robots.txt":                   {Data: []byte("User-agent: *")},
+}
+
+func get(h http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestContentTypesAndCaching(t *testing.T) {
+	h := Handler(testFS, "")
+	for _, tc := range []struct {
+		path, contentType, cacheControl string
+	}{
+		{"/index.html", "text/html; charset=utf-8", cacheRevalidate},
+		{"/favicon.ico", "image/x-icon", cacheRevalidate},
+		{"/manifest.webmanifest", "application/manifest+json", cacheRevalidate},
+		{"/robots.txt", "text/plain; charset=utf-8", cacheRevalidate},
+		{"/assets/index-4f1c2a9b.js", "text/javascript; charset=utf-8", cacheImmutable},
+		{"/assets/index-4f1c2a9b.js.map", "application/json", cacheImmutable},
+		{"/assets/index-0hCC__8P.css", "text/css; charset=utf-8", cacheImmutable},
+		{"/assets/inter-a1b2c3d4.woff2", "font/woff2", cacheImmutable},
+		{"/assets/logo-a1b2c3d4.svg", "image/svg+xml", cacheImmutable},
+		{"/assets/logo-a1b2c3d4.png", "image/png", cacheImmutable},
+	} {
+		w := get(h, tc.path, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s = %d, want 200", tc.path, w.Code)
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != tc.contentType {
+			t.Errorf("%s Content-Type = %q, want %q", tc.path, got, tc.contentType)
+		}
+		if got := w.Header().Get("Cache-Control"); got != tc.cacheControl {
+			t.Errorf("%s Cache-Control = %q, want %q", tc.path, got, tc.cacheControl)
+		}
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	h := Handler(testFS, "/dm")
+	index := get(h, "/", nil)
+	if index.Code != http.StatusOK || !strings.Contains(index.Body.String(), `<head><base href="/dm/">`) {
+		t.Fatalf("index = %d %q, want index.html with its base href", index.Code, index.Body.String())
+	}
+
+	// Unknown routes are the SPA's and get a fresh index.html
+	for _, path := range []string{"/servers/1/containers", "/settings", "/login?next=%2Fusers"} {
+		w := get(h, path, nil)
+		if w.Code != http.StatusOK || w.Body.String() != index.Body.String() {
+			t.Errorf("%s = %d, want index.html", path, w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); got != cacheRevalidate {
+			t.Errorf("%s Cache-Control = %q, want %q", path, got, cacheRevalidate)
+		}
+	}
+
+	// Missing files get a 404, including the ones an old index.html still links to
+	for _, path := range []string{"/assets/index-deadbeef.js", "/assets/missing.css", "/favicon.png"} {
+		if w := get(h, path, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%s = %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestRevalidation(t *testing.T) {
+	h := Handler(testFS, "")
+	w := get(h, "/assets/index-4f1c2a9b.js", nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if w := get(h, "/assets/index-4f1c2a9b.js", http.Header{"If-None-Match": {`"other", ` + etag}}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("matching If-None-Match = %d with %d bytes, want 304", w.Code, w.Body.Len())
+	}
+	if w := get(h, "/assets/index-4f1c2a9b.js", http.Header{"If-None-Match": {`"other"`}}); w.Code != http.StatusOK {
+		t.Fatalf("stale If-None-Match = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"errors"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// accessRank orders access levels so a higher level includes the lower ones, as the REST
+// handlers rank them
+var accessRank = map[string]int{
+	model.AccessLevelRead:   1,
+	model.AccessLevelManage: 2,
+	model.AccessLevelFull:   3,
+}
+
+// currentUser returns the authenticated user's ID and role, responding with unauthorized when
+// the auth middleware did not set them
+func currentUser(c *gin.Context) (uint, string, bool) {
+	id := c.GetUint("userID")
+	role := c.GetString("role")
+	if id == 0 || role == "" {
+		apierror.Abort(c, apierror.Unauthorized)
+		return 0, "", false
+	}
+	return id, role, true
+}
+
+// checkAccess checks that the current user holds at least the given access level on the server
+// and returns the granted level, "admin" for admins, who pass every check. On failure the error
+// response has been written, so it is called before the connection is upgraded.
+func checkAccess(c *gin.Context, db *gorm.DB, serverID uint, level string) (string, bool) {
+	userID, role, ok := currentUser(c)
+	if !ok {
+		return "", false
+	}
+	if role == "admin" {
+		return role, true
+	}
+	var permission model.ServerPermission
+	if err := db.Where("user_id = ? AND server_id = ?", userID, serverID).First(&permission).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.PermissionDenied)
+			return "", false
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return "", false
+	}
+	if accessRank[permission.AccessLevel] < accessRank[level] {
+		apierror.AbortMessage(c, apierror.PermissionDenied, apierror.T(c, "server_access_required", level))
+		return "", false
+	}
+	return permission.AccessLevel, true
+}
+
+// authorizeServer checks access to the server with checkAccess and loads it, responding with
+// server_not_found when it does not exist
+func authorizeServer(c *gin.Context, db *gorm.DB, serverID uint, level string) (*model.Server, string, bool) {
+	granted, ok := checkAccess(c, db, serverID, level)
+	if !ok {
+		return nil, "", false
+	}
+	var server model.Server
+	if err := db.First(&server, serverID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.ServerNotFound)
+			return nil, "", false
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return nil, "", false
+	}
+	return &server, granted, true
+}
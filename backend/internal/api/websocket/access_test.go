@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"docker-pulse/internal/migrate"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestSocketsCheckAccessBeforeUpgrading covers the answers the handlers give before the
+// connection is upgraded, which are all that is reachable without a docker host
+func TestSocketsCheckAccessBeforeUpgrading(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "data.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := migrate.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	users := map[string]model.User{}
+	for name, role := range map[string]string{"admin": "admin", "reader": "user", "manager": "user", "stranger": "user"} {
+		u := model.User{Username: name, Password: "x", Role: role}
+		if err := db.Create(&u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users[name] = u
+	}
+	server := model.Server{Name: "web", IP: "10.0.0.1", Port: 22, Username: "root", AuthMode: "password"}
+	if err := db.Create(&server).Error; err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+	for name, level := range map[string]string{"reader": model.AccessLevelRead, "manager": model.AccessLevelManage} {
+		if err := db.Create(&model.ServerPermission{UserID: users[name].ID, ServerID: server.ID, AccessLevel: level}).Error; err != nil {
+			t.Fatalf("grant: %v", err)
+		}
+	}
+
+	handlers := map[string]func(context.Context, *gin.Context, *gorm.DB){"terminal": TerminalHandler, "events": EventsHandler, "logs": LogsHandler}
+	for _, tc := range []struct {
+		handler, user, query string
+		want                 int
+		code                 string
+	}{
+		{"events", "stranger", "server_id=1", http.StatusForbidden, "permission_denied"},
+		{"events", "admin", "server_id=9", http.StatusNotFound, "server_not_found"},
+		{"logs", "stranger", "server_id=1&container_id=web", http.StatusForbidden, "permission_denied"},
+		{"logs", "admin", "server_id=9&container_id=web", http.StatusNotFound, "server_not_found"},
+		{"terminal", "reader", "server_id=1&container_id=web", http.StatusForbidden, "permission_denied"},
+		{"terminal", "stranger", "server_id=1&container_id=web", http.StatusForbidden, "permission_denied"},
+		{"terminal", "manager", "server_id=1", http.StatusForbidden, "admin_required"},
+		{"terminal", "admin", "server_id=9", http.StatusNotFound, "server_not_found"},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/ws/"+tc.handler+"?"+tc.query, nil)
+		c.Set("userID", users[tc.user].ID)
+		c.Set("role", users[tc.user].Role)
+		handlers[tc.handler](context.Background(), c, db)
+		if w.Code != tc.want || !strings.Contains(w.Body.String(), tc.code) {
+			t.Errorf("%s as %s with %s = %d %s, want %d %s", tc.handler, tc.user, tc.query, w.Code, w.Body.String(), tc.want, tc.code)
+		}
+	}
+}
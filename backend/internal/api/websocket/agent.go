@@ -0,0 +1,41 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"docker-pulse/internal/agent"
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AgentHandler accepts the connection of a server's agent, authenticated by the server's
+// enrollment token as a bearer token, and serves it until it drops or ctx is cancelled
+func AgentHandler(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		apierror.Abort(c, apierror.Unauthorized)
+		return
+	}
+	var server model.Server
+	if err := db.Where("agent_token_hash = ?", agent.HashToken(token)).First(&server).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.InvalidToken)
+			return
+		}
+		apierror.AbortCause(c, apierror.DatabaseError, err)
+		return
+	}
+
+	wsConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logging.L(c).Warn("failed to upgrade agent websocket", "server_id", server.ID, "error", err)
+		return
+	}
+	agent.Serve(ctx, wsConn, server.ID, c.GetHeader(agent.HeaderVersion), c.GetHeader(agent.HeaderHostname))
+}
@@ -0,0 +1,54 @@
+package websocket
+
+import (
+	"strings"
+
+	"docker-pulse/internal/demo"
+)
+
+// demoInput holds terminal input back in demo mode until Enter is pressed and only passes
+// whitelisted commands on to the shell. Typed characters are echoed locally and erased again
+// before a command is sent, so that the shell's own echo shows it once. Escape sequences and
+// control characters other than Ctrl-C are dropped, which keeps history recall and completion
+// from putting anything else on the shell's line.
+type demoInput struct {
+	line []rune
+	// rejected is shown for commands that are not whitelisted
+	rejected string
+}
+
+// feed processes terminal input and returns what to send to the shell and what to echo to the
+// client
+func (d *demoInput) feed(data string) (toShell, toClient string) {
+	var shell, client strings.Builder
+	for _, r := range data {
+		switch {
+		case r == '\r' || r == '\n':
+			cmd := string(d.line)
+			if strings.TrimSpace(cmd) == "" || demo.CommandAllowed(cmd) {
+				client.WriteString(strings.Repeat("\b \b", len(d.line)))
+				shell.WriteString(cmd)
+			} else {
+				client.WriteString("\r\n" + d.rejected)
+			}
+			shell.WriteString("\r")
+			d.line = nil
+		case r == '\x7f' || r == '\b':
+			if len(d.line) > 0 {
+				d.line = d.line[:len(d.line)-1]
+				client.WriteString("\b \b")
+			}
+		case r == '\x03':
+			d.line = nil
+			shell.WriteRune(r)
+		case r == '\x1b':
+			// The rest of the message is the escape sequence, e.g. an arrow key
+			return shell.String(), client.String()
+		case r < ' ':
+		default:
+			d.line = append(d.line, r)
+			client.WriteRune(r)
+		}
+	}
+	return shell.String(), client.String()
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/events"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// eventsPingInterval keeps idle event connections from being dropped by proxies
+const eventsPingInterval = 30 * time.Second
+
+// EventsHandler pushes the container events of the server in ?server_id= as they happen, each
+// message a model.ContainerEvent. Any access to the server is enough. The connection is closed
+// when ctx is cancelled.
+func EventsHandler(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	if _, _, ok := currentUser(c); !ok {
+		return
+	}
+
+	serverIDStr := c.Query("server_id")
+	if serverIDStr == "" {
+		apierror.AbortField(c, apierror.InvalidRequest, "server_id", apierror.T(c, "server_id_required"))
+		return
+	}
+	serverID, err := strconv.ParseUint(serverIDStr, 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.InvalidID)
+		return
+	}
+
+	server, _, ok := authorizeServer(c, db, uint(serverID), model.AccessLevelRead)
+	if !ok {
+		return
+	}
+
+	logger := logging.L(c).With("server_id", server.ID)
+	wsConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Warn("failed to upgrade websocket", "error", err)
+		return
+	}
+	defer wsConn.Close()
+
+	sub := events.Subscribe(*server)
+	defer sub.Close()
+
+	// The client sends nothing; reading notices when it goes away
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := wsConn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(eventsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case event := <-sub.C:
+			if err := wsConn.WriteJSON(event); err != nil {
+				logger.Debug("events websocket write failed", "error", err)
+				return
+			}
+		case <-ping.C:
+			if err := wsConn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-ctx.Done():
+			wsConn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(time.Second))
+			return
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+	internalssh "docker-pulse/internal/ssh"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+const (
+	// logsPingInterval keeps connections to quiet containers from being dropped by proxies
+	logsPingInterval = 30 * time.Second
+	// logsWriteTimeout is how long a client may keep a message waiting before it is dropped, so a
+	// stuck client does not hold on to the remote "docker logs"
+	logsWriteTimeout = 10 * time.Second
+	// logLineLimit is the longest line sent in one message; longer lines are split
+	logLineLimit = 64 << 10
+)
+
+// logTail is what the tail parameter accepts: a number of lines or "all"
+var logTail = regexp.MustCompile(`^([0-9]+|all)$`)
+
+// LogsHandler follows the log of the container in ?container_id= on the server in ?server_id=,
+// starting with the last ?tail= lines (200 by default), each message a model.LogStreamMessage.
+// Any access to the server is enough, as for GET /servers/:id/containers/:containerID/logs.
+// When the container stops an exit message is sent and the connection closed. The remote
+// "docker logs" is stopped when the client goes away or ctx is cancelled.
+func LogsHandler(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	if _, _, ok := currentUser(c); !ok {
+		return
+	}
+
+	serverIDStr := c.Query("server_id")
+	if serverIDStr == "" {
+		apierror.AbortField(c, apierror.InvalidRequest, "server_id", apierror.T(c, "server_id_required"))
+		return
+	}
+	serverID, err := strconv.ParseUint(serverIDStr, 10, 32)
+	if err != nil {
+		apierror.Abort(c, apierror.InvalidID)
+		return
+	}
+	containerID := c.Query("container_id")
+	if containerID == "" {
+		apierror.AbortField(c, apierror.InvalidRequest, "container_id", apierror.T(c, "validation_required", "container_id"))
+		return
+	}
+	if !internalssh.ValidContainerID(containerID) {
+		apierror.AbortField(c, apierror.ValidationFailed, "container_id", apierror.T(c, "validation_invalid", "container_id"))
+		return
+	}
+	tail := c.DefaultQuery("tail", "200")
+	if !logTail.MatchString(tail) {
+		apierror.AbortField(c, apierror.ValidationFailed, "tail", apierror.T(c, "validation_invalid", "tail"))
+		return
+	}
+	if n, err := strconv.Atoi(tail); err == nil {
+		if maxTail := config.GetInt(db, model.ConfigKeyLogTailMax); n > maxTail {
+			apierror.AbortField(c, apierror.ValidationFailed, "tail", apierror.T(c, "validation_max", "tail", strconv.Itoa(maxTail)))
+			return
+		}
+	}
+
+	server, _, ok := authorizeServer(c, db, uint(serverID), model.AccessLevelRead)
+	if !ok {
+		return
+	}
+
+	logger := logging.L(c).With("server_id", server.ID, "container_id", containerID)
+	lang := apierror.Language(c)
+	wsConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Warn("failed to upgrade websocket", "error", err)
+		return
+	}
+	defer wsConn.Close()
+
+	// Cancelling the stream kills the remote command, whichever side ends first
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	backend, err := internalssh.NewServerBackend(server)
+	if err != nil {
+		logger.Warn("ssh operation failed", "op", "connect", "error", err)
+		closeLogStream(wsConn, model.LogStreamMessage{Type: model.LogStreamError, Error: apierror.Message(lang, apierror.SSHUnreachable)},
+			websocket.CloseInternalServerErr, "connection failed")
+		return
+	}
+	logs, err := backend.FollowContainerLogs(streamCtx, containerID, tail)
+	if err != nil {
+		logger.Warn("ssh operation failed", "op", "follow_logs", "error", err)
+		closeLogStream(wsConn, model.LogStreamMessage{Type: model.LogStreamError, Error: apierror.Message(lang, apierror.SSHCommandFailed)},
+			websocket.CloseInternalServerErr, "log stream failed")
+		return
+	}
+	defer logs.Close()
+
+	// The client sends nothing; reading notices when it goes away
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := wsConn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		ping := time.NewTicker(logsPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-ping.C:
+				if err := wsConn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+					cancel()
+					return
+				}
+			case <-streamCtx.Done():
+				return
+			}
+		}
+	}()
+
+	r := bufio.NewReaderSize(logs, logLineLimit)
+	for {
+		line, err := r.ReadSlice('\n')
+		if len(line) > 0 {
+			wsConn.SetWriteDeadline(time.Now().Add(logsWriteTimeout))
+			msg := model.LogStreamMessage{Type: model.LogStreamLine, Line: strings.TrimRight(string(line), "\r\n")}
+			if werr := wsConn.WriteJSON(msg); werr != nil {
+				logger.Debug("logs websocket write failed", "error", werr)
+				return
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			switch {
+			case ctx.Err() != nil:
+				wsConn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+					time.Now().Add(time.Second))
+			case streamCtx.Err() != nil:
+				// The client went away
+			case errors.Is(err, dockerapi.ErrStreamLimit):
+				closeLogStream(wsConn, model.LogStreamMessage{Type: model.LogStreamError, Error: err.Error()},
+					websocket.CloseTryAgainLater, "stream limit reached")
+			default:
+				logger.Warn("ssh operation failed", "op", "follow_logs", "error", err)
+				closeLogStream(wsConn, model.LogStreamMessage{Type: model.LogStreamError, Error: apierror.Message(lang, apierror.SSHCommandFailed)},
+					websocket.CloseInternalServerErr, "log stream failed")
+			}
+			return
+		}
+	}
+
+	if streamCtx.Err() != nil {
+		return
+	}
+	// "docker logs --follow" ends when the container stops
+	exit := model.LogStreamMessage{Type: model.LogStreamExit}
+	if inspect, err := backend.InspectContainer(ctx, containerID); err == nil {
+		if details, err := dockerapi.ParseDetails(inspect); err == nil {
+			exit.Status = details.State.Status
+			if !details.State.Running {
+				exit.ExitCode = &details.State.ExitCode
+			}
+		}
+	}
+	closeLogStream(wsConn, exit, websocket.CloseNormalClosure, "container exited")
+}
+
+// closeLogStream sends the last message of a log stream and closes the connection with code
+func closeLogStream(conn *websocket.Conn, msg model.LogStreamMessage, code int, reason string) {
+	conn.SetWriteDeadline(time.Now().Add(logsWriteTimeout))
+	if err := conn.WriteJSON(msg); err != nil {
+		return
+	}
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
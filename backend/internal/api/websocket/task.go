@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/logging"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/pull"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// taskUpdateInterval is the least time between two updates of a task, which keeps the frequent
+// download progress of a pull from flooding the client
+const taskUpdateInterval = 250 * time.Millisecond
+
+// TaskHandler follows a background task: an image pull, a container update or a change of a
+// container's log rotation. Each message is the task as GET /tasks/:id returns it, with the
+// output since the previous message. The connection is closed once the task is over, or when
+// ctx is cancelled.
+func TaskHandler(ctx context.Context, c *gin.Context, db *gorm.DB) {
+	if _, _, ok := currentUser(c); !ok {
+		return
+	}
+
+	job, found := pull.Get(c.Param("id"))
+	if !found {
+		apierror.Abort(c, apierror.NotFound)
+		return
+	}
+
+	// Any access to the server lets a user follow the tasks on it
+	if _, ok := checkAccess(c, db, job.ServerID(), model.AccessLevelRead); !ok {
+		return
+	}
+
+	logger := logging.L(c).With("server_id", job.ServerID(), "task_id", c.Param("id"))
+	wsConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Warn("failed to upgrade websocket", "error", err)
+		return
+	}
+	defer wsConn.Close()
+
+	// The client sends nothing; reading notices when it goes away
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := wsConn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var offset int64
+	for {
+		changed := job.Changed()
+		snap := job.Snapshot(offset)
+		offset = snap.NextOffset
+		if err := wsConn.WriteJSON(snap); err != nil {
+			logger.Debug("task websocket write failed", "error", err)
+			return
+		}
+		if snap.Status != model.PullRunning {
+			wsConn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, snap.Status),
+				time.Now().Add(time.Second))
+			return
+		}
+		select {
+		case <-changed:
+		case <-gone:
+			return
+		case <-ctx.Done():
+			wsConn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(time.Second))
+			return
+		}
+		select {
+		case <-time.After(taskUpdateInterval):
+		case <-job.Done():
+		case <-gone:
+			return
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package websocket
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"bytes"
+	"docker-pulse/internal/apierror"
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/demo"
+	"docker-pulse/internal/logging"
 	"docker-pulse/internal/model"
 	internalssh "docker-pulse/internal/ssh" // Alias internal ssh package
 	"encoding/json"
@@ -35,76 +41,91 @@ type WebSocketMessage struct {
 	Rows int    `json:"rows,omitempty"`
 }
 
-// TerminalHandler upgrades the HTTP connection to WebSocket and pipes it to SSH
-func TerminalHandler(c *gin.Context, db *gorm.DB) {
+// CloseSSHLost is the WebSocket close code sent when the terminal's SSH connection stops
+// answering keepalives, so the client can offer to reconnect
+const CloseSSHLost = 4001
+
+// sessions tracks open terminal sessions so shutdown can wait for them to close
+var sessions sync.WaitGroup
+
+// Wait blocks until every terminal session has closed or ctx is done
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		sessions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TerminalHandler upgrades the HTTP connection to WebSocket and pipes it to SSH.
+// The session is closed, including the remote shell, when ctx is cancelled.
+func TerminalHandler(ctx context.Context, c *gin.Context, db *gorm.DB) {
 	w := c.Writer
 	r := c.Request
 
 	// 1. Get Authentication info from context
-	currentUserIDInt, _ := c.Get("userID")
-	currentUserID := currentUserIDInt.(uint)
-	currentUserRoleInt, _ := c.Get("role")
-	currentUserRole := currentUserRoleInt.(string)
+	if _, _, ok := currentUser(c); !ok {
+		return
+	}
 
 	// 2. Get Server Info and Container ID from DB
 	serverIDStr := r.URL.Query().Get("server_id")
 	containerID := r.URL.Query().Get("container_id")
 
 	if serverIDStr == "" {
-		http.Error(w, "server_id required", http.StatusBadRequest)
+		apierror.AbortField(c, apierror.InvalidRequest, "server_id", apierror.T(c, "server_id_required"))
+		return
+	}
+	if containerID != "" && !internalssh.ValidContainerID(containerID) {
+		apierror.AbortField(c, apierror.ValidationFailed, "container_id", apierror.T(c, "validation_invalid", "container_id"))
 		return
 	}
 
 	serverID, err := strconv.ParseUint(serverIDStr, 10, 32)
 	if err != nil {
-		http.Error(w, "invalid server ID", http.StatusBadRequest)
+		apierror.Abort(c, apierror.InvalidID)
 		return
 	}
 
-	// Permission check
-	if currentUserRole != "admin" {
-		var permission model.ServerPermission
-		if err := db.Where("user_id = ? AND server_id = ?", currentUserID, serverID).First(&permission).Error; err != nil {
-			http.Error(w, "access denied", http.StatusForbidden)
-			return
-		}
-
-		// Regular users must have at least 'manage' or 'full' access to use terminal
-		if permission.AccessLevel != model.AccessLevelManage && permission.AccessLevel != model.AccessLevelFull {
-			http.Error(w, "insufficient permissions: 'manage' access required for terminal", http.StatusForbidden)
-			return
-		}
-
-		// Host terminal (containerID == "") is restricted to admins or maybe specific 'host' permission?
-		// For now, if no containerID, we only allow admins to access host shell.
-		if containerID == "" {
-			http.Error(w, "host shell access restricted to administrators", http.StatusForbidden)
-			return
-		}
+	// Regular users need at least 'manage' access to use the terminal
+	server, granted, ok := authorizeServer(c, db, uint(serverID), model.AccessLevelManage)
+	if !ok {
+		return
 	}
-
-	var server model.Server
-	if err := db.First(&server, uint(serverID)).Error; err != nil {
-		http.Error(w, "server not found", http.StatusNotFound)
+	// The host shell (no containerID) is restricted to admins
+	if containerID == "" && granted != "admin" {
+		apierror.AbortMessage(c, apierror.AdminRequired, apierror.T(c, "host_shell_admin_only"))
 		return
 	}
 
+	logger := logging.L(c).With("server_id", server.ID, "container_id", containerID)
+
 	wsConn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade websocket: %v", err)
+		logger.Warn("failed to upgrade websocket", "error", err)
 		return
 	}
 	defer wsConn.Close()
+	sessions.Add(1)
+	defer sessions.Done()
 
 	// 2. Establish SSH Connection to the host
-	sshClient, err := internalssh.NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret)
+	sshClient, err := internalssh.NewServerClient(server)
 	if err != nil {
+		logger.Warn("ssh operation failed", "op", "connect", "error", err)
 		wsConn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Error: failed to initialize SSH client: %v\n", err)))
 		return
 	}
 
-	session, client, err := sshClient.CreateSession()
+	session, client, err := sshClient.CreateSession(ctx)
 	if err != nil {
+		logger.Warn("ssh operation failed", "op", "session", "error", err)
 		wsConn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Error: failed to create SSH session: %v\n", err)))
 		return
 	}
@@ -136,16 +157,18 @@ func TerminalHandler(c *gin.Context, db *gorm.DB) {
 
 	// 4. Determine shell and start command
 	var startCmd string
+	out := wsWriter{ws: wsConn, mu: &sync.Mutex{}}
+	var output io.Writer = out
 	if containerID != "" {
 		// Connect to container's shell
 		var shellCmd string
 		// Try bash
-		_, err = sshClient.ExecuteCommand(fmt.Sprintf("docker exec %s bash -c 'exit'", containerID))
+		_, err = sshClient.ExecuteDockerCommand(c.Request.Context(), fmt.Sprintf("docker exec %s bash -c 'exit'", internalssh.ShellQuote(containerID)))
 		if err == nil {
 			shellCmd = "bash"
 		} else {
 			// Try sh
-			_, err = sshClient.ExecuteCommand(fmt.Sprintf("docker exec %s sh -c 'exit'", containerID))
+			_, err = sshClient.ExecuteDockerCommand(c.Request.Context(), fmt.Sprintf("docker exec %s sh -c 'exit'", internalssh.ShellQuote(containerID)))
 			if err == nil {
 				shellCmd = "sh"
 			} else {
@@ -153,14 +176,14 @@ func TerminalHandler(c *gin.Context, db *gorm.DB) {
 				return
 			}
 		}
-		startCmd = fmt.Sprintf("docker exec -it %s %s", containerID, shellCmd)
+		startCmd, output = sshClient.SudoTerminal(fmt.Sprintf("docker exec -it %s %s", internalssh.ShellQuote(containerID), shellCmd), out, stdinPipe)
 	} else {
 		// Connect to host's shell (default behavior if no containerID)
 		startCmd = "bash" // Default to bash for host, could also add detection here
-		_, err = sshClient.ExecuteCommand("bash -c 'exit'")
+		_, err = sshClient.ExecuteCommand(c.Request.Context(), "bash -c 'exit'")
 		if err != nil {
 			startCmd = "sh"
-			_, err = sshClient.ExecuteCommand("sh -c 'exit'")
+			_, err = sshClient.ExecuteCommand(c.Request.Context(), "sh -c 'exit'")
 			if err != nil {
 				wsConn.WriteMessage(websocket.TextMessage, []byte("Error: neither bash nor sh found on host\n"))
 				return
@@ -173,16 +196,54 @@ func TerminalHandler(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
+	// Keep the SSH connection from being dropped by firewalls while the terminal is idle
+	var keepAliveFailed <-chan error
+	if interval := config.GetInt(db, model.ConfigKeySSHKeepAlive); interval > 0 {
+		keepAliveCtx, stopKeepAlive := context.WithCancel(context.Background())
+		defer stopKeepAlive()
+		keepAliveFailed = client.KeepAlive(keepAliveCtx, time.Duration(interval)*time.Second)
+	}
+
+	// Hang up the remote shell and tell the client when the server shuts down or the SSH
+	// connection is lost
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			wsConn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(time.Second))
+			session.Signal(ssh.SIGHUP)
+			session.Close()
+			wsConn.Close()
+		case err := <-keepAliveFailed:
+			logger.Info("ssh keepalive failed, closing the terminal", "error", err)
+			wsConn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(CloseSSHLost, "ssh connection lost"),
+				time.Now().Add(time.Second))
+			session.Close()
+			wsConn.Close()
+		case <-finished:
+		}
+	}()
+
 	// 5. Pipe Data
 	var wg sync.WaitGroup
 	wg.Add(2)
 
+	// In demo mode only whitelisted commands reach the shell
+	var filter *demoInput
+	if demo.Enabled() {
+		filter = &demoInput{rejected: apierror.T(c, "demo_command_blocked", strings.Join(demo.Commands(), ", "))}
+	}
+
 	// SSH -> WebSocket
 	go func() {
 		defer wg.Done()
-		_, err := io.Copy(wsWriter{wsConn}, stdoutPipe)
+		_, err := io.Copy(output, stdoutPipe)
 		if err != nil && err != io.EOF {
-			log.Printf("Error copying from SSH to WebSocket: %v", err)
+			logger.Debug("error copying from SSH to WebSocket", "error", err)
 		}
 	}()
 
@@ -196,27 +257,35 @@ func TerminalHandler(c *gin.Context, db *gorm.DB) {
 					// Normal closure, exit loop
 					break
 				}
-				log.Printf("Error reading WebSocket message: %v", err)
+				logger.Debug("error reading WebSocket message", "error", err)
 				break
 			}
 
 			var msg WebSocketMessage
 			if err := json.Unmarshal(p, &msg); err != nil {
-				log.Printf("Error unmarshaling WebSocket message: %v", err)
+				logger.Debug("error unmarshaling WebSocket message", "error", err)
 				continue
 			}
 
 			switch msg.Type {
 			case "input":
-				if _, err := stdinPipe.Write([]byte(msg.Data)); err != nil {
-					log.Printf("Error writing to stdin pipe: %v", err)
+				input := msg.Data
+				if filter != nil {
+					var echo string
+					input, echo = filter.feed(msg.Data)
+					if echo != "" {
+						out.Write([]byte(echo))
+					}
+				}
+				if _, err := stdinPipe.Write([]byte(input)); err != nil {
+					logger.Debug("error writing to stdin pipe", "error", err)
 				}
 			case "resize":
 				if err := session.WindowChange(msg.Rows, msg.Cols); err != nil {
-					log.Printf("Error resizing SSH terminal: %v", err)
+					logger.Debug("error resizing SSH terminal", "error", err)
 				}
 			default:
-				log.Printf("Unknown WebSocket message type: %s", msg.Type)
+				logger.Debug("unknown WebSocket message type", "type", msg.Type)
 			}
 		}
 	}()
@@ -245,11 +314,16 @@ func (w *wsReader) Read(p []byte) (n int, err error) {
 	return w.buf.Read(p)
 }
 
+// wsWriter sends terminal output as text messages. mu serializes writers, since a WebSocket
+// connection supports only one at a time.
 type wsWriter struct {
 	ws *websocket.Conn
+	mu *sync.Mutex
 }
 
 func (w wsWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	err = w.ws.WriteMessage(websocket.TextMessage, p)
 	if err != nil {
 		return 0, err
@@ -0,0 +1,151 @@
+package apierror
+
+import (
+	"net/http"
+
+	"docker-pulse/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code is a stable, machine readable error identifier. Clients may rely on it, so never rename one.
+type Code string
+
+const (
+	InvalidRequest     Code = "invalid_request"
+	InvalidID          Code = "invalid_id"
+	Unauthorized       Code = "unauthorized"
+	InvalidToken       Code = "invalid_token"
+	SessionExpired     Code = "session_expired"
+	InvalidCredentials Code = "invalid_credentials"
+	PermissionDenied   Code = "permission_denied"
+	AdminRequired      Code = "admin_required"
+	NotFound           Code = "not_found"
+	ServerNotFound     Code = "server_not_found"
+	UserNotFound       Code = "user_not_found"
+	Conflict           Code = "conflict"
+	ValidationFailed   Code = "validation_failed"
+	SSHUnreachable     Code = "ssh_unreachable"
+	SSHCommandFailed   Code = "ssh_command_failed"
+	DatabaseError      Code = "database_error"
+	NotConfigured      Code = "not_configured"
+	DeliveryFailed     Code = "delivery_failed"
+	Maintenance        Code = "maintenance"
+	CircuitOpen        Code = "circuit_open"
+	ServerBusy         Code = "server_busy"
+	Timeout            Code = "timeout"
+	Internal           Code = "internal_error"
+
+	// RegistryUnauthorized, RegistryRateLimited and RegistryError report failures of a container
+	// registry
+	RegistryUnauthorized Code = "registry_unauthorized"
+	RegistryRateLimited  Code = "registry_rate_limited"
+	RegistryError        Code = "registry_error"
+	// NotSwarmManager rejects swarm service commands on nodes that cannot run them
+	NotSwarmManager Code = "not_swarm_manager"
+	// DemoMode rejects changes while the instance runs as a read-only demo
+	DemoMode Code = "demo_mode"
+	// JumpHostUnreachable reports a failure on the hop to a server's jump host rather than the server
+	JumpHostUnreachable Code = "jump_host_unreachable"
+	// SSHUnavailable rejects features that run commands on servers reached over the Docker API only
+	SSHUnavailable Code = "ssh_unavailable"
+	// RangeNotSatisfiable rejects reads of a part of a file that starts past its end
+	RangeNotSatisfiable Code = "range_not_satisfiable"
+)
+
+// statuses holds the HTTP status of every code. Messages live in the per-language catalogs.
+var statuses = map[Code]int{
+	InvalidRequest:     http.StatusBadRequest,
+	InvalidID:          http.StatusBadRequest,
+	Unauthorized:       http.StatusUnauthorized,
+	InvalidToken:       http.StatusUnauthorized,
+	SessionExpired:     http.StatusUnauthorized,
+	InvalidCredentials: http.StatusUnauthorized,
+	PermissionDenied:   http.StatusForbidden,
+	AdminRequired:      http.StatusForbidden,
+	NotFound:           http.StatusNotFound,
+	ServerNotFound:     http.StatusNotFound,
+	UserNotFound:       http.StatusNotFound,
+	Conflict:           http.StatusConflict,
+	ValidationFailed:   http.StatusBadRequest,
+	SSHUnreachable:     http.StatusBadGateway,
+	SSHCommandFailed:   http.StatusBadGateway,
+	DatabaseError:      http.StatusInternalServerError,
+	NotConfigured:      http.StatusServiceUnavailable,
+	DeliveryFailed:     http.StatusBadGateway,
+	Maintenance:        http.StatusServiceUnavailable,
+	CircuitOpen:        http.StatusServiceUnavailable,
+	ServerBusy:         http.StatusServiceUnavailable,
+	Timeout:            http.StatusGatewayTimeout,
+	Internal:           http.StatusInternalServerError,
+
+	RegistryUnauthorized: http.StatusBadGateway,
+	RegistryRateLimited:  http.StatusTooManyRequests,
+	RegistryError:        http.StatusBadGateway,
+	NotSwarmManager:      http.StatusConflict,
+	DemoMode:             http.StatusForbidden,
+	JumpHostUnreachable:  http.StatusBadGateway,
+	SSHUnavailable:       http.StatusConflict,
+	RangeNotSatisfiable:  http.StatusRequestedRangeNotSatisfiable,
+}
+
+// Body is the payload of an error response
+type Body struct {
+	Code      Code   `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	// Field names the offending input, when there is one
+	Field string `json:"field,omitempty"`
+	// ServerID names the managed server involved, when there is one
+	ServerID uint `json:"server_id,omitempty"`
+}
+
+// Envelope is the JSON document of every error response
+type Envelope struct {
+	Error Body `json:"error"`
+}
+
+// Status returns the HTTP status of a code
+func Status(code Code) int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Message returns the default message of a code in the given language
+func Message(lang string, code Code) string {
+	return text(lang, string(code))
+}
+
+// Abort responds with the code's default message in the request's language and stops the handler chain
+func Abort(c *gin.Context, code Code) {
+	AbortMessage(c, code, Message(Language(c), code))
+}
+
+// AbortMessage responds with a custom user-safe message, usually one built with T
+func AbortMessage(c *gin.Context, code Code, message string) {
+	write(c, Body{Code: code, Message: message})
+}
+
+// AbortField responds with a message about a specific input field
+func AbortField(c *gin.Context, code Code, field, message string) {
+	write(c, Body{Code: code, Message: message, Field: field})
+}
+
+// AbortCause logs err as the cause and responds with the code's default message, so internal
+// details such as database or SSH errors never reach the client
+func AbortCause(c *gin.Context, code Code, err error) {
+	logger := logging.L(c)
+	if Status(code) >= http.StatusInternalServerError {
+		logger.Error("request failed", "code", code, "error", err)
+	} else {
+		logger.Warn("request failed", "code", code, "error", err)
+	}
+	Abort(c, code)
+}
+
+func write(c *gin.Context, body Body) {
+	body.RequestID = logging.RequestID(c)
+	c.AbortWithStatusJSON(Status(body.Code), Envelope{Error: body})
+}
@@ -0,0 +1,349 @@
+package apierror
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported languages
+const (
+	English = "en"
+	Chinese = "zh-CN"
+)
+
+// LanguageKey is the context key holding the current user's preferred language, if any
+const LanguageKey = "language"
+
+// catalogs holds every user-facing message per language. Codes are keyed by their value, the
+// other keys are messages with fmt arguments for AbortMessage and AbortField.
+var catalogs = map[string]map[string]string{
+	English: {
+		string(InvalidRequest):     "The request is invalid.",
+		string(InvalidID):          "The ID in the request is invalid.",
+		string(Unauthorized):       "Authentication is required.",
+		string(InvalidToken):       "The access token is invalid.",
+		string(SessionExpired):     "Your session has expired, please log in again.",
+		string(InvalidCredentials): "Invalid username or password.",
+		string(PermissionDenied):   "You do not have permission to perform this action.",
+		string(AdminRequired):      "This action requires the admin role.",
+		string(NotFound):           "The requested resource was not found.",
+		string(ServerNotFound):     "Server not found.",
+		string(UserNotFound):       "User not found.",
+		string(Conflict):           "The request conflicts with the current state.",
+		string(ValidationFailed):   "One or more values are invalid.",
+		string(SSHUnreachable):     "Could not connect to the server over SSH.",
+		string(SSHCommandFailed):   "The command on the server failed.",
+		string(DatabaseError):      "A database error occurred.",
+		string(NotConfigured):      "This feature is not configured.",
+		string(DeliveryFailed):     "The message could not be delivered.",
+		string(Maintenance):        "The panel is under maintenance, changes are disabled for now.",
+		string(CircuitOpen):        "The server is temporarily marked unreachable after repeated SSH failures.",
+		string(ServerBusy):         "Too many SSH sessions are open on the server, try again shortly.",
+		string(Timeout):            "The server did not respond in time.",
+		string(Internal):           "An internal error occurred.",
+
+		string(RegistryUnauthorized): "The container registry denied access.",
+		string(RegistryRateLimited):  "The container registry's rate limit was reached, try again later.",
+		string(RegistryError):        "The container registry could not be reached.",
+		string(NotSwarmManager):      "The server is not a swarm manager node, swarm services can only be changed on managers.",
+		string(DemoMode):             "This is a read-only demo, changes are disabled.",
+		string(JumpHostUnreachable):  "Could not connect to the server's jump host over SSH.",
+		string(SSHUnavailable):       "The server is managed over the Docker API without SSH, this feature needs SSH.",
+		string(RangeNotSatisfiable):  "The requested range starts past the end of the file.",
+
+		"role_required":              "This action requires the %s role.",
+		"server_access_required":     "This action requires '%s' access to the server.",
+		"host_shell_admin_only":      "Host shell access is restricted to administrators.",
+		"server_id_required":         "server_id is required.",
+		"backup_file_required":       "Backup file is required.",
+		"current_password_incorrect": "Current password incorrect.",
+		"telegram_not_configured":    "The Telegram bot token is not configured.",
+		"telegram_id_missing":        "Telegram user ID not found in data.",
+		"telegram_id_invalid":        "Invalid Telegram ID format.",
+		"telegram_already_bound":     "Telegram ID %d is already bound to user %s.",
+		"unknown_cache":              "Unknown cache: %s",
+		"unknown_action":             "Unknown container action.",
+		"path_required":              "File path is required.",
+		"config_value_type":          "Value for %s must be a string, number or boolean.",
+		"unsupported_language":       "Unsupported language.",
+		"invalid_json":               "The request body is not valid JSON.",
+		"smtp_not_configured":        "SMTP is not configured, set smtp_host and smtp_from first.",
+		"test_recipient_required":    "Enter a recipient or add an email address to your account.",
+		"notification_channel":       "Unknown notification channel: %s",
+		"email_channel_requires":     "The email channel requires an email address.",
+		"telegram_channel_requires":  "The telegram channel requires a bound Telegram account.",
+		"webhook_url":                "The webhook URL must be an absolute http(s) URL.",
+		"webhook_event":              "Unknown event type: %s",
+		"bundle_conflicts":           "The bundle has %d conflicts with existing data, run a dry run to see them.",
+		"invalid_port_spec":          "Invalid port mapping: %s",
+		"port_in_use_container":      "Host port %d/%s is already published by container %s.",
+		"port_in_use_process":        "Host port %d/%s is already in use by process %s.",
+		"port_in_use":                "Host port %d/%s is already in use.",
+		"listeners_unavailable":      "Could not list the listening sockets on the server, only container ports are shown.",
+		"daemon_option_unknown":      "Unknown docker daemon option: %s",
+		"daemon_option_type":         "Docker daemon option %s must be a %s.",
+		"daemon_restart_confirm":     "Enter the server name %s to confirm restarting docker.",
+		"daemon_restored":            "Docker did not come back after the restart, the previous daemon.json was restored and docker restarted.",
+		"daemon_restored_down":       "Docker did not come back after the restart, the previous daemon.json was restored but docker is still not running.",
+		"daemon_restore_failed":      "Docker did not come back after the restart and the previous daemon.json could not be restored.",
+		"image_no_repository":        "The container's image %s does not name a registry repository.",
+		"registry_denied":            "The registry %s denied access, add a login for it under registries.",
+		"registry_repo_not_found":    "The repository %s does not exist.",
+		"image_not_pulled":           "The container's image %s was not pulled from its registry, there is no digest to compare.",
+		"master_key_required":        "Storing registry logins requires DOCKERMANAGER_MASTER_KEY to be set.",
+		"log_truncate_confirm":       "Enter %s to confirm truncating the container's log.",
+		"log_config_empty":           "Set max_size, max_file or both.",
+		"log_config_recreate":        "The container is recreated to apply the log settings. It restarts, gets a new ID, and the log written so far is removed with the old container.",
+		"prune_until_invalid":        "Invalid until %s, expected a duration such as 24h.",
+		"prune_until_volumes":        "Volumes have no creation filter, remove until or do not prune volumes.",
+		"prune_label_invalid":        "Invalid label filter %s, expected key or key=value.",
+		"prune_labels_build_cache":   "The build cache has no labels, remove the label filters or do not prune the build cache.",
+		"restart_policy_invalid":     "Invalid restart policy %s, expected no, always, unless-stopped, on-failure or on-failure:<max>.",
+		"container_not_running":      "Container %s is not running, start it to list its processes.",
+		"signal_invalid":             "Invalid signal %s, expected one of %s.",
+		"container_name_invalid":     "Invalid container name %s, expected at least two letters, digits, _, . or -, starting with a letter or digit.",
+		"invalid_volume_spec":        "Invalid volume mount %s, expected source:target[:options] with a host path or volume name and an absolute target.",
+		"log_time_invalid":           "%s must be an RFC 3339 time or a duration like 30m.",
+		"log_pattern_invalid":        "%s is not a valid regular expression.",
+		"server_id_mismatch":         "server_id %d does not match the server %d in the path.",
+		"container_not_found":        "Container %s does not exist on this server.",
+		"file_path_invalid":          "%s must be an absolute path below /.",
+		"file_mode_invalid":          "mode must be an octal mode such as 755.",
+		"file_not_found":             "%s does not exist.",
+		"file_exists":                "%s already exists.",
+		"directory_not_empty":        "%s is not empty; set recursive to delete it.",
+		"file_binary_hint":           "The file looks binary; download it with the files/download endpoint, or ask for encoding=base64.",
+		"file_too_large_hint":        "The file is larger than %s bytes; download it with the files/download endpoint.",
+		"file_range_conflict":        "Use either offset and limit, head or tail.",
+		"file_range_offset":          "offset %d is past the end of the file, which is %d bytes.",
+		"compose_project_not_found":  "No container of the compose project %s exists on this server.",
+		"compose_file_not_found":     "The compose file %s does not exist on the server anymore.",
+		"compose_file_unknown":       "Docker compose did not record the config files of project %s.",
+		"compose_file_other":         "file must be one of the config files of project %s.",
+		"compose_file_invalid":       "The compose file is invalid: %s",
+		"image_pull_tag_conflict":    "tag cannot be set when image %s already names a tag or digest.",
+		"volume_not_found":           "The volume %s does not exist on this server.",
+		"volume_in_use":              "The volume %s is used by %s. Remove those containers first.",
+		"network_not_found":          "The network %s does not exist on this server.",
+		"network_in_use":             "The network %s has containers attached: %s. Disconnect them first.",
+		"network_built_in":           "The network %s is created by docker and cannot be removed.",
+		"network_address_outside":    "%s must lie within the subnet, which it requires.",
+		"swarm_update_empty":         "Set an image, force or both.",
+		"swarm_service_not_found":    "The swarm service %s does not exist.",
+		"build_arg_invalid":          "Invalid build argument %s, expected NAME=value.",
+		"build_source_required":      "Send either a Dockerfile or a build context, not both.",
+		"build_context_too_large":    "The build context is larger than %d MB.",
+		"template_name_taken":        "A template named %s already exists.",
+		"template_values_missing":    "Missing values for the placeholders %s.",
+		"invalid_env":                "Invalid environment variable %s, expected NAME=value.",
+		"demo_command_blocked":       "Only these commands are available in the demo: %s",
+		"key_passphrase_required":    "The private key is encrypted, please provide its passphrase.",
+		"key_passphrase_wrong":       "The passphrase does not match the private key.",
+		"key_unsupported":            "Unsupported private key, expected a PEM encoded RSA, ECDSA or Ed25519 key.",
+		"tls_bundle_invalid":         "Invalid TLS bundle, expected the PEM encoded client certificate, its private key and the CA certificate.",
+		"path_not_a_file":            "The path is not a regular file.",
+		"path_not_a_directory":       "The path is not a directory.",
+		"validation_required":        "%s is required.",
+		"validation_min":             "%s must be at least %s.",
+		"validation_max":             "%s must be at most %s.",
+		"validation_oneof":           "%s must be one of: %s.",
+		"validation_email":           "%s must be a valid email address.",
+		"validation_type":            "%s must be of type %s.",
+		"validation_invalid":         "%s is invalid.",
+	},
+	Chinese: {
+		string(InvalidRequest):     "请求无效。",
+		string(InvalidID):          "请求中的 ID 无效。",
+		string(Unauthorized):       "需要登录。",
+		string(InvalidToken):       "访问令牌无效。",
+		string(SessionExpired):     "会话已过期，请重新登录。",
+		string(InvalidCredentials): "用户名或密码错误。",
+		string(PermissionDenied):   "你没有执行此操作的权限。",
+		string(AdminRequired):      "此操作需要管理员角色。",
+		string(NotFound):           "请求的资源不存在。",
+		string(ServerNotFound):     "服务器不存在。",
+		string(UserNotFound):       "用户不存在。",
+		string(Conflict):           "请求与当前状态冲突。",
+		string(ValidationFailed):   "一个或多个值无效。",
+		string(SSHUnreachable):     "无法通过 SSH 连接到服务器。",
+		string(SSHCommandFailed):   "服务器上的命令执行失败。",
+		string(DatabaseError):      "数据库错误。",
+		string(NotConfigured):      "此功能尚未配置。",
+		string(DeliveryFailed):     "消息发送失败。",
+		string(Maintenance):        "面板正在维护，暂时无法进行修改。",
+		string(CircuitOpen):        "SSH 连接多次失败，该服务器暂时被标记为不可达。",
+		string(ServerBusy):         "服务器上打开的 SSH 会话过多，请稍后重试。",
+		string(Timeout):            "服务器未能及时响应。",
+		string(Internal):           "服务器内部错误。",
+
+		string(RegistryUnauthorized): "镜像仓库拒绝访问。",
+		string(RegistryRateLimited):  "已达到镜像仓库的请求频率限制，请稍后重试。",
+		string(RegistryError):        "无法连接镜像仓库。",
+		string(NotSwarmManager):      "该服务器不是 Swarm 管理节点，只能在管理节点上修改 Swarm 服务。",
+		string(DemoMode):             "这是只读演示实例，无法进行修改。",
+		string(JumpHostUnreachable):  "无法通过 SSH 连接到服务器的跳板机。",
+		string(SSHUnavailable):       "该服务器仅通过 Docker API 管理，没有 SSH，此功能需要 SSH。",
+		string(RangeNotSatisfiable):  "请求的范围超出了文件末尾。",
+
+		"role_required":              "此操作需要 %s 角色。",
+		"server_access_required":     "此操作需要对该服务器的 '%s' 权限。",
+		"host_shell_admin_only":      "仅管理员可以访问主机终端。",
+		"server_id_required":         "缺少 server_id。",
+		"backup_file_required":       "请上传备份文件。",
+		"current_password_incorrect": "当前密码错误。",
+		"telegram_not_configured":    "尚未配置 Telegram 机器人令牌。",
+		"telegram_id_missing":        "数据中缺少 Telegram 用户 ID。",
+		"telegram_id_invalid":        "Telegram ID 格式无效。",
+		"telegram_already_bound":     "Telegram ID %d 已绑定到用户 %s。",
+		"unknown_cache":              "未知的缓存：%s",
+		"unknown_action":             "未知的容器操作。",
+		"path_required":              "缺少文件路径。",
+		"config_value_type":          "%s 的值必须是字符串、数字或布尔值。",
+		"unsupported_language":       "不支持的语言。",
+		"invalid_json":               "请求体不是有效的 JSON。",
+		"smtp_not_configured":        "尚未配置 SMTP，请先设置 smtp_host 和 smtp_from。",
+		"test_recipient_required":    "请填写收件人，或为你的账号添加邮箱地址。",
+		"notification_channel":       "未知的通知渠道：%s",
+		"email_channel_requires":     "邮件通知需要填写邮箱地址。",
+		"telegram_channel_requires":  "Telegram 通知需要先绑定 Telegram 账号。",
+		"webhook_url":                "Webhook 地址必须是完整的 http(s) URL。",
+		"webhook_event":              "未知的事件类型：%s",
+		"bundle_conflicts":           "配置包与现有数据有 %d 处冲突，请先试运行查看。",
+		"invalid_port_spec":          "无效的端口映射：%s",
+		"port_in_use_container":      "主机端口 %d/%s 已被容器 %s 占用。",
+		"port_in_use_process":        "主机端口 %d/%s 已被进程 %s 占用。",
+		"port_in_use":                "主机端口 %d/%s 已被占用。",
+		"listeners_unavailable":      "无法获取服务器上的监听端口，仅显示容器端口。",
+		"daemon_option_unknown":      "未知的 Docker 守护进程配置项：%s",
+		"daemon_option_type":         "Docker 守护进程配置项 %s 必须是 %s。",
+		"daemon_restart_confirm":     "请输入服务器名称 %s 以确认重启 Docker。",
+		"daemon_restored":            "Docker 重启后未能恢复运行，已还原之前的 daemon.json 并重新启动 Docker。",
+		"daemon_restored_down":       "Docker 重启后未能恢复运行，已还原之前的 daemon.json，但 Docker 仍未运行。",
+		"daemon_restore_failed":      "Docker 重启后未能恢复运行，且无法还原之前的 daemon.json。",
+		"image_no_repository":        "容器的镜像 %s 未指向镜像仓库。",
+		"registry_denied":            "镜像仓库 %s 拒绝访问，请在镜像仓库登录中为其添加凭据。",
+		"registry_repo_not_found":    "镜像仓库 %s 不存在。",
+		"image_not_pulled":           "容器的镜像 %s 不是从镜像仓库拉取的，没有可比较的摘要。",
+		"master_key_required":        "保存镜像仓库登录凭据需要设置 DOCKERMANAGER_MASTER_KEY。",
+		"log_truncate_confirm":       "请输入 %s 以确认清空容器日志。",
+		"log_config_empty":           "请设置 max_size、max_file 或两者。",
+		"log_config_recreate":        "应用日志设置需要重建容器。容器会重启并获得新的 ID，已写入的日志会随旧容器一起删除。",
+		"prune_until_invalid":        "无效的 until 值 %s，应为 24h 这样的时长。",
+		"prune_until_volumes":        "数据卷不支持按创建时间过滤，请去掉 until 或不清理数据卷。",
+		"prune_label_invalid":        "无效的标签过滤条件 %s，格式应为 key 或 key=value。",
+		"prune_labels_build_cache":   "构建缓存没有标签，请去掉标签过滤条件或不清理构建缓存。",
+		"restart_policy_invalid":     "无效的重启策略 %s，应为 no、always、unless-stopped、on-failure 或 on-failure:<最大次数>。",
+		"container_not_running":      "容器 %s 未在运行，启动后才能查看其进程。",
+		"signal_invalid":             "无效的信号 %s，应为以下之一：%s。",
+		"container_name_invalid":     "无效的容器名称 %s，应至少两个字符，由字母、数字、_、. 或 - 组成，且以字母或数字开头。",
+		"invalid_volume_spec":        "无效的挂载 %s，格式应为 源:目标[:选项]，源为主机路径或卷名，目标为绝对路径。",
+		"log_time_invalid":           "%s 必须是 RFC 3339 时间或 30m 这样的时长。",
+		"log_pattern_invalid":        "%s 不是有效的正则表达式。",
+		"server_id_mismatch":         "server_id %d 与路径中的服务器 %d 不一致。",
+		"container_not_found":        "此服务器上不存在容器 %s。",
+		"file_path_invalid":          "%s 必须是 / 之下的绝对路径。",
+		"file_mode_invalid":          "mode 必须是八进制权限，例如 755。",
+		"file_not_found":             "%s 不存在。",
+		"file_exists":                "%s 已存在。",
+		"directory_not_empty":        "%s 不是空目录；设置 recursive 以删除它。",
+		"file_binary_hint":           "该文件疑似二进制文件；请通过 files/download 接口下载，或使用 encoding=base64 获取。",
+		"file_too_large_hint":        "该文件大于 %s 字节；请通过 files/download 接口下载。",
+		"file_range_conflict":        "offset 与 limit、head、tail 只能选择其一。",
+		"file_range_offset":          "offset %d 超出了文件末尾，文件大小为 %d 字节。",
+		"compose_project_not_found":  "此服务器上没有属于 Compose 项目 %s 的容器。",
+		"compose_file_not_found":     "服务器上已不存在 Compose 文件 %s。",
+		"compose_file_unknown":       "Docker Compose 未记录项目 %s 的配置文件。",
+		"compose_file_other":         "file 必须是项目 %s 的配置文件之一。",
+		"compose_file_invalid":       "Compose 文件无效：%s",
+		"image_pull_tag_conflict":    "镜像 %s 已指定标签或摘要，不能再设置 tag。",
+		"volume_not_found":           "此服务器上不存在卷 %s。",
+		"volume_in_use":              "卷 %s 正被 %s 使用，请先删除这些容器。",
+		"network_not_found":          "此服务器上不存在网络 %s。",
+		"network_in_use":             "网络 %s 仍连接着容器：%s，请先断开它们。",
+		"network_built_in":           "网络 %s 由 Docker 自带，不能删除。",
+		"network_address_outside":    "%s 必须位于子网之内，且需要先指定子网。",
+		"swarm_update_empty":         "请设置镜像、强制更新或两者。",
+		"swarm_service_not_found":    "Swarm 服务 %s 不存在。",
+		"build_arg_invalid":          "无效的构建参数 %s，格式应为 NAME=value。",
+		"build_source_required":      "请提供 Dockerfile 或构建上下文之一，不能同时提供。",
+		"build_context_too_large":    "构建上下文超过 %d MB。",
+		"template_name_taken":        "已存在名为 %s 的模板。",
+		"template_values_missing":    "缺少占位符 %s 的值。",
+		"invalid_env":                "无效的环境变量 %s，格式应为 NAME=value。",
+		"demo_command_blocked":       "演示实例中只能运行以下命令：%s",
+		"key_passphrase_required":    "私钥已加密，请提供私钥密码。",
+		"key_passphrase_wrong":       "私钥密码错误。",
+		"key_unsupported":            "不支持的私钥格式，请使用 PEM 编码的 RSA、ECDSA 或 Ed25519 私钥。",
+		"tls_bundle_invalid":         "无效的 TLS 证书包，请提供 PEM 编码的客户端证书、其私钥与 CA 证书。",
+		"path_not_a_file":            "该路径不是普通文件。",
+		"path_not_a_directory":       "该路径不是目录。",
+		"validation_required":        "%s 为必填项。",
+		"validation_min":             "%s 不能小于 %s。",
+		"validation_max":             "%s 不能大于 %s。",
+		"validation_oneof":           "%s 必须是以下之一：%s。",
+		"validation_email":           "%s 必须是有效的邮箱地址。",
+		"validation_type":            "%s 的类型必须是 %s。",
+		"validation_invalid":         "%s 无效。",
+	},
+}
+
+// T returns the message for key in the request's language, formatted with args
+func T(c *gin.Context, key string, args ...interface{}) string {
+	return text(Language(c), key, args...)
+}
+
+// text looks a message up in the language's catalog, falling back to English and then to the key
+func text(lang, key string, args ...interface{}) string {
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		if msg, ok = catalogs[English][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Language returns the language for messages to the current request: the user's stored
+// preference, otherwise the best supported match of the Accept-Language header
+func Language(c *gin.Context) string {
+	if lang, ok := Supported(c.GetString(LanguageKey)); ok {
+		return lang
+	}
+	return ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+}
+
+// Supported maps a language tag such as en-US, zh or zh-Hans-CN to a supported language
+func Supported(tag string) (string, bool) {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	switch primary {
+	case "en":
+		return English, true
+	case "zh":
+		return Chinese, true
+	}
+	return "", false
+}
+
+// ParseAcceptLanguage picks the supported language with the highest weight, English by default
+func ParseAcceptLanguage(header string) string {
+	best, bestQ := English, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if lang, ok := Supported(tag); ok && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
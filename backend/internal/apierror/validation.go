@@ -0,0 +1,70 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report validation errors with JSON field names instead of Go struct field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
+
+// Invalid responds to a request body that could not be bound. Validation failures become
+// localized field-level messages; the binding error itself is only logged.
+func Invalid(c *gin.Context, err error) {
+	lang := Language(c)
+	var fieldErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &fieldErrs) && len(fieldErrs) > 0:
+		messages := make([]string, len(fieldErrs))
+		for i, fe := range fieldErrs {
+			messages[i] = fieldMessage(lang, fe)
+		}
+		separator := " "
+		if lang == Chinese {
+			separator = ""
+		}
+		write(c, Body{Code: ValidationFailed, Message: strings.Join(messages, separator), Field: fieldErrs[0].Field()})
+	case errors.As(err, &typeErr):
+		write(c, Body{Code: ValidationFailed, Message: text(lang, "validation_type", typeErr.Field, typeErr.Type.String()), Field: typeErr.Field})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		write(c, Body{Code: InvalidRequest, Message: text(lang, "invalid_json")})
+	default:
+		AbortCause(c, InvalidRequest, err)
+	}
+}
+
+// fieldMessage describes a failed validation rule
+func fieldMessage(lang string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return text(lang, "validation_required", fe.Field())
+	case "min", "gte":
+		return text(lang, "validation_min", fe.Field(), fe.Param())
+	case "max", "lte":
+		return text(lang, "validation_max", fe.Field(), fe.Param())
+	case "oneof":
+		return text(lang, "validation_oneof", fe.Field(), strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "email":
+		return text(lang, "validation_email", fe.Field())
+	}
+	return text(lang, "validation_invalid", fe.Field())
+}
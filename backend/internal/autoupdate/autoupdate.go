@@ -0,0 +1,262 @@
+// Package autoupdate checks the images of all containers for updates on a schedule and updates
+// the containers opted into it during the daily maintenance window
+package autoupdate
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/maintenance"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/notify"
+	"docker-pulse/internal/registry"
+	"docker-pulse/internal/ssh"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// pollInterval is how often due checks and the maintenance window are looked at
+	pollInterval = 5 * time.Minute
+	// checkTimeout bounds checking the images of one server's containers
+	checkTimeout = 5 * time.Minute
+	// updateTimeout bounds the update of one container, pull included. Updates are not held
+	// to the SSH command timeout, since pulls take longer.
+	updateTimeout = 30 * time.Minute
+	// runsKept is the number of runs kept in each server's history
+	runsKept = 100
+)
+
+// Start runs the checks and updates until ctx is cancelled. onUpdate, if set, is called with the
+// server ID after each update.
+func Start(ctx context.Context, db *gorm.DB, onUpdate func(serverID uint)) {
+	go func() {
+		var lastCheck time.Time
+		for {
+			// Re-read the settings every cycle so config changes apply without a restart
+			interval := time.Duration(config.GetInt(db, model.ConfigKeyAutoUpdateInterval)) * time.Hour
+			if time.Since(lastCheck) >= interval {
+				lastCheck = time.Now()
+				checkAll(ctx, db)
+			}
+			if start, ok := windowStart(db, time.Now()); ok && !maintenance.Active() {
+				updateDue(ctx, db, start, onUpdate)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+		}
+	}()
+}
+
+// windowStart returns when the maintenance window now lies in started, and false when now lies
+// outside of it
+func windowStart(db *gorm.DB, now time.Time) (time.Time, bool) {
+	value, _ := config.Get(db, model.ConfigKeyAutoUpdateWindow)
+	from, to, err := config.ParseWindow(value)
+	if err != nil {
+		slog.Warn("auto-update: invalid maintenance window", "window", value, "error", err)
+		return time.Time{}, false
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+	switch {
+	case from < to && offset >= from && offset < to:
+		return midnight.Add(from), true
+	case from > to && offset >= from:
+		return midnight.Add(from), true
+	case from > to && offset < to:
+		// The window started yesterday and spans midnight
+		return midnight.AddDate(0, 0, -1).Add(from), true
+	}
+	return time.Time{}, false
+}
+
+// checkAll checks the images of the containers of every server
+func checkAll(ctx context.Context, db *gorm.DB) {
+	var servers []model.Server
+	if err := db.Find(&servers).Error; err != nil {
+		slog.Error("auto-update: failed to fetch servers", "error", err)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func(s model.Server) {
+			defer wg.Done()
+			checkServer(ctx, db, s)
+		}(server)
+	}
+	wg.Wait()
+}
+
+// checkServer compares the image of each container of a server with its registry and stores
+// the results. The results of containers that are gone are dropped.
+func checkServer(ctx context.Context, db *gorm.DB, server model.Server) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	backend, err := ssh.NewServerBackend(&server)
+	if err != nil {
+		// The server's credentials are unusable
+		return
+	}
+	containers, err := backend.ListContainers(ctx)
+	if err != nil {
+		slog.Warn("auto-update: failed to list containers", "server_id", server.ID, "error", err)
+		return
+	}
+
+	names := []string{}
+	for _, ct := range containers {
+		check := model.ImageCheck{ServerID: server.ID, Container: ct.Name, Image: ct.Image, CheckedAt: time.Now()}
+		ref, err := dockerapi.ContainerImage(ctx, backend, ct.ID)
+		if err == nil {
+			var update model.ImageUpdate
+			check.Image = ref.Image
+			if update, err = registry.CheckUpdate(ctx, db, server.ID, ref.Image, ref.Platform, ref.RepoDigests, false); err == nil {
+				check.LocalDigest, check.RemoteDigest, check.HasUpdate = update.LocalDigests[0], update.RemoteDigest, update.HasUpdate
+			}
+		}
+		if err != nil {
+			check.Error = err.Error()
+		}
+		saveCheck(db, check)
+		names = append(names, ct.Name)
+	}
+	q := db.Where("server_id = ?", server.ID)
+	if len(names) > 0 {
+		q = q.Where("container NOT IN ?", names)
+	}
+	q.Delete(&model.ImageCheck{})
+}
+
+// saveCheck replaces the stored check of a container
+func saveCheck(db *gorm.DB, check model.ImageCheck) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("server_id = ? AND container = ?", check.ServerID, check.Container).Delete(&model.ImageCheck{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&check).Error
+	})
+	if err != nil {
+		slog.Error("auto-update: failed to store an image check", "server_id", check.ServerID, "container", check.Container, "error", err)
+	}
+}
+
+// updateDue updates the opted in containers with a newer image that have not been tried since
+// the window started. The containers of a server are updated one at a time.
+func updateDue(ctx context.Context, db *gorm.DB, windowStart time.Time, onUpdate func(serverID uint)) {
+	var checks []model.ImageCheck
+	err := db.Where("has_update = ?", true).
+		Where("EXISTS (?)", db.Model(&model.ContainerAutoUpdate{}).Select("1").
+			Where("container_auto_updates.server_id = image_checks.server_id AND container_auto_updates.container = image_checks.container")).
+		Where("NOT EXISTS (?)", db.Model(&model.AutoUpdateRun{}).Select("1").
+			Where("auto_update_runs.server_id = image_checks.server_id AND auto_update_runs.container = image_checks.container AND auto_update_runs.started_at >= ?", windowStart)).
+		Find(&checks).Error
+	if err != nil {
+		slog.Error("auto-update: failed to load due updates", "error", err)
+		return
+	}
+	byServer := map[uint][]model.ImageCheck{}
+	for _, check := range checks {
+		byServer[check.ServerID] = append(byServer[check.ServerID], check)
+	}
+
+	var wg sync.WaitGroup
+	for serverID, checks := range byServer {
+		var server model.Server
+		if err := db.First(&server, serverID).Error; err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(server model.Server, checks []model.ImageCheck) {
+			defer wg.Done()
+			for _, check := range checks {
+				if ctx.Err() != nil {
+					return
+				}
+				updateContainer(ctx, db, server, check)
+				if onUpdate != nil {
+					onUpdate(server.ID)
+				}
+			}
+			pruneRuns(db, server.ID)
+		}(server, checks)
+	}
+	wg.Wait()
+}
+
+// updateContainer replaces a container with one running the new image, records the outcome and notifies
+// the users of the server. A failed update restores the old container.
+func updateContainer(ctx context.Context, db *gorm.DB, server model.Server, check model.ImageCheck) {
+	ctx, cancel := context.WithTimeout(ssh.WithoutCommandTimeout(ctx), updateTimeout)
+	defer cancel()
+
+	run := model.AutoUpdateRun{
+		ServerID:  server.ID,
+		Container: check.Container,
+		Image:     check.Image,
+		OldDigest: check.LocalDigest,
+		Status:    model.AutoUpdateSucceeded,
+		StartedAt: time.Now(),
+	}
+	backend, err := ssh.NewServerBackend(&server)
+	if err == nil {
+		var id string
+		if id, err = dockerapi.UpdateContainer(ctx, backend, check.Container, nil); err == nil {
+			run.NewDigest = runningDigest(ctx, backend, id)
+		}
+	}
+	run.FinishedAt = time.Now()
+
+	ev := notify.Event{Type: notify.AutoUpdateSucceeded, ServerID: server.ID, ServerName: server.Name, Container: check.Container, Image: check.Image}
+	if err != nil {
+		run.Status, run.Error = model.AutoUpdateFailed, err.Error()
+		ev.Type, ev.Detail = notify.AutoUpdateFailed, err.Error()
+		slog.Warn("auto-update: update failed", "server_id", server.ID, "container", check.Container, "error", err)
+	} else {
+		if run.NewDigest != "" {
+			ev.Detail = run.OldDigest + " -> " + run.NewDigest
+			db.Model(&model.ImageCheck{}).Where("server_id = ? AND container = ?", server.ID, check.Container).
+				Updates(map[string]interface{}{"local_digest": run.NewDigest, "has_update": false})
+		}
+		slog.Info("auto-update: container updated", "server_id", server.ID, "container", check.Container, "duration", run.FinishedAt.Sub(run.StartedAt))
+	}
+	if err := db.Create(&run).Error; err != nil {
+		slog.Error("auto-update: failed to record run", "server_id", server.ID, "container", check.Container, "error", err)
+	}
+	notify.Notify(db, ev)
+}
+
+// runningDigest returns the repo digest of the image a container runs from its repository,
+// empty when it cannot be read
+func runningDigest(ctx context.Context, backend dockerapi.Backend, containerID string) string {
+	ref, err := dockerapi.ContainerImage(ctx, backend, containerID)
+	if err != nil {
+		return ""
+	}
+	parsed, err := registry.ParseReference(ref.Image)
+	if err != nil {
+		return ""
+	}
+	if digests := registry.LocalDigests(parsed, ref.RepoDigests); len(digests) > 0 {
+		return digests[0]
+	}
+	return ""
+}
+
+// pruneRuns keeps the newest runs of a server
+func pruneRuns(db *gorm.DB, serverID uint) {
+	var ids []uint
+	if err := db.Model(&model.AutoUpdateRun{}).Where("server_id = ?", serverID).
+		Order("id DESC").Offset(runsKept).Limit(1).Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return
+	}
+	db.Where("server_id = ? AND id <= ?", serverID, ids[0]).Delete(&model.AutoUpdateRun{})
+}
@@ -0,0 +1,438 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/migrate"
+	"docker-pulse/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	formatVersion = 1
+	manifestName  = "manifest.json"
+	databaseName  = "dockerpulse.db"
+	secretName    = "jwt.secret"
+	filePrefix    = "backup-"
+	fileSuffix    = ".tar.gz"
+	batchSize     = 500
+
+	// maxEntrySize caps every file extracted from an uploaded archive
+	maxEntrySize = 1 << 30
+)
+
+// ErrInvalidArchive is returned when an uploaded file is not a usable backup
+var ErrInvalidArchive = errors.New("invalid backup archive")
+
+// Manifest describes the contents of a backup archive
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Driver    string    `json:"driver"`
+}
+
+// Service creates and restores backups of the application database and JWT secret
+type Service struct {
+	db         *gorm.DB
+	driver     string
+	secretPath string
+	dir        string
+}
+
+// New creates a backup service. Stored backups are kept in dir. An empty secretPath leaves the
+// JWT secret out of backups and restores.
+func New(db *gorm.DB, driver, secretPath, dir string) *Service {
+	return &Service{db: db, driver: driver, secretPath: secretPath, dir: dir}
+}
+
+// Dir returns the directory holding stored backups
+func (s *Service) Dir() string {
+	return s.dir
+}
+
+// Write streams a tar.gz backup to w. Nothing is written to w if the snapshot fails.
+func (s *Service) Write(w io.Writer) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(s.dir, ".snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, databaseName)
+	if err := s.snapshot(dbPath); err != nil {
+		return fmt.Errorf("snapshot database: %w", err)
+	}
+
+	manifest, err := json.Marshal(Manifest{Version: formatVersion, CreatedAt: time.Now().UTC(), Driver: s.driver})
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeEntry(tw, manifestName, manifest); err != nil {
+		return err
+	}
+	if err := writeFile(tw, databaseName, dbPath); err != nil {
+		return err
+	}
+	if s.secretPath != "" {
+		if secret, err := os.ReadFile(s.secretPath); err == nil {
+			if err := writeEntry(tw, secretName, secret); err != nil {
+				return err
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Save writes a backup into the backups directory, prunes old ones and returns the file name
+func (s *Service) Save() (string, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return "", err
+	}
+	name := filePrefix + time.Now().UTC().Format("20060102-150405") + fileSuffix
+	tmp, err := os.CreateTemp(s.dir, ".partial-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := s.Write(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		return "", err
+	}
+
+	if err := s.prune(config.GetInt(s.db, model.ConfigKeyBackupRetention)); err != nil {
+		slog.Warn("backup: failed to prune old backups", "error", err)
+	}
+	return name, nil
+}
+
+// Stored lists the stored backup file names, newest first
+func (s *Service) Stored() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), filePrefix) && strings.HasSuffix(e.Name(), fileSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	// The timestamp in the name sorts lexically
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+func (s *Service) prune(keep int) error {
+	names, err := s.Stored()
+	if err != nil || keep < 1 || len(names) <= keep {
+		return err
+	}
+	for _, name := range names[keep:] {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshot writes a consistent SQLite copy of the database to path
+func (s *Service) snapshot(path string) error {
+	if s.driver == config.DriverSQLite {
+		return s.db.Exec("VACUUM INTO ?", path).Error
+	}
+
+	dst, err := openSQLite(path)
+	if err != nil {
+		return err
+	}
+	defer closeDB(dst)
+	// The migrations create the schema, so the copy records its version like a SQLite snapshot
+	if err := migrate.Run(dst); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return copyTables(tx, dst)
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// Restore validates an uploaded archive and replaces the contents of the database with it.
+// Every user's token version is bumped so existing sessions must log in again.
+// The JWT secret is written back to disk and takes effect after a restart.
+func (s *Service) Restore(r io.Reader) (*Manifest, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, err
+	}
+	tmpDir, err := os.MkdirTemp(s.dir, ".restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest, err := extract(r, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := openSQLite(filepath.Join(tmpDir, databaseName))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer closeDB(src)
+	// Archives of older versions are brought up to the current schema, so that the tables and
+	// columns added since exist to copy. Those of newer versions hold data this one would drop.
+	if unknown, err := migrate.Unknown(src); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	} else if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: made by a newer version, with migrations %s", ErrInvalidArchive, strings.Join(unknown, ", "))
+	}
+	if err := migrate.Run(src); err != nil {
+		return nil, fmt.Errorf("%w: upgrading the database failed: %v", ErrInvalidArchive, err)
+	}
+	for _, m := range model.All() {
+		if !src.Migrator().HasTable(m) {
+			return nil, fmt.Errorf("%w: database is missing table for %T", ErrInvalidArchive, m)
+		}
+	}
+	var admins int64
+	if err := src.Model(&model.User{}).Where("role = ?", "admin").Count(&admins).Error; err != nil || admins == 0 {
+		return nil, fmt.Errorf("%w: database contains no admin user", ErrInvalidArchive)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		all := model.All()
+		for i := len(all) - 1; i >= 0; i-- {
+			if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(all[i]).Error; err != nil {
+				return err
+			}
+		}
+		if err := copyTables(src, tx); err != nil {
+			return err
+		}
+		if tx.Dialector.Name() == "postgres" {
+			if err := resetSequences(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.User{}).Session(&gorm.Session{AllowGlobalUpdate: true}).
+			UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.secretPath == "" {
+		return manifest, nil
+	}
+	if secret, err := os.ReadFile(filepath.Join(tmpDir, secretName)); err == nil {
+		if err := os.WriteFile(s.secretPath, secret, 0600); err != nil {
+			return manifest, fmt.Errorf("database restored but writing the JWT secret failed: %w", err)
+		}
+	}
+	return manifest, nil
+}
+
+// StartScheduler stores a backup whenever the configured interval has passed since the newest one
+func (s *Service) StartScheduler(ctx context.Context) {
+	go func() {
+		for {
+			if hours := config.GetInt(s.db, model.ConfigKeyBackupInterval); hours > 0 && s.due(time.Duration(hours)*time.Hour) {
+				if name, err := s.Save(); err != nil {
+					slog.Error("backup: scheduled backup failed", "error", err)
+				} else {
+					slog.Info("backup: scheduled backup stored", "file", name)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Minute):
+			}
+		}
+	}()
+}
+
+func (s *Service) due(interval time.Duration) bool {
+	names, err := s.Stored()
+	if err != nil || len(names) == 0 {
+		return err == nil
+	}
+	info, err := os.Stat(filepath.Join(s.dir, names[0]))
+	if err != nil {
+		return true
+	}
+	return time.Since(info.ModTime()) >= interval
+}
+
+// copyTables copies every model's rows, soft-deleted ones included, from src to dst
+func copyTables(src, dst *gorm.DB) error {
+	// Rows are copied verbatim, so hooks such as password hashing must not run again
+	dst = dst.Session(&gorm.Session{SkipHooks: true})
+	for _, m := range model.All() {
+		rows := reflect.New(reflect.SliceOf(reflect.TypeOf(m).Elem())).Interface()
+		err := src.Unscoped().Model(m).FindInBatches(rows, batchSize, func(*gorm.DB, int) error {
+			return dst.Omit(clause.Associations).Create(rows).Error
+		}).Error
+		if err != nil {
+			return fmt.Errorf("copy %T: %w", m, err)
+		}
+	}
+	return nil
+}
+
+// resetSequences moves Postgres ID sequences past the restored rows
+func resetSequences(tx *gorm.DB) error {
+	for _, m := range model.All() {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(m); err != nil {
+			return err
+		}
+		var maxID int64
+		if err := tx.Table(stmt.Schema.Table).Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("SELECT setval(pg_get_serial_sequence(?, 'id'), ?, false)", stmt.Schema.Table, maxID+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extract unpacks the known entries of a backup archive into dir and returns its manifest
+func extract(r io.Reader, dir string) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+
+	var manifest *Manifest
+	hasDatabase := false
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Size > maxEntrySize {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, hdr.Name)
+		}
+
+		switch hdr.Name {
+		case manifestName:
+			manifest = &Manifest{}
+			if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("%w: bad manifest: %v", ErrInvalidArchive, err)
+			}
+		case databaseName, secretName:
+			if err := copyToFile(filepath.Join(dir, hdr.Name), tr); err != nil {
+				return nil, err
+			}
+			hasDatabase = hasDatabase || hdr.Name == databaseName
+		default:
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, hdr.Name)
+		}
+	}
+
+	if manifest == nil || !hasDatabase {
+		return nil, fmt.Errorf("%w: manifest or database missing", ErrInvalidArchive)
+	}
+	if manifest.Version != formatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidArchive, manifest.Version)
+	}
+	return manifest, nil
+}
+
+func copyToFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeFile(tw *tar.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func openSQLite(path string) (*gorm.DB, error) {
+	return gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+}
+
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/migrate"
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// openTestDB opens an empty sqlite database in a file of its own
+func openTestDB(t *testing.T) (*gorm.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.db")
+	db, err := openSQLite(path)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { closeDB(db) })
+	return db, path
+}
+
+// newTestService returns a service on a migrated database of its own
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, _ := openTestDB(t)
+	if err := migrate.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return New(db, config.DriverSQLite, "", t.TempDir())
+}
+
+// migrateTo leaves db with the first n migrations applied
+func migrateTo(t *testing.T, db *gorm.DB, n int) {
+	t.Helper()
+	all, err := migrate.Pending(db)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if err := migrate.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := migrate.Rollback(db, len(all)-n); err != nil {
+		t.Fatalf("roll back to %s: %v", all[n-1], err)
+	}
+}
+
+// archive packs a database file into a backup archive as Write does
+func archive(t *testing.T, dbPath string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	manifest, _ := json.Marshal(Manifest{Version: formatVersion, CreatedAt: time.Now().UTC(), Driver: config.DriverSQLite})
+	if err := writeEntry(tw, manifestName, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(tw, databaseName, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestRestoreUpgradesOlderArchives(t *testing.T) {
+	// A backup taken before scheduled tasks, auto-updates and server notes existed
+	src, srcPath := openTestDB(t)
+	migrateTo(t, src, 4)
+	if src.Migrator().HasTable(&model.ScheduledTask{}) {
+		t.Fatal("the old schema already has scheduled tasks")
+	}
+	now := time.Now()
+	if err := src.Exec("INSERT INTO users (username, password, role, token_version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		"admin", "hash", "admin", 3, now, now).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	if err := src.Exec("INSERT INTO servers (name, ip, port, username, auth_mode, secret, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		"web", "10.0.0.1", 22, "root", "password", "sealed", now, now).Error; err != nil {
+		t.Fatalf("seed server: %v", err)
+	}
+	closeDB(src)
+
+	s := newTestService(t)
+	if _, err := s.Restore(archive(t, srcPath)); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	var user model.User
+	if err := s.db.Where("username = ?", "admin").First(&user).Error; err != nil {
+		t.Fatalf("restored user: %v", err)
+	}
+	if user.Password != "hash" || user.TokenVersion != 4 {
+		t.Fatalf("user password %q token version %d, want the archive's hash and version 3 bumped to 4", user.Password, user.TokenVersion)
+	}
+	var server model.Server
+	if err := s.db.Where("name = ?", "web").First(&server).Error; err != nil {
+		t.Fatalf("restored server: %v", err)
+	}
+	// Columns added since the backup get the defaults their migrations gave existing rows
+	if server.ConnectionMode != model.ConnectionModeCLI {
+		t.Fatalf("connection mode = %q, want %q", server.ConnectionMode, model.ConnectionModeCLI)
+	}
+}
+
+func TestRestoreRejectsNewerArchives(t *testing.T) {
+	src, srcPath := openTestDB(t)
+	if err := migrate.Run(src); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := src.Create(&model.User{Username: "admin", Password: "password", Role: "admin"}).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	if err := src.Exec("INSERT INTO schema_migrations (id, applied_at) VALUES (?, ?)", "9999_from_the_future", time.Now()).Error; err != nil {
+		t.Fatalf("record migration: %v", err)
+	}
+	closeDB(src)
+
+	s := newTestService(t)
+	if _, err := s.Restore(archive(t, srcPath)); !errors.Is(err, ErrInvalidArchive) {
+		t.Fatalf("restore = %v, want ErrInvalidArchive", err)
+	}
+}
+
+func TestWriteAndRestore(t *testing.T) {
+	s := newTestService(t)
+	if err := s.db.Create(&model.User{Username: "admin", Password: "password", Role: "admin"}).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	if err := s.db.Create(&model.Server{Name: "web", IP: "10.0.0.1", Port: 22, Description: "front", Notes: "behind the proxy"}).Error; err != nil {
+		t.Fatalf("seed server: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := s.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	target := newTestService(t)
+	if _, err := target.Restore(&buf); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	var server model.Server
+	if err := target.db.Where("name = ?", "web").First(&server).Error; err != nil {
+		t.Fatalf("restored server: %v", err)
+	}
+	if server.Description != "front" || server.Notes != "behind the proxy" {
+		t.Fatalf("server description %q notes %q, want them restored", server.Description, server.Notes)
+	}
+}
@@ -168,3 +168,14 @@ func (h *BotHandler) handleHelp(c telebot.Context) error {
 func (h *BotHandler) Start() {
 	h.Bot.Start()
 }
+
+// Stop stops the bot poller
+func (h *BotHandler) Stop() {
+	h.Bot.Stop()
+}
+
+// SendMessage sends a plain text message to a Telegram user
+func (h *BotHandler) SendMessage(telegramID int64, text string) error {
+	_, err := h.Bot.Send(&telebot.User{ID: telegramID}, text)
+	return err
+}
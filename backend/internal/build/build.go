@@ -0,0 +1,164 @@
+// Package build runs image builds on servers in the background and keeps their output for
+// clients to follow
+package build
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+)
+
+const (
+	// outputLimit is how much of a build's output is kept; older output is dropped
+	outputLimit = 1 << 20
+	// retention is how long finished builds stay available
+	retention = time.Hour
+	// buildTimeout is how long a build may run before it is aborted
+	buildTimeout = time.Hour
+)
+
+// Job is a build started by Start
+type Job struct {
+	mu         sync.Mutex
+	id         string
+	serverID   uint
+	tag        string
+	status     string
+	err        string
+	output     []byte
+	dropped    int64
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+var (
+	jobsMu sync.Mutex
+	jobs   = map[string]*Job{}
+)
+
+// Start builds tag on the server from the gzipped tar build context stored in contextFile,
+// which is removed once the build is over
+func Start(server model.Server, contextFile, tag string, buildArgs map[string]string) (*Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{id: id, serverID: server.ID, tag: tag, status: model.BuildRunning, startedAt: time.Now()}
+
+	jobsMu.Lock()
+	for k, j := range jobs {
+		if j.expired() {
+			delete(jobs, k)
+		}
+	}
+	jobs[id] = job
+	jobsMu.Unlock()
+
+	go job.run(server, contextFile, buildArgs)
+	return job, nil
+}
+
+// Get returns a build of the server. Builds of other servers are not found, so access checks
+// on the server cover the build.
+func Get(serverID uint, id string) (*Job, bool) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	job, ok := jobs[id]
+	if !ok || job.serverID != serverID || job.expired() {
+		return nil, false
+	}
+	return job, true
+}
+
+// Snapshot returns the build's state with the output from offset on. Output older than the
+// kept window is skipped.
+func (j *Job) Snapshot(offset int64) model.BuildJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if offset < j.dropped {
+		offset = j.dropped
+	}
+	total := j.dropped + int64(len(j.output))
+	if offset > total {
+		offset = total
+	}
+	snap := model.BuildJob{
+		ID:         j.id,
+		ServerID:   j.serverID,
+		Tag:        j.tag,
+		Status:     j.status,
+		Error:      j.err,
+		Output:     string(j.output[offset-j.dropped:]),
+		NextOffset: total,
+		StartedAt:  j.startedAt,
+	}
+	if !j.finishedAt.IsZero() {
+		t := j.finishedAt
+		snap.FinishedAt = &t
+	}
+	return snap
+}
+
+// Write appends build output, dropping the oldest output beyond outputLimit
+func (j *Job) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.output = append(j.output, p...)
+	if over := len(j.output) - outputLimit; over > 0 {
+		j.output = append([]byte(nil), j.output[over:]...)
+		j.dropped += int64(over)
+	}
+	return len(p), nil
+}
+
+func (j *Job) run(server model.Server, contextFile string, buildArgs map[string]string) {
+	defer os.Remove(contextFile)
+	err := func() error {
+		f, err := os.Open(contextFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		client, err := ssh.NewServerClient(&server)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), buildTimeout)
+		defer cancel()
+		return client.BuildImage(ctx, f, j.tag, buildArgs, j)
+	}()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finishedAt = time.Now()
+	j.status = model.BuildSucceeded
+	if err != nil {
+		j.status = model.BuildFailed
+		j.err = err.Error()
+		slog.Warn("build: image build failed", "server_id", server.ID, "tag", j.tag, "error", err)
+		return
+	}
+	slog.Info("build: image built", "server_id", server.ID, "tag", j.tag, "duration", j.finishedAt.Sub(j.startedAt))
+}
+
+// expired reports whether a finished build has passed its retention
+func (j *Job) expired() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.finishedAt.IsZero() && time.Since(j.finishedAt) > retention
+}
+
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,230 @@
+// Package bundle exports servers, users, permissions, settings and webhooks as a JSON bundle
+// and imports such bundles into another instance
+package bundle
+
+import (
+	"errors"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/version"
+
+	"gorm.io/gorm"
+)
+
+// FormatVersion is the bundle format written by Export and accepted by Import
+const FormatVersion = 1
+
+var (
+	// ErrInvalidBundle is returned for bundles that cannot be read or have an unsupported version
+	ErrInvalidBundle = errors.New("invalid bundle")
+	// ErrPassphraseRequired is returned when secrets are exported or imported without a passphrase
+	ErrPassphraseRequired = errors.New("a passphrase is required for bundles with secrets")
+	// ErrPassphrase is returned when the passphrase does not decrypt the bundle's secrets
+	ErrPassphrase = errors.New("the passphrase does not match the bundle")
+)
+
+// Bundle is the exported state of an instance. Records refer to each other by the IDs they have
+// on the exporting instance; Import maps those to the IDs on the importing one.
+type Bundle struct {
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	AppVersion string    `json:"app_version"`
+	// Encryption is set when the bundle carries secrets, which are then encrypted with it
+	Encryption  *Encryption       `json:"encryption,omitempty"`
+	Servers     []Server          `json:"servers"`
+	Users       []User            `json:"users"`
+	Permissions []Permission      `json:"permissions"`
+	Config      map[string]string `json:"config"`
+	Webhooks    []Webhook         `json:"webhooks"`
+}
+
+// Server is an exported server. Secret is encrypted and only present when secrets were requested.
+type Server struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	AuthMode string `json:"auth_mode"`
+	Secret   string `json:"secret,omitempty"`
+	// KeyPassphrase is sealed like Secret and travels with it
+	KeyPassphrase string `json:"key_passphrase,omitempty"`
+
+	// The optional jump host. Its secret and passphrase are sealed like Secret.
+	JumpIP            string `json:"jump_ip,omitempty"`
+	JumpPort          int    `json:"jump_port,omitempty"`
+	JumpUsername      string `json:"jump_username,omitempty"`
+	JumpAuthMode      string `json:"jump_auth_mode,omitempty"`
+	JumpSecret        string `json:"jump_secret,omitempty"`
+	JumpKeyPassphrase string `json:"jump_key_passphrase,omitempty"`
+
+	// SudoPassword is sealed like Secret and travels with it
+	UseSudo      bool   `json:"use_sudo,omitempty"`
+	SudoPassword string `json:"sudo_password,omitempty"`
+	NetInterface string `json:"net_interface,omitempty"`
+	// ConnectionMode is missing from bundles of versions before it, which only had the CLI
+	ConnectionMode string `json:"connection_mode,omitempty"`
+	// Description and Notes are missing from bundles of versions before them, which then leave
+	// those of an existing server alone
+	Description *string `json:"description,omitempty"`
+	Notes       *string `json:"notes,omitempty"`
+}
+
+// User is an exported user. PasswordHash is only present when password hashes were requested.
+type User struct {
+	ID                   uint   `json:"id"`
+	Username             string `json:"username"`
+	Role                 string `json:"role"`
+	TelegramID           int64  `json:"telegram_id"`
+	Language             string `json:"language"`
+	Email                string `json:"email"`
+	NotificationChannels string `json:"notification_channels"`
+	PasswordHash         string `json:"password_hash,omitempty"`
+}
+
+// Permission grants the user with UserID access to the server with ServerID, both bundle IDs
+type Permission struct {
+	UserID      uint       `json:"user_id"`
+	ServerID    uint       `json:"server_id"`
+	AccessLevel string     `json:"access_level"`
+	ExpireAt    *time.Time `json:"expire_at"`
+}
+
+// Webhook is an exported webhook. ServerID is a bundle ID and Secret is encrypted.
+type Webhook struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Secret   string `json:"secret,omitempty"`
+	Events   string `json:"events"`
+	ServerID *uint  `json:"server_id"`
+	Format   string `json:"format"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// Options selects the sensitive data included in an export
+type Options struct {
+	// IncludeSecrets adds server credentials, webhook secrets and secret settings, encrypted with Passphrase
+	IncludeSecrets bool
+	// IncludePasswords adds the users' password hashes
+	IncludePasswords bool
+	Passphrase       string
+}
+
+// Export builds a bundle of the current state. Settings are exported when stored in the
+// database; values pinned by the environment are left out.
+func Export(db *gorm.DB, opts Options) (*Bundle, error) {
+	b := &Bundle{
+		Version:     FormatVersion,
+		CreatedAt:   time.Now().UTC(),
+		AppVersion:  version.Version,
+		Servers:     []Server{},
+		Users:       []User{},
+		Permissions: []Permission{},
+		Config:      map[string]string{},
+		Webhooks:    []Webhook{},
+	}
+	var s *sealer
+	if opts.IncludeSecrets {
+		if opts.Passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		var err error
+		if b.Encryption, s, err = newEncryption(opts.Passphrase); err != nil {
+			return nil, err
+		}
+	}
+	seal := func(v string) (string, error) {
+		if s == nil || v == "" {
+			return "", nil
+		}
+		return s.seal(v)
+	}
+
+	var servers []model.Server
+	if err := db.Order("id").Find(&servers).Error; err != nil {
+		return nil, err
+	}
+	for _, sv := range servers {
+		secret, err := seal(sv.Secret)
+		if err != nil {
+			return nil, err
+		}
+		passphrase, err := seal(sv.KeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		jumpSecret, err := seal(sv.JumpSecret)
+		if err != nil {
+			return nil, err
+		}
+		jumpPassphrase, err := seal(sv.JumpKeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		sudoPassword, err := seal(sv.SudoPassword)
+		if err != nil {
+			return nil, err
+		}
+		description, notes := sv.Description, sv.Notes
+		b.Servers = append(b.Servers, Server{
+			ID: sv.ID, Name: sv.Name, IP: sv.IP, Port: sv.Port, Username: sv.Username, AuthMode: sv.AuthMode, Secret: secret, KeyPassphrase: passphrase,
+			JumpIP: sv.JumpIP, JumpPort: sv.JumpPort, JumpUsername: sv.JumpUsername, JumpAuthMode: sv.JumpAuthMode, JumpSecret: jumpSecret, JumpKeyPassphrase: jumpPassphrase,
+			UseSudo: sv.UseSudo, SudoPassword: sudoPassword, NetInterface: sv.NetInterface, ConnectionMode: sv.ConnectionMode,
+			Description: &description, Notes: &notes,
+		})
+	}
+
+	var users []model.User
+	if err := db.Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		out := User{ID: u.ID, Username: u.Username, Role: u.Role, TelegramID: u.TelegramID, Language: u.Language, Email: u.Email, NotificationChannels: u.NotificationChannels}
+		if opts.IncludePasswords {
+			out.PasswordHash = u.Password
+		}
+		b.Users = append(b.Users, out)
+	}
+
+	var perms []model.ServerPermission
+	if err := db.Order("id").Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range perms {
+		b.Permissions = append(b.Permissions, Permission{UserID: p.UserID, ServerID: p.ServerID, AccessLevel: p.AccessLevel, ExpireAt: p.ExpireAt})
+	}
+
+	values, err := config.Effective(db, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		if v.Source != config.SourceDB {
+			continue
+		}
+		if v.Type == config.TypeSecret {
+			if v.Value, err = seal(v.Value); err != nil {
+				return nil, err
+			}
+			if v.Value == "" {
+				continue
+			}
+		}
+		b.Config[v.Key] = v.Value
+	}
+
+	var hooks []model.Webhook
+	if err := db.Order("id").Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+	for _, h := range hooks {
+		secret, err := seal(h.Secret)
+		if err != nil {
+			return nil, err
+		}
+		b.Webhooks = append(b.Webhooks, Webhook{Name: h.Name, URL: h.URL, Secret: secret, Events: h.Events, ServerID: h.ServerID, Format: h.Format, Enabled: h.Enabled})
+	}
+	return b, nil
+}
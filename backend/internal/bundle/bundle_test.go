@@ -0,0 +1,68 @@
+package bundle
+
+import (
+	"path/filepath"
+	"testing"
+
+	"docker-pulse/internal/migrate"
+	"docker-pulse/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "data.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := migrate.Run(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestServerNotesTravelWithTheBundle(t *testing.T) {
+	src := newTestDB(t)
+	if err := src.Create(&model.Server{Name: "web", IP: "10.0.0.1", Port: 22, Username: "root", AuthMode: "password", Description: "front end", Notes: "## Restart\nbehind the proxy"}).Error; err != nil {
+		t.Fatalf("seed server: %v", err)
+	}
+	b, err := Export(src, Options{})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dst := newTestDB(t)
+	if err := dst.Create(&model.Server{Name: "web", IP: "10.0.0.1", Port: 22, Username: "root", AuthMode: "password", ConnectionMode: model.ConnectionModeCLI, Notes: "old notes"}).Error; err != nil {
+		t.Fatalf("seed server: %v", err)
+	}
+	report, err := Import(dst, b, "", true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(report.Items) != 1 || report.Items[0].Action != ActionUpdate {
+		t.Fatalf("dry run = %+v, want the server updated for its notes", report.Items)
+	}
+	if _, err := Import(dst, b, "", false); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	var server model.Server
+	if err := dst.Where("name = ?", "web").First(&server).Error; err != nil {
+		t.Fatalf("load server: %v", err)
+	}
+	if server.Description != "front end" || server.Notes != "## Restart\nbehind the proxy" {
+		t.Fatalf("server description %q notes %q, want the bundle's", server.Description, server.Notes)
+	}
+
+	// Bundles from before descriptions and notes leave them alone
+	b.Servers[0].Description, b.Servers[0].Notes = nil, nil
+	report, err = Import(dst, b, "", false)
+	if err != nil {
+		t.Fatalf("import old bundle: %v", err)
+	}
+	if report.Items[0].Action != ActionUnchanged {
+		t.Fatalf("old bundle = %+v, want the server unchanged", report.Items)
+	}
+}
@@ -0,0 +1,110 @@
+package bundle
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// checkText is encrypted into every bundle with secrets so a wrong passphrase is detected up front
+const checkText = "dockermanager-bundle"
+
+// Encryption describes how the secrets in a bundle are encrypted: AES-256-GCM with a key
+// derived from the passphrase by scrypt
+type Encryption struct {
+	KDF    string `json:"kdf"`
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+	Salt   string `json:"salt"`
+	Cipher string `json:"cipher"`
+	Check  string `json:"check"`
+}
+
+// sealer encrypts and decrypts single values
+type sealer struct {
+	aead cipher.AEAD
+}
+
+// newEncryption derives a key from the passphrase with a fresh salt
+func newEncryption(passphrase string) (*Encryption, *sealer, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	e := &Encryption{KDF: "scrypt", N: 1 << 15, R: 8, P: 1, Salt: base64.StdEncoding.EncodeToString(salt), Cipher: "aes-256-gcm"}
+	s, err := e.sealer(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.Check, err = s.seal(checkText); err != nil {
+		return nil, nil, err
+	}
+	return e, s, nil
+}
+
+// open derives the key of an existing bundle and checks the passphrase against it
+func (e *Encryption) open(passphrase string) (*sealer, error) {
+	if e.KDF != "scrypt" || e.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("%w: unsupported encryption %s/%s", ErrInvalidBundle, e.KDF, e.Cipher)
+	}
+	// Bound the cost so a crafted bundle cannot exhaust memory
+	if e.N <= 1 || e.N > 1<<20 || e.R <= 0 || e.R > 32 || e.P <= 0 || e.P > 16 {
+		return nil, fmt.Errorf("%w: unsupported scrypt parameters", ErrInvalidBundle)
+	}
+	s, err := e.sealer(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	check, err := s.open(e.Check)
+	if err != nil || subtle.ConstantTimeCompare([]byte(check), []byte(checkText)) != 1 {
+		return nil, ErrPassphrase
+	}
+	return s, nil
+}
+
+func (e *Encryption) sealer(passphrase string) (*sealer, error) {
+	salt, err := base64.StdEncoding.DecodeString(e.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid salt", ErrInvalidBundle)
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, e.N, e.R, e.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+// seal returns base64(nonce || ciphertext)
+func (s *sealer) seal(plain string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+func (s *sealer) open(sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return "", fmt.Errorf("%w: malformed encrypted value", ErrInvalidBundle)
+	}
+	plain, err := s.aead.Open(nil, raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: encrypted value does not match the passphrase", ErrInvalidBundle)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,504 @@
+package bundle
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Action is what an import does with one record of the bundle
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionUnchanged Action = "unchanged"
+	// ActionConflict marks a record that cannot be applied. Any conflict stops the import.
+	ActionConflict Action = "conflict"
+	// ActionSkip marks a record that is left out, e.g. a setting unknown to this version
+	ActionSkip Action = "skip"
+)
+
+// ErrConflicts is returned when a bundle is applied while it conflicts with existing data
+var ErrConflicts = errors.New("the bundle conflicts with existing data")
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// Item is the planned or applied action for one record
+type Item struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action Action `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Report lists what an import did or, for a dry run, would do
+type Report struct {
+	DryRun  bool           `json:"dry_run"`
+	Applied bool           `json:"applied"`
+	Counts  map[Action]int `json:"counts"`
+	Items   []Item         `json:"items"`
+	// ConfigChanged reports whether settings were written, so their change hooks need to run
+	ConfigChanged bool `json:"-"`
+}
+
+func (r *Report) add(kind, name string, action Action, reason string) {
+	r.Items = append(r.Items, Item{Kind: kind, Name: name, Action: action, Reason: reason})
+	r.Counts[action]++
+}
+
+// Import applies a bundle in a single transaction. Servers are matched by name and address,
+// users by username, permissions by user and server, webhooks by name and settings by key.
+// IDs in the bundle are mapped to the records they were matched to or created as. A dry run
+// performs the same work and rolls it back, so its report shows exactly what would happen.
+// If anything conflicts, nothing is written and ErrConflicts is returned with the report.
+func Import(db *gorm.DB, b *Bundle, passphrase string, dryRun bool) (*Report, error) {
+	if b.Version != FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d, expected %d", ErrInvalidBundle, b.Version, FormatVersion)
+	}
+	im := &importer{
+		bundle:  b,
+		report:  &Report{DryRun: dryRun, Counts: map[Action]int{}, Items: []Item{}},
+		servers: map[uint]uint{},
+		users:   map[uint]uint{},
+	}
+	if b.Encryption != nil {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		var err error
+		if im.sealer, err = b.Encryption.open(passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		im.tx = tx
+		for _, step := range []func() error{im.importServers, im.importUsers, im.importPermissions, im.importConfig, im.importWebhooks} {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		if im.report.Counts[ActionConflict] > 0 && !dryRun {
+			return ErrConflicts
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		im.report.ConfigChanged = false
+		return im.report, nil
+	}
+	if err != nil {
+		return im.report, err
+	}
+	im.report.Applied = true
+	return im.report, nil
+}
+
+// importer carries the state of one import. servers and users map bundle IDs to local IDs.
+type importer struct {
+	tx      *gorm.DB
+	bundle  *Bundle
+	sealer  *sealer
+	report  *Report
+	servers map[uint]uint
+	users   map[uint]uint
+}
+
+// decrypt opens an encrypted value. Empty values stay empty.
+func (im *importer) decrypt(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	if im.sealer == nil {
+		return "", fmt.Errorf("%w: encrypted value without encryption settings", ErrInvalidBundle)
+	}
+	return im.sealer.open(v)
+}
+
+func (im *importer) importServers() error {
+	for _, in := range im.bundle.Servers {
+		if in.Name == "" || in.IP == "" {
+			im.report.add("server", in.Name, ActionConflict, "name and IP are required")
+			continue
+		}
+		if in.Port == 0 {
+			in.Port = 22
+		}
+		secret, err := im.decrypt(in.Secret)
+		if err != nil {
+			return err
+		}
+		passphrase, err := im.decrypt(in.KeyPassphrase)
+		if err != nil {
+			return err
+		}
+		jumpSecret, err := im.decrypt(in.JumpSecret)
+		if err != nil {
+			return err
+		}
+		jumpPassphrase, err := im.decrypt(in.JumpKeyPassphrase)
+		if err != nil {
+			return err
+		}
+		sudoPassword, err := im.decrypt(in.SudoPassword)
+		if err != nil {
+			return err
+		}
+
+		mode := in.ConnectionMode
+		if mode == "" {
+			mode = model.ConnectionModeCLI
+		}
+		var description, notes string
+		if in.Description != nil {
+			description = *in.Description
+		}
+		if in.Notes != nil {
+			notes = *in.Notes
+		}
+
+		var existing []model.Server
+		if err := im.tx.Where("name = ?", in.Name).Find(&existing).Error; err != nil {
+			return err
+		}
+		switch {
+		case len(existing) == 0:
+			s := model.Server{
+				Name: in.Name, IP: in.IP, Port: in.Port, Username: in.Username, AuthMode: in.AuthMode, Secret: secret, KeyPassphrase: passphrase,
+				JumpIP: in.JumpIP, JumpPort: in.JumpPort, JumpUsername: in.JumpUsername, JumpAuthMode: in.JumpAuthMode, JumpSecret: jumpSecret, JumpKeyPassphrase: jumpPassphrase,
+				UseSudo: in.UseSudo, SudoPassword: sudoPassword, NetInterface: in.NetInterface, ConnectionMode: mode,
+				Description: description, Notes: notes,
+			}
+			if err := im.tx.Create(&s).Error; err != nil {
+				return err
+			}
+			im.servers[in.ID] = s.ID
+			reason := ""
+			if secret == "" {
+				reason = "created without credentials, set them before use"
+			}
+			im.report.add("server", in.Name, ActionCreate, reason)
+		case len(existing) > 1:
+			im.report.add("server", in.Name, ActionConflict, "several servers have this name")
+		case existing[0].IP != in.IP || existing[0].Port != in.Port:
+			im.report.add("server", in.Name, ActionConflict, fmt.Sprintf("a server with this name already points at %s:%d", existing[0].IP, existing[0].Port))
+		default:
+			s := existing[0]
+			im.servers[in.ID] = s.ID
+			sameJump := s.JumpIP == in.JumpIP && s.JumpPort == in.JumpPort && s.JumpUsername == in.JumpUsername && s.JumpAuthMode == in.JumpAuthMode &&
+				(jumpSecret == "" || (s.JumpSecret == jumpSecret && s.JumpKeyPassphrase == jumpPassphrase))
+			sameSudo := s.UseSudo == in.UseSudo && (secret == "" || s.SudoPassword == sudoPassword)
+			sameNotes := (in.Description == nil || s.Description == description) && (in.Notes == nil || s.Notes == notes)
+			if s.Username == in.Username && s.AuthMode == in.AuthMode && (secret == "" || (s.Secret == secret && s.KeyPassphrase == passphrase)) && sameJump && sameSudo && sameNotes && s.NetInterface == in.NetInterface && s.ConnectionMode == mode {
+				im.report.add("server", in.Name, ActionUnchanged, "")
+				continue
+			}
+			updates := map[string]interface{}{
+				"username": in.Username, "auth_mode": in.AuthMode,
+				"jump_ip": in.JumpIP, "jump_port": in.JumpPort, "jump_username": in.JumpUsername, "jump_auth_mode": in.JumpAuthMode,
+				"use_sudo": in.UseSudo, "net_interface": in.NetInterface, "connection_mode": mode,
+			}
+			if in.Description != nil {
+				updates["description"] = description
+			}
+			if in.Notes != nil {
+				updates["notes"] = notes
+			}
+			if secret != "" {
+				updates["secret"] = secret
+				updates["key_passphrase"] = passphrase
+				updates["sudo_password"] = sudoPassword
+			}
+			if jumpSecret != "" || in.JumpIP == "" {
+				updates["jump_secret"] = jumpSecret
+				updates["jump_key_passphrase"] = jumpPassphrase
+			}
+			if err := im.tx.Model(&s).Updates(updates).Error; err != nil {
+				return err
+			}
+			im.report.add("server", in.Name, ActionUpdate, "")
+		}
+	}
+	return nil
+}
+
+func (im *importer) importUsers() error {
+	for _, in := range im.bundle.Users {
+		if in.Username == "" {
+			im.report.add("user", in.Username, ActionConflict, "username is required")
+			continue
+		}
+		if in.Role != "admin" && in.Role != "user" {
+			im.report.add("user", in.Username, ActionConflict, fmt.Sprintf("unknown role %q", in.Role))
+			continue
+		}
+		if in.TelegramID != 0 {
+			var holder model.User
+			err := im.tx.Where("telegram_id = ? AND username <> ?", in.TelegramID, in.Username).First(&holder).Error
+			if err == nil {
+				im.report.add("user", in.Username, ActionConflict, fmt.Sprintf("Telegram ID %d is bound to user %s", in.TelegramID, holder.Username))
+				continue
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		var u model.User
+		err := im.tx.Where("username = ?", in.Username).First(&u).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			u = model.User{Username: in.Username, Role: in.Role, TelegramID: in.TelegramID, Language: in.Language, Email: in.Email, NotificationChannels: in.NotificationChannels}
+			if err := im.tx.Create(&u).Error; err != nil {
+				return err
+			}
+			// The hash is written after the create hook, which would hash it again
+			hash, reason := in.PasswordHash, ""
+			if hash == "" {
+				if hash, err = randomPasswordHash(); err != nil {
+					return err
+				}
+				reason = "created with a random password, reset it before use"
+			}
+			if err := im.tx.Model(&u).Update("password", hash).Error; err != nil {
+				return err
+			}
+			im.users[in.ID] = u.ID
+			im.report.add("user", in.Username, ActionCreate, reason)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		im.users[in.ID] = u.ID
+		updates := map[string]interface{}{}
+		if u.Role != in.Role {
+			updates["role"] = in.Role
+		}
+		if u.TelegramID != in.TelegramID {
+			updates["telegram_id"] = in.TelegramID
+		}
+		if u.Language != in.Language {
+			updates["language"] = in.Language
+		}
+		if u.Email != in.Email {
+			updates["email"] = in.Email
+		}
+		if u.NotificationChannels != in.NotificationChannels {
+			updates["notification_channels"] = in.NotificationChannels
+		}
+		if in.PasswordHash != "" && u.Password != in.PasswordHash {
+			updates["password"] = in.PasswordHash
+		}
+		if len(updates) == 0 {
+			im.report.add("user", in.Username, ActionUnchanged, "")
+			continue
+		}
+		// Role and password changes end the user's sessions
+		_, roleChanged := updates["role"]
+		_, passwordChanged := updates["password"]
+		if roleChanged || passwordChanged {
+			updates["token_version"] = gorm.Expr("token_version + 1")
+		}
+		if err := im.tx.Model(&u).Updates(updates).Error; err != nil {
+			return err
+		}
+		im.report.add("user", in.Username, ActionUpdate, "")
+	}
+	return nil
+}
+
+func (im *importer) importPermissions() error {
+	userNames := map[uint]string{}
+	for _, u := range im.bundle.Users {
+		userNames[u.ID] = u.Username
+	}
+	serverNames := map[uint]string{}
+	for _, s := range im.bundle.Servers {
+		serverNames[s.ID] = s.Name
+	}
+
+	for _, in := range im.bundle.Permissions {
+		name := fmt.Sprintf("%s on %s", userNames[in.UserID], serverNames[in.ServerID])
+		userID, userOK := im.users[in.UserID]
+		serverID, serverOK := im.servers[in.ServerID]
+		if !userOK || !serverOK {
+			reason := "the user or server is not part of the bundle"
+			if _, ok := userNames[in.UserID]; ok && !userOK {
+				reason = "the user was not imported"
+			} else if _, ok := serverNames[in.ServerID]; ok && !serverOK {
+				reason = "the server was not imported"
+			}
+			im.report.add("permission", name, ActionSkip, reason)
+			continue
+		}
+		switch in.AccessLevel {
+		case model.AccessLevelRead, model.AccessLevelManage, model.AccessLevelFull:
+		default:
+			im.report.add("permission", name, ActionConflict, fmt.Sprintf("unknown access level %q", in.AccessLevel))
+			continue
+		}
+
+		var p model.ServerPermission
+		err := im.tx.Where("user_id = ? AND server_id = ?", userID, serverID).First(&p).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			p = model.ServerPermission{UserID: userID, ServerID: serverID, AccessLevel: in.AccessLevel, ExpireAt: in.ExpireAt}
+			if err := im.tx.Create(&p).Error; err != nil {
+				return err
+			}
+			im.report.add("permission", name, ActionCreate, "")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if p.AccessLevel == in.AccessLevel && sameTime(p.ExpireAt, in.ExpireAt) {
+			im.report.add("permission", name, ActionUnchanged, "")
+			continue
+		}
+		if err := im.tx.Model(&p).Updates(map[string]interface{}{"access_level": in.AccessLevel, "expire_at": in.ExpireAt}).Error; err != nil {
+			return err
+		}
+		im.report.add("permission", name, ActionUpdate, "")
+	}
+	return nil
+}
+
+// importConfig writes settings directly; their change hooks run once the import is committed
+func (im *importer) importConfig() error {
+	names := make([]string, 0, len(im.bundle.Config))
+	for name := range im.bundle.Config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := im.bundle.Config[name]
+		k, ok := config.Lookup(name)
+		if !ok {
+			im.report.add("config", name, ActionSkip, "unknown setting")
+			continue
+		}
+		if k.Type == config.TypeSecret {
+			var err error
+			if value, err = im.decrypt(value); err != nil {
+				return err
+			}
+		}
+		current, err := config.Get(im.tx, name)
+		if err != nil {
+			return err
+		}
+		if current == value {
+			im.report.add("config", name, ActionUnchanged, "")
+			continue
+		}
+		if config.Overridden(name) {
+			im.report.add("config", name, ActionSkip, config.ErrOverridden.Error())
+			continue
+		}
+		if err := k.Check(value); err != nil {
+			im.report.add("config", name, ActionConflict, err.Error())
+			continue
+		}
+		if err := im.tx.Where(&model.Config{Key: name}).
+			Assign(model.Config{Value: value}).
+			FirstOrCreate(&model.Config{Key: name}).Error; err != nil {
+			return err
+		}
+		im.report.ConfigChanged = true
+		im.report.add("config", name, ActionUpdate, "")
+	}
+	return nil
+}
+
+func (im *importer) importWebhooks() error {
+	for _, in := range im.bundle.Webhooks {
+		if in.Name == "" || in.URL == "" {
+			im.report.add("webhook", in.Name, ActionConflict, "name and URL are required")
+			continue
+		}
+		var serverID *uint
+		if in.ServerID != nil {
+			id, ok := im.servers[*in.ServerID]
+			if !ok {
+				im.report.add("webhook", in.Name, ActionConflict, "its server was not imported")
+				continue
+			}
+			serverID = &id
+		}
+		secret, err := im.decrypt(in.Secret)
+		if err != nil {
+			return err
+		}
+		if in.Format == "" {
+			in.Format = model.WebhookFormatJSON
+		}
+
+		var existing []model.Webhook
+		if err := im.tx.Where("name = ?", in.Name).Find(&existing).Error; err != nil {
+			return err
+		}
+		switch len(existing) {
+		case 0:
+			h := model.Webhook{Name: in.Name, URL: in.URL, Secret: secret, Events: in.Events, ServerID: serverID, Format: in.Format, Enabled: in.Enabled}
+			if err := im.tx.Create(&h).Error; err != nil {
+				return err
+			}
+			im.report.add("webhook", in.Name, ActionCreate, "")
+		case 1:
+			h := existing[0]
+			if h.URL == in.URL && h.Events == in.Events && sameID(h.ServerID, serverID) && h.Format == in.Format &&
+				h.Enabled == in.Enabled && (secret == "" || h.Secret == secret) {
+				im.report.add("webhook", in.Name, ActionUnchanged, "")
+				continue
+			}
+			h.URL, h.Events, h.ServerID, h.Format, h.Enabled = in.URL, in.Events, serverID, in.Format, in.Enabled
+			if secret != "" {
+				h.Secret = secret
+			}
+			if err := im.tx.Save(&h).Error; err != nil {
+				return err
+			}
+			im.report.add("webhook", in.Name, ActionUpdate, "")
+		default:
+			im.report.add("webhook", in.Name, ActionConflict, "several webhooks have this name")
+		}
+	}
+	return nil
+}
+
+// randomPasswordHash returns the hash of a password nobody knows
+func randomPasswordHash() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return model.HashPassword(hex.EncodeToString(buf))
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
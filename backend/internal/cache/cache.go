@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/metrics"
+
+	gocache "github.com/patrickmn/go-cache"
+	"gorm.io/gorm"
+)
+
+const cleanupInterval = 10 * time.Minute
+
+func init() {
+	collect := func(value func(Stats) uint64) func() []metrics.Sample {
+		return func() []metrics.Sample {
+			var samples []metrics.Sample
+			for _, c := range All() {
+				samples = append(samples, metrics.Sample{Labels: []string{c.name}, Value: float64(value(c.Stats()))})
+			}
+			return samples
+		}
+	}
+	metrics.NewCounterFunc("dockermanager_cache_hits_total", "Cache lookups that found an entry.",
+		collect(func(s Stats) uint64 { return s.Hits }), "cache")
+	metrics.NewCounterFunc("dockermanager_cache_misses_total", "Cache lookups that found no entry.",
+		collect(func(s Stats) uint64 { return s.Misses }), "cache")
+	metrics.NewGaugeFunc("dockermanager_cache_entries", "Entries currently held by a cache.",
+		collect(func(s Stats) uint64 { return uint64(s.Entries) }), "cache")
+}
+
+// Cache is a named in-memory cache whose TTL can be changed at runtime
+type Cache struct {
+	name   string
+	ttlKey string
+	store  *gocache.Cache
+
+	ttl    atomic.Int64
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// Stats is a snapshot of a cache's counters
+type Stats struct {
+	Name       string `json:"name"`
+	Entries    int    `json:"entries"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+var (
+	mu     sync.RWMutex
+	caches = map[string]*Cache{}
+)
+
+// New creates and registers a cache. ttlKey names the int config key (seconds) controlling its TTL.
+func New(name, ttlKey string, defaultTTL time.Duration) *Cache {
+	c := &Cache{
+		name:   name,
+		ttlKey: ttlKey,
+		store:  gocache.New(defaultTTL, cleanupInterval),
+	}
+	c.ttl.Store(int64(defaultTTL))
+
+	mu.Lock()
+	caches[name] = c
+	mu.Unlock()
+	return c
+}
+
+// Configure applies the TTLs stored in the config registry and follows later changes
+func Configure(db *gorm.DB) {
+	for _, c := range All() {
+		c := c
+		if c.ttlKey == "" {
+			continue
+		}
+		c.SetTTL(time.Duration(config.GetInt(db, c.ttlKey)) * time.Second)
+		config.OnChange(c.ttlKey, func(value string) {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				c.SetTTL(time.Duration(seconds) * time.Second)
+			}
+		})
+	}
+}
+
+// Lookup returns a registered cache by name
+func Lookup(name string) (*Cache, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := caches[name]
+	return c, ok
+}
+
+// All returns every registered cache sorted by name
+func All() []*Cache {
+	mu.RLock()
+	defer mu.RUnlock()
+	all := make([]*Cache, 0, len(caches))
+	for _, c := range caches {
+		all = append(all, c)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	return all
+}
+
+// Name returns the registered name of the cache
+func (c *Cache) Name() string {
+	return c.name
+}
+
+// TTL returns the TTL applied to new entries
+func (c *Cache) TTL() time.Duration {
+	return time.Duration(c.ttl.Load())
+}
+
+// SetTTL changes the TTL applied to entries stored from now on
+func (c *Cache) SetTTL(ttl time.Duration) {
+	if ttl > 0 {
+		c.ttl.Store(int64(ttl))
+	}
+}
+
+// Get returns a cached value and records a hit or miss
+func (c *Cache) Get(key string) (interface{}, bool) {
+	v, found := c.store.Get(key)
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return v, found
+}
+
+// Set stores a value using the current TTL
+func (c *Cache) Set(key string, value interface{}) {
+	c.store.Set(key, value, c.TTL())
+}
+
+// SetFor stores a value for ttl instead of the cache's TTL, such as an entry that is served
+// stale past the TTL while it is refreshed
+func (c *Cache) SetFor(key string, value interface{}, ttl time.Duration) {
+	c.store.Set(key, value, ttl)
+}
+
+// Delete removes a single entry
+func (c *Cache) Delete(key string) {
+	c.store.Delete(key)
+}
+
+// Flush removes all entries
+func (c *Cache) Flush() {
+	c.store.Flush()
+}
+
+// Stats returns a snapshot of the cache's counters
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Name:       c.name,
+		Entries:    c.store.ItemCount(),
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		TTLSeconds: int64(c.TTL() / time.Second),
+	}
+}
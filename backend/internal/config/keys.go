@@ -0,0 +1,375 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+func init() {
+	Register(Key{
+		Name:        model.ConfigKeyTelegramBotToken,
+		Type:        TypeSecret,
+		Description: "Telegram bot token. Changing it restarts the bot.",
+	})
+	Register(Key{
+		Name:        model.ConfigKeyTelegramWebAppURL,
+		Type:        TypeURL,
+		Description: "Public URL of the Telegram Web App, including the base path when the panel is served under one",
+		Validate:    validatePanelURL,
+	})
+	Register(Key{
+		Name:        model.ConfigKeyPingTargets,
+		Type:        TypeString,
+		Description: "Latency probe targets, as a JSON list of {name, host} or a comma separated host list. A host may name the port TCP probes connect to, as host:port.",
+		Validate:    validatePingTargets,
+	})
+	Register(Key{
+		Name:        model.ConfigKeyPingTCPPort,
+		Type:        TypeInt,
+		Default:     "443",
+		Description: "Port latency probes connect to when a target does not answer ICMP and names no port",
+		Validate:    intRange(1, 65535),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyStatsInterval,
+		Type:        TypeInt,
+		Default:     "300",
+		Description: "Interval in seconds between stats collector runs",
+		Validate:    minInt(30),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyDiskAlertPercent,
+		Type:        TypeInt,
+		Default:     "90",
+		Description: "Percentage of the disk holding Docker's data above which users are alerted. 0 disables the alert.",
+		Validate:    intRange(0, 100),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyContainerCacheTTL,
+		Type:        TypeInt,
+		Default:     "300",
+		Description: "Seconds a server's container list stays cached",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyContainerStatsTTL,
+		Type:        TypeInt,
+		Default:     "15",
+		Description: "Seconds the resource usage of a server's containers stays cached",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyServerCacheTTL,
+		Type:        TypeInt,
+		Default:     "300",
+		Description: "Seconds server lists and server records stay cached",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyCORSOrigins,
+		Type:        TypeString,
+		Description: "Comma separated origins allowed to call the API cross-origin, e.g. https://*.example.com. Empty allows same-origin only.",
+		Validate:    validateOrigins,
+	})
+	Register(Key{
+		Name:        model.ConfigKeyBackupInterval,
+		Type:        TypeInt,
+		Default:     "0",
+		Description: "Hours between automatic backups into <data-dir>/backups. 0 disables them.",
+		Validate:    minInt(0),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyBackupRetention,
+		Type:        TypeInt,
+		Default:     "7",
+		Description: "Number of stored backups to keep",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeySMTPHost,
+		Type:        TypeString,
+		Description: "SMTP server for email notifications. Empty disables email.",
+	})
+	Register(Key{
+		Name:        model.ConfigKeySMTPPort,
+		Type:        TypeInt,
+		Default:     "587",
+		Description: "SMTP server port",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeySMTPUsername,
+		Type:        TypeString,
+		Description: "SMTP user name. Empty sends without authentication.",
+	})
+	Register(Key{
+		Name:        model.ConfigKeySMTPPassword,
+		Type:        TypeSecret,
+		Description: "SMTP password",
+	})
+	Register(Key{
+		Name:        model.ConfigKeySMTPFrom,
+		Type:        TypeString,
+		Description: "Sender address of notification emails, e.g. DockerManager <ops@example.com>",
+		Validate:    validateAddress,
+	})
+	Register(Key{
+		Name:        model.ConfigKeySMTPSecurity,
+		Type:        TypeString,
+		Default:     "starttls",
+		Description: "SMTP connection security: starttls, tls (implicit, usually port 465) or none",
+		Validate:    oneOf("starttls", "tls", "none"),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyMaintenanceMode,
+		Type:        TypeBool,
+		Default:     "false",
+		Description: "Maintenance mode. Rejects changes from non-admin users and pauses scheduled tasks.",
+	})
+	Register(Key{
+		Name:        model.ConfigKeyMaintenanceMessage,
+		Type:        TypeString,
+		Description: "Message shown to users while maintenance mode is on",
+	})
+	Register(Key{
+		Name:        model.ConfigKeyMaintenanceUntil,
+		Type:        TypeString,
+		Description: "Optional RFC 3339 time at which maintenance mode ends by itself, e.g. 2024-05-01T22:00:00Z",
+		Validate:    validateTime,
+	})
+	Register(Key{
+		Name:        model.ConfigKeyUpdateCheck,
+		Type:        TypeBool,
+		Default:     "true",
+		Description: "Check GitHub once a day for a newer DockerManager release and notify admins",
+	})
+	Register(Key{
+		Name:        model.ConfigKeySSHCircuitThreshold,
+		Type:        TypeInt,
+		Default:     "5",
+		Description: "Consecutive SSH connection failures after which a server is marked unreachable for the cooldown. 0 disables the circuit breaker.",
+		Validate:    minInt(0),
+	})
+	Register(Key{
+		Name:        model.ConfigKeySSHCircuitCooldown,
+		Type:        TypeInt,
+		Default:     "60",
+		Description: "Seconds an unreachable server fails fast before a single probe connection is tried",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeySSHPoolIdle,
+		Type:        TypeInt,
+		Default:     "300",
+		Description: "Seconds an unused pooled SSH connection stays open for reuse. 0 disables pooling and dials a connection per command.",
+		Validate:    minInt(0),
+	})
+	Register(Key{
+		Name:        model.ConfigKeySSHCommandTimeout,
+		Type:        TypeInt,
+		Default:     "30",
+		Description: "Seconds an SSH command may run before it is killed. Image pulls, builds and scheduled tasks are not bound by it.",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeySSHStreamMaxMB,
+		Type:        TypeInt,
+		Default:     "1024",
+		Description: "Megabytes of output a streamed command, such as container logs or a file download, may produce before it is killed",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeySSHStreamTimeout,
+		Type:        TypeInt,
+		Default:     "3600",
+		Description: "Seconds a streamed command, such as container logs or a file download, may run before it is killed",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyLogTailMax,
+		Type:        TypeInt,
+		Default:     "10000",
+		Description: "Most lines a container log request may ask for with tail. Requests for all lines are bound by the stream limits only.",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyLogSearchMaxMatches,
+		Type:        TypeInt,
+		Default:     "1000",
+		Description: "Most matching lines a container log search or grep returns; the search stops there",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyFileContentMax,
+		Type:        TypeInt,
+		Default:     "1048576",
+		Description: "Largest container file in bytes that is returned as content when opened; larger files have to be downloaded",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyAutoUpdateInterval,
+		Type:        TypeInt,
+		Default:     "6",
+		Description: "Hours between checks of the images of all containers for updates",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeyAutoUpdateWindow,
+		Type:        TypeString,
+		Default:     "03:00-05:00",
+		Description: "Daily window in the panel's local time, as HH:MM-HH:MM, in which containers opted into auto-updates are updated. It may span midnight, e.g. 23:00-01:00.",
+		Validate:    validateWindow,
+	})
+	Register(Key{
+		Name:        model.ConfigKeyVolumeHelperImage,
+		Type:        TypeString,
+		Default:     "alpine:3",
+		Description: "Image of the throwaway container that reads volume files the SSH user cannot read on the host. It needs sh, ls, head and tail; point it at a local registry on hosts without internet access.",
+		Validate:    validateImage,
+	})
+	Register(Key{
+		Name:        model.ConfigKeySSHMaxSessions,
+		Type:        TypeInt,
+		Default:     "3",
+		Description: "SSH sessions that may be open on one host at once; further ones wait for a session to close",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeySSHSessionWait,
+		Type:        TypeInt,
+		Default:     "10",
+		Description: "Seconds an SSH session waits for a free slot on a busy host before failing",
+		Validate:    minInt(1),
+	})
+	Register(Key{
+		Name:        model.ConfigKeySSHKeepAlive,
+		Type:        TypeInt,
+		Default:     "30",
+		Description: "Seconds between SSH keepalives while a terminal is open. A terminal whose server stops answering is closed. 0 disables keepalives.",
+		Validate:    minInt(0),
+	})
+}
+
+// panelBasePath is the sub-path the panel is served under
+var panelBasePath string
+
+// SetBasePath records the sub-path the panel is served under, so that URLs pointing at the
+// panel can be checked against it. Call it before serving requests.
+func SetBasePath(p string) {
+	panelBasePath = p
+}
+
+// validatePanelURL checks that a URL pointing at the panel lies below its base path
+func validatePanelURL(value string) error {
+	if value == "" || panelBasePath == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Path != panelBasePath && !strings.HasPrefix(u.Path, panelBasePath+"/") {
+		return fmt.Errorf("URL must point below the panel's base path %s", panelBasePath)
+	}
+	return nil
+}
+
+func validateAddress(value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(value); err != nil {
+		return fmt.Errorf("invalid email address %q", value)
+	}
+	return nil
+}
+
+// validateTime accepts an empty value or an RFC 3339 time
+func validateTime(value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, value); err != nil {
+		return fmt.Errorf("invalid time %q, expected RFC 3339 such as 2024-05-01T22:00:00Z", value)
+	}
+	return nil
+}
+
+// ParseWindow parses a daily window such as "03:00-05:00" into the offsets of its start and end
+// from midnight. The end lies before the start for windows spanning midnight.
+func ParseWindow(value string) (start, end time.Duration, err error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(value), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM such as 03:00-05:00", value)
+	}
+	for _, t := range []struct {
+		value string
+		d     *time.Duration
+	}{{from, &start}, {to, &end}} {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(t.value))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM such as 03:00-05:00", value)
+		}
+		*t.d = time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("window %q is empty", value)
+	}
+	return start, end, nil
+}
+
+func validateWindow(value string) error {
+	_, _, err := ParseWindow(value)
+	return err
+}
+
+// imageReference matches the image references docker run accepts
+var imageReference = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/:@-]*$`)
+
+func validateImage(value string) error {
+	if !imageReference.MatchString(value) {
+		return fmt.Errorf("invalid image %q", value)
+	}
+	return nil
+}
+
+func validateOrigins(value string) error {
+	for _, o := range strings.Split(value, ",") {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		u, err := url.Parse(strings.Replace(o, "*.", "wildcard.", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+			return fmt.Errorf("invalid origin %q, expected scheme://host[:port]", o)
+		}
+	}
+	return nil
+}
+
+func validatePingTargets(value string) error {
+	if !strings.HasPrefix(strings.TrimSpace(value), "[") {
+		return nil
+	}
+	var targets []struct {
+		Name string `json:"name"`
+		Host string `json:"host"`
+	}
+	if err := json.Unmarshal([]byte(value), &targets); err != nil {
+		return errors.New("ping targets must be a JSON list of {name, host} objects")
+	}
+	for _, t := range targets {
+		if strings.TrimSpace(t.Host) == "" {
+			return errors.New("every ping target requires a host")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Type describes how a configuration value is validated and presented
+type Type string
+
+const (
+	TypeString Type = "string"
+	TypeInt    Type = "int"
+	TypeBool   Type = "bool"
+	TypeURL    Type = "url"
+	TypeJSON   Type = "json"
+	TypeSecret Type = "secret"
+)
+
+// Value sources reported alongside effective values
+const (
+	SourceDefault = "default"
+	SourceDB      = "db"
+)
+
+// SecretMask is returned in place of secret values. Writing it back leaves the stored value untouched.
+const SecretMask = "********"
+
+// ErrUnknownKey is returned when a key is not declared in the registry
+var ErrUnknownKey = errors.New("unknown configuration key")
+
+// Key declares a known configuration key
+type Key struct {
+	Name        string
+	Type        Type
+	Default     string
+	Description string
+	// Validate performs additional checks after the type check. Optional.
+	Validate func(value string) error
+}
+
+// Value is the effective value of a key
+type Value struct {
+	Key         string `json:"key"`
+	Type        Type   `json:"type"`
+	Value       string `json:"value"`
+	Default     string `json:"default"`
+	Source      string `json:"source"`
+	Description string `json:"description,omitempty"`
+}
+
+var (
+	mu        sync.RWMutex
+	registry  = map[string]*Key{}
+	hooks     = map[string][]func(value string){}
+	overrides = map[string]override{}
+)
+
+// override is a value pinned outside the database, e.g. by an environment variable
+type override struct {
+	value  string
+	source string
+}
+
+// ErrOverridden is returned when writing a key whose value is pinned by the environment
+var ErrOverridden = errors.New("value is set by the environment")
+
+// Register adds a key to the registry. Registering the same name twice panics.
+func Register(k Key) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := registry[k.Name]; exists {
+		panic(fmt.Sprintf("config: key %q registered twice", k.Name))
+	}
+	registry[k.Name] = &k
+}
+
+// Lookup returns the declaration for a key
+func Lookup(name string) (*Key, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	k, ok := registry[name]
+	return k, ok
+}
+
+// Keys returns all registered keys sorted by name
+func Keys() []*Key {
+	mu.RLock()
+	defer mu.RUnlock()
+	keys := make([]*Key, 0, len(registry))
+	for _, k := range registry {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys
+}
+
+// Override pins the effective value of a key, taking precedence over the database. Writes to
+// the key are rejected from then on.
+func Override(name, value, source string) {
+	mu.Lock()
+	defer mu.Unlock()
+	overrides[name] = override{value: value, source: source}
+}
+
+// Overridden reports whether a key's value is pinned outside the database
+func Overridden(name string) bool {
+	_, ok := lookupOverride(name)
+	return ok
+}
+
+func lookupOverride(name string) (override, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	o, ok := overrides[name]
+	return o, ok
+}
+
+// OnChange registers a hook that runs after a key has been written successfully
+func OnChange(name string, fn func(value string)) {
+	mu.Lock()
+	defer mu.Unlock()
+	hooks[name] = append(hooks[name], fn)
+}
+
+// Check validates a value against the declaration of the key
+func (k *Key) Check(value string) error {
+	switch k.Type {
+	case TypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s must be an integer", k.Name)
+		}
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be a boolean", k.Name)
+		}
+	case TypeURL:
+		if value != "" {
+			u, err := url.ParseRequestURI(value)
+			if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("%s must be an absolute http(s) URL", k.Name)
+			}
+		}
+	case TypeJSON:
+		if value != "" && !json.Valid([]byte(value)) {
+			return fmt.Errorf("%s must be valid JSON", k.Name)
+		}
+	}
+	if k.Validate != nil {
+		return k.Validate(value)
+	}
+	return nil
+}
+
+// Get returns the effective value of a key, falling back to its default
+func Get(db *gorm.DB, name string) (string, error) {
+	v, err := lookupValue(db, name)
+	if err != nil {
+		return "", err
+	}
+	return v.Value, nil
+}
+
+// GetInt returns the effective value of an int key. Invalid stored values fall back to the default.
+func GetInt(db *gorm.DB, name string) int {
+	k, ok := Lookup(name)
+	if !ok {
+		return 0
+	}
+	def, _ := strconv.Atoi(k.Default)
+	value, err := Get(db, name)
+	if err != nil {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || k.Check(value) != nil {
+		return def
+	}
+	return n
+}
+
+// GetBool returns the effective value of a bool key
+func GetBool(db *gorm.DB, name string) bool {
+	value, err := Get(db, name)
+	if err != nil {
+		return false
+	}
+	b, _ := strconv.ParseBool(value)
+	return b
+}
+
+// Effective returns the effective values of all registered keys. Secret values are masked when mask is true.
+func Effective(db *gorm.DB, mask bool) ([]Value, error) {
+	var rows []model.Config
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	stored := make(map[string]string, len(rows))
+	for _, r := range rows {
+		stored[r.Key] = r.Value
+	}
+
+	keys := Keys()
+	values := make([]Value, 0, len(keys))
+	for _, k := range keys {
+		v := Value{Key: k.Name, Type: k.Type, Value: k.Default, Default: k.Default, Source: SourceDefault, Description: k.Description}
+		if s, ok := stored[k.Name]; ok {
+			v.Value = s
+			v.Source = SourceDB
+		}
+		if o, ok := lookupOverride(k.Name); ok {
+			v.Value = o.value
+			v.Source = o.source
+		}
+		if mask {
+			v.Value = maskValue(k, v.Value)
+			v.Default = maskValue(k, v.Default)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// Masked returns the presentation form of a value for the given key
+func Masked(name, value string) string {
+	k, ok := Lookup(name)
+	if !ok {
+		return value
+	}
+	return maskValue(k, value)
+}
+
+func maskValue(k *Key, value string) string {
+	if k.Type == TypeSecret && value != "" {
+		return SecretMask
+	}
+	return value
+}
+
+// Set validates and stores a single key
+func Set(db *gorm.DB, name, value string) error {
+	return SetMany(db, map[string]*string{name: &value})
+}
+
+// SetMany validates every entry before writing any of them. A nil value resets the key to its default.
+// Secret keys receiving SecretMask are left untouched; overridden keys are rejected.
+func SetMany(db *gorm.DB, values map[string]*string) error {
+	writes := make(map[string]*string, len(values))
+	for name, value := range values {
+		k, ok := Lookup(name)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownKey, name)
+		}
+		if value != nil && k.Type == TypeSecret && *value == SecretMask {
+			continue
+		}
+		if _, ok := lookupOverride(name); ok {
+			return &ValidationError{Key: name, Err: fmt.Errorf("%s: %w", name, ErrOverridden)}
+		}
+		if value != nil {
+			if err := k.Check(*value); err != nil {
+				return &ValidationError{Key: name, Err: err}
+			}
+		}
+		writes[name] = value
+	}
+
+	changed := make(map[string]string, len(writes))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for name, value := range writes {
+			if value == nil {
+				if err := tx.Unscoped().Where(&model.Config{Key: name}).Delete(&model.Config{}).Error; err != nil {
+					return err
+				}
+				k, _ := Lookup(name)
+				changed[name] = k.Default
+				continue
+			}
+			if err := tx.Where(&model.Config{Key: name}).
+				Assign(model.Config{Value: *value}).
+				FirstOrCreate(&model.Config{Key: name}).Error; err != nil {
+				return err
+			}
+			changed[name] = *value
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for name, value := range changed {
+		notify(name, value)
+	}
+	return nil
+}
+
+// Reload runs every change hook with the current value, e.g. after the database was replaced
+func Reload(db *gorm.DB) error {
+	values, err := Effective(db, false)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		notify(v.Key, v.Value)
+	}
+	return nil
+}
+
+func notify(name, value string) {
+	mu.RLock()
+	fns := hooks[name]
+	mu.RUnlock()
+	for _, fn := range fns {
+		fn(value)
+	}
+}
+
+func lookupValue(db *gorm.DB, name string) (Value, error) {
+	k, ok := Lookup(name)
+	if !ok {
+		return Value{}, fmt.Errorf("%w: %s", ErrUnknownKey, name)
+	}
+	v := Value{Key: k.Name, Type: k.Type, Value: k.Default, Default: k.Default, Source: SourceDefault}
+	if o, ok := lookupOverride(name); ok {
+		v.Value = o.value
+		v.Source = o.source
+		return v, nil
+	}
+
+	var row model.Config
+	err := db.Where(&model.Config{Key: name}).First(&row).Error
+	if err == nil {
+		v.Value = row.Value
+		v.Source = SourceDB
+		return v, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return v, nil
+	}
+	return v, err
+}
+
+// ValidationError reports a value rejected by the registry
+type ValidationError struct {
+	Key string
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// minInt returns a validator enforcing a lower bound on int keys
+func minInt(min int) func(string) error {
+	return func(value string) error {
+		n, _ := strconv.Atoi(value)
+		if n < min {
+			return fmt.Errorf("value must be at least %d", min)
+		}
+		return nil
+	}
+}
+
+// intRange returns a validator enforcing both bounds on int keys
+func intRange(min, max int) func(string) error {
+	return func(value string) error {
+		n, _ := strconv.Atoi(value)
+		if n < min || n > max {
+			return fmt.Errorf("value must be between %d and %d", min, max)
+		}
+		return nil
+	}
+}
+
+// oneOf returns a validator accepting only the given values
+func oneOf(allowed ...string) func(string) error {
+	return func(value string) error {
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("value must be one of %s", strings.Join(allowed, ", "))
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Secret sources, in order of precedence
+const (
+	SourceEnv  = "env"
+	SourceFile = "file"
+)
+
+// Secret is a sensitive value read from DOCKERMANAGER_<NAME> or from the file named by
+// DOCKERMANAGER_<NAME>_FILE, such as a mounted Docker or Kubernetes secret
+type Secret struct {
+	// Env is the name of the variable the value came from
+	Env    string
+	Value  string
+	Source string
+}
+
+// Set reports whether the secret was supplied
+func (s Secret) Set() bool {
+	return s.Value != ""
+}
+
+// String describes where the secret came from with the value redacted to a short fingerprint
+func (s Secret) String() string {
+	if !s.Set() {
+		return "unset"
+	}
+	sum := sha256.Sum256([]byte(s.Value))
+	return fmt.Sprintf("%s:%s(sha256:%s)", s.Source, s.Env, hex.EncodeToString(sum[:4]))
+}
+
+// loadSecret reads a secret from the environment or a secret file. Setting both is an error
+// since it is unclear which one is meant. A trailing newline in the file is ignored.
+func loadSecret(name string) (Secret, error) {
+	envName := EnvPrefix + name
+	fileEnv := envName + "_FILE"
+	value := os.Getenv(envName)
+	file := os.Getenv(fileEnv)
+
+	switch {
+	case value != "" && file != "":
+		return Secret{}, fmt.Errorf("only one of %s and %s may be set", envName, fileEnv)
+	case value != "":
+		return Secret{Env: envName, Value: value, Source: SourceEnv}, nil
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return Secret{}, fmt.Errorf("failed to read %s: %w", fileEnv, err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			return Secret{}, fmt.Errorf("%s points at an empty file", fileEnv)
+		}
+		return Secret{Env: fileEnv, Value: value, Source: SourceFile}, nil
+	}
+	return Secret{}, nil
+}
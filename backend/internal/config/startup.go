@@ -0,0 +1,283 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to the names of all startup environment variables
+const EnvPrefix = "DOCKERMANAGER_"
+
+// Startup holds settings that must be known before the database is opened.
+// Precedence is defaults < environment < flags.
+type Startup struct {
+	// ListenAddr is a TCP address or unix:<path> for a Unix domain socket
+	ListenAddr     string
+	DataDir        string
+	DatabaseDriver string
+	DatabaseDSN    string
+	GinMode        string
+	LogLevel       string
+	LogFormat      string
+	TrustedProxies []string
+
+	// SocketMode and SocketGroup set the permissions and group of a Unix socket
+	SocketMode  os.FileMode
+	SocketGroup string
+	// BasePath is the URL sub-path the panel is served under, e.g. "/docker", or "" at the root
+	BasePath string
+
+	// TLSCert is a certificate file path or TLSSelfSigned. TLS is disabled when empty.
+	TLSCert string
+	TLSKey  string
+	// HTTPRedirectAddr optionally runs a plain HTTP listener that redirects to HTTPS
+	HTTPRedirectAddr string
+
+	// AutoMigrate runs gorm's AutoMigrate after the versioned migrations. Meant for development only.
+	AutoMigrate bool
+	// MigrateRollback reverts this many migrations and exits when positive
+	MigrateRollback int
+
+	// MetricsToken, when set, is the bearer token required to read /metrics
+	MetricsToken string
+
+	// JWTSecret overrides the generated <data-dir>/.sk file
+	JWTSecret Secret
+	// BotToken overrides the Telegram bot token stored in the database
+	BotToken Secret
+	// MasterKey encrypts stored registry credentials
+	MasterKey Secret
+
+	// ShutdownTimeout bounds how long in-flight requests may drain after SIGINT/SIGTERM
+	ShutdownTimeout time.Duration
+	// SSHTimeout bounds requests that run commands on a managed server
+	SSHTimeout time.Duration
+	// ActionTimeout bounds container actions, which may pull images
+	ActionTimeout time.Duration
+
+	// DemoMode runs the instance as a read-only public demo
+	DemoMode bool
+	// DemoUser and DemoPassword are the credentials of the read-only user seeded in demo mode
+	DemoUser     string
+	DemoPassword string
+	// DemoCommands are the terminal commands allowed in demo mode, empty for the defaults
+	DemoCommands []string
+}
+
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// MySQLDSN returns a MySQL DSN with parseTime enabled, without which time.Time columns cannot
+// be scanned. A parseTime the DSN sets is kept.
+func MySQLDSN(dsn string) string {
+	switch {
+	case strings.Contains(dsn, "parseTime="):
+		return dsn
+	case strings.Contains(dsn, "?"):
+		return dsn + "&parseTime=true"
+	}
+	return dsn + "?parseTime=true"
+}
+
+// TLSSelfSigned makes the server generate and persist a self-signed certificate in the data directory
+const TLSSelfSigned = "auto-self-signed"
+
+// LoadStartup resolves the startup configuration from the environment and the given command line arguments
+func LoadStartup(args []string) (*Startup, error) {
+	fs := flag.NewFlagSet("dockermanager", flag.ContinueOnError)
+
+	s := &Startup{}
+	var socketMode, trustedProxies, basePath, shutdownTimeout, sshTimeout, actionTimeout, demoCommands string
+	fs.StringVar(&s.ListenAddr, "listen", env("LISTEN_ADDR", ":9090"), "address to listen on (env "+EnvPrefix+"LISTEN_ADDR)")
+	fs.StringVar(&socketMode, "socket-mode", env("SOCKET_MODE", "0660"), "permissions of the unix socket when listening on unix:<path> (env "+EnvPrefix+"SOCKET_MODE)")
+	fs.StringVar(&s.SocketGroup, "socket-group", env("SOCKET_GROUP", ""), "group name or ID owning the unix socket (env "+EnvPrefix+"SOCKET_GROUP)")
+	fs.StringVar(&s.DataDir, "data-dir", env("DATA_DIR", "data"), "directory for the database and generated secrets (env "+EnvPrefix+"DATA_DIR)")
+	fs.StringVar(&s.DatabaseDriver, "db-driver", env("DB_DRIVER", DriverSQLite), "database driver: sqlite, postgres or mysql (env "+EnvPrefix+"DB_DRIVER)")
+	fs.StringVar(&s.DatabaseDSN, "db-dsn", env("DB_DSN", ""), "database DSN; for sqlite a file path defaulting to <data-dir>/dockerpulse.db (env "+EnvPrefix+"DB_DSN)")
+	fs.StringVar(&s.GinMode, "gin-mode", env("GIN_MODE", envRaw("GIN_MODE", "debug")), "gin mode: debug, release or test (env "+EnvPrefix+"GIN_MODE)")
+	fs.StringVar(&s.LogLevel, "log-level", env("LOG_LEVEL", "info"), "log level: debug, info, warn or error (env "+EnvPrefix+"LOG_LEVEL)")
+	fs.StringVar(&s.LogFormat, "log-format", env("LOG_FORMAT", "text"), "log format: text or json (env "+EnvPrefix+"LOG_FORMAT)")
+	fs.StringVar(&trustedProxies, "trusted-proxies", env("TRUSTED_PROXIES", ""), "comma separated list of trusted proxy IPs/CIDRs (env "+EnvPrefix+"TRUSTED_PROXIES)")
+	fs.StringVar(&basePath, "base-path", env("BASE_PATH", ""), "URL sub-path to serve the panel under, e.g. /docker (env "+EnvPrefix+"BASE_PATH)")
+
+	fs.StringVar(&s.TLSCert, "tls-cert", env("TLS_CERT", ""), "TLS certificate file, or \""+TLSSelfSigned+"\" (env "+EnvPrefix+"TLS_CERT)")
+	fs.StringVar(&s.TLSKey, "tls-key", env("TLS_KEY", ""), "TLS private key file (env "+EnvPrefix+"TLS_KEY)")
+	fs.StringVar(&s.HTTPRedirectAddr, "http-redirect-addr", env("HTTP_REDIRECT_ADDR", ""), "address of an optional HTTP listener redirecting to HTTPS (env "+EnvPrefix+"HTTP_REDIRECT_ADDR)")
+
+	fs.StringVar(&s.MetricsToken, "metrics-token", env("METRICS_TOKEN", ""), "bearer token required to scrape /metrics; empty leaves it open (env "+EnvPrefix+"METRICS_TOKEN)")
+
+	fs.BoolVar(&s.AutoMigrate, "auto-migrate", env("AUTO_MIGRATE", "false") == "true", "also run AutoMigrate on all models after migrations, for development (env "+EnvPrefix+"AUTO_MIGRATE)")
+	fs.IntVar(&s.MigrateRollback, "migrate-rollback", 0, "roll back the given number of migrations and exit")
+	fs.StringVar(&shutdownTimeout, "shutdown-timeout", env("SHUTDOWN_TIMEOUT", "15s"), "how long to wait for in-flight requests on shutdown (env "+EnvPrefix+"SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&sshTimeout, "ssh-timeout", env("SSH_TIMEOUT", "30s"), "timeout of requests that run SSH commands (env "+EnvPrefix+"SSH_TIMEOUT)")
+	fs.StringVar(&actionTimeout, "action-timeout", env("ACTION_TIMEOUT", "5m"), "timeout of container actions such as image pulls (env "+EnvPrefix+"ACTION_TIMEOUT)")
+
+	fs.BoolVar(&s.DemoMode, "demo-mode", env("DEMO_MODE", "false") == "true", "run as a read-only demo: reject changes, mask server addresses and restrict the terminal (env "+EnvPrefix+"DEMO_MODE)")
+	fs.StringVar(&s.DemoUser, "demo-user", env("DEMO_USER", "demo"), "username of the read-only user seeded in demo mode (env "+EnvPrefix+"DEMO_USER)")
+	fs.StringVar(&s.DemoPassword, "demo-password", env("DEMO_PASSWORD", "demo"), "password of the demo user (env "+EnvPrefix+"DEMO_PASSWORD)")
+	fs.StringVar(&demoCommands, "demo-commands", env("DEMO_COMMANDS", ""), "comma separated terminal commands allowed in demo mode; empty for a default set of read-only commands (env "+EnvPrefix+"DEMO_COMMANDS)")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	switch s.GinMode {
+	case "debug", "release", "test":
+	default:
+		return nil, fmt.Errorf("invalid gin mode %q", s.GinMode)
+	}
+	switch s.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return nil, fmt.Errorf("invalid log level %q", s.LogLevel)
+	}
+	if s.LogFormat != "text" && s.LogFormat != "json" {
+		return nil, fmt.Errorf("invalid log format %q", s.LogFormat)
+	}
+
+	switch s.DatabaseDriver {
+	case DriverSQLite:
+	case DriverPostgres, DriverMySQL:
+		if s.DatabaseDSN == "" {
+			return nil, fmt.Errorf("db-dsn is required for the %s driver", s.DatabaseDriver)
+		}
+	default:
+		return nil, fmt.Errorf("invalid database driver %q", s.DatabaseDriver)
+	}
+
+	if strings.HasPrefix(s.ListenAddr, "unix:") {
+		if strings.TrimPrefix(s.ListenAddr, "unix:") == "" {
+			return nil, fmt.Errorf("listen address %q has no socket path", s.ListenAddr)
+		}
+		if s.HTTPRedirectAddr != "" {
+			return nil, fmt.Errorf("http-redirect-addr cannot be used with a unix socket")
+		}
+	}
+	mode, err := strconv.ParseUint(socketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return nil, fmt.Errorf("invalid socket mode %q", socketMode)
+	}
+	s.SocketMode = os.FileMode(mode)
+
+	if s.TLSCert != "" && s.TLSCert != TLSSelfSigned && s.TLSKey == "" {
+		return nil, fmt.Errorf("tls-key is required when tls-cert is a file")
+	}
+	if s.HTTPRedirectAddr != "" && s.TLSCert == "" {
+		return nil, fmt.Errorf("http-redirect-addr requires TLS to be enabled")
+	}
+
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"shutdown timeout", shutdownTimeout, &s.ShutdownTimeout},
+		{"ssh timeout", sshTimeout, &s.SSHTimeout},
+		{"action timeout", actionTimeout, &s.ActionTimeout},
+	} {
+		timeout, err := time.ParseDuration(d.value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", d.name, d.value)
+		}
+		*d.dst = timeout
+	}
+
+	if s.BasePath, err = normalizeBasePath(basePath); err != nil {
+		return nil, err
+	}
+
+	for _, sec := range []struct {
+		name string
+		dst  *Secret
+	}{
+		{"JWT_SECRET", &s.JWTSecret},
+		{"TELEGRAM_BOT_TOKEN", &s.BotToken},
+		{"MASTER_KEY", &s.MasterKey},
+	} {
+		if *sec.dst, err = loadSecret(sec.name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range strings.Split(trustedProxies, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			s.TrustedProxies = append(s.TrustedProxies, p)
+		}
+	}
+	for _, cmd := range strings.Split(demoCommands, ",") {
+		if cmd = strings.TrimSpace(cmd); cmd != "" {
+			s.DemoCommands = append(s.DemoCommands, cmd)
+		}
+	}
+	if s.DemoMode && (s.DemoUser == "" || s.DemoPassword == "") {
+		return nil, fmt.Errorf("demo-user and demo-password are required in demo mode")
+	}
+	if s.DatabaseDSN == "" {
+		s.DatabaseDSN = filepath.Join(s.DataDir, "dockerpulse.db")
+	}
+	return s, nil
+}
+
+// TLSEnabled reports whether the server should serve HTTPS
+func (s *Startup) TLSEnabled() bool {
+	return s.TLSCert != ""
+}
+
+// DataPath returns the path of a file inside the data directory
+func (s *Startup) DataPath(name string) string {
+	return filepath.Join(s.DataDir, name)
+}
+
+// String renders the effective configuration for the startup log with secrets redacted
+func (s *Startup) String() string {
+	return fmt.Sprintf("listen=%s data_dir=%s db_driver=%s db_dsn=%s gin_mode=%s log_level=%s log_format=%s trusted_proxies=%v base_path=%s tls_cert=%s http_redirect_addr=%s auto_migrate=%t shutdown_timeout=%s ssh_timeout=%s action_timeout=%s demo_mode=%t jwt_secret=%s bot_token=%s master_key=%s",
+		s.ListenAddr, s.DataDir, s.DatabaseDriver, RedactDSN(s.DatabaseDSN), s.GinMode, s.LogLevel, s.LogFormat, s.TrustedProxies, s.BasePath, s.TLSCert, s.HTTPRedirectAddr, s.AutoMigrate, s.ShutdownTimeout, s.SSHTimeout, s.ActionTimeout, s.DemoMode, s.JWTSecret, s.BotToken, s.MasterKey)
+}
+
+// normalizeBasePath turns "docker/" into "/docker" and "/" into ""
+func normalizeBasePath(p string) (string, error) {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return "", nil
+	}
+	p = "/" + p
+	if path.Clean(p) != p || strings.ContainsAny(p, "?#%\\\"<> ") {
+		return "", fmt.Errorf("invalid base path %q", p)
+	}
+	return p, nil
+}
+
+var (
+	dsnUserinfo = regexp.MustCompile(`(://[^:/@]+:)[^@]*@`)
+	dsnUserPass = regexp.MustCompile(`^([^:/@]+:)[^@]*@`)
+	dsnKeyValue = regexp.MustCompile(`(?i)(password=)[^\s&]*`)
+)
+
+// RedactDSN masks passwords in URL style, user:pass@ and key=value style DSNs
+func RedactDSN(dsn string) string {
+	dsn = dsnUserinfo.ReplaceAllString(dsn, "${1}****@")
+	dsn = dsnUserPass.ReplaceAllString(dsn, "${1}****@")
+	return dsnKeyValue.ReplaceAllString(dsn, "${1}****")
+}
+
+func env(name, def string) string {
+	return envRaw(EnvPrefix+name, def)
+}
+
+func envRaw(name, def string) string {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		return v
+	}
+	return def
+}
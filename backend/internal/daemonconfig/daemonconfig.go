@@ -0,0 +1,192 @@
+// Package daemonconfig validates Docker daemon configurations (daemon.json) before they are
+// written to a server
+package daemonconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Kinds of values a daemon.json key accepts
+const (
+	KindString     = "string"
+	KindBool       = "boolean"
+	KindInteger    = "integer"
+	KindStringList = "string array"
+	KindObjectList = "object array"
+	KindObject     = "object"
+	KindStringMap  = "object of strings"
+)
+
+// keys lists the daemon.json keys dockerd accepts and the kind of value each takes. Keys missing
+// here make dockerd refuse to start, so they are rejected before anything is written.
+var keys = map[string]string{
+	"allow-nondistributable-artifacts": KindStringList,
+	"api-cors-header":                  KindString,
+	"authorization-plugins":            KindStringList,
+	"bip":                              KindString,
+	"bridge":                           KindString,
+	"builder":                          KindObject,
+	"cdi-spec-dirs":                    KindStringList,
+	"cgroup-parent":                    KindString,
+	"containerd":                       KindString,
+	"containerd-namespace":             KindString,
+	"containerd-plugins-namespace":     KindString,
+	"data-root":                        KindString,
+	"debug":                            KindBool,
+	"default-address-pools":            KindObjectList,
+	"default-cgroupns-mode":            KindString,
+	"default-gateway":                  KindString,
+	"default-gateway-v6":               KindString,
+	"default-ipc-mode":                 KindString,
+	"default-network-opts":             KindObject,
+	"default-runtime":                  KindString,
+	"default-shm-size":                 KindString,
+	"default-ulimits":                  KindObject,
+	"dns":                              KindStringList,
+	"dns-opts":                         KindStringList,
+	"dns-search":                       KindStringList,
+	"exec-opts":                        KindStringList,
+	"exec-root":                        KindString,
+	"experimental":                     KindBool,
+	"features":                         KindObject,
+	"fixed-cidr":                       KindString,
+	"fixed-cidr-v6":                    KindString,
+	"group":                            KindString,
+	"host-gateway-ip":                  KindString,
+	"hosts":                            KindStringList,
+	"icc":                              KindBool,
+	"init":                             KindBool,
+	"init-path":                        KindString,
+	"insecure-registries":              KindStringList,
+	"ip":                               KindString,
+	"ip-forward":                       KindBool,
+	"ip-masq":                          KindBool,
+	"ip6tables":                        KindBool,
+	"iptables":                         KindBool,
+	"ipv6":                             KindBool,
+	"labels":                           KindStringList,
+	"live-restore":                     KindBool,
+	"log-driver":                       KindString,
+	"log-format":                       KindString,
+	"log-level":                        KindString,
+	"log-opts":                         KindStringMap,
+	"max-concurrent-downloads":         KindInteger,
+	"max-concurrent-uploads":           KindInteger,
+	"max-download-attempts":            KindInteger,
+	"metrics-addr":                     KindString,
+	"mtu":                              KindInteger,
+	"no-new-privileges":                KindBool,
+	"node-generic-resources":           KindStringList,
+	"oom-score-adjust":                 KindInteger,
+	"pidfile":                          KindString,
+	"proxies":                          KindStringMap,
+	"raw-logs":                         KindBool,
+	"registry-mirrors":                 KindStringList,
+	"runtimes":                         KindObject,
+	"seccomp-profile":                  KindString,
+	"selinux-enabled":                  KindBool,
+	"shutdown-timeout":                 KindInteger,
+	"storage-driver":                   KindString,
+	"storage-opts":                     KindStringList,
+	"swarm-default-advertise-addr":     KindString,
+	"tls":                              KindBool,
+	"tlscacert":                        KindString,
+	"tlscert":                          KindString,
+	"tlskey":                           KindString,
+	"tlsverify":                        KindBool,
+	"userland-proxy":                   KindBool,
+	"userland-proxy-path":              KindString,
+	"userns-remap":                     KindString,
+}
+
+// KeyError reports a key that dockerd would reject. Kind is empty for unknown keys and
+// otherwise names the kind of value the key takes.
+type KeyError struct {
+	Key  string
+	Kind string
+}
+
+func (e *KeyError) Error() string {
+	if e.Kind == "" {
+		return fmt.Sprintf("unknown daemon option %q", e.Key)
+	}
+	return fmt.Sprintf("daemon option %q must be a %s", e.Key, e.Kind)
+}
+
+// Validate checks every key of a decoded daemon.json, in name order so the reported error is
+// stable
+func Validate(config map[string]interface{}) *KeyError {
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		kind, ok := keys[name]
+		if !ok {
+			return &KeyError{Key: name}
+		}
+		if !matches(kind, config[name]) {
+			return &KeyError{Key: name, Kind: kind}
+		}
+	}
+	return nil
+}
+
+// Encode renders a configuration the way daemon.json is usually written
+func Encode(config map[string]interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func matches(kind string, value interface{}) bool {
+	switch kind {
+	case KindString:
+		_, ok := value.(string)
+		return ok
+	case KindBool:
+		_, ok := value.(bool)
+		return ok
+	case KindInteger:
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case KindStringList:
+		return isListOf(value, KindString)
+	case KindObjectList:
+		return isListOf(value, KindObject)
+	case KindObject:
+		_, ok := value.(map[string]interface{})
+		return ok
+	case KindStringMap:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for _, v := range m {
+			if _, ok := v.(string); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isListOf(value interface{}, kind string) bool {
+	list, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, v := range list {
+		if !matches(kind, v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+// Package demo implements the read-only demo mode: changes are rejected, server addresses and SSH
+// usernames are masked in responses and the terminal only runs whitelisted commands
+package demo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Masked replaces server addresses and SSH usernames in responses
+const Masked = "******"
+
+// DefaultCommands are the terminal commands allowed in demo mode unless others are configured
+var DefaultCommands = []string{
+	"docker ps", "docker ps -a", "docker images", "docker stats --no-stream", "docker info", "docker version",
+	"uptime", "df -h", "free -m", "uname -a", "ls", "ls -la", "pwd", "whoami", "date", "clear",
+}
+
+// enabled and commands are set once by Configure before the server starts
+var (
+	enabled  bool
+	commands map[string]bool
+)
+
+// Configure turns demo mode on or off and sets the allowed terminal commands, DefaultCommands
+// when none are given
+func Configure(on bool, allowed []string) {
+	enabled = on
+	if len(allowed) == 0 {
+		allowed = DefaultCommands
+	}
+	commands = map[string]bool{}
+	for _, cmd := range allowed {
+		commands[normalize(cmd)] = true
+	}
+}
+
+// Enabled reports whether demo mode is on
+func Enabled() bool {
+	return enabled
+}
+
+// CommandAllowed reports whether a terminal command line is whitelisted. Lines are compared
+// whole, so arguments, pipes and command lists other than the listed ones are rejected.
+func CommandAllowed(line string) bool {
+	return commands[normalize(line)]
+}
+
+// Commands returns the allowed terminal commands, sorted
+func Commands() []string {
+	list := make([]string, 0, len(commands))
+	for cmd := range commands {
+		list = append(list, cmd)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func normalize(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// SeedUser creates or resets the demo user with the given password and read access to every
+// server. An existing admin of that name is left alone and reported as an error.
+func SeedUser(db *gorm.DB, username, password string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		err := tx.Where("username = ?", username).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user = model.User{Username: username, Password: password, Role: "user"}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case user.Role == "admin":
+			return fmt.Errorf("the demo user %q is an admin", username)
+		default:
+			hash, err := model.HashPassword(password)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&user).Update("password", hash).Error; err != nil {
+				return err
+			}
+		}
+
+		var serverIDs []uint
+		if err := tx.Model(&model.Server{}).Pluck("id", &serverIDs).Error; err != nil {
+			return err
+		}
+		for _, serverID := range serverIDs {
+			var count int64
+			if err := tx.Model(&model.ServerPermission{}).Where("user_id = ? AND server_id = ?", user.ID, serverID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			perm := model.ServerPermission{UserID: user.ID, ServerID: serverID, AccessLevel: model.AccessLevelRead}
+			if err := tx.Create(&perm).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MaskJSON replaces every "ip" and "jump_ip" value in a JSON document, and the "username" and
+// "jump_username" of objects that have an "ip", with Masked. Documents that are not valid JSON are
+// returned unchanged.
+func MaskJSON(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return body
+	}
+	if !mask(doc) {
+		return body
+	}
+	masked, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return masked
+}
+
+// mask masks doc in place and reports whether anything changed
+func mask(doc interface{}) bool {
+	changed := false
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if ip, ok := v["ip"].(string); ok {
+			if ip != "" {
+				v["ip"] = Masked
+				changed = true
+			}
+			for _, key := range []string{"username", "jump_ip", "jump_username"} {
+				if value, ok := v[key].(string); ok && value != "" {
+					v[key] = Masked
+					changed = true
+				}
+			}
+		}
+		for _, child := range v {
+			if mask(child) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if mask(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,211 @@
+package dockerapi
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// psEntry is a container as printed by "docker ps --format '{{json .}}'"
+type psEntry struct {
+	ID        string `json:"ID"`
+	Names     string `json:"Names"`
+	Image     string `json:"Image"`
+	Status    string `json:"Status"`
+	State     string `json:"State"`
+	Ports     string `json:"Ports"`
+	CreatedAt string `json:"CreatedAt"`
+	// Labels is comma separated, e.g. "com.docker.compose.project=blog,com.docker.compose.service=db"
+	Labels string `json:"Labels"`
+
+	// project and service are only set from the legacy format, which prints the two labels
+	project, service string
+	// labels is set by the Engine API, which returns them as an object
+	labels map[string]string
+}
+
+// Labels docker compose sets on the containers it creates
+const (
+	composeProjectLabel     = "com.docker.compose.project"
+	composeServiceLabel     = "com.docker.compose.service"
+	composeWorkingDirLabel  = "com.docker.compose.project.working_dir"
+	composeConfigFilesLabel = "com.docker.compose.project.config_files"
+)
+
+// createdAtLayouts are the formats docker prints container creation times in
+var createdAtLayouts = []string{"2006-01-02 15:04:05 -0700 MST", "2006-01-02 15:04:05 -0700", time.RFC3339Nano}
+
+// ParseContainers parses the output of "docker ps -a" in the JSON or the legacy format into a
+// slice of Container models. Lines in neither format are skipped.
+// UserID and Permission are left empty; they depend on who asks and are set when responding.
+func ParseContainers(output string, serverID uint) []model.Container {
+	var containers []model.Container
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var entry psEntry
+		if strings.HasPrefix(line, "{") {
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				continue
+			}
+		} else {
+			// Legacy format of docker versions without the json template function
+			parts := strings.Split(line, "|")
+			if len(parts) != 9 {
+				continue
+			}
+			entry = psEntry{ID: parts[0], Names: parts[1], Image: parts[2], Status: parts[3], State: parts[4], Ports: parts[5], CreatedAt: parts[6], project: parts[7], service: parts[8]}
+		}
+		containers = append(containers, entry.container(serverID))
+	}
+	return containers
+}
+
+func (e psEntry) container(serverID uint) model.Container {
+	// Example: "0.0.0.0:80->80/tcp, 0.0.0.0:443->443/tcp"
+	ports := []string{}
+	for _, pm := range strings.Split(e.Ports, ", ") {
+		if pm = strings.TrimSpace(pm); pm != "" {
+			ports = append(ports, pm)
+		}
+	}
+
+	// Fallback to current time if no format matches
+	createdAt := time.Now()
+	for _, layout := range createdAtLayouts {
+		if t, err := time.Parse(layout, e.CreatedAt); err == nil {
+			createdAt = t
+			break
+		}
+	}
+
+	state := e.State
+	if state == "" {
+		// Old docker versions only print the status, e.g. "Up 2 hours" or "Exited (0) 3 days ago"
+		state = stateFromStatus(e.Status)
+	}
+
+	labels := e.labels
+	if labels == nil {
+		labels = parseLabels(e.Labels)
+	}
+	project, service := e.project, e.service
+	if v, ok := labels[composeProjectLabel]; ok {
+		project = v
+	}
+	if v, ok := labels[composeServiceLabel]; ok {
+		service = v
+	}
+
+	return model.Container{
+		ID:             e.ID,
+		ServerID:       serverID,
+		Name:           containerName(e.Names),
+		Image:          e.Image,
+		Status:         e.Status,
+		State:          state,
+		Health:         healthFromStatus(e.Status),
+		Ports:          ports,
+		CreatedAt:      createdAt,
+		ComposeProject: project,
+		ComposeService: service,
+		Labels:         labels,
+	}
+}
+
+// ComposeProjects collects the compose projects of containers from their labels, sorted by name.
+// The working directory and config files are taken from the first container that names them.
+func ComposeProjects(containers []model.Container) []model.ComposeProject {
+	byName := map[string]*model.ComposeProject{}
+	names := []string{}
+	for _, ct := range containers {
+		if ct.ComposeProject == "" {
+			continue
+		}
+		p, ok := byName[ct.ComposeProject]
+		if !ok {
+			p = &model.ComposeProject{Name: ct.ComposeProject, ConfigFiles: []string{}, Services: []string{}}
+			byName[ct.ComposeProject] = p
+			names = append(names, ct.ComposeProject)
+		}
+		if p.WorkingDir == "" {
+			p.WorkingDir = ct.Labels[composeWorkingDirLabel]
+		}
+		if files := ct.Labels[composeConfigFilesLabel]; len(p.ConfigFiles) == 0 && files != "" {
+			p.ConfigFiles = strings.Split(files, ",")
+		}
+		if ct.ComposeService != "" && !slices.Contains(p.Services, ct.ComposeService) {
+			p.Services = append(p.Services, ct.ComposeService)
+		}
+		p.Containers++
+		if ct.State == "running" {
+			p.Running++
+		}
+	}
+	sort.Strings(names)
+	projects := make([]model.ComposeProject, 0, len(names))
+	for _, name := range names {
+		sort.Strings(byName[name].Services)
+		projects = append(projects, *byName[name])
+	}
+	return projects
+}
+
+// parseLabels splits the comma separated labels docker ps prints. Values may contain commas
+// too, such as the compose config file list, so a part without "=" continues the previous value.
+func parseLabels(s string) map[string]string {
+	labels := map[string]string{}
+	last := ""
+	for _, part := range strings.Split(s, ",") {
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			if last != "" {
+				labels[last] += "," + part
+			}
+			continue
+		}
+		labels[key] = value
+		last = key
+	}
+	return labels
+}
+
+// containerName picks the container's own name from the comma separated names docker prints.
+// Names with a slash are aliases from legacy links, e.g. "web/db".
+func containerName(names string) string {
+	list := strings.Split(names, ",")
+	for _, name := range list {
+		if !strings.Contains(name, "/") {
+			return name
+		}
+	}
+	return list[0]
+}
+
+func stateFromStatus(status string) string {
+	switch {
+	case strings.HasPrefix(status, "Up") && strings.Contains(status, "(Paused)"):
+		return "paused"
+	case strings.HasPrefix(status, "Up"):
+		return "running"
+	case strings.HasPrefix(status, "Restarting"):
+		return "restarting"
+	case strings.HasPrefix(status, "Exited"):
+		return "exited"
+	case strings.HasPrefix(status, "Created"):
+		return "created"
+	case strings.HasPrefix(status, "Removal"):
+		return "removing"
+	case strings.HasPrefix(status, "Dead"):
+		return "dead"
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package dockerapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+func TestParseContainersEmpty(t *testing.T) {
+	for _, output := range []string{"", "\n", "  \n\n"} {
+		if containers := ParseContainers(output, 1); len(containers) != 0 {
+			t.Errorf("ParseContainers(%q) = %+v, want none", output, containers)
+		}
+	}
+}
+
+func TestParseContainersSkipsMalformedLines(t *testing.T) {
+	output := `{"ID":"abc123","Names":"web","Image":"nginx","Status":"Up 2 hours","State":"running"}
+{"ID":"def456","Names":"db",
+Error response from daemon: something went wrong
+abc|too|few|fields
+{"ID":"789abc","Names":"cache","Image":"redis","Status":"Exited (0) 3 days ago","State":"exited"}
+`
+	containers := ParseContainers(output, 7)
+	if len(containers) != 2 {
+		t.Fatalf("got %d containers, want the 2 well-formed ones: %+v", len(containers), containers)
+	}
+	if containers[0].Name != "web" || containers[1].Name != "cache" {
+		t.Fatalf("names = %q, %q, want web and cache", containers[0].Name, containers[1].Name)
+	}
+	if containers[0].ServerID != 7 || containers[1].State != "exited" {
+		t.Fatalf("containers = %+v", containers)
+	}
+}
+
+func TestParseContainersLabelsWithCommas(t *testing.T) {
+	output := `{"ID":"abc123","Names":"shop-db-1","Image":"postgres:16","Status":"Up 5 minutes","State":"running",` +
+		`"Labels":"com.docker.compose.config_files=/srv/shop/compose.yml,/srv/shop/compose.prod.yml,com.docker.compose.project=shop,com.docker.compose.service=db,empty="}`
+	containers := ParseContainers(output, 1)
+	if len(containers) != 1 {
+		t.Fatalf("got %d containers, want 1", len(containers))
+	}
+	want := map[string]string{
+		"com.docker.compose.config_files": "/srv/shop/compose.yml,/srv/shop/compose.prod.yml",
+		"com.docker.compose.project":      "shop",
+		"com.docker.compose.service":      "db",
+		"empty":                           "",
+	}
+	ct := containers[0]
+	if !reflect.DeepEqual(ct.Labels, want) {
+		t.Fatalf("labels = %v, want %v", ct.Labels, want)
+	}
+	if ct.ComposeProject != "shop" || ct.ComposeService != "db" {
+		t.Fatalf("compose project %q service %q, want shop and db", ct.ComposeProject, ct.ComposeService)
+	}
+}
+
+func TestParseContainersPorts(t *testing.T) {
+	output := `{"ID":"abc123","Names":"web","Image":"nginx","Status":"Up 2 hours","State":"running","Ports":"0.0.0.0:80->80/tcp, [::]:80->80/tcp, 0.0.0.0:443->443/tcp, 9000/udp"}
+{"ID":"def456","Names":"worker","Image":"app","Status":"Up 2 hours","State":"running","Ports":""}`
+	containers := ParseContainers(output, 1)
+	if len(containers) != 2 {
+		t.Fatalf("got %d containers, want 2", len(containers))
+	}
+	want := []string{"0.0.0.0:80->80/tcp", "[::]:80->80/tcp", "0.0.0.0:443->443/tcp", "9000/udp"}
+	if !reflect.DeepEqual(containers[0].Ports, want) {
+		t.Fatalf("ports = %q, want %q", containers[0].Ports, want)
+	}
+	// No ports is an empty list, which the API returns as [] rather than null
+	if containers[1].Ports == nil || len(containers[1].Ports) != 0 {
+		t.Fatalf("ports without mappings = %#v, want an empty list", containers[1].Ports)
+	}
+}
+
+func TestParseContainersFields(t *testing.T) {
+	output := `{"ID":"abc123","Names":"app/db,db","Image":"postgres:16","Status":"Up 3 hours (healthy)","State":"","CreatedAt":"2024-03-01 12:30:45 +0100 CET"}
+def456|web|nginx|Up 1 minute (Paused)||0.0.0.0:8080->80/tcp|2024-03-02 08:00:00 +0000 UTC|blog|frontend`
+	containers := ParseContainers(output, 3)
+	if len(containers) != 2 {
+		t.Fatalf("got %d containers, want 2", len(containers))
+	}
+
+	db := containers[0]
+	if db.Name != "db" {
+		t.Errorf("name = %q, want db rather than the link alias", db.Name)
+	}
+	if db.State != "running" || db.Health != model.HealthHealthy {
+		t.Errorf("state %q health %q, want running and healthy from the status", db.State, db.Health)
+	}
+	if want := time.Date(2024, 3, 1, 11, 30, 45, 0, time.UTC); !db.CreatedAt.Equal(want) {
+		t.Errorf("created at = %v, want %v", db.CreatedAt, want)
+	}
+
+	// The legacy format carries the compose labels as fields of their own
+	web := containers[1]
+	if web.ID != "def456" || web.Image != "nginx" || web.State != "paused" {
+		t.Errorf("legacy line = %+v", web)
+	}
+	if web.ComposeProject != "blog" || web.ComposeService != "frontend" {
+		t.Errorf("compose project %q service %q, want blog and frontend", web.ComposeProject, web.ComposeService)
+	}
+	if !reflect.DeepEqual(web.Ports, []string{"0.0.0.0:8080->80/tcp"}) {
+		t.Errorf("ports = %q", web.Ports)
+	}
+}
@@ -0,0 +1,214 @@
+package dockerapi
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// MaskedValue replaces the values of sensitive environment variables
+const MaskedValue = "********"
+
+// secretEnvWords mark an environment variable whose name contains one of them as sensitive
+var secretEnvWords = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN"}
+
+// detailsInspect is what ParseDetails reads from docker inspect
+type detailsInspect struct {
+	ID           string `json:"Id"`
+	Name         string
+	Image        string
+	Created      time.Time
+	RestartCount int
+	State        struct {
+		Status     string
+		Running    bool
+		Paused     bool
+		Restarting bool
+		OOMKilled  bool
+		Pid        int
+		ExitCode   int
+		Error      string
+		StartedAt  time.Time
+		FinishedAt time.Time
+	}
+	Config struct {
+		Hostname   string
+		User       string
+		WorkingDir string
+		Image      string
+		Env        []string
+		Cmd        []string
+		Entrypoint []string
+		Labels     map[string]string
+		Tty        bool
+	}
+	HostConfig struct {
+		NetworkMode       string
+		Memory            int64
+		MemoryReservation int64
+		MemorySwap        int64
+		NanoCpus          int64
+		CPUShares         int64 `json:"CpuShares"`
+		CpusetCpus        string
+		PidsLimit         *int64
+	}
+	Mounts []struct {
+		Type        string
+		Name        string
+		Source      string
+		Destination string
+		Mode        string
+		RW          bool
+	}
+	NetworkSettings struct {
+		Ports    map[string][]PortBinding
+		Networks map[string]struct {
+			NetworkID         string
+			IPAddress         string
+			GlobalIPv6Address string
+			Gateway           string
+			MacAddress        string
+			Aliases           []string
+		}
+	}
+}
+
+// ParseDetails reads a container's docker inspect output into its sections
+func ParseDetails(inspect string) (model.ContainerDetails, error) {
+	var ct detailsInspect
+	if err := decodeInspect(inspect, &ct); err != nil {
+		return model.ContainerDetails{}, err
+	}
+	d := model.ContainerDetails{
+		ID:           ct.ID,
+		Name:         strings.TrimPrefix(ct.Name, "/"),
+		Image:        ct.Config.Image,
+		ImageID:      ct.Image,
+		Created:      ct.Created,
+		RestartCount: ct.RestartCount,
+		State: model.ContainerState{
+			Status:     ct.State.Status,
+			Running:    ct.State.Running,
+			Paused:     ct.State.Paused,
+			Restarting: ct.State.Restarting,
+			OOMKilled:  ct.State.OOMKilled,
+			PID:        ct.State.Pid,
+			ExitCode:   ct.State.ExitCode,
+			Error:      ct.State.Error,
+			StartedAt:  ct.State.StartedAt,
+			FinishedAt: ct.State.FinishedAt,
+		},
+		Config: model.ContainerConfig{
+			Hostname:   ct.Config.Hostname,
+			User:       ct.Config.User,
+			WorkingDir: ct.Config.WorkingDir,
+			Env:        nonNil(ct.Config.Env),
+			Cmd:        nonNil(ct.Config.Cmd),
+			Entrypoint: nonNil(ct.Config.Entrypoint),
+			Labels:     ct.Config.Labels,
+			Tty:        ct.Config.Tty,
+		},
+		Network: model.ContainerNetwork{
+			Mode:     ct.HostConfig.NetworkMode,
+			Networks: []model.ContainerNetworkEndpoint{},
+			Ports:    []model.ContainerPortMapping{},
+		},
+		Mounts: []model.ContainerMount{},
+		Resources: model.ContainerResourceLimits{
+			MemoryBytes:            ct.HostConfig.Memory,
+			MemoryReservationBytes: ct.HostConfig.MemoryReservation,
+			MemorySwapBytes:        ct.HostConfig.MemorySwap,
+			CPUs:                   float64(ct.HostConfig.NanoCpus) / 1e9,
+			CPUShares:              ct.HostConfig.CPUShares,
+			CPUSetCPUs:             ct.HostConfig.CpusetCpus,
+		},
+	}
+	if d.Config.Labels == nil {
+		d.Config.Labels = map[string]string{}
+	}
+	if ct.HostConfig.PidsLimit != nil && *ct.HostConfig.PidsLimit > 0 {
+		d.Resources.PidsLimit = *ct.HostConfig.PidsLimit
+	}
+	for _, name := range sortedKeys(ct.NetworkSettings.Networks) {
+		n := ct.NetworkSettings.Networks[name]
+		d.Network.Networks = append(d.Network.Networks, model.ContainerNetworkEndpoint{
+			Name:        name,
+			NetworkID:   n.NetworkID,
+			IPAddress:   n.IPAddress,
+			IPv6Address: n.GlobalIPv6Address,
+			Gateway:     n.Gateway,
+			MacAddress:  n.MacAddress,
+			Aliases:     nonNil(n.Aliases),
+		})
+	}
+	for _, port := range sortedKeys(ct.NetworkSettings.Ports) {
+		bindings := ct.NetworkSettings.Ports[port]
+		if len(bindings) == 0 {
+			d.Network.Ports = append(d.Network.Ports, model.ContainerPortMapping{ContainerPort: port})
+		}
+		for _, b := range bindings {
+			d.Network.Ports = append(d.Network.Ports, model.ContainerPortMapping{ContainerPort: port, HostIP: b.HostIP, HostPort: b.HostPort})
+		}
+	}
+	for _, m := range ct.Mounts {
+		d.Mounts = append(d.Mounts, model.ContainerMount{Type: m.Type, Name: m.Name, Source: m.Source, Destination: m.Destination, Mode: m.Mode, RW: m.RW})
+	}
+	sort.Slice(d.Mounts, func(i, j int) bool { return d.Mounts[i].Destination < d.Mounts[j].Destination })
+
+	var err error
+	if d.RestartPolicy, err = RestartPolicyFromInspect(inspect); err != nil {
+		return model.ContainerDetails{}, err
+	}
+	if d.Health, err = ParseHealth(inspect, 1); err != nil {
+		return model.ContainerDetails{}, err
+	}
+	return d, nil
+}
+
+// MaskEnv returns env with the values of variables whose names contain PASSWORD, PASSWD, SECRET
+// or TOKEN replaced by MaskedValue
+func MaskEnv(env []string) []string {
+	masked := make([]string, len(env))
+	for i, entry := range env {
+		name, _, hasValue := strings.Cut(entry, "=")
+		upper := strings.ToUpper(name)
+		for _, word := range secretEnvWords {
+			if hasValue && strings.Contains(upper, word) {
+				entry = name + "=" + MaskedValue
+				break
+			}
+		}
+		masked[i] = entry
+	}
+	return masked
+}
+
+// MaskInspectEnv masks the environment in docker inspect output like MaskEnv. The output is
+// re-encoded, indented like docker prints it.
+func MaskInspectEnv(inspect string) (string, error) {
+	var list []map[string]any
+	if err := json.Unmarshal([]byte(inspect), &list); err != nil {
+		return "", err
+	}
+	for _, ct := range list {
+		config, _ := ct["Config"].(map[string]any)
+		env, _ := config["Env"].([]any)
+		for i, v := range env {
+			if entry, ok := v.(string); ok {
+				env[i] = MaskEnv([]string{entry})[0]
+			}
+		}
+	}
+	out, err := json.MarshalIndent(list, "", "    ")
+	return string(out), err
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
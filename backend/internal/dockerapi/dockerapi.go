@@ -0,0 +1,63 @@
+// Package dockerapi reads and manages the containers of a Docker host, either from what the
+// docker CLI prints or through the Docker Engine API
+package dockerapi
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// Backend runs container operations on a server's Docker. All backends return the same
+// containers, inspect output, logs and usage for the same host.
+type Backend interface {
+	// ListContainers returns all containers, with UserID and Permission left empty
+	ListContainers(ctx context.Context) ([]model.Container, error)
+	// InspectContainer returns what "docker inspect" prints: a JSON array holding the container
+	InspectContainer(ctx context.Context, containerID string) (string, error)
+	// ContainerAction starts, stops, restarts or removes a container, or pulls its image
+	ContainerAction(ctx context.Context, containerID, action string) error
+	// KillContainer sends a container one of KillSignals
+	KillContainer(ctx context.Context, containerID, signal string) error
+	// PullImage pulls the image a container was created from, passing each progress message to
+	// progress when it is not nil. It is the "pull" container action.
+	PullImage(ctx context.Context, containerID string, progress func(PullProgress)) error
+	// PullImageRef pulls an image by its reference, like "docker pull", passing the progress on
+	// like PullImage
+	PullImageRef(ctx context.Context, image string, progress func(PullProgress)) error
+	// InspectImage returns what "docker image inspect" prints: a JSON array holding the image
+	InspectImage(ctx context.Context, image string) (string, error)
+	// RenameContainer renames a container
+	RenameContainer(ctx context.Context, containerID, name string) error
+	// UpdateRestartPolicy changes the restart policy of a container
+	UpdateRestartPolicy(ctx context.Context, containerID string, policy model.RestartPolicy) error
+	// CreateContainer creates a container from cfg, attached to all of its networks, without
+	// starting it and returns the container's ID
+	CreateContainer(ctx context.Context, cfg RunConfig) (string, error)
+	// ContainerLogs streams the part of the container's log opts selects, stdout and stderr
+	// merged with the stderr lines starting with StderrPrefix. The reader must be closed.
+	ContainerLogs(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error)
+	// FollowContainerLogs streams the container's stdout log from the last tail lines, or "all",
+	// and then keeps following it until the container stops, ctx is done or the stream limits
+	// are reached. Closing the reader stops following.
+	FollowContainerLogs(ctx context.Context, containerID, tail string) (io.ReadCloser, error)
+	// ContainerStats returns a single reading of the usage of the running containers, keyed by
+	// short container ID
+	ContainerStats(ctx context.Context) (map[string]Usage, error)
+	// ContainerSamples reads a container's usage samples times, waiting interval between the
+	// readings
+	ContainerSamples(ctx context.Context, containerID string, samples int, interval time.Duration) ([]Sample, error)
+	// ContainerTop lists the processes of a running container with the first of TopArgs ps
+	// accepts, failing with ErrNotRunning when the container does not run
+	ContainerTop(ctx context.Context, containerID string) (Top, error)
+	// Events streams the daemon's container events of ContainerEventActions as JSON objects,
+	// see Event, until ctx is done or the stream limits are reached. The reader must be closed.
+	Events(ctx context.Context) (io.ReadCloser, error)
+}
+
+// ShortID truncates an ID to the 12 characters docker prints by default
+func ShortID(id string) string {
+	return id[:min(len(id), 12)]
+}
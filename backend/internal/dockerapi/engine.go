@@ -0,0 +1,766 @@
+package dockerapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// ErrStreamLimit is returned by reads from a log stream that was aborted for producing more
+// output or running longer than the client's stream limits allow
+var ErrStreamLimit = errors.New("stream limit exceeded")
+
+// Limits of clients that keep the default hooks
+const (
+	defaultTimeout        = 30 * time.Second
+	defaultStreamBytes    = 1 << 30
+	defaultStreamDuration = time.Hour
+)
+
+// Client calls the Docker Engine API. Its transport decides how the engine is reached, such as
+// the host's Docker socket forwarded through SSH or TCP with mutual TLS.
+type Client struct {
+	http *http.Client
+	// base is the URL paths are appended to, e.g. "https://10.0.0.5:2376"
+	base string
+	// serverID is set on the listed containers
+	serverID uint
+
+	// Track records the duration and result of every operation
+	Track func(op string, start time.Time, err *error)
+	// Bound bounds the context of every request except image pulls and log and event streams
+	Bound func(ctx context.Context) (context.Context, context.CancelFunc)
+	// StreamLimits returns how much output a log stream may produce and how long it may run
+	StreamLimits func() (maxBytes int64, maxDuration time.Duration)
+	// RegistryAuth returns the X-Registry-Auth header pulls of an image send, empty to pull
+	// without logging in
+	RegistryAuth func(image string) string
+}
+
+// New returns a client for the engine of a server, sending requests to base through transport
+func New(transport http.RoundTripper, base string, serverID uint) *Client {
+	return &Client{
+		http:     &http.Client{Transport: transport},
+		base:     strings.TrimSuffix(base, "/"),
+		serverID: serverID,
+		Track:    func(string, time.Time, *error) {},
+		Bound: func(ctx context.Context) (context.Context, context.CancelFunc) {
+			return context.WithTimeout(ctx, defaultTimeout)
+		},
+		StreamLimits: func() (int64, time.Duration) {
+			return defaultStreamBytes, defaultStreamDuration
+		},
+		RegistryAuth: func(string) string { return "" },
+	}
+}
+
+// engineContainer is a container as listed by GET /containers/json
+type engineContainer struct {
+	ID      string `json:"Id"`
+	Names   []string
+	Image   string
+	Created int64
+	Ports   []enginePort
+	Labels  map[string]string
+	State   string
+	Status  string
+}
+
+type enginePort struct {
+	IP          string
+	PrivatePort uint16
+	PublicPort  uint16
+	Type        string
+}
+
+func (c *Client) ListContainers(ctx context.Context) (_ []model.Container, err error) {
+	defer c.Track("list_containers", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	var list []engineContainer
+	if err := c.getJSON(ctx, "/containers/json", url.Values{"all": {"1"}}, &list); err != nil {
+		return nil, err
+	}
+	var containers []model.Container
+	for _, entry := range list {
+		containers = append(containers, entry.psEntry().container(c.serverID))
+	}
+	return containers, nil
+}
+
+// psEntry converts the container to what docker ps prints for it, so containers listed through
+// the API and the CLI convert to model.Container alike
+func (c engineContainer) psEntry() psEntry {
+	names := make([]string, len(c.Names))
+	for i, name := range c.Names {
+		names[i] = strings.TrimPrefix(name, "/")
+	}
+	image := c.Image
+	if strings.HasPrefix(image, "sha256:") {
+		// Containers of untagged images show the short image ID
+		image = ShortID(strings.TrimPrefix(image, "sha256:"))
+	}
+	return psEntry{
+		ID:        ShortID(c.ID),
+		Names:     strings.Join(names, ","),
+		Image:     image,
+		Status:    c.Status,
+		State:     c.State,
+		Ports:     displayablePorts(c.Ports),
+		CreatedAt: time.Unix(c.Created, 0).UTC().Format(time.RFC3339Nano),
+		labels:    c.Labels,
+	}
+}
+
+func (c *Client) InspectContainer(ctx context.Context, containerID string) (_ string, err error) {
+	defer c.Track("inspect", time.Now(), &err)
+	return c.inspect(ctx, "/containers/"+url.PathEscape(containerID)+"/json")
+}
+
+func (c *Client) InspectImage(ctx context.Context, image string) (_ string, err error) {
+	defer c.Track("inspect_image", time.Now(), &err)
+	return c.inspect(ctx, "/images/"+url.PathEscape(image)+"/json")
+}
+
+// inspect returns the object at path as docker inspect prints it
+func (c *Client) inspect(ctx context.Context, path string) (string, error) {
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	resp, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	// docker inspect prints an indented array of the inspected objects
+	var out bytes.Buffer
+	if err := json.Indent(&out, append(append([]byte("["), bytes.TrimSpace(body)...), ']'), "", "    "); err != nil {
+		return "", fmt.Errorf("unexpected inspect response: %w", err)
+	}
+	out.WriteByte('\n')
+	return out.String(), nil
+}
+
+func (c *Client) RenameContainer(ctx context.Context, containerID, name string) (err error) {
+	defer c.Track("container_rename", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	return StateConflict(c.postJSON(ctx, "/containers/"+url.PathEscape(containerID)+"/rename", url.Values{"name": {name}}, nil, nil))
+}
+
+func (c *Client) UpdateRestartPolicy(ctx context.Context, containerID string, policy model.RestartPolicy) (err error) {
+	defer c.Track("container_update", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	body := map[string]any{"RestartPolicy": map[string]any{"Name": policy.Name, "MaximumRetryCount": policy.MaximumRetryCount}}
+	return c.postJSON(ctx, "/containers/"+url.PathEscape(containerID)+"/update", nil, body, nil)
+}
+
+func (c *Client) CreateContainer(ctx context.Context, cfg RunConfig) (_ string, err error) {
+	defer c.Track("container_create", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := c.postJSON(ctx, "/containers/create", url.Values{"name": {cfg.Name}}, createBody(cfg), &created); err != nil {
+		return "", err
+	}
+	for _, network := range cfg.ExtraNetworks() {
+		connect := map[string]any{
+			"Container":      created.ID,
+			"EndpointConfig": map[string]any{"Aliases": cfg.Aliases(network)},
+		}
+		if err := c.postJSON(ctx, "/networks/"+url.PathEscape(network)+"/connect", nil, connect, nil); err != nil {
+			if resp, rmErr := c.request(ctx, http.MethodDelete, "/containers/"+created.ID, url.Values{"force": {"1"}}); rmErr == nil {
+				resp.Body.Close()
+			}
+			return "", err
+		}
+	}
+	return created.ID, nil
+}
+
+// createBody returns the request body of POST /containers/create for cfg
+func createBody(cfg RunConfig) map[string]any {
+	exposed := map[string]struct{}{}
+	for port := range cfg.Ports {
+		exposed[port] = struct{}{}
+	}
+	hostConfig := map[string]any{
+		"Binds":         cfg.Binds,
+		"Tmpfs":         cfg.Tmpfs,
+		"PortBindings":  cfg.Ports,
+		"RestartPolicy": map[string]any{"Name": cfg.RestartPolicy, "MaximumRetryCount": cfg.MaxRetries},
+		"NetworkMode":   cfg.NetworkMode,
+		"Privileged":    cfg.Privileged,
+		"CapAdd":        cfg.CapAdd,
+		"CapDrop":       cfg.CapDrop,
+		"Devices":       cfg.Devices,
+		"ExtraHosts":    cfg.ExtraHosts,
+		"Memory":        cfg.Memory,
+		"NanoCpus":      cfg.NanoCPUs,
+	}
+	if cfg.LogDriver != "" {
+		hostConfig["LogConfig"] = map[string]any{"Type": cfg.LogDriver, "Config": cfg.LogOptions}
+	}
+	config := map[string]any{
+		"Image":        cfg.Image,
+		"Hostname":     cfg.Hostname,
+		"User":         cfg.User,
+		"WorkingDir":   cfg.WorkingDir,
+		"Env":          cfg.Env,
+		"Labels":       cfg.Labels,
+		"Tty":          cfg.Tty,
+		"OpenStdin":    cfg.OpenStdin,
+		"ExposedPorts": exposed,
+		"HostConfig":   hostConfig,
+	}
+	// Unlike null, empty lists would replace the image's entrypoint and command
+	if cfg.Entrypoint != nil {
+		config["Entrypoint"] = cfg.Entrypoint
+	}
+	if cfg.Cmd != nil {
+		config["Cmd"] = cfg.Cmd
+	}
+	if primary := cfg.primaryNetwork(); primary != "" {
+		config["NetworkingConfig"] = map[string]any{
+			"EndpointsConfig": map[string]any{primary: map[string]any{"Aliases": cfg.Aliases(primary)}},
+		}
+	}
+	return config
+}
+
+func (c *Client) ContainerAction(ctx context.Context, containerID, action string) (err error) {
+	defer c.Track("container_action", time.Now(), &err)
+	method, path, query := http.MethodPost, "/containers/"+url.PathEscape(containerID), url.Values{}
+	switch action {
+	case "start", "stop", "restart", "pause", "unpause", "kill":
+		path += "/" + action
+	case "remove":
+		method = http.MethodDelete
+		query.Set("force", "1")
+	case "pull":
+		return c.PullImage(ctx, containerID, nil)
+	default:
+		return fmt.Errorf("unsupported action")
+	}
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	resp, err := c.request(ctx, method, path, query)
+	if err != nil {
+		return StateConflict(err)
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) KillContainer(ctx context.Context, containerID, signal string) (err error) {
+	defer c.Track("container_action", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	resp, err := c.request(ctx, http.MethodPost, "/containers/"+url.PathEscape(containerID)+"/kill", url.Values{"signal": {signal}})
+	if err != nil {
+		return StateConflict(err)
+	}
+	return resp.Body.Close()
+}
+
+// PullImage pulls the image a container was created from, like "docker pull" with the
+// reference from the container's config. Registry credentials stored on the host are not
+// available to the API, private images are pulled with the login from RegistryAuth.
+func (c *Client) PullImage(ctx context.Context, containerID string, progress func(PullProgress)) (err error) {
+	defer c.Track("pull_image", time.Now(), &err)
+	inspectCtx, cancel := c.Bound(ctx)
+	defer cancel()
+	var inspect struct {
+		Config struct {
+			Image string
+		}
+	}
+	if err := c.getJSON(inspectCtx, "/containers/"+url.PathEscape(containerID)+"/json", nil, &inspect); err != nil {
+		return err
+	}
+	return c.pullImage(ctx, inspect.Config.Image, progress)
+}
+
+func (c *Client) PullImageRef(ctx context.Context, image string, progress func(PullProgress)) (err error) {
+	defer c.Track("pull_image", time.Now(), &err)
+	return c.pullImage(ctx, image, progress)
+}
+
+// pullImage pulls an image like "docker pull"
+func (c *Client) pullImage(ctx context.Context, image string, progress func(PullProgress)) error {
+	query := url.Values{"fromImage": {image}}
+	// Without a tag the API pulls every tag of the repository, the CLI only "latest"
+	if name := image[strings.LastIndex(image, "/")+1:]; !strings.ContainsAny(name, ":@") {
+		query.Set("tag", "latest")
+	}
+	var header http.Header
+	if auth := c.RegistryAuth(image); auth != "" {
+		header = http.Header{"X-Registry-Auth": {auth}}
+	}
+	// Pulls may take longer than the command timeout
+	resp, err := c.send(ctx, http.MethodPost, "/images/create", query, header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// The pull reports its progress as a stream of JSON messages, and failures as a message too
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg PullProgress
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+		if progress != nil {
+			progress(msg)
+		}
+	}
+}
+
+func (c *Client) ContainerLogs(ctx context.Context, containerID string, opts LogOptions) (_ io.ReadCloser, err error) {
+	defer c.Track("container_logs", time.Now(), &err)
+	return c.containerLogs(ctx, containerID, opts.query())
+}
+
+func (c *Client) FollowContainerLogs(ctx context.Context, containerID, tail string) (_ io.ReadCloser, err error) {
+	defer c.Track("follow_logs", time.Now(), &err)
+	return c.containerLogs(ctx, containerID, url.Values{"stdout": {"1"}, "tail": {tail}, "follow": {"1"}})
+}
+
+// containerLogs streams GET /containers/{id}/logs with query, demultiplexed when needed. When
+// query asks for stderr its lines are prefixed with StderrPrefix.
+func (c *Client) containerLogs(ctx context.Context, containerID string, query url.Values) (io.ReadCloser, error) {
+	// Logs of containers without a TTY are multiplexed, even when only stdout is asked for, and
+	// older engines do not tell the two formats apart. With a TTY both streams are one.
+	inspectCtx, cancel := c.Bound(ctx)
+	defer cancel()
+	var inspect struct {
+		Config struct {
+			Tty bool
+		}
+	}
+	if err := c.getJSON(inspectCtx, "/containers/"+url.PathEscape(containerID)+"/json", nil, &inspect); err != nil {
+		return nil, err
+	}
+
+	maxBytes, limit := c.StreamLimits()
+	streamCtx, cancelStream := context.WithTimeoutCause(ctx, limit, fmt.Errorf("%w: ran longer than %s", ErrStreamLimit, limit))
+	resp, err := c.request(streamCtx, http.MethodGet, "/containers/"+url.PathEscape(containerID)+"/logs", query)
+	if err != nil {
+		cancelStream()
+		return nil, err
+	}
+	st := &engineStream{ctx: streamCtx, cancel: cancelStream, body: resp.Body, r: resp.Body, max: maxBytes}
+	if !inspect.Config.Tty {
+		st.r = &demuxReader{r: resp.Body}
+		if query.Get("stderr") == "1" {
+			st.r = &demuxReader{r: resp.Body, prefix: StderrPrefix}
+		}
+	}
+	return st, nil
+}
+
+func (c *Client) ContainerTop(ctx context.Context, containerID string) (_ Top, err error) {
+	defer c.Track("container_top", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	var top Top
+	for _, args := range TopArgs {
+		query := url.Values{}
+		if args != "" {
+			query.Set("ps_args", args)
+		}
+		top = Top{}
+		if err = c.getJSON(ctx, "/containers/"+url.PathEscape(containerID)+"/top", query, &top); err == nil {
+			return top, nil
+		}
+		if err = StateConflict(err); errors.Is(err, ErrNotRunning) {
+			return top, err
+		}
+	}
+	return top, err
+}
+
+func (c *Client) Events(ctx context.Context) (_ io.ReadCloser, err error) {
+	defer c.Track("events", time.Now(), &err)
+	filters, err := json.Marshal(map[string][]string{"type": {"container"}, "event": ContainerEventActions})
+	if err != nil {
+		return nil, err
+	}
+	maxBytes, limit := c.StreamLimits()
+	streamCtx, cancelStream := context.WithTimeoutCause(ctx, limit, fmt.Errorf("%w: ran longer than %s", ErrStreamLimit, limit))
+	resp, err := c.request(streamCtx, http.MethodGet, "/events", url.Values{"filters": {string(filters)}})
+	if err != nil {
+		cancelStream()
+		return nil, err
+	}
+	return &engineStream{ctx: streamCtx, cancel: cancelStream, body: resp.Body, r: resp.Body, max: maxBytes}, nil
+}
+
+// statsWorkers is how many containers ContainerStats reads at once
+const statsWorkers = 8
+
+func (c *Client) ContainerStats(ctx context.Context) (_ map[string]Usage, err error) {
+	defer c.Track("container_stats", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	var list []engineContainer
+	if err := c.getJSON(ctx, "/containers/json", nil, &list); err != nil {
+		return nil, err
+	}
+
+	// Every reading takes a second, as the engine samples the CPU usage twice
+	usage := make(map[string]Usage, len(list))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statsWorkers)
+	for _, entry := range list {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			var st engineStats
+			// Containers whose reading fails, such as ones stopping meanwhile, are left out
+			if c.getJSON(ctx, "/containers/"+id+"/stats", url.Values{"stream": {"0"}}, &st) != nil {
+				return
+			}
+			mu.Lock()
+			usage[ShortID(id)] = st.usage()
+			mu.Unlock()
+		}(entry.ID)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (c *Client) ContainerSamples(ctx context.Context, containerID string, samples int, interval time.Duration) (_ []Sample, err error) {
+	defer c.Track("container_samples", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	var readings []Sample
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+		var st engineStats
+		if err := c.getJSON(ctx, "/containers/"+url.PathEscape(containerID)+"/stats", url.Values{"stream": {"0"}}, &st); err != nil {
+			return nil, err
+		}
+		readings = append(readings, Sample{Time: time.Now(), Usage: st.usage()})
+	}
+	return readings, nil
+}
+
+// Info is what GET /info reports about the engine
+type Info struct {
+	ServerVersion     string
+	Containers        int
+	ContainersRunning int
+}
+
+// Info returns the engine's version and container counts
+func (c *Client) Info(ctx context.Context) (_ *Info, err error) {
+	defer c.Track("docker_info", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	var info Info
+	if err := c.getJSON(ctx, "/info", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Ping checks that the engine answers
+func (c *Client) Ping(ctx context.Context) (err error) {
+	defer c.Track("ping", time.Now(), &err)
+	ctx, cancel := c.Bound(ctx)
+	defer cancel()
+	resp, err := c.request(ctx, http.MethodGet, "/_ping", nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// request sends an Engine API request. Statuses other than 2xx and 304 Not Modified, which the
+// engine answers starting a started or stopping a stopped container with, are returned as
+// errors with the engine's message. The response body must be closed.
+func (c *Client) request(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	return c.send(ctx, method, path, query, nil, nil)
+}
+
+// send is request with additional headers and a request body
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader) (*http.Response, error) {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(data))
+	}
+	return nil, fmt.Errorf("docker engine API %s %s: %s: %s", method, path, resp.Status, msg.Message)
+}
+
+// postJSON sends a POST request with in encoded as JSON, no body when in is nil, and decodes
+// the response into out unless it is nil
+func (c *Client) postJSON(ctx context.Context, path string, query url.Values, in, out any) error {
+	var body io.Reader
+	var header http.Header
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body, header = bytes.NewReader(b), http.Header{"Content-Type": {"application/json"}}
+	}
+	resp, err := c.send(ctx, http.MethodPost, path, query, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unexpected response to %s: %w", path, err)
+	}
+	return nil
+}
+
+// getJSON sends a GET request and decodes the response into v
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, v any) error {
+	resp, err := c.request(ctx, http.MethodGet, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("unexpected response to %s: %w", path, err)
+	}
+	return nil
+}
+
+// engineStream is a log or event stream from the Engine API, bound by the stream limits like
+// the streams of StreamCommand
+type engineStream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	body   io.ReadCloser
+	// r reads body, demultiplexed unless the container has a TTY
+	r      io.Reader
+	max, n int64
+	err    error
+}
+
+func (st *engineStream) Read(p []byte) (int, error) {
+	if st.err != nil {
+		return 0, st.err
+	}
+	n, err := st.r.Read(p)
+	st.n += int64(n)
+	if st.n > st.max {
+		st.err = fmt.Errorf("%w: more than %d bytes of output", ErrStreamLimit, st.max)
+		st.cancel()
+		return max(n-int(st.n-st.max), 0), st.err
+	}
+	if err != nil && err != io.EOF {
+		// Reads fail with a bare cancellation when the time limit passes
+		if cause := context.Cause(st.ctx); cause != nil {
+			err = cause
+		}
+		st.err = err
+	}
+	return n, err
+}
+
+func (st *engineStream) Close() error {
+	st.cancel()
+	return st.body.Close()
+}
+
+// demuxReader reads the payload of a multiplexed Engine API stream, where every write of the
+// container is framed by a header with its stream and size. Without a prefix the streams are
+// not told apart; with one, every line of stderr starts with it.
+type demuxReader struct {
+	r io.Reader
+	// left is what remains of the current frame, stream the stream it belongs to
+	left   uint32
+	stream byte
+	prefix string
+	// midLine tells that the last stderr payload did not end a line
+	midLine bool
+	// pending is prefixed stderr not read yet, err the error to return once it is
+	pending []byte
+	err     error
+}
+
+// stderrStream is the stream byte of stderr frames
+const stderrStream = 2
+
+func (d *demuxReader) Read(p []byte) (int, error) {
+	if len(d.pending) > 0 {
+		n := copy(p, d.pending)
+		d.pending = d.pending[n:]
+		return n, nil
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	for d.left == 0 {
+		var header [8]byte
+		if _, err := io.ReadFull(d.r, header[:]); err != nil {
+			return 0, err
+		}
+		d.stream = header[0]
+		d.left = binary.BigEndian.Uint32(header[4:])
+	}
+	if d.prefix == "" || d.stream != stderrStream {
+		n, err := d.r.Read(p[:min(len(p), int(d.left))])
+		d.left -= uint32(n)
+		if err == io.EOF && d.left > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+
+	chunk := make([]byte, min(len(p), int(d.left)))
+	n, err := d.r.Read(chunk)
+	d.left -= uint32(n)
+	if err == io.EOF && d.left > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	for _, line := range bytes.SplitAfter(chunk[:n], []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if !d.midLine {
+			d.pending = append(d.pending, d.prefix...)
+		}
+		d.pending = append(d.pending, line...)
+		d.midLine = line[len(line)-1] != '\n'
+	}
+	n = copy(p, d.pending)
+	d.pending = d.pending[n:]
+	if len(d.pending) > 0 {
+		d.err = err
+		return n, nil
+	}
+	return n, err
+}
+
+// displayablePorts formats ports the way docker ps does: ports published on the same port
+// number are grouped into ranges, e.g. "0.0.0.0:8000-8001->8000-8001/tcp", followed by ports
+// published on another number, e.g. "0.0.0.0:8080->80/tcp".
+func displayablePorts(ports []enginePort) string {
+	type portGroup struct {
+		first, last uint16
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		a, b := ports[i], ports[j]
+		if a.PrivatePort != b.PrivatePort {
+			return a.PrivatePort < b.PrivatePort
+		}
+		if a.IP != b.IP {
+			return a.IP < b.IP
+		}
+		if a.PublicPort != b.PublicPort {
+			return a.PublicPort < b.PublicPort
+		}
+		return a.Type < b.Type
+	})
+	groups := map[string]*portGroup{}
+	var keys, result, mappings []string
+	for _, port := range ports {
+		key := port.Type
+		if port.IP != "" {
+			if port.PublicPort != port.PrivatePort {
+				host := net.JoinHostPort(port.IP, strconv.Itoa(int(port.PublicPort)))
+				mappings = append(mappings, fmt.Sprintf("%s->%d/%s", host, port.PrivatePort, port.Type))
+				continue
+			}
+			key = port.IP + "/" + port.Type
+		}
+		group := groups[key]
+		if group == nil {
+			groups[key] = &portGroup{first: port.PrivatePort, last: port.PrivatePort}
+			keys = append(keys, key)
+			continue
+		}
+		if port.PrivatePort == group.last+1 {
+			group.last = port.PrivatePort
+			continue
+		}
+		result = append(result, formPortGroup(key, group.first, group.last))
+		groups[key] = &portGroup{first: port.PrivatePort, last: port.PrivatePort}
+	}
+	for _, key := range keys {
+		result = append(result, formPortGroup(key, groups[key].first, groups[key].last))
+	}
+	return strings.Join(append(result, mappings...), ", ")
+}
+
+// formPortGroup formats a group of displayablePorts, keyed by "ip/type" or "type"
+func formPortGroup(key string, first, last uint16) string {
+	ip, proto, ok := strings.Cut(key, "/")
+	if !ok {
+		ip, proto = "", key
+	}
+	group := strconv.Itoa(int(first))
+	if first != last {
+		group += "-" + strconv.Itoa(int(last))
+	}
+	if ip != "" {
+		group = net.JoinHostPort(ip, group) + "->" + group
+	}
+	return group + "/" + proto
+}
@@ -0,0 +1,48 @@
+package dockerapi
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// ContainerEventActions are the container events Backend.Events streams
+var ContainerEventActions = []string{"start", "stop", "die", "oom"}
+
+// Event is a docker event as the Engine API streams it and "docker events --format
+// '{{json .}}'" prints it
+type Event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Time     int64 `json:"time"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// ContainerEvent converts a container event of the server. ok is false for events of other
+// objects and for container events other than ContainerEventActions.
+func (e Event) ContainerEvent(serverID uint) (event model.ContainerEvent, ok bool) {
+	if e.Type != "container" || !slices.Contains(ContainerEventActions, e.Action) {
+		return event, false
+	}
+	event = model.ContainerEvent{
+		ServerID:    serverID,
+		ContainerID: e.Actor.ID,
+		Name:        e.Actor.Attributes["name"],
+		Image:       e.Actor.Attributes["image"],
+		Action:      e.Action,
+		Time:        time.Unix(e.Time, 0),
+	}
+	if e.TimeNano != 0 {
+		event.Time = time.Unix(0, e.TimeNano)
+	}
+	if code, err := strconv.Atoi(e.Actor.Attributes["exitCode"]); err == nil && e.Action == "die" {
+		event.ExitCode = &code
+	}
+	return event, true
+}
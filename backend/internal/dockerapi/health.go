@@ -0,0 +1,59 @@
+package dockerapi
+
+import (
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// healthFromStatus reads a container's health from its status, which ends with "(healthy)",
+// "(unhealthy)" or "(health: starting)" for running containers with a health check
+func healthFromStatus(status string) string {
+	switch {
+	case strings.Contains(status, "(healthy)"):
+		return model.HealthHealthy
+	case strings.Contains(status, "(unhealthy)"):
+		return model.HealthUnhealthy
+	case strings.Contains(status, "(health: starting)"):
+		return model.HealthStarting
+	}
+	return model.HealthNone
+}
+
+// ParseHealth reads the health check state and the last limit probes from what "docker
+// inspect" prints for a container. A limit of 0 keeps all of the probes docker kept.
+func ParseHealth(inspect string, limit int) (model.ContainerHealth, error) {
+	var c struct {
+		ID    string `json:"Id"`
+		State struct {
+			Health *struct {
+				Status        string
+				FailingStreak int
+				Log           []struct {
+					Start    time.Time
+					End      time.Time
+					ExitCode int
+					Output   string
+				}
+			}
+		}
+	}
+	if err := decodeInspect(inspect, &c); err != nil {
+		return model.ContainerHealth{}, err
+	}
+	health := model.ContainerHealth{ContainerID: c.ID, Status: model.HealthNone, Probes: []model.HealthProbe{}}
+	if c.State.Health == nil || c.State.Health.Status == "" {
+		return health, nil
+	}
+	health.Status = c.State.Health.Status
+	health.FailingStreak = c.State.Health.FailingStreak
+	log := c.State.Health.Log
+	if limit > 0 && len(log) > limit {
+		log = log[len(log)-limit:]
+	}
+	for _, probe := range log {
+		health.Probes = append(health.Probes, model.HealthProbe{Start: probe.Start, End: probe.End, ExitCode: probe.ExitCode, Output: probe.Output})
+	}
+	return health, nil
+}
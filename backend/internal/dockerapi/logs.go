@@ -0,0 +1,171 @@
+package dockerapi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// StderrPrefix starts every line ContainerLogs returns from a container's stderr
+const StderrPrefix = "[stderr] "
+
+// LogOptions selects the part of a container's log ContainerLogs returns
+type LogOptions struct {
+	// Tail is the number of lines from the end of the selection, or "all"
+	Tail string
+	// Since and Until bound the log by time; zero times leave it unbounded
+	Since, Until time.Time
+	// Timestamps starts every line with its RFC 3339 time
+	Timestamps bool
+}
+
+// ParseLogTime parses a log bound the way "docker logs --since" takes it: an RFC 3339 time,
+// or a duration before now like "30m" or "1h30m"
+func ParseLogTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", value)
+	}
+	if d <= 0 {
+		return time.Time{}, errors.New("duration must be positive")
+	}
+	return now.Add(-d), nil
+}
+
+// query returns the Engine API query of the options, asking for both streams
+func (o LogOptions) query() url.Values {
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}, "tail": {o.Tail}}
+	if !o.Since.IsZero() {
+		query.Set("since", logTimestamp(o.Since))
+	}
+	if !o.Until.IsZero() {
+		query.Set("until", logTimestamp(o.Until))
+	}
+	if o.Timestamps {
+		query.Set("timestamps", "1")
+	}
+	return query
+}
+
+// logTimestamp formats t the way the docker CLI passes times to the Engine API
+func logTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// maxLogLine is the longest line kept when a log is read line by line; the rest of a longer
+// line is dropped
+const maxLogLine = 64 << 10
+
+// readLogLine returns the next line of r without its line break, cut to maxLogLine bytes
+func readLogLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line) < maxLogLine {
+			line = append(line, chunk[:min(len(chunk), maxLogLine-len(line))]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			err = nil
+		}
+		return strings.TrimRight(string(line), "\r\n"), err
+	}
+}
+
+// LineFilter reads the lines of a log that a regular expression matches, up to a limit
+type LineFilter struct {
+	r       *bufio.Reader
+	re      *regexp.Regexp
+	max     int
+	matches int
+	pending []byte
+}
+
+// FilterLines returns a reader of the lines of r that re matches, each ending with a line
+// break. It ends after max lines.
+func FilterLines(r io.Reader, re *regexp.Regexp, max int) *LineFilter {
+	return &LineFilter{r: bufio.NewReader(r), re: re, max: max}
+}
+
+func (f *LineFilter) Read(p []byte) (int, error) {
+	for len(f.pending) == 0 {
+		if f.LimitReached() {
+			return 0, io.EOF
+		}
+		line, err := readLogLine(f.r)
+		if err != nil {
+			return 0, err
+		}
+		if f.re.MatchString(line) {
+			f.matches++
+			f.pending = append(f.pending[:0], line...)
+			f.pending = append(f.pending, '\n')
+		}
+	}
+	n := copy(p, f.pending)
+	f.pending = f.pending[n:]
+	return n, nil
+}
+
+// LimitReached tells whether the filter stopped at its limit rather than the end of the log
+func (f *LineFilter) LimitReached() bool {
+	return f.matches >= f.max
+}
+
+// SearchLogs reads the lines of r, numbered from 1, and returns those re matches with up to
+// before and after lines around them. It stops once max lines matched and the lines after
+// the last one are read.
+func SearchLogs(r io.Reader, re *regexp.Regexp, before, after, max int) (model.LogSearchResponse, error) {
+	resp := model.LogSearchResponse{Matches: []model.LogMatch{}}
+	br := bufio.NewReader(r)
+	var recent []model.LogLine
+	// open are the matches still collecting lines after them
+	var open []int
+	for !resp.LimitReached || len(open) > 0 {
+		text, err := readLogLine(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return resp, err
+		}
+		resp.LinesScanned++
+		line := model.LogLine{Number: resp.LinesScanned, Text: text}
+
+		still := open[:0]
+		for _, i := range open {
+			resp.Matches[i].After = append(resp.Matches[i].After, line)
+			if len(resp.Matches[i].After) < after {
+				still = append(still, i)
+			}
+		}
+		open = still
+		if !resp.LimitReached && re.MatchString(text) {
+			resp.Matches = append(resp.Matches, model.LogMatch{LogLine: line, Before: slices.Clone(recent)})
+			if after > 0 {
+				open = append(open, len(resp.Matches)-1)
+			}
+			resp.LimitReached = len(resp.Matches) >= max
+		}
+		if before > 0 {
+			if len(recent) == before {
+				recent = recent[1:]
+			}
+			recent = append(recent, line)
+		}
+	}
+	return resp, nil
+}
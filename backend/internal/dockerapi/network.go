@@ -0,0 +1,22 @@
+package dockerapi
+
+import (
+	"regexp"
+	"slices"
+)
+
+// networkName matches the names docker allows for networks
+var networkName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// BuiltInNetworks are the networks docker creates on every host, which cannot be removed
+var BuiltInNetworks = []string{"bridge", "host", "none"}
+
+// ValidNetworkName reports whether name can be a network's name
+func ValidNetworkName(name string) bool {
+	return len(name) <= 128 && networkName.MatchString(name)
+}
+
+// IsBuiltInNetwork reports whether name is one of BuiltInNetworks
+func IsBuiltInNetwork(name string) bool {
+	return slices.Contains(BuiltInNetworks, name)
+}
@@ -0,0 +1,49 @@
+package dockerapi
+
+import "strings"
+
+// PullProgress is a progress message of an image pull, as the Engine API streams them. ID is
+// the layer the message is about; see IsLayer.
+type PullProgress struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+// ParsePullLine parses a line "docker pull" prints when its output is not a terminal, such as
+// "a2abf6c4d29d: Pull complete". Lines about a layer start with its short ID; other lines, such
+// as the digest and the final status, are about the whole pull. ok is false for blank lines.
+func ParsePullLine(line string) (progress PullProgress, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return progress, false
+	}
+	progress.Status = line
+	if id, status, found := strings.Cut(line, ": "); found && isLayerID(id) {
+		progress.ID, progress.Status = id, status
+	}
+	return progress, true
+}
+
+// IsLayer reports whether the message is about a layer rather than the whole pull. Messages
+// about the whole pull may carry the pulled tag as their ID.
+func (p PullProgress) IsLayer() bool {
+	return isLayerID(p.ID)
+}
+
+// isLayerID reports whether s is a short layer ID, 12 lowercase hex digits
+func isLayerID(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package dockerapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"docker-pulse/internal/model"
+)
+
+// ParseRestartPolicy parses a restart policy written as for docker run --restart, such as
+// "unless-stopped" or "on-failure:5"
+func ParseRestartPolicy(s string) (model.RestartPolicy, error) {
+	name, count, hasCount := strings.Cut(s, ":")
+	policy := model.RestartPolicy{Name: name}
+	switch name {
+	case "no", "always", "unless-stopped":
+		if hasCount {
+			return policy, fmt.Errorf("restart policy %s takes no maximum retry count", name)
+		}
+	case "on-failure":
+		if hasCount {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return policy, fmt.Errorf("invalid maximum retry count %q", count)
+			}
+			policy.MaximumRetryCount = n
+		}
+	default:
+		return policy, fmt.Errorf("unknown restart policy %q", name)
+	}
+	return policy, nil
+}
+
+// RestartPolicyFlag writes a restart policy as docker run --restart takes it
+func RestartPolicyFlag(policy model.RestartPolicy) string {
+	if policy.Name == "on-failure" && policy.MaximumRetryCount > 0 {
+		return fmt.Sprintf("on-failure:%d", policy.MaximumRetryCount)
+	}
+	return policy.Name
+}
+
+// RestartPolicyFromInspect returns the restart policy in what docker inspect prints for a
+// container. Docker leaves the name empty for containers created without a policy, which is
+// returned as "no".
+func RestartPolicyFromInspect(inspect string) (model.RestartPolicy, error) {
+	var c struct {
+		HostConfig struct {
+			RestartPolicy struct {
+				Name              string
+				MaximumRetryCount int
+			}
+		}
+	}
+	if err := decodeInspect(inspect, &c); err != nil {
+		return model.RestartPolicy{}, err
+	}
+	policy := model.RestartPolicy(c.HostConfig.RestartPolicy)
+	if policy.Name == "" {
+		policy.Name = "no"
+	}
+	return policy, nil
+}
@@ -0,0 +1,114 @@
+package dockerapi
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// volumeName matches the names docker allows for volumes
+var volumeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// ValidVolumeName reports whether name can be a volume's name
+func ValidVolumeName(name string) bool {
+	return volumeName.MatchString(name)
+}
+
+// bindOptions are the options a docker -v spec may end with, separated by commas
+var bindOptions = []string{"ro", "rw", "z", "Z", "shared", "rshared", "slave", "rslave", "private", "rprivate", "nocopy", "consistent", "cached", "delegated"}
+
+// CheckBind checks a docker -v spec that names its source: "/host/path:/path" or
+// "volume:/path", optionally followed by options such as ":ro"
+func CheckBind(spec string) error {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("expected source:target[:options], got %q", spec)
+	}
+	if source := parts[0]; !strings.HasPrefix(source, "/") && !volumeName.MatchString(source) {
+		return fmt.Errorf("invalid source %q", source)
+	}
+	if !strings.HasPrefix(parts[1], "/") {
+		return fmt.Errorf("target %q is not an absolute path", parts[1])
+	}
+	if len(parts) == 3 {
+		for _, option := range strings.Split(parts[2], ",") {
+			if !slices.Contains(bindOptions, option) {
+				return fmt.Errorf("unknown option %q", option)
+			}
+		}
+	}
+	return nil
+}
+
+// ParsePortSpecs turns docker -p specs, [ip:][hostPort:]containerPort[/proto] where the ports may
+// be ranges, into RunConfig.Ports. A host range is either as long as the container range, and the
+// ports are paired, or given for a single container port, which is published on any of them.
+func ParsePortSpecs(specs []string) (map[string][]PortBinding, error) {
+	ports := map[string][]PortBinding{}
+	for _, spec := range specs {
+		mapping, proto, _ := strings.Cut(strings.TrimSpace(spec), "/")
+		switch proto {
+		case "":
+			proto = "tcp"
+		case "tcp", "udp", "sctp":
+		default:
+			return nil, fmt.Errorf("unknown protocol %q", proto)
+		}
+		var ip, host string
+		container := mapping
+		if i := strings.LastIndex(mapping, ":"); i >= 0 {
+			container, host = mapping[i+1:], mapping[:i]
+			if j := strings.LastIndex(host, ":"); j >= 0 {
+				ip, host = host[:j], host[j+1:]
+			}
+		}
+		ip = strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+
+		from, to, err := portRange(container)
+		if err != nil {
+			return nil, err
+		}
+		if host == "" {
+			for p := from; p <= to; p++ {
+				key := strconv.Itoa(p) + "/" + proto
+				ports[key] = append(ports[key], PortBinding{HostIP: ip})
+			}
+			continue
+		}
+		hostFrom, hostTo, err := portRange(host)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case hostTo-hostFrom == to-from:
+			for p := from; p <= to; p++ {
+				key := strconv.Itoa(p) + "/" + proto
+				ports[key] = append(ports[key], PortBinding{HostIP: ip, HostPort: strconv.Itoa(hostFrom + p - from)})
+			}
+		case from == to:
+			key := strconv.Itoa(from) + "/" + proto
+			ports[key] = append(ports[key], PortBinding{HostIP: ip, HostPort: host})
+		default:
+			return nil, fmt.Errorf("port ranges %q and %q differ in length", host, container)
+		}
+	}
+	return ports, nil
+}
+
+// portRange parses "8080" or "8000-8010"
+func portRange(s string) (int, int, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	from, err := strconv.Atoi(lo)
+	if err != nil || from < 1 || from > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %q", s)
+	}
+	to := from
+	if isRange {
+		if to, err = strconv.Atoi(hi); err != nil || to < from || to > 65535 {
+			return 0, 0, fmt.Errorf("invalid port range %q", s)
+		}
+	}
+	return from, to, nil
+}
@@ -0,0 +1,78 @@
+package dockerapi
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// ErrNotRunning is returned for operations that need a running container
+var ErrNotRunning = errors.New("container is not running")
+
+// noSuchContainer are what docker reports for containers that do not exist, through the CLI's
+// inspect and the other commands and the Engine API
+var noSuchContainer = []string{"No such container", "No such object"}
+
+// IsNoSuchContainer tells whether err is docker reporting that the container does not exist
+func IsNoSuchContainer(err error) bool {
+	return err != nil && slices.ContainsFunc(noSuchContainer, func(m string) bool { return strings.Contains(err.Error(), m) })
+}
+
+// stateMessages are what docker reports refusing an operation for the state of a container, or
+// a name already taken by another container
+var stateMessages = []string{"is not running", "is already paused", "is not paused", "is paused", "is already in use"}
+
+// KillSignals are the signals the kill action sends, SIGKILL by default
+var KillSignals = []string{"SIGKILL", "SIGTERM", "SIGINT", "SIGQUIT", "SIGHUP", "SIGUSR1", "SIGUSR2"}
+
+// StateError is docker refusing an operation for the state of a container, such as killing a
+// container that is not running or renaming it to a name in use. Message is docker's.
+type StateError struct {
+	Message string
+}
+
+func (e *StateError) Error() string { return e.Message }
+
+// Is lets errors.Is match ErrNotRunning for containers that do not run
+func (e *StateError) Is(target error) bool {
+	return target == ErrNotRunning && strings.Contains(e.Message, "is not running")
+}
+
+// StateConflict returns a *StateError for the errors docker reports for the state of a container,
+// and err otherwise
+func StateConflict(err error) error {
+	if msg, ok := DaemonMessage(err); ok && slices.ContainsFunc(stateMessages, func(m string) bool { return strings.Contains(msg, m) }) {
+		return &StateError{Message: msg}
+	}
+	return err
+}
+
+// DaemonMessage returns the message of an error the docker daemon answered with, read from the
+// CLI's "Error response from daemon:" line or the Engine API's message
+func DaemonMessage(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if _, msg, ok := strings.Cut(line, "Error response from daemon: "); ok {
+			return strings.TrimSpace(msg), true
+		}
+		if parts := strings.SplitN(line, ": ", 3); strings.HasPrefix(line, "docker engine API ") && len(parts) == 3 {
+			return strings.TrimSpace(parts[2]), true
+		}
+	}
+	return "", false
+}
+
+// ParseSignal returns the KillSignals name of signal, which may be given without the "SIG"
+// prefix and in any case, e.g. "hup". An empty signal is SIGKILL.
+func ParseSignal(signal string) (string, bool) {
+	if signal == "" {
+		return KillSignals[0], true
+	}
+	signal = strings.ToUpper(strings.TrimSpace(signal))
+	if !strings.HasPrefix(signal, "SIG") {
+		signal = "SIG" + signal
+	}
+	return signal, slices.Contains(KillSignals, signal)
+}
@@ -0,0 +1,198 @@
+package dockerapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Usage is the resource usage of a running container. NetIO and BlockIO are the received and
+// sent, read and written totals as docker stats prints them, e.g. "1.2kB / 648B".
+type Usage struct {
+	CPUPercent float64
+	MemUsage   int64
+	MemLimit   int64
+	NetIO      string
+	BlockIO    string
+}
+
+// statsEntry is a container as printed by "docker stats --format '{{json .}}'"
+type statsEntry struct {
+	ID       string `json:"ID"`
+	CPUPerc  string `json:"CPUPerc"`  // e.g. "0.25%"
+	MemUsage string `json:"MemUsage"` // e.g. "12.5MiB / 1.94GiB"
+	NetIO    string `json:"NetIO"`    // e.g. "1.2kB / 648B"
+	BlockIO  string `json:"BlockIO"`
+}
+
+// byteUnits are the unit suffixes docker prints sizes with: binary ones for memory, decimal ones
+// for network and block I/O and for disk usage
+var byteUnits = map[string]float64{
+	"B":   1,
+	"kB":  1e3,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"PB":  1e15,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// decimalUnits are the units docker prints network and block I/O in, each 1000 times the last
+var decimalUnits = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB"}
+
+// ParseStats parses the output of "docker stats --no-stream" in the JSON or the legacy
+// "ID|CPUPerc|MemUsage|NetIO|BlockIO" format, keyed by short container ID. Lines in neither
+// format are skipped.
+func ParseStats(output string) map[string]Usage {
+	usage := map[string]Usage{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var entry statsEntry
+		if strings.HasPrefix(line, "{") {
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				continue
+			}
+		} else {
+			parts := strings.Split(line, "|")
+			if len(parts) != 5 {
+				continue
+			}
+			entry = statsEntry{ID: parts[0], CPUPerc: parts[1], MemUsage: parts[2], NetIO: parts[3], BlockIO: parts[4]}
+		}
+		if entry.ID != "" {
+			usage[ShortID(entry.ID)] = entry.usage()
+		}
+	}
+	return usage
+}
+
+func (e statsEntry) usage() Usage {
+	cpu, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(e.CPUPerc), "%"), 64)
+	mem, limit, _ := strings.Cut(e.MemUsage, "/")
+	return Usage{
+		CPUPercent: cpu,
+		MemUsage:   ParseByteSize(mem),
+		MemLimit:   ParseByteSize(limit),
+		NetIO:      statsText(e.NetIO),
+		BlockIO:    statsText(e.BlockIO),
+	}
+}
+
+// statsText drops the "--" docker prints for values of containers that stopped while being read
+func statsText(value string) string {
+	if value = strings.TrimSpace(value); value == "--" || value == "-- / --" {
+		return ""
+	}
+	return value
+}
+
+// ParseByteSize parses a size as docker prints it, e.g. "1.94GiB" or "648B". Unparsable sizes,
+// such as the "--" of containers that stopped while being read, are 0.
+func ParseByteSize(size string) int64 {
+	size = strings.TrimSpace(size)
+	i := strings.IndexFunc(size, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i <= 0 {
+		return 0
+	}
+	n, err := strconv.ParseFloat(size[:i], 64)
+	unit, ok := byteUnits[strings.TrimSpace(size[i:])]
+	if err != nil || !ok {
+		return 0
+	}
+	return int64(n * unit)
+}
+
+// engineStats is a reading of GET /containers/{id}/stats
+type engineStats struct {
+	CPUStats    engineCPUStats `json:"cpu_stats"`
+	PreCPUStats engineCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IoServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+}
+
+type engineCPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// usage computes the values docker stats prints from a reading, the way the docker CLI does
+func (st engineStats) usage() Usage {
+	var u Usage
+	cpuDelta := float64(st.CPUStats.CPUUsage.TotalUsage) - float64(st.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(st.CPUStats.SystemUsage) - float64(st.PreCPUStats.SystemUsage)
+	cpus := float64(st.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(st.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		u.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	// The page cache is not counted: total_inactive_file on cgroup v1, inactive_file on v2
+	mem := st.MemoryStats.Usage
+	if v, ok := st.MemoryStats.Stats["total_inactive_file"]; ok && v < mem {
+		mem -= v
+	} else if v, ok := st.MemoryStats.Stats["inactive_file"]; ok && v < mem {
+		mem -= v
+	}
+	u.MemUsage, u.MemLimit = int64(mem), int64(st.MemoryStats.Limit)
+
+	var rx, tx, read, write uint64
+	for _, n := range st.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	for _, entry := range st.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+	u.NetIO = HumanSize(rx) + " / " + HumanSize(tx)
+	u.BlockIO = HumanSize(read) + " / " + HumanSize(write)
+	return u
+}
+
+// HumanSize formats a size with three significant digits in decimal units, e.g. "1.2kB"
+func HumanSize(n uint64) string {
+	size, unit := float64(n), 0
+	for size >= 1000 && unit < len(decimalUnits)-1 {
+		size /= 1000
+		unit++
+	}
+	return fmt.Sprintf("%.3g%s", size, decimalUnits[unit])
+}
+
+// Sample is a reading of a container's usage and when it was taken
+type Sample struct {
+	Time time.Time
+	Usage
+}
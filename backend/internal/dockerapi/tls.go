@@ -0,0 +1,78 @@
+package dockerapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrTLSBundle is returned for bundles without a usable client certificate, key and CA
+var ErrTLSBundle = errors.New("invalid TLS bundle")
+
+// dialTimeout bounds connecting to an engine listening on TCP
+const dialTimeout = 10 * time.Second
+
+// ParseTLSBundle parses the PEM bundle of an engine using mutual TLS: the client certificate,
+// its private key and the certificates of the CAs the engine's certificate is verified
+// against, in any order. The certificate matching the key is the client's, all others are CAs.
+func ParseTLSBundle(bundle string) (*tls.Config, error) {
+	var certs, keys [][]byte
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			certs = append(certs, pem.EncodeToMemory(block))
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			keys = append(keys, pem.EncodeToMemory(block))
+		}
+	}
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("%w: expected one private key, found %d", ErrTLSBundle, len(keys))
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: x509.NewCertPool()}
+	for _, cert := range certs {
+		if len(config.Certificates) == 0 {
+			if pair, err := tls.X509KeyPair(cert, keys[0]); err == nil {
+				config.Certificates = []tls.Certificate{pair}
+				continue
+			}
+		}
+		if !config.RootCAs.AppendCertsFromPEM(cert) {
+			return nil, fmt.Errorf("%w: unparsable certificate", ErrTLSBundle)
+		}
+	}
+	switch {
+	case len(config.Certificates) == 0:
+		return nil, fmt.Errorf("%w: no certificate matches the private key", ErrTLSBundle)
+	case len(certs) == 1:
+		return nil, fmt.Errorf("%w: no CA certificate", ErrTLSBundle)
+	}
+	return config, nil
+}
+
+// NewTLS returns a client for an engine listening on addr, e.g. "10.0.0.5:2376", with mutual
+// TLS configured from bundle as ParseTLSBundle reads it
+func NewTLS(addr, bundle string, serverID uint) (*Client, error) {
+	config, err := ParseTLSBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: dialTimeout}).DialContext,
+		TLSClientConfig:     config,
+		TLSHandshakeTimeout: dialTimeout,
+		DisableKeepAlives:   true,
+	}
+	return New(transport, "https://"+addr, serverID), nil
+}
@@ -0,0 +1,59 @@
+package dockerapi
+
+import (
+	"strconv"
+	"strings"
+
+	"docker-pulse/internal/model"
+)
+
+// TopArgs are the ps arguments docker top is tried with, in order. BusyBox ps has neither -e
+// nor the CPU and memory columns; the last, empty, arguments are docker's default "-ef".
+var TopArgs = []string{"-eo pid,ppid,user,%cpu,%mem,etime,cmd", "-o pid,ppid,user,etime,args", ""}
+
+// Top is the process list docker top reports: the ps column titles and a row of values per process
+type Top struct {
+	Titles    []string   `json:"Titles"`
+	Processes [][]string `json:"Processes"`
+}
+
+// ContainerProcesses converts the rows by their column titles, which differ between the ps
+// arguments: "USER" or "UID", "ELAPSED" or "ETIME", "CMD", "COMMAND" or "ARGS"
+func (t Top) ContainerProcesses() []model.ContainerProcess {
+	processes := []model.ContainerProcess{}
+	for _, row := range t.Processes {
+		var p model.ContainerProcess
+		for i, title := range t.Titles {
+			if i >= len(row) {
+				break
+			}
+			value := strings.TrimSpace(row[i])
+			switch strings.ToUpper(title) {
+			case "PID":
+				p.PID, _ = strconv.Atoi(value)
+			case "PPID":
+				p.PPID, _ = strconv.Atoi(value)
+			case "USER", "UID":
+				p.User = value
+			case "%CPU":
+				p.CPUPercent = parsePercent(value)
+			case "%MEM":
+				p.MemPercent = parsePercent(value)
+			case "ELAPSED", "ETIME":
+				p.Elapsed = value
+			case "CMD", "COMMAND", "ARGS":
+				p.Command = value
+			}
+		}
+		processes = append(processes, p)
+	}
+	return processes
+}
+
+func parsePercent(value string) *float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,676 @@
+package dockerapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// updateTimeout is how long the replacement of an updated container has to report running,
+	// and healthy when its image has a health check
+	updateTimeout = 60 * time.Second
+	// updateSettle is how long the replacement must keep running to count as started
+	updateSettle = 5 * time.Second
+	// rollbackTimeout bounds restoring the old container, which goes on when the update's
+	// context is done
+	rollbackTimeout = time.Minute
+)
+
+// swarmServiceLabel is set on the containers of swarm service tasks
+const swarmServiceLabel = "com.docker.swarm.service.id"
+
+// RunConfig is the configuration a container was run with, as far as recreating it carries it
+// over. Settings the container took from its image are left out, so that its replacement takes
+// them from the new image.
+type RunConfig struct {
+	Name       string
+	Image      string
+	Hostname   string
+	User       string
+	WorkingDir string
+	Env        []string
+	// Entrypoint and Cmd are nil when the image's are used. With an Entrypoint the image's
+	// command is not used either, as with docker run --entrypoint.
+	Entrypoint []string
+	Cmd        []string
+	Labels     map[string]string
+	Tty        bool
+	OpenStdin  bool
+	// Binds are docker -v specs, "/host/path:/path" or "volume:/path" with ":ro" for read-only
+	// mounts
+	Binds []string
+	// Tmpfs maps paths to the options of the tmpfs mounted there
+	Tmpfs map[string]string
+	// Ports maps container ports such as "80/tcp" to their host bindings
+	Ports         map[string][]PortBinding
+	RestartPolicy string
+	MaxRetries    int
+	NetworkMode   string
+	// Networks maps the networks the container is attached to to its aliases on them. The
+	// network of NetworkMode is attached when the container is created, the others after.
+	Networks   map[string][]string
+	Privileged bool
+	CapAdd     []string
+	CapDrop    []string
+	Devices    []Device
+	ExtraHosts []string
+	Memory     int64
+	NanoCPUs   int64
+	// LogDriver and LogOptions are the container's log driver and its --log-opt settings
+	LogDriver  string
+	LogOptions map[string]string
+}
+
+// PortBinding is a host address a container port is published on. An empty HostPort picks a
+// free port.
+type PortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// Device is a host device added to a container
+type Device struct {
+	PathOnHost        string `json:"PathOnHost"`
+	PathInContainer   string `json:"PathInContainer"`
+	CgroupPermissions string `json:"CgroupPermissions"`
+}
+
+// containerInspect is what recreating a container reads from "docker inspect"
+type containerInspect struct {
+	ID           string `json:"Id"`
+	Name         string
+	Image        string
+	RestartCount int
+	State        struct {
+		Running    bool
+		Restarting bool
+		ExitCode   int
+		Error      string
+		Health     *struct {
+			Status string
+		}
+	}
+	Config struct {
+		Hostname   string
+		User       string
+		WorkingDir string
+		Image      string
+		Env        []string
+		Cmd        []string
+		Entrypoint []string
+		Labels     map[string]string
+		Tty        bool
+		OpenStdin  bool
+	}
+	HostConfig struct {
+		PortBindings  map[string][]PortBinding
+		RestartPolicy struct {
+			Name              string
+			MaximumRetryCount int
+		}
+		NetworkMode string
+		Privileged  bool
+		CapAdd      []string
+		CapDrop     []string
+		Devices     []Device
+		ExtraHosts  []string
+		Tmpfs       map[string]string
+		Memory      int64
+		NanoCpus    int64
+		AutoRemove  bool
+		LogConfig   struct {
+			Type   string
+			Config map[string]string
+		}
+	}
+	Mounts []struct {
+		Type        string
+		Name        string
+		Source      string
+		Destination string
+		RW          bool
+	}
+	NetworkSettings struct {
+		Networks map[string]struct {
+			Aliases []string
+		}
+	}
+}
+
+// imageInspect is what recreating a container reads from "docker image inspect"
+type imageInspect struct {
+	ID           string `json:"Id"`
+	RepoDigests  []string
+	Os           string
+	Architecture string
+	Variant      string
+	Config       struct {
+		Env        []string
+		Cmd        []string
+		Entrypoint []string
+		Labels     map[string]string
+	}
+}
+
+// UpdateContainer pulls the image a container was created from and replaces the container with
+// one created from the new image with the same configuration. The old container is stopped and
+// renamed out of the way, and only removed once the replacement has kept running, or turned
+// healthy, within a timeout; otherwise the replacement is removed and the old container is
+// restored. Containers that were not running are replaced without being started, and containers
+// that already run the pulled image are left alone. Progress of
+// the pull and the steps that follow are passed to progress when it is not nil. It returns the
+// ID of the replacement.
+func UpdateContainer(ctx context.Context, b Backend, containerID string, progress func(PullProgress)) (string, error) {
+	report := func(status string) {
+		if progress != nil {
+			progress(PullProgress{Status: status})
+		}
+	}
+	old, err := inspectContainer(ctx, b, containerID)
+	if err != nil {
+		return "", err
+	}
+	if err := recreatable(old); err != nil {
+		return "", err
+	}
+	image, err := inspectImage(ctx, b, old.Image)
+	if err != nil {
+		return "", err
+	}
+	cfg := runConfig(old, image)
+
+	if err := b.PullImage(ctx, old.ID, progress); err != nil {
+		return "", fmt.Errorf("pulling %s failed: %w", cfg.Image, err)
+	}
+	pulled, err := inspectImage(ctx, b, cfg.Image)
+	if err != nil {
+		return "", err
+	}
+	if pulled.ID == old.Image {
+		report(cfg.Name + " already runs the latest " + cfg.Image)
+		return old.ID, nil
+	}
+	return replaceContainer(ctx, b, old, cfg, report)
+}
+
+// LogRotation is how a json-file or local log driver rotates a container's log: the size of a
+// log file, like "10m", and the number of files kept. Empty values keep the driver's defaults.
+type LogRotation struct {
+	MaxSize string
+	MaxFile int
+}
+
+// SetLogRotation replaces a container with one that rotates its log as rot says, since docker
+// only sets log options when a container is created. The container is replaced, and rolled
+// back on failure, as UpdateContainer replaces it, but from the image it runs. The log written
+// so far is removed with the old container. It returns the ID of the replacement.
+func SetLogRotation(ctx context.Context, b Backend, containerID string, rot LogRotation, progress func(PullProgress)) (string, error) {
+	report := func(status string) {
+		if progress != nil {
+			progress(PullProgress{Status: status})
+		}
+	}
+	old, err := inspectContainer(ctx, b, containerID)
+	if err != nil {
+		return "", err
+	}
+	if err := recreatable(old); err != nil {
+		return "", err
+	}
+	if driver := old.HostConfig.LogConfig.Type; driver != "json-file" && driver != "local" {
+		return "", fmt.Errorf("the %s log driver keeps no log files on the server to rotate", driver)
+	}
+	image, err := inspectImage(ctx, b, old.Image)
+	if err != nil {
+		return "", err
+	}
+	cfg := runConfig(old, image)
+	// The replacement is created from the image's reference, which must still name the image
+	// the container runs, or this would update it as well
+	tagged, err := inspectImage(ctx, b, cfg.Image)
+	if err != nil {
+		return "", err
+	}
+	if tagged.ID != old.Image {
+		return "", fmt.Errorf("%s names a newer image than %s runs, update the container instead", cfg.Image, cfg.Name)
+	}
+
+	options := map[string]string{}
+	for k, v := range cfg.LogOptions {
+		if k != "max-size" && k != "max-file" {
+			options[k] = v
+		}
+	}
+	if rot.MaxSize != "" {
+		options["max-size"] = rot.MaxSize
+	}
+	if rot.MaxFile > 0 {
+		options["max-file"] = strconv.Itoa(rot.MaxFile)
+	}
+	cfg.LogOptions = options
+	return replaceContainer(ctx, b, old, cfg, report)
+}
+
+// recreatable refuses containers that cannot be replaced by a container created like them
+func recreatable(ct containerInspect) error {
+	switch {
+	case ct.HostConfig.AutoRemove:
+		return errors.New("containers run with --rm are removed when they stop and cannot be recreated")
+	case ct.Config.Labels[swarmServiceLabel] != "":
+		return errors.New("the container is a swarm service task, change its service instead")
+	}
+	return nil
+}
+
+// replaceContainer replaces old with a container created from cfg. The old container is stopped
+// and renamed out of the way, and only removed once the replacement has kept running, or turned
+// healthy, within a timeout; otherwise the replacement is removed and the old container is
+// restored. The replacement is only started when the old container was running. It returns the
+// ID of the replacement.
+func replaceContainer(ctx context.Context, b Backend, old containerInspect, cfg RunConfig, report func(string)) (string, error) {
+	running := old.State.Running
+	if running {
+		report("Stopping " + cfg.Name)
+		if err := b.ContainerAction(ctx, old.ID, "stop"); err != nil {
+			return "", err
+		}
+	}
+	backup := fmt.Sprintf("%s_old_%d", cfg.Name, time.Now().Unix())
+	report("Renaming " + cfg.Name + " to " + backup)
+	if err := b.RenameContainer(ctx, old.ID, backup); err != nil {
+		if running {
+			err = errors.Join(err, b.ContainerAction(context.WithoutCancel(ctx), old.ID, "start"))
+		}
+		return "", err
+	}
+
+	rollback := func(newID string, cause error) error {
+		report("Rolling back: " + cause.Error())
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+		defer cancel()
+		var errs []error
+		if newID != "" {
+			errs = append(errs, b.ContainerAction(ctx, newID, "remove"))
+		}
+		errs = append(errs, b.RenameContainer(ctx, old.ID, cfg.Name))
+		if running {
+			errs = append(errs, b.ContainerAction(ctx, old.ID, "start"))
+		}
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("%w; restoring the old container %s failed: %v", cause, backup, err)
+		}
+		return fmt.Errorf("%w; the old container was restored", cause)
+	}
+
+	report("Creating " + cfg.Name + " from " + cfg.Image)
+	newID, err := b.CreateContainer(ctx, cfg)
+	if err != nil {
+		return "", rollback("", fmt.Errorf("creating the new container failed: %w", err))
+	}
+	if running {
+		report("Starting " + cfg.Name)
+		if err := b.ContainerAction(ctx, newID, "start"); err != nil {
+			return "", rollback(newID, fmt.Errorf("starting the new container failed: %w", err))
+		}
+		if err := waitRunning(ctx, b, newID); err != nil {
+			return "", rollback(newID, err)
+		}
+	}
+
+	report("Removing " + backup)
+	if err := b.ContainerAction(ctx, old.ID, "remove"); err != nil {
+		return newID, fmt.Errorf("the container was updated, but removing the old container %s failed: %w", backup, err)
+	}
+	return newID, nil
+}
+
+// waitRunning waits until a started container has kept running for updateSettle without
+// restarting, and turned healthy when it has a health check
+func waitRunning(ctx context.Context, b Backend, containerID string) error {
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+	var since time.Time
+	for {
+		ct, err := inspectContainer(ctx, b, containerID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("the new container did not report running within %s", updateTimeout)
+			}
+			return err
+		}
+		switch {
+		case ct.RestartCount > 0 || ct.State.Restarting:
+			return fmt.Errorf("the new container keeps restarting, last exit code %d", ct.State.ExitCode)
+		case !ct.State.Running:
+			if ct.State.Error != "" {
+				return fmt.Errorf("the new container stopped: %s", ct.State.Error)
+			}
+			return fmt.Errorf("the new container exited with code %d", ct.State.ExitCode)
+		case ct.State.Health != nil && ct.State.Health.Status == "unhealthy":
+			return errors.New("the new container is unhealthy")
+		}
+		if since.IsZero() {
+			since = time.Now()
+		}
+		healthy := ct.State.Health == nil || ct.State.Health.Status == "healthy"
+		if healthy && time.Since(since) >= updateSettle {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the new container did not report running within %s", updateTimeout)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func inspectContainer(ctx context.Context, b Backend, containerID string) (containerInspect, error) {
+	var ct containerInspect
+	out, err := b.InspectContainer(ctx, containerID)
+	if err != nil {
+		return ct, err
+	}
+	return ct, decodeInspect(out, &ct)
+}
+
+func inspectImage(ctx context.Context, b Backend, image string) (imageInspect, error) {
+	var img imageInspect
+	out, err := b.InspectImage(ctx, image)
+	if err != nil {
+		return img, err
+	}
+	return img, decodeInspect(out, &img)
+}
+
+// ImageReference is the image a container was created from, and the repo digests and platform
+// of the image it runs
+type ImageReference struct {
+	// Container is the container's name
+	Container string
+	// Image is the reference the container was created from, e.g. "postgres:15"
+	Image string
+	// RepoDigests are the repo digests of the image the container runs, e.g. "postgres@sha256:..."
+	RepoDigests []string
+	// Platform is the platform of the image the container runs, e.g. "linux/arm64/v8"
+	Platform string
+}
+
+// ContainerImage returns the image reference a container was created from and the repo digests
+// and platform of the image it runs
+func ContainerImage(ctx context.Context, b Backend, containerID string) (ImageReference, error) {
+	ct, err := inspectContainer(ctx, b, containerID)
+	if err != nil {
+		return ImageReference{}, err
+	}
+	img, err := inspectImage(ctx, b, ct.Image)
+	if err != nil {
+		return ImageReference{}, err
+	}
+	platform := img.Os + "/" + img.Architecture
+	if img.Variant != "" {
+		platform += "/" + img.Variant
+	}
+	return ImageReference{Container: strings.TrimPrefix(ct.Name, "/"), Image: ct.Config.Image, RepoDigests: img.RepoDigests, Platform: platform}, nil
+}
+
+// decodeInspect decodes the single object in what docker inspect prints
+func decodeInspect(output string, v any) error {
+	var list []json.RawMessage
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return fmt.Errorf("unexpected inspect output: %w", err)
+	}
+	if len(list) != 1 {
+		return fmt.Errorf("unexpected inspect output: %d objects", len(list))
+	}
+	if err := json.Unmarshal(list[0], v); err != nil {
+		return fmt.Errorf("unexpected inspect output: %w", err)
+	}
+	return nil
+}
+
+// runConfig returns the configuration of a container without what it took from its image
+func runConfig(ct containerInspect, image imageInspect) RunConfig {
+	cfg := RunConfig{
+		Name:          strings.TrimPrefix(ct.Name, "/"),
+		Image:         ct.Config.Image,
+		User:          ct.Config.User,
+		WorkingDir:    ct.Config.WorkingDir,
+		Labels:        map[string]string{},
+		Tty:           ct.Config.Tty,
+		OpenStdin:     ct.Config.OpenStdin,
+		Tmpfs:         ct.HostConfig.Tmpfs,
+		Ports:         ct.HostConfig.PortBindings,
+		MaxRetries:    ct.HostConfig.RestartPolicy.MaximumRetryCount,
+		NetworkMode:   ct.HostConfig.NetworkMode,
+		Networks:      map[string][]string{},
+		Privileged:    ct.HostConfig.Privileged,
+		CapAdd:        ct.HostConfig.CapAdd,
+		CapDrop:       ct.HostConfig.CapDrop,
+		Devices:       ct.HostConfig.Devices,
+		ExtraHosts:    ct.HostConfig.ExtraHosts,
+		Memory:        ct.HostConfig.Memory,
+		NanoCPUs:      ct.HostConfig.NanoCpus,
+		RestartPolicy: ct.HostConfig.RestartPolicy.Name,
+		LogDriver:     ct.HostConfig.LogConfig.Type,
+		LogOptions:    ct.HostConfig.LogConfig.Config,
+	}
+	if cfg.RestartPolicy == "no" {
+		cfg.RestartPolicy = ""
+	}
+	// Containers sharing the host's or another container's namespaces have its hostname, the
+	// others a generated one unless it was set
+	ownNetwork := !sharesNetwork(cfg.NetworkMode)
+	if ownNetwork && ct.Config.Hostname != ShortID(ct.ID) {
+		cfg.Hostname = ct.Config.Hostname
+	}
+
+	for _, env := range ct.Config.Env {
+		if !slices.Contains(image.Config.Env, env) {
+			cfg.Env = append(cfg.Env, env)
+		}
+	}
+	if !slices.Equal(ct.Config.Entrypoint, image.Config.Entrypoint) {
+		cfg.Entrypoint = ct.Config.Entrypoint
+		if cfg.Entrypoint == nil {
+			cfg.Entrypoint = []string{""}
+		}
+	}
+	if cfg.Entrypoint != nil || !slices.Equal(ct.Config.Cmd, image.Config.Cmd) {
+		cfg.Cmd = ct.Config.Cmd
+	}
+	for k, v := range ct.Config.Labels {
+		if value, ok := image.Config.Labels[k]; !ok || value != v {
+			cfg.Labels[k] = v
+		}
+	}
+
+	for _, m := range ct.Mounts {
+		var bind string
+		switch m.Type {
+		case "bind":
+			bind = m.Source + ":" + m.Destination
+		case "volume":
+			// Anonymous volumes are kept too, so the replacement has the data of the old container
+			bind = m.Name + ":" + m.Destination
+		default:
+			continue
+		}
+		if !m.RW {
+			bind += ":ro"
+		}
+		cfg.Binds = append(cfg.Binds, bind)
+	}
+
+	if ownNetwork {
+		for name, endpoint := range ct.NetworkSettings.Networks {
+			// Docker adds the container's short ID as an alias on user-defined networks
+			aliases := []string{}
+			for _, alias := range endpoint.Aliases {
+				if alias != ShortID(ct.ID) && alias != cfg.Name {
+					aliases = append(aliases, alias)
+				}
+			}
+			cfg.Networks[name] = aliases
+		}
+	}
+	return cfg
+}
+
+// sharesNetwork reports whether a network mode shares the network namespace of the host or of
+// another container
+func sharesNetwork(mode string) bool {
+	return mode == "host" || mode == "none" || strings.HasPrefix(mode, "container:")
+}
+
+// primaryNetwork returns the network the container is attached to when it is created, empty
+// when it has no network of its own
+func (cfg RunConfig) primaryNetwork() string {
+	switch {
+	case sharesNetwork(cfg.NetworkMode):
+		return ""
+	case cfg.NetworkMode == "" || cfg.NetworkMode == "default":
+		return "bridge"
+	}
+	return cfg.NetworkMode
+}
+
+// Aliases returns the container's aliases on a network. The default bridge network has none,
+// docker only allows them on user-defined networks.
+func (cfg RunConfig) Aliases(network string) []string {
+	if network == "" || network == "bridge" {
+		return nil
+	}
+	return cfg.Networks[network]
+}
+
+// ExtraNetworks returns the networks that are attached after the container was created, sorted
+func (cfg RunConfig) ExtraNetworks() []string {
+	var names []string
+	for name := range cfg.Networks {
+		if name != cfg.primaryNetwork() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CreateArgs returns the arguments of "docker create" that creates a container from cfg
+func (cfg RunConfig) CreateArgs() []string {
+	args := []string{"--name", cfg.Name}
+	add := func(flag string, values ...string) {
+		for _, v := range values {
+			args = append(args, flag, v)
+		}
+	}
+	if cfg.Hostname != "" {
+		add("--hostname", cfg.Hostname)
+	}
+	if cfg.User != "" {
+		add("--user", cfg.User)
+	}
+	if cfg.WorkingDir != "" {
+		add("--workdir", cfg.WorkingDir)
+	}
+	add("-e", cfg.Env...)
+	if cfg.Entrypoint != nil {
+		add("--entrypoint", cfg.Entrypoint[0])
+	}
+	for _, k := range sortedKeys(cfg.Labels) {
+		add("--label", k+"="+cfg.Labels[k])
+	}
+	if cfg.Tty {
+		args = append(args, "-t")
+	}
+	if cfg.OpenStdin {
+		args = append(args, "-i")
+	}
+	add("-v", cfg.Binds...)
+	for _, path := range sortedKeys(cfg.Tmpfs) {
+		if options := cfg.Tmpfs[path]; options != "" {
+			path += ":" + options
+		}
+		add("--tmpfs", path)
+	}
+	add("-p", cfg.portSpecs()...)
+	if cfg.RestartPolicy != "" {
+		policy := cfg.RestartPolicy
+		if policy == "on-failure" && cfg.MaxRetries > 0 {
+			policy += ":" + strconv.Itoa(cfg.MaxRetries)
+		}
+		add("--restart", policy)
+	}
+	if cfg.NetworkMode != "" && cfg.NetworkMode != "default" {
+		add("--network", cfg.NetworkMode)
+	}
+	add("--network-alias", cfg.Aliases(cfg.primaryNetwork())...)
+	if cfg.Privileged {
+		args = append(args, "--privileged")
+	}
+	add("--cap-add", cfg.CapAdd...)
+	add("--cap-drop", cfg.CapDrop...)
+	for _, d := range cfg.Devices {
+		device := d.PathOnHost + ":" + d.PathInContainer
+		if d.CgroupPermissions != "" {
+			device += ":" + d.CgroupPermissions
+		}
+		add("--device", device)
+	}
+	add("--add-host", cfg.ExtraHosts...)
+	if cfg.Memory > 0 {
+		add("--memory", strconv.FormatInt(cfg.Memory, 10))
+	}
+	if cfg.NanoCPUs > 0 {
+		add("--cpus", strconv.FormatFloat(float64(cfg.NanoCPUs)/1e9, 'f', -1, 64))
+	}
+	if cfg.LogDriver != "" {
+		add("--log-driver", cfg.LogDriver)
+	}
+	for _, k := range sortedKeys(cfg.LogOptions) {
+		add("--log-opt", k+"="+cfg.LogOptions[k])
+	}
+	args = append(args, cfg.Image)
+	if len(cfg.Entrypoint) > 1 {
+		args = append(args, cfg.Entrypoint[1:]...)
+	}
+	return append(args, cfg.Cmd...)
+}
+
+// portSpecs returns the container's published ports as docker -p specs, sorted
+func (cfg RunConfig) portSpecs() []string {
+	var specs []string
+	for port, bindings := range cfg.Ports {
+		for _, b := range bindings {
+			ip := b.HostIP
+			if strings.Contains(ip, ":") {
+				ip = "[" + ip + "]"
+			}
+			switch {
+			case ip != "":
+				specs = append(specs, ip+":"+b.HostPort+":"+port)
+			case b.HostPort != "":
+				specs = append(specs, b.HostPort+":"+port)
+			default:
+				specs = append(specs, port)
+			}
+		}
+	}
+	sort.Strings(specs)
+	return specs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,30 @@
+package dockerapi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRecreatingKeepsTheLogConfig(t *testing.T) {
+	var ct containerInspect
+	err := decodeInspect(`[{"Id":"0123456789abcdef","Name":"/web","Image":"sha256:aaa",
+		"Config":{"Hostname":"0123456789ab","Image":"nginx:1.25"},
+		"HostConfig":{"NetworkMode":"default","LogConfig":{"Type":"json-file","Config":{"max-size":"10m","max-file":"3"}}}}]`, &ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := runConfig(ct, imageInspect{ID: "sha256:aaa"})
+	args := cfg.CreateArgs()
+	for _, want := range [][]string{{"--log-driver", "json-file"}, {"--log-opt", "max-file=3"}, {"--log-opt", "max-size=10m"}} {
+		i := slices.Index(args, want[1])
+		if i < 1 || args[i-1] != want[0] {
+			t.Errorf("docker create %q does not pass %s %s", args, want[0], want[1])
+		}
+	}
+
+	body := createBody(cfg)["HostConfig"].(map[string]any)
+	logConfig, ok := body["LogConfig"].(map[string]any)
+	if !ok || logConfig["Type"] != "json-file" || logConfig["Config"].(map[string]string)["max-size"] != "10m" {
+		t.Errorf("create body LogConfig = %v, want json-file with max-size 10m", body["LogConfig"])
+	}
+}
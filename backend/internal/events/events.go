@@ -0,0 +1,175 @@
+// Package events follows the container events of servers while clients are subscribed to
+// them. A server's events are read from a single "docker events" stream, started with the first
+// subscription and stopped when the last one is closed.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+)
+
+const (
+	// minBackoff and maxBackoff bound the wait before following a server's events again after
+	// the stream broke. The wait doubles with each attempt and starts over once a stream stayed
+	// up for maxBackoff.
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+	// subscriberBuffer is how far a subscriber may fall behind before events are dropped for it
+	subscriberBuffer = 64
+)
+
+var (
+	mu    sync.Mutex
+	feeds = map[uint]*feed{}
+	// onEvent is called with the server's ID for every event and after reconnecting
+	onEvent func(serverID uint)
+)
+
+// feed follows the events of a server for its subscribers
+type feed struct {
+	server model.Server
+	cancel context.CancelFunc
+	subs   map[*Subscription]struct{}
+}
+
+// Subscription receives the events of a server on C until it is closed
+type Subscription struct {
+	C    <-chan model.ContainerEvent
+	ch   chan model.ContainerEvent
+	feed *feed
+	once sync.Once
+}
+
+// OnEvent sets a function called with the server ID for every event, before the event is
+// passed on, and after the stream reconnected, since events may have been missed meanwhile
+func OnEvent(fn func(serverID uint)) {
+	mu.Lock()
+	defer mu.Unlock()
+	onEvent = fn
+}
+
+// Subscribe receives the server's container events, following them if nobody did yet. The
+// subscription must be closed.
+func Subscribe(server model.Server) *Subscription {
+	mu.Lock()
+	defer mu.Unlock()
+	f, ok := feeds[server.ID]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		f = &feed{server: server, cancel: cancel, subs: map[*Subscription]struct{}{}}
+		feeds[server.ID] = f
+		go f.run(ctx)
+	}
+	ch := make(chan model.ContainerEvent, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, feed: f}
+	f.subs[sub] = struct{}{}
+	return sub
+}
+
+// Close stops the subscription and closes C. The server's events stop being followed when
+// this was its last subscription.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(s.feed.subs, s)
+		close(s.ch)
+		if len(s.feed.subs) == 0 {
+			s.feed.cancel()
+			if feeds[s.feed.server.ID] == s.feed {
+				delete(feeds, s.feed.server.ID)
+			}
+		}
+	})
+}
+
+// run follows the events until ctx is cancelled, reconnecting with backoff when the stream
+// breaks
+func (f *feed) run(ctx context.Context) {
+	backoff := minBackoff
+	for attempt := 0; ; attempt++ {
+		started := time.Now()
+		err := f.follow(ctx, attempt > 0)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= maxBackoff {
+			backoff = minBackoff
+		}
+		// Streams reaching their time limit are expected to end
+		level := slog.LevelWarn
+		if errors.Is(err, ssh.ErrStreamLimit) {
+			level = slog.LevelDebug
+		}
+		slog.Log(ctx, level, "events: stream broke, reconnecting", "server_id", f.server.ID, "error", err, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// follow reads the event stream until it breaks. reconnect tells that earlier streams were
+// followed, so that events may have been missed in between.
+func (f *feed) follow(ctx context.Context, reconnect bool) error {
+	backend, err := ssh.NewServerBackend(&f.server)
+	if err != nil {
+		return err
+	}
+	stream, err := backend.Events(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	if reconnect {
+		f.changed()
+	}
+
+	dec := json.NewDecoder(stream)
+	for {
+		var e dockerapi.Event
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("event stream ended")
+			}
+			return err
+		}
+		if event, ok := e.ContainerEvent(f.server.ID); ok {
+			f.changed()
+			f.broadcast(event)
+		}
+	}
+}
+
+func (f *feed) changed() {
+	mu.Lock()
+	fn := onEvent
+	mu.Unlock()
+	if fn != nil {
+		fn(f.server.ID)
+	}
+}
+
+// broadcast passes an event to every subscriber, dropping it for those that fell behind
+func (f *feed) broadcast(event model.ContainerEvent) {
+	mu.Lock()
+	defer mu.Unlock()
+	for sub := range f.subs {
+		select {
+		case sub.ch <- event:
+		default:
+			slog.Debug("events: subscriber fell behind, event dropped", "server_id", f.server.ID, "container_id", event.ContainerID)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnixPrefix marks a listen address as the path of a Unix domain socket, e.g. unix:/run/dockermanager.sock
+const UnixPrefix = "unix:"
+
+// SocketPath returns the socket path of a unix: listen address
+func SocketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, UnixPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, UnixPrefix), true
+}
+
+// Listen opens a TCP listener, or a Unix socket for unix: addresses. A stale socket left by a
+// previous run is removed; the socket file is removed again when the listener is closed.
+// group, a group name or ID, optionally sets the socket's group ownership.
+func Listen(addr string, mode os.FileMode, group string) (net.Listener, error) {
+	path, ok := SocketPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	dir := filepath.Dir(path)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("socket directory %s does not exist", dir)
+	}
+	if err := removeStale(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s (is %s writable?): %w", path, dir, err)
+	}
+	ln.SetUnlinkOnClose(true)
+
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("set socket permissions: %w", err)
+	}
+	if group != "" {
+		gid, err := lookupGroup(group)
+		if err == nil {
+			err = os.Chown(path, -1, gid)
+		}
+		if err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("set socket group %s: %w", group, err)
+		}
+	}
+	return ln, nil
+}
+
+// removeStale deletes a socket file nobody listens on. Regular files and live sockets are left alone.
+func removeStale(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
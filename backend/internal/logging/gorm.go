@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// GormLogger adapts slog to GORM's logger interface
+type GormLogger struct {
+	Level         logger.LogLevel
+	SlowThreshold time.Duration
+}
+
+// NewGormLogger returns a GORM logger writing through slog
+func NewGormLogger(level logger.LogLevel) *GormLogger {
+	return &GormLogger{Level: level, SlowThreshold: time.Second}
+}
+
+func (l *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.Level = level
+	return &clone
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.Level >= logger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, args...), "component", "gorm")
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.Level >= logger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, args...), "component", "gorm")
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.Level >= logger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, args...), "component", "gorm")
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.Level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.Level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "query failed", "component", "gorm", "error", err, "duration_ms", elapsed.Milliseconds(), "rows", rows, "sql", sql)
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold && l.Level >= logger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "slow query", "component", "gorm", "duration_ms", elapsed.Milliseconds(), "rows", rows, "sql", sql)
+	case l.Level >= logger.Info:
+		sql, rows := fc()
+		slog.DebugContext(ctx, "query", "component", "gorm", "duration_ms", elapsed.Milliseconds(), "rows", rows, "sql", sql)
+	}
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	loggerKey = "logger"
+	// RequestIDKey is the gin context key holding the request ID
+	RequestIDKey = "requestID"
+	// RequestIDHeader carries the request ID in requests and responses
+	RequestIDHeader = "X-Request-ID"
+)
+
+// Setup installs the default slog logger. Output from the standard log package is routed through it as well.
+func Setup(level, format string) {
+	var lvl slog.Level
+	switch level {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "warn":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		lvl = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var h slog.Handler
+	if format == "json" {
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		h = slog.NewTextHandler(os.Stdout, opts)
+	}
+	slog.SetDefault(slog.New(h))
+}
+
+// Middleware assigns a request ID (honoring an incoming X-Request-ID), attaches a request-scoped
+// logger to the context and writes one access log line per request.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = NewRequestID()
+		}
+		c.Set(RequestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		logger := slog.Default().With("request_id", requestID, "method", c.Request.Method, "path", c.Request.URL.Path)
+		c.Set(loggerKey, logger)
+
+		start := time.Now()
+		c.Next()
+
+		level := slog.LevelInfo
+		status := c.Writer.Status()
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		L(c).Log(c.Request.Context(), level, "request",
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// L returns the request-scoped logger, including the user ID once authentication has run
+func L(c *gin.Context) *slog.Logger {
+	logger := slog.Default()
+	if v, ok := c.Value(loggerKey).(*slog.Logger); ok {
+		logger = v
+	}
+	if userID, ok := c.Get("userID"); ok {
+		logger = logger.With("user_id", userID)
+	}
+	return logger
+}
+
+// RequestID returns the ID assigned to the current request
+func RequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// Fatal logs at error level and exits
+func Fatal(msg string, args ...any) {
+	slog.Log(context.Background(), slog.LevelError, msg, args...)
+	os.Exit(1)
+}
+
+// NewRequestID generates a random request ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,119 @@
+// Package maintenance tracks the panel-wide maintenance mode
+package maintenance
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/notify"
+
+	"gorm.io/gorm"
+)
+
+// checkInterval is how often the end time of maintenance mode is checked
+const checkInterval = 30 * time.Second
+
+// Status is the maintenance state shown to users
+type Status struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+var (
+	mu      sync.RWMutex
+	enabled bool
+	message string
+	until   time.Time
+)
+
+// Current returns the maintenance status. Maintenance mode past its end time is reported as off.
+func Current() Status {
+	mu.RLock()
+	defer mu.RUnlock()
+	if !enabled || (!until.IsZero() && !time.Now().Before(until)) {
+		return Status{}
+	}
+	s := Status{Enabled: true, Message: message}
+	if !until.IsZero() {
+		u := until
+		s.Until = &u
+	}
+	return s
+}
+
+// Active reports whether maintenance mode is on
+func Active() bool {
+	return Current().Enabled
+}
+
+// Start loads the maintenance settings, follows changes to them and switches maintenance mode
+// off once its end time has passed
+func Start(ctx context.Context, db *gorm.DB) {
+	setEnabled(strconv.FormatBool(config.GetBool(db, model.ConfigKeyMaintenanceMode)))
+	msg, _ := config.Get(db, model.ConfigKeyMaintenanceMessage)
+	setMessage(msg)
+	end, _ := config.Get(db, model.ConfigKeyMaintenanceUntil)
+	setUntil(end)
+	config.OnChange(model.ConfigKeyMaintenanceMode, setEnabled)
+	config.OnChange(model.ConfigKeyMaintenanceMessage, setMessage)
+	config.OnChange(model.ConfigKeyMaintenanceUntil, setUntil)
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				expire(db)
+			}
+		}
+	}()
+}
+
+// expire turns maintenance mode off when its end time has passed
+func expire(db *gorm.DB) {
+	mu.RLock()
+	ended := enabled && !until.IsZero() && !time.Now().Before(until)
+	mu.RUnlock()
+	if !ended {
+		return
+	}
+	off := "false"
+	if err := config.SetMany(db, map[string]*string{
+		model.ConfigKeyMaintenanceMode:  &off,
+		model.ConfigKeyMaintenanceUntil: nil,
+	}); err != nil {
+		slog.Error("failed to end maintenance mode", "error", err)
+		return
+	}
+	slog.Info("maintenance mode ended at its scheduled end time")
+	notify.Notify(db, notify.Event{Type: notify.AdminAction, Detail: "maintenance mode ended at its scheduled end time"})
+}
+
+func setEnabled(value string) {
+	b, _ := strconv.ParseBool(value)
+	mu.Lock()
+	enabled = b
+	mu.Unlock()
+}
+
+func setMessage(value string) {
+	mu.Lock()
+	message = value
+	mu.Unlock()
+}
+
+func setUntil(value string) {
+	t, _ := time.Parse(time.RFC3339, value)
+	mu.Lock()
+	until = t
+	mu.Unlock()
+}
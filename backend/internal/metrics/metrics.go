@@ -0,0 +1,300 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the latency buckets in seconds, from fast cache hits up to slow SSH commands
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// family is a metric exposed in the Prometheus text format
+type family interface {
+	write(w *bufio.Writer)
+}
+
+var (
+	mu       sync.RWMutex
+	families []family
+)
+
+func register(f family) {
+	mu.Lock()
+	families = append(families, f)
+	mu.Unlock()
+}
+
+// labelKey joins label values into a map key
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// CounterVec counts events per combination of label values. Label values must come from a
+// bounded set, such as route templates or server IDs.
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+// NewCounterVec creates and registers a counter
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{name: name, help: help, labels: labels, values: map[string]*counterValue{}}
+	register(v)
+	return v
+}
+
+// Inc adds one for the given label values
+func (v *CounterVec) Inc(labels ...string) {
+	key := labelKey(labels)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	cv, ok := v.values[key]
+	if !ok {
+		cv = &counterValue{labels: labels}
+		v.values[key] = cv
+	}
+	cv.value++
+}
+
+// Samples returns the current value per label combination, sorted by label values
+func (v *CounterVec) Samples() []Sample {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	samples := make([]Sample, 0, len(v.values))
+	for _, key := range sortedKeys(v.values) {
+		cv := v.values[key]
+		samples = append(samples, Sample{Labels: cv.labels, Value: cv.value})
+	}
+	return samples
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	writeHeader(w, v.name, v.help, "counter")
+	for _, key := range sortedKeys(v.values) {
+		cv := v.values[key]
+		writeSample(w, v.name, v.labels, cv.labels, "", "", cv.value)
+	}
+}
+
+// HistogramVec records observations such as latencies into buckets per combination of label values
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64 // per bucket, not cumulative; the last one counts values above every bound
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec creates and registers a histogram with the given upper bucket bounds
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: map[string]*histogram{}}
+	register(v)
+	return v
+}
+
+// Observe records a value for the given label values
+func (v *HistogramVec) Observe(value float64, labels ...string) {
+	key := labelKey(labels)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.values[key]
+	if !ok {
+		h = &histogram{labels: labels, counts: make([]uint64, len(v.buckets)+1)}
+		v.values[key] = h
+	}
+	h.counts[sort.SearchFloat64s(v.buckets, value)]++
+	h.sum += value
+	h.count++
+}
+
+// Summary condenses the observations of one label combination
+type Summary struct {
+	Labels []string
+	Count  uint64
+	Sum    float64
+	// P50 and P95 are estimated from the buckets
+	P50 float64
+	P95 float64
+}
+
+// Summaries returns a summary per label combination, sorted by label values
+func (v *HistogramVec) Summaries() []Summary {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	summaries := make([]Summary, 0, len(v.values))
+	for _, key := range sortedKeys(v.values) {
+		h := v.values[key]
+		summaries = append(summaries, Summary{
+			Labels: h.labels,
+			Count:  h.count,
+			Sum:    h.sum,
+			P50:    v.quantile(h, 0.5),
+			P95:    v.quantile(h, 0.95),
+		})
+	}
+	return summaries
+}
+
+// quantile interpolates linearly inside the bucket holding the requested rank
+func (v *HistogramVec) quantile(h *histogram, q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := q * float64(h.count)
+	var seen float64
+	for i, n := range h.counts {
+		if seen+float64(n) < rank || n == 0 {
+			seen += float64(n)
+			continue
+		}
+		if i == len(v.buckets) {
+			return v.buckets[len(v.buckets)-1]
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = v.buckets[i-1]
+		}
+		return lower + (v.buckets[i]-lower)*(rank-seen)/float64(n)
+	}
+	return v.buckets[len(v.buckets)-1]
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	writeHeader(w, v.name, v.help, "histogram")
+	for _, key := range sortedKeys(v.values) {
+		h := v.values[key]
+		var cumulative uint64
+		for i, bound := range v.buckets {
+			cumulative += h.counts[i]
+			writeSample(w, v.name+"_bucket", v.labels, h.labels, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, v.name+"_bucket", v.labels, h.labels, "le", "+Inf", float64(h.count))
+		writeSample(w, v.name+"_sum", v.labels, h.labels, "", "", h.sum)
+		writeSample(w, v.name+"_count", v.labels, h.labels, "", "", float64(h.count))
+	}
+}
+
+// Sample is one value of a metric read at scrape time
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// funcFamily exposes values owned elsewhere, such as cache counters
+type funcFamily struct {
+	name, help, kind string
+	labels           []string
+	collect          func() []Sample
+}
+
+// NewCounterFunc registers a counter whose samples are read from collect on every scrape
+func NewCounterFunc(name, help string, collect func() []Sample, labels ...string) {
+	register(&funcFamily{name: name, help: help, kind: "counter", labels: labels, collect: collect})
+}
+
+// NewGaugeFunc registers a gauge whose samples are read from collect on every scrape
+func NewGaugeFunc(name, help string, collect func() []Sample, labels ...string) {
+	register(&funcFamily{name: name, help: help, kind: "gauge", labels: labels, collect: collect})
+}
+
+func (f *funcFamily) write(w *bufio.Writer) {
+	writeHeader(w, f.name, f.help, f.kind)
+	for _, s := range f.collect() {
+		writeSample(w, f.name, f.labels, s.Labels, "", "", s.Value)
+	}
+}
+
+// Write renders every registered metric in the Prometheus text exposition format
+func Write(out io.Writer) error {
+	mu.RLock()
+	all := append([]family(nil), families...)
+	mu.RUnlock()
+
+	w := bufio.NewWriter(out)
+	for _, f := range all {
+		f.write(w)
+	}
+	return w.Flush()
+}
+
+// Handler serves the metrics to Prometheus
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSample(w *bufio.Writer, name string, names, values []string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(names) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, n := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			v := ""
+			if i < len(values) {
+				v = values[i]
+			}
+			fmt.Fprintf(w, "%s=%s", n, strconv.Quote(v))
+		}
+		if extraName != "" {
+			if len(names) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=%q", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
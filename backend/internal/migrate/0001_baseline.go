@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The schema as it was created by AutoMigrate before migrations existed. Installs that
+// already have these tables are left untouched and only get the migration recorded.
+
+type baselineUser struct {
+	ID           uint `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	LastLogin    *time.Time
+	Username     string `gorm:"uniqueIndex;size:191;not null"`
+	Password     string `gorm:"not null"`
+	TokenVersion int64  `gorm:"default:1"`
+	TelegramID   int64  `gorm:"index"`
+	Role         string `gorm:"default:'user'"`
+
+	ServerPermissions []baselineServerPermission `gorm:"foreignKey:UserID"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineServer struct {
+	gorm.Model
+	Name     string
+	IP       string
+	Port     int `gorm:"default:22"`
+	Username string
+	AuthMode string
+	Secret   string
+
+	ServerPermissions []baselineServerPermission `gorm:"foreignKey:ServerID"`
+}
+
+func (baselineServer) TableName() string { return "servers" }
+
+type baselineServerPermission struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	UserID      uint   `gorm:"not null;index"`
+	ServerID    uint   `gorm:"not null;index"`
+	AccessLevel string `gorm:"not null"`
+	ExpireAt    *time.Time
+}
+
+func (baselineServerPermission) TableName() string { return "server_permissions" }
+
+type baselineConfig struct {
+	gorm.Model
+	Key   string `gorm:"uniqueIndex;size:191;not null"`
+	Value string `gorm:"type:text"`
+}
+
+func (baselineConfig) TableName() string { return "configs" }
+
+type baselineStatsHistory struct {
+	ID        uint   `gorm:"primaryKey"`
+	ServerID  uint   `gorm:"index"`
+	Target    string `gorm:"index;size:191"`
+	Latency   float64
+	Timestamp time.Time `gorm:"index"`
+}
+
+func (baselineStatsHistory) TableName() string { return "stats_histories" }
+
+func baselineUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&baselineUser{}, &baselineServer{}, &baselineServerPermission{}, &baselineConfig{}, &baselineStatsHistory{})
+}
+
+func baselineDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&baselineStatsHistory{}, &baselineConfig{}, &baselineServerPermission{}, &baselineServer{}, &baselineUser{})
+}
@@ -0,0 +1,22 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// Users can store the language API messages are returned in
+
+type userLanguage struct {
+	Language string `gorm:"size:16"`
+}
+
+func (userLanguage) TableName() string { return "users" }
+
+func userLanguageUp(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn(&userLanguage{}, "Language") {
+		return nil
+	}
+	return tx.Migrator().AddColumn(&userLanguage{}, "Language")
+}
+
+func userLanguageDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&userLanguage{}, "Language")
+}
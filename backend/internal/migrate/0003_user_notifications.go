@@ -0,0 +1,35 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// Users can receive notifications by email and choose their notification channels
+
+type userNotifications struct {
+	Email                string `gorm:"size:191"`
+	NotificationChannels string `gorm:"size:64"`
+}
+
+func (userNotifications) TableName() string { return "users" }
+
+var userNotificationColumns = []string{"Email", "NotificationChannels"}
+
+func userNotificationsUp(tx *gorm.DB) error {
+	for _, column := range userNotificationColumns {
+		if tx.Migrator().HasColumn(&userNotifications{}, column) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(&userNotifications{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func userNotificationsDown(tx *gorm.DB) error {
+	for _, column := range userNotificationColumns {
+		if err := tx.Migrator().DropColumn(&userNotifications{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Outgoing webhooks and the log of their deliveries
+
+type webhook struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string `gorm:"size:191;not null"`
+	URL       string `gorm:"type:text;not null"`
+	Secret    string
+	Events    string `gorm:"size:512"`
+	ServerID  *uint  `gorm:"index"`
+	Format    string `gorm:"size:16;default:'json'"`
+	Enabled   bool
+}
+
+func (webhook) TableName() string { return "webhooks" }
+
+type webhookDelivery struct {
+	ID         uint   `gorm:"primaryKey"`
+	WebhookID  uint   `gorm:"index;not null"`
+	Event      string `gorm:"size:64"`
+	Attempt    int
+	StatusCode int
+	Error      string `gorm:"type:text"`
+	DurationMS int64
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+func (webhookDelivery) TableName() string { return "webhook_deliveries" }
+
+func webhooksUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&webhook{}, &webhookDelivery{})
+}
+
+func webhooksDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&webhookDelivery{}, &webhook{})
+}
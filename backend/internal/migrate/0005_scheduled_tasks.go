@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scheduled container tasks and their run history
+
+type scheduledTask struct {
+	ID         uint `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ServerID   uint   `gorm:"index;not null"`
+	Name       string `gorm:"size:191;not null"`
+	TargetType string `gorm:"size:16;not null"`
+	Target     string `gorm:"size:191;not null"`
+	Type       string `gorm:"size:16;not null"`
+	Command    string `gorm:"type:text"`
+	Cron       string `gorm:"size:64;not null"`
+	Enabled    bool
+	CreatedBy  uint
+	NextRunAt  *time.Time
+	LastRunAt  *time.Time
+	LastStatus string `gorm:"size:16"`
+}
+
+func (scheduledTask) TableName() string { return "scheduled_tasks" }
+
+type taskRun struct {
+	ID         uint      `gorm:"primaryKey"`
+	TaskID     uint      `gorm:"index;not null"`
+	StartedAt  time.Time `gorm:"index"`
+	FinishedAt time.Time
+	Status     string `gorm:"size:16"`
+	Output     string `gorm:"type:text"`
+	Error      string `gorm:"type:text"`
+}
+
+func (taskRun) TableName() string { return "task_runs" }
+
+func scheduledTasksUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&scheduledTask{}, &taskRun{})
+}
+
+func scheduledTasksDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&taskRun{}, &scheduledTask{})
+}
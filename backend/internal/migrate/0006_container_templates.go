@@ -0,0 +1,33 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reusable container run configurations
+
+type containerTemplate struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Name          string `gorm:"size:191;uniqueIndex;not null"`
+	Image         string `gorm:"size:512;not null"`
+	Env           string `gorm:"type:text"`
+	Ports         string `gorm:"type:text"`
+	Volumes       string `gorm:"type:text"`
+	RestartPolicy string `gorm:"size:32"`
+	Notes         string `gorm:"type:text"`
+	CreatedBy     uint
+}
+
+func (containerTemplate) TableName() string { return "container_templates" }
+
+func containerTemplatesUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&containerTemplate{})
+}
+
+func containerTemplatesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&containerTemplate{})
+}
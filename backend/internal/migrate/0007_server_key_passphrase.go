@@ -0,0 +1,22 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// Servers can store the passphrase of an encrypted private key
+
+type serverKeyPassphrase struct {
+	KeyPassphrase string
+}
+
+func (serverKeyPassphrase) TableName() string { return "servers" }
+
+func serverKeyPassphraseUp(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn(&serverKeyPassphrase{}, "KeyPassphrase") {
+		return nil
+	}
+	return tx.Migrator().AddColumn(&serverKeyPassphrase{}, "KeyPassphrase")
+}
+
+func serverKeyPassphraseDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&serverKeyPassphrase{}, "KeyPassphrase")
+}
@@ -0,0 +1,39 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// Servers can be reached through a jump host
+
+type serverJumpHost struct {
+	JumpIP            string
+	JumpPort          int
+	JumpUsername      string
+	JumpAuthMode      string
+	JumpSecret        string
+	JumpKeyPassphrase string
+}
+
+func (serverJumpHost) TableName() string { return "servers" }
+
+var serverJumpHostColumns = []string{"JumpIP", "JumpPort", "JumpUsername", "JumpAuthMode", "JumpSecret", "JumpKeyPassphrase"}
+
+func serverJumpHostUp(tx *gorm.DB) error {
+	for _, column := range serverJumpHostColumns {
+		if tx.Migrator().HasColumn(&serverJumpHost{}, column) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(&serverJumpHost{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func serverJumpHostDown(tx *gorm.DB) error {
+	for _, column := range serverJumpHostColumns {
+		if err := tx.Migrator().DropColumn(&serverJumpHost{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
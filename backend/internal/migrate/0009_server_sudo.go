@@ -0,0 +1,35 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// Docker commands can run through sudo
+
+type serverSudo struct {
+	UseSudo      bool
+	SudoPassword string
+}
+
+func (serverSudo) TableName() string { return "servers" }
+
+var serverSudoColumns = []string{"UseSudo", "SudoPassword"}
+
+func serverSudoUp(tx *gorm.DB) error {
+	for _, column := range serverSudoColumns {
+		if tx.Migrator().HasColumn(&serverSudo{}, column) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(&serverSudo{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func serverSudoDown(tx *gorm.DB) error {
+	for _, column := range serverSudoColumns {
+		if err := tx.Migrator().DropColumn(&serverSudo{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
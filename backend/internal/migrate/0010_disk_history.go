@@ -0,0 +1,28 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Disk usage history collected with the latency history
+
+type diskHistory struct {
+	ID        uint `gorm:"primaryKey"`
+	ServerID  uint `gorm:"index"`
+	Usage     float64
+	Total     int64
+	Used      int64
+	Timestamp time.Time `gorm:"index"`
+}
+
+func (diskHistory) TableName() string { return "disk_histories" }
+
+func diskHistoryUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&diskHistory{})
+}
+
+func diskHistoryDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&diskHistory{})
+}
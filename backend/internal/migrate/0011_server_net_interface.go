@@ -0,0 +1,22 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// Network rates can be read from an interface other than the default route's
+
+type serverNetInterface struct {
+	NetInterface string
+}
+
+func (serverNetInterface) TableName() string { return "servers" }
+
+func serverNetInterfaceUp(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn(&serverNetInterface{}, "NetInterface") {
+		return nil
+	}
+	return tx.Migrator().AddColumn(&serverNetInterface{}, "NetInterface")
+}
+
+func serverNetInterfaceDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&serverNetInterface{}, "NetInterface")
+}
@@ -0,0 +1,35 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stored OS, kernel and Docker details of each server
+
+type systemInfo struct {
+	ServerID      uint `gorm:"primaryKey;autoIncrement:false"`
+	Uname         string
+	Hostname      string
+	Kernel        string
+	Architecture  string
+	OSID          string
+	OSName        string
+	OSVersion     string
+	DockerVersion string
+	StorageDriver string
+	CgroupVersion string
+	CgroupDriver  string
+	UpdatedAt     time.Time
+}
+
+func (systemInfo) TableName() string { return "system_infos" }
+
+func systemInfoUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&systemInfo{})
+}
+
+func systemInfoDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&systemInfo{})
+}
@@ -0,0 +1,22 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// Container operations can use the Docker Engine API instead of the docker CLI
+
+type serverConnectionMode struct {
+	ConnectionMode string `gorm:"default:cli"`
+}
+
+func (serverConnectionMode) TableName() string { return "servers" }
+
+func serverConnectionModeUp(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn(&serverConnectionMode{}, "ConnectionMode") {
+		return nil
+	}
+	return tx.Migrator().AddColumn(&serverConnectionMode{}, "ConnectionMode")
+}
+
+func serverConnectionModeDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&serverConnectionMode{}, "ConnectionMode")
+}
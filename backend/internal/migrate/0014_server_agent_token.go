@@ -0,0 +1,22 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// Servers can be managed through an agent, which authenticates with the server's enrollment token
+
+type serverAgentToken struct {
+	AgentTokenHash string
+}
+
+func (serverAgentToken) TableName() string { return "servers" }
+
+func serverAgentTokenUp(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn(&serverAgentToken{}, "AgentTokenHash") {
+		return nil
+	}
+	return tx.Migrator().AddColumn(&serverAgentToken{}, "AgentTokenHash")
+}
+
+func serverAgentTokenDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&serverAgentToken{}, "AgentTokenHash")
+}
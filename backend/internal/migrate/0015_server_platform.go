@@ -0,0 +1,22 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// The platform of each host is detected once and stored, so its stats use the right commands
+
+type serverPlatform struct {
+	Platform string
+}
+
+func (serverPlatform) TableName() string { return "servers" }
+
+func serverPlatformUp(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn(&serverPlatform{}, "Platform") {
+		return nil
+	}
+	return tx.Migrator().AddColumn(&serverPlatform{}, "Platform")
+}
+
+func serverPlatformDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&serverPlatform{}, "Platform")
+}
@@ -0,0 +1,29 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Logins to container registries, with their secrets encrypted by the master key
+
+type registryCredential struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Registry  string `gorm:"size:191;not null;index"`
+	Username  string `gorm:"size:191;not null"`
+	Secret    string `gorm:"type:text;not null"`
+	ServerID  *uint  `gorm:"index"`
+}
+
+func (registryCredential) TableName() string { return "registry_credentials" }
+
+func registryCredentialsUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&registryCredential{})
+}
+
+func registryCredentialsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&registryCredential{})
+}
@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Containers opted into auto-updates, the latest image checks and the history of updates
+
+type containerAutoUpdate struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	ServerID  uint   `gorm:"uniqueIndex:idx_auto_update_container;not null"`
+	Container string `gorm:"size:191;uniqueIndex:idx_auto_update_container;not null"`
+	CreatedBy uint
+}
+
+func (containerAutoUpdate) TableName() string { return "container_auto_updates" }
+
+type imageCheck struct {
+	ID           uint   `gorm:"primaryKey"`
+	ServerID     uint   `gorm:"uniqueIndex:idx_image_check_container;not null"`
+	Container    string `gorm:"size:191;uniqueIndex:idx_image_check_container;not null"`
+	Image        string `gorm:"size:255"`
+	LocalDigest  string `gorm:"size:100"`
+	RemoteDigest string `gorm:"size:100"`
+	HasUpdate    bool
+	Error        string `gorm:"type:text"`
+	CheckedAt    time.Time
+}
+
+func (imageCheck) TableName() string { return "image_checks" }
+
+type autoUpdateRun struct {
+	ID         uint      `gorm:"primaryKey"`
+	ServerID   uint      `gorm:"index;not null"`
+	Container  string    `gorm:"size:191;not null"`
+	Image      string    `gorm:"size:255"`
+	OldDigest  string    `gorm:"size:100"`
+	NewDigest  string    `gorm:"size:100"`
+	Status     string    `gorm:"size:16"`
+	Error      string    `gorm:"type:text"`
+	StartedAt  time.Time `gorm:"index"`
+	FinishedAt time.Time
+}
+
+func (autoUpdateRun) TableName() string { return "auto_update_runs" }
+
+func autoUpdatesUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&containerAutoUpdate{}, &imageCheck{}, &autoUpdateRun{})
+}
+
+func autoUpdatesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&autoUpdateRun{}, &imageCheck{}, &containerAutoUpdate{})
+}
@@ -0,0 +1,35 @@
+package migrate
+
+import "gorm.io/gorm"
+
+// Servers have a short description and longer notes on what the host is for
+
+type serverNotes struct {
+	Description string `gorm:"size:255"`
+	Notes       string `gorm:"type:text"`
+}
+
+func (serverNotes) TableName() string { return "servers" }
+
+var serverNotesColumns = []string{"Description", "Notes"}
+
+func serverNotesUp(tx *gorm.DB) error {
+	for _, column := range serverNotesColumns {
+		if tx.Migrator().HasColumn(&serverNotes{}, column) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(&serverNotes{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func serverNotesDown(tx *gorm.DB) error {
+	for _, column := range serverNotesColumns {
+		if err := tx.Migrator().DropColumn(&serverNotes{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
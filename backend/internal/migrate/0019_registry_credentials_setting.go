@@ -0,0 +1,90 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"docker-pulse/internal/secretbox"
+
+	"gorm.io/gorm"
+)
+
+// The registry_credentials setting kept registry logins in plain text. They move into the
+// registry credentials table, encrypted with the master key, as logins for all servers.
+
+// registryCredentialsSetting is the key of the setting
+const registryCredentialsSetting = "registry_credentials"
+
+// settingLogin is a login in the setting, which maps registry hosts to them
+type settingLogin struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// settingRegistry is the host a registry was stored under as the credentials table keys it,
+// with the scheme and Docker Hub's alternative names removed
+func settingRegistry(host string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://"), "/")
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "index.docker.io/v1", "registry.hub.docker.com":
+		return "docker.io"
+	}
+	return host
+}
+
+func registryCredentialsSettingUp(tx *gorm.DB) error {
+	var setting baselineConfig
+	err := tx.Where(&baselineConfig{Key: registryCredentialsSetting}).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var creds map[string]settingLogin
+	if strings.TrimSpace(setting.Value) != "" {
+		if err := json.Unmarshal([]byte(setting.Value), &creds); err != nil {
+			return fmt.Errorf("the %s setting is not valid JSON: %w", registryCredentialsSetting, err)
+		}
+	}
+	for host, c := range creds {
+		if c.Username == "" || c.Password == "" {
+			continue
+		}
+		secret, err := secretbox.Seal(c.Password)
+		if err != nil {
+			return fmt.Errorf("moving the %s setting into the encrypted store: %w", registryCredentialsSetting, err)
+		}
+		if err := tx.Create(&registryCredential{Registry: settingRegistry(host), Username: c.Username, Secret: secret}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Delete(&setting).Error
+}
+
+// registryCredentialsSettingDown writes the logins for all servers back into the setting. They
+// stay in the table too, which the rollback of 0016_registry_credentials drops.
+func registryCredentialsSettingDown(tx *gorm.DB) error {
+	var stored []registryCredential
+	if err := tx.Where("server_id IS NULL").Order("id").Find(&stored).Error; err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		return nil
+	}
+	creds := map[string]settingLogin{}
+	for _, cred := range stored {
+		password, err := secretbox.Open(cred.Secret)
+		if err != nil {
+			return fmt.Errorf("decrypting the login for %s: %w", cred.Registry, err)
+		}
+		creds[cred.Registry] = settingLogin{Username: cred.Username, Password: password}
+	}
+	value, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&baselineConfig{Key: registryCredentialsSetting, Value: string(value)}).Error
+}
@@ -0,0 +1,170 @@
+package migrate
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one ordered schema or data change. IDs sort in apply order.
+type Migration struct {
+	ID       string
+	Migrate  func(tx *gorm.DB) error
+	Rollback func(tx *gorm.DB) error
+}
+
+// schemaMigration records an applied migration
+type schemaMigration struct {
+	ID        string `gorm:"primaryKey;size:191"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// lockID identifies the advisory lock that keeps concurrent instances from migrating at once
+const lockID = 72061309
+
+// Run applies every pending migration in order, each in its own transaction
+func Run(db *gorm.DB) error {
+	return withLock(db, func(conn *gorm.DB) error {
+		if err := conn.AutoMigrate(&schemaMigration{}); err != nil {
+			return err
+		}
+		applied, err := appliedIDs(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if applied[m.ID] {
+				continue
+			}
+			m := m
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Migrate(tx); err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{ID: m.ID, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", m.ID, err)
+			}
+			slog.Info("applied migration", "id", m.ID)
+		}
+		return nil
+	})
+}
+
+// Rollback reverts the most recently applied migrations, newest first
+func Rollback(db *gorm.DB, steps int) error {
+	return withLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedIDs(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if !applied[m.ID] {
+				continue
+			}
+			if m.Rollback == nil {
+				return fmt.Errorf("migration %s cannot be rolled back", m.ID)
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Rollback(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{ID: m.ID}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback %s: %w", m.ID, err)
+			}
+			slog.Info("rolled back migration", "id", m.ID)
+			steps--
+		}
+		return nil
+	})
+}
+
+// Pending returns the IDs of migrations that have not been applied yet
+func Pending(db *gorm.DB) ([]string, error) {
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		ids := make([]string, 0, len(migrations))
+		for _, m := range migrations {
+			ids = append(ids, m.ID)
+		}
+		return ids, nil
+	}
+	applied, err := appliedIDs(db)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, m := range migrations {
+		if !applied[m.ID] {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids, nil
+}
+
+// Unknown returns the IDs of applied migrations this version does not have, which a database
+// migrated by a newer version holds
+func Unknown(db *gorm.DB) ([]string, error) {
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return nil, nil
+	}
+	applied, err := appliedIDs(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range migrations {
+		delete(applied, m.ID)
+	}
+	ids := make([]string, 0, len(applied))
+	for id := range applied {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func appliedIDs(db *gorm.DB) (map[string]bool, error) {
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		applied[r.ID] = true
+	}
+	return applied, nil
+}
+
+// withLock runs fn on a single connection holding a database-wide lock where the backend supports one
+func withLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		// Every call below must start from a fresh statement, or the table of the first one
+		// sticks to the connection and later migrations run against schema_migrations
+		conn = conn.Session(&gorm.Session{NewDB: true})
+		switch conn.Dialector.Name() {
+		case "postgres":
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockID)
+		case "mysql":
+			if err := conn.Exec("SELECT GET_LOCK(?, 60)", fmt.Sprint(lockID)).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", fmt.Sprint(lockID))
+		}
+		return fn(conn)
+	})
+}
@@ -0,0 +1,270 @@
+package migrate
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/secretbox"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openSQLite opens an empty sqlite database in a file of its own, as a fresh data dir would have
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "data.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("database handle: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// assertSchema checks that every model has its table and every column it maps
+func assertSchema(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	for _, m := range model.All() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			t.Fatalf("parse %T: %v", m, err)
+		}
+		if !db.Migrator().HasTable(m) {
+			t.Errorf("table %s is missing", stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			if !db.Migrator().HasColumn(m, field.DBName) {
+				t.Errorf("column %s.%s is missing", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func assertNothingPending(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	pending, err := Pending(db)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("pending after run = %v, want none", pending)
+	}
+}
+
+func TestRunFreshInstall(t *testing.T) {
+	db := openSQLite(t)
+
+	pending, err := Pending(db)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if len(pending) != len(migrations) {
+		t.Fatalf("pending on an empty database = %d, want %d", len(pending), len(migrations))
+	}
+
+	if err := Run(db); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	assertNothingPending(t, db)
+	assertSchema(t, db)
+
+	// A second run, as on every later start, finds nothing to do
+	if err := Run(db); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	var count int64
+	db.Model(&schemaMigration{}).Count(&count)
+	if count != int64(len(migrations)) {
+		t.Fatalf("recorded migrations = %d, want %d", count, len(migrations))
+	}
+}
+
+func TestRunUpgradesBaseline(t *testing.T) {
+	db := openSQLite(t)
+
+	// An install from before migrations: the tables AutoMigrate created and no bookkeeping
+	if err := db.AutoMigrate(&baselineUser{}, &baselineServer{}, &baselineServerPermission{}, &baselineConfig{}, &baselineStatsHistory{}); err != nil {
+		t.Fatalf("seed schema: %v", err)
+	}
+	user := baselineUser{Username: "admin", Password: "hash", Role: "admin"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	server := baselineServer{Name: "web", IP: "10.0.0.1", Port: 22, Username: "root", AuthMode: "password", Secret: "sealed"}
+	if err := db.Create(&server).Error; err != nil {
+		t.Fatalf("seed server: %v", err)
+	}
+	expire := time.Now().Add(time.Hour).Truncate(time.Second)
+	perm := baselineServerPermission{UserID: user.ID, ServerID: server.ID, AccessLevel: "read", ExpireAt: &expire}
+	if err := db.Create(&perm).Error; err != nil {
+		t.Fatalf("seed permission: %v", err)
+	}
+	if err := db.Create(&baselineConfig{Key: "site_name", Value: "pulse"}).Error; err != nil {
+		t.Fatalf("seed config: %v", err)
+	}
+
+	if err := Run(db); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	assertNothingPending(t, db)
+	assertSchema(t, db)
+
+	var gotUser model.User
+	if err := db.First(&gotUser, user.ID).Error; err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	if gotUser.Username != "admin" || gotUser.Role != "admin" {
+		t.Fatalf("user = %q/%q, want admin/admin", gotUser.Username, gotUser.Role)
+	}
+	var gotServer model.Server
+	if err := db.First(&gotServer, server.ID).Error; err != nil {
+		t.Fatalf("load server: %v", err)
+	}
+	if gotServer.Name != "web" || gotServer.IP != "10.0.0.1" {
+		t.Fatalf("server = %q/%q, want web/10.0.0.1", gotServer.Name, gotServer.IP)
+	}
+	var perms int64
+	db.Model(&model.ServerPermission{}).Where("user_id = ? AND server_id = ?", user.ID, server.ID).Count(&perms)
+	if perms != 1 {
+		t.Fatalf("permissions = %d, want 1", perms)
+	}
+	var cfg model.Config
+	if err := db.Where("key = ?", "site_name").First(&cfg).Error; err != nil || cfg.Value != "pulse" {
+		t.Fatalf("config = %q (%v), want pulse", cfg.Value, err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	db := openSQLite(t)
+	if err := Run(db); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// One step reverts only the newest migration
+	if err := Rollback(db, 1); err != nil {
+		t.Fatalf("rollback one: %v", err)
+	}
+	pending, err := Pending(db)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if last := migrations[len(migrations)-1].ID; len(pending) != 1 || pending[0] != last {
+		t.Fatalf("pending after one rollback = %v, want [%s]", pending, last)
+	}
+
+	// Every step back to an empty database, then forward again
+	if err := Rollback(db, len(migrations)); err != nil {
+		t.Fatalf("rollback all: %v", err)
+	}
+	for _, m := range model.All() {
+		if db.Migrator().HasTable(m) {
+			t.Errorf("table for %T survived a full rollback", m)
+		}
+	}
+	if err := Run(db); err != nil {
+		t.Fatalf("run after rollback: %v", err)
+	}
+	assertNothingPending(t, db)
+	assertSchema(t, db)
+}
+
+func TestEachMigrationAgainstEmptyDatabase(t *testing.T) {
+	// Each migration runs on a database that holds exactly the ones before it, once from an
+	// empty file and again after being rolled back, so none depends on state left by Run
+	for i, m := range migrations {
+		t.Run(m.ID, func(t *testing.T) {
+			db := openSQLite(t)
+			if err := Run(db); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if err := Rollback(db, len(migrations)-i); err != nil {
+				t.Fatalf("roll back to before %s: %v", m.ID, err)
+			}
+			pending, err := Pending(db)
+			if err != nil {
+				t.Fatalf("pending: %v", err)
+			}
+			if len(pending) == 0 || pending[0] != m.ID {
+				t.Fatalf("first pending = %v, want %s", pending, m.ID)
+			}
+			if err := Run(db); err != nil {
+				t.Fatalf("reapply: %v", err)
+			}
+			assertNothingPending(t, db)
+			assertSchema(t, db)
+		})
+	}
+}
+
+func TestRegistryCredentialsSettingMovesIntoTheStore(t *testing.T) {
+	if err := secretbox.SetKey("test master key"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { secretbox.SetKey("") })
+	db := openSQLite(t)
+	if err := Run(db); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := Rollback(db, 1); err != nil {
+		t.Fatalf("roll back: %v", err)
+	}
+	setting := `{"ghcr.io": {"username": "me", "password": "ghp_token"}, "https://index.docker.io/v1/": {"username": "hub", "password": "hub-pass"}}`
+	if err := db.Create(&baselineConfig{Key: registryCredentialsSetting, Value: setting}).Error; err != nil {
+		t.Fatalf("seed setting: %v", err)
+	}
+
+	if err := Run(db); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var stored []model.RegistryCredential
+	if err := db.Order("registry").Find(&stored).Error; err != nil {
+		t.Fatalf("load credentials: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("moved %d logins, want 2", len(stored))
+	}
+	for i, want := range []struct{ registry, username, password string }{{"docker.io", "hub", "hub-pass"}, {"ghcr.io", "me", "ghp_token"}} {
+		cred := stored[i]
+		if cred.Registry != want.registry || cred.Username != want.username || cred.ServerID != nil {
+			t.Errorf("login %d = %s %s for server %v, want %s %s for all servers", i, cred.Registry, cred.Username, cred.ServerID, want.registry, want.username)
+		}
+		if strings.Contains(cred.Secret, want.password) {
+			t.Errorf("the password of %s is stored in plain text", cred.Registry)
+		}
+		if password, err := secretbox.Open(cred.Secret); err != nil || password != want.password {
+			t.Errorf("the password of %s opens to %q, %v", cred.Registry, password, err)
+		}
+	}
+	var settings int64
+	db.Unscoped().Model(&baselineConfig{}).Where(&baselineConfig{Key: registryCredentialsSetting}).Count(&settings)
+	if settings != 0 {
+		t.Fatal("the plain-text setting was kept")
+	}
+
+	// Without the master key the logins cannot be moved, which stops the upgrade
+	db = openSQLite(t)
+	if err := Run(db); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := Rollback(db, 1); err != nil {
+		t.Fatalf("roll back: %v", err)
+	}
+	db.Create(&baselineConfig{Key: registryCredentialsSetting, Value: setting})
+	secretbox.SetKey("")
+	if err := Run(db); !errors.Is(err, secretbox.ErrNoKey) {
+		t.Fatalf("run without a master key = %v, want ErrNoKey", err)
+	}
+}
@@ -0,0 +1,25 @@
+package migrate
+
+// migrations lists every schema change in apply order. Never edit or reorder an entry once it has shipped;
+// add a new migration instead. Migrations use their own snapshot structs so later model changes don't alter them.
+var migrations = []Migration{
+	{ID: "0001_baseline", Migrate: baselineUp, Rollback: baselineDown},
+	{ID: "0002_user_language", Migrate: userLanguageUp, Rollback: userLanguageDown},
+	{ID: "0003_user_notifications", Migrate: userNotificationsUp, Rollback: userNotificationsDown},
+	{ID: "0004_webhooks", Migrate: webhooksUp, Rollback: webhooksDown},
+	{ID: "0005_scheduled_tasks", Migrate: scheduledTasksUp, Rollback: scheduledTasksDown},
+	{ID: "0006_container_templates", Migrate: containerTemplatesUp, Rollback: containerTemplatesDown},
+	{ID: "0007_server_key_passphrase", Migrate: serverKeyPassphraseUp, Rollback: serverKeyPassphraseDown},
+	{ID: "0008_server_jump_host", Migrate: serverJumpHostUp, Rollback: serverJumpHostDown},
+	{ID: "0009_server_sudo", Migrate: serverSudoUp, Rollback: serverSudoDown},
+	{ID: "0010_disk_history", Migrate: diskHistoryUp, Rollback: diskHistoryDown},
+	{ID: "0011_server_net_interface", Migrate: serverNetInterfaceUp, Rollback: serverNetInterfaceDown},
+	{ID: "0012_system_info", Migrate: systemInfoUp, Rollback: systemInfoDown},
+	{ID: "0013_server_connection_mode", Migrate: serverConnectionModeUp, Rollback: serverConnectionModeDown},
+	{ID: "0014_server_agent_token", Migrate: serverAgentTokenUp, Rollback: serverAgentTokenDown},
+	{ID: "0015_server_platform", Migrate: serverPlatformUp, Rollback: serverPlatformDown},
+	{ID: "0016_registry_credentials", Migrate: registryCredentialsUp, Rollback: registryCredentialsDown},
+	{ID: "0017_auto_updates", Migrate: autoUpdatesUp, Rollback: autoUpdatesDown},
+	{ID: "0018_server_notes", Migrate: serverNotesUp, Rollback: serverNotesDown},
+	{ID: "0019_registry_credentials_setting", Migrate: registryCredentialsSettingUp, Rollback: registryCredentialsSettingDown},
+}
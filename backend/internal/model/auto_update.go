@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+// Auto-update run outcomes
+const (
+	AutoUpdateSucceeded = "succeeded"
+	AutoUpdateFailed    = "failed"
+)
+
+// ContainerAutoUpdate opts a container into being updated automatically during the maintenance
+// window once a newer image is found. Containers are kept by name, which survives updates.
+type ContainerAutoUpdate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ServerID  uint      `gorm:"uniqueIndex:idx_auto_update_container;not null" json:"server_id"`
+	Container string    `gorm:"size:191;uniqueIndex:idx_auto_update_container;not null" json:"container"`
+	CreatedBy uint      `json:"created_by"`
+}
+
+// ImageCheck is the result of the latest scheduled update check of a container's image
+type ImageCheck struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	ServerID  uint   `gorm:"uniqueIndex:idx_image_check_container;not null" json:"server_id"`
+	Container string `gorm:"size:191;uniqueIndex:idx_image_check_container;not null" json:"container"`
+	Image     string `gorm:"size:255" json:"image"`
+	// LocalDigest is the repo digest of the image the container runs, RemoteDigest the digest its
+	// tag points at in the registry
+	LocalDigest  string `gorm:"size:100" json:"local_digest"`
+	RemoteDigest string `gorm:"size:100" json:"remote_digest"`
+	HasUpdate    bool   `json:"has_update"`
+	// Error is why the image could not be checked, such as a build without a registry
+	Error     string    `gorm:"type:text" json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	// AutoUpdate tells whether the container is opted into auto-updates
+	AutoUpdate bool `gorm:"-" json:"auto_update"`
+}
+
+// AutoUpdateRun records an automatic update of a container
+type AutoUpdateRun struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	ServerID  uint   `gorm:"index;not null" json:"server_id"`
+	Container string `gorm:"size:191;not null" json:"container"`
+	Image     string `gorm:"size:255" json:"image"`
+	// OldDigest is the repo digest the container ran before, NewDigest the one it runs after a
+	// successful update
+	OldDigest  string    `gorm:"size:100" json:"old_digest"`
+	NewDigest  string    `gorm:"size:100" json:"new_digest,omitempty"`
+	Status     string    `gorm:"size:16" json:"status"`
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+	StartedAt  time.Time `gorm:"index" json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// AutoUpdateRequest opts a container into or out of auto-updates
+type AutoUpdateRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// AutoUpdateResponse is a container's auto-update setting. Container is its name.
+type AutoUpdateResponse struct {
+	Container  string `json:"container"`
+	AutoUpdate bool   `json:"auto_update"`
+}
+
+// ImageChecksResponse lists the latest update checks of a server's containers
+type ImageChecksResponse struct {
+	Checks []ImageCheck `json:"checks"`
+}
+
+// AutoUpdateRunsResponse lists a server's automatic updates, newest first
+type AutoUpdateRunsResponse struct {
+	Runs []AutoUpdateRun `json:"runs"`
+}
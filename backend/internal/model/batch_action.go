@@ -0,0 +1,26 @@
+package model
+
+// BatchActionRequest runs a container action on several containers of a server
+type BatchActionRequest struct {
+	Action       string   `json:"action" binding:"required,oneof=start stop restart pause unpause kill remove"`
+	ContainerIDs []string `json:"container_ids" binding:"required,min=1,max=100,dive,required"`
+	// Signal is the signal the kill action sends, SIGKILL when empty
+	Signal string `json:"signal,omitempty"`
+}
+
+// BatchActionResult is the outcome of a batch action for one container: the success message, or
+// the error when it failed
+type BatchActionResult struct {
+	ContainerID string `json:"container_id"`
+	Success     bool   `json:"success"`
+	Message     string `json:"message,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BatchActionResponse lists the outcome for each container, in the order they were given
+type BatchActionResponse struct {
+	Action    string              `json:"action"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []BatchActionResult `json:"results"`
+}
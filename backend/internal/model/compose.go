@@ -0,0 +1,73 @@
+package model
+
+// Actions of POST /servers/:id/compose/:project/action. ComposePull pulls the project's images
+// and recreates the containers whose image changed.
+const (
+	ComposeUp      = "up"
+	ComposeDown    = "down"
+	ComposeRestart = "restart"
+	ComposePull    = "pull"
+)
+
+// ComposeProject is a docker compose project as found from the labels of its containers.
+// WorkingDir and ConfigFiles are paths on the server.
+type ComposeProject struct {
+	Name        string   `json:"name"`
+	WorkingDir  string   `json:"working_dir"`
+	ConfigFiles []string `json:"config_files"`
+	Services    []string `json:"services"`
+	Containers  int      `json:"containers"`
+	Running     int      `json:"running"`
+}
+
+// ComposeProjectsResponse lists a server's compose projects sorted by name
+type ComposeProjectsResponse struct {
+	Projects []ComposeProject `json:"projects"`
+}
+
+// ComposeActionRequest runs a docker compose command on a project
+type ComposeActionRequest struct {
+	Action string `json:"action" binding:"required,oneof=up down restart pull"`
+}
+
+// ComposeActionResult is the outcome of a compose action. Output holds what docker compose
+// printed on stdout and stderr; ExitCode is not 0 when it failed.
+type ComposeActionResult struct {
+	Project  string `json:"project"`
+	Action   string `json:"action"`
+	Output   string `json:"output"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// ComposeFile is a config file of a compose project as stored on the server
+type ComposeFile struct {
+	Project string `json:"project"`
+	Path    string `json:"path"`
+	// Files lists all config files of the project, such as override files; ?file= picks one
+	Files   []string `json:"files"`
+	Content string   `json:"content"`
+}
+
+// ComposeFileUpdate replaces a config file of a compose project. File is one of the project's
+// config files, the first one when empty.
+type ComposeFileUpdate struct {
+	File    string `json:"file"`
+	Content string `json:"content" binding:"required,max=1048576"`
+}
+
+// ComposeServiceChanges lists the services a config file change added, removed or changed, by
+// name
+type ComposeServiceChanges struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// ComposeFileResult is the outcome of writing a config file. Backup is the copy of the previous
+// file next to it.
+type ComposeFileResult struct {
+	Project  string                `json:"project"`
+	Path     string                `json:"path"`
+	Backup   string                `json:"backup"`
+	Services ComposeServiceChanges `json:"services"`
+}
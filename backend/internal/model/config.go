@@ -4,12 +4,46 @@ import "gorm.io/gorm"
 
 type Config struct {
 	gorm.Model
-	Key   string `gorm:"uniqueIndex;not null"`
+	Key   string `gorm:"uniqueIndex;size:191;not null"`
 	Value string `gorm:"type:text"`
 }
 
 const (
-	ConfigKeyTelegramBotToken  = "telegram_bot_token"
-	ConfigKeyTelegramWebAppURL = "telegram_web_app_url"
-	ConfigKeyPingTargets       = "ping_targets"
+	ConfigKeyTelegramBotToken    = "telegram_bot_token"
+	ConfigKeyTelegramWebAppURL   = "telegram_web_app_url"
+	ConfigKeyPingTargets         = "ping_targets"
+	ConfigKeyStatsInterval       = "stats_interval_seconds"
+	ConfigKeyDiskAlertPercent    = "disk_alert_percent"
+	ConfigKeyContainerCacheTTL   = "cache_ttl_containers_seconds"
+	ConfigKeyContainerStatsTTL   = "cache_ttl_container_stats_seconds"
+	ConfigKeyServerCacheTTL      = "cache_ttl_servers_seconds"
+	ConfigKeyCORSOrigins         = "cors_allowed_origins"
+	ConfigKeyBackupInterval      = "backup_interval_hours"
+	ConfigKeyBackupRetention     = "backup_retention"
+	ConfigKeySMTPHost            = "smtp_host"
+	ConfigKeySMTPPort            = "smtp_port"
+	ConfigKeySMTPUsername        = "smtp_username"
+	ConfigKeySMTPPassword        = "smtp_password"
+	ConfigKeySMTPFrom            = "smtp_from"
+	ConfigKeySMTPSecurity        = "smtp_security"
+	ConfigKeyMaintenanceMode     = "maintenance_mode"
+	ConfigKeyMaintenanceMessage  = "maintenance_message"
+	ConfigKeyMaintenanceUntil    = "maintenance_until"
+	ConfigKeyUpdateCheck         = "update_check"
+	ConfigKeySSHCircuitThreshold = "ssh_circuit_failures"
+	ConfigKeySSHCircuitCooldown  = "ssh_circuit_cooldown_seconds"
+	ConfigKeySSHPoolIdle         = "ssh_pool_idle_seconds"
+	ConfigKeySSHCommandTimeout   = "ssh_command_timeout_seconds"
+	ConfigKeySSHKeepAlive        = "ssh_keepalive_seconds"
+	ConfigKeyPingTCPPort         = "ping_tcp_port"
+	ConfigKeySSHStreamMaxMB      = "ssh_stream_max_mb"
+	ConfigKeySSHStreamTimeout    = "ssh_stream_timeout_seconds"
+	ConfigKeySSHMaxSessions      = "ssh_max_sessions"
+	ConfigKeySSHSessionWait      = "ssh_session_wait_seconds"
+	ConfigKeyLogTailMax          = "log_tail_max"
+	ConfigKeyLogSearchMaxMatches = "log_search_max_matches"
+	ConfigKeyFileContentMax      = "file_content_max_bytes"
+	ConfigKeyAutoUpdateInterval  = "auto_update_check_interval_hours"
+	ConfigKeyAutoUpdateWindow    = "auto_update_window"
+	ConfigKeyVolumeHelperImage   = "volume_helper_image"
 )
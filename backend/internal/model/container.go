@@ -12,23 +12,74 @@ type Container struct {
 	Image      string    `json:"image"`
 	Status     string    `json:"status"`
 	State      string    `json:"state"`
+	// Health is one of the Health constants, read from the status such as "Up 2 hours (healthy)"
+	Health     string    `json:"health"`
 	Ports      []string  `json:"ports"`
 	CreatedAt  time.Time `json:"created_at"`
 	UserID     uint      `json:"user_id"` // Owner of the container
 	Permission string    `json:"permission"` // e.g., "read", "write", "admin"
+
+	// Compose project and service from the labels of containers created by docker compose
+	ComposeProject string `json:"compose_project,omitempty"`
+	ComposeService string `json:"compose_service,omitempty"`
+	// Labels are only used to filter the list, as they can be long
+	Labels map[string]string `json:"-"`
+
+	// Resource usage from "docker stats", only set in the container stats response and only for
+	// running containers. Memory is in bytes; NetIO and BlockIO are docker's "read / written" text.
+	CPUPercent float64 `json:"cpu_percent,omitempty"`
+	MemUsage   int64   `json:"mem_usage,omitempty"`
+	MemLimit   int64   `json:"mem_limit,omitempty"`
+	NetIO      string  `json:"net_io,omitempty"`
+	BlockIO    string  `json:"block_io,omitempty"`
 }
 
 // ContainerListResponse is the response structure for listing containers
 type ContainerListResponse struct {
 	Containers []Container `json:"containers"`
-	Total      int         `json:"total"`
+	// Total counts all containers of the server, Filtered those matching the filters; the
+	// page is taken from the filtered ones
+	Total    int `json:"total"`
+	Filtered int `json:"filtered"`
+	Page     int `json:"page,omitempty"`
+	PageSize int `json:"page_size,omitempty"`
+	// FetchedAt is when the list was read from docker; it may be served until it is refreshed
+	FetchedAt time.Time `json:"fetched_at"`
+	// Cached tells whether the list was served from the cache rather than read for this request
+	Cached bool `json:"cached"`
+}
+
+// StandaloneGroup is the group of containers not created by docker compose
+const StandaloneGroup = "standalone"
+
+// ContainerGroup is the containers of one compose project, or the StandaloneGroup
+type ContainerGroup struct {
+	Project    string      `json:"project"`
+	Containers []Container `json:"containers"`
+}
+
+// ContainerGroupsResponse is the container list grouped by compose project, with projects sorted
+// by name and the standalone group last
+type ContainerGroupsResponse struct {
+	Groups    []ContainerGroup `json:"groups"`
+	Total     int              `json:"total"`
+	Filtered  int              `json:"filtered"`
+	Page      int              `json:"page,omitempty"`
+	PageSize  int              `json:"page_size,omitempty"`
+	FetchedAt time.Time        `json:"fetched_at"`
+	Cached    bool             `json:"cached"`
 }
 
-// ContainerActionRequest is the request structure for container actions (start, stop, restart, remove)
+// ContainerActionRequest is the request structure for container actions (start, stop, restart, remove, pause, unpause, kill, rename)
 type ContainerActionRequest struct {
+	// ServerID is optional and must match the server in the path when given
 	ServerID    uint   `json:"server_id"`
 	ContainerID string `json:"container_id"`
-	Action      string `json:"action"` // "start", "stop", "restart", "remove"
+	Action      string `json:"action"` // "start", "stop", "restart", "remove", "pause", "unpause", "kill", "rename"
+	// Signal is the signal the kill action sends, SIGKILL when empty
+	Signal string `json:"signal,omitempty"`
+	// NewName is the name the rename action gives the container
+	NewName string `json:"new_name,omitempty"`
 }
 
 // ContainerLogRequest is the request structure for fetching container logs
@@ -41,27 +92,52 @@ type ContainerLogRequest struct {
 // ContainerLogResponse is the response structure for container logs
 type ContainerLogResponse struct {
 	Logs string `json:"logs"`
+	// LimitReached is set when ?grep= stopped at log_search_max_matches lines
+	LimitReached bool `json:"limit_reached,omitempty"`
 }
 
-// FileEntry represents a file or directory within a container
+// FileEntry represents a file or directory within a container. LinkTarget is where a symbolic
+// link points, as it was created. Symbolic links that resolve to a directory have IsDir set as
+// well, except in listings of images without ls.
 type FileEntry struct {
 	Name        string    `json:"name"`
 	Size        int64     `json:"size"`
 	Mode        string    `json:"mode"` // e.g., "drwxr-xr-x"
 	IsDir       bool      `json:"is_dir"`
 	IsSymlink   bool      `json:"is_symlink"` // New field to indicate if it's a symbolic link
+	LinkTarget  string    `json:"link_target,omitempty"`
 	ModTime     time.Time `json:"mod_time"`
 	Permissions string    `json:"permissions"` // e.g., "755"
 }
 
-// FileListResponse is the response structure for listing files
+// FileListResponse is the response structure for listing files. Truncated is set when a listing
+// stopped at its limit, which only volume listings have.
 type FileListResponse struct {
-	Path  string      `json:"path"`
-	Files []FileEntry `json:"files"`
+	Path      string      `json:"path"`
+	Files     []FileEntry `json:"files"`
+	Truncated bool        `json:"truncated,omitempty"`
 }
 
-// FileContentResponse is the response structure for file content
+// FileContentResponse is the response structure for file content. Files that look binary or are
+// larger than file_content_max_bytes come without content, unless binary ones are asked for as
+// base64.
 type FileContentResponse struct {
 	Path    string `json:"path"`
 	Content string `json:"content"`
+	// Encoding is "base64" when Content is base64, and empty for text
+	Encoding string `json:"encoding,omitempty"`
+	// Size is the number of bytes of the file Content holds, the whole file unless a part was
+	// read. For files left out for their size it is the file's size, or -1 when that is unknown.
+	Size int64 `json:"size"`
+	// TotalSize is the file's size, or -1 when it is too large and its size unknown
+	TotalSize int64 `json:"total_size"`
+	// Offset is where Content starts in the file
+	Offset int64 `json:"offset"`
+	// EOF is set when Content reaches the end of the file
+	EOF bool `json:"eof"`
+	// IsBinary is set, with Content empty, for files to download instead; Hint says so
+	IsBinary bool `json:"is_binary"`
+	// TooLarge is set when the file was left out for its size rather than its content
+	TooLarge bool   `json:"too_large,omitempty"`
+	Hint     string `json:"hint,omitempty"`
 }
@@ -0,0 +1,27 @@
+package model
+
+// ContainerCreateRequest creates and starts a container, like docker run -d
+type ContainerCreateRequest struct {
+	Image string `json:"image" binding:"required,max=255"`
+	Name  string `json:"name" binding:"required,max=128"`
+	// Env entries are NAME=value
+	Env []string `json:"env"`
+	// Ports are docker -p specs, [ip:][hostPort:]containerPort[/proto]
+	Ports []string `json:"ports"`
+	// Volumes are docker -v specs with a source, "/host/path:/path" or "volume:/path[:ro]"
+	Volumes []string `json:"volumes"`
+	// RestartPolicy is no, always, unless-stopped or on-failure[:max], no when empty
+	RestartPolicy string `json:"restart_policy"`
+	// Network is the network the container joins, docker's default bridge when empty
+	Network string `json:"network"`
+	// Command replaces the image's command when set
+	Command []string `json:"command"`
+}
+
+// ContainerCreateResult is the container created by ContainerCreateRequest. Pulled tells that its
+// image was missing and was pulled first.
+type ContainerCreateResult struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	Pulled      bool   `json:"pulled"`
+}
@@ -0,0 +1,99 @@
+package model
+
+import "time"
+
+// ContainerDetails is what docker inspect reports about a container, in sections
+type ContainerDetails struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Image   string    `json:"image"`
+	ImageID string    `json:"image_id"`
+	Created time.Time `json:"created"`
+	// RestartCount is how often docker restarted the container under its restart policy
+	RestartCount  int                     `json:"restart_count"`
+	State         ContainerState          `json:"state"`
+	Config        ContainerConfig         `json:"config"`
+	Network       ContainerNetwork        `json:"network"`
+	Mounts        []ContainerMount        `json:"mounts"`
+	RestartPolicy RestartPolicy           `json:"restart_policy"`
+	Health        ContainerHealth         `json:"health"`
+	Resources     ContainerResourceLimits `json:"resources"`
+}
+
+// ContainerState is the run state of a container. FinishedAt is zero for containers that never
+// stopped.
+type ContainerState struct {
+	Status     string    `json:"status"`
+	Running    bool      `json:"running"`
+	Paused     bool      `json:"paused"`
+	Restarting bool      `json:"restarting"`
+	OOMKilled  bool      `json:"oom_killed"`
+	PID        int       `json:"pid"`
+	ExitCode   int       `json:"exit_code"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// ContainerConfig is how a container runs its process. Values of sensitive environment
+// variables are masked for non-admins.
+type ContainerConfig struct {
+	Hostname   string            `json:"hostname"`
+	User       string            `json:"user"`
+	WorkingDir string            `json:"working_dir"`
+	Env        []string          `json:"env"`
+	Cmd        []string          `json:"cmd"`
+	Entrypoint []string          `json:"entrypoint"`
+	Labels     map[string]string `json:"labels"`
+	Tty        bool              `json:"tty"`
+}
+
+// ContainerNetwork is how a container is connected: its network mode, the networks it is
+// attached to and its published ports
+type ContainerNetwork struct {
+	Mode     string                     `json:"mode"`
+	Networks []ContainerNetworkEndpoint `json:"networks"`
+	Ports    []ContainerPortMapping     `json:"ports"`
+}
+
+// ContainerNetworkEndpoint is a container's attachment to a network. Link is the API path of
+// the network.
+type ContainerNetworkEndpoint struct {
+	Name        string   `json:"name"`
+	NetworkID   string   `json:"network_id"`
+	Link        string   `json:"link"`
+	IPAddress   string   `json:"ip_address"`
+	IPv6Address string   `json:"ipv6_address,omitempty"`
+	Gateway     string   `json:"gateway"`
+	MacAddress  string   `json:"mac_address"`
+	Aliases     []string `json:"aliases"`
+}
+
+// ContainerPortMapping is a container port, and where it is published when it is. Ports that
+// are only exposed have no host port.
+type ContainerPortMapping struct {
+	ContainerPort string `json:"container_port"`
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      string `json:"host_port,omitempty"`
+}
+
+// ContainerMount is a bind mount, volume or tmpfs of a container
+type ContainerMount struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Mode        string `json:"mode"`
+	RW          bool   `json:"rw"`
+}
+
+// ContainerResourceLimits are a container's resource limits; 0 is unlimited
+type ContainerResourceLimits struct {
+	MemoryBytes            int64   `json:"memory_bytes"`
+	MemoryReservationBytes int64   `json:"memory_reservation_bytes"`
+	MemorySwapBytes        int64   `json:"memory_swap_bytes"`
+	CPUs                   float64 `json:"cpus"`
+	CPUShares              int64   `json:"cpu_shares"`
+	CPUSetCPUs             string  `json:"cpuset_cpus,omitempty"`
+	PidsLimit              int64   `json:"pids_limit"`
+}
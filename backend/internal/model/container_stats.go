@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// ContainerStatsSample is a reading of a container's resource usage. Memory is in bytes; NetIO
+// and BlockIO are docker's "read / written" text.
+type ContainerStatsSample struct {
+	Time       time.Time `json:"time"`
+	CPUPercent float64   `json:"cpu_percent"`
+	MemUsage   int64     `json:"mem_usage"`
+	MemLimit   int64     `json:"mem_limit"`
+	NetIO      string    `json:"net_io"`
+	BlockIO    string    `json:"block_io"`
+}
+
+// ContainerStats is the latest reading of a container's resource usage, and with several samples
+// every reading, oldest first
+type ContainerStats struct {
+	ContainerID string `json:"container_id"`
+	ContainerStatsSample
+	Samples []ContainerStatsSample `json:"samples,omitempty"`
+}
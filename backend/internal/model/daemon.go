@@ -0,0 +1,31 @@
+package model
+
+// DaemonConfig is a server's Docker daemon configuration as read from daemon.json
+type DaemonConfig struct {
+	Path string `json:"path"`
+	// Exists is false when there is no daemon.json and dockerd runs on its defaults
+	Exists bool                   `json:"exists"`
+	Config map[string]interface{} `json:"config"`
+	// Raw is the file as stored, so that a file dockerd cannot parse can still be inspected
+	Raw string `json:"raw"`
+	// ParseError is set when Raw is not valid JSON
+	ParseError string `json:"parse_error,omitempty"`
+}
+
+// DaemonConfigUpdate replaces daemon.json. Restarting docker applies the change and requires
+// Confirm to repeat the server's name.
+type DaemonConfigUpdate struct {
+	Config  map[string]interface{} `json:"config" binding:"required"`
+	Restart bool                   `json:"restart"`
+	Confirm string                 `json:"confirm"`
+}
+
+// DaemonConfigResult reports a daemon.json update
+type DaemonConfigResult struct {
+	Path string `json:"path"`
+	// Backup is the copy of the previous file, empty when there was none
+	Backup    string `json:"backup"`
+	Restarted bool   `json:"restarted"`
+	// DockerVersion is reported by docker info once the daemon is back after a restart
+	DockerVersion string `json:"docker_version,omitempty"`
+}
@@ -0,0 +1,41 @@
+package model
+
+// DockerDiskUsage is the disk space docker takes on a server, as "docker system df" reports it.
+// Images and Volumes are only listed for the verbose variant.
+type DockerDiskUsage struct {
+	Types            []DockerDiskUsageType `json:"types"`
+	TotalBytes       int64                 `json:"total_bytes"`
+	ReclaimableBytes int64                 `json:"reclaimable_bytes"`
+	Images           []ImageDiskUsage      `json:"images,omitempty"`
+	Volumes          []VolumeDiskUsage     `json:"volumes,omitempty"`
+}
+
+// DockerDiskUsageType is the space taken by one kind of docker object: "Images", "Containers",
+// "Local Volumes" or "Build Cache". Active counts the objects in use, by a container for images
+// and volumes, running for containers.
+type DockerDiskUsageType struct {
+	Type             string `json:"type"`
+	TotalCount       int    `json:"total_count"`
+	Active           int    `json:"active"`
+	SizeBytes        int64  `json:"size_bytes"`
+	ReclaimableBytes int64  `json:"reclaimable_bytes"`
+}
+
+// ImageDiskUsage is the space taken by an image. SharedSizeBytes is shared with other images
+// through common layers; UniqueSizeBytes is freed by removing the image.
+type ImageDiskUsage struct {
+	ID              string `json:"id"`
+	Repository      string `json:"repository"`
+	Tag             string `json:"tag"`
+	SizeBytes       int64  `json:"size_bytes"`
+	SharedSizeBytes int64  `json:"shared_size_bytes"`
+	UniqueSizeBytes int64  `json:"unique_size_bytes"`
+	Containers      int    `json:"containers"`
+}
+
+// VolumeDiskUsage is the space taken by a local volume. Links counts the containers using it.
+type VolumeDiskUsage struct {
+	Name      string `json:"name"`
+	Links     int    `json:"links"`
+	SizeBytes int64  `json:"size_bytes"`
+}
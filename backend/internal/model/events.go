@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ContainerEvent is a change of a container's state that docker reported
+type ContainerEvent struct {
+	ServerID    uint   `json:"server_id"`
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	// Action is start, stop, die or oom
+	Action string `json:"action"`
+	// ExitCode is set for die events
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Time     time.Time `json:"time"`
+}
@@ -0,0 +1,22 @@
+package model
+
+// File operations of POST /servers/:id/containers/:containerID/files/op
+const (
+	FileOpDelete = "delete"
+	FileOpRename = "rename"
+	FileOpMkdir  = "mkdir"
+	FileOpChmod  = "chmod"
+)
+
+// FileOpRequest changes a file or directory in a container. Paths are absolute.
+type FileOpRequest struct {
+	Op   string `json:"op" binding:"required,oneof=delete rename mkdir chmod"`
+	Path string `json:"path" binding:"required,max=4096"`
+	// NewPath is where rename moves Path to; it must not exist yet
+	NewPath string `json:"new_path" binding:"max=4096"`
+	// Mode is the octal mode chmod sets, e.g. "755"
+	Mode string `json:"mode"`
+	// Recursive lets delete remove a directory that is not empty and chmod apply to a
+	// directory's contents
+	Recursive bool `json:"recursive"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Container health states, from the container's HEALTHCHECK. HealthNone is a container without
+// a health check, or one that is not running.
+const (
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+	HealthStarting  = "starting"
+	HealthNone      = "none"
+)
+
+// ContainerHealth is the state of a container's health check and its latest probes, oldest
+// first. Docker keeps the last five probes. FailingStreak counts the failed probes in a row.
+type ContainerHealth struct {
+	ContainerID   string        `json:"container_id"`
+	Status        string        `json:"status"`
+	FailingStreak int           `json:"failing_streak"`
+	Probes        []HealthProbe `json:"probes"`
+}
+
+// HealthProbe is a run of a container's health check. An ExitCode of 0 is healthy, 1 unhealthy;
+// other codes mean the check could not run. Output is what the check printed, cut off by docker
+// after 4 KB.
+type HealthProbe struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exit_code"`
+	Output   string    `json:"output"`
+}
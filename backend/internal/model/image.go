@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// ImageTag is a tag of a container image's repository
+type ImageTag struct {
+	Name string `json:"name"`
+	// Digest and UpdatedAt are only known for Docker Hub repositories
+	Digest    string     `json:"digest,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// Running is set when the tag points at the image the container runs
+	Running bool `json:"running"`
+}
+
+// ImageTagList is one page of the tags available for a container's image
+type ImageTagList struct {
+	Image      string `json:"image"`
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	// Tag is the tag the container was created from
+	Tag string `json:"tag"`
+	// RunningDigests are the repo digests of the image the container runs
+	RunningDigests []string `json:"running_digests"`
+	// RunningTags lists every tag, on any page, that points at the running image
+	RunningTags []string   `json:"running_tags"`
+	Tags        []ImageTag `json:"tags"`
+	Total       int        `json:"total"`
+	Page        int        `json:"page"`
+	PerPage     int        `json:"per_page"`
+	FetchedAt   time.Time  `json:"fetched_at"`
+}
+
+// ImageUpdate tells whether the tag a container was created from now points at another image
+// in the registry
+type ImageUpdate struct {
+	HasUpdate bool   `json:"has_update"`
+	Image     string `json:"image"`
+	// Platform is the platform of the image the container runs, e.g. "linux/arm64/v8"
+	Platform string `json:"platform"`
+	// LocalDigests are the repo digests of the image the container runs
+	LocalDigests []string `json:"local_digests"`
+	// RemoteDigest is the digest the tag points at in the registry, an index for multi-platform
+	// images, and RemotePlatformDigest the digest of the index's image for Platform
+	RemoteDigest         string `json:"remote_digest"`
+	RemotePlatformDigest string `json:"remote_platform_digest,omitempty"`
+	// CheckedAt is when the registry was asked, checks are cached for an hour
+	CheckedAt time.Time `json:"checked_at"`
+}
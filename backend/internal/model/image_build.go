@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Build job states
+const (
+	BuildRunning   = "running"
+	BuildSucceeded = "succeeded"
+	BuildFailed    = "failed"
+)
+
+// ImageSummary is an image on a server
+type ImageSummary struct {
+	ID         string `json:"id"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+	Size       string `json:"size"`
+	CreatedAt  string `json:"created_at"`
+	// BuiltLocally is set for images without a repo digest, which were built on the host
+	BuiltLocally bool `json:"built_locally"`
+}
+
+// ImagePullRequest names an image to pull. Tag defaults to "latest" unless Image already names a
+// tag or digest, in which case Tag must be empty.
+type ImagePullRequest struct {
+	Image string `json:"image" binding:"required,max=512"`
+	Tag   string `json:"tag" binding:"max=128"`
+}
+
+// BuildJob is an image build running in the background. Output holds the build output from
+// the requested offset; NextOffset is the offset to ask for next.
+type BuildJob struct {
+	ID         string     `json:"id"`
+	ServerID   uint       `json:"server_id"`
+	Tag        string     `json:"tag"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Output     string     `json:"output"`
+	NextOffset int64      `json:"next_offset"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,21 @@
+package model
+
+// Types of the messages of a followed container log
+const (
+	LogStreamLine  = "log"
+	LogStreamExit  = "exit"
+	LogStreamError = "error"
+)
+
+// LogStreamMessage is a message of /ws/logs: a log line, the container's exit once it stops,
+// or why following the log failed. The last two are followed by a close of the connection.
+type LogStreamMessage struct {
+	Type string `json:"type"`
+	// Line is a line of a log message, without the line break
+	Line string `json:"line,omitempty"`
+	// Status is the container's state after it stopped, empty when the container is gone
+	Status string `json:"status,omitempty"`
+	// ExitCode is set on exit messages of containers that are still around
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
@@ -0,0 +1,77 @@
+package model
+
+// ContainerLogUsage is the disk space taken by a container's log files
+type ContainerLogUsage struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	// Driver is the log driver; only json-file and local keep files that count here
+	Driver  string `json:"driver"`
+	LogPath string `json:"log_path"`
+	// SizeBytes includes rotated files next to LogPath
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// LogUsageResponse lists container log sizes, largest first
+type LogUsageResponse struct {
+	Containers []ContainerLogUsage `json:"containers"`
+	TotalBytes int64               `json:"total_bytes"`
+}
+
+// LogTruncateRequest confirms emptying a container's log by repeating the container name or ID
+// used in the request path
+type LogTruncateRequest struct {
+	Confirm string `json:"confirm" binding:"required"`
+}
+
+// LogConfigRequest sets how a container's json-file or local log driver rotates its log.
+// MaxSize is a size such as "10m" and MaxFile the number of files kept; empty values keep the
+// driver's defaults.
+type LogConfigRequest struct {
+	MaxSize string `json:"max_size"`
+	MaxFile int    `json:"max_file" binding:"min=0,max=100"`
+}
+
+// LogConfigResponse is the task that recreates the container with the new log settings, and a
+// warning about what recreating it does
+type LogConfigResponse struct {
+	PullTask
+	Warning string `json:"warning"`
+}
+
+// LogSearchRequest searches the part of a container's log that Tail, Since and Until select,
+// as GET /servers/:id/containers/:containerID/logs takes them, with a regular expression
+type LogSearchRequest struct {
+	Pattern    string `json:"pattern" binding:"required,max=1000"`
+	IgnoreCase bool   `json:"ignore_case"`
+	// Before and After are the lines of context returned around each match
+	Before int `json:"before" binding:"min=0,max=20"`
+	After  int `json:"after" binding:"min=0,max=20"`
+	// MaxMatches stops the search early; 0 means the log_search_max_matches setting, which
+	// also bounds it
+	MaxMatches int    `json:"max_matches" binding:"min=0"`
+	Tail       string `json:"tail"`
+	Since      string `json:"since"`
+	Until      string `json:"until"`
+	Timestamps bool   `json:"timestamps"`
+}
+
+// LogLine is a line of a container's log, numbered from 1 within the searched part
+type LogLine struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+// LogMatch is a matching log line with the lines around it
+type LogMatch struct {
+	LogLine
+	Before []LogLine `json:"before,omitempty"`
+	After  []LogLine `json:"after,omitempty"`
+}
+
+// LogSearchResponse lists the matches of a log search. LimitReached tells that the search
+// stopped at the match limit, so later lines were not searched.
+type LogSearchResponse struct {
+	Matches      []LogMatch `json:"matches"`
+	LinesScanned int        `json:"lines_scanned"`
+	LimitReached bool       `json:"limit_reached"`
+}
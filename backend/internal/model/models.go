@@ -0,0 +1,23 @@
+package model
+
+// All returns every persisted model, parents before the tables referencing them
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&Server{},
+		&ServerPermission{},
+		&Config{},
+		&StatsHistory{},
+		&DiskHistory{},
+		&SystemInfo{},
+		&Webhook{},
+		&WebhookDelivery{},
+		&ScheduledTask{},
+		&TaskRun{},
+		&ContainerTemplate{},
+		&RegistryCredential{},
+		&ContainerAutoUpdate{},
+		&ImageCheck{},
+		&AutoUpdateRun{},
+	}
+}
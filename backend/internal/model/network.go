@@ -0,0 +1,73 @@
+package model
+
+// Network is a docker network on a server. Containers are the containers attached to it, only
+// running ones for most drivers. BuiltIn marks the bridge, host and none networks docker
+// creates itself, which cannot be removed.
+type Network struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Driver     string             `json:"driver"`
+	Scope      string             `json:"scope"`
+	CreatedAt  string             `json:"created_at,omitempty"`
+	Internal   bool               `json:"internal"`
+	IPv6       bool               `json:"ipv6"`
+	Subnets    []NetworkSubnet    `json:"subnets"`
+	Labels     map[string]string  `json:"labels"`
+	Containers []NetworkContainer `json:"containers"`
+	BuiltIn    bool               `json:"built_in"`
+}
+
+// NetworkSubnet is an address pool of a network's IPAM config
+type NetworkSubnet struct {
+	Subnet  string `json:"subnet"`
+	Gateway string `json:"gateway,omitempty"`
+	IPRange string `json:"ip_range,omitempty"`
+}
+
+// NetworkContainer is a container attached to a network, with its addresses on it in CIDR
+// notation
+type NetworkContainer struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	IPv4Address string `json:"ipv4_address"`
+	IPv6Address string `json:"ipv6_address,omitempty"`
+	MacAddress  string `json:"mac_address"`
+}
+
+// NetworksResponse lists a server's networks
+type NetworksResponse struct {
+	Networks []Network `json:"networks"`
+}
+
+// NetworkDetails is a network with the driver options docker network inspect reports
+type NetworkDetails struct {
+	Network
+	Attachable bool              `json:"attachable"`
+	Options    map[string]string `json:"options"`
+}
+
+// NetworkCreateRequest creates a bridge network. Gateway requires Subnet and has to lie in it;
+// without a subnet docker picks a free one. Internal networks have no route to the outside.
+type NetworkCreateRequest struct {
+	Name     string            `json:"name" binding:"required,max=128"`
+	Subnet   string            `json:"subnet" binding:"omitempty,cidr"`
+	Gateway  string            `json:"gateway" binding:"omitempty,ip"`
+	IPRange  string            `json:"ip_range" binding:"omitempty,cidr"`
+	Internal bool              `json:"internal"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// NetworkConnectRequest attaches a container to a network, optionally at a fixed address of
+// the network's subnet and with aliases other containers on the network can resolve
+type NetworkConnectRequest struct {
+	Container   string   `json:"container" binding:"required"`
+	IPv4Address string   `json:"ipv4_address" binding:"omitempty,ipv4"`
+	Aliases     []string `json:"aliases"`
+}
+
+// NetworkDisconnectRequest detaches a container from a network. Force also detaches containers
+// that are not running.
+type NetworkDisconnectRequest struct {
+	Container string `json:"container" binding:"required"`
+	Force     bool   `json:"force"`
+}
@@ -0,0 +1,29 @@
+package model
+
+// PortBinding is a host port in use on a server, either published by a container or held by
+// another process
+type PortBinding struct {
+	HostIP   string `json:"host_ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	// Container and ContainerPort are set for ports published by a container
+	Container     string `json:"container,omitempty"`
+	ContainerPort string `json:"container_port,omitempty"`
+	// Process is set for other listeners when the SSH user may see it, e.g. "nginx"
+	Process string `json:"process,omitempty"`
+}
+
+// PortListResponse lists the host ports in use on a server
+type PortListResponse struct {
+	Ports []PortBinding `json:"ports"`
+	// ListenerError explains why non-docker listeners are missing, e.g. when ss is not installed
+	ListenerError string `json:"listener_error,omitempty"`
+}
+
+// PortCheckRequest asks whether host ports are free. Ports use the docker -p syntax, e.g.
+// "8080:80" or "127.0.0.1:5353:53/udp". Ports published by ExcludeContainer are ignored, so a
+// container can be recreated with its own ports.
+type PortCheckRequest struct {
+	Ports            []string `json:"ports" binding:"required"`
+	ExcludeContainer string   `json:"exclude_container"`
+}
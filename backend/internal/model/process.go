@@ -0,0 +1,23 @@
+package model
+
+// ContainerProcess is a process running in a container, as docker top reports it. Hosts with a
+// BusyBox ps report no CPU and memory usage, and the plain docker top columns no user name
+// either; fields ps did not report are left empty.
+type ContainerProcess struct {
+	PID  int `json:"pid"`
+	PPID int `json:"ppid"`
+	// User is the user name, or the user ID when ps prints no names
+	User       string   `json:"user"`
+	CPUPercent *float64 `json:"cpu_percent,omitempty"`
+	MemPercent *float64 `json:"mem_percent,omitempty"`
+	// Elapsed is how long the process runs, as ps prints it, e.g. "1-02:03:04"
+	Elapsed string `json:"elapsed,omitempty"`
+	Command string `json:"command"`
+}
+
+// ContainerProcessesResponse lists the processes of a container. Titles are the ps columns the
+// processes were read from.
+type ContainerProcessesResponse struct {
+	Titles    []string           `json:"titles"`
+	Processes []ContainerProcess `json:"processes"`
+}
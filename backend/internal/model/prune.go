@@ -0,0 +1,56 @@
+package model
+
+// Kinds of docker objects a prune removes, in the order they are pruned: removing containers
+// first lets the prune of the other kinds free what those containers used
+const (
+	PruneContainers = "containers"
+	PruneImages     = "images"
+	PruneVolumes    = "volumes"
+	PruneNetworks   = "networks"
+	PruneBuildCache = "build-cache"
+)
+
+// PruneTypes lists the kinds of docker objects in the order they are pruned
+var PruneTypes = []string{PruneContainers, PruneImages, PruneVolumes, PruneNetworks, PruneBuildCache}
+
+// PruneRequest selects what unused docker objects a prune removes
+type PruneRequest struct {
+	Types []string `json:"types" binding:"required,min=1,dive,oneof=containers images volumes networks build-cache"`
+	// Until only prunes objects created longer ago than a duration such as "24h". Volumes have no
+	// creation filter.
+	Until string `json:"until"`
+	// Labels only prunes objects with all of these labels, "key" or "key=value". The build cache
+	// has no labels.
+	Labels []string `json:"labels"`
+	// All also prunes tagged images and build cache that is not dangling, and on docker 23 and
+	// later named volumes; otherwise only dangling images and cache and anonymous volumes go
+	All bool `json:"all"`
+	// DryRun reports what would be removed without removing anything
+	DryRun bool `json:"dry_run"`
+}
+
+// PruneResult is what a prune removed, or would remove for a dry run
+type PruneResult struct {
+	DryRun         bool              `json:"dry_run"`
+	Types          []PruneTypeResult `json:"types"`
+	ReclaimedBytes int64             `json:"reclaimed_bytes"`
+}
+
+// PruneTypeResult is what the prune of one kind of object removed, as docker reported it. For a
+// dry run Items are the objects that would be removed and ReclaimedBytes estimates their size
+// from "docker system df -v"; the build cache is not listed and its reclaimable size is given.
+// Error is set when the prune of this kind failed, the other kinds are pruned regardless.
+type PruneTypeResult struct {
+	Type           string      `json:"type"`
+	Items          []PruneItem `json:"items"`
+	ReclaimedBytes int64       `json:"reclaimed_bytes"`
+	Error          string      `json:"error,omitempty"`
+}
+
+// PruneItem is a pruned object. Volumes only have a name and pruned networks are reported by
+// name; SizeBytes is only known for dry runs.
+type PruneItem struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// Pull task states
+const (
+	PullRunning   = "running"
+	PullSucceeded = "succeeded"
+	PullFailed    = "failed"
+)
+
+// Error codes of failed pulls, for the failures clients handle differently. Other failures have
+// no code.
+const (
+	PullErrorImageNotFound = "image_not_found"
+	PullErrorUnauthorized  = "registry_unauthorized"
+	PullErrorRateLimited   = "registry_rate_limited"
+	PullErrorDiskFull      = "disk_full"
+)
+
+// PullTask is an image pull running in the background: of a container's image, Action "pull",
+// of an image by name, Action "pull_image", or a container update, Action "update", which pulls
+// the image and then recreates the container. Percent is the mean progress of the layers, 100
+// once the task succeeded. Output holds the task's progress lines from the requested offset;
+// NextOffset is the offset to ask for next.
+type PullTask struct {
+	ID          string `json:"id"`
+	ServerID    uint   `json:"server_id"`
+	ContainerID string `json:"container_id"`
+	// Image is the reference pulled by a "pull_image" task
+	Image  string `json:"image,omitempty"`
+	Action string `json:"action"`
+	// NewContainerID is the ID of the container that replaced an updated one
+	NewContainerID string `json:"new_container_id,omitempty"`
+	Status         string `json:"status"`
+	Error          string `json:"error,omitempty"`
+	// ErrorCode classifies the error of a failed task, one of the PullError codes
+	ErrorCode  string      `json:"error_code,omitempty"`
+	Percent    int         `json:"percent"`
+	Layers     []PullLayer `json:"layers"`
+	Output     string      `json:"output"`
+	NextOffset int64       `json:"next_offset"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
+
+// PullLayer is the progress of a layer of a pulled image. Current and Total count the bytes
+// downloaded or extracted so far when docker reports them.
+type PullLayer struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Current int64  `json:"current,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+	Percent int    `json:"percent"`
+}
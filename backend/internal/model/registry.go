@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// RegistryCredential is a login to a container registry, used to pull images on servers and to
+// query the registry from the backend
+type RegistryCredential struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Registry is the registry host, e.g. "ghcr.io", or "docker.io" for Docker Hub
+	Registry string `gorm:"size:191;not null;index" json:"registry"`
+	Username string `gorm:"size:191;not null" json:"username"`
+	// Secret is the password or token, encrypted with the master key. It is never returned by the API.
+	Secret string `gorm:"type:text;not null" json:"-"`
+	// ServerID limits the credential to one server, which then uses it over a credential for all
+	// servers. Registry queries of the backend for that server's containers use it too.
+	ServerID *uint `gorm:"index" json:"server_id"`
+}
@@ -0,0 +1,14 @@
+package model
+
+// RestartPolicy is when docker restarts a container: Name is "no", "always", "unless-stopped" or
+// "on-failure", which gives up after MaximumRetryCount restarts unless that is 0
+type RestartPolicy struct {
+	Name              string `json:"name"`
+	MaximumRetryCount int    `json:"maximum_retry_count"`
+}
+
+// RestartPolicyRequest sets a container's restart policy, written as for docker run --restart:
+// "no", "always", "unless-stopped", "on-failure" or "on-failure:<max>"
+type RestartPolicyRequest struct {
+	Policy string `json:"policy" binding:"required"`
+}
@@ -1,6 +1,10 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Server represents the server table (servers)
 type Server struct {
@@ -11,7 +15,61 @@ type Server struct {
 	Username    string `json:"username"`
 	AuthMode    string `json:"auth_mode"`
 	Secret      string `json:"-"`
+	// KeyPassphrase decrypts Secret when it is an encrypted private key
+	KeyPassphrase string `json:"-"`
+
+	// Optional jump host the server is reached through, authenticated like the server itself
+	JumpIP            string `json:"jump_ip,omitempty"`
+	JumpPort          int    `json:"jump_port,omitempty"`
+	JumpUsername      string `json:"jump_username,omitempty"`
+	JumpAuthMode      string `json:"jump_auth_mode,omitempty"`
+	JumpSecret        string `json:"-"`
+	JumpKeyPassphrase string `json:"-"`
+
+	// UseSudo runs docker commands through sudo, for users outside the docker group. SudoPassword
+	// answers sudo's prompt when it is not passwordless.
+	UseSudo      bool   `json:"use_sudo"`
+	SudoPassword string `json:"-"`
+
+	// NetInterface is the interface network rates are read from, the default route's when empty
+	NetInterface string `json:"net_interface,omitempty"`
+
+	// ConnectionMode is how container operations reach Docker, ConnectionModeCLI or ConnectionModeAPI
+	ConnectionMode string `json:"connection_mode" gorm:"default:cli"`
+
+	// AgentTokenHash is the hash of the enrollment token the server's agent connects with, empty
+	// when no agent is enrolled
+	AgentTokenHash string `json:"-"`
+
+	// Platform is the host's kernel as "uname -s" reports it, lowercased, detected when its stats
+	// are first collected
+	Platform string `json:"platform,omitempty"`
+
+	// Description says in a line what the host is for. Notes are longer, in markdown; like the
+	// other fields only admins edit them, and everyone who can read the server sees them.
+	Description string `json:"description" gorm:"size:255"`
+	Notes       string `json:"notes" gorm:"type:text"`
 
 	// Relationships
 	ServerPermissions []ServerPermission `gorm:"foreignKey:ServerID"`
-}
\ No newline at end of file
+}
+
+// Connection modes of a server: docker CLI commands run over SSH, or the Docker Engine API on the
+// host's Docker socket forwarded through the SSH connection
+const (
+	ConnectionModeCLI = "cli"
+	ConnectionModeAPI = "api"
+)
+
+// AuthModeDockerTLS servers are not reached over SSH but over the Docker Engine API on TCP with
+// mutual TLS. Their Secret is a PEM bundle of the client certificate, its key and the CA.
+const AuthModeDockerTLS = "docker-tls"
+
+// ServerDetails is a server as it is returned on its own, with the age of the data
+type ServerDetails struct {
+	Server
+	// FetchedAt is when the server was read from the database; Cached tells whether it was
+	// served from the cache rather than read for this request
+	FetchedAt time.Time `json:"fetched_at"`
+	Cached    bool      `json:"cached"`
+}
@@ -5,7 +5,17 @@ import "time"
 type StatsHistory struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	ServerID  uint      `gorm:"index" json:"server_id"`
-	Target    string    `gorm:"index" json:"target"` // The ping target
+	Target    string    `gorm:"index;size:191" json:"target"` // The ping target
 	Latency   float64   `json:"latency"`
 	Timestamp time.Time `gorm:"index" json:"timestamp"`
 }
+
+// DiskHistory is the disk usage of a server's Docker filesystem at one collector cycle
+type DiskHistory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ServerID  uint      `gorm:"index" json:"server_id"`
+	Usage     float64   `json:"usage"`
+	Total     int64     `json:"total"`
+	Used      int64     `json:"used"`
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+}
@@ -0,0 +1,25 @@
+package model
+
+// SwarmScaleRequest sets a replicated service's replica count
+type SwarmScaleRequest struct {
+	Replicas *int `json:"replicas" binding:"required,min=0"`
+}
+
+// SwarmUpdateRequest changes a service's image and/or forces a rolling restart
+type SwarmUpdateRequest struct {
+	Image string `json:"image"`
+	Force bool   `json:"force"`
+}
+
+// SwarmRollout reports a service's tasks after a scale or update. Converged is false when the
+// tasks did not settle before the panel stopped waiting; the change then continues in the swarm.
+type SwarmRollout struct {
+	Service   string `json:"service"`
+	Desired   int    `json:"desired"`
+	Running   int    `json:"running"`
+	Pending   int    `json:"pending"`
+	Failed    int    `json:"failed"`
+	Converged bool   `json:"converged"`
+	// Errors are the distinct errors of failed tasks
+	Errors []string `json:"errors"`
+}
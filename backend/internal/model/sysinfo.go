@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// SystemInfo describes a server's operating system and Docker setup. It rarely changes, so it is
+// stored and only read from the server again on request.
+type SystemInfo struct {
+	ServerID uint `gorm:"primaryKey;autoIncrement:false" json:"server_id"`
+	// Uname is the full uname -a line, Kernel and Architecture its release and machine fields
+	Uname        string `json:"uname"`
+	Hostname     string `json:"hostname"`
+	Kernel       string `json:"kernel"`
+	Architecture string `json:"architecture"`
+	// OSID and OSVersion are ID and VERSION_ID from os-release, e.g. "ubuntu" and "20.04". Hosts
+	// without os-release only get OSName, from /etc/issue.
+	OSID      string `json:"os_id"`
+	OSName    string `json:"os_name"`
+	OSVersion string `json:"os_version"`
+	// The Docker fields are empty when docker info failed
+	DockerVersion string    `json:"docker_version"`
+	StorageDriver string    `json:"storage_driver"`
+	CgroupVersion string    `json:"cgroup_version"`
+	CgroupDriver  string    `json:"cgroup_driver"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Scheduled task types
+const (
+	TaskRestart = "restart"
+	TaskExec    = "exec"
+	TaskPull    = "pull"
+)
+
+// Scheduled task targets
+const (
+	TaskTargetContainer = "container"
+	TaskTargetCompose   = "compose"
+)
+
+// Task run outcomes
+const (
+	TaskRunSuccess = "success"
+	TaskRunFailed  = "failed"
+	TaskRunSkipped = "skipped"
+)
+
+// ScheduledTask runs an action against a container or compose project on a cron schedule
+type ScheduledTask struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ServerID  uint      `gorm:"index;not null" json:"server_id"`
+	Name      string    `gorm:"size:191;not null" json:"name"`
+	// TargetType is "container" or "compose"; Target is the container name or ID, or the compose project
+	TargetType string `gorm:"size:16;not null" json:"target_type"`
+	Target     string `gorm:"size:191;not null" json:"target"`
+	// Type is restart, exec (Command runs inside the container) or pull (pull the image and update)
+	Type      string     `gorm:"size:16;not null" json:"type"`
+	Command   string     `gorm:"type:text" json:"command"`
+	Cron      string     `gorm:"size:64;not null" json:"cron"`
+	Enabled   bool       `json:"enabled"`
+	CreatedBy uint       `json:"created_by"`
+	NextRunAt *time.Time `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	// LastStatus is the outcome of the last run: success, failed or skipped
+	LastStatus string `gorm:"size:16" json:"last_status"`
+}
+
+// TaskRun records one execution of a scheduled task
+type TaskRun struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TaskID     uint      `gorm:"index;not null" json:"task_id"`
+	StartedAt  time.Time `gorm:"index" json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Status     string    `gorm:"size:16" json:"status"`
+	Output     string    `gorm:"type:text" json:"output"`
+	Error      string    `gorm:"type:text" json:"error"`
+}
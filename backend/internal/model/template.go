@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// Labels set on containers created from a template
+const (
+	LabelTemplateID   = "dockermanager.template.id"
+	LabelTemplateName = "dockermanager.template.name"
+)
+
+// ContainerTemplate is a saved docker run configuration. The image, env values, ports and volumes
+// may contain ${NAME} placeholders that are filled in when a container is created from it.
+type ContainerTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `gorm:"size:191;uniqueIndex;not null" json:"name"`
+	Image     string    `gorm:"size:512;not null" json:"image"`
+	// Env holds KEY=value entries, Ports docker -p specs and Volumes docker -v specs, one per line
+	Env           string `gorm:"type:text" json:"env"`
+	Ports         string `gorm:"type:text" json:"ports"`
+	Volumes       string `gorm:"type:text" json:"volumes"`
+	RestartPolicy string `gorm:"size:32" json:"restart_policy"`
+	Notes         string `gorm:"type:text" json:"notes"`
+	CreatedBy     uint   `json:"created_by"`
+}
+
+// TemplateDeployRequest creates a container from a template. Values fills the template's
+// placeholders; every placeholder needs a value, which may be empty.
+type TemplateDeployRequest struct {
+	Name   string            `json:"name" binding:"required,max=128"`
+	Values map[string]string `json:"values"`
+}
+
+// TemplateDeployResult is the container created from a template
+type TemplateDeployResult struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	TemplateID  uint   `json:"template_id"`
+	// Pulled tells that the image was missing and was pulled first
+	Pulled bool `json:"pulled"`
+}
@@ -12,11 +12,15 @@ type User struct {
 	CreatedAt	 time.Time    `json:"created_at"`
 	UpdatedAt    time.Time    `json:"updated_at"`
 	LastLogin    *time.Time   `json:"last_login"`
-	Username     string       `gorm:"uniqueIndex;not null" json:"username"`
+	Username     string       `gorm:"uniqueIndex;size:191;not null" json:"username"`
 	Password     string       `gorm:"not null" json:"-"`
 	TokenVersion int64        `gorm:"default:1" json:"-"`
 	TelegramID   int64        `gorm:"index" json:"telegram_id"`
 	Role         string       `gorm:"default:'user'" json:"role"`
+	Language     string       `gorm:"size:16" json:"language"` // preferred language of API messages, empty to follow Accept-Language
+	Email        string       `gorm:"size:191" json:"email"`
+	// NotificationChannels is a comma separated list of channels alerts are delivered on, e.g. "telegram,email"
+	NotificationChannels string `gorm:"size:64" json:"notification_channels"`
 	
 	ServerPermissions []ServerPermission `gorm:"foreignKey:UserID"`
 }
@@ -0,0 +1,44 @@
+package model
+
+// Volume is a docker volume on a server. SizeBytes is what "docker system df -v" reports, unset
+// for volumes it cannot size such as those of other drivers. A volume is dangling when no
+// container, running or not, mounts it.
+type Volume struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	Scope      string            `json:"scope"`
+	CreatedAt  string            `json:"created_at,omitempty"`
+	Labels     map[string]string `json:"labels"`
+	SizeBytes  *int64            `json:"size_bytes,omitempty"`
+	Containers []VolumeMount     `json:"containers"`
+	Dangling   bool              `json:"dangling"`
+}
+
+// VolumeMount is a container that mounts a volume, at Destination
+type VolumeMount struct {
+	Container   string `json:"container"`
+	Destination string `json:"destination"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+// VolumesResponse lists a server's volumes
+type VolumesResponse struct {
+	Volumes []Volume `json:"volumes"`
+}
+
+// VolumeDetails is a volume with the driver options and status docker volume inspect reports
+type VolumeDetails struct {
+	Volume
+	Options map[string]string      `json:"options"`
+	Status  map[string]interface{} `json:"status,omitempty"`
+}
+
+// VolumePruneRequest removes the volumes no container mounts. Without All docker 23 and later
+// only remove anonymous volumes. Labels only prunes volumes with all of these labels, "key" or
+// "key=value"; DryRun lists the volumes without removing them.
+type VolumePruneRequest struct {
+	All    bool     `json:"all"`
+	Labels []string `json:"labels"`
+	DryRun bool     `json:"dry_run"`
+}
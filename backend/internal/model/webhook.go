@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Webhook payload formats
+const (
+	WebhookFormatJSON    = "json"
+	WebhookFormatSlack   = "slack"
+	WebhookFormatDiscord = "discord"
+)
+
+// Webhook pushes events to an external URL
+type Webhook struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `gorm:"size:191;not null" json:"name"`
+	URL       string    `gorm:"type:text;not null" json:"url"`
+	// Secret signs payloads with HMAC-SHA256 when set. It is never returned by the API.
+	Secret string `json:"-"`
+	// Events is a comma separated list of event types, empty for all events
+	Events string `gorm:"size:512" json:"events"`
+	// ServerID limits server events to one server when set. Events without a server are always sent.
+	ServerID *uint  `gorm:"index" json:"server_id"`
+	Format   string `gorm:"size:16;default:'json'" json:"format"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// WebhookDelivery records one attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	WebhookID  uint      `gorm:"index;not null" json:"webhook_id"`
+	Event      string    `gorm:"size:64" json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `gorm:"type:text" json:"error"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
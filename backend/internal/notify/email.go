@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// smtpTimeout bounds a whole SMTP conversation
+const smtpTimeout = 30 * time.Second
+
+// ErrSMTPNotConfigured is returned when no SMTP host is set
+var ErrSMTPNotConfigured = errors.New("SMTP is not configured")
+
+// SMTPSettings are the SMTP options from the configuration registry
+type SMTPSettings struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// Security is "starttls", "tls" or "none"
+	Security string
+}
+
+// LoadSMTP reads the current SMTP settings
+func LoadSMTP(db *gorm.DB) SMTPSettings {
+	get := func(key string) string {
+		v, _ := config.Get(db, key)
+		return v
+	}
+	return SMTPSettings{
+		Host:     get(model.ConfigKeySMTPHost),
+		Port:     config.GetInt(db, model.ConfigKeySMTPPort),
+		Username: get(model.ConfigKeySMTPUsername),
+		Password: get(model.ConfigKeySMTPPassword),
+		From:     get(model.ConfigKeySMTPFrom),
+		Security: get(model.ConfigKeySMTPSecurity),
+	}
+}
+
+// Configured reports whether email can be sent
+func (s SMTPSettings) Configured() bool {
+	return s.Host != "" && s.From != ""
+}
+
+// SendEmail delivers a message to a single address. Errors are returned as reported by the
+// SMTP server so that admins can diagnose their settings.
+func SendEmail(s SMTPSettings, to string, m Message) error {
+	if !s.Configured() {
+		return ErrSMTPNotConfigured
+	}
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	body, err := buildMessage(from, rcpt, m)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	conn, err := net.DialTimeout("tcp", addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	tlsConfig := &tls.Config{ServerName: s.Host}
+	if s.Security == "tls" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.Security == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("the SMTP server does not support STARTTLS; set smtp_security to tls or none")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(rcpt.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMessage renders a multipart/alternative message with the plain text and HTML bodies
+func buildMessage(from, to *mail.Address, m Message) ([]byte, error) {
+	boundary := randomToken()
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+randomToken()+"@dockermanager>")
+	header("MIME-Version", "1.0")
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\nContent-Type: %s\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n", boundary, part.contentType)
+		qp := quotedprintable.NewWriter(&buf)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+func randomToken() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
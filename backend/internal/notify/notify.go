@@ -0,0 +1,177 @@
+// Package notify renders alerts and delivers them to users on the channels they selected
+package notify
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Delivery channels a user can select
+const (
+	ChannelTelegram = "telegram"
+	ChannelEmail    = "email"
+)
+
+// Channels lists every channel in display order
+var Channels = []string{ChannelTelegram, ChannelEmail}
+
+// EventType identifies a kind of notification
+type EventType string
+
+const (
+	ServerOffline    EventType = "server_offline"
+	ServerRecovered  EventType = "server_recovered"
+	ContainerCrashed EventType = "container_crashed"
+	ImageUpdate      EventType = "image_update"
+	// AutoUpdateSucceeded and AutoUpdateFailed report automatic container updates
+	AutoUpdateSucceeded EventType = "auto_update_succeeded"
+	AutoUpdateFailed    EventType = "auto_update_failed"
+	Digest              EventType = "digest"
+	// DiskFull reports a server whose disk filled past the disk_alert_percent setting
+	DiskFull EventType = "disk_full"
+	// AdminAction reports changes made by admins. It is only sent to webhooks.
+	AdminAction EventType = "admin_action"
+	// UpdateAvailable reports a newer DockerManager release. It is only sent to admins.
+	UpdateAvailable EventType = "update_available"
+	// Test is sent on request to check the delivery settings
+	Test EventType = "test"
+)
+
+// EventTypes lists the event types webhooks can subscribe to
+var EventTypes = []EventType{ServerOffline, ServerRecovered, ContainerCrashed, ImageUpdate, AutoUpdateSucceeded, AutoUpdateFailed, Digest, DiskFull, AdminAction, UpdateAvailable}
+
+// Event is something users are notified about. Server events reach every user with access to
+// the server; events without a server reach every user.
+type Event struct {
+	Type       EventType
+	ServerID   uint
+	ServerName string
+	Container  string
+	Image      string
+	// Actor is the user who triggered the event, if any
+	Actor string
+	// Detail is a free form explanation, e.g. the error that took a server offline
+	Detail string
+	// Lines are the entries of a digest, or the largest container logs of a full disk
+	Lines []string
+	// Version and URL describe a DockerManager release
+	Version string
+	URL     string
+	Time    time.Time
+}
+
+// Recipient is the user a message is delivered to
+type Recipient struct {
+	UserID     uint
+	Username   string
+	Email      string
+	TelegramID int64
+}
+
+// Message is a rendered notification
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers a message on one channel. Returned errors are retried.
+type Sender func(r Recipient, m Message) error
+
+var (
+	sendersMu sync.RWMutex
+	senders   = map[string]Sender{}
+)
+
+// RegisterSender sets the sender of a channel, replacing the previous one
+func RegisterSender(channel string, s Sender) {
+	sendersMu.Lock()
+	defer sendersMu.Unlock()
+	senders[channel] = s
+}
+
+func senderFor(channel string) (Sender, bool) {
+	sendersMu.RLock()
+	defer sendersMu.RUnlock()
+	s, ok := senders[channel]
+	return s, ok
+}
+
+// ValidChannel reports whether a channel name is known
+func ValidChannel(channel string) bool {
+	for _, ch := range Channels {
+		if ch == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitList turns a stored comma separated list, such as channels or event types, into its entries
+func SplitList(value string) []string {
+	channels := []string{}
+	for _, ch := range strings.Split(value, ",") {
+		if ch != "" {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// Notify renders the event and queues it for every interested user on each of their channels
+// and for every matching webhook. It never blocks on delivery.
+func Notify(db *gorm.DB, ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	msg, err := Render(ev)
+	if err != nil {
+		slog.Error("notify: failed to render notification", "type", ev.Type, "error", err)
+		return
+	}
+	if ev.Type != AdminAction {
+		notifyUsers(db, ev, msg)
+	}
+	dispatchWebhooks(db, ev, msg)
+}
+
+// notifyUsers queues the message for users who have access to the event's server
+func notifyUsers(db *gorm.DB, ev Event, msg Message) {
+	var users []model.User
+	q := db.Select("id", "username", "email", "telegram_id", "notification_channels").
+		Where("notification_channels <> ''")
+	if ev.Type == UpdateAvailable {
+		q = q.Where("role = ?", "admin")
+	} else if ev.ServerID != 0 {
+		q = q.Where("role = ? OR id IN (?)", "admin",
+			db.Model(&model.ServerPermission{}).Select("user_id").Where("server_id = ?", ev.ServerID))
+	}
+	if err := q.Find(&users).Error; err != nil {
+		slog.Error("notify: failed to load recipients", "type", ev.Type, "error", err)
+		return
+	}
+
+	for _, u := range users {
+		r := Recipient{UserID: u.ID, Username: u.Username, Email: u.Email, TelegramID: u.TelegramID}
+		for _, ch := range SplitList(u.NotificationChannels) {
+			if (ch == ChannelEmail && r.Email == "") || (ch == ChannelTelegram && r.TelegramID == 0) {
+				continue
+			}
+			ch, r := ch, r
+			enqueue(job{event: ev.Type, target: fmt.Sprintf("%s:user/%d", ch, r.UserID), send: func(int) error {
+				send, ok := senderFor(ch)
+				if !ok {
+					return fmt.Errorf("no sender for channel %s", ch)
+				}
+				return send(r, msg)
+			}})
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// queueSize bounds the number of pending deliveries. Notifications beyond it are dropped.
+const queueSize = 256
+
+// retryDelays are the waits before each retry of a failed delivery
+var retryDelays = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute}
+
+// job is one delivery of an event: a message to a user on one channel, or a webhook call
+type job struct {
+	event EventType
+	// target names the recipient in logs, e.g. "email:user/3" or "webhook/5"
+	target  string
+	attempt int
+	// send makes an attempt, numbered from 1
+	send func(attempt int) error
+}
+
+var queue = make(chan job, queueSize)
+
+// enqueue adds a job without ever blocking the caller
+func enqueue(j job) {
+	select {
+	case queue <- j:
+	default:
+		slog.Warn("notify: queue is full, dropping notification", "type", j.event, "target", j.target)
+	}
+}
+
+// Start registers the email sender and delivers queued notifications until ctx is cancelled.
+// Pending and scheduled retries are dropped on shutdown.
+func Start(ctx context.Context, db *gorm.DB) {
+	RegisterSender(ChannelEmail, func(r Recipient, m Message) error {
+		return SendEmail(LoadSMTP(db), r.Email, m)
+	})
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case j := <-queue:
+				deliver(ctx, j)
+			}
+		}
+	}()
+}
+
+// deliver sends a job and schedules a retry when it fails
+func deliver(ctx context.Context, j job) {
+	logger := slog.With("type", j.event, "target", j.target, "attempt", j.attempt+1)
+	err := j.send(j.attempt + 1)
+	if err == nil {
+		logger.Debug("notify: delivered")
+		return
+	}
+	if j.attempt >= len(retryDelays) || !retryable(err) {
+		logger.Error("notify: delivery failed, giving up", "error", err)
+		return
+	}
+	delay := retryDelays[j.attempt]
+	logger.Warn("notify: delivery failed, retrying", "error", err, "retry_in", delay)
+	j.attempt++
+	time.AfterFunc(delay, func() {
+		if ctx.Err() == nil {
+			enqueue(j)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templates holds the subject, plain text and HTML body of every event type. The HTML body is
+// wrapped in htmlLayout, so it only contains the content.
+var templates = map[EventType]struct {
+	subject, text, html string
+}{
+	ServerOffline: {
+		subject: "{{.ServerName}} is offline",
+		text:    "Server {{.ServerName}} stopped responding at {{time .Time}}.{{if .Detail}}\n\nError: {{.Detail}}{{end}}",
+		html:    "<p>Server <b>{{.ServerName}}</b> stopped responding at {{time .Time}}.</p>{{if .Detail}}<pre>{{.Detail}}</pre>{{end}}",
+	},
+	ServerRecovered: {
+		subject: "{{.ServerName}} is back online",
+		text:    "Server {{.ServerName}} is reachable again since {{time .Time}}.",
+		html:    "<p>Server <b>{{.ServerName}}</b> is reachable again since {{time .Time}}.</p>",
+	},
+	ContainerCrashed: {
+		subject: "Container {{.Container}} on {{.ServerName}} crashed",
+		text:    "Container {{.Container}} on {{.ServerName}} exited unexpectedly at {{time .Time}}.{{if .Detail}}\n\n{{.Detail}}{{end}}",
+		html:    "<p>Container <b>{{.Container}}</b> on <b>{{.ServerName}}</b> exited unexpectedly at {{time .Time}}.</p>{{if .Detail}}<pre>{{.Detail}}</pre>{{end}}",
+	},
+	ImageUpdate: {
+		subject: "Image update available for {{.Container}} on {{.ServerName}}",
+		text:    "A newer version of {{.Image}} is available for container {{.Container}} on {{.ServerName}}.",
+		html:    "<p>A newer version of <code>{{.Image}}</code> is available for container <b>{{.Container}}</b> on <b>{{.ServerName}}</b>.</p>",
+	},
+	AutoUpdateSucceeded: {
+		subject: "Container {{.Container}} on {{.ServerName}} was updated",
+		text:    "Container {{.Container}} on {{.ServerName}} was updated to the latest {{.Image}} at {{time .Time}}.{{if .Detail}}\n\n{{.Detail}}{{end}}",
+		html:    "<p>Container <b>{{.Container}}</b> on <b>{{.ServerName}}</b> was updated to the latest <code>{{.Image}}</code> at {{time .Time}}.</p>{{if .Detail}}<pre>{{.Detail}}</pre>{{end}}",
+	},
+	AutoUpdateFailed: {
+		subject: "Updating container {{.Container}} on {{.ServerName}} failed",
+		text:    "The automatic update of container {{.Container}} on {{.ServerName}} to the latest {{.Image}} failed at {{time .Time}}. The container was left on its previous image.{{if .Detail}}\n\nError: {{.Detail}}{{end}}",
+		html:    "<p>The automatic update of container <b>{{.Container}}</b> on <b>{{.ServerName}}</b> to the latest <code>{{.Image}}</code> failed at {{time .Time}}. The container was left on its previous image.</p>{{if .Detail}}<pre>{{.Detail}}</pre>{{end}}",
+	},
+	Digest: {
+		subject: "Daily digest",
+		text:    "Summary for {{date .Time}}:\n{{range .Lines}}\n- {{.}}{{end}}",
+		html:    "<p>Summary for {{date .Time}}:</p><ul>{{range .Lines}}<li>{{.}}</li>{{end}}</ul>",
+	},
+	DiskFull: {
+		subject: "Disk of {{.ServerName}} is almost full",
+		text:    "The disk holding Docker's data on {{.ServerName}} is {{.Detail}} at {{time .Time}}.{{if .Lines}}\n\nLargest container logs:{{range .Lines}}\n- {{.}}{{end}}{{end}}",
+		html:    "<p>The disk holding Docker's data on <b>{{.ServerName}}</b> is {{.Detail}} at {{time .Time}}.</p>{{if .Lines}}<p>Largest container logs:</p><ul>{{range .Lines}}<li>{{.}}</li>{{end}}</ul>{{end}}",
+	},
+	AdminAction: {
+		subject: "{{.Actor}}: {{.Detail}}",
+		text:    "{{.Actor}} {{.Detail}} at {{time .Time}}.",
+		html:    "<p><b>{{.Actor}}</b> {{.Detail}} at {{time .Time}}.</p>",
+	},
+	UpdateAvailable: {
+		subject: "DockerManager {{.Version}} is available",
+		text:    "DockerManager {{.Version}} has been released{{if .Detail}}, this panel runs {{.Detail}}{{end}}.\n\nRelease notes: {{.URL}}",
+		html:    "<p>DockerManager <b>{{.Version}}</b> has been released{{if .Detail}}, this panel runs {{.Detail}}{{end}}.</p><p><a href=\"{{.URL}}\">Release notes</a></p>",
+	},
+	Test: {
+		subject: "Test message",
+		text:    "This is a test message sent at {{time .Time}}. Notification delivery works.",
+		html:    "<p>This is a test message sent at {{time .Time}}. Notification delivery works.</p>",
+	},
+}
+
+const subjectPrefix = "[DockerManager] "
+
+const htmlLayout = `<!DOCTYPE html>
+<html><body style="font-family: -apple-system, 'Segoe UI', Helvetica, Arial, sans-serif; color: #1f2937; line-height: 1.5;">
+<h2 style="font-size: 18px;">{{.Subject}}</h2>
+{{.Body}}
+<p style="color: #6b7280; font-size: 12px;">Sent by DockerManager</p>
+</body></html>
+`
+
+var funcs = map[string]interface{}{
+	"time": func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+	"date": func(t time.Time) string { return t.Format("2006-01-02") },
+}
+
+var (
+	textTemplates = template.New("text").Funcs(funcs)
+	htmlTemplates = htmltemplate.New("html").Funcs(funcs)
+	layout        = htmltemplate.Must(htmltemplate.New("layout").Parse(htmlLayout))
+)
+
+func init() {
+	for t, tpl := range templates {
+		template.Must(textTemplates.New(string(t) + ".subject").Parse(tpl.subject))
+		template.Must(textTemplates.New(string(t) + ".text").Parse(tpl.text))
+		htmltemplate.Must(htmlTemplates.New(string(t) + ".html").Parse(tpl.html))
+	}
+}
+
+// Render produces the subject and bodies of an event. A zero Time means now.
+func Render(ev Event) (Message, error) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if _, ok := templates[ev.Type]; !ok {
+		return Message{}, fmt.Errorf("no template for notification type %q", ev.Type)
+	}
+	var subject, text, body bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, string(ev.Type)+".subject", ev); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, string(ev.Type)+".text", ev); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&body, string(ev.Type)+".html", ev); err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{
+		Subject: subjectPrefix + strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+	}
+	var html bytes.Buffer
+	err := layout.Execute(&html, struct {
+		Subject string
+		Body    htmltemplate.HTML
+	}{msg.Subject, htmltemplate.HTML(body.String())})
+	if err != nil {
+		return Message{}, err
+	}
+	msg.HTML = html.String()
+	return msg, nil
+}
@@ -0,0 +1,220 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// webhookTimeout bounds a single webhook request
+	webhookTimeout = 10 * time.Second
+	// deliveriesKept is the number of recent deliveries logged per webhook
+	deliveriesKept = 50
+	// discordLimit is the maximum length of a Discord message
+	discordLimit = 2000
+)
+
+// SignatureHeader carries "sha256=<hex HMAC of the body>" when the webhook has a secret
+const SignatureHeader = "X-Signature"
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// Payload is the body of generic JSON webhooks
+type Payload struct {
+	Event      EventType `json:"event"`
+	Time       time.Time `json:"time"`
+	ServerID   uint      `json:"server_id,omitempty"`
+	ServerName string    `json:"server_name,omitempty"`
+	Container  string    `json:"container,omitempty"`
+	Image      string    `json:"image,omitempty"`
+	Actor      string    `json:"actor,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	Lines      []string  `json:"lines,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Title      string    `json:"title"`
+	Text       string    `json:"text"`
+}
+
+// permanentError marks a failure that retrying cannot fix, such as a 4xx response
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+// ValidEventType reports whether webhooks can subscribe to an event type
+func ValidEventType(t string) bool {
+	for _, e := range EventTypes {
+		if string(e) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookWants reports whether a webhook subscribed to the event. Test events always match.
+func WebhookWants(h model.Webhook, ev Event) bool {
+	if ev.Type == Test {
+		return true
+	}
+	if h.Events != "" && !hasEntry(h.Events, string(ev.Type)) {
+		return false
+	}
+	if h.ServerID != nil && ev.ServerID != 0 && *h.ServerID != ev.ServerID {
+		return false
+	}
+	return true
+}
+
+func hasEntry(list, entry string) bool {
+	for _, e := range strings.Split(list, ",") {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatchWebhooks queues the event for every enabled webhook that subscribed to it
+func dispatchWebhooks(db *gorm.DB, ev Event, msg Message) {
+	var hooks []model.Webhook
+	if err := db.Where("enabled = ?", true).Find(&hooks).Error; err != nil {
+		slog.Error("notify: failed to load webhooks", "type", ev.Type, "error", err)
+		return
+	}
+	for _, h := range hooks {
+		if !WebhookWants(h, ev) {
+			continue
+		}
+		h := h
+		enqueue(job{event: ev.Type, target: fmt.Sprintf("webhook/%d", h.ID), send: func(attempt int) error {
+			_, err := DeliverWebhook(db, h, ev, msg, attempt)
+			return err
+		}})
+	}
+}
+
+// DeliverWebhook sends the event to the webhook once and records the attempt in its delivery log
+func DeliverWebhook(db *gorm.DB, h model.Webhook, ev Event, msg Message, attempt int) (model.WebhookDelivery, error) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	d := model.WebhookDelivery{WebhookID: h.ID, Event: string(ev.Type), Attempt: attempt}
+	start := time.Now()
+	status, err := postWebhook(h, ev, msg)
+	d.StatusCode = status
+	d.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		d.Error = err.Error()
+	}
+	if dbErr := recordDelivery(db, &d); dbErr != nil {
+		slog.Warn("notify: failed to record webhook delivery", "webhook_id", h.ID, "error", dbErr)
+	}
+	return d, err
+}
+
+// postWebhook sends one request and returns the response status
+func postWebhook(h model.Webhook, ev Event, msg Message) (int, error) {
+	body, err := webhookBody(h.Format, ev, msg)
+	if err != nil {
+		return 0, permanentError{err}
+	}
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DockerManager-Webhook")
+	req.Header.Set("X-DockerManager-Event", string(ev.Type))
+	if h.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.Secret, body))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return resp.StatusCode, nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return resp.StatusCode, permanentError{err}
+	}
+	return resp.StatusCode, err
+}
+
+// Sign returns the X-Signature value of a body: the hex HMAC-SHA256 keyed with the secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBody renders the request body in the webhook's format
+func webhookBody(format string, ev Event, msg Message) ([]byte, error) {
+	switch format {
+	case model.WebhookFormatSlack:
+		return json.Marshal(map[string]string{"text": "*" + msg.Subject + "*\n" + msg.Text})
+	case model.WebhookFormatDiscord:
+		content := "**" + msg.Subject + "**\n" + msg.Text
+		if utf8.RuneCountInString(content) > discordLimit {
+			content = string([]rune(content)[:discordLimit-1]) + "…"
+		}
+		return json.Marshal(map[string]string{"content": content})
+	case model.WebhookFormatJSON, "":
+		return json.Marshal(Payload{
+			Event:      ev.Type,
+			Time:       ev.Time,
+			ServerID:   ev.ServerID,
+			ServerName: ev.ServerName,
+			Container:  ev.Container,
+			Image:      ev.Image,
+			Actor:      ev.Actor,
+			Detail:     ev.Detail,
+			Lines:      ev.Lines,
+			Version:    ev.Version,
+			URL:        ev.URL,
+			Title:      msg.Subject,
+			Text:       msg.Text,
+		})
+	}
+	return nil, fmt.Errorf("unknown webhook format %q", format)
+}
+
+// recordDelivery stores a delivery and trims the webhook's log to the newest entries
+func recordDelivery(db *gorm.DB, d *model.WebhookDelivery) error {
+	if err := db.Create(d).Error; err != nil {
+		return err
+	}
+	var ids []uint
+	err := db.Model(&model.WebhookDelivery{}).Where("webhook_id = ?", d.WebhookID).
+		Order("id DESC").Offset(deliveriesKept).Limit(1).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return db.Where("webhook_id = ? AND id <= ?", d.WebhookID, ids[0]).Delete(&model.WebhookDelivery{}).Error
+}
+
+// retryable reports whether a failed delivery should be attempted again
+func retryable(err error) bool {
+	var p permanentError
+	return !errors.As(err, &p)
+}
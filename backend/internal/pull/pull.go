@@ -0,0 +1,348 @@
+// Package pull pulls images on servers in the background, by name or of a container, optionally
+// recreating the container from the pulled image, and keeps their progress for clients to follow
+package pull
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+)
+
+const (
+	// outputLimit is how much of a pull's output is kept; older output is dropped
+	outputLimit = 1 << 20
+	// retention is how long finished pulls stay available
+	retention = time.Hour
+	// pullTimeout is how long a pull may run before it is aborted
+	pullTimeout = time.Hour
+)
+
+// Actions a job runs
+const (
+	// ActionPull pulls the image of a container
+	ActionPull = "pull"
+	// ActionUpdate pulls the image and replaces the container with one running it, see
+	// dockerapi.UpdateContainer
+	ActionUpdate = "update"
+	// ActionPullImage pulls an image by its reference, see StartImage
+	ActionPullImage = "pull_image"
+	// ActionLogConfig replaces the container with one rotating its log differently, see
+	// StartLogRotation
+	ActionLogConfig = "log_config"
+)
+
+// Job is a pull started by Start
+type Job struct {
+	mu          sync.Mutex
+	id          string
+	serverID    uint
+	containerID string
+	image       string
+	action      string
+	// rotation is what an ActionLogConfig job sets
+	rotation dockerapi.LogRotation
+	// newContainerID is the replacement of an updated container
+	newContainerID string
+	status         string
+	err            string
+	errCode        string
+	layers         []*layer
+	output         []byte
+	dropped        int64
+	startedAt      time.Time
+	finishedAt     time.Time
+	// changed is closed and replaced whenever the job changes
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// layer is the progress of a layer, in the order docker first reported them
+type layer struct {
+	id             string
+	status         string
+	current, total int64
+}
+
+var (
+	jobsMu sync.Mutex
+	jobs   = map[string]*Job{}
+)
+
+// Start runs action, ActionPull or ActionUpdate, on the container on the server
+func Start(server model.Server, containerID, action string) (*Job, error) {
+	return start(server, &Job{containerID: containerID, action: action})
+}
+
+// StartImage pulls an image by its reference on the server. The registry login stored for the
+// image's registry is used when there is one.
+func StartImage(server model.Server, image string) (*Job, error) {
+	return start(server, &Job{image: image, action: ActionPullImage})
+}
+
+// StartLogRotation replaces the container on the server with one that rotates its log as rot
+// says, see dockerapi.SetLogRotation
+func StartLogRotation(server model.Server, containerID string, rot dockerapi.LogRotation) (*Job, error) {
+	return start(server, &Job{containerID: containerID, action: ActionLogConfig, rotation: rot})
+}
+
+func start(server model.Server, job *Job) (*Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	job.id = id
+	job.serverID = server.ID
+	job.status = model.PullRunning
+	job.startedAt = time.Now()
+	job.changed = make(chan struct{})
+	job.done = make(chan struct{})
+
+	jobsMu.Lock()
+	for k, j := range jobs {
+		if j.expired() {
+			delete(jobs, k)
+		}
+	}
+	jobs[id] = job
+	jobsMu.Unlock()
+
+	go job.run(server)
+	return job, nil
+}
+
+// Get returns a pull. Callers check access to the pull's server, see ServerID.
+func Get(id string) (*Job, bool) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	job, ok := jobs[id]
+	if !ok || job.expired() {
+		return nil, false
+	}
+	return job, true
+}
+
+// ServerID returns the server the image is pulled on
+func (j *Job) ServerID() uint {
+	return j.serverID
+}
+
+// Changed returns a channel that is closed on the next change of the pull
+func (j *Job) Changed() <-chan struct{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.changed
+}
+
+// Done returns a channel that is closed once the pull is over
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Snapshot returns the pull's state with the output from offset on. Output older than the
+// kept window is skipped.
+func (j *Job) Snapshot(offset int64) model.PullTask {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if offset < j.dropped {
+		offset = j.dropped
+	}
+	total := j.dropped + int64(len(j.output))
+	if offset > total {
+		offset = total
+	}
+	snap := model.PullTask{
+		ID:             j.id,
+		ServerID:       j.serverID,
+		ContainerID:    j.containerID,
+		Image:          j.image,
+		Action:         j.action,
+		Status:         j.status,
+		Error:          j.err,
+		ErrorCode:      j.errCode,
+		Layers:         make([]model.PullLayer, len(j.layers)),
+		Output:         string(j.output[offset-j.dropped:]),
+		NextOffset:     total,
+		StartedAt:      j.startedAt,
+		NewContainerID: j.newContainerID,
+	}
+	sum := 0
+	for i, l := range j.layers {
+		snap.Layers[i] = model.PullLayer{ID: l.id, Status: l.status, Current: l.current, Total: l.total, Percent: l.percent()}
+		sum += snap.Layers[i].Percent
+	}
+	if len(j.layers) > 0 {
+		snap.Percent = sum / len(j.layers)
+	}
+	if j.status == model.PullSucceeded {
+		snap.Percent = 100
+	}
+	if !j.finishedAt.IsZero() {
+		t := j.finishedAt
+		snap.FinishedAt = &t
+	}
+	return snap
+}
+
+// progress records a progress message. Each change of a layer's status is added to the output;
+// the byte counts of downloads and extractions only update the layer.
+func (j *Job) progress(msg dockerapi.PullProgress) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	line := msg.Status
+	if msg.IsLayer() {
+		var l *layer
+		for _, known := range j.layers {
+			if known.id == msg.ID {
+				l = known
+				break
+			}
+		}
+		if l == nil {
+			l = &layer{id: msg.ID}
+			j.layers = append(j.layers, l)
+		}
+		if l.status == msg.Status {
+			line = ""
+		}
+		l.status, l.current, l.total = msg.Status, msg.ProgressDetail.Current, msg.ProgressDetail.Total
+		if line != "" {
+			line = msg.ID + ": " + line
+		}
+	} else if msg.ID != "" {
+		line = msg.ID + ": " + line
+	}
+	if line != "" {
+		j.write(line + "\n")
+	}
+	j.notify()
+}
+
+// write appends output, dropping the oldest output beyond outputLimit. j.mu must be held.
+func (j *Job) write(s string) {
+	j.output = append(j.output, s...)
+	if over := len(j.output) - outputLimit; over > 0 {
+		j.output = append([]byte(nil), j.output[over:]...)
+		j.dropped += int64(over)
+	}
+}
+
+// notify wakes up whoever waits for a change. j.mu must be held.
+func (j *Job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *Job) run(server model.Server) {
+	err := func() error {
+		backend, err := ssh.NewServerBackend(&server)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), pullTimeout)
+		defer cancel()
+		switch j.action {
+		case ActionPullImage:
+			return backend.PullImageRef(ctx, j.image, j.progress)
+		case ActionPull:
+			return backend.PullImage(ctx, j.containerID, j.progress)
+		}
+		var newID string
+		if j.action == ActionLogConfig {
+			newID, err = dockerapi.SetLogRotation(ctx, backend, j.containerID, j.rotation, j.progress)
+		} else {
+			newID, err = dockerapi.UpdateContainer(ctx, backend, j.containerID, j.progress)
+		}
+		j.mu.Lock()
+		j.newContainerID = newID
+		j.mu.Unlock()
+		return err
+	}()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	defer close(j.done)
+	defer j.notify()
+	j.finishedAt = time.Now()
+	j.status = model.PullSucceeded
+	if err != nil {
+		j.status = model.PullFailed
+		j.err = err.Error()
+		j.errCode = errorCode(j.err)
+		slog.Warn("pull: "+j.action+" failed", "server_id", server.ID, "container_id", j.containerID, "image", j.image, "error", err)
+		return
+	}
+	slog.Info("pull: "+j.action+" finished", "server_id", server.ID, "container_id", j.containerID, "image", j.image, "duration", j.finishedAt.Sub(j.startedAt))
+}
+
+// errorCode classifies the error of a failed pull by the messages of docker and the registries.
+// Docker Hub answers pulls of repositories that do not exist as if they needed a login, so
+// "repository does not exist" is checked first.
+func errorCode(msg string) string {
+	msg = strings.ToLower(msg)
+	contains := func(parts ...string) bool {
+		for _, p := range parts {
+			if strings.Contains(msg, p) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case contains("no space left on device"):
+		return model.PullErrorDiskFull
+	case contains("toomanyrequests", "rate limit"):
+		return model.PullErrorRateLimited
+	case contains("manifest unknown", "repository does not exist", "name unknown", "not found: manifest"):
+		return model.PullErrorImageNotFound
+	case contains("unauthorized", "authentication required", "access denied", "access to the resource is denied", "no basic auth credentials"):
+		return model.PullErrorUnauthorized
+	}
+	return ""
+}
+
+// expired reports whether a finished pull has passed its retention
+func (j *Job) expired() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.finishedAt.IsZero() && time.Since(j.finishedAt) > retention
+}
+
+// percent estimates how far along the layer is. Downloading takes the first 80 percent and
+// extracting the rest; without byte counts, as the CLI prints no download progress when its
+// output is not a terminal, a layer jumps between the stages.
+func (l *layer) percent() int {
+	fraction := func(share int64) int64 {
+		if l.total <= 0 {
+			return 0
+		}
+		return share * min(l.current, l.total) / l.total
+	}
+	switch {
+	case l.status == "Pull complete" || l.status == "Already exists":
+		return 100
+	case strings.HasPrefix(l.status, "Extracting"):
+		return int(80 + fraction(20))
+	case l.status == "Download complete" || l.status == "Verifying Checksum":
+		return 80
+	case strings.HasPrefix(l.status, "Downloading"):
+		return int(fraction(80))
+	default:
+		return 0
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,189 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Manifest media types of multi-platform indexes
+const (
+	mediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+	mediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// manifestTypes are the manifest formats accepted when resolving a tag. Listing the indexes
+// first makes the registry return the digest docker records in RepoDigests for multi-platform
+// images.
+var manifestTypes = []string{
+	mediaTypeOCIIndex,
+	mediaTypeManifestList,
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Platform is the platform of an image, e.g. linux/arm64/v8
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// ParsePlatform parses a platform as docker formats it, e.g. "linux/amd64" or "linux/arm/v7".
+// The OS and architecture are required and the variant is optional.
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return Platform{}, fmt.Errorf("invalid platform %q", s)
+	}
+	p := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+func (p Platform) String() string {
+	if p.Variant != "" {
+		return p.OS + "/" + p.Architecture + "/" + p.Variant
+	}
+	return p.OS + "/" + p.Architecture
+}
+
+// Digests are the manifests a tag points at
+type Digests struct {
+	// Digest is the digest of the tag's manifest, an index for multi-platform images
+	Digest string
+	// PlatformDigest is the digest of the platform's image in the index. It equals Digest for
+	// single-platform images and is empty when the index has no image for the platform.
+	PlatformDigest string
+}
+
+// ResolveDigests returns the digests the referenced tag currently points at for a platform. A
+// HEAD request for the manifest, which Docker Hub does not count against the pull rate limit,
+// resolves single-platform images; the index of multi-platform ones is read to pick the
+// platform's image.
+func ResolveDigests(ctx context.Context, ref Reference, creds *Credentials, platform Platform) (Digests, error) {
+	resp, authorization, err := manifest(ctx, http.MethodHead, ref, ref.Tag, creds, "")
+	if err != nil {
+		return Digests{}, err
+	}
+	resp.Body.Close()
+	digests := Digests{Digest: resp.Header.Get("Docker-Content-Digest")}
+	if digests.Digest == "" {
+		return Digests{}, fmt.Errorf("registry %s sent no digest for %s:%s", ref.Registry, ref.Repository, ref.Tag)
+	}
+	if !isIndex(resp.Header.Get("Content-Type")) {
+		digests.PlatformDigest = digests.Digest
+		return digests, nil
+	}
+
+	// Fetching the index by digest makes sure it is the one the HEAD request resolved
+	digests.PlatformDigest, err = platformDigest(ctx, ref, creds, authorization, digests.Digest, platform)
+	if err != nil {
+		return Digests{}, err
+	}
+	return digests, nil
+}
+
+// PlatformDigest returns the digest of the platform's image in the manifest with the given
+// digest: the image's digest from an index, the digest itself for single-platform images. It is
+// empty when the index has no image for the platform.
+func PlatformDigest(ctx context.Context, ref Reference, creds *Credentials, digest string, platform Platform) (string, error) {
+	return platformDigest(ctx, ref, creds, "", digest, platform)
+}
+
+func platformDigest(ctx context.Context, ref Reference, creds *Credentials, authorization, digest string, platform Platform) (string, error) {
+	resp, _, err := manifest(ctx, http.MethodGet, ref, digest, creds, authorization)
+	if err != nil {
+		return "", err
+	}
+	if !isIndex(resp.Header.Get("Content-Type")) {
+		resp.Body.Close()
+		return digest, nil
+	}
+	var index struct {
+		Manifests []struct {
+			Digest   string   `json:"digest"`
+			Platform Platform `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := decode(resp, &index); err != nil {
+		return "", err
+	}
+	candidates := make([]Platform, len(index.Manifests))
+	for i, m := range index.Manifests {
+		candidates[i] = m.Platform
+	}
+	if i := matchPlatform(candidates, platform); i >= 0 {
+		return index.Manifests[i].Digest, nil
+	}
+	return "", nil
+}
+
+// manifest requests the manifest of a tag or digest, answering the registry's authentication
+// challenge when authorization is empty. It returns the response with a successful status and
+// the Authorization header that was used.
+func manifest(ctx context.Context, method string, ref Reference, reference string, creds *Credentials, authorization string) (*http.Response, string, error) {
+	target := fmt.Sprintf("https://%s/v2/%s/manifests/%s", apiHost(ref.Registry), ref.Repository, reference)
+	challenged := authorization != ""
+	for {
+		req, err := http.NewRequestWithContext(ctx, method, target, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && !challenged {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			challenged = true
+			if authorization, err = authorize(ctx, challenge, ref, creds); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		if err := checkStatus(resp); err != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+			resp.Body.Close()
+			return nil, "", err
+		}
+		return resp, authorization, nil
+	}
+}
+
+func isIndex(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == mediaTypeOCIIndex || mediaType == mediaTypeManifestList
+}
+
+// matchPlatform returns the index of the candidate matching platform, -1 when none does. The
+// variant must match when both name one; otherwise a candidate without a variant is taken, since
+// indexes often leave out the default variants like v8 of arm64.
+func matchPlatform(candidates []Platform, platform Platform) int {
+	fallback := -1
+	for i, c := range candidates {
+		if c.OS != platform.OS || c.Architecture != platform.Architecture {
+			continue
+		}
+		switch {
+		case c.Variant == platform.Variant:
+			return i
+		case c.Variant == "" || platform.Variant == "":
+			if fallback < 0 {
+				fallback = i
+			}
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,174 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParsePlatform(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Platform
+	}{
+		{"linux/amd64", Platform{OS: "linux", Architecture: "amd64"}},
+		{"linux/arm/v7", Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{"linux/arm64/v8", Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		{"windows/amd64", Platform{OS: "windows", Architecture: "amd64"}},
+	} {
+		got, err := ParsePlatform(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("ParsePlatform(%q) = %+v, %v, want %+v", tc.in, got, err, tc.want)
+		}
+		if got.String() != tc.in {
+			t.Errorf("%+v formats as %q, want %q", got, got.String(), tc.in)
+		}
+	}
+
+	for _, in := range []string{"", "linux", "linux/", "/amd64", "linux//v7", "linux/arm/", "linux/arm/v7/extra", "<no value>/<no value>/"} {
+		if got, err := ParsePlatform(in); err == nil || got != (Platform{}) {
+			t.Errorf("ParsePlatform(%q) = %+v, %v, want an error", in, got, err)
+		}
+	}
+}
+
+func TestMatchPlatform(t *testing.T) {
+	candidates := []Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm", Variant: "v6"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "windows", Architecture: "amd64"},
+	}
+	for _, tc := range []struct {
+		platform Platform
+		want     int
+	}{
+		{Platform{OS: "linux", Architecture: "amd64"}, 0},
+		{Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, 2},
+		// Indexes leave out default variants
+		{Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, 3},
+		{Platform{OS: "windows", Architecture: "amd64"}, 4},
+		{Platform{OS: "linux", Architecture: "s390x"}, -1},
+		// An unknown platform matches nothing
+		{Platform{}, -1},
+	} {
+		if got := matchPlatform(candidates, tc.platform); got != tc.want {
+			t.Errorf("matchPlatform(%v) = %d, want %d", tc.platform, got, tc.want)
+		}
+	}
+}
+
+// recordedIndexes are indexes recorded from Docker Hub, one OCI index with attestation manifests
+// and one Docker manifest list
+var recordedIndexes = map[string]struct {
+	file, mediaType string
+}{
+	"library/nginx": {"testdata/nginx-oci-index.json", mediaTypeOCIIndex},
+	"library/redis": {"testdata/redis-manifest-list.json", mediaTypeManifestList},
+}
+
+// singlePlatformManifest is the digest the fake registry serves a single-platform image under
+const singlePlatformManifest = "sha256:3c4a8b1f0e9d2c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b"
+
+// fakeRegistry serves the recorded indexes under the tag "latest" and by digest, and a single
+// platform image as library/app:latest. httpClient is pointed at it for the test.
+func fakeRegistry(t *testing.T) string {
+	t.Helper()
+	indexes := map[string][]byte{}
+	digests := map[string]string{}
+	for repo, recorded := range recordedIndexes {
+		body, err := os.ReadFile(recorded.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes[repo] = body
+		digests[repo] = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repo, reference, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if repo == "library/app" && (reference == "latest" || reference == singlePlatformManifest) {
+			w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+			w.Header().Set("Docker-Content-Digest", singlePlatformManifest)
+			return
+		}
+		body, known := indexes[repo]
+		if !known || (reference != "latest" && reference != digests[repo]) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", recordedIndexes[repo].mediaType)
+		w.Header().Set("Docker-Content-Digest", digests[repo])
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	}))
+	t.Cleanup(server.Close)
+	client := httpClient
+	httpClient = server.Client()
+	t.Cleanup(func() { httpClient = client })
+	return server.Listener.Addr().String()
+}
+
+func TestResolveDigestsPicksThePlatformImage(t *testing.T) {
+	host := fakeRegistry(t)
+	for _, tc := range []struct {
+		repo, platform, want string
+	}{
+		{"library/nginx", "linux/amd64", "sha256:42de49d302127345f707bb48515f5318bc4f4f88e9dbec069926913f47d909f5"},
+		{"library/nginx", "linux/arm64/v8", "sha256:a496309fd8a493d8d95de02511a7f8376af9b2fe878cd594ea30bf477790ecbe"},
+		{"library/nginx", "linux/arm/v7", "sha256:5985cc37d123a85192a2dee973acf79c1a1153009acb5d16a437f5b7566d7f07"},
+		// Attestation manifests are listed with an unknown platform and never picked
+		{"library/nginx", "linux/riscv64", ""},
+		{"library/redis", "linux/amd64", "sha256:91b980cf5cd78c872181d6612d550e2823ba4fa5cd50944a1d9da9f4ea78d113"},
+		{"library/redis", "linux/arm64/v8", "sha256:08cee409bcf1a9cb7d89203e1ab69919e7394e97938c8fc2363ac0d0c5746238"},
+		// The index names the default variant, which docker may leave out
+		{"library/redis", "linux/arm64", "sha256:08cee409bcf1a9cb7d89203e1ab69919e7394e97938c8fc2363ac0d0c5746238"},
+		{"library/redis", "linux/arm/v6", "sha256:db5764954b10c7020196f9fa449585f945d02a695dfbfff26583c94e05c39f40"},
+		{"library/redis", "windows/amd64", "sha256:9a285e147bac3d5161127c2e7b55119f7158e2385550e9d6cc3c10fae96767bc"},
+		{"library/redis", "linux/mips64le", ""},
+	} {
+		platform, err := ParsePlatform(tc.platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref := Reference{Registry: host, Repository: tc.repo, Tag: "latest"}
+		digests, err := ResolveDigests(context.Background(), ref, nil, platform)
+		if err != nil {
+			t.Fatalf("%s for %s: %v", tc.repo, tc.platform, err)
+		}
+		if digests.PlatformDigest != tc.want {
+			t.Errorf("%s for %s = %q, want %q", tc.repo, tc.platform, digests.PlatformDigest, tc.want)
+		}
+		if !strings.HasPrefix(digests.Digest, "sha256:") || digests.Digest == digests.PlatformDigest {
+			t.Errorf("%s for %s has the index digest %q, want the digest of the index itself", tc.repo, tc.platform, digests.Digest)
+		}
+
+		// Looking the index up by digest, as done for the local image's index, gives the same
+		platformDigest, err := PlatformDigest(context.Background(), ref, nil, digests.Digest, platform)
+		if err != nil || platformDigest != tc.want {
+			t.Errorf("%s@%s for %s = %q, %v, want %q", tc.repo, digests.Digest, tc.platform, platformDigest, err, tc.want)
+		}
+	}
+}
+
+func TestResolveDigestsOfSinglePlatformImages(t *testing.T) {
+	host := fakeRegistry(t)
+	ref := Reference{Registry: host, Repository: "library/app", Tag: "latest"}
+	digests, err := ResolveDigests(context.Background(), ref, nil, Platform{OS: "linux", Architecture: "arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digests.Digest != singlePlatformManifest || digests.PlatformDigest != singlePlatformManifest {
+		t.Fatalf("digests = %+v, want %s for both", digests, singlePlatformManifest)
+	}
+}
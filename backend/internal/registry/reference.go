@@ -0,0 +1,86 @@
+// Package registry reads tag lists and manifest digests from container registries: Docker Hub
+// and any registry implementing the v2 distribution API
+package registry
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// DockerHub is the canonical name of Docker Hub in references
+const DockerHub = "docker.io"
+
+// ErrNoRepository is returned for image references that name no repository, such as image IDs
+var ErrNoRepository = errors.New("the image reference does not name a repository")
+
+var imageID = regexp.MustCompile(`^(sha256:)?[a-f0-9]{64}$`)
+
+// Reference is a parsed image reference, e.g. ghcr.io/org/app:1.2 or postgres:15
+type Reference struct {
+	// Registry is the registry host, DockerHub for images without one
+	Registry string
+	// Repository is the path within the registry, with "library/" added for official Hub images
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference splits an image reference as docker does: the first path component is a
+// registry when it contains a dot or a port or is localhost
+func ParseReference(image string) (Reference, error) {
+	image = strings.TrimSpace(image)
+	if image == "" || imageID.MatchString(image) {
+		return Reference{}, ErrNoRepository
+	}
+	var ref Reference
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+
+	ref.Registry, ref.Repository = DockerHub, name
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, ref.Repository = NormalizeHost(first), rest
+	}
+	if ref.Registry == DockerHub && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if ref.Repository == "" {
+		return Reference{}, ErrNoRepository
+	}
+	return ref, nil
+}
+
+// String formats the reference without the tag or digest, as shown by docker
+func (r Reference) String() string {
+	if r.Registry == DockerHub {
+		return strings.TrimPrefix(r.Repository, "library/")
+	}
+	return r.Registry + "/" + r.Repository
+}
+
+// NormalizeHost maps the aliases of Docker Hub to DockerHub, so that credentials stored under
+// any of them apply
+func NormalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://"), "/")
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "index.docker.io/v1", "registry.hub.docker.com":
+		return DockerHub
+	}
+	return host
+}
+
+// apiHost returns the host serving the v2 API of a registry
+func apiHost(registry string) string {
+	if registry == DockerHub {
+		return "registry-1.docker.io"
+	}
+	return registry
+}
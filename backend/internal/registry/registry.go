@@ -0,0 +1,328 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/secretbox"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxTags caps the number of tags read from one repository
+	MaxTags = 10000
+	// requestTimeout bounds each registry request
+	requestTimeout = 20 * time.Second
+	// maxResponseSize limits the size of a registry response
+	maxResponseSize = 8 << 20
+	v2PageSize      = 1000
+	hubPageSize     = 100
+)
+
+var (
+	// ErrUnauthorized is returned when the registry rejects the credentials, or requires some
+	// and none are stored
+	ErrUnauthorized = errors.New("the registry denied access to the repository")
+	// ErrNotFound is returned when the repository does not exist
+	ErrNotFound = errors.New("the repository does not exist")
+	// ErrRateLimited is returned when the registry refuses further requests for now, like Docker
+	// Hub does for anonymous pulls
+	ErrRateLimited = errors.New("the registry rate limit was exceeded")
+)
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// Tag is a tag of a repository. Digest and UpdatedAt are only known for Docker Hub repositories,
+// where the Hub API returns them with the list.
+type Tag struct {
+	Name      string
+	Digest    string
+	UpdatedAt *time.Time
+}
+
+// Credentials log in to a registry
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CredentialsFor returns the credentials for a registry host when pulling for a server: the
+// server's own stored credential, else one for all servers. It returns nil when there are none.
+func CredentialsFor(db *gorm.DB, serverID uint, registry string) *Credentials {
+	var stored []model.RegistryCredential
+	if err := db.Where("registry = ? AND (server_id = ? OR server_id IS NULL)", registry, serverID).Order("id").Find(&stored).Error; err != nil {
+		slog.Warn("failed to load registry credentials", "registry", registry, "error", err)
+		return nil
+	}
+	// Prefer the server's own credential
+	sort.SliceStable(stored, func(i, j int) bool { return stored[i].ServerID != nil && stored[j].ServerID == nil })
+	for _, cred := range stored {
+		password, err := secretbox.Open(cred.Secret)
+		if err != nil {
+			slog.Warn("failed to decrypt registry credential", "registry", registry, "id", cred.ID, "error", err)
+			continue
+		}
+		return &Credentials{Username: cred.Username, Password: password}
+	}
+	return nil
+}
+
+// DockerConfig returns a docker CLI config.json logging in to a registry host with creds
+func DockerConfig(registry string, creds Credentials) []byte {
+	// The CLI stores Docker Hub logins under the URL of its v1 index
+	if registry == DockerHub {
+		registry = "https://index.docker.io/v1/"
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Password))
+	config, _ := json.Marshal(map[string]any{"auths": map[string]any{registry: map[string]string{"auth": auth}}})
+	return config
+}
+
+// EngineAuth returns the X-Registry-Auth header of an Engine API pull from a registry host
+func EngineAuth(registry string, creds Credentials) string {
+	auth, _ := json.Marshal(map[string]string{"username": creds.Username, "password": creds.Password, "serveraddress": registry})
+	return base64.URLEncoding.EncodeToString(auth)
+}
+
+// ListTags returns the tags of the referenced repository, up to MaxTags. Public Docker Hub
+// repositories are read from the Hub API, which includes digests; everything else uses the
+// registry's v2 tags list.
+func ListTags(ctx context.Context, ref Reference, creds *Credentials) ([]Tag, error) {
+	if ref.Registry == DockerHub && creds == nil {
+		tags, hubErr := hubTags(ctx, ref)
+		if hubErr == nil {
+			return tags, nil
+		}
+		// The Hub API does not show private repositories, try the registry itself
+		tags, err := v2Tags(ctx, ref, nil)
+		if errors.Is(hubErr, ErrNotFound) && errors.Is(err, ErrUnauthorized) {
+			return nil, ErrNotFound
+		}
+		return tags, err
+	}
+	return v2Tags(ctx, ref, creds)
+}
+
+// hubTags pages through the Docker Hub API
+func hubTags(ctx context.Context, ref Reference) ([]Tag, error) {
+	next := fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/tags?page_size=%d", ref.Repository, hubPageSize)
+	tags := []Tag{}
+	for next != "" && len(tags) < MaxTags {
+		resp, err := get(ctx, next, "")
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Next    string `json:"next"`
+			Results []struct {
+				Name        string    `json:"name"`
+				Digest      string    `json:"digest"`
+				LastUpdated time.Time `json:"last_updated"`
+			} `json:"results"`
+		}
+		err = decode(resp, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.Results {
+			t := Tag{Name: r.Name, Digest: r.Digest}
+			if !r.LastUpdated.IsZero() {
+				updated := r.LastUpdated
+				t.UpdatedAt = &updated
+			}
+			tags = append(tags, t)
+		}
+		next = page.Next
+	}
+	return capTags(tags), nil
+}
+
+// v2Tags pages through the distribution API's tags list, following Link headers
+func v2Tags(ctx context.Context, ref Reference, creds *Credentials) ([]Tag, error) {
+	next := fmt.Sprintf("https://%s/v2/%s/tags/list?n=%d", apiHost(ref.Registry), ref.Repository, v2PageSize)
+	authorization := ""
+	tags := []Tag{}
+	for next != "" && len(tags) < MaxTags {
+		resp, err := get(ctx, next, authorization)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && authorization == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if authorization, err = authorize(ctx, challenge, ref, creds); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		link := resp.Header.Get("Link")
+		if err := decode(resp, &page); err != nil {
+			return nil, err
+		}
+		for _, name := range page.Tags {
+			tags = append(tags, Tag{Name: name})
+		}
+		next = nextLink(next, link)
+	}
+	return capTags(tags), nil
+}
+
+// authorize answers a WWW-Authenticate challenge with the value of the Authorization header to
+// retry with: a bearer token from the registry's token service, or basic credentials
+func authorize(ctx context.Context, challenge string, ref Reference, creds *Credentials) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds == nil {
+			return "", ErrUnauthorized
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(creds.Username, creds.Password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		realm, err := url.Parse(params["realm"])
+		if err != nil || realm.Scheme == "" {
+			return "", fmt.Errorf("invalid token realm %q", params["realm"])
+		}
+		q := realm.Query()
+		if params["service"] != "" {
+			q.Set("service", params["service"])
+		}
+		q.Set("scope", "repository:"+ref.Repository+":pull")
+		realm.RawQuery = q.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if creds != nil {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}
+		if err := decode(resp, &token); err != nil {
+			return "", err
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+		if token.Token == "" {
+			return "", ErrUnauthorized
+		}
+		return "Bearer " + token.Token, nil
+	}
+	return "", ErrUnauthorized
+}
+
+func get(ctx context.Context, rawURL, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return httpClient.Do(req)
+}
+
+// decode maps error statuses with checkStatus and otherwise decodes the JSON body into v. The
+// body is always closed.
+func decode(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+// checkStatus maps error statuses to ErrUnauthorized, ErrNotFound and ErrRateLimited
+func checkStatus(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("registry answered %s", resp.Status)
+	}
+	return nil
+}
+
+// parseChallenge splits a WWW-Authenticate header such as
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := map[string]string{}
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[strings.ToLower(strings.TrimSpace(key))] = value[1:]
+				break
+			}
+			params[strings.ToLower(strings.TrimSpace(key))] = value[1 : end+1]
+			rest = value[end+2:]
+			continue
+		}
+		value, rest, _ = strings.Cut(value, ",")
+		params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	return scheme, params
+}
+
+// nextLink resolves the rel="next" target of a Link header against the current URL
+func nextLink(current, header string) string {
+	for _, part := range strings.Split(header, ",") {
+		target, rel, _ := strings.Cut(part, ";")
+		if !strings.Contains(rel, `rel="next"`) {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		base, err := url.Parse(current)
+		if err != nil {
+			return ""
+		}
+		next, err := base.Parse(target)
+		if err != nil {
+			return ""
+		}
+		return next.String()
+	}
+	return ""
+}
+
+func capTags(tags []Tag) []Tag {
+	if len(tags) > MaxTags {
+		return tags[:MaxTags]
+	}
+	return tags
+}
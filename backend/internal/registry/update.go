@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/cache"
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotPulled is returned when no repo digest of an image is from its own repository, so it
+// cannot be compared with the registry, such as for images built on the host
+var ErrNotPulled = errors.New("the image was not pulled from its repository")
+
+// Cache for the digests tags point at, keyed by registry, repository, tag and platform, and for
+// the platform digests of pulled manifests, keyed by their digest
+var digestCache = cache.New("image_digests", "", time.Hour)
+
+// digestEntry is a tag's digests as stored in digestCache
+type digestEntry struct {
+	digests   Digests
+	checkedAt time.Time
+}
+
+// LocalDigests returns the digests of the repo digests of an image, "name@sha256:...", that
+// are from the repository of ref. Only these tell which image the tag pointed at.
+func LocalDigests(ref Reference, repoDigests []string) []string {
+	local := []string{}
+	for _, d := range repoDigests {
+		name, digest, ok := strings.Cut(d, "@")
+		if r, err := ParseReference(name); ok && err == nil && r.Registry == ref.Registry && r.Repository == ref.Repository {
+			local = append(local, digest)
+		}
+	}
+	return local
+}
+
+// CheckUpdate compares the repo digests of the image a container runs, of the given platform,
+// with the digest the tag it was created from points at in the registry. The digest is cached
+// for an hour unless refresh is set. It fails with ErrNoRepository for image IDs, ErrNotPulled
+// for images without a repo digest of their repository and the registry's errors.
+func CheckUpdate(ctx context.Context, db *gorm.DB, serverID uint, image, platformName string, repoDigests []string, refresh bool) (model.ImageUpdate, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return model.ImageUpdate{}, err
+	}
+	local := LocalDigests(ref, repoDigests)
+	if len(local) == 0 {
+		return model.ImageUpdate{}, ErrNotPulled
+	}
+	resp := model.ImageUpdate{Image: image, Platform: platformName, LocalDigests: local, CheckedAt: time.Now()}
+	// An image pinned by digest never changes
+	if ref.Digest != "" {
+		resp.RemoteDigest = ref.Digest
+		return resp, nil
+	}
+
+	platform, err := ParsePlatform(platformName)
+	if err != nil {
+		// Without the platform only the tag's own digest can be compared
+		slog.Debug("unknown image platform", "image", image, "error", err)
+	}
+	creds := CredentialsFor(db, serverID, ref.Registry)
+	key := ref.Registry + "/" + ref.Repository + ":" + ref.Tag + "@" + platform.String()
+	entry, found := digestCache.Get(key)
+	if !found || refresh {
+		digests, err := ResolveDigests(ctx, ref, creds, platform)
+		if err != nil {
+			return model.ImageUpdate{}, err
+		}
+		entry = digestEntry{digests: digests, checkedAt: time.Now()}
+		digestCache.Set(key, entry)
+	}
+	de := entry.(digestEntry)
+	resp.RemoteDigest, resp.RemotePlatformDigest, resp.CheckedAt = de.digests.Digest, de.digests.PlatformDigest, de.checkedAt
+	// Depending on how the image was pulled, its repo digest is the index's or the platform's
+	resp.HasUpdate = !slices.Contains(local, de.digests.Digest) &&
+		(de.digests.PlatformDigest == "" || !slices.Contains(local, de.digests.PlatformDigest))
+	// A rebuild for another platform changes the index too, so compare the image the local
+	// index holds for this platform. Indexes the registry no longer has count as updated.
+	if resp.HasUpdate && de.digests.PlatformDigest != "" {
+		for _, digest := range local {
+			if localPlatformDigest(ctx, ref, creds, digest, platform) == de.digests.PlatformDigest {
+				resp.HasUpdate = false
+				break
+			}
+		}
+	}
+	return resp, nil
+}
+
+// localPlatformDigest returns the digest of the platform's image in the manifest with a local
+// repo digest, empty when the registry cannot tell. Manifests never change once pushed, so the
+// answer is cached like the tags' digests.
+func localPlatformDigest(ctx context.Context, ref Reference, creds *Credentials, digest string, platform Platform) string {
+	key := ref.Registry + "/" + ref.Repository + "@" + digest + "@" + platform.String()
+	if entry, found := digestCache.Get(key); found {
+		return entry.(digestEntry).digests.PlatformDigest
+	}
+	platformDigest, err := PlatformDigest(ctx, ref, creds, digest, platform)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		slog.Debug("failed to read a local image's manifest", "registry", ref.Registry, "repository", ref.Repository, "digest", digest, "error", err)
+		return ""
+	}
+	digestCache.Set(key, digestEntry{digests: Digests{Digest: digest, PlatformDigest: platformDigest}, checkedAt: time.Now()})
+	return platformDigest
+}
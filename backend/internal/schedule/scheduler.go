@@ -0,0 +1,265 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/maintenance"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/ssh"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// pollInterval is how often due tasks are looked up
+	pollInterval = 20 * time.Second
+	// runsKept is the number of runs kept in each task's history
+	runsKept = 100
+	// outputLimit caps the output stored per run
+	outputLimit = 64 << 10
+	// taskTimeout bounds a run. Runs are not held to the SSH command timeout, since pulls and
+	// compose updates take longer.
+	taskTimeout = 30 * time.Minute
+)
+
+var composeProject = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Validate checks a task's target, type and cron expression and returns the next run after now
+func Validate(task *model.ScheduledTask, now time.Time) (time.Time, error) {
+	switch task.TargetType {
+	case model.TaskTargetContainer:
+		if !ssh.ValidContainerID(task.Target) {
+			return time.Time{}, fmt.Errorf("invalid container name %q", task.Target)
+		}
+	case model.TaskTargetCompose:
+		if !composeProject.MatchString(task.Target) {
+			return time.Time{}, fmt.Errorf("invalid compose project name %q", task.Target)
+		}
+	default:
+		return time.Time{}, fmt.Errorf("target type must be %s or %s", model.TaskTargetContainer, model.TaskTargetCompose)
+	}
+	switch task.Type {
+	case model.TaskRestart, model.TaskPull:
+	case model.TaskExec:
+		if task.TargetType != model.TaskTargetContainer {
+			return time.Time{}, errors.New("commands can only be run in a container")
+		}
+		if strings.TrimSpace(task.Command) == "" {
+			return time.Time{}, errors.New("exec tasks need a command")
+		}
+	default:
+		return time.Time{}, fmt.Errorf("task type must be %s, %s or %s", model.TaskRestart, model.TaskExec, model.TaskPull)
+	}
+	c, err := ParseCron(task.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.Next(now)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never matches", task.Cron)
+	}
+	return next, nil
+}
+
+// Scheduler executes due tasks. A task whose previous run is still going is skipped.
+type Scheduler struct {
+	db *gorm.DB
+	// onRun is called after a run that may have changed the server's containers
+	onRun func(serverID uint)
+
+	mu      sync.Mutex
+	running map[uint]bool
+}
+
+// Start runs the scheduler until ctx is cancelled. onRun, if set, is called with the server ID
+// after each finished run. The returned channel is closed once the scheduler stops polling.
+func Start(ctx context.Context, db *gorm.DB, onRun func(serverID uint)) <-chan struct{} {
+	s := &Scheduler{db: db, onRun: onRun, running: map[uint]bool{}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			s.poll(ctx, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// poll starts every enabled task that is due and moves its next run forward
+func (s *Scheduler) poll(ctx context.Context, now time.Time) {
+	var tasks []model.ScheduledTask
+	err := s.db.Where("enabled = ? AND (next_run_at IS NULL OR next_run_at <= ?)", true, now).Find(&tasks).Error
+	if err != nil {
+		slog.Error("scheduler: failed to load due tasks", "error", err)
+		return
+	}
+	for _, task := range tasks {
+		due := task.NextRunAt != nil
+		var next *time.Time
+		if c, err := ParseCron(task.Cron); err == nil {
+			if t := c.Next(now); !t.IsZero() {
+				next = &t
+			}
+		}
+		if next == nil {
+			slog.Warn("scheduler: disabling task with an unusable cron expression", "task_id", task.ID, "cron", task.Cron)
+			s.db.Model(&model.ScheduledTask{}).Where("id = ?", task.ID).Updates(map[string]interface{}{"enabled": false, "next_run_at": nil})
+			continue
+		}
+		if err := s.db.Model(&model.ScheduledTask{}).Where("id = ?", task.ID).Update("next_run_at", next).Error; err != nil {
+			slog.Error("scheduler: failed to update next run", "task_id", task.ID, "error", err)
+			continue
+		}
+		// Tasks without a next run were just enabled or created elsewhere and only get scheduled
+		if !due {
+			continue
+		}
+		if maintenance.Active() {
+			s.record(task, model.TaskRun{StartedAt: now, FinishedAt: now, Status: model.TaskRunSkipped, Error: "maintenance mode is on"})
+			continue
+		}
+
+		s.mu.Lock()
+		busy := s.running[task.ID]
+		if !busy {
+			s.running[task.ID] = true
+		}
+		s.mu.Unlock()
+		if busy {
+			s.record(task, model.TaskRun{StartedAt: now, FinishedAt: now, Status: model.TaskRunSkipped, Error: "previous run is still in progress"})
+			continue
+		}
+		go func(task model.ScheduledTask) {
+			defer func() {
+				s.mu.Lock()
+				delete(s.running, task.ID)
+				s.mu.Unlock()
+			}()
+			s.run(ctx, task)
+		}(task)
+	}
+}
+
+// run executes a task and records the outcome
+func (s *Scheduler) run(ctx context.Context, task model.ScheduledTask) {
+	run := model.TaskRun{StartedAt: time.Now(), Status: model.TaskRunSuccess}
+	output, err := s.execute(ctx, task)
+	run.FinishedAt = time.Now()
+	run.Output = truncate(output)
+	if err != nil {
+		run.Status = model.TaskRunFailed
+		run.Error = truncate(err.Error())
+		slog.Warn("scheduler: task failed", "task_id", task.ID, "server_id", task.ServerID, "error", err)
+	} else {
+		slog.Info("scheduler: task finished", "task_id", task.ID, "server_id", task.ServerID, "duration", run.FinishedAt.Sub(run.StartedAt))
+	}
+	s.record(task, run)
+	if s.onRun != nil && task.Type != model.TaskExec {
+		s.onRun(task.ServerID)
+	}
+}
+
+// execute runs the task through the same client code as the API
+func (s *Scheduler) execute(ctx context.Context, task model.ScheduledTask) (string, error) {
+	ctx, cancel := context.WithTimeout(ssh.WithoutCommandTimeout(ctx), taskTimeout)
+	defer cancel()
+
+	var server model.Server
+	if err := s.db.First(&server, task.ServerID).Error; err != nil {
+		return "", fmt.Errorf("failed to load server: %w", err)
+	}
+	target := task.Target
+	if task.TargetType != model.TaskTargetCompose && task.Type != model.TaskExec {
+		// Containers are restarted and pulled through the server's Docker backend, which also
+		// reaches servers managed over the Docker API only
+		backend, err := ssh.NewServerBackend(&server)
+		if err != nil {
+			return "", err
+		}
+		switch task.Type {
+		case model.TaskRestart:
+			return "", backend.ContainerAction(ctx, target, "restart")
+		case model.TaskPull:
+			// The container is recreated from the pulled image like an update from the API, and
+			// put back as it was when the replacement does not come up
+			var steps []string
+			_, err := dockerapi.UpdateContainer(ctx, backend, target, func(p dockerapi.PullProgress) {
+				if p.ID == "" && p.Status != "" {
+					steps = append(steps, p.Status)
+				}
+			})
+			return strings.Join(steps, "\n"), err
+		}
+		return "", fmt.Errorf("unsupported task type %s", task.Type)
+	}
+
+	client, err := ssh.NewServerClient(&server)
+	if err != nil {
+		return "", err
+	}
+	if task.TargetType == model.TaskTargetCompose {
+		project := "docker compose -p " + ssh.ShellQuote(target)
+		switch task.Type {
+		case model.TaskRestart:
+			return client.ExecuteDockerCommand(ctx, project+" restart")
+		case model.TaskPull:
+			// up needs the compose files, so run it from the project's working directory
+			out, err := client.ExecuteDockerCommand(ctx, fmt.Sprintf(`docker ps -a --filter label=com.docker.compose.project=%s --format '{{index .Labels "com.docker.compose.project.working_dir"}}' | head -n 1`, ssh.ShellQuote(target)))
+			if err != nil {
+				return out, err
+			}
+			dir := strings.TrimSpace(out)
+			if dir == "" {
+				return "", fmt.Errorf("compose project %s has no containers to find its working directory from", target)
+			}
+			return client.ExecuteDockerCommand(ctx, fmt.Sprintf(`cd %s && %s pull && %s up -d`, ssh.ShellQuote(dir), project, project))
+		}
+		return "", fmt.Errorf("unsupported task type %s", task.Type)
+	}
+	return client.ExecuteDockerCommand(ctx, "docker exec "+ssh.ShellQuote(target)+" sh -c "+ssh.ShellQuote(task.Command))
+}
+
+// record stores a run, updates the task's last result and trims its history
+func (s *Scheduler) record(task model.ScheduledTask, run model.TaskRun) {
+	run.TaskID = task.ID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ScheduledTask{}).Where("id = ?", task.ID).
+			Updates(map[string]interface{}{"last_run_at": run.StartedAt, "last_status": run.Status}).Error; err != nil {
+			return err
+		}
+		var ids []uint
+		if err := tx.Model(&model.TaskRun{}).Where("task_id = ?", task.ID).
+			Order("id DESC").Offset(runsKept).Limit(1).Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+			return err
+		}
+		return tx.Where("task_id = ? AND id <= ?", task.ID, ids[0]).Delete(&model.TaskRun{}).Error
+	})
+	if err != nil {
+		slog.Error("scheduler: failed to record task run", "task_id", task.ID, "error", err)
+	}
+}
+
+func truncate(s string) string {
+	if len(s) <= outputLimit {
+		return s
+	}
+	return s[:outputLimit] + "\n[output truncated]"
+}
@@ -0,0 +1,91 @@
+// Package secretbox encrypts credentials stored in the database with the master key from
+// DOCKERMANAGER_MASTER_KEY. Values are sealed with AES-256-GCM under a key derived from it, so
+// a copy of the database alone does not reveal them.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// prefix marks sealed values and the format they were sealed with
+const prefix = "v1:"
+
+var (
+	// ErrNoKey is returned when no master key is configured
+	ErrNoKey = errors.New("no master key is configured, set DOCKERMANAGER_MASTER_KEY")
+	// ErrDecrypt is returned for values that were sealed with another master key or are corrupt
+	ErrDecrypt = errors.New("the value cannot be decrypted with the master key")
+)
+
+var (
+	mu   sync.RWMutex
+	aead cipher.AEAD
+)
+
+// SetKey derives the encryption key from the master key. An empty master key disables
+// encryption, making Seal and Open fail with ErrNoKey.
+func SetKey(masterKey string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if masterKey == "" {
+		aead = nil
+		return nil
+	}
+	sum := sha256.Sum256([]byte("dockermanager-secretbox\x00" + masterKey))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return err
+	}
+	aead, err = cipher.NewGCM(block)
+	return err
+}
+
+// Configured reports whether a master key is set
+func Configured() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return aead != nil
+}
+
+// Seal encrypts plain, returning "v1:" followed by base64(nonce || ciphertext)
+func Seal(plain string) (string, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if aead == nil {
+		return "", ErrNoKey
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return prefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+// Open decrypts a value returned by Seal
+func Open(sealed string) (string, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if aead == nil {
+		return "", ErrNoKey
+	}
+	encoded, ok := strings.CutPrefix(sealed, prefix)
+	if !ok {
+		return "", ErrDecrypt
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,39 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+
+	"docker-pulse/internal/agent"
+)
+
+// SetAgent routes the client's commands and Docker requests through the server's agent. Terminals
+// and streamed commands still use SSH. A nil conn uses SSH for everything.
+func (s *SSHClient) SetAgent(conn *agent.Conn) {
+	s.agent = conn
+}
+
+// agentExec runs a command through the agent, bound by the command timeout like SSH commands.
+// The agent runs commands as its own user, who must be allowed to use docker, so sudo does not
+// apply.
+func (s *SSHClient) agentExec(ctx context.Context, cmd string, stdin []byte) (stdout, stderr string, err error) {
+	ctx, cancel := withCommandTimeout(ctx)
+	defer cancel()
+	return s.agent.Exec(ctx, cmd, stdin)
+}
+
+// exitError is a command that ran and failed, an *ssh.ExitError or an *agent.ExitError
+type exitError interface {
+	error
+	ExitStatus() int
+}
+
+// exitStatus returns the exit status of a command that ran and failed, over SSH or through an
+// agent. ok is false for commands that did not run to the end.
+func exitStatus(err error) (status int, ok bool) {
+	var exit exitError
+	if errors.As(err, &exit) {
+		return exit.ExitStatus(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,235 @@
+package ssh
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeHost answers the docker commands and Engine API requests of the backend tests for the
+// same two containers, and records what it was asked to do
+type fakeHost struct {
+	t  *testing.T
+	mu sync.Mutex
+	// calls are the docker commands run or the Engine API requests sent
+	calls []string
+}
+
+var fakeCreated = time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)
+
+const fakeInspect = `[
+    {
+        "Id": "abc123def4567890abc123def4567890abc123def4567890abc123def4567890",
+        "Name": "/web"
+    }
+]`
+
+func (h *fakeHost) record(call string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call)
+}
+
+func (h *fakeHost) recorded() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.calls...)
+}
+
+// exec plays docker on the host for the CLI backend
+func (h *fakeHost) exec(cmd string, ch ssh.Channel) {
+	h.record(cmd)
+	switch {
+	case cmd == "docker ps -a --format '{{json .}}'":
+		fmt.Fprintln(ch, `{"ID":"abc123def456","Names":"web","Image":"nginx:1.27","Status":"Up 2 hours (healthy)","State":"running",`+
+			`"Ports":"0.0.0.0:8080->80/tcp, [::]:8080->80/tcp","CreatedAt":"2024-03-01 12:30:45 +0000 UTC",`+
+			`"Labels":"com.docker.compose.project=shop,com.docker.compose.service=web"}`)
+		fmt.Fprintln(ch, `{"ID":"0123456789ab","Names":"worker","Image":"3f8a1b2c4d5e","Status":"Exited (1) 5 minutes ago","State":"exited",`+
+			`"Ports":"","CreatedAt":"2024-03-01 12:30:45 +0000 UTC","Labels":""}`)
+	case cmd == "docker inspect 'web'":
+		fmt.Fprintln(ch, fakeInspect)
+	case strings.Contains(cmd, "docker logs --tail '2' 'web'"):
+		// What the host prints after sed has prefixed stderr
+		io.WriteString(ch, "ready\n"+dockerapi.StderrPrefix+"warning: low memory\n")
+	case cmd == "docker start 'gone'":
+		io.WriteString(ch.Stderr(), "Error response from daemon: No such container: gone\n")
+		exit(ch, 1)
+		return
+	case strings.HasPrefix(cmd, "docker "):
+	default:
+		h.t.Errorf("unexpected command %q", cmd)
+	}
+	exit(ch, 0)
+}
+
+// engine plays the Docker socket for the API backend
+func (h *fakeHost) engine() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := r.Method + " " + r.URL.Path
+		if r.URL.RawQuery != "" {
+			call += "?" + r.URL.RawQuery
+		}
+		h.record(call)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/json":
+			json.NewEncoder(w).Encode([]map[string]any{
+				{
+					"Id": "abc123def4567890abc123def4567890abc123def4567890abc123def4567890", "Names": []string{"/web"},
+					"Image": "nginx:1.27", "Created": fakeCreated.Unix(), "State": "running", "Status": "Up 2 hours (healthy)",
+					"Ports": []map[string]any{
+						{"IP": "::", "PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"},
+						{"IP": "0.0.0.0", "PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"},
+					},
+					"Labels": map[string]string{"com.docker.compose.project": "shop", "com.docker.compose.service": "web"},
+				},
+				{
+					"Id": "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "Names": []string{"/worker"},
+					"Image": "sha256:3f8a1b2c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8", "Created": fakeCreated.Unix(),
+					"State": "exited", "Status": "Exited (1) 5 minutes ago", "Ports": []any{}, "Labels": map[string]string{},
+				},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/web/json":
+			io.WriteString(w, `{"Id": "abc123def4567890abc123def4567890abc123def4567890abc123def4567890", "Name": "/web", "Config": {"Tty": false}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/containers/web/logs":
+			w.Write(frame(1, "ready\n"))
+			w.Write(frame(2, "warning: low memory\n"))
+		case r.URL.Path == "/containers/gone/start":
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message":"No such container: gone"}`)
+		case r.URL.Path == "/containers/web/start":
+			// Starting a started container
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// frame is one frame of a multiplexed log stream
+func frame(stream byte, payload string) []byte {
+	header := make([]byte, 8, 8+len(payload))
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+// newBackendClient returns a client of the fake host in the given connection mode
+func newBackendClient(t *testing.T, mode string) (*SSHClient, *fakeHost) {
+	t.Helper()
+	host := &fakeHost{t: t}
+	engine := host.engine()
+	client := &SSHClient{Config: testClientConfig(), Addr: startTestServer(t, host.exec, engine)}
+	client.SetConnectionMode(mode)
+	return client, host
+}
+
+var connectionModes = []string{model.ConnectionModeCLI, model.ConnectionModeAPI}
+
+func TestBackendsListIdenticalContainers(t *testing.T) {
+	want := []model.Container{
+		{
+			ID: "abc123def456", Name: "web", Image: "nginx:1.27", Status: "Up 2 hours (healthy)", State: "running",
+			Health: model.HealthHealthy, Ports: []string{"0.0.0.0:8080->80/tcp", "[::]:8080->80/tcp"}, CreatedAt: fakeCreated,
+			ComposeProject: "shop", ComposeService: "web",
+			Labels: map[string]string{"com.docker.compose.project": "shop", "com.docker.compose.service": "web"},
+		},
+		{
+			ID: "0123456789ab", Name: "worker", Image: "3f8a1b2c4d5e", Status: "Exited (1) 5 minutes ago", State: "exited",
+			Health: model.HealthNone, Ports: []string{}, CreatedAt: fakeCreated, Labels: map[string]string{},
+		},
+	}
+	for _, mode := range connectionModes {
+		t.Run(mode, func(t *testing.T) {
+			client, _ := newBackendClient(t, mode)
+			containers, err := client.Docker().ListContainers(context.Background())
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			for i := range containers {
+				// Times parsed from text carry a location, which Equal ignores and DeepEqual does not
+				if containers[i].CreatedAt.Equal(fakeCreated) {
+					containers[i].CreatedAt = fakeCreated
+				}
+			}
+			if !reflect.DeepEqual(containers, want) {
+				t.Fatalf("containers =\n%+v\nwant\n%+v", containers, want)
+			}
+		})
+	}
+}
+
+func TestBackendsInspectContainer(t *testing.T) {
+	for _, mode := range connectionModes {
+		t.Run(mode, func(t *testing.T) {
+			client, _ := newBackendClient(t, mode)
+			inspect, err := client.Docker().InspectContainer(context.Background(), "web")
+			if err != nil {
+				t.Fatalf("inspect: %v", err)
+			}
+			var objects []map[string]any
+			if err := json.Unmarshal([]byte(inspect), &objects); err != nil || len(objects) != 1 || objects[0]["Name"] != "/web" {
+				t.Fatalf("inspect = %q, want docker inspect's array of the container", inspect)
+			}
+		})
+	}
+}
+
+func TestBackendsContainerActions(t *testing.T) {
+	want := map[string][]string{
+		model.ConnectionModeCLI: {"docker start 'web'", "docker stop 'web'", "docker restart 'web'", "docker pause 'web'", "docker unpause 'web'", "docker rm -f 'web'"},
+		model.ConnectionModeAPI: {"POST /containers/web/start", "POST /containers/web/stop", "POST /containers/web/restart", "POST /containers/web/pause", "POST /containers/web/unpause", "DELETE /containers/web?force=1"},
+	}
+	for _, mode := range connectionModes {
+		t.Run(mode, func(t *testing.T) {
+			client, host := newBackendClient(t, mode)
+			docker := client.Docker()
+			for _, action := range []string{"start", "stop", "restart", "pause", "unpause", "remove"} {
+				if err := docker.ContainerAction(context.Background(), "web", action); err != nil {
+					t.Fatalf("%s: %v", action, err)
+				}
+			}
+			if got := host.recorded(); !reflect.DeepEqual(got, want[mode]) {
+				t.Fatalf("calls = %q, want %q", got, want[mode])
+			}
+
+			// Docker's message is kept, whichever way it was reached
+			err := docker.ContainerAction(context.Background(), "gone", "start")
+			if err == nil || !strings.Contains(err.Error(), "No such container: gone") {
+				t.Fatalf("start of a missing container = %v, want docker's message", err)
+			}
+		})
+	}
+}
+
+func TestBackendsContainerLogs(t *testing.T) {
+	for _, mode := range connectionModes {
+		t.Run(mode, func(t *testing.T) {
+			client, _ := newBackendClient(t, mode)
+			logs, err := client.Docker().ContainerLogs(context.Background(), "web", dockerapi.LogOptions{Tail: "2"})
+			if err != nil {
+				t.Fatalf("logs: %v", err)
+			}
+			defer logs.Close()
+			out, err := io.ReadAll(logs)
+			if err != nil {
+				t.Fatalf("read logs: %v", err)
+			}
+			if want := "ready\n" + dockerapi.StderrPrefix + "warning: low memory\n"; string(out) != want {
+				t.Fatalf("logs = %q, want %q", out, want)
+			}
+		})
+	}
+}
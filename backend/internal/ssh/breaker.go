@@ -0,0 +1,161 @@
+package ssh
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Circuit states. An open circuit fails connections right away; once the cooldown has passed
+// it is half open and lets a single probe connection through, which closes or reopens it.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// ErrCircuitOpen is returned instead of connecting to a server whose circuit is open
+var ErrCircuitOpen = errors.New("server temporarily marked unreachable after repeated SSH failures")
+
+// CircuitState describes a server's circuit breaker
+type CircuitState struct {
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"`
+	RetryAt   *time.Time `json:"retry_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type breaker struct {
+	failures int
+	openedAt time.Time
+	probing  bool
+	lastErr  string
+}
+
+var (
+	breakerMu sync.Mutex
+	breakers  = map[uint]*breaker{}
+	// threshold is the number of consecutive connect failures that open a circuit, 0 disables breakers
+	threshold = 5
+	cooldown  = time.Minute
+)
+
+// ConfigureCircuits applies the breaker settings and follows changes to them
+func ConfigureCircuits(db *gorm.DB) {
+	setThreshold(strconv.Itoa(config.GetInt(db, model.ConfigKeySSHCircuitThreshold)))
+	setCooldown(strconv.Itoa(config.GetInt(db, model.ConfigKeySSHCircuitCooldown)))
+	config.OnChange(model.ConfigKeySSHCircuitThreshold, setThreshold)
+	config.OnChange(model.ConfigKeySSHCircuitCooldown, setCooldown)
+}
+
+func setThreshold(value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	threshold = n
+	if n <= 0 {
+		breakers = map[uint]*breaker{}
+	}
+}
+
+func setCooldown(value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	breakerMu.Lock()
+	cooldown = time.Duration(n) * time.Second
+	breakerMu.Unlock()
+}
+
+// allowConnect returns ErrCircuitOpen while the server's circuit is open. After the cooldown it
+// lets one probe through at a time.
+func allowConnect(serverID uint) error {
+	if serverID == 0 {
+		return nil
+	}
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	b := breakers[serverID]
+	if b == nil || threshold <= 0 || b.failures < threshold {
+		return nil
+	}
+	if time.Since(b.openedAt) < cooldown || b.probing {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// recordConnect updates the server's circuit with the result of a connection attempt
+func recordConnect(serverID uint, err error) {
+	if serverID == 0 {
+		return
+	}
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	if threshold <= 0 {
+		return
+	}
+	b := breakers[serverID]
+	if err == nil {
+		if b != nil {
+			if b.failures >= threshold {
+				slog.Info("ssh: circuit closed", "server_id", serverID)
+			}
+			delete(breakers, serverID)
+		}
+		return
+	}
+	if b == nil {
+		b = &breaker{}
+		breakers[serverID] = b
+	}
+	b.failures++
+	b.lastErr = err.Error()
+	b.probing = false
+	if b.failures >= threshold {
+		if b.failures == threshold {
+			slog.Warn("ssh: circuit opened", "server_id", serverID, "failures", b.failures, "cooldown", cooldown)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// Circuit returns the state of a server's circuit breaker
+func Circuit(serverID uint) CircuitState {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	b := breakers[serverID]
+	if b == nil {
+		return CircuitState{State: CircuitClosed}
+	}
+	st := CircuitState{State: CircuitClosed, Failures: b.failures, LastError: b.lastErr}
+	if threshold > 0 && b.failures >= threshold {
+		opened, retry := b.openedAt, b.openedAt.Add(cooldown)
+		st.OpenedAt, st.RetryAt = &opened, &retry
+		st.State = CircuitOpen
+		if b.probing || !time.Now().Before(retry) {
+			st.State = CircuitHalfOpen
+		}
+	}
+	return st
+}
+
+// ResetCircuit closes a server's circuit, e.g. after the host was fixed
+func ResetCircuit(serverID uint) {
+	breakerMu.Lock()
+	delete(breakers, serverID)
+	breakerMu.Unlock()
+}
@@ -0,0 +1,71 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// BuildImage extracts a gzipped tar build context read from buildContext into a temporary
+// directory on the host and runs docker build there, writing the build output to output. The
+// directory is removed when the script exits, whether the build succeeded or not.
+func (s *SSHClient) BuildImage(ctx context.Context, buildContext io.Reader, tag string, buildArgs map[string]string, output io.Writer) (err error) {
+	defer s.track("build_image", time.Now(), &err)
+	session, client, err := s.CreateSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	defer client.Close()
+
+	names := make([]string, 0, len(buildArgs))
+	for name := range buildArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var args strings.Builder
+	for _, name := range names {
+		args.WriteString(" --build-arg " + ShellQuote(name+"="+buildArgs[name]))
+	}
+	script := `set -e; d=$(mktemp -d /tmp/dockermanager-build.XXXXXX); trap 'rm -rf "$d"' EXIT; trap 'exit 1' HUP INT TERM; ` +
+		`tar -xzf - -C "$d"; ` +
+		fmt.Sprintf(`BUILDKIT_PROGRESS=plain docker build -t %s%s "$d" 2>&1`, ShellQuote(tag), args.String())
+
+	session.Stdin = buildContext
+	session.Stdout = output
+	session.Stderr = output
+	// Builds may take longer than the command timeout
+	return client.run(WithoutCommandTimeout(ctx), session, s.sudo(session, script))
+}
+
+// ListImages returns the host's images. Images without a repo digest were built or tagged on
+// the host rather than pulled.
+func (s *SSHClient) ListImages(ctx context.Context) (_ []model.ImageSummary, err error) {
+	defer s.track("list_images", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, `docker image ls --digests --format '{{.ID}}|{{.Repository}}|{{.Tag}}|{{.Digest}}|{{.Size}}|{{.CreatedAt}}'`, nil)
+	if err != nil {
+		return nil, err
+	}
+	images := []model.ImageSummary{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		parts := strings.SplitN(line, "|", 6)
+		if len(parts) < 6 {
+			continue
+		}
+		images = append(images, model.ImageSummary{
+			ID:           parts[0],
+			Repository:   parts[1],
+			Tag:          parts[2],
+			Digest:       strings.TrimPrefix(parts[3], "<none>"),
+			Size:         parts[4],
+			CreatedAt:    parts[5],
+			BuiltLocally: parts[3] == "<none>",
+		})
+	}
+	return images, nil
+}
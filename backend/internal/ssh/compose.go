@@ -0,0 +1,83 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// ErrComposeFileNotFound is returned for config files of a compose project that no longer exist
+var ErrComposeFileNotFound = errors.New("the compose file does not exist")
+
+// composeBinary sets $C to the docker compose plugin or, on hosts without it, the standalone
+// docker-compose
+const composeBinary = `if docker compose version >/dev/null 2>&1; then C="docker compose"; else C=docker-compose; fi; `
+
+// ComposeAction runs one of the model.Compose actions on a project, from its working directory
+// and with its config files when they are known, and returns what docker compose printed. A
+// command that ran and failed is not an error: its exit status is returned with the output.
+func (s *SSHClient) ComposeAction(ctx context.Context, project model.ComposeProject, action string) (_ string, exitCode int, err error) {
+	defer s.track("compose_"+action, time.Now(), &err)
+	compose := "$C -p " + ShellQuote(project.Name)
+	for _, f := range project.ConfigFiles {
+		compose += " -f " + ShellQuote(f)
+	}
+	var cmd string
+	switch action {
+	case model.ComposeUp:
+		cmd = compose + " up -d"
+	case model.ComposeDown:
+		cmd = compose + " down"
+	case model.ComposeRestart:
+		cmd = compose + " restart"
+	case model.ComposePull:
+		cmd = compose + " pull && " + compose + " up -d"
+	default:
+		return "", 0, fmt.Errorf("unsupported compose action %s", action)
+	}
+	if project.WorkingDir != "" {
+		// Relative paths in the config files, such as build contexts and env files, are
+		// resolved from the working directory
+		cmd = "cd " + ShellQuote(project.WorkingDir) + " && " + cmd
+	}
+
+	// Compose prints its progress on stderr. Pulls and recreating containers can take longer
+	// than the command timeout.
+	output, err := s.runDockerScript(WithoutCommandTimeout(ctx), "{ "+composeBinary+cmd+"; } 2>&1", nil)
+	if status, ok := exitStatus(err); ok {
+		return output, status, nil
+	}
+	return output, 0, err
+}
+
+// ReadComposeFile returns the content of a compose project's config file
+func (s *SSHClient) ReadComposeFile(ctx context.Context, path string) (_ string, err error) {
+	defer s.track("read_compose_file", time.Now(), &err)
+	script := fmt.Sprintf(`test -f %[1]s || exit %[2]d; cat %[1]s`, ShellQuote(path), missingExit)
+	output, err := s.runDockerScript(ctx, script, nil)
+	if status, ok := exitStatus(err); ok && status == missingExit {
+		return "", ErrComposeFileNotFound
+	}
+	return output, err
+}
+
+// WriteComposeFile replaces a compose project's config file with content, first copying it to a
+// timestamped backup next to it, and returns the backup's path. The file keeps its owner and
+// mode.
+func (s *SSHClient) WriteComposeFile(ctx context.Context, path string, content []byte) (backup string, err error) {
+	defer s.track("write_compose_file", time.Now(), &err)
+	backup = path + ".bak-" + time.Now().UTC().Format("20060102-150405")
+	script := fmt.Sprintf(`f=%s; b=%s; test -f "$f" || exit %d; `+
+		`cp -p "$f" "$b" && cp -p "$f" "$f.tmp" && cat > "$f.tmp" && mv "$f.tmp" "$f"`, ShellQuote(path), ShellQuote(backup), missingExit)
+	_, err = s.runDockerScript(ctx, script, content)
+	if status, ok := exitStatus(err); ok && status == missingExit {
+		return "", ErrComposeFileNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return backup, nil
+}
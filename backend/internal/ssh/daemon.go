@@ -0,0 +1,89 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DaemonConfigPath is where dockerd reads its configuration
+const DaemonConfigPath = "/etc/docker/daemon.json"
+
+// missingExit is the exit status scripts use to report that the daemon configuration does not exist
+const missingExit = 3
+
+// ReadDaemonConfig returns the content of daemon.json. exists is false when the file is missing,
+// which leaves dockerd on its defaults.
+func (s *SSHClient) ReadDaemonConfig(ctx context.Context) (content string, exists bool, err error) {
+	defer s.track("read_daemon_config", time.Now(), &err)
+	script := asRoot + fmt.Sprintf(`$S test -f %[1]s || exit %[2]d; $S cat %[1]s`, DaemonConfigPath, missingExit)
+	output, err := s.runDockerScript(ctx, script, nil)
+	if status, ok := exitStatus(err); ok && status == missingExit {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return output, true, nil
+}
+
+// WriteDaemonConfig replaces daemon.json with content, first copying the current file to a
+// timestamped backup next to it. The returned backup path is empty when there was no file to
+// back up.
+func (s *SSHClient) WriteDaemonConfig(ctx context.Context, content []byte) (backup string, err error) {
+	defer s.track("write_daemon_config", time.Now(), &err)
+	backup = DaemonConfigPath + ".bak-" + time.Now().UTC().Format("20060102-150405")
+	script := asRoot + fmt.Sprintf(`if $S test -f %[1]s; then $S cp -p %[1]s %[2]s || exit 1; echo %[2]s; fi; `+
+		`$S mkdir -p /etc/docker && $S tee %[1]s.tmp >/dev/null && $S mv %[1]s.tmp %[1]s`, DaemonConfigPath, backup)
+	output, err := s.runDockerScript(ctx, script, content)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// RestoreDaemonConfig puts a backup made by WriteDaemonConfig back in place. An empty backup
+// removes daemon.json, since there was none before.
+func (s *SSHClient) RestoreDaemonConfig(ctx context.Context, backup string) (err error) {
+	defer s.track("restore_daemon_config", time.Now(), &err)
+	script := asRoot + fmt.Sprintf(`$S rm -f %s`, DaemonConfigPath)
+	if backup != "" {
+		script = asRoot + fmt.Sprintf(`$S cp -p %s %s`, ShellQuote(backup), DaemonConfigPath)
+	}
+	_, err = s.runDockerScript(ctx, script, nil)
+	return err
+}
+
+// RestartDocker restarts the docker service with systemctl, or the service command on hosts
+// without systemd. Failed states are reset first so that systemd's start limit does not block
+// the restart after a rollback.
+func (s *SSHClient) RestartDocker(ctx context.Context) (err error) {
+	defer s.track("restart_docker", time.Now(), &err)
+	script := asRoot + `if command -v systemctl >/dev/null 2>&1; then $S systemctl reset-failed docker 2>/dev/null; $S systemctl restart docker; else $S service docker restart; fi`
+	// Stopping every container can take longer than the command timeout
+	_, err = s.runDockerScript(WithoutCommandTimeout(ctx), script, nil)
+	return err
+}
+
+// WaitForDocker polls "docker info" until the daemon answers, the timeout passes or ctx is done,
+// returning the daemon's version
+func (s *SSHClient) WaitForDocker(ctx context.Context, timeout time.Duration) (version string, err error) {
+	defer s.track("wait_docker", time.Now(), &err)
+	deadline := time.Now().Add(timeout)
+	for {
+		var output string
+		output, err = s.runDockerScript(ctx, `docker info --format '{{.ServerVersion}}'`, nil)
+		if err == nil {
+			return strings.TrimSpace(output), nil
+		}
+		if time.Now().After(deadline) {
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+)
+
+// Docker versions without --format for "docker system df" reject the flag, and the table
+// output is parsed instead
+const (
+	diskUsageScript        = `docker system df --format '{{json .}}' 2>/dev/null || docker system df`
+	diskUsageVerboseScript = `docker system df -v --format '{{json .}}' 2>/dev/null || docker system df -v`
+	// diskUsageSeparator separates the totals from the verbose output
+	diskUsageSeparator = "--- verbose ---"
+)
+
+// GetDiskUsage returns the space taken by docker's images, containers, volumes and build cache.
+// verbose also lists every image and volume, which takes longer as docker sizes each volume.
+func (s *SSHClient) GetDiskUsage(ctx context.Context, verbose bool) (_ model.DockerDiskUsage, err error) {
+	defer s.track("disk_usage", time.Now(), &err)
+	script := diskUsageScript
+	if verbose {
+		script += fmt.Sprintf("; echo '%s'; %s", diskUsageSeparator, diskUsageVerboseScript)
+	}
+	output, err := s.runDockerScript(ctx, script, nil)
+	if err != nil {
+		return model.DockerDiskUsage{}, err
+	}
+	totals, details, _ := strings.Cut(output, diskUsageSeparator+"\n")
+
+	usage := model.DockerDiskUsage{Types: []model.DockerDiskUsageType{}}
+	for _, row := range parseDiskUsageTotals(totals) {
+		t := model.DockerDiskUsageType{
+			Type:             row.get("TYPE"),
+			TotalCount:       row.count("TOTALCOUNT", "TOTAL"),
+			Active:           row.count("ACTIVE"),
+			SizeBytes:        row.size("SIZE"),
+			ReclaimableBytes: row.size("RECLAIMABLE"),
+		}
+		usage.Types = append(usage.Types, t)
+		usage.TotalBytes += t.SizeBytes
+		usage.ReclaimableBytes += t.ReclaimableBytes
+	}
+	if !verbose {
+		return usage, nil
+	}
+
+	lists := parseDiskUsageDetails(details)
+	usage.Images = []model.ImageDiskUsage{}
+	for _, row := range lists.images {
+		usage.Images = append(usage.Images, model.ImageDiskUsage{
+			ID:              row.get("ID", "IMAGEID"),
+			Repository:      row.get("REPOSITORY"),
+			Tag:             row.get("TAG"),
+			SizeBytes:       row.size("SIZE"),
+			SharedSizeBytes: row.size("SHAREDSIZE"),
+			UniqueSizeBytes: row.size("UNIQUESIZE"),
+			Containers:      row.count("CONTAINERS"),
+		})
+	}
+	usage.Volumes = []model.VolumeDiskUsage{}
+	for _, row := range lists.volumes {
+		usage.Volumes = append(usage.Volumes, model.VolumeDiskUsage{
+			Name:      row.get("NAME", "VOLUMENAME"),
+			Links:     row.count("LINKS"),
+			SizeBytes: row.size("SIZE"),
+		})
+	}
+	return usage, nil
+}
+
+// dfRow is a row of "docker system df" output, keyed by the upper-case field or column name
+// without spaces, so that the JSON field "SharedSize" and the column "SHARED SIZE" match
+type dfRow map[string]string
+
+// get returns the first of the fields the row has
+func (r dfRow) get(keys ...string) string {
+	for _, key := range keys {
+		if v, ok := r[key]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// count parses a count; "N/A" and missing fields are 0
+func (r dfRow) count(keys ...string) int {
+	n, _ := strconv.Atoi(r.get(keys...))
+	return n
+}
+
+// size parses a size such as "1.2GB", or "1.2GB (50%)" for reclaimable space
+func (r dfRow) size(keys ...string) int64 {
+	size, _, _ := strings.Cut(r.get(keys...), " ")
+	return dockerapi.ParseByteSize(size)
+}
+
+func dfKey(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, " ", ""))
+}
+
+// dfJSONRow converts an object docker printed with {{json .}}. Docker prints counts and sizes
+// as strings, but numbers are accepted too.
+func dfJSONRow(fields map[string]any) dfRow {
+	row := dfRow{}
+	for k, v := range fields {
+		if v != nil {
+			row[dfKey(k)] = fmt.Sprint(v)
+		}
+	}
+	return row
+}
+
+// parseDiskUsageTotals parses the output of "docker system df", a JSON object per line or the
+// table of older docker versions
+func parseDiskUsageTotals(output string) []dfRow {
+	output = strings.TrimSpace(output)
+	if !strings.HasPrefix(output, "{") {
+		return parseDFTable(strings.Split(output, "\n"))
+	}
+	var rows []dfRow
+	for _, line := range strings.Split(output, "\n") {
+		var fields map[string]any
+		if json.Unmarshal([]byte(line), &fields) == nil {
+			rows = append(rows, dfJSONRow(fields))
+		}
+	}
+	return rows
+}
+
+// dfDetails are the lists of "docker system df -v"
+type dfDetails struct {
+	images, containers, volumes []dfRow
+}
+
+// parseDiskUsageDetails parses the output of "docker system df -v", a single JSON object or,
+// on older docker versions, a table per section
+func parseDiskUsageDetails(output string) dfDetails {
+	output = strings.TrimSpace(output)
+	if strings.HasPrefix(output, "{") {
+		var lists struct {
+			Images, Containers, Volumes []map[string]any
+		}
+		json.Unmarshal([]byte(output), &lists)
+		rows := func(list []map[string]any) []dfRow {
+			var rows []dfRow
+			for _, fields := range list {
+				rows = append(rows, dfJSONRow(fields))
+			}
+			return rows
+		}
+		return dfDetails{images: rows(lists.Images), containers: rows(lists.Containers), volumes: rows(lists.Volumes)}
+	}
+
+	// Sections start with a title such as "Images space usage:" followed by a table
+	sections := map[string][]string{}
+	var section string
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasSuffix(line, " space usage:"):
+			section = strings.TrimSuffix(line, " space usage:")
+		case strings.HasPrefix(line, "Build cache usage:"):
+			section = "Build cache"
+		case strings.TrimSpace(line) != "":
+			sections[section] = append(sections[section], line)
+		}
+	}
+	return dfDetails{
+		images:     parseDFTable(sections["Images"]),
+		containers: parseDFTable(sections["Containers"]),
+		volumes:    parseDFTable(sections["Local Volumes"]),
+	}
+}
+
+// parseDFTable parses a table docker aligned with a tabwriter. Columns are at least three
+// spaces apart while column names such as "IMAGE ID" contain single spaces, so a column starts
+// where the header has text after two or more spaces, and its cells are at the same offset.
+func parseDFTable(lines []string) []dfRow {
+	if len(lines) < 2 {
+		return nil
+	}
+	header := []rune(lines[0])
+	var starts []int
+	for i, r := range header {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if i == 0 || (i >= 2 && unicode.IsSpace(header[i-1]) && unicode.IsSpace(header[i-2])) {
+			starts = append(starts, i)
+		}
+	}
+	cell := func(line []rune, col int) string {
+		start, end := starts[col], len(line)
+		if col+1 < len(starts) {
+			end = min(starts[col+1], len(line))
+		}
+		if start >= end {
+			return ""
+		}
+		return strings.TrimSpace(string(line[start:end]))
+	}
+
+	names := make([]string, len(starts))
+	for col := range starts {
+		names[col] = dfKey(cell(header, col))
+	}
+	var rows []dfRow
+	for _, line := range lines[1:] {
+		runes := []rune(line)
+		row := dfRow{}
+		for col, name := range names {
+			row[name] = cell(runes, col)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
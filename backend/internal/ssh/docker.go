@@ -0,0 +1,315 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"docker-pulse/internal/agent"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// dockerSocket is where the Docker Engine API listens on the host
+const dockerSocket = "/var/run/docker.sock"
+
+// ErrNoSSH is returned for servers that are reached over the Docker API only, which offer
+// container operations but nothing that runs commands on the host
+var ErrNoSSH = errors.New("the server is managed over the Docker API without SSH")
+
+// NewServerBackend returns the Docker backend of a stored server: the Engine API through its agent
+// while it is connected, over TCP with mutual TLS for docker-tls servers, otherwise the backend
+// of its SSH client
+func NewServerBackend(server *model.Server) (dockerapi.Backend, error) {
+	if conn := agent.Lookup(server.ID); conn != nil {
+		client := conn.Docker()
+		applyLimits(client, server.ID)
+		return client, nil
+	}
+	if server.AuthMode == model.AuthModeDockerTLS {
+		return NewDockerTLSClient(server)
+	}
+	client, err := NewServerClient(server)
+	if err != nil {
+		return nil, err
+	}
+	return client.Docker(), nil
+}
+
+// NewDockerTLSClient returns an Engine API client for a docker-tls server, bound by the same
+// command timeout and stream limits as SSH commands
+func NewDockerTLSClient(server *model.Server) (*dockerapi.Client, error) {
+	client, err := dockerapi.NewTLS(net.JoinHostPort(server.IP, strconv.Itoa(server.Port)), server.Secret, server.ID)
+	if err != nil {
+		return nil, err
+	}
+	applyLimits(client, server.ID)
+	return client, nil
+}
+
+// RealtimeStats reads the current stats of a stored server. Servers reached over the Docker API
+// only report the engine's version, their containers and the latency, as host metrics need SSH.
+func RealtimeStats(ctx context.Context, server *model.Server, pingTargets string) (_ *ServerStats, err error) {
+	if server.AuthMode != model.AuthModeDockerTLS {
+		client, err := NewServerClient(server)
+		if err != nil {
+			return nil, err
+		}
+		return client.GetServerRealtimeStats(ctx, pingTargets)
+	}
+	defer trackServer(server.ID, "realtime_stats", time.Now(), &err)
+	client, err := NewDockerTLSClient(server)
+	if err != nil {
+		return nil, err
+	}
+	stats := &ServerStats{Status: StatusOffline}
+	stats.measureLatency(ctx, pingTargets, net.JoinHostPort(server.IP, strconv.Itoa(server.Port)))
+	info, err := client.Info(ctx)
+	if err != nil {
+		stats.StatusDetail = err.Error()
+		return stats, nil
+	}
+	stats.Status = StatusOnline
+	stats.DockerVersion = info.ServerVersion
+	stats.RunningContainers, stats.TotalContainers = info.ContainersRunning, info.Containers
+	return stats, nil
+}
+
+// applyLimits makes an Engine API client follow the command timeout and stream limits, record
+// its operations like SSH operations and pull with the stored registry credentials
+func applyLimits(client *dockerapi.Client, serverID uint) {
+	client.Track = func(op string, start time.Time, err *error) {
+		trackServer(serverID, op, start, err)
+	}
+	client.Bound = withCommandTimeout
+	client.StreamLimits = func() (int64, time.Duration) {
+		return streamMaxBytes.Load(), time.Duration(streamMaxDuration.Load())
+	}
+	client.RegistryAuth = engineRegistryAuth(serverID)
+}
+
+// SetConnectionMode selects the backend Docker returns, model.ConnectionModeCLI or
+// model.ConnectionModeAPI
+func (s *SSHClient) SetConnectionMode(mode string) {
+	s.connectionMode = mode
+}
+
+// Docker returns the backend of the client's connection mode, or the Engine API through the
+// server's agent while it is connected. In the API mode every request opens a channel to the
+// host's Docker socket, which takes a session slot like a command does. The SSH user must be
+// allowed to open the socket, as sudo does not apply to it.
+func (s *SSHClient) Docker() dockerapi.Backend {
+	if s.agent != nil {
+		client := s.agent.Docker()
+		applyLimits(client, s.ServerID)
+		return client
+	}
+	if s.connectionMode == model.ConnectionModeAPI {
+		client := dockerapi.New(&http.Transport{
+			DialContext:       s.dialDockerSocket,
+			DisableKeepAlives: true,
+		}, "http://docker", s.ServerID)
+		applyLimits(client, s.ServerID)
+		return client
+	}
+	return cliBackend{s}
+}
+
+// cliBackend runs docker commands over SSH and parses their output
+type cliBackend struct {
+	s *SSHClient
+}
+
+func (b cliBackend) ListContainers(ctx context.Context) ([]model.Container, error) {
+	output, err := b.s.GetContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dockerapi.ParseContainers(output, b.s.ServerID), nil
+}
+
+func (b cliBackend) InspectContainer(ctx context.Context, containerID string) (string, error) {
+	return b.s.GetContainerDetails(ctx, containerID)
+}
+
+func (b cliBackend) ContainerAction(ctx context.Context, containerID, action string) error {
+	return b.s.ExecuteContainerAction(ctx, containerID, action)
+}
+
+func (b cliBackend) KillContainer(ctx context.Context, containerID, signal string) error {
+	return b.s.KillContainer(ctx, containerID, signal)
+}
+
+func (b cliBackend) PullImage(ctx context.Context, containerID string, progress func(dockerapi.PullProgress)) error {
+	return pullProgress(progress, func(output io.Writer) error {
+		return b.s.PullImageByContainer(ctx, containerID, output)
+	})
+}
+
+func (b cliBackend) PullImageRef(ctx context.Context, image string, progress func(dockerapi.PullProgress)) error {
+	return pullProgress(progress, func(output io.Writer) error {
+		return b.s.PullImageRef(ctx, image, output)
+	})
+}
+
+// pullProgress runs a pull writing what docker prints to a pullWriter for progress, or to nowhere
+// without one
+func pullProgress(progress func(dockerapi.PullProgress), pull func(output io.Writer) error) error {
+	if progress == nil {
+		return pull(nil)
+	}
+	w := &pullWriter{progress: progress}
+	err := pull(w)
+	w.flush()
+	return err
+}
+
+func (b cliBackend) InspectImage(ctx context.Context, image string) (string, error) {
+	return b.s.InspectImage(ctx, image)
+}
+
+func (b cliBackend) RenameContainer(ctx context.Context, containerID, name string) error {
+	return b.s.RenameContainer(ctx, containerID, name)
+}
+
+func (b cliBackend) UpdateRestartPolicy(ctx context.Context, containerID string, policy model.RestartPolicy) error {
+	return b.s.UpdateRestartPolicy(ctx, containerID, policy)
+}
+
+func (b cliBackend) CreateContainer(ctx context.Context, cfg dockerapi.RunConfig) (string, error) {
+	return b.s.CreateContainer(ctx, cfg)
+}
+
+func (b cliBackend) ContainerLogs(ctx context.Context, containerID string, opts dockerapi.LogOptions) (io.ReadCloser, error) {
+	return b.s.GetContainerLogs(ctx, containerID, opts)
+}
+
+func (b cliBackend) FollowContainerLogs(ctx context.Context, containerID, tail string) (io.ReadCloser, error) {
+	return b.s.FollowContainerLogs(ctx, containerID, tail)
+}
+
+func (b cliBackend) ContainerTop(ctx context.Context, containerID string) (dockerapi.Top, error) {
+	return b.s.GetContainerTop(ctx, containerID)
+}
+
+func (b cliBackend) Events(ctx context.Context) (io.ReadCloser, error) {
+	return b.s.GetEvents(ctx)
+}
+
+func (b cliBackend) ContainerStats(ctx context.Context) (map[string]dockerapi.Usage, error) {
+	output, err := b.s.GetContainerStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dockerapi.ParseStats(output), nil
+}
+
+func (b cliBackend) ContainerSamples(ctx context.Context, containerID string, samples int, interval time.Duration) ([]dockerapi.Sample, error) {
+	return b.s.GetContainerSamples(ctx, containerID, samples, interval)
+}
+
+// pullWriter passes each line "docker pull" prints to progress as it arrives
+type pullWriter struct {
+	progress func(dockerapi.PullProgress)
+	partial  []byte
+}
+
+func (w *pullWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if msg, ok := dockerapi.ParsePullLine(string(w.partial[:i])); ok {
+			w.progress(msg)
+		}
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush passes on a last line without a newline
+func (w *pullWriter) flush() {
+	if msg, ok := dockerapi.ParsePullLine(string(w.partial)); ok {
+		w.progress(msg)
+	}
+	w.partial = nil
+}
+
+// dialDockerSocket opens a channel to the host's Docker socket on the server's pooled or a
+// dedicated connection. The channel holds a session slot until it is closed.
+func (s *SSHClient) dialDockerSocket(ctx context.Context, _, _ string) (_ net.Conn, err error) {
+	defer s.track("connect", time.Now(), &err)
+	release, err := acquireSession(ctx, s.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.connect()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	channel, err := s.openSocket(conn)
+	var refused *ssh.OpenChannelError
+	if err != nil && conn.pooled != nil && !errors.Is(err, errSessionTimeout) && !errors.As(err, &refused) {
+		// The pooled connection died
+		conn.discard()
+		if conn, err = s.connect(); err == nil {
+			channel, err = s.openSocket(conn)
+		}
+	}
+	if err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		release()
+		return nil, fmt.Errorf("failed to open the Docker socket %s: %w", dockerSocket, err)
+	}
+	conn.release = release
+	return &socketConn{Conn: channel, conn: conn}, nil
+}
+
+// openSocket opens a channel to the Docker socket on conn. When the server does not answer
+// within the connect timeout the connection is closed and errSessionTimeout returned.
+func (s *SSHClient) openSocket(conn *Conn) (net.Conn, error) {
+	type opened struct {
+		channel net.Conn
+		err     error
+	}
+	done := make(chan opened, 1)
+	go func() {
+		channel, err := conn.client.Dial("unix", dockerSocket)
+		done <- opened{channel, err}
+	}()
+	select {
+	case o := <-done:
+		return o.channel, o.err
+	case <-time.After(s.Config.Timeout):
+		conn.abandon()
+		if o := <-done; o.channel != nil {
+			o.channel.Close()
+		}
+		return nil, errSessionTimeout
+	}
+}
+
+// socketConn is a channel to the Docker socket that releases its connection when closed
+type socketConn struct {
+	net.Conn
+	conn *Conn
+}
+
+func (c *socketConn) Close() error {
+	err := c.Conn.Close()
+	c.conn.Close()
+	return err
+}
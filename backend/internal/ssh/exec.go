@@ -0,0 +1,93 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// abortGrace is how long an aborted command's session gets to close before its connection is
+// given up on
+const abortGrace = 5 * time.Second
+
+var (
+	timeoutMu sync.Mutex
+	// commandTimeout bounds every command whose context does not opt out with WithoutCommandTimeout
+	commandTimeout = 30 * time.Second
+)
+
+// unboundedKey marks contexts created by WithoutCommandTimeout
+type unboundedKey struct{}
+
+// ConfigureCommands applies the command timeout setting and follows changes to it
+func ConfigureCommands(db *gorm.DB) {
+	setCommandTimeout(strconv.Itoa(config.GetInt(db, model.ConfigKeySSHCommandTimeout)))
+	config.OnChange(model.ConfigKeySSHCommandTimeout, setCommandTimeout)
+}
+
+func setCommandTimeout(value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return
+	}
+	timeoutMu.Lock()
+	commandTimeout = time.Duration(n) * time.Second
+	timeoutMu.Unlock()
+}
+
+// WithoutCommandTimeout returns a context whose commands are only bounded by ctx itself, for
+// commands such as image pulls and builds that may legitimately run longer than the command
+// timeout
+func WithoutCommandTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unboundedKey{}, true)
+}
+
+// withCommandTimeout bounds ctx by the command timeout, unless it comes from WithoutCommandTimeout
+func withCommandTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx.Value(unboundedKey{}) != nil {
+		return context.WithCancel(ctx)
+	}
+	timeoutMu.Lock()
+	timeout := commandTimeout
+	timeoutMu.Unlock()
+	return context.WithTimeout(ctx, timeout)
+}
+
+// run runs cmd on session until it exits, ctx is done or the command timeout passes. A command
+// that is cut short is sent SIGKILL and its session closed. When the host does not confirm that
+// within abortGrace it is considered stalled and the connection is closed, so run never returns
+// while the session still writes to its outputs.
+func (c *Conn) run(ctx context.Context, session *ssh.Session, cmd string) error {
+	ctx, cancel := withCommandTimeout(ctx)
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("command not started: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(cmd)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		select {
+		case <-done:
+		case <-time.After(abortGrace):
+			c.abandon()
+			<-done
+		}
+		return fmt.Errorf("command aborted: %w", ctx.Err())
+	}
+}
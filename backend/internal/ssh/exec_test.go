@@ -0,0 +1,218 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// execHandler answers an exec request on a fake server's session channel
+type execHandler func(cmd string, ch ssh.Channel)
+
+// newTestConn starts an in-process SSH server that hands every exec request to handle and
+// returns a Conn connected to it
+func newTestConn(t *testing.T, handle execHandler) *Conn {
+	t.Helper()
+	client, err := ssh.Dial("tcp", startTestServer(t, handle, nil), testClientConfig())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	c := &Conn{client: client}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// startTestServer starts an in-process SSH server and returns its address. Exec requests are
+// handed to handle; channels to the Docker socket are answered by engine, one request each.
+func startTestServer(t *testing.T, handle execHandler, engine http.Handler) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("host signer: %v", err)
+	}
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestConn(nc, serverConfig, handle, engine)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func testClientConfig() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	}
+}
+
+func serveTestConn(nc net.Conn, config *ssh.ServerConfig, handle execHandler, engine http.Handler) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, config)
+	if err != nil {
+		nc.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() == "direct-streamlocal@openssh.com" && engine != nil {
+			ch, requests, err := newCh.Accept()
+			if err != nil {
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			go serveEngine(ch, engine)
+			continue
+		}
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "only sessions")
+			continue
+		}
+		ch, requests, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(req.Type == "signal", nil)
+					continue
+				}
+				req.Reply(true, nil)
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				go handle(payload.Command, ch)
+			}
+		}()
+	}
+}
+
+// serveEngine answers one HTTP request sent over a channel to the Docker socket
+func serveEngine(ch ssh.Channel, engine http.Handler) {
+	defer ch.Close()
+	req, err := http.ReadRequest(bufio.NewReader(ch))
+	if err != nil {
+		return
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	resp := w.Result()
+	resp.Close = true
+	resp.Write(ch)
+}
+
+// exit ends a fake command with the given status
+func exit(ch ssh.Channel, status uint32) {
+	var payload [4]byte
+	binary.BigEndian.PutUint32(payload[:], status)
+	ch.SendRequest("exit-status", false, payload[:])
+	ch.Close()
+}
+
+// setTestCommandTimeout shortens the command timeout for one test
+func setTestCommandTimeout(t *testing.T, d time.Duration) {
+	timeoutMu.Lock()
+	prev := commandTimeout
+	commandTimeout = d
+	timeoutMu.Unlock()
+	t.Cleanup(func() {
+		timeoutMu.Lock()
+		commandTimeout = prev
+		timeoutMu.Unlock()
+	})
+}
+
+func TestRunStalledCommandTimesOut(t *testing.T) {
+	setTestCommandTimeout(t, 200*time.Millisecond)
+	// The fake command never writes, exits or closes its channel
+	stalled := make(chan struct{})
+	t.Cleanup(func() { close(stalled) })
+	c := newTestConn(t, func(cmd string, ch ssh.Channel) { <-stalled })
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		t.Fatalf("session: %v", err)
+	}
+	defer session.Close()
+
+	start := time.Now()
+	err = c.run(context.Background(), session, "docker ps")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("run = %v, want the command timeout", err)
+	}
+	if !strings.Contains(err.Error(), "command aborted") {
+		t.Fatalf("run = %q, want it reported as aborted", err)
+	}
+	// The timeout plus the grace the aborted session gets to close
+	if limit := 200*time.Millisecond + abortGrace + time.Second; elapsed > limit {
+		t.Fatalf("run returned after %v, want within %v", elapsed, limit)
+	}
+}
+
+func TestRunWithoutCommandTimeoutFollowsContext(t *testing.T) {
+	setTestCommandTimeout(t, 100*time.Millisecond)
+	c := newTestConn(t, func(cmd string, ch ssh.Channel) {
+		time.Sleep(300 * time.Millisecond)
+		ch.Write([]byte("pulled\n"))
+		exit(ch, 0)
+	})
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		t.Fatalf("session: %v", err)
+	}
+	defer session.Close()
+	var out strings.Builder
+	session.Stdout = &out
+
+	// Commands such as pulls opt out of the command timeout and outlive it
+	if err := c.run(WithoutCommandTimeout(context.Background()), session, "docker pull nginx"); err != nil {
+		t.Fatalf("run = %v, want it to finish after the command timeout", err)
+	}
+	if out.String() != "pulled\n" {
+		t.Fatalf("output = %q, want pulled", out.String())
+	}
+}
+
+func TestRunCancelledBeforeStart(t *testing.T) {
+	c := newTestConn(t, func(cmd string, ch ssh.Channel) { exit(ch, 0) })
+	session, err := c.client.NewSession()
+	if err != nil {
+		t.Fatalf("session: %v", err)
+	}
+	defer session.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.run(ctx, session, "true"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("run = %v, want it not started", err)
+	}
+}
@@ -0,0 +1,327 @@
+package ssh
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+)
+
+// ErrNotAFile is returned when a path to be read is a directory or another non-regular file
+var ErrNotAFile = errors.New("not a regular file")
+
+// ErrFileTooLarge is returned when a file to be read is larger than the limit
+var ErrFileTooLarge = errors.New("file too large")
+
+// cappedBuffer is a buffer that fails writes beyond max bytes, so reading a file of unknown
+// size stops once it is too large
+type cappedBuffer struct {
+	bytes.Buffer
+	max      int64
+	exceeded bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if int64(b.Len()+len(p)) > b.max {
+		b.exceeded = true
+		return 0, ErrFileTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// ErrRangeNotSatisfiable is returned when a file range starts past the end of the file
+var ErrRangeNotSatisfiable = errors.New("range starts past the end of the file")
+
+// FileRange selects the part of a file ReadContainerFileRange reads: Length bytes from Offset, or
+// the first Head or last Tail lines when either is set
+type FileRange struct {
+	Offset, Length int64
+	Head, Tail     int64
+}
+
+// fileRangeScript prints the size of the regular file $1 on the first line, followed by the part
+// of it that $2 selects. Line ranges are cut to $4 bytes, keeping the start of head and the end
+// of tail. stat is not in every image, in which case wc counts the bytes.
+const fileRangeScript = `[ -e "$1" ] || { echo "$1: No such file or directory" >&2; exit 1; }
+[ -f "$1" ] || { echo "$1: not a regular file" >&2; exit 1; }
+size=$(stat -L -c %s -- "$1" 2>/dev/null || wc -c < "$1") || exit
+echo $size
+case "$2" in
+bytes) tail -c +$(($3 + 1)) -- "$1" | head -c "$4" ;;
+head) head -n "$3" -- "$1" | head -c "$4" ;;
+tail) tail -n "$3" -- "$1" | tail -c "$4" ;;
+esac`
+
+// ReadContainerFileRange reads part of a file in a container, with at most limit bytes of a line
+// range, and returns it with the offset it starts at and the file's size. The file is cut on the
+// container's side, so only the part is transferred.
+func (s *SSHClient) ReadContainerFileRange(ctx context.Context, containerID, filePath string, r FileRange, limit int64) (_ []byte, offset, size int64, err error) {
+	defer s.track("read_file_range", time.Now(), &err)
+	cmd := fmt.Sprintf("docker exec %s sh -c %s sh %s", ShellQuote(containerID), ShellQuote(fileRangeScript), ShellQuote(filePath))
+	for _, arg := range r.args(limit) {
+		cmd += " " + ShellQuote(arg)
+	}
+	output, err := s.ExecuteDockerCommand(ctx, cmd)
+	if err != nil {
+		if err := dockerapi.StateConflict(err); errors.Is(err, dockerapi.ErrNotRunning) {
+			return nil, 0, 0, err
+		}
+		switch {
+		case strings.Contains(output, "No such file or directory"):
+			return nil, 0, 0, fmt.Errorf("%s: %w", filePath, ErrFileNotFound)
+		case strings.Contains(output, "not a regular file"):
+			return nil, 0, 0, fmt.Errorf("%s: %w", filePath, ErrNotAFile)
+		}
+		return nil, 0, 0, err
+	}
+	return r.parse(output)
+}
+
+// args are the arguments of fileRangeScript after the file
+func (r FileRange) args(limit int64) []string {
+	switch {
+	case r.Head > 0:
+		return []string{"head", strconv.FormatInt(r.Head, 10), strconv.FormatInt(limit, 10)}
+	case r.Tail > 0:
+		return []string{"tail", strconv.FormatInt(r.Tail, 10), strconv.FormatInt(limit, 10)}
+	}
+	return []string{"bytes", strconv.FormatInt(r.Offset, 10), strconv.FormatInt(r.Length, 10)}
+}
+
+// parse reads the output of fileRangeScript, returning the part of the file with the offset it
+// starts at and the file's size
+func (r FileRange) parse(output string) (_ []byte, offset, size int64, err error) {
+	sizeLine, content, _ := strings.Cut(output, "\n")
+	size, err = strconv.ParseInt(strings.TrimSpace(sizeLine), 10, 64)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("unexpected file size %q", sizeLine)
+	}
+	switch {
+	case r.Head > 0:
+	case r.Tail > 0:
+		offset = max(size-int64(len(content)), 0)
+	case r.Offset > size:
+		return nil, 0, size, ErrRangeNotSatisfiable
+	default:
+		offset = r.Offset
+	}
+	return []byte(content), offset, size, nil
+}
+
+// Errors of ContainerFileOp, recognised from the messages of the tools in the container
+var (
+	ErrFileNotFound      = errors.New("no such file or directory")
+	ErrFileExists        = errors.New("file exists")
+	ErrDirectoryNotEmpty = errors.New("directory not empty")
+)
+
+// fileOpScripts run an operation in the container's shell with the paths and mode as positional
+// parameters, so they never have to be quoted into the script. Directories that are not empty
+// are only deleted with rm -r when recursive, and rename refuses to replace what is there.
+var fileOpScripts = map[string]string{
+	model.FileOpDelete: `if [ -d "$1" ] && [ ! -L "$1" ]; then rmdir -- "$1"; else rm -- "$1"; fi`,
+	model.FileOpRename: `if [ -e "$2" ] || [ -L "$2" ]; then echo "$2: File exists" >&2; exit 1; fi; mv -- "$1" "$2"`,
+	model.FileOpMkdir:  `mkdir -- "$1"`,
+	model.FileOpChmod:  `chmod "$2" -- "$1"`,
+}
+
+// fileOpErrors are the errors of ContainerFileOp and the messages that report them, the same
+// for GNU coreutils and BusyBox
+var fileOpErrors = []struct {
+	message string
+	err     error
+}{
+	{"No such file or directory", ErrFileNotFound},
+	{"File exists", ErrFileExists},
+	{"Directory not empty", ErrDirectoryNotEmpty},
+}
+
+// ContainerFileOp deletes, renames, creates or changes the mode of a path in a container with
+// docker exec, as op describes it. The errors of the container's tools are returned as
+// ErrFileNotFound, ErrFileExists and ErrDirectoryNotEmpty, and a container that does not run
+// fails with dockerapi.ErrNotRunning.
+func (s *SSHClient) ContainerFileOp(ctx context.Context, containerID string, op model.FileOpRequest) (err error) {
+	defer s.track("file_"+op.Op, time.Now(), &err)
+	script, ok := fileOpScripts[op.Op]
+	if !ok {
+		return fmt.Errorf("unknown file operation %q", op.Op)
+	}
+	args := []string{op.Path}
+	switch {
+	case op.Op == model.FileOpDelete && op.Recursive:
+		script = `rm -rf -- "$1"`
+	case op.Op == model.FileOpRename:
+		args = append(args, op.NewPath)
+	case op.Op == model.FileOpChmod:
+		if op.Recursive {
+			script = `chmod -R "$2" -- "$1"`
+		}
+		args = append(args, op.Mode)
+	}
+	cmd := fmt.Sprintf("docker exec %s sh -c %s sh", ShellQuote(containerID), ShellQuote(script))
+	for _, arg := range args {
+		cmd += " " + ShellQuote(arg)
+	}
+	output, err := s.ExecuteDockerCommand(ctx, cmd)
+	if err == nil {
+		return nil
+	}
+	if err := dockerapi.StateConflict(err); errors.Is(err, dockerapi.ErrNotRunning) {
+		return err
+	}
+	for _, e := range fileOpErrors {
+		if strings.Contains(output, e.message) {
+			return fmt.Errorf("%s: %w", strings.TrimSpace(output), e.err)
+		}
+	}
+	return err
+}
+
+// CopyContainerFile reads a file out of a container with docker cp, which handles binary files,
+// any file name and images without a shell. open is called with the file's metadata and returns
+// where its content goes. When docker cp fails before open was called the file is read with
+// "docker exec cat" instead, and open gets a Size of -1 as the size is unknown.
+func (s *SSHClient) CopyContainerFile(ctx context.Context, containerID, filePath string, open func(model.FileEntry) (io.Writer, error)) (err error) {
+	defer s.track("copy_file", time.Now(), &err)
+	opened := false
+	err = s.readArchive(ctx, containerID, filePath, func(tr *tar.Reader) error {
+		h, err := tr.Next()
+		if err != nil {
+			return fmt.Errorf("failed to read the archive: %w", err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			return fmt.Errorf("%s: %w", filePath, ErrNotAFile)
+		}
+		opened = true
+		w, err := open(fileEntry(h, path.Base(h.Name)))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, tr)
+		return err
+	})
+	if err == nil || opened || errors.Is(err, ErrNotAFile) || ctx.Err() != nil {
+		return err
+	}
+
+	w, openErr := open(model.FileEntry{Name: path.Base(filePath), Size: -1})
+	if openErr != nil {
+		return openErr
+	}
+	if catErr := s.streamDockerCommand(ctx, fmt.Sprintf("docker exec %s cat %s", ShellQuote(containerID), ShellQuote(filePath)), w); catErr != nil {
+		return fmt.Errorf("%w (reading with cat failed too: %v)", err, catErr)
+	}
+	return nil
+}
+
+// listContainerArchive lists a container directory from the tar stream of docker cp, for images
+// without ls. The whole directory tree is streamed, so large directories take a while.
+func (s *SSHClient) listContainerArchive(ctx context.Context, containerID, dir string) ([]model.FileEntry, error) {
+	files := []model.FileEntry{}
+	err := s.readArchive(ctx, containerID, dir, func(tr *tar.Reader) error {
+		root := ""
+		for first := true; ; first = false {
+			h, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read the archive: %w", err)
+			}
+			name := strings.Trim(h.Name, "/")
+			if first {
+				if h.Typeflag != tar.TypeDir {
+					return fmt.Errorf("%s is not a directory", dir)
+				}
+				root = name
+				continue
+			}
+			rel := strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+			if rel == "" || strings.Contains(rel, "/") {
+				continue
+			}
+			files = append(files, fileEntry(h, rel))
+		}
+	})
+	return files, err
+}
+
+// readArchive runs docker cp for a container path, following it when it is a symlink, and
+// passes the tar stream to read. The command is killed when read fails.
+func (s *SSHClient) readArchive(ctx context.Context, containerID, srcPath string, read func(*tar.Reader) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := read(tar.NewReader(pr))
+		if err != nil {
+			cancel()
+		}
+		// Let docker cp finish writing the rest of the archive
+		io.Copy(io.Discard, pr)
+		done <- err
+	}()
+	cmd := fmt.Sprintf("docker cp -L %s -", ShellQuote(containerID+":"+srcPath))
+	runErr := s.streamDockerCommand(ctx, cmd, pw)
+	pw.CloseWithError(runErr)
+	if err := <-done; err != nil && (runErr == nil || !errors.Is(err, runErr)) {
+		return err
+	}
+	return runErr
+}
+
+// streamDockerCommand runs a docker command, writing its stdout to w. The command is only bound
+// by ctx and the stream limits, as transfers may take longer than the command timeout.
+func (s *SSHClient) streamDockerCommand(ctx context.Context, cmd string, w io.Writer) error {
+	stream, err := s.StreamCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	_, err = io.Copy(w, stream)
+	return err
+}
+
+// fileEntry describes a tar entry the way ListContainerFiles describes ls output
+func fileEntry(h *tar.Header, name string) model.FileEntry {
+	mode := lsMode(h)
+	return model.FileEntry{
+		Name:        name,
+		Size:        h.Size,
+		Mode:        mode,
+		IsDir:       h.Typeflag == tar.TypeDir,
+		IsSymlink:   h.Typeflag == tar.TypeSymlink,
+		LinkTarget:  h.Linkname,
+		ModTime:     h.ModTime,
+		Permissions: modeToOctal(mode),
+	}
+}
+
+// lsMode formats a tar entry's type and permissions like ls -l, e.g. "drwxr-xr-x"
+func lsMode(h *tar.Header) string {
+	kind := "-"
+	switch h.Typeflag {
+	case tar.TypeDir:
+		kind = "d"
+	case tar.TypeSymlink:
+		kind = "l"
+	case tar.TypeChar:
+		kind = "c"
+	case tar.TypeBlock:
+		kind = "b"
+	case tar.TypeFifo:
+		kind = "p"
+	}
+	return kind + fs.FileMode(h.Mode).Perm().String()[1:]
+}
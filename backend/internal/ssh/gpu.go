@@ -0,0 +1,135 @@
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gpuQuery reads one CSV line per GPU: index, name, utilization %, memory used and total in MiB,
+// temperature in °C
+const gpuQuery = "nvidia-smi --query-gpu=index,name,utilization.gpu,memory.used,memory.total,temperature.gpu --format=csv,noheader,nounits"
+
+// GPUStats is the state of one NVIDIA GPU as reported by nvidia-smi
+type GPUStats struct {
+	Index         int     `json:"index"`
+	Name          string  `json:"name"`
+	Utilization   float64 `json:"utilization"`
+	MemoryUsedMB  float64 `json:"memory_used_mb"`
+	MemoryTotalMB float64 `json:"memory_total_mb"`
+	Temperature   float64 `json:"temperature"`
+}
+
+// GPURequest is the GPU access a container asked for with --gpus
+type GPURequest struct {
+	// Count is the number of GPUs requested, -1 for all
+	Count     int      `json:"count"`
+	DeviceIDs []string `json:"device_ids,omitempty"`
+	Driver    string   `json:"driver,omitempty"`
+}
+
+// gpuRecheck is how long a host found without nvidia-smi is trusted to stay without it
+const gpuRecheck = time.Hour
+
+// gpuProbe records whether nvidia-smi was found on a host
+type gpuProbe struct {
+	present bool
+	at      time.Time
+}
+
+// gpuHosts remembers the probe result per address, so hosts without GPUs cost no SSH commands
+// besides one probe an hour
+var gpuHosts sync.Map // addr -> gpuProbe
+
+// GetGPUStats returns the host's NVIDIA GPUs, or nil when nvidia-smi is not installed
+func (s *SSHClient) GetGPUStats(ctx context.Context) (_ []GPUStats, err error) {
+	v, known := gpuHosts.Load(s.Addr)
+	probe, _ := v.(gpuProbe)
+	if known && !probe.present && time.Since(probe.at) < gpuRecheck {
+		return nil, nil
+	}
+	defer s.track("gpu_stats", time.Now(), &err)
+	if !probe.present {
+		output, err := s.runScript(ctx, "command -v nvidia-smi >/dev/null 2>&1 && echo yes || echo no", nil)
+		if err != nil {
+			return nil, err
+		}
+		probe = gpuProbe{present: strings.TrimSpace(output) == "yes", at: time.Now()}
+		gpuHosts.Store(s.Addr, probe)
+		if !probe.present {
+			return nil, nil
+		}
+	}
+	output, err := s.runScript(ctx, gpuQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseGPUStats(output), nil
+}
+
+// parseGPUStats parses gpuQuery output. Values nvidia-smi cannot read ("[N/A]") are left at zero.
+func parseGPUStats(output string) []GPUStats {
+	var gpus []GPUStats
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) < 6 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		number := func(s string) float64 {
+			v, _ := strconv.ParseFloat(s, 64)
+			return v
+		}
+		index, _ := strconv.Atoi(fields[0])
+		gpus = append(gpus, GPUStats{
+			Index:         index,
+			Name:          fields[1],
+			Utilization:   number(fields[2]),
+			MemoryUsedMB:  number(fields[3]),
+			MemoryTotalMB: number(fields[4]),
+			Temperature:   number(fields[5]),
+		})
+	}
+	return gpus
+}
+
+// GPURequestFromInspect returns the GPU request in a container's docker inspect output, or nil
+// when the container requests no GPUs
+func GPURequestFromInspect(details string) *GPURequest {
+	var inspect []struct {
+		HostConfig struct {
+			DeviceRequests []struct {
+				Driver       string
+				Count        int
+				DeviceIDs    []string
+				Capabilities [][]string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(details), &inspect); err != nil || len(inspect) == 0 {
+		return nil
+	}
+	for _, r := range inspect[0].HostConfig.DeviceRequests {
+		if r.Driver != "nvidia" && !hasCapability(r.Capabilities, "gpu") {
+			continue
+		}
+		return &GPURequest{Count: r.Count, DeviceIDs: r.DeviceIDs, Driver: r.Driver}
+	}
+	return nil
+}
+
+func hasCapability(sets [][]string, capability string) bool {
+	for _, set := range sets {
+		for _, c := range set {
+			if c == capability {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrJumpHost marks failures of the hop to a server's jump host, as opposed to the hop from the
+// jump host to the server
+var ErrJumpHost = errors.New("jump host")
+
+// JumpHost is a bastion that connections to a server are tunnelled through
+type JumpHost struct {
+	Config *ssh.ClientConfig
+	Addr   string
+
+	fingerprint string
+}
+
+// NewJumpHost prepares a jump host. Authentication works as for NewSSHClient.
+func NewJumpHost(ip string, port int, username, authMode, secret, passphrase string) (*JumpHost, error) {
+	if port == 0 {
+		port = 22
+	}
+	config, err := clientConfig(username, authMode, secret, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	addr := fmt.Sprintf("%s:%d", ip, port)
+	return &JumpHost{
+		Config:      config,
+		Addr:        addr,
+		fingerprint: fingerprint(addr, username, authMode, secret, passphrase),
+	}, nil
+}
+
+// SetJumpHost makes the client connect through jump
+func (s *SSHClient) SetJumpHost(jump *JumpHost) {
+	s.jump = jump
+	s.fingerprint = fingerprint(s.fingerprint, jump.fingerprint)
+}
+
+// dialThroughJump connects to the jump host, opens a tunnel from it to the server and runs the
+// server's handshake over the tunnel. The jump connection is closed along with the server's.
+func (s *SSHClient) dialThroughJump() (*ssh.Client, error) {
+	jump, err := handshake(s.jump.Addr, s.jump.Config)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrJumpHost, s.jump.Addr, err)
+	}
+
+	// Tunnels have no deadlines, so the jump connection is closed when the server takes too long
+	timer := time.AfterFunc(s.Config.Timeout, func() { jump.Close() })
+	conn, err := jump.Dial("tcp", s.Addr)
+	if err != nil {
+		timer.Stop()
+		jump.Close()
+		return nil, fmt.Errorf("failed to reach %s from jump host %s: %w", s.Addr, s.jump.Addr, err)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, s.Addr, s.Config)
+	if !timer.Stop() {
+		if err == nil {
+			c.Close()
+		}
+		return nil, fmt.Errorf("ssh: handshake with %s through jump host %s timed out", s.Addr, s.jump.Addr)
+	}
+	if err != nil {
+		jump.Close()
+		return nil, err
+	}
+
+	client := ssh.NewClient(c, chans, reqs)
+	go func() {
+		client.Wait()
+		jump.Close()
+	}()
+	return client, nil
+}
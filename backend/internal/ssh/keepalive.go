@@ -0,0 +1,51 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errKeepAliveTimeout is reported when a keepalive is not answered within the interval
+var errKeepAliveTimeout = errors.New("ssh: keepalive not answered")
+
+// KeepAlive sends an OpenSSH keepalive request over the connection every interval until ctx is
+// done, so that firewalls do not drop it while a session is idle. A keepalive that fails or is
+// not answered within the interval closes the connection, ending its sessions, and is reported
+// on the returned channel.
+func (c *Conn) KeepAlive(ctx context.Context, interval time.Duration) <-chan error {
+	failed := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := c.ping(interval); err != nil {
+				c.abandon()
+				failed <- err
+				return
+			}
+		}
+	}()
+	return failed
+}
+
+// ping sends a keepalive and waits up to timeout for the answer. Servers answer the request
+// with a failure, which still shows the connection is alive.
+func (c *Conn) ping(timeout time.Duration) error {
+	answered := make(chan error, 1)
+	go func() {
+		_, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil)
+		answered <- err
+	}()
+	select {
+	case err := <-answered:
+		return err
+	case <-time.After(timeout):
+		return errKeepAliveTimeout
+	}
+}
@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Errors of ParsePrivateKey
+var (
+	ErrKeyPassphraseRequired = errors.New("the SSH private key is encrypted, a passphrase is required")
+	ErrKeyPassphrase         = errors.New("wrong passphrase for the SSH private key")
+	ErrKeyFormat             = errors.New("unsupported SSH private key format")
+)
+
+// ParsePrivateKey parses a PEM encoded RSA, ECDSA or Ed25519 private key in OpenSSH, PKCS#1,
+// PKCS#8 or SEC 1 format. Encrypted keys are decrypted with passphrase, which is ignored for
+// keys that are not encrypted.
+func ParsePrivateKey(key, passphrase string) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(key))
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case err == nil:
+		return signer, nil
+	case !errors.As(err, &missing):
+		return nil, fmt.Errorf("%w: %v", ErrKeyFormat, err)
+	case passphrase == "":
+		return nil, ErrKeyPassphraseRequired
+	}
+
+	signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+	switch {
+	case err == nil:
+		return signer, nil
+	case errors.Is(err, x509.IncorrectPasswordError):
+		return nil, ErrKeyPassphrase
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrKeyFormat, err)
+	}
+}
@@ -0,0 +1,214 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"gorm.io/gorm"
+)
+
+const (
+	// latencyTimeout bounds probing all targets of one stats request together
+	latencyTimeout = 2 * time.Second
+	// icmpTimeout is the share of latencyTimeout an ICMP echo may take before the TCP probe
+	icmpTimeout = time.Second
+	// icmpRecheck is how long the panel is trusted to stay unable to open ICMP sockets
+	icmpRecheck = time.Hour
+)
+
+var (
+	// pingPort is the port of TCP probes to targets that name none
+	pingPort atomic.Int32
+	icmpSeq  atomic.Uint32
+
+	icmpMu sync.Mutex
+	// icmpFailed records when opening an ICMP socket of a network last failed
+	icmpFailed = map[string]time.Time{}
+)
+
+func init() {
+	pingPort.Store(443)
+}
+
+// ConfigureLatency applies the TCP probe port setting and follows changes to it
+func ConfigureLatency(db *gorm.DB) {
+	setPingPort(strconv.Itoa(config.GetInt(db, model.ConfigKeyPingTCPPort)))
+	config.OnChange(model.ConfigKeyPingTCPPort, setPingPort)
+}
+
+func setPingPort(value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 || n > 65535 {
+		return
+	}
+	pingPort.Store(int32(n))
+}
+
+// MeasureLatencies probes all targets concurrently and returns the round trip time to each in
+// milliseconds, 0 for targets that did not answer within latencyTimeout
+func MeasureLatencies(ctx context.Context, targets []string) []float64 {
+	ctx, cancel := context.WithTimeout(ctx, latencyTimeout)
+	defer cancel()
+	results := make([]float64, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			results[i] = MeasureLatency(ctx, target)
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+// MeasureLatency returns the round trip time to target in milliseconds, or 0 when it did not
+// answer before ctx is done. An ICMP echo is tried first. Targets that do not answer it, or all
+// targets when the panel may not send ICMP, get a TCP connect to the port in target ("host:port")
+// or the configured ping port. Only the network round trip is measured, not the name lookup.
+func MeasureLatency(ctx context.Context, target string) float64 {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = target, strconv.Itoa(int(pingPort.Load()))
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return 0
+	}
+	ip := addrs[0].IP
+
+	icmpCtx, cancel := context.WithTimeout(ctx, icmpTimeout)
+	rtt, err := pingICMP(icmpCtx, ip)
+	cancel()
+	if err != nil {
+		rtt, err = pingTCP(ctx, net.JoinHostPort(ip.String(), port))
+	}
+	if err != nil {
+		return 0
+	}
+	// Keep answers faster than a microsecond apart from failures
+	return max(float64(rtt.Microseconds())/1000, 0.001)
+}
+
+// pingICMP sends an ICMP echo to ip and waits for the reply, over an unprivileged ICMP socket
+// where the kernel allows one and a raw socket otherwise
+func pingICMP(ctx context.Context, ip net.IP) (time.Duration, error) {
+	conn, privileged, err := listenICMP(ip.To4() != nil)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	var echoType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	proto := 1
+	if ip.To4() == nil {
+		echoType, replyType, proto = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, 58
+	}
+	// Unprivileged sockets have the kernel set the ID, and only get their own replies
+	id := rand.Intn(0xffff)
+	seq := int(icmpSeq.Add(1) & 0xffff)
+	msg, err := (&icmp.Message{Type: echoType, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("docker-pulse")}}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if !privileged {
+		dst = &net.UDPAddr{IP: ip}
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(msg, dst); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			return 0, err
+		}
+		rtt := time.Since(start)
+		reply, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || reply.Type != replyType {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (privileged && echo.ID != id) || !peerIP(peer).Equal(ip) {
+			continue
+		}
+		return rtt, nil
+	}
+}
+
+// listenICMP opens an ICMP socket, reporting whether it is a raw one. Networks that could not be
+// opened are not tried again for icmpRecheck.
+func listenICMP(v4 bool) (_ *icmp.PacketConn, privileged bool, err error) {
+	networks := []string{"udp6", "ip6:ipv6-icmp"}
+	if v4 {
+		networks = []string{"udp4", "ip4:icmp"}
+	}
+	for _, network := range networks {
+		icmpMu.Lock()
+		failed, ok := icmpFailed[network]
+		icmpMu.Unlock()
+		if ok && time.Since(failed) < icmpRecheck {
+			continue
+		}
+		conn, listenErr := icmp.ListenPacket(network, "")
+		if listenErr == nil {
+			return conn, network != networks[0], nil
+		}
+		err = listenErr
+		icmpMu.Lock()
+		icmpFailed[network] = time.Now()
+		icmpMu.Unlock()
+	}
+	if err == nil {
+		err = errors.New("ICMP sockets are not permitted")
+	}
+	return nil, false, err
+}
+
+func peerIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// pingTCP measures how long a TCP connect to addr takes. A refused connection counts as an
+// answer, as the host's reset took a round trip too.
+func pingTCP(ctx context.Context, addr string) (time.Duration, error) {
+	var d net.Dialer
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	rtt := time.Since(start)
+	if err == nil {
+		conn.Close()
+		return rtt, nil
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return rtt, nil
+	}
+	return 0, err
+}
@@ -0,0 +1,143 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrServerBusy is returned when a session could not be opened because the server kept its
+// maximum of concurrent sessions for the whole session wait
+var ErrServerBusy = errors.New("server busy: too many concurrent SSH sessions")
+
+var (
+	gateMu sync.Mutex
+	gates  = map[string]*sessionGate{}
+	// maxSessions is how many sessions may be open on one host at once
+	maxSessions = 3
+	// sessionWait is how long a session waits for a slot before failing with ErrServerBusy
+	sessionWait = 10 * time.Second
+)
+
+// sessionGate counts the sessions open on a host. Callers beyond maxSessions queue in waiters
+// in order of arrival; a released slot is handed to the first of them.
+type sessionGate struct {
+	used    int
+	waiters []chan struct{}
+}
+
+// ConfigureSessions applies the session limit settings and follows changes to them
+func ConfigureSessions(db *gorm.DB) {
+	setMaxSessions(strconv.Itoa(config.GetInt(db, model.ConfigKeySSHMaxSessions)))
+	setSessionWait(strconv.Itoa(config.GetInt(db, model.ConfigKeySSHSessionWait)))
+	config.OnChange(model.ConfigKeySSHMaxSessions, setMaxSessions)
+	config.OnChange(model.ConfigKeySSHSessionWait, setSessionWait)
+}
+
+func setMaxSessions(value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return
+	}
+	gateMu.Lock()
+	defer gateMu.Unlock()
+	maxSessions = n
+	// A raised limit lets queued sessions in right away
+	for _, g := range gates {
+		for g.used < maxSessions && len(g.waiters) > 0 {
+			g.used++
+			g.admitLocked()
+		}
+	}
+}
+
+func setSessionWait(value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return
+	}
+	gateMu.Lock()
+	sessionWait = time.Duration(n) * time.Second
+	gateMu.Unlock()
+}
+
+// acquireSession takes one of the session slots of host, waiting for one to be released while
+// all are in use. It fails when ctx is done or no slot was released within the session wait.
+// The returned function releases the slot and may be called more than once.
+func acquireSession(ctx context.Context, host string) (func(), error) {
+	gateMu.Lock()
+	g := gates[host]
+	if g == nil {
+		g = &sessionGate{}
+		gates[host] = g
+	}
+	release := sync.OnceFunc(func() {
+		gateMu.Lock()
+		defer gateMu.Unlock()
+		g.releaseLocked(host)
+	})
+	if g.used < maxSessions && len(g.waiters) == 0 {
+		g.used++
+		gateMu.Unlock()
+		return release, nil
+	}
+	admitted := make(chan struct{})
+	g.waiters = append(g.waiters, admitted)
+	wait := sessionWait
+	gateMu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-admitted:
+		return release, nil
+	case <-ctx.Done():
+		err = fmt.Errorf("waiting for a session: %w", ctx.Err())
+	case <-timer.C:
+		err = ErrServerBusy
+	}
+
+	gateMu.Lock()
+	defer gateMu.Unlock()
+	select {
+	case <-admitted:
+		// The slot was handed over while giving up, so it is passed on
+		g.releaseLocked(host)
+	default:
+		for i, w := range g.waiters {
+			if w == admitted {
+				g.waiters = append(g.waiters[:i], g.waiters[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil, err
+}
+
+// releaseLocked frees a slot, handing it to the first waiter unless a lowered limit is still
+// exceeded. gateMu must be held.
+func (g *sessionGate) releaseLocked(host string) {
+	if len(g.waiters) > 0 && g.used <= maxSessions {
+		g.admitLocked()
+		return
+	}
+	g.used--
+	if g.used == 0 && len(g.waiters) == 0 {
+		delete(gates, host)
+	}
+}
+
+// admitLocked lets the first waiter in on a slot already counted in used. gateMu must be held.
+func (g *sessionGate) admitLocked() {
+	close(g.waiters[0])
+	g.waiters = g.waiters[1:]
+}
@@ -0,0 +1,52 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// logUsageScript prints id|name|driver|kilobytes|log path for every container. Log files live
+// below /var/lib/docker, so du runs as root. Rotated files (path.1, path.2, ...) are included.
+const logUsageScript = asRoot + `docker ps -aq --no-trunc | xargs -r docker inspect --format '{{.Id}}|{{.Name}}|{{.HostConfig.LogConfig.Type}}|{{.LogPath}}' | ` +
+	`while IFS='|' read -r id name driver path; do size=0; ` +
+	`if [ -n "$path" ]; then size=$($S du -kc "$path" "$path".* 2>/dev/null | tail -n 1 | cut -f1); fi; ` +
+	`echo "$id|$name|$driver|${size:-0}|$path"; done`
+
+// GetLogUsage returns the size of every container's log files
+func (s *SSHClient) GetLogUsage(ctx context.Context) (_ []model.ContainerLogUsage, err error) {
+	defer s.track("log_usage", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, logUsageScript, nil)
+	if err != nil {
+		return nil, err
+	}
+	var usage []model.ContainerLogUsage
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		parts := strings.SplitN(line, "|", 5)
+		if len(parts) < 5 {
+			continue
+		}
+		kb, _ := strconv.ParseInt(strings.TrimSpace(parts[3]), 10, 64)
+		usage = append(usage, model.ContainerLogUsage{
+			ContainerID: parts[0],
+			Name:        strings.TrimPrefix(parts[1], "/"),
+			Driver:      parts[2],
+			LogPath:     parts[4],
+			SizeBytes:   kb * 1024,
+		})
+	}
+	return usage, nil
+}
+
+// TruncateContainerLog empties a container's current log file in place. Docker keeps writing
+// to the same file, so the container does not need a restart.
+func (s *SSHClient) TruncateContainerLog(ctx context.Context, containerID string) (err error) {
+	defer s.track("truncate_log", time.Now(), &err)
+	script := asRoot + fmt.Sprintf(`path=$(docker inspect --format '{{.LogPath}}' %s) && [ -n "$path" ] && $S truncate -s 0 "$path"`, ShellQuote(containerID))
+	_, err = s.runDockerScript(ctx, script, nil)
+	return err
+}
@@ -2,13 +2,17 @@ package ssh
 
 import (
 	"bytes"
+	"context"
+	"docker-pulse/internal/agent"
+	"docker-pulse/internal/dockerapi"
 	"docker-pulse/internal/model"
+	"docker-pulse/internal/registry"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net"
-	"os/exec"
-	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -19,141 +23,331 @@ import (
 type SSHClient struct {
 	Config *ssh.ClientConfig
 	Addr   string
+	// ServerID labels the client's metrics and selects its circuit breaker; zero for clients not
+	// tied to a stored server
+	ServerID uint
+	// BypassCircuit connects even when the server's circuit is open, for explicit connection tests.
+	// The result still updates the circuit. Such clients always dial a connection of their own.
+	BypassCircuit bool
+
+	jump        *JumpHost
+	fingerprint string
+	// useSudo runs docker commands through sudo, answering its prompt with sudoPassword if set
+	useSudo      bool
+	sudoPassword string
+	// netInterface is the interface network rates are read from, the default route's when empty
+	netInterface string
+	// connectionMode selects the backend of Docker, model.ConnectionModeCLI when empty
+	connectionMode string
+	// platform is the host's platform, one of the Platform constants, empty until detected
+	platform string
+	// agent is the connection of the server's agent, which commands and Docker requests go
+	// through instead of SSH while it is set
+	agent *agent.Conn
 }
 
+// Server states reported in ServerStats.Status
+const (
+	StatusOnline = "online"
+	// StatusDockerStopped is a reachable host whose docker daemon does not answer
+	StatusDockerStopped = "docker_stopped"
+	// StatusDockerMissing is a reachable host without the docker CLI
+	StatusDockerMissing = "docker_missing"
+	StatusOffline       = "offline"
+)
+
 type ServerStats struct {
-	Status            string             `json:"status"`
-	CPUUsage          float64            `json:"cpu_usage"`
-	RAMUsage          float64            `json:"ram_usage"`
-	DockerVersion     string             `json:"docker_version"`
+	Status string `json:"status"`
+	// StatusDetail explains a status other than online, e.g. the connection or docker error
+	StatusDetail string  `json:"status_detail,omitempty"`
+	CPUUsage     float64 `json:"cpu_usage"`
+	RAMUsage     float64 `json:"ram_usage"`
+	// SystemStatsError explains CPU and RAM usage that could not be read, e.g. on other platforms
+	SystemStatsError string  `json:"system_stats_error,omitempty"`
+	LoadAvg1         float64 `json:"load_avg_1"`
+	LoadAvg5         float64 `json:"load_avg_5"`
+	LoadAvg15        float64 `json:"load_avg_15"`
+	CPUCores         int     `json:"cpu_cores"`
+	LoadPerCore      float64 `json:"load_per_core"` // LoadAvg1 per core, comparable between servers
+	DiskUsage        float64 `json:"disk_usage"`
+	DiskTotal        int64   `json:"disk_total"`
+	DiskUsed         int64   `json:"disk_used"`
+	NetRxRate        float64 `json:"net_rx_rate"`
+	NetTxRate        float64 `json:"net_tx_rate"`
+	DockerVersion    string  `json:"docker_version"`
+	// Platform is the host's kernel as "uname -s" reports it, lowercased, e.g. "linux" or "darwin"
+	Platform          string             `json:"platform,omitempty"`
 	Uptime            string             `json:"uptime"`
 	RunningContainers int                `json:"running_containers"`
 	TotalContainers   int                `json:"total_containers"`
 	Latency           float64            `json:"latency"`
 	LatencyMap        map[string]float64 `json:"latency_map"`
+	Circuit           *CircuitState      `json:"circuit,omitempty"`
+	// GPUs is only set by the stats endpoint, on hosts with nvidia-smi
+	GPUs []GPUStats `json:"gpus,omitempty"`
 }
 
-func NewSSHClient(ip string, port int, username, authMode, secret string) (*SSHClient, error) {
+// NewSSHClient prepares a client for the server. For the "key" mode secret is a private key,
+// decrypted with passphrase when it is encrypted.
+func NewSSHClient(ip string, port int, username, authMode, secret, passphrase string) (*SSHClient, error) {
+	config, err := clientConfig(username, authMode, secret, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	addr := fmt.Sprintf("%s:%d", ip, port)
+	return &SSHClient{
+		Config:      config,
+		Addr:        addr,
+		fingerprint: fingerprint(addr, username, authMode, secret, passphrase),
+	}, nil
+}
+
+// NewServerClient prepares a client for a stored server, connecting through its jump host when
+// it has one. Servers reached over the Docker API only fail with ErrNoSSH.
+func NewServerClient(server *model.Server) (*SSHClient, error) {
+	if server.AuthMode == model.AuthModeDockerTLS {
+		return nil, ErrNoSSH
+	}
+	client, err := NewSSHClient(server.IP, server.Port, server.Username, server.AuthMode, server.Secret, server.KeyPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	client.ServerID = server.ID
+	if server.JumpIP != "" {
+		jump, err := NewJumpHost(server.JumpIP, server.JumpPort, server.JumpUsername, server.JumpAuthMode, server.JumpSecret, server.JumpKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrJumpHost, err)
+		}
+		client.SetJumpHost(jump)
+	}
+	if server.UseSudo {
+		client.SetSudo(server.SudoPassword)
+	}
+	if server.NetInterface != "" {
+		client.SetNetInterface(server.NetInterface)
+	}
+	client.SetConnectionMode(server.ConnectionMode)
+	client.SetPlatform(server.Platform)
+	client.SetAgent(agent.Lookup(server.ID))
+	return client, nil
+}
+
+func clientConfig(username, authMode, secret, passphrase string) (*ssh.ClientConfig, error) {
 	var authMethods []ssh.AuthMethod
 
 	switch authMode {
 	case "password":
 		authMethods = append(authMethods, ssh.Password(secret))
 	case "key":
-		signer, err := ssh.ParsePrivateKey([]byte(secret))
+		signer, err := ParsePrivateKey(secret, passphrase)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse SSH private key: %v", err)
+			return nil, err
 		}
 		authMethods = append(authMethods, ssh.PublicKeys(signer))
 	default:
 		return nil, fmt.Errorf("unsupported authentication mode: %s", authMode)
 	}
 
-	config := &ssh.ClientConfig{
+	return &ssh.ClientConfig{
 		User:            username,
 		Auth:            authMethods,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 		Timeout:         10 * time.Second,
-	}
-	return &SSHClient{
-		Config: config,
-		Addr:   fmt.Sprintf("%s:%d", ip, port),
 	}, nil
 }
 
-func (s *SSHClient) CreateSession() (*ssh.Session, *ssh.Client, error) {
-	client, err := ssh.Dial("tcp", s.Addr, s.Config)
+// CreateSession opens a session on the server. Clients with a ServerID share one pooled
+// connection per server, which is dialed on first use and again when it has died; closing the
+// returned Conn hands it back. Sessions beyond the host's session limit wait for one to be
+// closed, until ctx is done or the session wait passes with ErrServerBusy.
+func (s *SSHClient) CreateSession(ctx context.Context) (_ *ssh.Session, _ *Conn, err error) {
+	defer s.track("connect", time.Now(), &err)
+	release, err := acquireSession(ctx, s.Addr)
 	if err != nil {
 		return nil, nil, err
 	}
-
-	session, err := client.NewSession()
+	session, conn, err := s.newSession()
 	if err != nil {
-		client.Close()
+		release()
 		return nil, nil, err
 	}
-	return session, client, nil
+	conn.release = release
+	return session, conn, nil
+}
+
+// CheckConnectivity reports whether a session can be opened on the server
+func (s *SSHClient) CheckConnectivity(ctx context.Context) bool {
+	return s.checkConnectivity(ctx) == nil
 }
 
-func (s *SSHClient) CheckConnectivity() bool {
-	client, err := ssh.Dial("tcp", s.Addr, s.Config)
+func (s *SSHClient) checkConnectivity(ctx context.Context) error {
+	if s.agent != nil {
+		// The agent's connection is kept alive by pings
+		return nil
+	}
+	session, conn, err := s.CreateSession(ctx)
 	if err != nil {
-		return false
+		return err
 	}
-	defer client.Close()
-	return true
+	session.Close()
+	conn.Close()
+	return nil
 }
 
-func (s *SSHClient) GetDockerInfo() (*ServerStats, error) {
-	session, client, err := s.CreateSession()
+// dial connects to the server, through its jump host when it has one, and its circuit breaker
+func (s *SSHClient) dial() (*ssh.Client, error) {
+	if !s.BypassCircuit {
+		if err := allowConnect(s.ServerID); err != nil {
+			return nil, err
+		}
+	}
+	var client *ssh.Client
+	var err error
+	if s.jump != nil {
+		client, err = s.dialThroughJump()
+	} else {
+		client, err = handshake(s.Addr, s.Config)
+	}
+	recordConnect(s.ServerID, err)
+	return client, err
+}
+
+// handshake connects to addr. The handshake is bounded by the connect timeout too, so a host
+// that accepts the TCP connection but stalls does not hang it.
+func handshake(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, config.Timeout)
 	if err != nil {
 		return nil, err
 	}
-	defer session.Close()
-	defer client.Close()
-
-	var stdoutBuf bytes.Buffer
-	session.Stdout = &stdoutBuf
-
-	// Use docker info to get version and container counts, and uptime for system uptime
-	err = session.Run("docker info --format '{{.ServerVersion}}|{{.ContainersRunning}}|{{.Containers}}' && uptime -p")
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
 	if err != nil {
-		return &ServerStats{Status: "offline"}, nil
+		conn.Close()
+		return nil, err
 	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
 
-	output := stdoutBuf.String()
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	stats := &ServerStats{Status: "online", DockerVersion: "Unknown", Uptime: "N/A"}
+// dockerInfoScript prints the host's platform and uptime and then the docker version and container
+// counts. BSD hosts print their boot time instead of the uptime. It exits with dockerMissingExit
+// when there is no docker CLI.
+const dockerInfoScript = `uname -s
+uptime -p 2>/dev/null || sysctl -n kern.boottime 2>/dev/null || echo
+command -v docker >/dev/null 2>&1 || exit 127
+docker info --format '{{.ServerVersion}}|{{.ContainersRunning}}|{{.Containers}}'`
+
+const dockerMissingExit = 127
+
+// GetDockerInfo reads the docker version, container counts and the host's platform and uptime.
+// When docker cannot be used Status is StatusDockerMissing or StatusDockerStopped, with docker's
+// error in StatusDetail. An error is only returned when the script could not be run at all.
+func (s *SSHClient) GetDockerInfo(ctx context.Context) (_ *ServerStats, err error) {
+	defer s.track("docker_info", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, dockerInfoScript, nil)
+	stats := &ServerStats{Status: StatusOnline, DockerVersion: "Unknown", Uptime: "N/A"}
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	for len(lines) < 3 {
+		lines = append(lines, "")
+	}
+	stats.Platform = strings.ToLower(strings.TrimSpace(lines[0]))
+	if uptime := strings.TrimSpace(lines[1]); uptime != "" {
+		stats.Uptime = formatBootTime(uptime, time.Now())
+	}
+
+	status, exited := exitStatus(err)
+	switch {
+	case exited && status == dockerMissingExit:
+		stats.Status, stats.DockerVersion = StatusDockerMissing, ""
+		stats.StatusDetail = "docker is not installed or not in PATH"
+		return stats, nil
+	case exited:
+		stats.Status, stats.DockerVersion = StatusDockerStopped, ""
+		stats.StatusDetail = dockerError(err)
+		return stats, nil
+	case err != nil:
+		return nil, err
+	}
 
-	if len(lines) >= 2 {
-		dockerParts := strings.Split(lines[0], "|")
+	if lines[2] != "" {
+		dockerParts := strings.Split(lines[2], "|")
 		if len(dockerParts) >= 3 {
 			stats.DockerVersion = strings.TrimSpace(dockerParts[0])
 			stats.RunningContainers, _ = strconv.Atoi(strings.TrimSpace(dockerParts[1]))
 			stats.TotalContainers, _ = strconv.Atoi(strings.TrimSpace(dockerParts[2]))
 		}
-		stats.Uptime = strings.TrimSpace(lines[1])
 	}
 	return stats, nil
 }
 
-func (s *SSHClient) GetSystemStats() (float64, float64, error) {
-	session, client, err := s.CreateSession()
-	if err != nil {
-		return 0, 0, err
+// dockerError returns the stderr part of a failed docker script's error, such as "Cannot connect
+// to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?"
+func dockerError(err error) string {
+	var exit exitError
+	if !errors.As(err, &exit) {
+		return err.Error()
 	}
-	defer session.Close()
-	defer client.Close()
+	if stderr := strings.TrimPrefix(err.Error(), exit.Error()+": "); stderr != "" {
+		return stderr
+	}
+	return err.Error()
+}
 
-	var stdoutBuf bytes.Buffer
-	session.Stdout = &stdoutBuf
-	cpuCmd := "top -bn1 | grep 'Cpu(s)' | sed 's/.*, *\\([0-9.]*\\)%* id.*/\\1/' | awk '{print 100 - $1}'"
-	if err := session.Run(cpuCmd); err != nil {
-		return 0, 0, err
+func (s *SSHClient) GetServerRealtimeStats(ctx context.Context, pingTargets string) (_ *ServerStats, err error) {
+	defer s.track("realtime_stats", time.Now(), &err)
+	stats := &ServerStats{Status: StatusOffline}
+	stats.measureLatency(ctx, pingTargets, s.Addr)
+
+	if err := s.checkConnectivity(ctx); err != nil {
+		stats.StatusDetail = err.Error()
+		return stats, nil
 	}
-	cpu, _ := strconv.ParseFloat(strings.TrimSpace(stdoutBuf.String()), 64)
+	stats.Status = StatusOnline
 
-	stdoutBuf.Reset()
-	session2, client2, err := s.CreateSession()
+	di, err := s.GetDockerInfo(ctx)
 	if err != nil {
-		return cpu, 0, err
+		stats.StatusDetail = err.Error()
 	}
-	defer session2.Close()
-	defer client2.Close()
-	session2.Stdout = &stdoutBuf
-	ramCmd := "free | grep Mem | awk '{print $3/$2 * 100.0}'"
-	if err := session2.Run(ramCmd); err != nil {
-		return cpu, 0, err
+	if di != nil {
+		stats.Status, stats.StatusDetail = di.Status, di.StatusDetail
+		stats.DockerVersion = di.DockerVersion
+		stats.Uptime = di.Uptime
+		stats.RunningContainers = di.RunningContainers
+		stats.TotalContainers = di.TotalContainers
 	}
-	ram, _ := strconv.ParseFloat(strings.TrimSpace(stdoutBuf.String()), 64)
 
-	return cpu, ram, nil
-}
+	if s.platform == "" && di != nil {
+		s.platform = di.Platform
+	}
+	stats.Platform = s.platform
+	cpu, ram, err := s.GetSystemStats(ctx)
+	if err != nil {
+		stats.SystemStatsError = err.Error()
+	}
+	stats.CPUUsage = cpu
+	stats.RAMUsage = ram
 
-func (s *SSHClient) GetServerRealtimeStats(pingTargets string) (*ServerStats, error) {
-	stats := &ServerStats{Status: "offline"}
+	if load, err := s.GetLoadStats(ctx); err == nil {
+		stats.LoadAvg1, stats.LoadAvg5, stats.LoadAvg15, stats.CPUCores = load.Avg1, load.Avg5, load.Avg15, load.Cores
+		if load.Cores > 0 {
+			stats.LoadPerCore = load.Avg1 / float64(load.Cores)
+		}
+	}
 
-	// Measure latency
+	if disk, err := s.GetDiskStats(ctx); err == nil {
+		stats.DiskUsage, stats.DiskTotal, stats.DiskUsed = disk.Usage, disk.Total, disk.Used
+	}
+
+	if network, err := s.GetNetStats(ctx); err == nil {
+		stats.NetRxRate, stats.NetTxRate = network.RxRate, network.TxRate
+	}
+
+	return stats, nil
+}
+
+// measureLatency fills in the latency to the ping targets, or to self, the address the server
+// is connected to, when none are configured
+func (stats *ServerStats) measureLatency(ctx context.Context, pingTargets, self string) {
 	type TargetInfo struct {
 		Name string `json:"name"`
 		Host string `json:"host"`
@@ -163,16 +357,12 @@ func (s *SSHClient) GetServerRealtimeStats(pingTargets string) (*ServerStats, er
 	if pingTargets != "" && strings.HasPrefix(pingTargets, "[") {
 		// New JSON format
 		if err := json.Unmarshal([]byte(pingTargets), &targets); err != nil {
-			log.Printf("SSH: failed to unmarshal ping targets: %v", err)
+			slog.Warn("ssh: failed to unmarshal ping targets", "error", err)
 		}
 	}
 
 	if len(targets) == 0 {
 		// Fallback to legacy or default
-		host, _, _ := net.SplitHostPort(s.Addr)
-		if host == "" {
-			host = s.Addr
-		}
 		if pingTargets != "" && !strings.HasPrefix(pingTargets, "[") {
 			// Comma-separated legacy format
 			for _, t := range strings.Split(pingTargets, ",") {
@@ -182,16 +372,20 @@ func (s *SSHClient) GetServerRealtimeStats(pingTargets string) (*ServerStats, er
 				}
 			}
 		} else {
-			targets = append(targets, TargetInfo{Name: "Self", Host: host})
+			// Probe the server's own port when it does not answer ICMP
+			targets = append(targets, TargetInfo{Name: "Self", Host: self})
 		}
 	}
 
 	var totalLatency float64
 	var count int
 	stats.LatencyMap = make(map[string]float64)
-	for _, t := range targets {
-		l := MeasureLatency(t.Host)
-		stats.LatencyMap[t.Name] = l
+	hosts := make([]string, len(targets))
+	for i, t := range targets {
+		hosts[i] = t.Host
+	}
+	for i, l := range MeasureLatencies(ctx, hosts) {
+		stats.LatencyMap[targets[i].Name] = l
 		if l > 0 {
 			totalLatency += l
 			count++
@@ -200,59 +394,100 @@ func (s *SSHClient) GetServerRealtimeStats(pingTargets string) (*ServerStats, er
 	if count > 0 {
 		stats.Latency = totalLatency / float64(count)
 	}
+}
 
-	if !s.CheckConnectivity() {
-		return stats, nil
-	}
-	stats.Status = "online"
+// LoadStats is the load average over 1, 5 and 15 minutes and the number of online CPU cores
+type LoadStats struct {
+	Avg1, Avg5, Avg15 float64
+	Cores             int
+}
 
-	di, _ := s.GetDockerInfo()
-	if di != nil {
-		stats.DockerVersion = di.DockerVersion
-		stats.Uptime = di.Uptime
-		stats.RunningContainers = di.RunningContainers
-		stats.TotalContainers = di.TotalContainers
+// GetLoadStats reads /proc/loadavg, or vm.loadavg on BSD hosts, and the core count in one command
+func (s *SSHClient) GetLoadStats(ctx context.Context) (_ *LoadStats, err error) {
+	defer s.track("load_stats", time.Now(), &err)
+	output, err := s.runScript(ctx, "cat /proc/loadavg 2>/dev/null || sysctl -n vm.loadavg | tr -d '{}'; nproc 2>/dev/null || getconf _NPROCESSORS_ONLN", nil)
+	if err != nil {
+		return nil, err
 	}
-
-	cpu, ram, _ := s.GetSystemStats()
-	stats.CPUUsage = cpu
-	stats.RAMUsage = ram
-
-	return stats, nil
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	avgs := strings.Fields(lines[0])
+	if len(lines) < 2 || len(avgs) < 3 {
+		return nil, fmt.Errorf("unexpected load output: %q", output)
+	}
+	load := &LoadStats{}
+	var errs [4]error
+	load.Avg1, errs[0] = strconv.ParseFloat(avgs[0], 64)
+	load.Avg5, errs[1] = strconv.ParseFloat(avgs[1], 64)
+	load.Avg15, errs[2] = strconv.ParseFloat(avgs[2], 64)
+	load.Cores, errs[3] = strconv.Atoi(strings.TrimSpace(lines[1]))
+	if err := errors.Join(errs[:]...); err != nil {
+		return nil, fmt.Errorf("unexpected load output: %w", err)
+	}
+	return load, nil
 }
 
-func MeasureLatency(target string) float64 {
-	start := time.Now()
+// DiskStats is the usage of the filesystem holding Docker's data, in bytes. Usage is the used
+// share in percent as df reports it, which excludes blocks reserved for root.
+type DiskStats struct {
+	Usage float64
+	Total int64
+	Used  int64
+}
 
-	// Try ICMP ping first via os/exec
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("ping", "-n", "1", "-w", "1000", target)
-	} else {
-		cmd = exec.Command("ping", "-c", "1", "-W", "1", target)
+// GetDiskStats reads the usage of the filesystem of /var/lib/docker, or of / on hosts without it
+func (s *SSHClient) GetDiskStats(ctx context.Context) (_ *DiskStats, err error) {
+	defer s.track("disk_stats", time.Now(), &err)
+	output, err := s.runScript(ctx, "df -P -B1 /var/lib/docker 2>/dev/null || df -P -B1 /", nil)
+	if err != nil {
+		return nil, err
 	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	// Filesystem 1-blocks Used Available Capacity Mounted-on
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("unexpected df output: %q", output)
+	}
+	total, err1 := strconv.ParseInt(fields[1], 10, 64)
+	used, err2 := strconv.ParseInt(fields[2], 10, 64)
+	available, err3 := strconv.ParseInt(fields[3], 10, 64)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, fmt.Errorf("unexpected df output: %w", err)
+	}
+	disk := &DiskStats{Total: total, Used: used}
+	if used+available > 0 {
+		disk.Usage = float64(used) / float64(used+available) * 100
+	}
+	return disk, nil
+}
 
-	err := cmd.Run()
-	if err == nil {
-		return float64(time.Since(start).Milliseconds())
+// GetContainers returns "docker ps -a" output with one JSON object per container. Docker versions
+// without the json template function print the legacy format, with the fields
+// ID|Names|Image|Status|State|Ports|CreatedAt|ComposeProject|ComposeService on each line.
+func (s *SSHClient) GetContainers(ctx context.Context) (_ string, err error) {
+	defer s.track("list_containers", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, "docker ps -a --format '{{json .}}'", nil)
+	if err == nil || !isExitError(err) {
+		return output, err
 	}
+	return s.runDockerScript(ctx, "docker ps -a --format '{{.ID}}|{{.Names}}|{{.Image}}|{{.Status}}|{{.State}}|{{.Ports}}|{{.CreatedAt}}|{{.Label \"com.docker.compose.project\"}}|{{.Label \"com.docker.compose.service\"}}'", nil)
+}
 
-	// Fallback to TCP check if ICMP fails (common in restricted environments)
-	// We try common ports if no port specified
-	ports := []string{"80", "443", "22"}
-	for _, p := range ports {
-		conn, err := net.DialTimeout("tcp", net.JoinHostPort(target, p), time.Second)
-		if err == nil {
-			conn.Close()
-			return float64(time.Since(start).Milliseconds())
-		}
+// GetContainerStats returns a single "docker stats" reading of the running containers, one JSON
+// object per line, or "|" separated fields on docker versions without the json template function
+func (s *SSHClient) GetContainerStats(ctx context.Context) (_ string, err error) {
+	defer s.track("container_stats", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, "docker stats --no-stream --format "+ShellQuote(containerStatsFormats[0]), nil)
+	if err == nil || !isExitError(err) {
+		return output, err
 	}
-
-	return 0
+	return s.runDockerScript(ctx, "docker stats --no-stream --format "+ShellQuote(containerStatsFormats[1]), nil)
 }
 
-func (s *SSHClient) GetContainers() (string, error) {
-	session, client, err := s.CreateSession()
+// ListListeners returns the host's listening TCP and UDP sockets as printed by "ss -Htulnp".
+// Process names are only included when the SSH user may see them.
+func (s *SSHClient) ListListeners(ctx context.Context) (_ string, err error) {
+	defer s.track("list_listeners", time.Now(), &err)
+	session, client, err := s.CreateSession(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -261,71 +496,97 @@ func (s *SSHClient) GetContainers() (string, error) {
 
 	var stdoutBuf bytes.Buffer
 	session.Stdout = &stdoutBuf
-	cmd := "docker ps -a --format '{{.ID}}|{{.Names}}|{{.Image}}|{{.Status}}|{{.State}}|{{.Ports}}|{{.CreatedAt}}'"
-	if err := session.Run(cmd); err != nil {
+	if err := client.run(ctx, session, "ss -Htulnp"); err != nil {
 		return "", err
 	}
 	return stdoutBuf.String(), nil
 }
 
-func (s *SSHClient) ExecuteContainerAction(containerID, action string) error {
-	session, client, err := s.CreateSession()
-	if err != nil {
-		return err
-	}
-	defer session.Close()
-	defer client.Close()
-
+func (s *SSHClient) ExecuteContainerAction(ctx context.Context, containerID, action string) (err error) {
+	defer s.track("container_action", time.Now(), &err)
 	var cmd string
 	switch action {
 	case "start":
-		cmd = fmt.Sprintf("docker start %s", containerID)
+		cmd = "docker start " + ShellQuote(containerID)
 	case "stop":
-		cmd = fmt.Sprintf("docker stop %s", containerID)
+		cmd = "docker stop " + ShellQuote(containerID)
 	case "restart":
-		cmd = fmt.Sprintf("docker restart %s", containerID)
+		cmd = "docker restart " + ShellQuote(containerID)
 	case "remove":
-		cmd = fmt.Sprintf("docker rm -f %s", containerID)
+		cmd = "docker rm -f " + ShellQuote(containerID)
+	case "pause", "unpause":
+		cmd = "docker " + action + " " + ShellQuote(containerID)
+	case "kill":
+		return s.KillContainer(ctx, containerID, "SIGKILL")
 	case "pull": // This is for updating the image
 		// We'll handle image pull separately if needed, but for the "update" button,
 		// usually we pull then recreate. For now, just pull.
-		return s.PullImageByContainer(containerID)
+		return s.PullImageByContainer(ctx, containerID, nil)
 	default:
 		return fmt.Errorf("unsupported action")
 	}
+	// Docker's message is kept, for the errors of a container in the wrong state
+	_, err = s.execCommand(ctx, cmd, true)
+	return dockerapi.StateConflict(err)
+}
 
-	return session.Run(cmd)
+// KillContainer sends a container a signal with docker kill
+func (s *SSHClient) KillContainer(ctx context.Context, containerID, signal string) (err error) {
+	defer s.track("container_action", time.Now(), &err)
+	_, err = s.execCommand(ctx, "docker kill --signal "+ShellQuote(signal)+" "+ShellQuote(containerID), true)
+	return dockerapi.StateConflict(err)
 }
 
-func (s *SSHClient) PullImageByContainer(containerID string) error {
-	session, client, err := s.CreateSession()
+// PullImageByContainer pulls the image a container was created from, writing what "docker pull"
+// prints to output as it arrives when output is not nil
+func (s *SSHClient) PullImageByContainer(ctx context.Context, containerID string, output io.Writer) (err error) {
+	defer s.track("pull_image", time.Now(), &err)
+	// Get image name first
+	inspectCmd := "docker inspect --format '{{.Config.Image}}' " + ShellQuote(containerID)
+	imageOutput, err := s.execCommand(ctx, inspectCmd, true)
 	if err != nil {
 		return err
 	}
-	defer session.Close()
-	defer client.Close()
+	return s.pullImage(ctx, strings.TrimSpace(imageOutput), output)
+}
 
-	var stdoutBuf bytes.Buffer
-	session.Stdout = &stdoutBuf
-	// Get image name first
-	inspectCmd := fmt.Sprintf("docker inspect --format '{{.Config.Image}}' %s", containerID)
-	if err := session.Run(inspectCmd); err != nil {
-		return err
-	}
-	imageName := strings.TrimSpace(stdoutBuf.String())
+// PullImageRef pulls an image by its reference, writing what "docker pull" prints to output like
+// PullImageByContainer
+func (s *SSHClient) PullImageRef(ctx context.Context, imageName string, output io.Writer) (err error) {
+	defer s.track("pull_image", time.Now(), &err)
+	return s.pullImage(ctx, imageName, output)
+}
 
-	stdoutBuf.Reset()
-	session2, client2, err := s.CreateSession()
-	if err != nil {
-		return err
-	}
-	defer session2.Close()
-	defer client2.Close()
-	return session2.Run(fmt.Sprintf("docker pull %s", imageName))
+// pullImage runs "docker pull", with the registry login stored for the image when there is one
+func (s *SSHClient) pullImage(ctx context.Context, imageName string, output io.Writer) error {
+	script, stdin := "docker pull "+ShellQuote(imageName), []byte(nil)
+	if host, creds := registryCredentials(s.ServerID, imageName); creds != nil {
+		// A throwaway docker config read from stdin logs in for this pull only, so the login is
+		// neither left on the host nor visible in the process list
+		script = `set -e; dir=$(mktemp -d); trap 'rm -rf "$dir"' EXIT; cat > "$dir/config.json"; docker --config "$dir" pull ` + ShellQuote(imageName)
+		stdin = registry.DockerConfig(host, *creds)
+	}
+	// Pulls may take longer than the command timeout
+	return s.streamDockerScript(WithoutCommandTimeout(ctx), script, stdin, output)
+}
+
+func (s *SSHClient) ExecuteCommand(ctx context.Context, cmd string) (_ string, err error) {
+	defer s.track("exec", time.Now(), &err)
+	return s.execCommand(ctx, cmd, false)
 }
 
-func (s *SSHClient) ExecuteCommand(cmd string) (string, error) {
-	session, client, err := s.CreateSession()
+// ExecuteDockerCommand is ExecuteCommand for commands that run docker, which are run through
+// sudo when the server is configured for it
+func (s *SSHClient) ExecuteDockerCommand(ctx context.Context, cmd string) (_ string, err error) {
+	defer s.track("exec", time.Now(), &err)
+	return s.execCommand(ctx, cmd, true)
+}
+
+func (s *SSHClient) execCommand(ctx context.Context, cmd string, docker bool) (string, error) {
+	if s.agent != nil {
+		return commandResult(s.agentExec(ctx, cmd, nil))
+	}
+	session, client, err := s.CreateSession(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -336,11 +597,17 @@ func (s *SSHClient) ExecuteCommand(cmd string) (string, error) {
 	var stderrBuf bytes.Buffer
 	session.Stdout = &stdoutBuf
 	session.Stderr = &stderrBuf
+	if docker {
+		cmd = s.sudo(session, cmd)
+	}
 
-	err = session.Run(cmd)
-	output := stdoutBuf.String()
-	stderr := stderrBuf.String()
+	err = client.run(ctx, session, cmd)
+	return commandResult(stdoutBuf.String(), stderrBuf.String(), err)
+}
 
+// commandResult returns the output of a command run by execCommand, with stderr appended when it
+// failed
+func commandResult(output, stderr string, err error) (string, error) {
 	if err != nil {
 		fullOutput := output
 		if stderr != "" {
@@ -349,30 +616,55 @@ func (s *SSHClient) ExecuteCommand(cmd string) (string, error) {
 			}
 			fullOutput += stderr
 		}
-		return fullOutput, fmt.Errorf("command failed: %v, output: %s", err, fullOutput)
+		return fullOutput, fmt.Errorf("command failed: %w, output: %s", err, fullOutput)
 	}
 	return output, nil
 }
 
-func (s *SSHClient) GetContainerLogs(containerID, tail string) (string, error) {
-	session, client, err := s.CreateSession()
-	if err != nil {
-		return "", err
+// GetEvents streams the container events of dockerapi.ContainerEventActions, one JSON object
+// per line. The reader must be closed.
+func (s *SSHClient) GetEvents(ctx context.Context) (_ io.ReadCloser, err error) {
+	defer s.track("events", time.Now(), &err)
+	cmd := "docker events --format '{{json .}}' --filter type=container"
+	for _, action := range dockerapi.ContainerEventActions {
+		cmd += " --filter event=" + action
 	}
-	defer session.Close()
-	defer client.Close()
+	return s.StreamCommand(ctx, cmd)
+}
 
-	var stdoutBuf bytes.Buffer
-	session.Stdout = &stdoutBuf
-	cmd := fmt.Sprintf("docker logs --tail %s %s", tail, containerID)
-	if err := session.Run(cmd); err != nil {
-		return "", err
-	}
-	return stdoutBuf.String(), nil
+// GetContainerLogs streams the part of the container's log opts selects, with the lines of its
+// stderr prefixed with dockerapi.StderrPrefix and merged into stdout. The reader must be closed.
+func (s *SSHClient) GetContainerLogs(ctx context.Context, containerID string, opts dockerapi.LogOptions) (_ io.ReadCloser, err error) {
+	defer s.track("container_logs", time.Now(), &err)
+	id := ShellQuote(containerID)
+	cmd := "docker logs --tail " + ShellQuote(opts.Tail)
+	if !opts.Since.IsZero() {
+		cmd += " --since " + opts.Since.UTC().Format(time.RFC3339Nano)
+	}
+	if !opts.Until.IsZero() {
+		cmd += " --until " + opts.Until.UTC().Format(time.RFC3339Nano)
+	}
+	if opts.Timestamps {
+		cmd += " --timestamps"
+	}
+	// docker logs replays the container's stderr on its own, which then goes through sed while
+	// stdout is passed on unchanged. Errors of docker logs would end up in the output too, so a
+	// missing container is noticed beforehand.
+	script := fmt.Sprintf("docker inspect --type container --format '{{.Id}}' %s >/dev/null || exit\n"+
+		"{ %s %s 2>&1 1>&3 3>&- | sed 's/^/%s/'; } 3>&1", id, cmd, id, dockerapi.StderrPrefix)
+	return s.StreamCommand(ctx, script)
+}
+
+// FollowContainerLogs streams the container's stdout log like GetContainerLogs and keeps
+// following it until the container stops. Closing the reader kills "docker logs".
+func (s *SSHClient) FollowContainerLogs(ctx context.Context, containerID, tail string) (_ io.ReadCloser, err error) {
+	defer s.track("follow_logs", time.Now(), &err)
+	return s.StreamCommand(ctx, fmt.Sprintf("docker logs --follow --tail %s %s", ShellQuote(tail), ShellQuote(containerID)))
 }
 
-func (s *SSHClient) GetContainerDetails(containerID string) (string, error) {
-	session, client, err := s.CreateSession()
+func (s *SSHClient) GetContainerDetails(ctx context.Context, containerID string) (_ string, err error) {
+	defer s.track("inspect", time.Now(), &err)
+	session, client, err := s.CreateSession(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -381,58 +673,40 @@ func (s *SSHClient) GetContainerDetails(containerID string) (string, error) {
 
 	var stdoutBuf bytes.Buffer
 	session.Stdout = &stdoutBuf
-	cmd := fmt.Sprintf("docker inspect %s", containerID)
-	if err := session.Run(cmd); err != nil {
+	cmd := "docker inspect " + ShellQuote(containerID)
+	if err := client.run(ctx, session, s.sudo(session, cmd)); err != nil {
 		return "", err
 	}
 	return stdoutBuf.String(), nil
 }
 
-func (s *SSHClient) CheckForImageUpdate(containerID string) (bool, error) {
-	session, client, err := s.CreateSession()
-	if err != nil {
-		return false, err
-	}
-	defer session.Close()
-	defer client.Close()
-
-	var stdoutBuf bytes.Buffer
-	session.Stdout = &stdoutBuf
-
-	// 1. Get image name
-	if err := session.Run(fmt.Sprintf("docker inspect --format '{{.Config.Image}}' %s", containerID)); err != nil {
-		return false, err
-	}
-	imageName := strings.TrimSpace(stdoutBuf.String())
-	stdoutBuf.Reset()
+// ImageReference is the image a container was created from
+type ImageReference struct {
+	// Image is the reference the container was created from, e.g. "postgres:15"
+	Image string
+	// RepoDigests are the repo digests of the image the container runs, e.g. "postgres@sha256:..."
+	RepoDigests []string
+	// Platform is the platform of the image the container runs, e.g. "linux/arm64/v8"
+	Platform string
+}
 
-	// 2. Get local digest
-	session2, client2, _ := s.CreateSession()
-	defer session2.Close()
-	defer client2.Close()
-	session2.Stdout = &stdoutBuf
-	if err := session2.Run(fmt.Sprintf("docker inspect --format '{{index .RepoDigests 0}}' %s", imageName)); err != nil {
-		return true, nil // If can't inspect local image digest, assume update might be needed
+// GetImageReference returns the image reference a container was created from and the repo
+// digests and platform of the image it runs
+func (s *SSHClient) GetImageReference(ctx context.Context, containerID string) (_ ImageReference, err error) {
+	defer s.track("image_reference", time.Now(), &err)
+	script := fmt.Sprintf(`set -e; docker inspect --format '{{.Config.Image}}' %[1]s; `+
+		`docker image inspect --format '{{.Os}}/{{.Architecture}}{{with .Variant}}/{{.}}{{end}} {{json .RepoDigests}}' "$(docker inspect --format '{{.Image}}' %[1]s)"`, ShellQuote(containerID))
+	output, err := s.runDockerScript(ctx, script, nil)
+	if err != nil {
+		return ImageReference{}, err
 	}
-	localDigest := strings.TrimSpace(stdoutBuf.String())
-	stdoutBuf.Reset()
-
-	// 3. Try to get remote digest (requires docker manifest or experimental)
-	// Fallback: Use a simpler check or just return false for now to avoid overhead if manifest is missing
-	// For this task, I'll implement a basic check using `docker manifest inspect`
-	session3, client3, _ := s.CreateSession()
-	defer session3.Close()
-	defer client3.Close()
-	session3.Stdout = &stdoutBuf
-	remoteCmd := fmt.Sprintf("docker manifest inspect %s 2>/dev/null | jq -r '.RepoDigests[0]' 2>/dev/null || echo ''", imageName)
-	_ = session3.Run(remoteCmd)
-	remoteDigest := strings.TrimSpace(stdoutBuf.String())
-
-	if remoteDigest != "" && localDigest != "" && remoteDigest != localDigest {
-		return true, nil
+	image, rest, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	platform, digestsJSON, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	ref := ImageReference{Image: strings.TrimSpace(image), Platform: platform}
+	if err := json.Unmarshal([]byte(digestsJSON), &ref.RepoDigests); err != nil {
+		return ImageReference{}, fmt.Errorf("failed to parse repo digests: %v", err)
 	}
-
-	return false, nil
+	return ref, nil
 }
 
 // Helper function to convert symbolic mode string to octal permissions string
@@ -465,28 +739,57 @@ func modeToOctal(mode string) string {
 	return octal
 }
 
-func (s *SSHClient) ListContainerFiles(containerID, path string) ([]model.FileEntry, error) {
+// symlinkDirsMarker separates the ls output of listFilesScript from the symlinks to directories.
+// ls lines start with a file type or "total", never with "#".
+const symlinkDirsMarker = "#symlinked-dirs"
+
+// listFilesScript lists the directory $1 with ls, then prints the names of the symlinks in it
+// that resolve to directories, one per line after symlinkDirsMarker.
+const listFilesScript = `ls -la --time-style=long-iso -- "$1" 2>/dev/null || ls -la -- "$1" || exit
+echo '` + symlinkDirsMarker + `'
+cd -- "$1" 2>/dev/null || exit 0
+for f in * .*; do [ -L "$f" ] && [ -d "$f" ] && echo "$f"; done
+exit 0`
+
+// ListContainerFiles lists a directory of a container. Symlinks carry their target, and those
+// that resolve to directories are marked as directories too, so they can be opened like one.
+func (s *SSHClient) ListContainerFiles(ctx context.Context, containerID, path string) (_ []model.FileEntry, err error) {
+	defer s.track("list_files", time.Now(), &err)
 	// Use sh -c to try multiple ls variants for compatibility (Alpine/BusyBox vs GNU)
 	// We prefer long-iso for easier parsing if available.
-	cmd := fmt.Sprintf("docker exec %s sh -c \"ls -la --time-style=long-iso %s 2>/dev/null || ls -la %s\"", containerID, path, path)
-	output, err := s.ExecuteCommand(cmd)
+	cmd := fmt.Sprintf("docker exec %s sh -c %s sh %s", ShellQuote(containerID), ShellQuote(listFilesScript), ShellQuote(path))
+	output, err := s.ExecuteDockerCommand(ctx, cmd)
 	if err != nil {
 		// Check for specific common failures
 		if strings.Contains(output, "is not running") {
 			return nil, fmt.Errorf("container is not running")
 		}
 		if strings.Contains(output, "executable file not found") {
-			return nil, fmt.Errorf("ls command not found in container (minimal image)")
+			// Minimal image without ls
+			return s.listContainerArchive(ctx, containerID, path)
 		}
 		return nil, err
 	}
+	return parseFileList(output), nil
+}
+
+// parseFileList parses the output of listFilesScript
+func parseFileList(output string) []model.FileEntry {
+	output, symlinked, _ := strings.Cut(output, symlinkDirsMarker+"\n")
+	symlinkDirs := map[string]bool{}
+	for _, name := range strings.Split(symlinked, "\n") {
+		if name != "" {
+			symlinkDirs[name] = true
+		}
+	}
 
 	lines := strings.Split(output, "\n")
-	var files []model.FileEntry
+	files := []model.FileEntry{}
 
 	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "total") {
+		// Only the line break is trimmed, names may end with spaces
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "total") {
 			continue
 		}
 
@@ -514,11 +817,12 @@ func (s *SSHClient) ListContainerFiles(containerID, path string) ([]model.FileEn
 			// Likely long-iso: 2024-01-27 06:17
 			dateStr := parts[5] + " " + parts[6]
 			modTime, _ = time.Parse("2006-01-02 15:04", dateStr)
-			name = strings.Join(parts[7:], " ")
+			name = lsName(line, 7)
 		} else if len(parts) >= 9 {
 			// Likely standard: Jan 27 06:17 or Jan 27 2024
 			dateStr := parts[5] + " " + parts[6] + " " + parts[7]
 			// Try parsing both common formats
+			var err error
 			modTime, err = time.Parse("Jan _2 15:04", dateStr)
 			if err != nil {
 				modTime, _ = time.Parse("Jan _2 2006", dateStr)
@@ -527,16 +831,18 @@ func (s *SSHClient) ListContainerFiles(containerID, path string) ([]model.FileEn
 			if modTime.Year() == 0 {
 				modTime = modTime.AddDate(time.Now().Year(), 0, 0)
 			}
-			name = strings.Join(parts[8:], " ")
+			name = lsName(line, 8)
 		} else {
 			// Fallback: name is just the last part, and we can't be sure about the date
 			name = parts[len(parts)-1]
 		}
 
+		var linkTarget string
 		if isSymlink {
 			if idx := strings.Index(name, " -> "); idx != -1 {
-				name = name[:idx]
+				name, linkTarget = name[:idx], name[idx+len(" -> "):]
 			}
+			isDir = symlinkDirs[name]
 		}
 
 		if name == "." || name == ".." {
@@ -549,21 +855,47 @@ func (s *SSHClient) ListContainerFiles(containerID, path string) ([]model.FileEn
 			Mode:        mode,
 			IsDir:       isDir,
 			IsSymlink:   isSymlink,
+			LinkTarget:  linkTarget,
 			ModTime:     modTime,
 			Permissions: modeToOctal(mode),
 		})
 	}
 
-	return files, nil
+	return files
+}
+
+// lsName returns a line of ls -l from its n-th field on, which is the file name, keeping the
+// spaces within it that splitting the line into fields would lose
+func lsName(line string, n int) string {
+	for i := 0; i < n; i++ {
+		line = strings.TrimLeft(line, " ")
+		j := strings.IndexByte(line, ' ')
+		if j < 0 {
+			return ""
+		}
+		line = line[j:]
+	}
+	return strings.TrimLeft(line, " ")
 }
 
-func (s *SSHClient) GetContainerFileContent(containerID, path string) (string, error) {
-	// Use 'cat' to read file content
-	cmd := fmt.Sprintf("docker exec %s cat %s", containerID, path)
-	output, err := s.ExecuteCommand(cmd)
+// GetContainerFileContent reads a file out of a container and returns its content and size. A
+// file larger than max bytes fails with ErrFileTooLarge once that is known, without reading the
+// rest; the size is then -1 when the file had to be read with cat.
+func (s *SSHClient) GetContainerFileContent(ctx context.Context, containerID, path string, max int64) (_ []byte, size int64, err error) {
+	defer s.track("read_file", time.Now(), &err)
+	content := &cappedBuffer{max: max}
+	err = s.CopyContainerFile(ctx, containerID, path, func(file model.FileEntry) (io.Writer, error) {
+		size = file.Size
+		if size > max {
+			return nil, ErrFileTooLarge
+		}
+		return content, nil
+	})
+	if content.exceeded || errors.Is(err, ErrFileTooLarge) {
+		return nil, size, ErrFileTooLarge
+	}
 	if err != nil {
-		// If cat fails (e.g., directory or binary file), return the error message
-		return "", fmt.Errorf("failed to read file content: %v", err)
+		return nil, 0, fmt.Errorf("failed to read file content: %w", err)
 	}
-	return output, nil
+	return content.Bytes(), int64(content.Len()), nil
 }
@@ -0,0 +1,147 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// listing is what a test expects of a FileEntry
+type listing struct {
+	isDir, isSymlink bool
+	linkTarget       string
+}
+
+func checkListing(t *testing.T, files []model.FileEntry, want map[string]listing) {
+	t.Helper()
+	got := map[string]listing{}
+	for _, f := range files {
+		got[f.Name] = listing{f.IsDir, f.IsSymlink, f.LinkTarget}
+	}
+	for name, w := range want {
+		g, ok := got[name]
+		if !ok {
+			t.Errorf("%q is missing from %q", name, names(files))
+			continue
+		}
+		if g != w {
+			t.Errorf("%q = %+v, want %+v", name, g, w)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("listed %q, want %d entries", names(files), len(want))
+	}
+}
+
+func names(files []model.FileEntry) []string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Name
+	}
+	return names
+}
+
+func TestParseFileList(t *testing.T) {
+	want := map[string]listing{
+		"with  two spaces": {},
+		"ünïcödé.txt":      {},
+		"日本語":              {isDir: true},
+		"dangling":         {isSymlink: true, linkTarget: "/nowhere"},
+		"data":             {isDir: true, isSymlink: true, linkTarget: "/srv/data"},
+		"to space":         {isSymlink: true, linkTarget: "with  two spaces"},
+	}
+	for tool, output := range map[string]string{
+		"coreutils": `total 16
+drwxr-xr-x 3 root root 4096 2024-03-01 12:30 .
+drwxr-xr-x 1 root root 4096 2024-03-01 12:00 ..
+-rw-r--r-- 1 root root   12 2024-03-01 12:30 with  two spaces
+-rw-r--r-- 1 root root    5 2024-03-01 12:30 ünïcödé.txt
+drwxr-xr-x 2 root root 4096 2024-03-01 12:30 日本語
+lrwxrwxrwx 1 root root    8 2024-03-01 12:30 dangling -> /nowhere
+lrwxrwxrwx 1 root root    9 2024-03-01 12:30 data -> /srv/data
+lrwxrwxrwx 1 root root   16 2024-03-01 12:30 to space -> with  two spaces
+#symlinked-dirs
+data
+`,
+		"busybox": `total 16
+drwxr-xr-x    3 root     root          4096 Mar  1 12:30 .
+drwxr-xr-x    1 root     root          4096 Mar  1 12:00 ..
+-rw-r--r--    1 root     root            12 Mar  1 12:30 with  two spaces
+-rw-r--r--    1 root     root             5 Mar  1 12:30 ünïcödé.txt
+drwxr-xr-x    2 root     root          4096 Mar  1  2023 日本語
+lrwxrwxrwx    1 root     root             8 Mar  1 12:30 dangling -> /nowhere
+lrwxrwxrwx    1 root     root             9 Mar  1 12:30 data -> /srv/data
+lrwxrwxrwx    1 root     root            16 Mar  1 12:30 to space -> with  two spaces
+#symlinked-dirs
+data
+`,
+	} {
+		t.Run(tool, func(t *testing.T) {
+			files := parseFileList(output)
+			checkListing(t, files, want)
+			for _, f := range files {
+				if f.ModTime.Month() != time.March || f.ModTime.Day() != 1 {
+					t.Errorf("%q was modified %v, want on March 1", f.Name, f.ModTime)
+				}
+			}
+		})
+	}
+}
+
+// TestListContainerFiles lists a directory with the script the container runs, in place of the
+// container's ls
+func TestListContainerFiles(t *testing.T) {
+	host := &argvHost{t: t, dir: t.TempDir()}
+	client := &SSHClient{Config: testClientConfig(), Addr: startTestServer(t, host.exec, nil)}
+	client.SetConnectionMode(model.ConnectionModeCLI)
+
+	dir := filepath.Join(host.dir, "app data")
+	for _, d := range []string{"日本語", "sub dir"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"with  two spaces", "trailing space ", "ünïcödé.txt", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"dangling":    "/nowhere/at all",
+		"to sub dir":  "sub dir",
+		".hidden dir": "日本語",
+		"to file":     "ünïcödé.txt",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := client.ListContainerFiles(context.Background(), "web", dir)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	checkListing(t, files, map[string]listing{
+		"日本語":              {isDir: true},
+		"sub dir":          {isDir: true},
+		"with  two spaces": {},
+		"trailing space ":  {},
+		"ünïcödé.txt":      {},
+		".hidden":          {},
+		// Links to directories can be opened, dangling ones are kept with their target
+		"dangling":    {isSymlink: true, linkTarget: "/nowhere/at all"},
+		"to sub dir":  {isDir: true, isSymlink: true, linkTarget: "sub dir"},
+		".hidden dir": {isDir: true, isSymlink: true, linkTarget: "日本語"},
+		"to file":     {isSymlink: true, linkTarget: "ünïcödé.txt"},
+	})
+
+	// Reading through a dangling link finds no file
+	if _, _, _, err := client.ReadContainerFileRange(context.Background(), "web", filepath.Join(dir, "dangling"), FileRange{Head: 1}, 1024); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("reading through a dangling link = %v, want ErrFileNotFound", err)
+	}
+}
@@ -0,0 +1,34 @@
+package ssh
+
+import (
+	"strconv"
+	"time"
+
+	"docker-pulse/internal/metrics"
+)
+
+var (
+	// Operations counts SSH operations by operation, server and result ("ok" or "error")
+	Operations = metrics.NewCounterVec("dockermanager_ssh_operations_total",
+		"SSH operations by operation, server and result.", "operation", "server_id", "result")
+	// OperationDuration records how long SSH operations take by operation and server
+	OperationDuration = metrics.NewHistogramVec("dockermanager_ssh_operation_duration_seconds",
+		"Duration of SSH operations.", metrics.DefaultBuckets, "operation", "server_id")
+)
+
+// track records an SSH operation that started at start and failed when *err is set.
+// Labels are bounded to the operation name and server ID.
+func (s *SSHClient) track(op string, start time.Time, err *error) {
+	trackServer(s.ServerID, op, start, err)
+}
+
+// trackServer records an operation on a server like track, for servers reached without SSH too
+func trackServer(serverID uint, op string, start time.Time, err *error) {
+	server := strconv.FormatUint(uint64(serverID), 10)
+	result := "ok"
+	if *err != nil {
+		result = "error"
+	}
+	Operations.Inc(op, server, result)
+	OperationDuration.Observe(time.Since(start).Seconds(), op, server)
+}
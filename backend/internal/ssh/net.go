@@ -0,0 +1,148 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ValidInterfaceName matches names Linux accepts for network interfaces
+var ValidInterfaceName = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,15}$`)
+
+// netSampleMaxAge is how long a stored counter sample may be used as the start of the next
+// rate; older ones are replaced by two readings a second apart
+const netSampleMaxAge = 5 * time.Minute
+
+// netSampleMarker separates the readings in the output of netScript
+const netSampleMarker = "--sample--"
+
+// netSample is a reading of an interface's counters. Uptime is the host's /proc/uptime, so
+// intervals are measured on the host rather than from when the output arrived.
+type netSample struct {
+	iface  string
+	rx, tx uint64
+	uptime float64
+	// rxRate and txRate are the rates computed when the sample was taken
+	rxRate, txRate float64
+	at             time.Time
+}
+
+// netSamples keeps the last sample per address, so most rate readings cost a single read
+var netSamples sync.Map // addr -> netSample
+
+// NetStats is the receive and transmit rate of a network interface in bytes per second
+type NetStats struct {
+	Interface string
+	RxRate    float64
+	TxRate    float64
+}
+
+// SetNetInterface sets the interface network rates are read from, instead of the one of the
+// default route
+func (s *SSHClient) SetNetInterface(name string) {
+	s.netInterface = name
+}
+
+// GetNetStats reads the traffic of the client's network interface from /proc/net/dev. Rates are
+// computed against the previous reading of the host, or from two readings a second apart when
+// there is none. Hosts without /proc report zero rates.
+func (s *SSHClient) GetNetStats(ctx context.Context) (_ *NetStats, err error) {
+	v, known := netSamples.Load(s.Addr)
+	prev, _ := v.(netSample)
+	if known && (s.netInterface == "" || prev.iface == s.netInterface) && time.Since(prev.at) < time.Second {
+		return &NetStats{Interface: prev.iface, RxRate: prev.rxRate, TxRate: prev.txRate}, nil
+	}
+	defer s.track("net_stats", time.Now(), &err)
+	usable := known && time.Since(prev.at) < netSampleMaxAge && (s.netInterface == "" || prev.iface == s.netInterface)
+	output, err := s.runScript(ctx, netScript(s.netInterface, !usable), nil)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := parseNetSamples(output)
+	if err != nil || len(samples) == 0 {
+		return &NetStats{}, err
+	}
+	if usable {
+		samples = append([]netSample{prev}, samples...)
+	}
+	last := samples[len(samples)-1]
+	if len(samples) > 1 {
+		last.rxRate, last.txRate = netRates(samples[len(samples)-2], last)
+	}
+	last.at = time.Now()
+	netSamples.Store(s.Addr, last)
+	return &NetStats{Interface: last.iface, RxRate: last.rxRate, TxRate: last.txRate}, nil
+}
+
+// netScript prints the interface name, the host's uptime and /proc/net/dev, twice with a
+// second in between when twice is set. Nothing is printed without /proc/net/dev.
+func netScript(iface string, twice bool) string {
+	script := `[ -r /proc/net/dev ] || exit 0
+iface=` + ShellQuote(iface) + `
+[ -n "$iface" ] || iface=$(awk '$2 == "00000000" { print $1; exit }' /proc/net/route 2>/dev/null)
+sample() { echo ` + netSampleMarker + `; echo "$iface"; cat /proc/uptime /proc/net/dev; }
+sample`
+	if twice {
+		script += "\nsleep 1\nsample"
+	}
+	return script
+}
+
+// parseNetSamples parses netScript output. A reading without the interface, such as on hosts
+// without a default route, is left out.
+func parseNetSamples(output string) ([]netSample, error) {
+	var samples []netSample
+	for _, block := range strings.Split(output, netSampleMarker+"\n")[1:] {
+		lines := strings.Split(block, "\n")
+		if len(lines) < 2 || lines[0] == "" {
+			continue
+		}
+		sample := netSample{iface: strings.TrimSpace(lines[0])}
+		uptime := strings.Fields(lines[1])
+		if len(uptime) == 0 {
+			return nil, fmt.Errorf("unexpected /proc/uptime output: %q", lines[1])
+		}
+		var err error
+		if sample.uptime, err = strconv.ParseFloat(uptime[0], 64); err != nil {
+			return nil, fmt.Errorf("unexpected /proc/uptime output: %w", err)
+		}
+		found := false
+		for _, line := range lines[2:] {
+			name, counters, ok := strings.Cut(line, ":")
+			if !ok || strings.TrimSpace(name) != sample.iface {
+				continue
+			}
+			// Receive bytes is the first field, transmit bytes the ninth
+			fields := strings.Fields(counters)
+			if len(fields) < 9 {
+				return nil, fmt.Errorf("unexpected /proc/net/dev line: %q", line)
+			}
+			rx, err1 := strconv.ParseUint(fields[0], 10, 64)
+			tx, err2 := strconv.ParseUint(fields[8], 10, 64)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("unexpected /proc/net/dev line: %q", line)
+			}
+			sample.rx, sample.tx = rx, tx
+			found = true
+			break
+		}
+		if found {
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// netRates returns the rates between two readings, zero when they are of different interfaces
+// or the counters were reset by a reboot or the interface being recreated
+func netRates(from, to netSample) (rx, tx float64) {
+	elapsed := to.uptime - from.uptime
+	if from.iface != to.iface || elapsed <= 0 || to.rx < from.rx || to.tx < from.tx {
+		return 0, 0
+	}
+	return float64(to.rx-from.rx) / elapsed, float64(to.tx-from.tx) / elapsed
+}
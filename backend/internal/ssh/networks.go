@@ -0,0 +1,249 @@
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+)
+
+var (
+	// ErrNetworkNotFound is returned for networks that do not exist
+	ErrNetworkNotFound = errors.New("the network does not exist")
+	// ErrNetworkInUse is returned when removing a network containers are attached to
+	ErrNetworkInUse = errors.New("the network is in use")
+)
+
+// networkInspect is a network as docker network inspect prints it
+type networkInspect struct {
+	ID         string `json:"Id"`
+	Name       string
+	Created    string
+	Scope      string
+	Driver     string
+	EnableIPv6 bool
+	Internal   bool
+	Attachable bool
+	IPAM       struct {
+		Config []struct {
+			Subnet  string
+			Gateway string
+			IPRange string
+		}
+	}
+	Containers map[string]struct {
+		Name        string
+		MacAddress  string
+		IPv4Address string
+		IPv6Address string
+	}
+	Options map[string]string
+	Labels  map[string]string
+}
+
+// networkExistsScript exits with missingExit unless the network named by the quoted name exists.
+// Listing first tells a missing network from a failing docker.
+func networkExistsScript(name string) string {
+	return fmt.Sprintf(`set -e; all=$(docker network ls --format '{{.Name}}'); printf '%%s\n' "$all" | grep -Fxq -- %s || exit %d; `, name, missingExit)
+}
+
+// ListNetworks returns the server's networks with the containers attached to them
+func (s *SSHClient) ListNetworks(ctx context.Context) (_ []model.Network, err error) {
+	defer s.track("list_networks", time.Now(), &err)
+	// Networks removed in the meantime are skipped
+	output, err := s.runDockerScript(ctx, `set -e; n=$(docker network ls -q); if [ -n "$n" ]; then docker network inspect $n 2>/dev/null || true; fi`, nil)
+	if err != nil {
+		return nil, err
+	}
+	details, err := parseNetworks(output)
+	if err != nil {
+		return nil, err
+	}
+	networks := make([]model.Network, len(details))
+	for i, d := range details {
+		networks[i] = d.Network
+	}
+	return networks, nil
+}
+
+// GetNetwork returns a network like ListNetworks, or ErrNetworkNotFound
+func (s *SSHClient) GetNetwork(ctx context.Context, name string) (_ model.NetworkDetails, err error) {
+	defer s.track("inspect_network", time.Now(), &err)
+	q := ShellQuote(name)
+	output, err := s.runDockerScript(ctx, networkExistsScript(q)+"docker network inspect "+q, nil)
+	if status, ok := exitStatus(err); ok && status == missingExit {
+		return model.NetworkDetails{}, ErrNetworkNotFound
+	}
+	if err != nil {
+		return model.NetworkDetails{}, err
+	}
+	networks, err := parseNetworks(output)
+	if err != nil {
+		return model.NetworkDetails{}, err
+	}
+	for _, n := range networks {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return model.NetworkDetails{}, ErrNetworkNotFound
+}
+
+// CreateNetwork creates a bridge network and returns it. IPv6 is enabled for IPv6 subnets.
+// Docker's refusals, such as a name that is taken or a subnet that overlaps another network's,
+// are returned as they are.
+func (s *SSHClient) CreateNetwork(ctx context.Context, req model.NetworkCreateRequest) (_ model.NetworkDetails, err error) {
+	defer s.track("create_network", time.Now(), &err)
+	q := ShellQuote(req.Name)
+	cmd := "docker network create --driver bridge"
+	if req.Subnet != "" {
+		cmd += " --subnet " + ShellQuote(req.Subnet)
+		if ip, _, err := net.ParseCIDR(req.Subnet); err == nil && ip.To4() == nil {
+			cmd += " --ipv6"
+		}
+	}
+	if req.Gateway != "" {
+		cmd += " --gateway " + ShellQuote(req.Gateway)
+	}
+	if req.IPRange != "" {
+		cmd += " --ip-range " + ShellQuote(req.IPRange)
+	}
+	if req.Internal {
+		cmd += " --internal"
+	}
+	keys := make([]string, 0, len(req.Labels))
+	for k := range req.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmd += " --label " + ShellQuote(k+"="+req.Labels[k])
+	}
+	output, err := s.runDockerScript(ctx, "set -e; "+cmd+" "+q+" >/dev/null; docker network inspect "+q, nil)
+	if err != nil {
+		return model.NetworkDetails{}, err
+	}
+	networks, err := parseNetworks(output)
+	if err != nil {
+		return model.NetworkDetails{}, err
+	}
+	if len(networks) == 0 {
+		return model.NetworkDetails{}, fmt.Errorf("network %s was not found after creating it", req.Name)
+	}
+	return networks[0], nil
+}
+
+// RemoveNetwork removes a network no container is attached to. It fails with
+// ErrNetworkNotFound, or with ErrNetworkInUse and the names of the attached containers.
+func (s *SSHClient) RemoveNetwork(ctx context.Context, name string) (usedBy []string, err error) {
+	defer s.track("remove_network", time.Now(), &err)
+	q := ShellQuote(name)
+	script := networkExistsScript(q) + fmt.Sprintf(
+		`c=$(docker network inspect --format '{{range .Containers}}{{println .Name}}{{end}}' %[1]s); if [ -n "$c" ]; then echo "$c"; exit %[2]d; fi; `+
+			`docker network rm %[1]s >/dev/null`, q, inUseExit)
+	output, err := s.runDockerScript(ctx, script, nil)
+	if status, ok := exitStatus(err); ok {
+		switch status {
+		case missingExit:
+			return nil, ErrNetworkNotFound
+		case inUseExit:
+			return sectionLines(output), ErrNetworkInUse
+		}
+	}
+	return nil, err
+}
+
+// ConnectNetwork attaches a container to a network, at ipv4Address when it is set. It fails
+// with ErrNetworkNotFound, and with docker's errors for missing containers and refusals such as
+// a container already attached.
+func (s *SSHClient) ConnectNetwork(ctx context.Context, name, containerID, ipv4Address string, aliases []string) (err error) {
+	defer s.track("connect_network", time.Now(), &err)
+	cmd := "docker network connect"
+	if ipv4Address != "" {
+		cmd += " --ip " + ShellQuote(ipv4Address)
+	}
+	for _, alias := range aliases {
+		cmd += " --alias " + ShellQuote(alias)
+	}
+	q := ShellQuote(name)
+	_, err = s.runDockerScript(ctx, networkExistsScript(q)+cmd+" "+q+" "+ShellQuote(containerID), nil)
+	if status, ok := exitStatus(err); ok && status == missingExit {
+		return ErrNetworkNotFound
+	}
+	return err
+}
+
+// DisconnectNetwork detaches a container from a network like ConnectNetwork attaches it. force
+// also detaches containers that are not running.
+func (s *SSHClient) DisconnectNetwork(ctx context.Context, name, containerID string, force bool) (err error) {
+	defer s.track("disconnect_network", time.Now(), &err)
+	cmd := "docker network disconnect"
+	if force {
+		cmd += " --force"
+	}
+	q := ShellQuote(name)
+	_, err = s.runDockerScript(ctx, networkExistsScript(q)+cmd+" "+q+" "+ShellQuote(containerID), nil)
+	if status, ok := exitStatus(err); ok && status == missingExit {
+		return ErrNetworkNotFound
+	}
+	return err
+}
+
+// parseNetworks reads docker network inspect's output, sorted by name
+func parseNetworks(output string) ([]model.NetworkDetails, error) {
+	var inspected []networkInspect
+	if out := strings.TrimSpace(output); out != "" {
+		if err := json.Unmarshal([]byte(out), &inspected); err != nil {
+			return nil, fmt.Errorf("failed to parse docker network inspect output: %w", err)
+		}
+	}
+	networks := []model.NetworkDetails{}
+	for _, n := range inspected {
+		d := model.NetworkDetails{
+			Network: model.Network{
+				ID:         n.ID,
+				Name:       n.Name,
+				Driver:     n.Driver,
+				Scope:      n.Scope,
+				CreatedAt:  n.Created,
+				Internal:   n.Internal,
+				IPv6:       n.EnableIPv6,
+				Subnets:    []model.NetworkSubnet{},
+				Labels:     n.Labels,
+				Containers: []model.NetworkContainer{},
+				BuiltIn:    dockerapi.IsBuiltInNetwork(n.Name),
+			},
+			Attachable: n.Attachable,
+			Options:    n.Options,
+		}
+		for _, cfg := range n.IPAM.Config {
+			d.Subnets = append(d.Subnets, model.NetworkSubnet{Subnet: cfg.Subnet, Gateway: cfg.Gateway, IPRange: cfg.IPRange})
+		}
+		for id, ct := range n.Containers {
+			d.Containers = append(d.Containers, model.NetworkContainer{
+				ID:          id,
+				Name:        ct.Name,
+				IPv4Address: ct.IPv4Address,
+				IPv6Address: ct.IPv6Address,
+				MacAddress:  ct.MacAddress,
+			})
+		}
+		sort.Slice(d.Containers, func(i, j int) bool { return d.Containers[i].Name < d.Containers[j].Name })
+		if d.Labels == nil {
+			d.Labels = map[string]string{}
+		}
+		if d.Options == nil {
+			d.Options = map[string]string{}
+		}
+		networks = append(networks, d)
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
+	return networks, nil
+}
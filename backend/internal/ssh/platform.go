@@ -0,0 +1,225 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Platforms of a host as "uname -s" reports them, lowercased
+const (
+	PlatformLinux   = "linux"
+	PlatformDarwin  = "darwin"
+	PlatformFreeBSD = "freebsd"
+)
+
+// ErrUnsupportedPlatform is returned for host stats on platforms without commands to read them
+var ErrUnsupportedPlatform = errors.New("unsupported platform")
+
+// SetPlatform sets the host's platform as detected before, so system stats use its commands
+// without detecting it again. An empty platform is detected by GetServerRealtimeStats.
+func (s *SSHClient) SetPlatform(platform string) {
+	s.platform = platform
+}
+
+// Platform returns the host's platform, empty until it is known
+func (s *SSHClient) Platform() string {
+	return s.platform
+}
+
+// systemStatsScripts print the CPU and RAM readings parsed by systemStatsParsers. Each runs in a
+// single session.
+var systemStatsScripts = map[string]string{
+	// Idle CPU from top, then used and total memory from free
+	PlatformLinux: `top -bn1 | grep 'Cpu(s)' | sed 's/.*, *\([0-9.]*\)%* id.*/\1/'
+free | awk '/^Mem/ {print $3, $2}'`,
+	// The second top sample covers the last second, the first one the time since boot
+	PlatformDarwin: `top -l 2 -n 0 -s 1 | grep 'CPU usage' | tail -n 1
+vm_stat
+sysctl -n hw.memsize`,
+	// Two readings of the CPU time counters a second apart, then the memory counters
+	PlatformFreeBSD: `sysctl -n kern.cp_time; sleep 1; sysctl -n kern.cp_time
+sysctl hw.physmem hw.pagesize vm.stats.vm.v_free_count vm.stats.vm.v_inactive_count vm.stats.vm.v_cache_count 2>/dev/null
+true`,
+}
+
+var systemStatsParsers = map[string]func(output string) (cpu, ram float64, err error){
+	PlatformLinux:   parseLinuxSystemStats,
+	PlatformDarwin:  parseDarwinSystemStats,
+	PlatformFreeBSD: parseFreeBSDSystemStats,
+}
+
+// GetSystemStats returns the CPU and RAM usage in percent, read with the commands of the host's
+// platform. Hosts whose platform is not known yet are read like Linux ones, other platforms
+// return ErrUnsupportedPlatform.
+func (s *SSHClient) GetSystemStats(ctx context.Context) (_, _ float64, err error) {
+	defer s.track("system_stats", time.Now(), &err)
+	platform := s.platform
+	if platform == "" {
+		platform = PlatformLinux
+	}
+	script, ok := systemStatsScripts[platform]
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %s", ErrUnsupportedPlatform, platform)
+	}
+	output, err := s.runScript(ctx, script, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	return systemStatsParsers[platform](output)
+}
+
+// parseLinuxSystemStats parses the idle CPU percentage printed by top, e.g. "93.8", and a line
+// with the used and total memory from free, e.g. "812344 2014352"
+func parseLinuxSystemStats(output string) (cpu, ram float64, err error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 {
+		return 0, 0, fmt.Errorf("unexpected system stats output: %q", output)
+	}
+	idle, err := strconv.ParseFloat(strings.TrimSpace(lines[0]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected top output: %q", lines[0])
+	}
+	var used, total float64
+	if _, err := fmt.Sscan(lines[1], &used, &total); err != nil || total <= 0 {
+		return 0, 0, fmt.Errorf("unexpected free output: %q", lines[1])
+	}
+	return 100 - idle, used / total * 100, nil
+}
+
+// parseDarwinSystemStats parses the CPU usage line of top, e.g. "CPU usage: 5.26% user, 10.52%
+// sys, 84.21% idle", the vm_stat output and the memory size in bytes. Used memory counts active,
+// wired and compressed pages, close to what Activity Monitor shows.
+func parseDarwinSystemStats(output string) (cpu, ram float64, err error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "CPU usage:") {
+		return 0, 0, fmt.Errorf("unexpected system stats output: %q", output)
+	}
+	idle := -1.0
+	for _, part := range strings.Split(strings.TrimPrefix(lines[0], "CPU usage:"), ",") {
+		if value, ok := strings.CutSuffix(strings.TrimSpace(part), "% idle"); ok {
+			idle, err = strconv.ParseFloat(value, 64)
+		}
+	}
+	if idle < 0 || err != nil {
+		return 0, 0, fmt.Errorf("unexpected top output: %q", lines[0])
+	}
+
+	memSize, err := strconv.ParseFloat(strings.TrimSpace(lines[len(lines)-1]), 64)
+	if err != nil || memSize <= 0 {
+		return 0, 0, fmt.Errorf("unexpected memory size: %q", lines[len(lines)-1])
+	}
+	// Mach Virtual Memory Statistics: (page size of 16384 bytes)
+	var pageSize float64
+	pages := map[string]float64{}
+	for _, line := range lines[1 : len(lines)-1] {
+		if _, rest, ok := strings.Cut(line, "page size of "); ok {
+			pageSize, _ = strconv.ParseFloat(strings.Fields(rest)[0], 64)
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			pages[strings.TrimSpace(name)], _ = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "."), 64)
+		}
+	}
+	if pageSize == 0 {
+		return 0, 0, fmt.Errorf("unexpected vm_stat output: %q", output)
+	}
+	used := (pages["Pages active"] + pages["Pages wired down"] + pages["Pages occupied by compressor"]) * pageSize
+	return 100 - idle, min(used/memSize*100, 100), nil
+}
+
+// parseFreeBSDSystemStats parses two readings of kern.cp_time, the ticks spent in user, nice,
+// system, interrupt and idle time, and the "name: value" lines of the memory counters. Free,
+// inactive and cached pages count as available.
+func parseFreeBSDSystemStats(output string) (cpu, ram float64, err error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 3 {
+		return 0, 0, fmt.Errorf("unexpected system stats output: %q", output)
+	}
+	before, err1 := parseCPTime(lines[0])
+	after, err2 := parseCPTime(lines[1])
+	if err := errors.Join(err1, err2); err != nil {
+		return 0, 0, err
+	}
+	var busy, total float64
+	for i := range after {
+		delta := after[i] - before[i]
+		total += delta
+		if i != len(after)-1 {
+			busy += delta
+		}
+	}
+	if total > 0 {
+		cpu = busy / total * 100
+	}
+
+	counters := map[string]float64{}
+	for _, line := range lines[2:] {
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			counters[strings.TrimSpace(name)], _ = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		}
+	}
+	physmem, pageSize := counters["hw.physmem"], counters["hw.pagesize"]
+	if physmem <= 0 || pageSize <= 0 {
+		return 0, 0, fmt.Errorf("unexpected sysctl output: %q", strings.Join(lines[2:], "\n"))
+	}
+	available := (counters["vm.stats.vm.v_free_count"] + counters["vm.stats.vm.v_inactive_count"] + counters["vm.stats.vm.v_cache_count"]) * pageSize
+	return cpu, max(physmem-available, 0) / physmem * 100, nil
+}
+
+// parseCPTime parses kern.cp_time, e.g. "2213 0 1741 112 513337"
+func parseCPTime(line string) ([5]float64, error) {
+	var ticks [5]float64
+	fields := strings.Fields(line)
+	if len(fields) != len(ticks) {
+		return ticks, fmt.Errorf("unexpected kern.cp_time: %q", line)
+	}
+	for i, field := range fields {
+		var err error
+		if ticks[i], err = strconv.ParseFloat(field, 64); err != nil {
+			return ticks, fmt.Errorf("unexpected kern.cp_time: %q", line)
+		}
+	}
+	return ticks, nil
+}
+
+// formatBootTime turns the kern.boottime of BSD hosts, e.g. "{ sec = 1700000000, usec = 0 } Tue
+// Nov 14 22:13:20 2023", into the uptime in the format of "uptime -p", e.g. "up 3 days, 2
+// hours, 5 minutes". Other lines are returned unchanged.
+func formatBootTime(line string, now time.Time) string {
+	rest, ok := strings.CutPrefix(line, "{ sec = ")
+	if !ok {
+		return line
+	}
+	end := strings.IndexByte(rest, ',')
+	if end < 0 {
+		return line
+	}
+	sec, err := strconv.ParseInt(rest[:end], 10, 64)
+	if err != nil {
+		return line
+	}
+	up := now.Sub(time.Unix(sec, 0))
+	minutes := int(up.Minutes())
+	var parts []string
+	for _, unit := range []struct {
+		name    string
+		minutes int
+	}{{"week", 7 * 24 * 60}, {"day", 24 * 60}, {"hour", 60}, {"minute", 1}} {
+		n := minutes / unit.minutes
+		minutes %= unit.minutes
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+unit.name)
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", n, unit.name))
+		}
+	}
+	if len(parts) == 0 {
+		return "up 0 minutes"
+	}
+	return "up " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,81 @@
+package ssh
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseSystemStats(t *testing.T) {
+	for _, tc := range []struct {
+		platform, output string
+		cpu, ram         float64
+	}{
+		{PlatformLinux, "93.8\n812344 2014352\n", 6.2, 40.33},
+		{PlatformDarwin, `CPU usage: 5.26% user, 10.52% sys, 84.21% idle
+Mach Virtual Memory Statistics: (page size of 16384 bytes)
+Pages free:                               12345.
+Pages active:                            200000.
+Pages inactive:                          190000.
+Pages wired down:                        100000.
+Pages occupied by compressor:             50000.
+17179869184
+`, 15.79, 33.38},
+		{PlatformFreeBSD, `2213 0 1741 112 513337
+2253 0 1761 112 513497
+hw.physmem: 8589934592
+hw.pagesize: 4096
+vm.stats.vm.v_free_count: 1048576
+vm.stats.vm.v_inactive_count: 262144
+vm.stats.vm.v_cache_count: 0
+`, 27.27, 37.5},
+	} {
+		cpu, ram, err := systemStatsParsers[tc.platform](tc.output)
+		if err != nil {
+			t.Errorf("%s: %v", tc.platform, err)
+			continue
+		}
+		if math.Abs(cpu-tc.cpu) > 0.01 || math.Abs(ram-tc.ram) > 0.01 {
+			t.Errorf("%s: cpu %.2f ram %.2f, want %.2f and %.2f", tc.platform, cpu, ram, tc.cpu, tc.ram)
+		}
+	}
+}
+
+func TestParseSystemStatsRejectsUnexpectedOutput(t *testing.T) {
+	for platform, outputs := range map[string][]string{
+		// What GNU top and free print on a host where they are BusyBox or missing
+		PlatformLinux:   {"", "93.8\n", "idle\n812344 2014352", "93.8\n812344 0"},
+		PlatformDarwin:  {"", "CPU usage: 5% user\nvm_stat\n17179869184", "CPU usage: 84.21% idle\nPages active: 1.\n0"},
+		PlatformFreeBSD: {"", "1 2 3 4 5\n1 2 3 4 5", "1 2 3\n1 2 3 4 5\nhw.physmem: 1", "1 2 3 4 5\n1 2 3 4 5\nhw.pagesize: 4096"},
+	} {
+		for _, output := range outputs {
+			if cpu, ram, err := systemStatsParsers[platform](output); err == nil {
+				t.Errorf("%s: %q parsed as cpu %.2f ram %.2f, want an error", platform, output, cpu, ram)
+			}
+		}
+	}
+}
+
+func TestEveryPlatformHasScriptAndParser(t *testing.T) {
+	for _, platform := range []string{PlatformLinux, PlatformDarwin, PlatformFreeBSD} {
+		if systemStatsScripts[platform] == "" || systemStatsParsers[platform] == nil {
+			t.Errorf("%s has no system stats script or parser", platform)
+		}
+	}
+}
+
+func TestFormatBootTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, tc := range []struct{ in, want string }{
+		{"{ sec = 1699726700, usec = 0 } Sat Nov 11 18:18:20 2023", "up 3 days, 3 hours, 55 minutes"},
+		{"{ sec = 1698784400, usec = 12 } Tue Oct 31 20:33:20 2023", "up 2 weeks, 1 hour, 40 minutes"},
+		{"{ sec = 1699999990, usec = 0 }", "up 0 minutes"},
+		// The output of "uptime -p" on Linux passes through
+		{"up 2 hours, 5 minutes", "up 2 hours, 5 minutes"},
+		{"{ sec = soon, usec = 0 }", "{ sec = soon, usec = 0 }"},
+	} {
+		if got := formatBootTime(tc.in, now); got != tc.want {
+			t.Errorf("formatBootTime(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
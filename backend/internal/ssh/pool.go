@@ -0,0 +1,315 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// poolSweep is how often idle pooled connections are looked for
+const poolSweep = 30 * time.Second
+
+// errSessionTimeout is returned when a connected server does not answer a request for a session
+var errSessionTimeout = fmt.Errorf("no answer from the server when opening a session: %w", context.DeadlineExceeded)
+
+// Conn is the connection a session from CreateSession runs on. Close releases it: pooled
+// connections stay open for the next session, dedicated ones are closed.
+type Conn struct {
+	client *ssh.Client
+	pooled *pooledClient
+	once   sync.Once
+	// release frees the session slot taken for the Conn's session
+	release func()
+}
+
+// Close releases the connection and its session slot. Sessions on it should be closed first.
+func (c *Conn) Close() error {
+	var err error
+	c.freeSlot()
+	c.once.Do(func() {
+		if c.pooled == nil {
+			err = c.client.Close()
+			return
+		}
+		c.pooled.release()
+	})
+	return err
+}
+
+// pooledClient is an authenticated connection shared by the sessions of one server
+type pooledClient struct {
+	client      *ssh.Client
+	entry       *poolEntry
+	fingerprint string
+	// refs counts the Conns handed out. A detached client is no longer handed out and is closed
+	// once refs drops to zero.
+	refs     int
+	lastUsed time.Time
+	detached bool
+	closed   bool
+}
+
+// poolEntry holds a server's pooled connection. dialMu keeps concurrent requests for the same
+// server from dialing more than once.
+type poolEntry struct {
+	dialMu  sync.Mutex
+	current *pooledClient
+}
+
+var (
+	poolMu sync.Mutex
+	pool   = map[uint]*poolEntry{}
+	// idleTTL is how long an unused connection stays open, 0 disables pooling
+	idleTTL = 5 * time.Minute
+)
+
+// StartPool applies the pool settings, follows changes to them and closes connections that
+// have been idle for longer than the configured TTL. Every pooled connection is closed when
+// ctx is done.
+func StartPool(ctx context.Context, db *gorm.DB) {
+	setIdleTTL(strconv.Itoa(config.GetInt(db, model.ConfigKeySSHPoolIdle)))
+	config.OnChange(model.ConfigKeySSHPoolIdle, setIdleTTL)
+
+	go func() {
+		ticker := time.NewTicker(poolSweep)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				closeIdle(0)
+				return
+			case <-ticker.C:
+				poolMu.Lock()
+				ttl := idleTTL
+				poolMu.Unlock()
+				closeIdle(ttl)
+			}
+		}
+	}()
+}
+
+func setIdleTTL(value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	poolMu.Lock()
+	idleTTL = time.Duration(n) * time.Second
+	poolMu.Unlock()
+	if n <= 0 {
+		closeIdle(0)
+	}
+}
+
+// closeIdle closes the unused pooled connections idle for at least ttl. With ttl 0 connections
+// in use are detached as well, so they close once released.
+func closeIdle(ttl time.Duration) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	for _, e := range pool {
+		if pc := e.current; pc != nil && (ttl == 0 || (pc.refs == 0 && time.Since(pc.lastUsed) >= ttl)) {
+			pc.detachLocked()
+		}
+	}
+}
+
+// connect returns a connection for a new session: the server's pooled connection, dialed when
+// there is none yet, or a dedicated one for clients not tied to a stored server, connection
+// tests and while pooling is disabled
+func (s *SSHClient) connect() (*Conn, error) {
+	poolMu.Lock()
+	pooling := idleTTL > 0
+	poolMu.Unlock()
+	if s.ServerID == 0 || s.BypassCircuit || !pooling {
+		return s.dedicated()
+	}
+
+	poolMu.Lock()
+	e := pool[s.ServerID]
+	if e == nil {
+		e = &poolEntry{}
+		pool[s.ServerID] = e
+	}
+	poolMu.Unlock()
+
+	e.dialMu.Lock()
+	defer e.dialMu.Unlock()
+	poolMu.Lock()
+	if pc := e.current; pc != nil {
+		if pc.fingerprint == s.fingerprint {
+			pc.refs++
+			poolMu.Unlock()
+			return &Conn{client: pc.client, pooled: pc}, nil
+		}
+		// The server's address or credentials changed
+		pc.detachLocked()
+	}
+	poolMu.Unlock()
+
+	client, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	pc := &pooledClient{client: client, entry: e, fingerprint: s.fingerprint, refs: 1, lastUsed: time.Now()}
+	poolMu.Lock()
+	e.current = pc
+	poolMu.Unlock()
+	go func() {
+		// Drop the connection from the pool as soon as it is found dead
+		client.Wait()
+		poolMu.Lock()
+		pc.detachLocked()
+		poolMu.Unlock()
+	}()
+	return &Conn{client: client, pooled: pc}, nil
+}
+
+// dedicated dials a connection that is closed with its Conn
+func (s *SSHClient) dedicated() (*Conn, error) {
+	client, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{client: client}, nil
+}
+
+// newSession opens a session on a pooled or dedicated connection. A pooled connection that
+// fails to open one is discarded and dialed again, unless the server only refused another
+// channel, e.g. because sshd's MaxSessions is reached; that session gets a dedicated connection.
+// A server that does not answer at all is not dialed again.
+func (s *SSHClient) newSession() (*ssh.Session, *Conn, error) {
+	conn, err := s.connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := s.openSession(conn)
+	if errors.Is(err, errSessionTimeout) {
+		return nil, nil, err
+	}
+	if err == nil || conn.pooled == nil {
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		return session, conn, nil
+	}
+
+	var refused *ssh.OpenChannelError
+	if errors.As(err, &refused) {
+		conn.Close()
+		conn, err = s.dedicated()
+	} else {
+		conn.discard()
+		conn, err = s.connect()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err = s.openSession(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return session, conn, nil
+}
+
+// openSession opens a session on conn. When the server does not answer within the connect
+// timeout the connection is closed and errSessionTimeout returned.
+func (s *SSHClient) openSession(conn *Conn) (*ssh.Session, error) {
+	type opened struct {
+		session *ssh.Session
+		err     error
+	}
+	done := make(chan opened, 1)
+	go func() {
+		session, err := conn.client.NewSession()
+		done <- opened{session, err}
+	}()
+	select {
+	case o := <-done:
+		return o.session, o.err
+	case <-time.After(s.Config.Timeout):
+		conn.abandon()
+		if o := <-done; o.session != nil {
+			o.session.Close()
+		}
+		return nil, errSessionTimeout
+	}
+}
+
+// freeSlot releases the Conn's session slot, if it has one
+func (c *Conn) freeSlot() {
+	if c.release != nil {
+		c.release()
+	}
+}
+
+// discard releases a pooled connection that turned out to be broken and keeps it from being
+// handed out again
+func (c *Conn) discard() {
+	c.freeSlot()
+	c.once.Do(func() {
+		poolMu.Lock()
+		defer poolMu.Unlock()
+		c.pooled.refs--
+		c.pooled.detachLocked()
+	})
+}
+
+// abandon closes the connection right away, failing any other session on it. A pooled
+// connection is no longer handed out.
+func (c *Conn) abandon() {
+	if c.pooled != nil {
+		c.discard()
+	}
+	c.client.Close()
+}
+
+// release hands a pooled connection back
+func (pc *pooledClient) release() {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	pc.refs--
+	pc.lastUsed = time.Now()
+	pc.closeIfUnusedLocked()
+}
+
+// detachLocked removes the client from the pool. It is closed right away when unused and
+// otherwise once its last Conn is released. poolMu must be held.
+func (pc *pooledClient) detachLocked() {
+	if pc.entry.current == pc {
+		pc.entry.current = nil
+	}
+	pc.detached = true
+	pc.closeIfUnusedLocked()
+}
+
+// closeIfUnusedLocked closes a detached client that no Conn uses. poolMu must be held.
+func (pc *pooledClient) closeIfUnusedLocked() {
+	if pc.detached && pc.refs == 0 && !pc.closed {
+		pc.closed = true
+		go pc.client.Close()
+	}
+}
+
+// fingerprint identifies the address and credentials a client connects with, so that a pooled
+// connection is not reused after a server's settings change
+func fingerprint(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,231 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// probeTimeout bounds a whole probe, so that an address that drops packets is reported within it
+const probeTimeout = 10 * time.Second
+
+// The stages of a probe, in the order they run
+const (
+	// ProbeReach opens a TCP connection to the server, through its jump host when it has one
+	ProbeReach = "reach"
+	// ProbeAuth runs the SSH handshake and authenticates, or for docker-tls servers the TLS
+	// handshake with the client certificate and a ping of the engine
+	ProbeAuth = "auth"
+	// ProbeDocker reads the version of the docker daemon
+	ProbeDocker = "docker"
+)
+
+// probeDockerScript prints the docker daemon's version, exiting with dockerMissingExit when there
+// is no docker CLI
+const probeDockerScript = `command -v docker >/dev/null 2>&1 || exit 127
+docker version --format '{{.Server.Version}}'`
+
+// ProbeStage is a stage of a probe that ran
+type ProbeStage struct {
+	Stage     string `json:"stage"`
+	OK        bool   `json:"ok"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Probe is the result of connecting to a server stage by stage. The stages after one that fails
+// do not run; FailedStage names it and Error is its error. FailedHop is "jump_host" when the
+// server's jump host could not be reached and "server" for other failures, as in connection
+// tests.
+type Probe struct {
+	Reachable       bool         `json:"reachable"`
+	Authenticated   bool         `json:"authenticated"`
+	DockerAvailable bool         `json:"docker_available"`
+	DockerVersion   string       `json:"docker_version,omitempty"`
+	Error           string       `json:"error,omitempty"`
+	FailedStage     string       `json:"failed_stage,omitempty"`
+	FailedHop       string       `json:"failed_hop,omitempty"`
+	ElapsedMS       int64        `json:"elapsed_ms"`
+	Stages          []ProbeStage `json:"stages"`
+}
+
+// ProbeServer connects to a server that need not be stored, without the pool, its agent or its
+// circuit breaker, and checks that docker answers. The whole probe takes at most probeTimeout.
+func ProbeServer(ctx context.Context, server *model.Server) *Probe {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	start := time.Now()
+	p := &Probe{Stages: []ProbeStage{}}
+	if server.AuthMode == model.AuthModeDockerTLS {
+		p.probeDockerTLS(ctx, server)
+	} else {
+		p.probeSSH(ctx, server)
+	}
+	p.ElapsedMS = time.Since(start).Milliseconds()
+	return p
+}
+
+// stage runs a stage and records it, reporting whether it succeeded
+func (p *Probe) stage(name string, run func() error) bool {
+	start := time.Now()
+	err := run()
+	st := ProbeStage{Stage: name, OK: err == nil, ElapsedMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		st.Error = err.Error()
+		p.Error, p.FailedStage, p.FailedHop = st.Error, name, "server"
+		if errors.Is(err, ErrJumpHost) {
+			p.FailedHop = "jump_host"
+		}
+	}
+	p.Stages = append(p.Stages, st)
+	return err == nil
+}
+
+// probeSSH probes a server over SSH on a connection of its own, which is closed afterwards
+func (p *Probe) probeSSH(ctx context.Context, server *model.Server) {
+	client, err := NewServerClient(server)
+	if err != nil {
+		// The credentials are unusable, such as a key that does not parse
+		p.stage(ProbeAuth, func() error { return err })
+		return
+	}
+
+	var conn net.Conn
+	var jump *ssh.Client
+	ok := p.stage(ProbeReach, func() error {
+		conn, jump, err = client.probeDial(ctx)
+		return err
+	})
+	if jump != nil {
+		defer jump.Close()
+	}
+	if !ok {
+		return
+	}
+	p.Reachable = true
+
+	var sc *ssh.Client
+	if !p.stage(ProbeAuth, func() error {
+		sc, err = probeHandshake(ctx, conn, client.Addr, client.Config)
+		return err
+	}) {
+		return
+	}
+	p.Authenticated = true
+	c := &Conn{client: sc}
+	defer c.Close()
+
+	var version string
+	if !p.stage(ProbeDocker, func() error {
+		version, err = client.probeDocker(ctx, c)
+		return err
+	}) {
+		return
+	}
+	p.DockerAvailable, p.DockerVersion = true, version
+}
+
+// probeDial opens a TCP connection to the server, through its jump host when it has one. The jump
+// host's connection is returned to be closed once the probe is over, and is closed when ctx is
+// done, as tunnels have no deadlines.
+func (s *SSHClient) probeDial(ctx context.Context) (net.Conn, *ssh.Client, error) {
+	var d net.Dialer
+	if s.jump == nil {
+		conn, err := d.DialContext(ctx, "tcp", s.Addr)
+		return conn, nil, err
+	}
+	jc, err := d.DialContext(ctx, "tcp", s.jump.Addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w %s: %w", ErrJumpHost, s.jump.Addr, err)
+	}
+	jump, err := probeHandshake(ctx, jc, s.jump.Addr, s.jump.Config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w %s: %w", ErrJumpHost, s.jump.Addr, err)
+	}
+	context.AfterFunc(ctx, func() { jump.Close() })
+	conn, err := jump.Dial("tcp", s.Addr)
+	if err != nil {
+		return nil, jump, fmt.Errorf("failed to reach %s from jump host %s: %w", s.Addr, s.jump.Addr, err)
+	}
+	return conn, jump, nil
+}
+
+// probeHandshake runs the SSH handshake on conn, bounded by ctx's deadline
+func probeHandshake(ctx context.Context, conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// probeDocker returns the docker daemon's version, running docker through sudo when the client
+// uses it
+func (s *SSHClient) probeDocker(ctx context.Context, conn *Conn) (string, error) {
+	session, err := conn.client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	var stdout, stderr bytes.Buffer
+	session.Stdout, session.Stderr = &stdout, &stderr
+	err = conn.run(ctx, session, s.sudo(session, probeDockerScript))
+	if status, ok := exitStatus(err); ok && status == dockerMissingExit {
+		return "", errors.New("docker is not installed or not in PATH")
+	}
+	if err != nil {
+		return "", errors.New(dockerError(fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// probeDockerTLS probes a docker-tls server: the TCP connection, the TLS handshake with a ping
+// and the engine's version
+func (p *Probe) probeDockerTLS(ctx context.Context, server *model.Server) {
+	if !p.stage(ProbeReach, func() error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(server.IP, strconv.Itoa(server.Port)))
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}) {
+		return
+	}
+	p.Reachable = true
+
+	client, err := NewDockerTLSClient(server)
+	if !p.stage(ProbeAuth, func() error {
+		if err != nil {
+			return err
+		}
+		return client.Ping(ctx)
+	}) {
+		return
+	}
+	p.Authenticated = true
+
+	var info *dockerapi.Info
+	if !p.stage(ProbeDocker, func() error {
+		info, err = client.Info(ctx)
+		return err
+	}) {
+		return
+	}
+	p.DockerAvailable, p.DockerVersion = true, info.ServerVersion
+}
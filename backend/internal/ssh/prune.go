@@ -0,0 +1,290 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+)
+
+// volumeAllCheck sets $all when "docker volume prune" has --all, docker 23 and later, where
+// it only removes anonymous volumes without the flag
+const volumeAllCheck = `all=; if docker volume prune --help 2>/dev/null | grep -q -- --all; then all=1; fi; `
+
+// anonymousVolumeLabel is set by docker 23 and later on the volumes it creates without a name
+const anonymousVolumeLabel = "com.docker.volume.anonymous"
+
+// createdLayout is how docker ps, images and network ls print creation times
+const createdLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// Prune removes the unused docker objects of a kind, one of model.PruneTypes, and returns what
+// docker reported removing
+func (s *SSHClient) Prune(ctx context.Context, kind string, req model.PruneRequest) (_ model.PruneTypeResult, err error) {
+	defer s.track("prune", time.Now(), &err)
+	var script string
+	switch kind {
+	case model.PruneContainers:
+		script = "docker container prune -f" + pruneFilters(req, true, true)
+	case model.PruneImages:
+		script = "docker image prune -f" + allFlag(req) + pruneFilters(req, true, true)
+	case model.PruneVolumes:
+		script = volumeAllCheck + "docker volume prune -f" + pruneFilters(req, false, true)
+		if req.All {
+			script += " ${all:+--all}"
+		}
+	case model.PruneNetworks:
+		script = "docker network prune -f" + pruneFilters(req, true, true)
+	case model.PruneBuildCache:
+		script = "docker builder prune -f" + allFlag(req) + pruneFilters(req, true, false)
+	default:
+		return model.PruneTypeResult{}, fmt.Errorf("unknown prune type %q", kind)
+	}
+	output, err := s.runDockerScript(ctx, script, nil)
+	if err != nil {
+		return model.PruneTypeResult{}, err
+	}
+	return parsePruneOutput(kind, output), nil
+}
+
+// PruneDryRun lists what Prune would remove for each of kinds, without removing anything. The
+// objects are listed with the same filters the prunes use, and sized with "docker system df".
+func (s *SSHClient) PruneDryRun(ctx context.Context, kinds []string, req model.PruneRequest) (_ []model.PruneTypeResult, err error) {
+	defer s.track("prune_dry_run", time.Now(), &err)
+	script := "set -e; "
+	section := func(name, cmd string) {
+		script += fmt.Sprintf("echo '--- %s ---'; %s; ", name, cmd)
+	}
+	for _, kind := range kinds {
+		switch kind {
+		case model.PruneContainers:
+			section(kind, "docker ps -a --no-trunc --filter status=created --filter status=exited --filter status=dead"+
+				pruneFilters(req, false, true)+` --format '{{.ID}}|{{.Names}}|{{.CreatedAt}}'`)
+		case model.PruneImages:
+			dangling := " --filter dangling=true"
+			if req.All {
+				dangling = ""
+			}
+			section(kind, "docker images --no-trunc"+dangling+pruneFilters(req, false, true)+
+				` --format '{{.ID}}|{{.Repository}}:{{.Tag}}|{{.CreatedAt}}'`)
+		case model.PruneVolumes:
+			cmd := volumeAllCheck + "docker volume ls -q --filter dangling=true" + pruneFilters(req, false, true)
+			if !req.All {
+				cmd += " ${all:+--filter label=" + anonymousVolumeLabel + "}"
+			}
+			section(kind, cmd)
+		case model.PruneNetworks:
+			section(kind, "docker network ls --no-trunc --filter dangling=true --filter type=custom"+
+				pruneFilters(req, false, true)+` --format '{{.ID}}|{{.Name}}|{{.CreatedAt}}'`)
+		case model.PruneBuildCache:
+			section("totals", diskUsageScript)
+		}
+	}
+	if slices.ContainsFunc(kinds, func(kind string) bool { return kind != model.PruneNetworks && kind != model.PruneBuildCache }) {
+		section("details", diskUsageVerboseScript)
+	}
+	output, err := s.runDockerScript(ctx, script, nil)
+	if err != nil {
+		return nil, err
+	}
+	sections := scriptSections(output)
+	details := parseDiskUsageDetails(sections["details"])
+
+	var cutoff time.Time
+	if req.Until != "" {
+		d, err := time.ParseDuration(req.Until)
+		if err != nil {
+			return nil, err
+		}
+		cutoff = time.Now().Add(-d)
+	}
+	// old reports whether an object created at created passes the until filter. Objects whose
+	// creation time cannot be read are listed.
+	old := func(created string) bool {
+		t, err := time.Parse(createdLayout, created)
+		return cutoff.IsZero() || err != nil || t.Before(cutoff)
+	}
+
+	var results []model.PruneTypeResult
+	for _, kind := range kinds {
+		result := model.PruneTypeResult{Type: kind, Items: []model.PruneItem{}}
+		lines := sectionLines(sections[kind])
+		switch kind {
+		case model.PruneContainers:
+			sizes := dfSizes(details.containers, dfShortID("ID", "CONTAINERID"))
+			for _, line := range lines {
+				parts := strings.SplitN(line, "|", 3)
+				if len(parts) == 3 && old(parts[2]) {
+					id := parts[0]
+					result.Items = append(result.Items, model.PruneItem{ID: id, Name: parts[1], SizeBytes: sizes[shortID(id)].size})
+				}
+			}
+		case model.PruneImages:
+			images := dfSizes(details.images, dfShortID("ID", "IMAGEID"))
+			seen := map[string]int{}
+			for _, line := range lines {
+				parts := strings.SplitN(line, "|", 3)
+				if len(parts) < 3 || !old(parts[2]) {
+					continue
+				}
+				id := parts[0]
+				short := shortID(id)
+				if images[short].containers > 0 {
+					continue
+				}
+				name := parts[1]
+				if strings.Contains(name, "<none>") {
+					name = ""
+				}
+				if i, ok := seen[id]; ok {
+					if name != "" {
+						result.Items[i].Name = strings.TrimPrefix(result.Items[i].Name+", "+name, ", ")
+					}
+					continue
+				}
+				seen[id] = len(result.Items)
+				result.Items = append(result.Items, model.PruneItem{ID: id, Name: name, SizeBytes: images[short].unique})
+			}
+		case model.PruneVolumes:
+			sizes := dfSizes(details.volumes, func(row dfRow) string { return row.get("NAME", "VOLUMENAME") })
+			for _, name := range lines {
+				result.Items = append(result.Items, model.PruneItem{Name: name, SizeBytes: sizes[name].size})
+			}
+		case model.PruneNetworks:
+			for _, line := range lines {
+				parts := strings.SplitN(line, "|", 3)
+				if len(parts) == 3 && old(parts[2]) {
+					result.Items = append(result.Items, model.PruneItem{ID: parts[0], Name: parts[1]})
+				}
+			}
+		case model.PruneBuildCache:
+			for _, row := range parseDiskUsageTotals(sections["totals"]) {
+				if strings.EqualFold(row.get("TYPE"), "Build Cache") {
+					result.ReclaimedBytes = row.size("RECLAIMABLE")
+				}
+			}
+		}
+		for _, item := range result.Items {
+			result.ReclaimedBytes += item.SizeBytes
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// dfSize is the size of an object in "docker system df -v"
+type dfSize struct {
+	size, unique int64
+	containers   int
+}
+
+// dfSizes indexes the rows of a "docker system df -v" list by key
+func dfSizes(rows []dfRow, key func(dfRow) string) map[string]dfSize {
+	sizes := map[string]dfSize{}
+	for _, row := range rows {
+		sizes[key(row)] = dfSize{size: row.size("SIZE"), unique: row.size("UNIQUESIZE"), containers: row.count("CONTAINERS")}
+	}
+	return sizes
+}
+
+// dfShortID keys rows by the short form of their ID, which docker may print in full
+func dfShortID(idKeys ...string) func(dfRow) string {
+	return func(row dfRow) string {
+		return shortID(row.get(idKeys...))
+	}
+}
+
+// shortID shortens a container or image ID, with or without its "sha256:" prefix
+func shortID(id string) string {
+	return dockerapi.ShortID(strings.TrimPrefix(id, "sha256:"))
+}
+
+// pruneFilters returns the --filter flags of a prune or of the listing for its dry run. until
+// and labels tell whether the command takes those filters.
+func pruneFilters(req model.PruneRequest, until, labels bool) string {
+	var filters string
+	if until && req.Until != "" {
+		filters += " --filter " + ShellQuote("until="+req.Until)
+	}
+	if labels {
+		for _, label := range req.Labels {
+			filters += " --filter " + ShellQuote("label="+label)
+		}
+	}
+	return filters
+}
+
+func allFlag(req model.PruneRequest) string {
+	if req.All {
+		return " -a"
+	}
+	return ""
+}
+
+// parsePruneOutput reads what a prune removed and the space it reclaimed from its output.
+// Removed objects are listed below a "Deleted Containers:" style heading, or for the build
+// cache in a table ending with "Total:".
+func parsePruneOutput(kind, output string) model.PruneTypeResult {
+	result := model.PruneTypeResult{Type: kind, Items: []model.PruneItem{}}
+	listing := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Total reclaimed space:"):
+			result.ReclaimedBytes = dockerapi.ParseByteSize(strings.TrimPrefix(line, "Total reclaimed space:"))
+			listing = false
+		case strings.HasPrefix(line, "Total:"):
+			result.ReclaimedBytes = dockerapi.ParseByteSize(strings.TrimPrefix(line, "Total:"))
+			listing = false
+		case line == "":
+			listing = false
+		case strings.HasPrefix(line, "Deleted ") && strings.HasSuffix(line, ":"),
+			kind == model.PruneBuildCache && strings.HasPrefix(line, "ID"):
+			listing = true
+		case !listing:
+		case kind == model.PruneImages:
+			// Untagged references are listed too, only the deleted images count
+			if id, ok := strings.CutPrefix(line, "deleted: "); ok {
+				result.Items = append(result.Items, model.PruneItem{ID: id})
+			}
+		case kind == model.PruneBuildCache:
+			result.Items = append(result.Items, model.PruneItem{ID: strings.TrimRight(strings.Fields(line)[0], "*")})
+		case kind == model.PruneContainers:
+			result.Items = append(result.Items, model.PruneItem{ID: line})
+		default:
+			// Volumes and networks are listed by name
+			result.Items = append(result.Items, model.PruneItem{Name: line})
+		}
+	}
+	return result
+}
+
+// scriptSections splits the output of a script that starts each section by echoing
+// "--- name ---"
+func scriptSections(output string) map[string]string {
+	sections := map[string]string{}
+	var name string
+	for _, line := range strings.SplitAfter(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--- ") && strings.HasSuffix(trimmed, " ---") {
+			name = strings.TrimSuffix(strings.TrimPrefix(trimmed, "--- "), " ---")
+			continue
+		}
+		sections[name] += line
+	}
+	return sections
+}
+
+// sectionLines returns the non-blank lines of a section
+func sectionLines(section string) []string {
+	var lines []string
+	for _, line := range strings.Split(section, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,42 @@
+package ssh
+
+import (
+	"sync/atomic"
+
+	"docker-pulse/internal/registry"
+
+	"gorm.io/gorm"
+)
+
+// registryDB holds the registry credentials pulls log in with, nil until ConfigureRegistries
+var registryDB atomic.Pointer[gorm.DB]
+
+// ConfigureRegistries makes image pulls log in with the registry credentials stored in db
+func ConfigureRegistries(db *gorm.DB) {
+	registryDB.Store(db)
+}
+
+// registryCredentials returns the host of an image's registry and the stored login to it for
+// pulls on a server, nil when there is none
+func registryCredentials(serverID uint, image string) (string, *registry.Credentials) {
+	db := registryDB.Load()
+	if db == nil {
+		return "", nil
+	}
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return "", nil
+	}
+	return ref.Registry, registry.CredentialsFor(db, serverID, ref.Registry)
+}
+
+// engineRegistryAuth returns the RegistryAuth of Engine API clients of a server
+func engineRegistryAuth(serverID uint) func(image string) string {
+	return func(image string) string {
+		host, creds := registryCredentials(serverID, image)
+		if creds == nil {
+			return ""
+		}
+		return registry.EngineAuth(host, *creds)
+	}
+}
@@ -0,0 +1,53 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+)
+
+// CreateContainer creates a container from cfg with docker create and attaches it to cfg's
+// other networks, removing it again when that fails. It returns the container's ID.
+func (s *SSHClient) CreateContainer(ctx context.Context, cfg dockerapi.RunConfig) (_ string, err error) {
+	defer s.track("container_create", time.Now(), &err)
+	args := cfg.CreateArgs()
+	for i, arg := range args {
+		args[i] = ShellQuote(arg)
+	}
+	script := "set -e; id=$(docker create " + strings.Join(args, " ") + ")"
+	for _, network := range cfg.ExtraNetworks() {
+		connect := "docker network connect"
+		for _, alias := range cfg.Aliases(network) {
+			connect += " --alias " + ShellQuote(alias)
+		}
+		script += `; ` + connect + " " + ShellQuote(network) + ` "$id" || { docker rm -f "$id" >/dev/null; exit 1; }`
+	}
+	output, err := s.runDockerScript(ctx, script+`; echo "$id"`, nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// RenameContainer renames a container with docker rename
+func (s *SSHClient) RenameContainer(ctx context.Context, containerID, name string) (err error) {
+	defer s.track("container_rename", time.Now(), &err)
+	_, err = s.execCommand(ctx, "docker rename "+ShellQuote(containerID)+" "+ShellQuote(name), true)
+	return dockerapi.StateConflict(err)
+}
+
+// UpdateRestartPolicy changes a container's restart policy with docker update
+func (s *SSHClient) UpdateRestartPolicy(ctx context.Context, containerID string, policy model.RestartPolicy) (err error) {
+	defer s.track("container_update", time.Now(), &err)
+	_, err = s.execCommand(ctx, "docker update --restart="+ShellQuote(dockerapi.RestartPolicyFlag(policy))+" "+ShellQuote(containerID), true)
+	return err
+}
+
+// InspectImage returns what docker image inspect prints for an image
+func (s *SSHClient) InspectImage(ctx context.Context, image string) (_ string, err error) {
+	defer s.track("inspect_image", time.Now(), &err)
+	return s.execCommand(ctx, "docker image inspect "+ShellQuote(image), true)
+}
@@ -0,0 +1,106 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// asRoot prefixes scripts so that "$S cmd" runs cmd directly as root or through passwordless sudo
+// for other users. sudo never prompts, a password requirement fails the command instead. Scripts
+// run by runDockerScript on servers using sudo already run as root.
+const asRoot = `S=; [ "$(id -u)" -eq 0 ] || S="sudo -n"; `
+
+// ShellQuote quotes s as a single POSIX shell word. Every value interpolated into a command or
+// script must pass through it, as the shell would otherwise interpret it.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// containerRef matches container IDs and names as docker allows them. They start with a letter or
+// digit, so they are never read as an option either.
+var containerRef = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidContainerID reports whether id can be a container's ID or name. Swarm service and compose
+// project names follow the same rules.
+func ValidContainerID(id string) bool {
+	return containerRef.MatchString(id)
+}
+
+// runScript runs a shell script with optional stdin and returns its stdout. On failure the error
+// wraps the session's error, so exit statuses stay inspectable, and carries stderr.
+func (s *SSHClient) runScript(ctx context.Context, script string, stdin []byte) (string, error) {
+	return s.execScript(ctx, script, stdin, false)
+}
+
+// runDockerScript is runScript for scripts that run docker, which are run through sudo when the
+// client uses it
+func (s *SSHClient) runDockerScript(ctx context.Context, script string, stdin []byte) (string, error) {
+	return s.execScript(ctx, script, stdin, true)
+}
+
+// streamDockerScript is runDockerScript with stdout written to output as it arrives, or
+// discarded when output is nil. Through an agent stdout is written once the script is over.
+func (s *SSHClient) streamDockerScript(ctx context.Context, script string, stdin []byte, output io.Writer) error {
+	if output == nil {
+		output = io.Discard
+	}
+	if s.agent != nil {
+		stdout, stderr, err := s.agentExec(ctx, script, stdin)
+		io.WriteString(output, stdout)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+		}
+		return nil
+	}
+	session, client, err := s.CreateSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	defer client.Close()
+
+	var stderrBuf bytes.Buffer
+	session.Stdout = output
+	session.Stderr = &stderrBuf
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
+	if err := client.run(ctx, session, s.sudo(session, script)); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderrBuf.String()))
+	}
+	return nil
+}
+
+func (s *SSHClient) execScript(ctx context.Context, script string, stdin []byte, docker bool) (string, error) {
+	if s.agent != nil {
+		stdout, stderr, err := s.agentExec(ctx, script, stdin)
+		if err != nil {
+			return stdout, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+		}
+		return stdout, nil
+	}
+	session, client, err := s.CreateSession(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	defer client.Close()
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	session.Stdout = &stdoutBuf
+	session.Stderr = &stderrBuf
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
+	if docker {
+		script = s.sudo(session, script)
+	}
+	if err := client.run(ctx, session, script); err != nil {
+		return stdoutBuf.String(), fmt.Errorf("%w: %s", err, strings.TrimSpace(stderrBuf.String()))
+	}
+	return stdoutBuf.String(), nil
+}
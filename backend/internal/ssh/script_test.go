@@ -0,0 +1,170 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"docker-pulse/internal/model"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// maliciousNames are file names that run a command, end one or split into several arguments
+// when a shell gets to see them unquoted. The commands touch "pwned" in the working directory.
+var maliciousNames = []string{
+	"with space",
+	"semi;touch pwned",
+	"$(touch pwned)",
+	"`touch pwned`",
+	`single'double"quotes`,
+	"'; touch pwned; '",
+	"new\nline; touch pwned",
+	"and && touch pwned",
+	"pipe | touch pwned",
+	"$HOME ${PATH} *",
+	"-rf",
+}
+
+func TestShellQuote(t *testing.T) {
+	dir := t.TempDir()
+	for _, s := range append(maliciousNames, "", "'", "''", `\'`, "ünïcödé ☃") {
+		cmd := exec.Command("sh", "-c", "printf %s "+ShellQuote(s))
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("sh for %q: %v", s, err)
+		}
+		if string(out) != s {
+			t.Errorf("ShellQuote(%q) reads back as %q", s, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Fatal("a quoted argument ran a command")
+	}
+}
+
+func TestValidContainerID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"abc123def456":    true,
+		"web":             true,
+		"shop-web_1.blue": true,
+		"":                false,
+		"-it":             false,
+		".hidden":         false,
+		"web; rm -rf /":   false,
+		"$(id)":           false,
+		"web\nid":         false,
+		"../web":          false,
+		"web name":        false,
+	} {
+		if got := ValidContainerID(id); got != want {
+			t.Errorf("ValidContainerID(%q) = %t, want %t", id, got, want)
+		}
+	}
+}
+
+// argvHost runs the commands it gets with a docker that prints its arguments, so the test sees
+// the words the host's shell split the command into. The script of "docker exec ... sh -c" is
+// then run in dir, standing in for the container.
+type argvHost struct {
+	t   *testing.T
+	dir string
+	mu  sync.Mutex
+	// argvs are the arguments docker was called with
+	argvs [][]string
+}
+
+func (h *argvHost) exec(cmd string, ch ssh.Channel) {
+	if !strings.HasPrefix(cmd, "docker ") {
+		h.t.Errorf("unexpected command %q", cmd)
+		exit(ch, 127)
+		return
+	}
+	shell := exec.Command("sh", "-c", `docker() { printf '%s\0' "$@"; }; `+cmd)
+	shell.Dir = h.dir
+	out, err := shell.Output()
+	if err != nil {
+		h.t.Errorf("%q: %v", cmd, err)
+		exit(ch, 1)
+		return
+	}
+	argv := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	h.mu.Lock()
+	h.argvs = append(h.argvs, argv)
+	h.mu.Unlock()
+
+	if len(argv) < 4 || argv[0] != "exec" || argv[2] != "sh" || argv[3] != "-c" {
+		exit(ch, 1)
+		return
+	}
+	script := exec.Command("sh", argv[3:]...)
+	script.Dir = h.dir
+	script.Stdout, script.Stderr = ch, ch.Stderr()
+	status := uint32(0)
+	if err := script.Run(); err != nil {
+		status = 1
+	}
+	exit(ch, status)
+}
+
+func (h *argvHost) recorded() [][]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([][]string(nil), h.argvs...)
+}
+
+// TestFileCommandsPassPathsLiterally works on files with malicious names and checks that each
+// name reached docker, and the script in the container, as a single argument
+func TestFileCommandsPassPathsLiterally(t *testing.T) {
+	host := &argvHost{t: t, dir: t.TempDir()}
+	client := &SSHClient{Config: testClientConfig(), Addr: startTestServer(t, host.exec, nil)}
+	client.SetConnectionMode(model.ConnectionModeCLI)
+	ctx := context.Background()
+
+	for _, name := range maliciousNames {
+		renamed := name + " renamed"
+		if err := client.ContainerFileOp(ctx, "web", model.FileOpRequest{Op: model.FileOpMkdir, Path: name}); err != nil {
+			t.Fatalf("mkdir %q: %v", name, err)
+		}
+		if info, err := os.Stat(filepath.Join(host.dir, name)); err != nil || !info.IsDir() {
+			t.Fatalf("mkdir %q did not create it: %v", name, err)
+		}
+		if err := client.ContainerFileOp(ctx, "web", model.FileOpRequest{Op: model.FileOpRename, Path: name, NewPath: renamed}); err != nil {
+			t.Fatalf("rename %q: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(host.dir, renamed, "file"), []byte("first\nsecond\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		content, _, size, err := client.ReadContainerFileRange(ctx, "web", renamed+"/file", FileRange{Head: 1}, 1024)
+		if err != nil || string(content) != "first\n" || size != 13 {
+			t.Fatalf("read %q = %q of %d bytes, %v", renamed+"/file", content, size, err)
+		}
+		if err := client.ContainerFileOp(ctx, "web", model.FileOpRequest{Op: model.FileOpDelete, Path: renamed, Recursive: true}); err != nil {
+			t.Fatalf("delete %q: %v", renamed, err)
+		}
+		if _, err := os.Lstat(filepath.Join(host.dir, renamed)); !os.IsNotExist(err) {
+			t.Fatalf("delete %q left it in place: %v", renamed, err)
+		}
+
+		// The script's arguments follow "docker exec web sh -c <script> sh"
+		for _, argv := range host.recorded()[len(host.recorded())-4:] {
+			if len(argv) < 7 || argv[1] != "web" {
+				t.Fatalf("docker was called with %q", argv)
+			}
+			if args := argv[6:]; args[0] != name && args[0] != renamed && args[0] != renamed+"/file" {
+				t.Fatalf("the script of %q got the arguments %q", name, args)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(host.dir, "pwned")); err == nil {
+		t.Fatal("a file name ran a command")
+	}
+	if entries, _ := os.ReadDir(host.dir); len(entries) != 0 {
+		t.Fatalf("left behind %d files", len(entries))
+	}
+}
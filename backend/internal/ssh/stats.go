@@ -0,0 +1,65 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+)
+
+// containerStatsFormats are the formats "docker stats" is read with, the second one for docker
+// versions without the json template function
+var containerStatsFormats = []string{`{{json .}}`, `{{.ID}}|{{.CPUPerc}}|{{.MemUsage}}|{{.NetIO}}|{{.BlockIO}}`}
+
+// GetContainerSamples reads a container's usage samples times in a single session, waiting
+// interval between the readings. Each reading is preceded by the host's clock, printed as
+// "--- <seconds>.<nanoseconds> ---".
+func (s *SSHClient) GetContainerSamples(ctx context.Context, containerID string, samples int, interval time.Duration) (_ []dockerapi.Sample, err error) {
+	defer s.track("container_samples", time.Now(), &err)
+	id := ShellQuote(containerID)
+	stats := fmt.Sprintf("docker stats --no-stream --format %s %s 2>/dev/null || docker stats --no-stream --format %s %s || exit",
+		ShellQuote(containerStatsFormats[0]), id, ShellQuote(containerStatsFormats[1]), id)
+	script := fmt.Sprintf(`i=0; while [ $i -lt %d ]; do if [ $i -gt 0 ]; then sleep %s; fi; echo "--- $(date +%%s.%%N) ---"; %s; i=$((i+1)); done`,
+		samples, strconv.FormatFloat(interval.Seconds(), 'f', 3, 64), stats)
+	output, err := s.runDockerScript(ctx, script, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseSamples(output), nil
+}
+
+// parseSamples reads the output of GetContainerSamples. A reading docker printed nothing for,
+// such as one of a container removed meanwhile, is left out.
+func parseSamples(output string) []dockerapi.Sample {
+	var readings []dockerapi.Sample
+	var taken time.Time
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if stamp, ok := strings.CutPrefix(line, "--- "); ok {
+			taken = parseClock(strings.TrimSuffix(stamp, " ---"))
+			continue
+		}
+		for _, usage := range dockerapi.ParseStats(line) {
+			readings = append(readings, dockerapi.Sample{Time: taken, Usage: usage})
+		}
+	}
+	return readings
+}
+
+// parseClock parses what "date +%s.%N" printed. date without %N, such as BusyBox's, prints it
+// as is, and the time is read to the second.
+func parseClock(clock string) time.Time {
+	secs, frac, _ := strings.Cut(clock, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	nsec, err := strconv.ParseInt((frac + "000000000")[:9], 10, 64)
+	if err != nil {
+		nsec = 0
+	}
+	return time.Unix(sec, nsec)
+}
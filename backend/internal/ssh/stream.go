@@ -0,0 +1,225 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/dockerapi"
+	"docker-pulse/internal/model"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// ErrStreamLimit is returned by reads from a command stream that was aborted for producing more
+// output or running longer than the stream limits allow. Log streams of the Engine API fail
+// with it too.
+var ErrStreamLimit = dockerapi.ErrStreamLimit
+
+// streamStderrLimit is how much of a streamed command's stderr is kept for its error
+const streamStderrLimit = 4096
+
+var (
+	// streamMaxBytes and streamMaxDuration bound every stream from StreamCommand
+	streamMaxBytes    atomic.Int64
+	streamMaxDuration atomic.Int64
+)
+
+func init() {
+	streamMaxBytes.Store(1 << 30)
+	streamMaxDuration.Store(int64(time.Hour))
+}
+
+// ConfigureStreams applies the stream limit settings and follows changes to them
+func ConfigureStreams(db *gorm.DB) {
+	setStreamMaxMB(strconv.Itoa(config.GetInt(db, model.ConfigKeySSHStreamMaxMB)))
+	setStreamTimeout(strconv.Itoa(config.GetInt(db, model.ConfigKeySSHStreamTimeout)))
+	config.OnChange(model.ConfigKeySSHStreamMaxMB, setStreamMaxMB)
+	config.OnChange(model.ConfigKeySSHStreamTimeout, setStreamTimeout)
+}
+
+func setStreamMaxMB(value string) {
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		streamMaxBytes.Store(int64(n) << 20)
+	}
+}
+
+func setStreamTimeout(value string) {
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		streamMaxDuration.Store(int64(time.Duration(n) * time.Second))
+	}
+}
+
+// StreamCommand starts cmd the way docker commands are run, through sudo when the client uses
+// it, and returns its stdout as it is produced. The reader must be closed; closing it kills the
+// command if it still runs and releases the session and connection.
+//
+// The command is bound by ctx rather than the command timeout. It is killed once its output
+// passes the stream size limit or it runs longer than the stream time limit, and reads then
+// return ErrStreamLimit. When the command fails, the read that reaches the end of its output
+// returns the exit error with the start of stderr.
+func (s *SSHClient) StreamCommand(ctx context.Context, cmd string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("command not started: %w", err)
+	}
+	session, conn, err := s.CreateSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		conn.Close()
+		return nil, err
+	}
+	st := &commandStream{
+		session: session,
+		conn:    conn,
+		stdout:  stdout,
+		stderr:  &headBuffer{max: streamStderrLimit},
+		max:     streamMaxBytes.Load(),
+		done:    make(chan struct{}),
+	}
+	session.Stderr = st.stderr
+	if err := session.Start(s.sudo(session, cmd)); err != nil {
+		session.Close()
+		conn.Close()
+		return nil, err
+	}
+	go func() {
+		st.waitErr = session.Wait()
+		close(st.done)
+	}()
+
+	limit := time.Duration(streamMaxDuration.Load())
+	st.timer = time.AfterFunc(limit, func() {
+		st.abort(fmt.Errorf("%w: ran longer than %s", ErrStreamLimit, limit))
+	})
+	st.stopCtx = context.AfterFunc(ctx, func() {
+		st.abort(fmt.Errorf("command aborted: %w", ctx.Err()))
+	})
+	return st, nil
+}
+
+// commandStream is the stdout of a command started by StreamCommand
+type commandStream struct {
+	session *ssh.Session
+	conn    *Conn
+	stdout  io.Reader
+	stderr  *headBuffer
+	// max is the number of bytes that may be read, n the number read so far
+	max, n  int64
+	timer   *time.Timer
+	stopCtx func() bool
+
+	// done is closed once the command exited, with waitErr set to how
+	done    chan struct{}
+	waitErr error
+
+	mu sync.Mutex
+	// aborted is why the command was killed, if it was
+	aborted   error
+	closeOnce sync.Once
+}
+
+func (st *commandStream) Read(p []byte) (int, error) {
+	if err := st.abortErr(); err != nil {
+		return 0, err
+	}
+	n, err := st.stdout.Read(p)
+	st.n += int64(n)
+	if st.n > st.max {
+		st.abort(fmt.Errorf("%w: more than %d bytes of output", ErrStreamLimit, st.max))
+		return max(n-int(st.n-st.max), 0), st.abortErr()
+	}
+	if err == nil {
+		return n, nil
+	}
+	// Output ends early when the command is killed, so the reason takes precedence
+	if abortErr := st.abortErr(); abortErr != nil {
+		return n, abortErr
+	}
+	if err == io.EOF {
+		<-st.done
+		if st.waitErr != nil {
+			return n, fmt.Errorf("%w: %s", st.waitErr, strings.TrimSpace(st.stderr.String()))
+		}
+	}
+	return n, err
+}
+
+// Close kills the command unless it exited and releases its session and connection. When the
+// host does not confirm the kill within abortGrace the connection is closed.
+func (st *commandStream) Close() error {
+	st.closeOnce.Do(func() {
+		st.timer.Stop()
+		st.stopCtx()
+		select {
+		case <-st.done:
+		default:
+			st.kill()
+			select {
+			case <-st.done:
+			case <-time.After(abortGrace):
+				st.conn.abandon()
+				<-st.done
+			}
+		}
+		st.session.Close()
+		st.conn.Close()
+	})
+	return nil
+}
+
+// abort kills the command for reason, unless it was already aborted
+func (st *commandStream) abort(reason error) {
+	st.mu.Lock()
+	first := st.aborted == nil
+	if first {
+		st.aborted = reason
+	}
+	st.mu.Unlock()
+	if first {
+		st.kill()
+	}
+}
+
+func (st *commandStream) kill() {
+	st.session.Signal(ssh.SIGKILL)
+	st.session.Close()
+}
+
+func (st *commandStream) abortErr() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.aborted
+}
+
+// headBuffer keeps the first max bytes written to it and discards the rest
+type headBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(len(p), room)]...)
+	}
+	return len(p), nil
+}
+
+func (b *headBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,141 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sudoSentinel follows the sudo password on stdin. Wrapped scripts discard stdin up to it, so a
+// password sudo did not ask for never reaches the script's own input.
+const sudoSentinel = "--docker-pulse-sudo--"
+
+// sudoPrompt is the sudo prompt of container terminals, answered with the password by the
+// writer from SudoTerminal
+const sudoPrompt = "[docker-pulse] sudo password: "
+
+// SudoCheck reports whether the SSH user can run commands as root through sudo.
+// PasswordAccepted is only set when a sudo password is configured.
+type SudoCheck struct {
+	Passwordless     bool   `json:"passwordless"`
+	PasswordAccepted *bool  `json:"password_accepted,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// SetSudo makes docker commands run through sudo. sudo must either not need a password or
+// accept password, which is then sent whenever sudo asks for it.
+func (s *SSHClient) SetSudo(password string) {
+	s.useSudo = true
+	s.sudoPassword = password
+}
+
+// sudo returns cmd to be run on session, wrapped in sudo when the client uses it. With a sudo
+// password the session's stdin is prefixed with the password, so it must be set beforehand.
+func (s *SSHClient) sudo(session *ssh.Session, cmd string) string {
+	if !s.useSudo {
+		return cmd
+	}
+	if s.sudoPassword == "" {
+		return "sudo -n sh -c " + ShellQuote(cmd)
+	}
+	stdin := session.Stdin
+	if stdin == nil {
+		stdin = strings.NewReader("")
+	}
+	session.Stdin = io.MultiReader(strings.NewReader(s.sudoPassword+"\n"+sudoSentinel+"\n"), stdin)
+	skip := `while IFS= read -r l; do [ "$l" = "` + sudoSentinel + `" ] && break; done; `
+	return "sudo -S -p '' sh -c " + ShellQuote(skip+cmd)
+}
+
+// CheckSudo reports whether sudo works without a password and, when a sudo password is
+// configured, whether sudo accepts it. Errors other than sudo refusing are returned.
+func (s *SSHClient) CheckSudo(ctx context.Context) (*SudoCheck, error) {
+	check := &SudoCheck{}
+	_, err := s.runScript(ctx, "sudo -n true", nil)
+	if err != nil && !isExitError(err) {
+		return nil, err
+	}
+	check.Passwordless = err == nil
+	if s.sudoPassword != "" && !check.Passwordless {
+		_, err = s.runDockerScript(ctx, "true", nil)
+		if err != nil && !isExitError(err) {
+			return nil, err
+		}
+		accepted := err == nil
+		check.PasswordAccepted = &accepted
+	}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check, nil
+}
+
+func isExitError(err error) bool {
+	_, ok := exitStatus(err)
+	return ok
+}
+
+// SudoTerminal returns the command that starts cmd, a docker command, in a terminal session and
+// the writer the session's output should be copied to. With a sudo password the writer answers
+// sudo's prompt by writing the password to stdin and keeps the prompt from the output.
+func (s *SSHClient) SudoTerminal(cmd string, output, stdin io.Writer) (string, io.Writer) {
+	switch {
+	case !s.useSudo:
+		return cmd, output
+	case s.sudoPassword == "":
+		return "sudo -n " + cmd, output
+	}
+	return "sudo -p " + ShellQuote(sudoPrompt) + " " + cmd, &promptAnswer{output: output, stdin: stdin, answer: s.sudoPassword + "\n"}
+}
+
+// promptAnswer passes output through, answering the first sudo prompt in it. Output is held
+// back only while it may be the start of the prompt.
+type promptAnswer struct {
+	output   io.Writer
+	stdin    io.Writer
+	answer   string
+	mu       sync.Mutex
+	pending  []byte
+	answered bool
+}
+
+func (p *promptAnswer) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.answered {
+		return p.output.Write(data)
+	}
+	p.pending = append(p.pending, data...)
+	if i := bytes.Index(p.pending, []byte(sudoPrompt)); i >= 0 {
+		p.answered = true
+		if _, err := io.WriteString(p.stdin, p.answer); err != nil {
+			return 0, err
+		}
+		rest := append(p.pending[:i:i], p.pending[i+len(sudoPrompt):]...)
+		p.pending = nil
+		if _, err := p.output.Write(rest); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	// Hold back the longest suffix that may still become the prompt
+	keep := 0
+	for n := min(len(p.pending), len(sudoPrompt)-1); n > 0; n-- {
+		if bytes.HasPrefix([]byte(sudoPrompt), p.pending[len(p.pending)-n:]) {
+			keep = n
+			break
+		}
+	}
+	flush := p.pending[:len(p.pending)-keep]
+	if len(flush) > 0 {
+		if _, err := p.output.Write(flush); err != nil {
+			return 0, err
+		}
+	}
+	p.pending = append([]byte(nil), p.pending[len(p.pending)-keep:]...)
+	return len(data), nil
+}
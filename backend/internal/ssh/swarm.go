@@ -0,0 +1,81 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotSwarmManager is returned for swarm service commands on nodes that are not managers
+var ErrNotSwarmManager = errors.New("the node is not a swarm manager")
+
+// SwarmTask is a task of a swarm service as listed by docker service ps
+type SwarmTask struct {
+	ID           string
+	DesiredState string
+	CurrentState string
+	Error        string
+}
+
+// IsSwarmManager reports whether the node can manage swarm services
+func (s *SSHClient) IsSwarmManager(ctx context.Context) (_ bool, err error) {
+	defer s.track("swarm_info", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, `docker info --format '{{.Swarm.ControlAvailable}}'`, nil)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) == "true", nil
+}
+
+// ScaleService sets a replicated service's replica count without waiting for the tasks
+func (s *SSHClient) ScaleService(ctx context.Context, name string, replicas int) (err error) {
+	defer s.track("swarm_scale", time.Now(), &err)
+	_, err = s.runDockerScript(ctx, "docker service scale --detach "+ShellQuote(fmt.Sprintf("%s=%d", name, replicas)), nil)
+	return err
+}
+
+// UpdateService changes a service's image and/or forces its tasks to be replaced, without
+// waiting for the rollout
+func (s *SSHClient) UpdateService(ctx context.Context, name, image string, force bool) (err error) {
+	defer s.track("swarm_update", time.Now(), &err)
+	cmd := "docker service update --detach"
+	if image != "" {
+		cmd += " --image " + ShellQuote(image)
+	}
+	if force {
+		cmd += " --force"
+	}
+	_, err = s.runDockerScript(ctx, cmd+" "+ShellQuote(name), nil)
+	return err
+}
+
+// ServiceReplicas returns the replica count of a replicated service, or -1 for global services
+func (s *SSHClient) ServiceReplicas(ctx context.Context, name string) (_ int, err error) {
+	defer s.track("swarm_inspect", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, fmt.Sprintf(`docker service inspect --format '{{if .Spec.Mode.Replicated}}{{.Spec.Mode.Replicated.Replicas}}{{else}}-1{{end}}' %s`, ShellQuote(name)), nil)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(output))
+}
+
+// ServiceTasks lists every task of a service, including finished ones
+func (s *SSHClient) ServiceTasks(ctx context.Context, name string) (_ []SwarmTask, err error) {
+	defer s.track("swarm_tasks", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, fmt.Sprintf(`docker service ps --no-trunc --format '{{.ID}}|{{.DesiredState}}|{{.CurrentState}}|{{.Error}}' %s`, ShellQuote(name)), nil)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []SwarmTask
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		parts := strings.SplitN(line, "|", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		tasks = append(tasks, SwarmTask{ID: parts[0], DesiredState: parts[1], CurrentState: parts[2], Error: strings.TrimSpace(parts[3])})
+	}
+	return tasks, nil
+}
@@ -0,0 +1,107 @@
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+// sysInfoScript prints the sections parsed by parseSystemInfo, each after a "--name--" line.
+// Missing files and a failing docker leave their sections empty.
+const sysInfoScript = `echo --uname--; uname -a
+echo --fields--; uname -n; uname -r; uname -m
+echo --os-release--; cat /etc/os-release 2>/dev/null || cat /usr/lib/os-release 2>/dev/null
+echo --issue--; head -n 1 /etc/issue 2>/dev/null
+echo --docker--; docker info --format '{{json .}}' 2>/dev/null
+true`
+
+// GetSystemInfo reads the host's kernel, architecture and distribution and Docker's storage
+// driver and cgroup setup
+func (s *SSHClient) GetSystemInfo(ctx context.Context) (_ *model.SystemInfo, err error) {
+	defer s.track("system_info", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, sysInfoScript, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseSystemInfo(output), nil
+}
+
+// parseSystemInfo parses sysInfoScript output
+func parseSystemInfo(output string) *model.SystemInfo {
+	sections := map[string][]string{}
+	section := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "--") && strings.HasSuffix(line, "--") && len(line) > 4 {
+			section = strings.Trim(line, "-")
+			continue
+		}
+		if line != "" {
+			sections[section] = append(sections[section], line)
+		}
+	}
+
+	info := &model.SystemInfo{}
+	if uname := sections["uname"]; len(uname) > 0 {
+		info.Uname = uname[0]
+	}
+	fields := append(sections["fields"], "", "", "")
+	info.Hostname, info.Kernel, info.Architecture = fields[0], fields[1], fields[2]
+
+	release := parseOSRelease(sections["os-release"])
+	info.OSID = release["ID"]
+	info.OSVersion = release["VERSION_ID"]
+	info.OSName = release["PRETTY_NAME"]
+	if info.OSName == "" {
+		info.OSName = strings.TrimSpace(release["NAME"] + " " + release["VERSION"])
+	}
+	if issue := sections["issue"]; info.OSName == "" && len(issue) > 0 {
+		info.OSName = cleanIssue(issue[0])
+	}
+
+	if docker := sections["docker"]; len(docker) > 0 {
+		var d struct {
+			ServerVersion string
+			Driver        string
+			CgroupVersion string
+			CgroupDriver  string
+		}
+		if json.Unmarshal([]byte(docker[0]), &d) == nil {
+			info.DockerVersion, info.StorageDriver = d.ServerVersion, d.Driver
+			info.CgroupVersion, info.CgroupDriver = d.CgroupVersion, d.CgroupDriver
+		}
+	}
+	return info
+}
+
+// parseOSRelease parses os-release lines of KEY=value, with values optionally quoted
+func parseOSRelease(lines []string) map[string]string {
+	values := map[string]string{}
+	for _, line := range lines {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if n := len(value); n >= 2 && (value[0] == '"' || value[0] == '\'') && value[n-1] == value[0] {
+			quote := value[0]
+			value = value[1 : n-1]
+			if quote == '"' {
+				value = strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\$`, `$`, "\\`", "`").Replace(value)
+			}
+		}
+		values[key] = value
+	}
+	return values
+}
+
+// cleanIssue drops the getty escapes such as "\n" and "\l" from a line of /etc/issue, e.g.
+// "Welcome to Alpine Linux 3.18\nKernel \r on an \m (\l)" keeps "Welcome to Alpine Linux 3.18"
+func cleanIssue(line string) string {
+	if i := strings.Index(line, `\`); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,52 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/dockerapi"
+)
+
+// GetContainerTop lists the processes of a running container with docker top, trying the ps
+// arguments of dockerapi.TopArgs in order since BusyBox ps rejects the GNU ones. Only the errors
+// of the last attempt are kept, so that a container that does not run fails with
+// dockerapi.ErrNotRunning.
+func (s *SSHClient) GetContainerTop(ctx context.Context, containerID string) (_ dockerapi.Top, err error) {
+	defer s.track("container_top", time.Now(), &err)
+	var attempts []string
+	for i, args := range dockerapi.TopArgs {
+		cmd := "docker top " + ShellQuote(containerID)
+		if args != "" {
+			cmd += " " + args
+		}
+		if i < len(dockerapi.TopArgs)-1 {
+			cmd += " 2>/dev/null"
+		}
+		attempts = append(attempts, cmd)
+	}
+	output, err := s.runDockerScript(ctx, strings.Join(attempts, " || "), nil)
+	if err != nil {
+		return dockerapi.Top{}, dockerapi.StateConflict(err)
+	}
+	return parseTop(output), nil
+}
+
+// parseTop reads the table docker top prints. Titles have no spaces, but the command in the
+// last column does, which the column offsets of parseDFTable keep together.
+func parseTop(output string) dockerapi.Top {
+	top := dockerapi.Top{Titles: []string{}, Processes: [][]string{}}
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+		return top
+	}
+	top.Titles = strings.Fields(lines[0])
+	for _, row := range parseDFTable(lines) {
+		process := make([]string, len(top.Titles))
+		for i, title := range top.Titles {
+			process[i] = row[dfKey(title)]
+		}
+		top.Processes = append(top.Processes, process)
+	}
+	return top
+}
@@ -0,0 +1,269 @@
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"docker-pulse/internal/model"
+)
+
+var (
+	// ErrVolumeNotFound is returned for volumes that do not exist
+	ErrVolumeNotFound = errors.New("the volume does not exist")
+	// ErrVolumeInUse is returned when removing a volume a container mounts
+	ErrVolumeInUse = errors.New("the volume is in use")
+	// ErrNotADirectory is returned when a path to be listed is not a directory
+	ErrNotADirectory = errors.New("not a directory")
+)
+
+// inUseExit is the exit status scripts use to report that a volume is mounted by a container
+const inUseExit = 4
+
+// volumeInspect is a volume as docker volume inspect prints it
+type volumeInspect struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Scope      string
+	CreatedAt  string
+	Labels     map[string]string
+	Options    map[string]string
+	Status     map[string]interface{}
+}
+
+// containerMount is a mount of docker inspect's .Mounts
+type containerMount struct {
+	Type        string
+	Name        string
+	Destination string
+	RW          bool
+}
+
+// volumesScript inspects the volumes named by the shell expression volumes, the containers
+// named by containers and, for sizes, runs "docker system df -v". Volumes and containers
+// removed in the meantime are skipped, and a failing df leaves the sizes out. Callers set -e so
+// that failing to list them is an error.
+func volumesScript(volumes, containers string) string {
+	return fmt.Sprintf(`v=%s; c=%s; `+
+		`echo '--- volumes ---'; if [ -n "$v" ]; then docker volume inspect $v 2>/dev/null || true; fi; `+
+		`echo '--- mounts ---'; if [ -n "$c" ]; then docker inspect --format '{{.Name}}|{{json .Mounts}}' $c 2>/dev/null || true; fi; `+
+		`echo '--- details ---'; %s || true`, volumes, containers, diskUsageVerboseScript)
+}
+
+// ListVolumes returns the server's volumes with the containers that mount them and their sizes.
+// Sizing takes longer the more data the volumes hold.
+func (s *SSHClient) ListVolumes(ctx context.Context) (_ []model.Volume, err error) {
+	defer s.track("list_volumes", time.Now(), &err)
+	output, err := s.runDockerScript(ctx, "set -e; "+volumesScript("$(docker volume ls -q)", "$(docker ps -aq)"), nil)
+	if err != nil {
+		return nil, err
+	}
+	details, err := parseVolumes(scriptSections(output))
+	if err != nil {
+		return nil, err
+	}
+	volumes := make([]model.Volume, len(details))
+	for i, d := range details {
+		volumes[i] = d.Volume
+	}
+	return volumes, nil
+}
+
+// GetVolume returns a volume like ListVolumes, or ErrVolumeNotFound
+func (s *SSHClient) GetVolume(ctx context.Context, name string) (_ model.VolumeDetails, err error) {
+	defer s.track("inspect_volume", time.Now(), &err)
+	q := ShellQuote(name)
+	// Listing first tells a missing volume from a failing docker
+	script := "set -e; all=$(docker volume ls -q); " + volumesScript(
+		fmt.Sprintf(`$(printf '%%s\n' "$all" | grep -Fx -- %s || true)`, q), "$(docker ps -aq --filter volume="+q+")")
+	output, err := s.runDockerScript(ctx, script, nil)
+	if err != nil {
+		return model.VolumeDetails{}, err
+	}
+	volumeList, err := parseVolumes(scriptSections(output))
+	if err != nil {
+		return model.VolumeDetails{}, err
+	}
+	for _, v := range volumeList {
+		if v.Name == name {
+			return v, nil
+		}
+	}
+	return model.VolumeDetails{}, ErrVolumeNotFound
+}
+
+// RemoveVolume removes a volume no container mounts. It fails with ErrVolumeNotFound, or with
+// ErrVolumeInUse and the names of the containers that mount it.
+func (s *SSHClient) RemoveVolume(ctx context.Context, name string) (usedBy []string, err error) {
+	defer s.track("remove_volume", time.Now(), &err)
+	q := ShellQuote(name)
+	script := fmt.Sprintf(`set -e; all=$(docker volume ls -q); printf '%%s\n' "$all" | grep -Fxq -- %[1]s || exit %[2]d; `+
+		`c=$(docker ps -a --filter volume=%[1]s --format '{{.Names}}'); if [ -n "$c" ]; then echo "$c"; exit %[3]d; fi; `+
+		`docker volume rm %[1]s >/dev/null`, q, missingExit, inUseExit)
+	output, err := s.runDockerScript(ctx, script, nil)
+	if status, ok := exitStatus(err); ok {
+		switch status {
+		case missingExit:
+			return nil, ErrVolumeNotFound
+		case inUseExit:
+			return sectionLines(output), ErrVolumeInUse
+		}
+	}
+	return nil, err
+}
+
+// parseVolumes reads the sections of volumesScript's output
+func parseVolumes(sections map[string]string) ([]model.VolumeDetails, error) {
+	var inspected []volumeInspect
+	if out := strings.TrimSpace(sections["volumes"]); out != "" {
+		if err := json.Unmarshal([]byte(out), &inspected); err != nil {
+			return nil, fmt.Errorf("failed to parse docker volume inspect output: %w", err)
+		}
+	}
+
+	mounts := map[string][]model.VolumeMount{}
+	for _, line := range sectionLines(sections["mounts"]) {
+		name, raw, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		var containerMounts []containerMount
+		if json.Unmarshal([]byte(raw), &containerMounts) != nil {
+			continue
+		}
+		for _, m := range containerMounts {
+			if m.Type == "volume" {
+				mounts[m.Name] = append(mounts[m.Name], model.VolumeMount{
+					Container:   strings.TrimPrefix(name, "/"),
+					Destination: m.Destination,
+					ReadOnly:    !m.RW,
+				})
+			}
+		}
+	}
+
+	sizes := map[string]int64{}
+	for _, row := range parseDiskUsageDetails(sections["details"]).volumes {
+		if size := row.get("SIZE"); size != "" && size != "N/A" {
+			sizes[row.get("NAME", "VOLUMENAME")] = row.size("SIZE")
+		}
+	}
+
+	volumes := []model.VolumeDetails{}
+	for _, v := range inspected {
+		d := model.VolumeDetails{
+			Volume: model.Volume{
+				Name:       v.Name,
+				Driver:     v.Driver,
+				Mountpoint: v.Mountpoint,
+				Scope:      v.Scope,
+				CreatedAt:  v.CreatedAt,
+				Labels:     v.Labels,
+				Containers: mounts[v.Name],
+			},
+			Options: v.Options,
+			Status:  v.Status,
+		}
+		if d.Labels == nil {
+			d.Labels = map[string]string{}
+		}
+		if d.Options == nil {
+			d.Options = map[string]string{}
+		}
+		if d.Containers == nil {
+			d.Containers = []model.VolumeMount{}
+		}
+		d.Dangling = len(d.Containers) == 0
+		if size, ok := sizes[v.Name]; ok {
+			d.SizeBytes = &size
+		}
+		volumes = append(volumes, d)
+	}
+	return volumes, nil
+}
+
+// volumeExecScript runs the script $s on the path $p of the volume $v with the arguments it is
+// given. When the SSH user can read the volume's mountpoint the script runs on the host, as long
+// as the path resolves to somewhere inside the volume, so that symlinks in the volume never reach
+// the host's files. Otherwise it runs in a throwaway container of $image that mounts the volume
+// read-only at /mnt and has no network. A volume that does not exist exits with $missing.
+const volumeExecScript = `all=$(docker volume ls -q) || exit 1
+printf '%s\n' "$all" | grep -Fxq -- "$v" || exit $missing
+mp=$(docker volume inspect --format '{{.Mountpoint}}' "$v") || exit 1
+t=
+if [ -n "$mp" ] && [ -r "$mp" ] && [ -x "$mp" ]; then
+	root=$(realpath -- "$mp" 2>/dev/null || readlink -f -- "$mp" 2>/dev/null) &&
+	t=$(realpath -- "$root$p" 2>/dev/null || readlink -f -- "$root$p" 2>/dev/null) &&
+	case "$t" in "$root" | "$root"/*) ;; *) t= ;; esac
+fi
+if [ -n "$t" ]; then
+	sh -c "$s" sh "$t" "$@"
+else
+	docker run --rm --network none -v "$v:/mnt:ro" --entrypoint sh "$image" -c "$s" sh "/mnt$p" "$@"
+fi`
+
+// volumeListScript lists the directory $1 like listFilesScript, cut to $2 lines
+const volumeListScript = `[ -e "$1" ] || { echo "$1: No such file or directory" >&2; exit 1; }
+[ -d "$1" ] || { echo "$1: Not a directory" >&2; exit 1; }
+{
+` + listFilesScript + `
+} | head -n "$2"`
+
+// runVolumeScript runs script on filePath in a volume, see volumeExecScript, and returns its
+// output. It fails with ErrVolumeNotFound and, from the script's messages, ErrFileNotFound,
+// ErrNotAFile and ErrNotADirectory.
+func (s *SSHClient) runVolumeScript(ctx context.Context, volume, helperImage, script, filePath string, args ...string) (string, error) {
+	cmd := fmt.Sprintf("v=%s; p=%s; s=%s; image=%s; missing=%d; set --",
+		ShellQuote(volume), ShellQuote(filePath), ShellQuote(script), ShellQuote(helperImage), missingExit)
+	for _, arg := range args {
+		cmd += " " + ShellQuote(arg)
+	}
+	output, err := s.runDockerScript(ctx, cmd+"\n"+volumeExecScript, nil)
+	if status, ok := exitStatus(err); ok && status == missingExit {
+		return "", ErrVolumeNotFound
+	}
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "No such file or directory"):
+			return "", fmt.Errorf("%s: %w", filePath, ErrFileNotFound)
+		case strings.Contains(msg, "not a regular file"):
+			return "", fmt.Errorf("%s: %w", filePath, ErrNotAFile)
+		case strings.Contains(msg, "Not a directory"):
+			return "", fmt.Errorf("%s: %w", filePath, ErrNotADirectory)
+		}
+		return "", err
+	}
+	return output, nil
+}
+
+// ListVolumeFiles lists a directory of a volume, dir being the path inside the volume, with at
+// most limit entries. truncated is set when there were more; ls sorts them by name.
+func (s *SSHClient) ListVolumeFiles(ctx context.Context, volume, helperImage, dir string, limit int) (_ []model.FileEntry, truncated bool, err error) {
+	defer s.track("list_volume_files", time.Now(), &err)
+	// Besides the entries ls prints a total and the "." and ".." entries
+	output, err := s.runVolumeScript(ctx, volume, helperImage, volumeListScript, dir, strconv.Itoa(limit+4))
+	if err != nil {
+		return nil, false, err
+	}
+	files := parseFileList(output)
+	if len(files) > limit {
+		return files[:limit], true, nil
+	}
+	return files, false, nil
+}
+
+// ReadVolumeFileRange reads part of a file in a volume like ReadContainerFileRange
+func (s *SSHClient) ReadVolumeFileRange(ctx context.Context, volume, helperImage, filePath string, r FileRange, limit int64) (_ []byte, offset, size int64, err error) {
+	defer s.track("read_volume_file", time.Now(), &err)
+	output, err := s.runVolumeScript(ctx, volume, helperImage, fileRangeScript, filePath, r.args(limit)...)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return r.parse(output)
+}
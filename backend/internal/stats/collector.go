@@ -1,49 +1,156 @@
 package stats
 
 import (
+	"context"
+	"docker-pulse/internal/config"
 	"docker-pulse/internal/model"
+	"docker-pulse/internal/notify"
 	"docker-pulse/internal/ssh"
-	"log"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-func StartCollector(db *gorm.DB) {
-	ticker := time.NewTicker(5 * time.Minute)
+// collectTimeout bounds the commands of one server's collection, well below the command
+// timeout so that a stalled server does not hold up the cycle
+const collectTimeout = 10 * time.Second
+
+// logsListed is the number of the largest container logs a disk alert lists
+const logsListed = 5
+
+// StartCollector runs the collector until ctx is cancelled. The returned channel is closed
+// once the cycle in progress has finished writing.
+func StartCollector(ctx context.Context, db *gorm.DB) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
-		// Run once at start
-		collect(db)
-		for range ticker.C {
+		defer close(done)
+		for {
 			collect(db)
+			// Re-read the interval every cycle so config changes apply without a restart
+			interval := time.Duration(config.GetInt(db, model.ConfigKeyStatsInterval)) * time.Second
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
 		}
 	}()
+	return done
+}
+
+// lastOnline remembers whether each server was reachable in the previous cycle
+var lastOnline sync.Map // server ID -> bool
+
+// trackStatus notifies users when a server goes offline or comes back. The first cycle after
+// startup only records the state, so restarts don't repeat old alerts.
+func trackStatus(db *gorm.DB, s model.Server, online bool, cause error) {
+	prev, seen := lastOnline.Swap(s.ID, online)
+	if !seen || prev.(bool) == online {
+		return
+	}
+	ev := notify.Event{Type: notify.ServerRecovered, ServerID: s.ID, ServerName: s.Name}
+	if !online {
+		ev.Type = notify.ServerOffline
+		if cause != nil {
+			ev.Detail = cause.Error()
+		}
+	}
+	notify.Notify(db, ev)
+}
+
+// diskFull remembers whether each server's disk was over the alert threshold in the previous cycle
+var diskFull sync.Map // server ID -> bool
+
+// trackDisk notifies users when the disk holding Docker's data fills past the disk_alert_percent
+// setting, and again only once it was below it. Container logs without rotation are a common
+// cause, so the alert lists the largest. As with trackStatus, the first cycle after startup only
+// records the state.
+func trackDisk(db *gorm.DB, s model.Server, stats *ssh.ServerStats) {
+	threshold := config.GetInt(db, model.ConfigKeyDiskAlertPercent)
+	full := threshold > 0 && stats.DiskTotal > 0 && stats.DiskUsage >= float64(threshold)
+	prev, seen := diskFull.Swap(s.ID, full)
+	if !full || !seen || prev.(bool) {
+		return
+	}
+	ev := notify.Event{
+		Type:       notify.DiskFull,
+		ServerID:   s.ID,
+		ServerName: s.Name,
+		Detail:     fmt.Sprintf("%.0f%% full, %s of %s used", stats.DiskUsage, formatSize(stats.DiskUsed), formatSize(stats.DiskTotal)),
+		Lines:      largestLogs(s),
+	}
+	notify.Notify(db, ev)
+}
+
+// largestLogs describes the largest container logs on a server, or returns nil when they
+// cannot be read, as on servers managed over the Docker API only
+func largestLogs(s model.Server) []string {
+	client, err := ssh.NewServerClient(&s)
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+	usage, err := client.GetLogUsage(ctx)
+	if err != nil {
+		slog.Warn("collector: failed to read container log sizes", "server_id", s.ID, "error", err)
+		return nil
+	}
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].SizeBytes > usage[j].SizeBytes })
+	var lines []string
+	for _, u := range usage {
+		if len(lines) == logsListed || u.SizeBytes == 0 {
+			break
+		}
+		lines = append(lines, u.Name+": "+formatSize(u.SizeBytes))
+	}
+	return lines
+}
+
+// formatSize prints a number of bytes in the largest binary unit that keeps it above 1
+func formatSize(bytes int64) string {
+	size, unit := float64(bytes), 0
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
 }
 
 func collect(db *gorm.DB) {
 	var servers []model.Server
 	if err := db.Find(&servers).Error; err != nil {
-		log.Printf("Collector: failed to fetch servers: %v", err)
+		slog.Error("collector: failed to fetch servers", "error", err)
 		return
 	}
 
-	var pingTargets string
-	var config model.Config
-	if err := db.Where("key = ?", model.ConfigKeyPingTargets).First(&config).Error; err == nil {
-		pingTargets = config.Value
-	}
+	pingTargets, _ := config.Get(db, model.ConfigKeyPingTargets)
 
+	var wg sync.WaitGroup
 	for _, server := range servers {
+		wg.Add(1)
 		go func(s model.Server) {
-			sshClient, err := ssh.NewSSHClient(s.IP, s.Port, s.Username, s.AuthMode, s.Secret)
+			defer wg.Done()
+			// The history tables keep latency and disk usage
+			ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+			defer cancel()
+			stats, err := ssh.RealtimeStats(ctx, &s, pingTargets)
 			if err != nil {
+				// The server's credentials are unusable, which is not an outage
 				return
 			}
-
-			// We only need latency for the history table
-			stats, err := sshClient.GetServerRealtimeStats(pingTargets)
-			if err != nil {
-				return
+			// A stopped docker daemon still leaves the server reachable
+			trackStatus(db, s, stats.Status != ssh.StatusOffline, nil)
+			if stats.Platform != "" && stats.Platform != s.Platform {
+				db.Model(&s).Update("platform", stats.Platform)
 			}
 
 			now := time.Now()
@@ -67,9 +174,23 @@ func collect(db *gorm.DB) {
 				}
 				db.Create(&history)
 			}
+
+			trackDisk(db, s, stats)
+			if stats.DiskTotal > 0 {
+				db.Create(&model.DiskHistory{
+					ServerID:  s.ID,
+					Usage:     stats.DiskUsage,
+					Total:     stats.DiskTotal,
+					Used:      stats.DiskUsed,
+					Timestamp: now,
+				})
+			}
 		}(server)
 	}
+	wg.Wait()
 
 	// Periodic cleanup of old stats (older than 30 days)
-	db.Where("timestamp < ?", time.Now().AddDate(0, 0, -30)).Delete(&model.StatsHistory{})
+	cutoff := time.Now().AddDate(0, 0, -30)
+	db.Where("timestamp < ?", cutoff).Delete(&model.StatsHistory{})
+	db.Where("timestamp < ?", cutoff).Delete(&model.DiskHistory{})
 }
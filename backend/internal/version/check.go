@@ -0,0 +1,152 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"docker-pulse/internal/config"
+	"docker-pulse/internal/model"
+	"docker-pulse/internal/notify"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// releasesURL is the GitHub API endpoint of the latest release
+	releasesURL = "https://api.github.com/repos/AsZer0s/DockerManager/releases/latest"
+	// checkInterval is the time between update checks
+	checkInterval = 24 * time.Hour
+	checkTimeout  = 15 * time.Second
+)
+
+var httpClient = &http.Client{Timeout: checkTimeout}
+
+// Info is the running version and the result of the last update check
+type Info struct {
+	Current         string     `json:"current"`
+	Commit          string     `json:"commit,omitempty"`
+	Latest          string     `json:"latest,omitempty"`
+	UpdateAvailable bool       `json:"update_available"`
+	ReleaseURL      string     `json:"release_url,omitempty"`
+	CheckedAt       *time.Time `json:"checked_at,omitempty"`
+	CheckEnabled    bool       `json:"check_enabled"`
+}
+
+// release is the part of a GitHub release the check uses
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+var (
+	mu        sync.RWMutex
+	latest    release
+	checkedAt time.Time
+	// notified is the release admins were last told about
+	notified string
+)
+
+// Current returns the running version and the cached result of the last successful check
+func Current(db *gorm.DB) Info {
+	mu.RLock()
+	defer mu.RUnlock()
+	info := Info{
+		Current:      Version,
+		Commit:       Commit,
+		Latest:       latest.TagName,
+		ReleaseURL:   latest.HTMLURL,
+		CheckEnabled: config.GetBool(db, model.ConfigKeyUpdateCheck),
+	}
+	if !checkedAt.IsZero() {
+		t := checkedAt
+		info.CheckedAt = &t
+		info.UpdateAvailable = Newer(latest.TagName, Version)
+	}
+	return info
+}
+
+// Start checks for a newer release once a day while update_check is enabled, and right away
+// when it is switched on. Nothing is ever downloaded or installed.
+func Start(ctx context.Context, db *gorm.DB) {
+	trigger := make(chan struct{}, 1)
+	config.OnChange(model.ConfigKeyUpdateCheck, func(value string) {
+		if on, _ := strconv.ParseBool(value); on {
+			select {
+			case trigger <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			if config.GetBool(db, model.ConfigKeyUpdateCheck) {
+				check(ctx, db)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-trigger:
+			}
+		}
+	}()
+}
+
+// check fetches the latest release and tells admins about a new one. Failures are only logged
+// at debug level, since panels without internet access are expected.
+func check(ctx context.Context, db *gorm.DB) {
+	r, err := fetchLatest(ctx)
+	if err != nil {
+		slog.Debug("update check failed", "error", err)
+		return
+	}
+
+	mu.Lock()
+	latest = r
+	checkedAt = time.Now()
+	announce := Newer(r.TagName, Version) && r.TagName != notified
+	if announce {
+		notified = r.TagName
+	}
+	mu.Unlock()
+
+	if announce {
+		slog.Info("a newer DockerManager release is available", "current", Version, "latest", r.TagName, "url", r.HTMLURL)
+		notify.Notify(db, notify.Event{Type: notify.UpdateAvailable, Version: r.TagName, URL: r.HTMLURL, Detail: Version})
+	}
+}
+
+func fetchLatest(ctx context.Context) (release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return release{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "DockerManager/"+Version)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return release{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return release{}, fmt.Errorf("GitHub returned HTTP %d", resp.StatusCode)
+	}
+	var r release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&r); err != nil {
+		return release{}, err
+	}
+	if r.TagName == "" {
+		return release{}, fmt.Errorf("release has no tag")
+	}
+	return r, nil
+}
@@ -0,0 +1,63 @@
+// Package version reports the running DockerManager version and checks GitHub for newer releases
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Version and Commit are set at build time:
+//
+//	go build -ldflags "-X docker-pulse/internal/version.Version=1.2.3 -X docker-pulse/internal/version.Commit=$(git rev-parse --short HEAD)"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// String returns the version with the commit appended when it is known
+func String() string {
+	if Commit == "" {
+		return Version
+	}
+	return Version + " (" + Commit + ")"
+}
+
+// Newer reports whether release is a newer semantic version than current. Versions that do
+// not parse, such as "dev" builds, are never considered outdated.
+func Newer(release, current string) bool {
+	r, ok := parse(release)
+	if !ok {
+		return false
+	}
+	c, ok := parse(current)
+	if !ok {
+		return false
+	}
+	for i := range r {
+		if r[i] != c[i] {
+			return r[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parse reads "v1.2.3" or "1.2" into its numeric parts. Pre-release and build suffixes are ignored.
+func parse(v string) ([3]int, bool) {
+	var out [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) > 3 {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
@@ -39,8 +39,8 @@ const ContainerFileManager: React.FC<ContainerFileManagerProps> = ({ serverId, c
   };
 
   const handleFileClick = async (file: FileEntry) => {
-    // Treat directories and symbolic links as navigable paths
-    if (file.is_dir || file.is_symlink) {
+    // Symbolic links to directories come with is_dir set
+    if (file.is_dir) {
       setCurrentPath((prevPath) => (prevPath === '/' ? `/${file.name}` : `${prevPath}/${file.name}`));
     } else {
       // View file content
@@ -49,7 +49,7 @@ const ContainerFileManager: React.FC<ContainerFileManagerProps> = ({ serverId, c
       setFileContent(null); // Reset content when opening new file
       try {
         const response = await containerApi.getContainerFileContent(serverId, containerId, `${currentPath === '/' ? '' : currentPath}/${file.name}`);
-        setFileContent(response.data.content);
+        setFileContent(response.data.is_binary ? response.data.hint ?? '' : response.data.content);
       } catch (err: any) {
         setFileContent(`${t('failed_to_load_file_content')}: ${err.response?.data?.error || err.message}`);
       }
@@ -62,10 +62,18 @@ const ContainerFileManager: React.FC<ContainerFileManagerProps> = ({ serverId, c
     setCurrentPath(parentPath);
   };
 
-  const handleDownloadFile = (file: FileEntry) => {
-    // This would typically involve a backend endpoint that streams the file
-    // For now, we'll just log it or show a placeholder.
-    alert(`${t('download_not_implemented').replace('${file.name}', file.name)}`);
+  const handleDownloadFile = async (file: FileEntry) => {
+    try {
+      const response = await containerApi.downloadContainerFile(serverId, containerId, `${currentPath === '/' ? '' : currentPath}/${file.name}`);
+      const url = URL.createObjectURL(response.data);
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = file.name;
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (err: any) {
+      alert(`${t('failed_to_download_file')}: ${err.message}`);
+    }
   };
 
   const renderFileContentModal = () => {
@@ -160,6 +168,7 @@ const ContainerFileManager: React.FC<ContainerFileManagerProps> = ({ serverId, c
                         {file.is_dir ? <Folder className="w-4 h-4 text-blue-600 dark:text-blue-400" /> : <FileText className="w-4 h-4 text-zinc-500 dark:text-zinc-400" />}
                       </div>
                       {file.name}
+                      {file.link_target && <span className="text-xs text-zinc-400">→ {file.link_target}</span>}
                     </div>
                   </td>
                   <td className="px-4 py-3">{file.is_dir ? '-' : `${(file.size / 1024).toFixed(1)} KB`}</td>
@@ -1,7 +1,7 @@
 import React, { useState, useEffect, useRef } from 'react';
 import { X, Box, Info, AlertTriangle, CheckCircle, RefreshCw, Terminal as TerminalIcon, FileText, ScrollText } from 'lucide-react';
 import { useApp } from '../hooks/useApp';
-import { containerApi } from '../lib/api';
+import { containerApi, ContainerDetailsResponse } from '../lib/api';
 import Terminal from './Terminal'; // Import the Terminal component
 import ContainerFileManager from './ContainerFileManager'; // Import the file manager
 
@@ -17,7 +17,7 @@ type Tab = 'info' | 'exec' | 'file' | 'logs';
 const ContainerModal: React.FC<ContainerModalProps> = ({ isOpen, onClose, serverId, containerId }) => {
   const { t } = useApp();
   const [activeTab, setActiveTab] = useState<Tab>('info');
-  const [details, setDetails] = useState<any>(null);
+  const [details, setDetails] = useState<ContainerDetailsResponse | null>(null);
   const [logs, setLogs] = useState<string>('');
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
@@ -50,8 +50,7 @@ const ContainerModal: React.FC<ContainerModalProps> = ({ isOpen, onClose, server
     setError(null);
     try {
       const response = await containerApi.getContainerDetails(serverId, containerId);
-      const parsedDetails = JSON.parse(response.data.details);
-      setDetails(Array.isArray(parsedDetails) ? parsedDetails : parsedDetails);
+      setDetails(response.data);
     } catch (err: any) {
       setError(`${t('fetch_details_error')}: ${err.response?.data?.error || err.message}`);
     } finally {
@@ -98,10 +97,10 @@ const ContainerModal: React.FC<ContainerModalProps> = ({ isOpen, onClose, server
                 <div>
                   <h3 className="text-sm font-semibold text-zinc-500 uppercase tracking-wider mb-2">{t('basic_info')}</h3>
                   <div className="grid grid-cols-1 md:grid-cols-2 gap-4 text-sm">
-                    <p><strong className="text-zinc-400">{t('username')}:</strong> {details?.Name?.replace(/^\//, '')}</p>
-                    <p><strong className="text-zinc-400">{t('id')}:</strong> <span className="font-mono text-xs">{details?.Id?.substring(0, 12)}</span></p>
-                    <p><strong className="text-zinc-400">{t('image_name')}:</strong> <span className="font-mono text-xs">{details?.Config?.Image}</span></p>
-                    <p><strong className="text-zinc-400">{t('created')}:</strong> {new Date(details?.Created).toLocaleString()}</p>
+                    <p><strong className="text-zinc-400">{t('username')}:</strong> {details.name}</p>
+                    <p><strong className="text-zinc-400">{t('id')}:</strong> <span className="font-mono text-xs">{details.id.substring(0, 12)}</span></p>
+                    <p><strong className="text-zinc-400">{t('image_name')}:</strong> <span className="font-mono text-xs">{details.image}</span></p>
+                    <p><strong className="text-zinc-400">{t('created')}:</strong> {new Date(details.created).toLocaleString()}</p>
                   </div>
                 </div>
 
@@ -109,10 +108,10 @@ const ContainerModal: React.FC<ContainerModalProps> = ({ isOpen, onClose, server
                 <div>
                   <h3 className="text-sm font-semibold text-zinc-500 uppercase tracking-wider mb-2">{t('status')}</h3>
                   <div className="grid grid-cols-1 md:grid-cols-2 gap-4 text-sm">
-                    <p><strong className="text-zinc-400">{t('status')}:</strong> {details?.State?.Status}</p>
-                    <p><strong className="text-zinc-400">{t('running')}:</strong> {details?.State?.Running ? t('yes') : t('no')}</p>
-                    <p><strong className="text-zinc-400">PID:</strong> {details?.State?.Pid}</p>
-                    <p><strong className="text-zinc-400">{t('start')}:</strong> {new Date(details?.State?.StartedAt).toLocaleString()}</p>
+                    <p><strong className="text-zinc-400">{t('status')}:</strong> {details.state.status}</p>
+                    <p><strong className="text-zinc-400">{t('running')}:</strong> {details.state.running ? t('yes') : t('no')}</p>
+                    <p><strong className="text-zinc-400">PID:</strong> {details.state.pid}</p>
+                    <p><strong className="text-zinc-400">{t('start')}:</strong> {new Date(details.state.started_at).toLocaleString()}</p>
                   </div>
                 </div>
 
@@ -200,7 +199,7 @@ const ContainerModal: React.FC<ContainerModalProps> = ({ isOpen, onClose, server
               <Box className="w-5 h-5 text-emerald-500 dark:text-emerald-400" />
             </div>
             <div>
-              <h2 className="text-xl font-bold text-zinc-900 dark:text-zinc-100">{details?.[0]?.Name?.replace('/', '') || containerId.substring(0, 12)}</h2>
+              <h2 className="text-xl font-bold text-zinc-900 dark:text-zinc-100">{details?.name || containerId.substring(0, 12)}</h2>
               <p className="text-xs text-zinc-500 font-mono">{containerId}</p>
             </div>
           </div>
@@ -11,6 +11,8 @@ import {
   Edit2,
   Trash2,
   HardDrive,
+  Database,
+  ArrowDownUp,
   Container as ContainerIcon,
   Activity
 } from 'lucide-react';
@@ -18,6 +20,17 @@ import { useApp } from '../hooks/useApp';
 import { Server } from '../lib/api';
 import { Link } from 'react-router-dom'; // Import Link
 
+// formatRate formats a rate in bytes per second, e.g. "1.2 MB/s"
+const formatRate = (bytes: number = 0) => {
+  const units = ['B/s', 'KB/s', 'MB/s', 'GB/s'];
+  let i = 0;
+  while (bytes >= 1024 && i < units.length - 1) {
+    bytes /= 1024;
+    i++;
+  }
+  return `${bytes.toFixed(i === 0 ? 0 : 1)} ${units[i]}`;
+};
+
 interface ServerCardProps {
   server: any; // 使用 any 或具体扩展类型以兼容 Servers.tsx
   onEdit?: (server: Server) => void;
@@ -31,6 +44,8 @@ const ServerCard: React.FC<ServerCardProps> = ({ server, onEdit, onDelete, activ
   const [showTerminalModal, setShowTerminalModal] = useState(false);
 
   const isOnline = server.status === 'online';
+  // The host answers but docker cannot be used
+  const dockerDown = server.status === 'docker_stopped' || server.status === 'docker_missing';
 
   const handleConnectClick = () => {
     setShowTerminalModal(true);
@@ -52,11 +67,11 @@ const ServerCard: React.FC<ServerCardProps> = ({ server, onEdit, onDelete, activ
         <div className="relative z-20 mb-6 flex items-start justify-between">
           <div className="flex items-center gap-3">
             {/* Status Indicator */}
-            <div className="relative flex h-3 w-3">
+            <div className="relative flex h-3 w-3" title={server.statusDetail || undefined}>
               {isOnline && (
                 <span className="absolute inline-flex h-full w-full animate-ping rounded-full bg-emerald-400 opacity-75"></span>
               )}
-              <span className={`relative inline-flex h-3 w-3 rounded-full ${isOnline ? 'bg-emerald-500' : 'bg-rose-500'}`}></span>
+              <span className={`relative inline-flex h-3 w-3 rounded-full ${isOnline ? 'bg-emerald-500' : dockerDown ? 'bg-amber-500' : 'bg-rose-500'}`}></span>
             </div>
 
             <div>
@@ -64,6 +79,9 @@ const ServerCard: React.FC<ServerCardProps> = ({ server, onEdit, onDelete, activ
                 {server.name}
               </h2>
               <p className="font-mono text-xs text-zinc-500 mt-0.5">{server.ip}</p>
+              {dockerDown && (
+                <p className="text-xs text-amber-500 mt-0.5" title={server.statusDetail || undefined}>{t(server.status)}</p>
+              )}
             </div>
           </div>
 
@@ -113,8 +131,8 @@ const ServerCard: React.FC<ServerCardProps> = ({ server, onEdit, onDelete, activ
                 <Cpu className="h-3.5 w-3.5" />
                 <span>{t('cpu_usage')}</span>
               </div>
-              <span className={`font-mono ${isOnline ? 'text-zinc-200' : 'text-zinc-600'}`}>
-                {server.cpuUsage}%
+              <span className={`font-mono ${isOnline ? 'text-zinc-200' : 'text-zinc-600'}`} title={server.systemStatsError}>
+                {server.systemStatsError ? 'N/A' : `${server.cpuUsage}%`}
               </span>
             </div>
             <div className="h-1.5 w-full overflow-hidden rounded-full bg-zinc-100 dark:bg-zinc-800">
@@ -133,8 +151,8 @@ const ServerCard: React.FC<ServerCardProps> = ({ server, onEdit, onDelete, activ
                 <HardDrive className="h-3.5 w-3.5" />
                 <span>{t('memory_usage')}</span>
               </div>
-              <span className={`font-mono ${isOnline ? 'text-zinc-200' : 'text-zinc-600'}`}>
-                {server.ramUsage}%
+              <span className={`font-mono ${isOnline ? 'text-zinc-200' : 'text-zinc-600'}`} title={server.systemStatsError}>
+                {server.systemStatsError ? 'N/A' : `${server.ramUsage}%`}
               </span>
             </div>
             <div className="h-1.5 w-full overflow-hidden rounded-full bg-zinc-100 dark:bg-zinc-800">
@@ -146,6 +164,39 @@ const ServerCard: React.FC<ServerCardProps> = ({ server, onEdit, onDelete, activ
             </div>
           </div>
 
+          {/* Disk Metric */}
+          <div className="space-y-1.5">
+            <div className="flex items-center justify-between text-xs">
+              <div className="flex items-center gap-1.5 text-zinc-400">
+                <Database className="h-3.5 w-3.5" />
+                <span>{t('disk_usage')}</span>
+              </div>
+              <span className={`font-mono ${isOnline ? 'text-zinc-200' : 'text-zinc-600'}`}>
+                {(server.diskUsage ?? 0).toFixed(1)}%
+              </span>
+            </div>
+            <div className="h-1.5 w-full overflow-hidden rounded-full bg-zinc-100 dark:bg-zinc-800">
+              <div
+                className={`h-full rounded-full transition-all duration-1000 ease-out ${isOnline ? (server.diskUsage >= 90 ? 'bg-red-500' : 'bg-cyan-500') : 'bg-zinc-700'
+                  }`}
+                style={{ width: `${server.diskUsage ?? 0}%` }}
+              />
+            </div>
+          </div>
+
+          {/* Network Metric */}
+          {isOnline && (
+            <div className="flex items-center justify-between text-xs">
+              <div className="flex items-center gap-1.5 text-zinc-400">
+                <ArrowDownUp className="h-3.5 w-3.5" />
+                <span>{t('network_traffic')}</span>
+              </div>
+              <span className="font-mono text-zinc-200">
+                ↓ {formatRate(server.netRxRate)} · ↑ {formatRate(server.netTxRate)}
+              </span>
+            </div>
+          )}
+
           {/* Latency Metric */}
           {isOnline && server.latency_map && Object.keys(server.latency_map).length > 0 && (
             <div className="space-y-1.5">
@@ -176,6 +227,12 @@ const ServerCard: React.FC<ServerCardProps> = ({ server, onEdit, onDelete, activ
             <div className="text-zinc-500">{t('docker_version')}</div>
             <div className="text-zinc-700 dark:text-zinc-300 text-right font-mono truncate">{server.dockerVersion}</div>
 
+            <div className="text-zinc-500">{t('load_average')}</div>
+            <div className="text-zinc-700 dark:text-zinc-300 text-right font-mono truncate">
+              {(server.loadAvg ?? [0, 0, 0]).map((l: number) => l.toFixed(2)).join(' ')}
+              {server.cpuCores > 0 && ` / ${server.cpuCores} ${t('cores')}`}
+            </div>
+
             <div className="text-zinc-500">{t('uptime')}</div>
             <div className="text-zinc-700 dark:text-zinc-300 text-right font-mono truncate">{server.uptime}</div>
           </div>
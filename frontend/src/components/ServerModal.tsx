@@ -1,7 +1,7 @@
 import React, { useState, useEffect } from 'react';
-import { X, Server as ServerIcon, KeyRound, User, Globe, Hash, Lock } from 'lucide-react';
+import { X, Server as ServerIcon, KeyRound, User, Globe, Hash, Lock, FileText, PlugZap, CheckCircle2, XCircle, Loader2 } from 'lucide-react';
 import { useApp } from '../hooks/useApp';
-import { Server, ServerPayload } from '../lib/api';
+import { Server, ServerPayload, ServerProbe, serverApi } from '../lib/api';
 
 interface ServerModalProps {
   isOpen: boolean;
@@ -16,10 +16,17 @@ const ServerModal: React.FC<ServerModalProps> = ({ isOpen, onClose, onSave, edit
   const [ip, setIp] = useState('');
   const [port, setPort] = useState('22');
   const [username, setUsername] = useState('root');
-  const [authMode, setAuthMode] = useState('password'); // 'password' or 'key'
-  const [secret, setSecret] = useState(''); // password or private key
+  const [authMode, setAuthMode] = useState('password'); // 'password', 'key' or 'docker-tls'
+  const [secret, setSecret] = useState(''); // password, private key or TLS bundle
+  const [description, setDescription] = useState('');
+  const [notes, setNotes] = useState(''); // markdown
+  const [testing, setTesting] = useState(false);
+  const [probe, setProbe] = useState<ServerProbe | null>(null);
+  const [testError, setTestError] = useState('');
 
   useEffect(() => {
+    setProbe(null);
+    setTestError('');
     if (isOpen && editingServer) {
       setName(editingServer.name);
       setIp(editingServer.ip);
@@ -28,6 +35,8 @@ const ServerModal: React.FC<ServerModalProps> = ({ isOpen, onClose, onSave, edit
       setAuthMode(editingServer.auth_mode);
       // Note: Secret is not returned by API for security reasons, so it won't be pre-filled
       setSecret('');
+      setDescription(editingServer.description || '');
+      setNotes(editingServer.notes || '');
     } else if (isOpen) {
       // Reset form when opening for new server
       setName('');
@@ -36,21 +45,43 @@ const ServerModal: React.FC<ServerModalProps> = ({ isOpen, onClose, onSave, edit
       setUsername('root');
       setAuthMode('password');
       setSecret('');
+      setDescription('');
+      setNotes('');
     }
   }, [isOpen, editingServer]);
 
   if (!isOpen) return null;
 
+  const payload = (): ServerPayload => ({
+    name,
+    ip,
+    port: parseInt(port, 10),
+    username,
+    auth_mode: authMode,
+    secret,
+    description,
+    notes,
+  });
+
   const handleSubmit = (e: React.FormEvent) => {
     e.preventDefault();
-    onSave({
-      name,
-      ip,
-      port: parseInt(port, 10),
-      username,
-      auth_mode: authMode,
-      secret,
-    });
+    onSave(payload());
+  };
+
+  // Tests the form's values without saving them. An edited server keeps its stored secret
+  // when the field is left blank.
+  const handleTest = async () => {
+    setTesting(true);
+    setProbe(null);
+    setTestError('');
+    try {
+      const res = await serverApi.testServerConnection(editingServer ? { ...payload(), server_id: editingServer.ID } : payload());
+      setProbe(res.data);
+    } catch (err: any) {
+      setTestError(err.response?.data?.message || err.message);
+    } finally {
+      setTesting(false);
+    }
   };
 
   return (
@@ -131,7 +162,7 @@ const ServerModal: React.FC<ServerModalProps> = ({ isOpen, onClose, onSave, edit
                   onChange={(e) => setUsername(e.target.value)}
                   className="w-full pl-10 pr-3 py-2.5 bg-white dark:bg-zinc-950/50 border border-zinc-200 dark:border-zinc-800 rounded-xl text-zinc-900 dark:text-zinc-100 placeholder-zinc-400 dark:placeholder-zinc-600 focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500/50 transition-all text-sm shadow-sm"
                   placeholder="e.g., root"
-                  required
+                  required={authMode !== 'docker-tls'}
                 />
               </div>
             </div>
@@ -144,11 +175,17 @@ const ServerModal: React.FC<ServerModalProps> = ({ isOpen, onClose, onSave, edit
               <select
                 id="authMode"
                 value={authMode}
-                onChange={(e) => setAuthMode(e.target.value)}
+                onChange={(e) => {
+                  setAuthMode(e.target.value);
+                  // The Docker API listens on 2376 with TLS rather than on the SSH port
+                  if (e.target.value === 'docker-tls' && port === '22') setPort('2376');
+                  if (e.target.value !== 'docker-tls' && port === '2376') setPort('22');
+                }}
                 className="w-full pl-10 pr-3 py-2.5 bg-white dark:bg-zinc-950/50 border border-zinc-200 dark:border-zinc-800 rounded-xl text-zinc-900 dark:text-zinc-100 focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500/50 transition-all text-sm appearance-none cursor-pointer shadow-sm"
               >
                 <option value="password">{t('password')}</option>
                 <option value="key">{t('ssh_key')}</option>
+                <option value="docker-tls">{t('docker_tls')}</option>
               </select>
               <div className="pointer-events-none absolute inset-y-0 right-0 flex items-center px-3 text-zinc-400 dark:text-zinc-500">
                 <svg className="fill-current h-4 w-4" xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 20"><path d="M9.293 12.95l.707.707L15.657 8l-1.414-1.414L10 10.828 5.757 6.586 4.343 8z" /></svg>
@@ -158,7 +195,7 @@ const ServerModal: React.FC<ServerModalProps> = ({ isOpen, onClose, onSave, edit
 
           <div>
             <label htmlFor="secret" className="block text-xs font-semibold text-zinc-500 dark:text-zinc-400 uppercase tracking-wider mb-1.5 ml-1">
-              {authMode === 'password' ? t('password') : t('ssh_key')}
+              {authMode === 'password' ? t('password') : authMode === 'docker-tls' ? t('docker_tls') : t('ssh_key')}
             </label>
             <div className="relative group">
               <KeyRound className="absolute left-3 top-3 w-4 h-4 text-zinc-400 dark:text-zinc-500 group-focus-within:text-emerald-500 transition-colors" />
@@ -179,14 +216,68 @@ const ServerModal: React.FC<ServerModalProps> = ({ isOpen, onClose, onSave, edit
                   onChange={(e) => setSecret(e.target.value)}
                   rows={4}
                   className="w-full pl-10 pr-3 py-3 bg-white dark:bg-zinc-950/50 border border-zinc-200 dark:border-zinc-800 rounded-xl text-zinc-900 dark:text-zinc-100 placeholder-zinc-400 dark:placeholder-zinc-600 focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500/50 transition-all text-sm font-mono shadow-sm"
-                  placeholder={editingServer ? t('leave_blank_to_keep') : t('paste_ssh_key')}
+                  placeholder={editingServer ? t('leave_blank_to_keep') : authMode === 'docker-tls' ? t('paste_tls_bundle') : t('paste_ssh_key')}
                   required={!editingServer}
                 ></textarea>
               )}
             </div>
           </div>
 
+          <div>
+            <label htmlFor="description" className="block text-xs font-semibold text-zinc-500 dark:text-zinc-400 uppercase tracking-wider mb-1.5 ml-1">{t('server_description')}</label>
+            <div className="relative group">
+              <FileText className="absolute left-3 top-1/2 -translate-y-1/2 w-4 h-4 text-zinc-400 dark:text-zinc-500 group-focus-within:text-emerald-500 transition-colors" />
+              <input
+                type="text"
+                id="description"
+                value={description}
+                onChange={(e) => setDescription(e.target.value)}
+                maxLength={255}
+                className="w-full pl-10 pr-3 py-2.5 bg-white dark:bg-zinc-950/50 border border-zinc-200 dark:border-zinc-800 rounded-xl text-zinc-900 dark:text-zinc-100 placeholder-zinc-400 dark:placeholder-zinc-600 focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500/50 transition-all text-sm shadow-sm"
+                placeholder={t('server_description_placeholder')}
+              />
+            </div>
+          </div>
+
+          <div>
+            <label htmlFor="notes" className="block text-xs font-semibold text-zinc-500 dark:text-zinc-400 uppercase tracking-wider mb-1.5 ml-1">{t('server_notes')}</label>
+            <textarea
+              id="notes"
+              value={notes}
+              onChange={(e) => setNotes(e.target.value)}
+              rows={4}
+              className="w-full px-3 py-3 bg-white dark:bg-zinc-950/50 border border-zinc-200 dark:border-zinc-800 rounded-xl text-zinc-900 dark:text-zinc-100 placeholder-zinc-400 dark:placeholder-zinc-600 focus:outline-none focus:ring-2 focus:ring-emerald-500/20 focus:border-emerald-500/50 transition-all text-sm font-mono shadow-sm"
+              placeholder={t('server_notes_placeholder')}
+            ></textarea>
+          </div>
+
+          {(probe || testError) && (
+            <div className="rounded-xl border border-zinc-200 dark:border-zinc-800 bg-zinc-50 dark:bg-zinc-950/50 p-3 space-y-1.5 text-sm">
+              {testError && <p className="text-red-500">{testError}</p>}
+              {probe?.stages.map((stage) => (
+                <div key={stage.stage} className="flex items-start gap-2">
+                  {stage.ok ? <CheckCircle2 className="w-4 h-4 mt-0.5 shrink-0 text-emerald-500" /> : <XCircle className="w-4 h-4 mt-0.5 shrink-0 text-red-500" />}
+                  <div className="min-w-0">
+                    <span className="text-zinc-900 dark:text-zinc-100">{t(`probe_stage_${stage.stage}`)}</span>
+                    <span className="ml-2 text-xs text-zinc-500">{stage.elapsed_ms} ms</span>
+                    {stage.stage === 'docker' && stage.ok && probe.docker_version && <span className="ml-2 text-xs text-zinc-500">Docker {probe.docker_version}</span>}
+                    {stage.error && <p className="text-xs text-red-500 break-words">{stage.error}</p>}
+                  </div>
+                </div>
+              ))}
+            </div>
+          )}
+
           <div className="flex gap-3 mt-8">
+            <button
+              type="button"
+              onClick={handleTest}
+              disabled={testing || !ip}
+              className="flex items-center justify-center gap-2 px-4 py-2.5 rounded-xl border border-zinc-200 dark:border-zinc-800 text-zinc-500 dark:text-zinc-400 hover:text-zinc-900 dark:hover:text-zinc-100 hover:bg-zinc-50 dark:hover:bg-zinc-800 transition-colors font-medium shadow-sm disabled:opacity-50"
+            >
+              {testing ? <Loader2 className="w-4 h-4 animate-spin" /> : <PlugZap className="w-4 h-4" />}
+              {t('test_connection')}
+            </button>
             <button
               type="button"
               onClick={onClose}
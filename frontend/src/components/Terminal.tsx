@@ -4,6 +4,7 @@ import { FitAddon } from 'xterm-addon-fit';
 import { WebglAddon } from 'xterm-addon-webgl';
 import { AlertCircle, Loader2, WifiOff } from 'lucide-react';
 import { useApp } from '../hooks/useApp';
+import { BASE_PATH } from '../lib/basePath';
 import 'xterm/css/xterm.css';
 
 interface TerminalProps {
@@ -13,6 +14,9 @@ interface TerminalProps {
 
 type ConnectionStatus = 'connecting' | 'connected' | 'disconnected' | 'error';
 
+// Close code the backend sends when the server stops answering SSH keepalives
+const SSH_CONNECTION_LOST = 4001;
+
 const Terminal: React.FC<TerminalProps> = ({ serverId, containerId }) => {
   const { t, theme } = useApp();
   const terminalRef = useRef<HTMLDivElement>(null);
@@ -23,6 +27,8 @@ const Terminal: React.FC<TerminalProps> = ({ serverId, containerId }) => {
 
   const [status, setStatus] = useState<ConnectionStatus>('connecting');
   const [errorMessage, setErrorMessage] = useState('');
+  // Bumped by the reconnect button to open a new session
+  const [attempt, setAttempt] = useState(0);
 
   useEffect(() => {
     if (terminalRef.current) {
@@ -120,7 +126,7 @@ const Terminal: React.FC<TerminalProps> = ({ serverId, containerId }) => {
       const protocol = window.location.protocol === 'https:' ? 'wss' : 'ws';
       const host = window.location.host;
       const containerParam = containerId ? `&container_id=${containerId}` : '';
-      const wsUrl = `${protocol}://${host}/ws/terminal?server_id=${serverId}${containerParam}&token=${token}`;
+      const wsUrl = `${protocol}://${host}${BASE_PATH}/ws/terminal?server_id=${serverId}${containerParam}&token=${token}`;
 
       try {
         websocket.current = new WebSocket(wsUrl);
@@ -138,7 +144,9 @@ const Terminal: React.FC<TerminalProps> = ({ serverId, containerId }) => {
 
         websocket.current.onclose = (event) => {
           setStatus('disconnected');
-          if (event.code !== 1000) {
+          if (event.code === SSH_CONNECTION_LOST) {
+            setErrorMessage(t('ssh_connection_lost'));
+          } else if (event.code !== 1000) {
             setErrorMessage(`${t('error')} (Code: ${event.code})`);
           }
           xtermInstance.current?.write(`\r\n\x1b[33m${t('disconnected_from_remote')}\x1b[0m\r\n`);
@@ -183,7 +191,7 @@ const Terminal: React.FC<TerminalProps> = ({ serverId, containerId }) => {
         xtermInstance.current = null;
       };
     }
-  }, [serverId, theme]);
+  }, [serverId, theme, attempt]);
 
   return (
     <div className="relative h-full w-full bg-white dark:bg-[#09090b] px-1 pb-1"> {/* Tiny padding for borders */}
@@ -217,6 +225,19 @@ const Terminal: React.FC<TerminalProps> = ({ serverId, containerId }) => {
         <div className="absolute inset-0 z-20 flex flex-col items-center justify-center bg-white/80 dark:bg-[#09090b]/80 backdrop-blur-[2px] pointer-events-none">
           <WifiOff className="w-12 h-12 text-zinc-400 dark:text-zinc-600 mb-2 opacity-50" />
           <p className="text-zinc-400 dark:text-zinc-500 font-mono text-sm">{t('session_terminated')}</p>
+          {errorMessage && (
+            <p className="text-zinc-500 text-sm mt-1">{errorMessage}</p>
+          )}
+          <button
+            onClick={() => {
+              setStatus('connecting');
+              setErrorMessage('');
+              setAttempt((n) => n + 1);
+            }}
+            className="pointer-events-auto mt-4 px-4 py-1.5 rounded-md text-sm font-medium bg-emerald-500 text-white hover:bg-emerald-600 transition-colors"
+          >
+            {t('reconnect')}
+          </button>
         </div>
       )}
 
@@ -1,5 +1,6 @@
 import React, { createContext, useContext, useState, useEffect } from 'react';
 import { Language, Theme, translations } from '../lib/translations';
+import { userApi } from '../lib/api';
 
 interface AppContextType {
     language: Language;
@@ -23,6 +24,14 @@ export const AppProvider: React.FC<{ children: React.ReactNode }> = ({ children
         localStorage.setItem('dm_language', language);
     }, [language]);
 
+    // Keep the stored preference in sync, it decides the language of API messages
+    const changeLanguage = (lang: Language) => {
+        setLanguage(lang);
+        if (localStorage.getItem('jwt_token')) {
+            userApi.setLanguage(lang === 'en' ? 'en' : 'zh-CN').catch(() => undefined);
+        }
+    };
+
     useEffect(() => {
         localStorage.setItem('dm_theme', theme);
         if (theme === 'dark') {
@@ -37,7 +46,7 @@ export const AppProvider: React.FC<{ children: React.ReactNode }> = ({ children
     };
 
     return (
-        <AppContext.Provider value={{ language, setLanguage, theme, setTheme, t }}>
+        <AppContext.Provider value={{ language, setLanguage: changeLanguage, theme, setTheme, t }}>
             {children}
         </AppContext.Provider>
     );
@@ -1,6 +1,7 @@
 import axios from 'axios';
+import { BASE_PATH } from './basePath';
 
-const API_BASE_URL = '/api/v1';
+const API_BASE_URL = `${BASE_PATH}/api/v1`;
 
 const api = axios.create({
   baseURL: API_BASE_URL,
@@ -21,6 +22,8 @@ api.interceptors.request.use(
     if (token) {
       config.headers.Authorization = `Bearer ${token}`;
     }
+    // Error messages come back in the UI language
+    config.headers['Accept-Language'] = localStorage.getItem('dm_language') === 'en' ? 'en' : 'zh-CN';
     return config;
   },
   (error) => {
@@ -31,6 +34,15 @@ api.interceptors.request.use(
 api.interceptors.response.use(
   (response) => response,
   (error) => {
+    // Flatten the error envelope {error: {code, message, request_id}} so components can keep
+    // reading data.error as a string; the request ID is appended for support.
+    const body = error.response?.data;
+    if (body && body.error && typeof body.error === 'object') {
+      const { code, message, request_id } = body.error;
+      body.error_code = code;
+      body.request_id = request_id;
+      body.error = request_id ? `${message} (request ID: ${request_id})` : message;
+    }
     if (error.response && error.response.status === 401) {
       if (onUnauthorizedCallback) {
         onUnauthorizedCallback();
@@ -52,17 +64,54 @@ export interface Server {
   port: number;
   username: string;
   auth_mode: string;
+  connection_mode?: 'cli' | 'api';
+  platform?: string;
+  description?: string;
+  // Markdown, only admins edit it
+  notes?: string;
+}
+
+// A server as GET /servers/:id returns it, with the age of the data
+export interface ServerDetails extends Server {
+  fetched_at: string;
+  cached: boolean;
 }
 
 export interface ServerPayload extends Omit<Server, 'ID' | 'CreatedAt' | 'UpdatedAt' | 'DeletedAt'> {
   secret: string;
 }
 
+// Connecting stops at the first failed stage
+export interface ServerProbe {
+  reachable: boolean;
+  authenticated: boolean;
+  docker_available: boolean;
+  docker_version?: string;
+  error?: string;
+  failed_stage?: 'reach' | 'auth' | 'docker';
+  failed_hop?: 'jump_host' | 'server';
+  elapsed_ms: number;
+  stages: { stage: 'reach' | 'auth' | 'docker'; ok: boolean; elapsed_ms: number; error?: string }[];
+}
+
 export interface ServerStats {
-  status: 'online' | 'offline' | 'loading';
+  status: 'online' | 'docker_stopped' | 'docker_missing' | 'offline' | 'loading';
+  status_detail?: string;
   cpu_usage: number;
   ram_usage: number;
+  system_stats_error?: string;
+  load_avg_1: number;
+  load_avg_5: number;
+  load_avg_15: number;
+  cpu_cores: number;
+  load_per_core: number;
+  disk_usage: number;
+  disk_total: number;
+  disk_used: number;
+  net_rx_rate: number;
+  net_tx_rate: number;
   docker_version: string;
+  platform?: string;
   uptime: string;
   running_containers: number;
   total_containers: number;
@@ -70,6 +119,193 @@ export interface ServerStats {
   latency_map: Record<string, number>;
 }
 
+export interface SystemInfo {
+  server_id: number;
+  uname: string;
+  hostname: string;
+  kernel: string;
+  architecture: string;
+  os_id: string;
+  os_name: string;
+  os_version: string;
+  docker_version: string;
+  storage_driver: string;
+  cgroup_version: string;
+  cgroup_driver: string;
+  updated_at: string;
+}
+
+export interface DockerDiskUsageType {
+  type: string;
+  total_count: number;
+  active: number;
+  size_bytes: number;
+  reclaimable_bytes: number;
+}
+
+export interface ImageDiskUsage {
+  id: string;
+  repository: string;
+  tag: string;
+  size_bytes: number;
+  shared_size_bytes: number;
+  unique_size_bytes: number;
+  containers: number;
+}
+
+export interface VolumeDiskUsage {
+  name: string;
+  links: number;
+  size_bytes: number;
+}
+
+export interface DockerDiskUsage {
+  types: DockerDiskUsageType[];
+  total_bytes: number;
+  reclaimable_bytes: number;
+  // Only set with verbose
+  images?: ImageDiskUsage[];
+  volumes?: VolumeDiskUsage[];
+}
+
+export type PruneType = 'containers' | 'images' | 'volumes' | 'networks' | 'build-cache';
+
+export interface PruneRequest {
+  types: PruneType[];
+  until?: string;
+  labels?: string[];
+  all?: boolean;
+  dry_run?: boolean;
+}
+
+export interface PruneItem {
+  id?: string;
+  name?: string;
+  size_bytes?: number;
+}
+
+export interface PruneTypeResult {
+  type: PruneType;
+  items: PruneItem[];
+  reclaimed_bytes: number;
+  error?: string;
+}
+
+export interface PruneResult {
+  dry_run: boolean;
+  types: PruneTypeResult[];
+  reclaimed_bytes: number;
+}
+
+// A volume is dangling when no container, running or not, mounts it
+export interface Volume {
+  name: string;
+  driver: string;
+  mountpoint: string;
+  scope: string;
+  created_at?: string;
+  labels: Record<string, string>;
+  size_bytes?: number;
+  containers: { container: string; destination: string; read_only: boolean }[];
+  dangling: boolean;
+}
+
+export interface VolumeDetails extends Volume {
+  options: Record<string, string>;
+  status?: Record<string, unknown>;
+}
+
+export interface VolumePruneRequest {
+  all?: boolean;
+  labels?: string[];
+  dry_run?: boolean;
+}
+
+// built_in marks bridge, host and none, which cannot be removed
+export interface Network {
+  id: string;
+  name: string;
+  driver: string;
+  scope: string;
+  created_at?: string;
+  internal: boolean;
+  ipv6: boolean;
+  subnets: { subnet: string; gateway?: string; ip_range?: string }[];
+  labels: Record<string, string>;
+  containers: { id: string; name: string; ipv4_address: string; ipv6_address?: string; mac_address: string }[];
+  built_in: boolean;
+}
+
+export interface NetworkDetails extends Network {
+  attachable: boolean;
+  options: Record<string, string>;
+}
+
+export interface NetworkCreateRequest {
+  name: string;
+  subnet?: string;
+  gateway?: string;
+  ip_range?: string;
+  internal?: boolean;
+  labels?: Record<string, string>;
+}
+
+// Sent by the /ws/events WebSocket
+export interface ContainerEvent {
+  server_id: number;
+  container_id: string;
+  name: string;
+  image: string;
+  action: 'start' | 'stop' | 'die' | 'oom';
+  exit_code?: number;
+  time: string;
+}
+
+// since and until are RFC 3339 times or durations before now, like '30m'
+export interface LogQueryOptions {
+  since?: string;
+  until?: string;
+  timestamps?: boolean;
+  grep?: string;
+}
+
+export interface LogSearchRequest {
+  pattern: string;
+  ignore_case?: boolean;
+  before?: number;
+  after?: number;
+  max_matches?: number;
+  tail?: string;
+  since?: string;
+  until?: string;
+  timestamps?: boolean;
+}
+
+export interface LogLine {
+  number: number;
+  text: string;
+}
+
+export interface LogMatch extends LogLine {
+  before?: LogLine[];
+  after?: LogLine[];
+}
+
+export interface LogSearchResponse {
+  matches: LogMatch[];
+  lines_scanned: number;
+  limit_reached: boolean;
+}
+
+// Sent by the /ws/logs WebSocket; exit and error messages are followed by the close
+export interface LogStreamMessage {
+  type: 'log' | 'exit' | 'error';
+  line?: string;
+  status?: string;
+  exit_code?: number;
+  error?: string;
+}
+
 export interface Container {
   id: string;
   server_id: number;
@@ -77,33 +313,303 @@ export interface Container {
   image: string;
   status: string;
   state: string;
+  health: 'healthy' | 'unhealthy' | 'starting' | 'none';
   ports: string[];
   created_at: string;
   user_id: number;
   permission: string;
+  compose_project?: string;
+  compose_service?: string;
+  // Only set by listContainerStats, for running containers
+  cpu_percent?: number;
+  mem_usage?: number;
+  mem_limit?: number;
+  net_io?: string;
+  block_io?: string;
+}
+
+export interface ContainerGroup {
+  project: string;
+  containers: Container[];
 }
 
 export interface ContainerListResponse {
   containers: Container[];
   total: number;
+  filtered: number;
+  page?: number;
+  page_size?: number;
+  // When the list was read from docker; it may be served for a while after that
+  fetched_at: string;
+  // Whether the list came from the cache rather than being read for this request
+  cached: boolean;
+}
+
+// Filters, order and page of a container list; label entries are "key" or "key=value"
+export interface ContainerListQuery {
+  state?: 'created' | 'restarting' | 'running' | 'removing' | 'paused' | 'exited' | 'dead';
+  name?: string;
+  image?: string;
+  label?: string[];
+  sort?: 'name' | 'created' | 'state';
+  order?: 'asc' | 'desc';
+  page?: number;
+  page_size?: number;
+}
+
+export interface HealthProbe {
+  start: string;
+  end: string;
+  exit_code: number;
+  output: string;
+}
+
+export interface ContainerHealth {
+  container_id: string;
+  status: 'healthy' | 'unhealthy' | 'starting' | 'none';
+  failing_streak: number;
+  probes: HealthProbe[];
+}
+
+export interface ContainerStatsSample {
+  time: string;
+  cpu_percent: number;
+  mem_usage: number;
+  mem_limit: number;
+  net_io: string;
+  block_io: string;
+}
+
+// The latest reading, and every reading when more than one sample was taken
+export interface ContainerStats extends ContainerStatsSample {
+  container_id: string;
+  samples?: ContainerStatsSample[];
+}
+
+// cpu_percent and mem_percent are missing for containers with a BusyBox ps
+export interface ContainerProcess {
+  pid: number;
+  ppid: number;
+  user: string;
+  cpu_percent?: number;
+  mem_percent?: number;
+  elapsed?: string;
+  command: string;
+}
+
+export interface ContainerProcessesResponse {
+  titles: string[];
+  processes: ContainerProcess[];
 }
 
 export interface ContainerActionRequest {
   server_id: number;
   container_id: string;
-  action: 'start' | 'stop' | 'restart' | 'remove';
+  action: 'start' | 'stop' | 'restart' | 'pause' | 'unpause' | 'kill' | 'rename' | 'remove' | 'pull' | 'update';
+  // kill only, SIGKILL by default
+  signal?: string;
+  // rename only
+  new_name?: string;
+}
+
+export interface ContainerCreateRequest {
+  image: string;
+  name: string;
+  env?: string[];
+  ports?: string[];
+  volumes?: string[];
+  restart_policy?: string;
+  network?: string;
+  command?: string[];
+}
+
+export interface ContainerCreateResult {
+  container_id: string;
+  name: string;
+  pulled: boolean;
+}
+
+export interface BatchActionRequest {
+  action: 'start' | 'stop' | 'restart' | 'pause' | 'unpause' | 'kill' | 'remove';
+  container_ids: string[];
+  signal?: string;
+}
+
+export interface BatchActionResult {
+  container_id: string;
+  success: boolean;
+  message?: string;
+  error?: string;
+}
+
+export interface BatchActionResponse {
+  action: string;
+  succeeded: number;
+  failed: number;
+  results: BatchActionResult[];
+}
+
+export interface PullLayer {
+  id: string;
+  status: string;
+  current?: number;
+  total?: number;
+  percent: number;
+}
+
+// PullTask is the image pull or container update the "pull" and "update" actions start in the
+// background, or a pull of an image by name, "pull_image"
+export interface PullTask {
+  id: string;
+  server_id: number;
+  container_id: string;
+  image?: string;
+  action: 'pull' | 'update' | 'pull_image';
+  new_container_id?: string;
+  status: 'running' | 'succeeded' | 'failed';
+  error?: string;
+  error_code?: 'image_not_found' | 'registry_unauthorized' | 'registry_rate_limited' | 'disk_full';
+  percent: number;
+  layers: PullLayer[];
+  output: string;
+  next_offset: number;
+  started_at: string;
+  finished_at?: string;
 }
 
 export interface ContainerLogResponse {
   logs: string;
+  limit_reached?: boolean;
+}
+
+export interface RestartPolicy {
+  name: 'no' | 'always' | 'unless-stopped' | 'on-failure';
+  maximum_retry_count: number;
 }
 
+// Values of environment variables named like passwords, secrets or tokens are masked for
+// non-admins
 export interface ContainerDetailsResponse {
+  id: string;
+  name: string;
+  image: string;
+  image_id: string;
+  created: string;
+  restart_count: number;
+  state: {
+    status: string;
+    running: boolean;
+    paused: boolean;
+    restarting: boolean;
+    oom_killed: boolean;
+    pid: number;
+    exit_code: number;
+    error?: string;
+    started_at: string;
+    finished_at: string;
+  };
+  config: {
+    hostname: string;
+    user: string;
+    working_dir: string;
+    env: string[];
+    cmd: string[];
+    entrypoint: string[];
+    labels: Record<string, string>;
+    tty: boolean;
+  };
+  network: {
+    mode: string;
+    networks: { name: string; network_id: string; link: string; ip_address: string; ipv6_address?: string; gateway: string; mac_address: string; aliases: string[] }[];
+    ports: { container_port: string; host_ip?: string; host_port?: string }[];
+  };
+  mounts: { type: string; name?: string; source: string; destination: string; mode: string; rw: boolean }[];
+  restart_policy: RestartPolicy;
+  health: ContainerHealth;
+  resources: {
+    memory_bytes: number;
+    memory_reservation_bytes: number;
+    memory_swap_bytes: number;
+    cpus: number;
+    cpu_shares: number;
+    cpuset_cpus?: string;
+    pids_limit: number;
+  };
+  gpus: { count: number; device_ids?: string[]; driver?: string } | null;
+}
+
+// Returned with ?raw=true
+export interface ContainerDetailsRawResponse {
   details: string; // Raw JSON string from docker inspect
+  restart_policy: RestartPolicy | null;
 }
 
 export interface ContainerImageUpdateResponse {
   has_update: boolean;
+  image: string;
+  platform: string;
+  local_digests: string[];
+  remote_digest: string;
+  remote_platform_digest?: string;
+  checked_at: string;
+}
+
+export interface ImageCheck {
+  id: number;
+  server_id: number;
+  container: string;
+  image: string;
+  local_digest: string;
+  remote_digest: string;
+  has_update: boolean;
+  error?: string;
+  checked_at: string;
+  auto_update: boolean;
+}
+
+export interface AutoUpdateRun {
+  id: number;
+  server_id: number;
+  container: string;
+  image: string;
+  old_digest: string;
+  new_digest?: string;
+  status: 'succeeded' | 'failed';
+  error?: string;
+  started_at: string;
+  finished_at: string;
+}
+
+export interface ComposeProject {
+  name: string;
+  working_dir: string;
+  config_files: string[];
+  services: string[];
+  containers: number;
+  running: number;
+}
+
+export type ComposeAction = 'up' | 'down' | 'restart' | 'pull';
+
+export interface ComposeActionResult {
+  project: string;
+  action: ComposeAction;
+  output: string;
+  exit_code: number;
+}
+
+export interface ComposeFile {
+  project: string;
+  path: string;
+  files: string[];
+  content: string;
+}
+
+export interface ComposeFileResult {
+  project: string;
+  path: string;
+  backup: string;
+  services: { added: string[]; removed: string[]; changed: string[] };
 }
 
 export interface FileEntry {
@@ -111,7 +617,8 @@ export interface FileEntry {
   size: number;
   mode: string;
   is_dir: boolean;
-  is_symlink: boolean; // Added to handle symbolic links
+  is_symlink: boolean; // Added to handle symbolic links; is_dir is set too when one resolves to a directory
+  link_target?: string; // Where a symbolic link points
   mod_time: string; // ISO 8601 string
   permissions: string; // Octal string, e.g., "755"
 }
@@ -119,35 +626,128 @@ export interface FileEntry {
 export interface FileListResponse {
   path: string;
   files: FileEntry[];
+  // Set when a volume listing stopped at 5000 entries
+  truncated?: boolean;
 }
 
 export interface FileContentResponse {
   path: string;
   content: string;
+  encoding?: 'base64';
+  size: number; // bytes of the file in content
+  total_size: number; // -1 when too large and of unknown size
+  offset: number; // where content starts in the file
+  eof: boolean;
+  is_binary: boolean; // content is empty; download the file instead
+  too_large?: boolean;
+  hint?: string;
+}
+
+// FileRange selects part of a file: limit bytes from offset, or the first or last lines
+export interface FileRange {
+  offset?: number;
+  limit?: number;
+  head?: number;
+  tail?: number;
+}
+
+export interface FileOpRequest {
+  op: 'delete' | 'rename' | 'mkdir' | 'chmod';
+  path: string;
+  new_path?: string; // rename target, which must not exist
+  mode?: string; // chmod octal mode, e.g. "755"
+  recursive?: boolean; // delete non-empty directories, chmod directory contents
 }
 
 export const containerApi = {
-  listContainers: (serverId: string) => api.get<ContainerListResponse>(`/servers/${serverId}/containers`),
+  listContainers: (serverId: string, refresh = false, query?: ContainerListQuery) =>
+    api.get<ContainerListResponse>(`/servers/${serverId}/containers`, {
+      params: { ...query, ...(refresh ? { refresh: true } : {}) },
+      paramsSerializer: { indexes: null },
+    }),
+  listContainerStats: (serverId: string, refresh = false) =>
+    api.get<ContainerListResponse>(`/servers/${serverId}/containers/stats`, { params: refresh ? { refresh: true } : undefined }),
+  createContainer: (serverId: string, req: ContainerCreateRequest) => api.post<ContainerCreateResult>(`/servers/${serverId}/containers`, req),
   containerAction: (req: ContainerActionRequest) => api.post(`/servers/${req.server_id}/containers/action`, req),
-  getContainerLogs: (serverId: string, containerId: string, tail: string = 'all') => api.get<ContainerLogResponse>(`/servers/${serverId}/containers/${containerId}/logs?tail=${tail}`),
+  batchContainerAction: (serverId: string, req: BatchActionRequest) => api.post<BatchActionResponse>(`/servers/${serverId}/containers/batch-action`, req),
+  getContainerLogs: (serverId: string, containerId: string, tail: string = 'all', options?: LogQueryOptions) =>
+    api.get<ContainerLogResponse>(`/servers/${serverId}/containers/${containerId}/logs`, { params: { tail, ...options } }),
+  searchContainerLogs: (serverId: string, containerId: string, data: LogSearchRequest) =>
+    api.post<LogSearchResponse>(`/servers/${serverId}/containers/${containerId}/logs/search`, data),
   getContainerDetails: (serverId: string, containerId: string) => api.get<ContainerDetailsResponse>(`/servers/${serverId}/containers/${containerId}/details`),
+  getContainerDetailsRaw: (serverId: string, containerId: string) =>
+    api.get<ContainerDetailsRawResponse>(`/servers/${serverId}/containers/${containerId}/details`, { params: { raw: true } }),
+  updateRestartPolicy: (serverId: string, containerId: string, policy: string) => api.put<RestartPolicy>(`/servers/${serverId}/containers/${containerId}/restart-policy`, { policy }),
+  getContainerHealth: (serverId: string, containerId: string, limit?: number) => api.get<ContainerHealth>(`/servers/${serverId}/containers/${containerId}/health`, { params: limit ? { limit } : undefined }),
+  getContainerStats: (serverId: string, containerId: string, samples?: number, interval?: number) =>
+    api.get<ContainerStats>(`/servers/${serverId}/containers/${containerId}/stats`, { params: samples ? { samples, interval } : undefined }),
+  listContainerProcesses: (serverId: string, containerId: string) => api.get<ContainerProcessesResponse>(`/servers/${serverId}/containers/${containerId}/processes`),
+  getPullTask: (taskId: string, offset: number = 0) => api.get<PullTask>(`/tasks/${taskId}`, { params: { offset } }),
   checkContainerImageUpdate: (serverId: string, containerId: string) => api.get<ContainerImageUpdateResponse>(`/servers/${serverId}/containers/${containerId}/check-update`),
+  setContainerAutoUpdate: (serverId: string, containerId: string, enabled: boolean) =>
+    api.put<{ container: string; auto_update: boolean }>(`/servers/${serverId}/containers/${containerId}/auto-update`, { enabled }),
+  listImageChecks: (serverId: string) => api.get<{ checks: ImageCheck[] }>(`/servers/${serverId}/image-updates`),
+  listAutoUpdateRuns: (serverId: string, limit?: number) =>
+    api.get<{ runs: AutoUpdateRun[] }>(`/servers/${serverId}/auto-updates`, { params: limit ? { limit } : undefined }),
 
   // File Management
   listContainerFiles: (serverId: string, containerId: string, path: string = '/') =>
     api.get<FileListResponse>(`/servers/${serverId}/containers/${containerId}/files`, { params: { path } }),
 
-  getContainerFileContent: (serverId: string, containerId: string, path: string) =>
-    api.get<FileContentResponse>(`/servers/${serverId}/containers/${containerId}/files/content`, { params: { path } }),
+  getContainerFileContent: (serverId: string, containerId: string, path: string, encoding?: 'base64', range?: FileRange) =>
+    api.get<FileContentResponse>(`/servers/${serverId}/containers/${containerId}/files/content`, { params: { path, encoding, ...range } }),
+
+  downloadContainerFile: (serverId: string, containerId: string, path: string) =>
+    api.get<Blob>(`/servers/${serverId}/containers/${containerId}/files/download`, { params: { path }, responseType: 'blob' }),
+
+  containerFileOp: (serverId: string, containerId: string, req: FileOpRequest) =>
+    api.post<FileListResponse>(`/servers/${serverId}/containers/${containerId}/files/op`, req),
 };
 
 export const serverApi = {
-  listServers: () => api.get<Server[]>('/servers'),
+  // q only lists servers whose name, IP or description contains it
+  listServers: (refresh = false, q?: string) =>
+    api.get<Server[]>('/servers', { params: refresh || q ? { refresh: refresh || undefined, q: q || undefined } : undefined }),
   createServer: (server: ServerPayload) => api.post<Server>('/servers', server),
-  getServer: (id: string) => api.get<Server>(`/servers/${id}`),
+  getServer: (id: string, refresh = false) => api.get<ServerDetails>(`/servers/${id}`, { params: refresh ? { refresh: true } : undefined }),
   updateServer: (id: string, server: Partial<ServerPayload>) => api.put<Server>(`/servers/${id}`, server),
+  // With server_id the given fields replace the stored server's
+  testServerConnection: (server: Partial<ServerPayload> & { server_id?: number }) =>
+    api.post<ServerProbe>('/servers/test-connection', server),
   deleteServer: (id: string) => api.delete(`/servers/${id}`),
   getServerStats: (id: string) => api.get<ServerStats>(`/servers/${id}/stats`),
+  getSystemInfo: (id: string, refresh = false) => api.get<SystemInfo>(`/servers/${id}/system-info`, { params: refresh ? { refresh: true } : undefined }),
+  getDockerDiskUsage: (id: string, verbose = false) => api.get<DockerDiskUsage>(`/servers/${id}/docker/disk-usage`, { params: verbose ? { verbose: true } : undefined }),
+  pruneDocker: (id: string, req: PruneRequest) => api.post<PruneResult>(`/servers/${id}/docker/prune`, req),
+  listVolumes: (id: string) => api.get<{ volumes: Volume[] }>(`/servers/${id}/volumes`),
+  getVolume: (id: string, name: string) => api.get<VolumeDetails>(`/servers/${id}/volumes/${name}`),
+  deleteVolume: (id: string, name: string) => api.delete(`/servers/${id}/volumes/${name}`),
+  pruneVolumes: (id: string, req: VolumePruneRequest) => api.post<PruneResult>(`/servers/${id}/volumes/prune`, req),
+  listVolumeFiles: (id: string, name: string, path: string = '/') =>
+    api.get<FileListResponse>(`/servers/${id}/volumes/${name}/files`, { params: { path } }),
+  getVolumeFileContent: (id: string, name: string, path: string, encoding?: 'base64', range?: FileRange) =>
+    api.get<FileContentResponse>(`/servers/${id}/volumes/${name}/files/content`, { params: { path, encoding, ...range } }),
+  listNetworks: (id: string) => api.get<{ networks: Network[] }>(`/servers/${id}/networks`),
+  getNetwork: (id: string, name: string) => api.get<NetworkDetails>(`/servers/${id}/networks/${name}`),
+  createNetwork: (id: string, req: NetworkCreateRequest) => api.post<NetworkDetails>(`/servers/${id}/networks`, req),
+  deleteNetwork: (id: string, name: string) => api.delete(`/servers/${id}/networks/${name}`),
+  connectNetwork: (id: string, name: string, container: string, opts?: { ipv4_address?: string; aliases?: string[] }) =>
+    api.post(`/servers/${id}/networks/${name}/connect`, { container, ...opts }),
+  disconnectNetwork: (id: string, name: string, container: string, force = false) =>
+    api.post(`/servers/${id}/networks/${name}/disconnect`, { container, force }),
+  pullImage: (id: string, image: string, tag?: string) => api.post<PullTask>(`/servers/${id}/images/pull`, { image, tag }),
+  listComposeProjects: (id: string, refresh = false) =>
+    api.get<{ projects: ComposeProject[] }>(`/servers/${id}/compose`, { params: refresh ? { refresh: true } : undefined }),
+  composeAction: (id: string, project: string, action: ComposeAction) =>
+    api.post<ComposeActionResult>(`/servers/${id}/compose/${project}/action`, { action }),
+  getComposeFile: (id: string, project: string, file?: string) =>
+    api.get<ComposeFile>(`/servers/${id}/compose/${project}/file`, { params: file ? { file } : undefined }),
+  updateComposeFile: (id: string, project: string, content: string, file?: string) =>
+    api.put<ComposeFileResult>(`/servers/${id}/compose/${project}/file`, { file, content }),
+};
+
+export const userApi = {
+  setLanguage: (language: 'en' | 'zh-CN' | '') => api.put<{ language: string }>('/users/language', { language }),
 };
 
 // Export individual methods for easier use in components
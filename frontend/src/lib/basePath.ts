@@ -0,0 +1,3 @@
+// BASE_PATH is the sub-path the panel is served under, e.g. "/docker", or "" at the root.
+// The backend injects <base href="..."> into index.html; the Vite dev server does not, so it falls back to the root.
+export const BASE_PATH = (document.querySelector('base')?.getAttribute('href') ?? '/').replace(/\/+$/, '');
@@ -5,10 +5,11 @@ import App from './App.tsx'
 import './index.css'
 import { AuthProvider } from './hooks/useAuth.tsx'
 import { AppProvider } from './hooks/useApp.tsx'
+import { BASE_PATH } from './lib/basePath'
 
 ReactDOM.createRoot(document.getElementById('root')!).render(
   <React.StrictMode>
-    <BrowserRouter>
+    <BrowserRouter basename={BASE_PATH || '/'}>
       <AppProvider>
         <AuthProvider>
           <App />
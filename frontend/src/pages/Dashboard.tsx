@@ -56,6 +56,12 @@ const Dashboard: React.FC = () => {
             status: 'loading',
             cpuUsage: 0,
             ramUsage: 0,
+            diskUsage: 0,
+            netRxRate: 0,
+            netTxRate: 0,
+            loadAvg: [0, 0, 0],
+            cpuCores: 0,
+            loadPerCore: 0,
             running_containers: 0
           };
         });
@@ -90,8 +96,15 @@ const Dashboard: React.FC = () => {
                 return {
                   ...s,
                   status: sData.status,
+                  statusDetail: sData.status_detail,
                   cpuUsage: sData.cpu_usage,
                   ramUsage: sData.ram_usage,
+                  diskUsage: sData.disk_usage,
+                  netRxRate: sData.net_rx_rate,
+                  netTxRate: sData.net_tx_rate,
+                  loadAvg: [sData.load_avg_1, sData.load_avg_5, sData.load_avg_15],
+                  cpuCores: sData.cpu_cores,
+                  loadPerCore: sData.load_per_core,
                   dockerVersion: sData.docker_version,
                   uptime: sData.uptime,
                   running_containers: sData.running_containers,
@@ -1,7 +1,8 @@
 import React, { useState, useEffect } from 'react';
 import {
   Server as ServerIcon,
-  Plus
+  Plus,
+  ArrowDownWideNarrow
 } from 'lucide-react';
 import { serverApi, Server, ServerPayload, ServerStats } from '../lib/api';
 import ServerModal from '../components/ServerModal';
@@ -12,8 +13,16 @@ import { useApp } from '../hooks/useApp';
 
 interface ServerWithStatus extends Server {
   status: ServerStats['status'];
+  statusDetail?: ServerStats['status_detail'];
   cpuUsage: ServerStats['cpu_usage'];
   ramUsage: ServerStats['ram_usage'];
+  systemStatsError?: ServerStats['system_stats_error'];
+  diskUsage: ServerStats['disk_usage'];
+  netRxRate: ServerStats['net_rx_rate'];
+  netTxRate: ServerStats['net_tx_rate'];
+  loadAvg: [number, number, number];
+  cpuCores: ServerStats['cpu_cores'];
+  loadPerCore: ServerStats['load_per_core'];
   dockerVersion: ServerStats['docker_version'];
   uptime: ServerStats['uptime'];
 }
@@ -25,6 +34,7 @@ const Servers: React.FC = () => {
   const [editingServer, setEditingServer] = useState<Server | null>(null);
   const [serverToDelete, setServerToDelete] = useState<Server | null>(null);
   const [activeMenu, setActiveMenu] = useState<number | null>(null);
+  const [sortByLoad, setSortByLoad] = useState(false);
   const { user } = useAuth();
   const { t } = useApp();
 
@@ -59,6 +69,12 @@ const Servers: React.FC = () => {
             status: 'loading' as const,
             cpuUsage: 0,
             ramUsage: 0,
+            diskUsage: 0,
+            netRxRate: 0,
+            netTxRate: 0,
+            loadAvg: [0, 0, 0] as [number, number, number],
+            cpuCores: 0,
+            loadPerCore: 0,
             dockerVersion: '...',
             uptime: '-'
           };
@@ -79,13 +95,21 @@ const Servers: React.FC = () => {
               if (s.ID === server.ID) {
                 // Logic to prevent 0-stats overwrite (from original code)
                 if (stats.cpu_usage === 0 && stats.ram_usage === 0 && (s.cpuUsage !== 0 || s.ramUsage !== 0)) {
-                  return { ...s, status: stats.status };
+                  return { ...s, status: stats.status, statusDetail: stats.status_detail, systemStatsError: stats.system_stats_error };
                 }
                 return {
                   ...s,
                   status: stats.status,
+                  statusDetail: stats.status_detail,
                   cpuUsage: stats.cpu_usage,
                   ramUsage: stats.ram_usage,
+                  systemStatsError: stats.system_stats_error,
+                  diskUsage: stats.disk_usage,
+                  netRxRate: stats.net_rx_rate,
+                  netTxRate: stats.net_tx_rate,
+                  loadAvg: [stats.load_avg_1, stats.load_avg_5, stats.load_avg_15] as [number, number, number],
+                  cpuCores: stats.cpu_cores,
+                  loadPerCore: stats.load_per_core,
                   dockerVersion: stats.docker_version,
                   uptime: stats.uptime,
                 };
@@ -160,6 +184,11 @@ const Servers: React.FC = () => {
     setActiveMenu(null);
   };
 
+  // Busiest servers first when sorting by load, comparing load per core so server sizes don't matter
+  const sortedServers = sortByLoad
+    ? [...servers].sort((a, b) => (b.loadPerCore ?? 0) - (a.loadPerCore ?? 0))
+    : servers;
+
   return (
     <div className="max-w-6xl mx-auto space-y-8 animate-in fade-in duration-500">
 
@@ -174,22 +203,34 @@ const Servers: React.FC = () => {
           </h1>
           <p className="text-zinc-500 dark:text-zinc-400 mt-2 ml-1">{t('server_mgmt_desc')}</p>
         </div>
-        {user?.role === 'admin' && (
+        <div className="flex items-center gap-3">
           <button
-            onClick={() => { setEditingServer(null); setIsModalOpen(true); }}
-            className="group bg-gradient-to-r from-emerald-500 to-emerald-600 hover:from-emerald-400 hover:to-emerald-500 text-zinc-950 font-bold py-2.5 px-5 rounded-xl flex items-center gap-2 transition-all shadow-lg shadow-emerald-500/20 hover:shadow-emerald-500/30 hover:-translate-y-0.5"
+            onClick={() => setSortByLoad(!sortByLoad)}
+            className={`py-2.5 px-4 rounded-xl flex items-center gap-2 text-sm font-medium border transition-colors ${sortByLoad
+              ? 'bg-emerald-500/10 border-emerald-500/30 text-emerald-500'
+              : 'border-zinc-200 dark:border-zinc-800 text-zinc-500 hover:text-zinc-900 dark:hover:text-zinc-200'
+              }`}
           >
-            <Plus className="w-5 h-5 transition-transform group-hover:rotate-90" />
-            {t('connect_server')}
+            <ArrowDownWideNarrow className="w-4 h-4" />
+            {t('sort_by_load')}
           </button>
-        )}
+          {user?.role === 'admin' && (
+            <button
+              onClick={() => { setEditingServer(null); setIsModalOpen(true); }}
+              className="group bg-gradient-to-r from-emerald-500 to-emerald-600 hover:from-emerald-400 hover:to-emerald-500 text-zinc-950 font-bold py-2.5 px-5 rounded-xl flex items-center gap-2 transition-all shadow-lg shadow-emerald-500/20 hover:shadow-emerald-500/30 hover:-translate-y-0.5"
+            >
+              <Plus className="w-5 h-5 transition-transform group-hover:rotate-90" />
+              {t('connect_server')}
+            </button>
+          )}
+        </div>
       </header>
 
       {/* Grid Layout */}
       <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-3 gap-6">
 
         {/* Server Cards */}
-        {servers.map((server) => (
+        {sortedServers.map((server) => (
           <ServerCard
             key={server.ID}
             server={server}
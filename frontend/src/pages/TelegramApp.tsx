@@ -7,6 +7,7 @@ import {
   Activity,
   Cpu,
   HardDrive,
+  Database,
   Clock,
   ArrowLeft,
   RefreshCw,
@@ -40,8 +41,10 @@ interface TelegramContainer {
 interface TelegramServerStats {
   server_name: string;
   status: string;
+  status_detail?: string;
   cpu_usage: number;
   ram_usage: number;
+  disk_usage: number;
   docker_version: string;
   uptime: string;
   running_containers: number;
@@ -229,6 +232,13 @@ const TelegramApp: React.FC = () => {
               </div>
               <div className="text-2xl font-bold text-amber-400">{summary.running_containers}</div>
             </div>
+            <div className="bg-zinc-700/50 rounded-lg p-3 col-span-2">
+              <div className="flex items-center gap-2 mb-1">
+                <AlertCircle className="w-4 h-4 text-red-400" />
+                <span className="text-zinc-400 text-xs">不健康</span>
+              </div>
+              <div className="text-2xl font-bold text-red-400">{summary.unhealthy_containers ?? 0}</div>
+            </div>
           </div>
         ) : (
           <div className="text-center text-zinc-500 py-4">加载中...</div>
@@ -314,6 +324,9 @@ const TelegramApp: React.FC = () => {
                   <span className="text-sm text-zinc-400">{serverStats.status}</span>
                 </div>
               </div>
+              {serverStats.status_detail && (
+                <div className="text-xs text-amber-400 break-words">{serverStats.status_detail}</div>
+              )}
 
               <div className="grid grid-cols-2 gap-3">
                 <div className="bg-zinc-700/50 rounded-lg p-3">
@@ -330,6 +343,13 @@ const TelegramApp: React.FC = () => {
                   </div>
                   <div className="text-xl font-bold text-white">{serverStats.ram_usage.toFixed(1)}%</div>
                 </div>
+                <div className="bg-zinc-700/50 rounded-lg p-3">
+                  <div className="flex items-center gap-2 mb-1">
+                    <Database className="w-4 h-4 text-cyan-400" />
+                    <span className="text-zinc-400 text-xs">磁盘使用率</span>
+                  </div>
+                  <div className="text-xl font-bold text-white">{(serverStats.disk_usage ?? 0).toFixed(1)}%</div>
+                </div>
                 <div className="bg-zinc-700/50 rounded-lg p-3">
                   <div className="flex items-center gap-2 mb-1">
                     <Activity className="w-4 h-4 text-amber-400" />
@@ -5,6 +5,8 @@ import path from 'path'
 // https://vitejs.dev/config/
 export default defineConfig({
   plugins: [react()],
+  // Relative asset URLs resolve against the <base href> the backend injects, so one build works under any sub-path
+  base: './',
   resolve: {
     alias: {
       "@": path.resolve(__dirname, "./src"),